@@ -143,6 +143,156 @@ const (
 	// Note: The instruction list is too long to enumerate in godoc.
 	// See https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 	CoreFeatureSIMD
+
+	// CoreFeatureThreads enables shared memories and atomic instructions
+	// ("threads"). This is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Memories may be declared `shared`, which requires a maximum size.
+	//   - Adds atomic loads, stores and read-modify-write instructions, such
+	//     as `i32.atomic.load` and `i64.atomic.rmw.cmpxchg`.
+	//   - Adds `memory.atomic.wait32`, `memory.atomic.wait64`,
+	//     `memory.atomic.notify` and `atomic.fence` instructions.
+	//
+	// Note: wazero does not create threads. A shared memory can be used by
+	// multiple modules which are called concurrently from different
+	// goroutines by the embedder. Shared memories are only supported on
+	// Linux, and only when 32-bit, as they are reserved in virtual memory
+	// so that they never move as they grow.
+	//
+	// See https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md
+	CoreFeatureThreads
+
+	// CoreFeatureTailCall enables tail calls ("tail-call"). This is not
+	// included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Adds `return_call` and `return_call_indirect` instructions, which
+	//     replace the current call frame with the one of the callee instead
+	//     of pushing a new frame. This allows deep mutual recursion without
+	//     growing the call stack.
+	//
+	// See https://github.com/WebAssembly/tail-call/blob/main/proposals/tail-call/Overview.md
+	CoreFeatureTailCall
+
+	// CoreFeatureMultiMemory enables multiple memories ("multi-memory"). This
+	// is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - A module can import and define more than one memory.
+	//   - Load, store and bulk memory instructions can take a memory index
+	//     immediate, as well as active data segments.
+	//   - api.Module ExportedMemory returns each exported memory, not only
+	//     the first one.
+	//
+	// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+	CoreFeatureMultiMemory
+
+	// CoreFeatureMemory64 enables 64-bit linear memories ("memory64"). This
+	// is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Memories may be declared with an `i64` index type, which allows
+	//     more than 65536 pages (4GiB). See RuntimeConfig.WithMemoryLimitPages.
+	//   - Load, store and bulk memory instructions on such memories take
+	//     `i64` addresses, and `memory.size` and `memory.grow` use `i64`.
+	//   - api.Memory Size64, Read64 and Write64 allow accessing them beyond
+	//     the 32-bit offsets.
+	//
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+	CoreFeatureMemory64
+
+	// CoreFeatureExceptionHandling enables exceptions ("exception-handling").
+	// This is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Adds the tag section, and tags can be imported and exported.
+	//   - Adds `try`, `catch`, `catch_all`, `delegate`, `throw` and
+	//     `rethrow` instructions.
+	//   - A function defined by HostModuleBuilder can throw by panicking
+	//     with an api.Exception, and api.Function Call returns an
+	//     uncaught exception as an *api.Exception error.
+	//
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+	CoreFeatureExceptionHandling
+
+	// CoreFeatureExtendedConst enables extended constant expressions
+	// ("extended-const"). This is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Constant expressions of globals, element and data segment offsets
+	//     may consist of multiple instructions.
+	//   - Adds `i32.add`, `i32.sub`, `i32.mul`, `i64.add`, `i64.sub` and
+	//     `i64.mul` to constant expressions.
+	//
+	// See https://github.com/WebAssembly/extended-const/blob/main/proposals/extended-const/Overview.md
+	CoreFeatureExtendedConst
+
+	// CoreFeatureFunctionReferences enables typed function references
+	// ("function-references"). This is not included in CoreFeaturesV2.
+	//
+	// Here are the notable effects:
+	//   - Adds typed reference value types `(ref $t)` and `(ref null $t)`,
+	//     which are passed to and from the host as ValueTypeFuncref or
+	//     ValueTypeExternref.
+	//   - Locals of a non-nullable reference type must be set before use.
+	//   - Adds `call_ref`, `return_call_ref`, `ref.as_non_null`,
+	//     `br_on_null` and `br_on_non_null` instructions. `return_call_ref`
+	//     also requires CoreFeatureTailCall.
+	//   - `ref.func` results in a non-nullable reference of the function type.
+	//
+	// See https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md
+	CoreFeatureFunctionReferences
+
+	// CoreFeatureGC enables garbage collected structs and arrays ("gc").
+	// This is not included in CoreFeaturesV2, and requires
+	// CoreFeatureFunctionReferences.
+	//
+	// Here are the notable effects:
+	//   - The type section may define struct and array types, grouped by
+	//     `rec` and declaring supertypes with `sub`.
+	//   - Adds the abstract heap types `any`, `eq`, `struct`, `array` and
+	//     `none`, whose references are passed to and from the host as
+	//     ValueTypeAnyref. Module.GCObject inspects them.
+	//   - Adds `struct.new`, `struct.new_default`, `struct.get`,
+	//     `struct.get_s`, `struct.get_u`, `struct.set`, `array.new`,
+	//     `array.new_default`, `array.new_fixed`, `array.get`, `array.get_s`,
+	//     `array.get_u`, `array.set`, `array.len`, `ref.test`, `ref.cast`
+	//     and `ref.eq` instructions.
+	//   - `ref.test` and `ref.cast` are only supported on references of the
+	//     `any` hierarchy, and `i31` is not supported.
+	//
+	// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md
+	CoreFeatureGC
+
+	// CoreFeatureRelaxedSIMD enables relaxed vector instructions
+	// ("relaxed-simd"). This is not included in CoreFeaturesV2, and requires
+	// CoreFeatureSIMD.
+	//
+	// Here are the notable effects:
+	//   - Adds `i8x16.relaxed_swizzle`, `i32x4.relaxed_trunc_f32x4_s`,
+	//     `i32x4.relaxed_trunc_f32x4_u`, `i32x4.relaxed_trunc_f64x2_s_zero`,
+	//     `i32x4.relaxed_trunc_f64x2_u_zero`, `f32x4.relaxed_madd`,
+	//     `f32x4.relaxed_nmadd`, `f64x2.relaxed_madd`, `f64x2.relaxed_nmadd`,
+	//     `i8x16.relaxed_laneselect`, `i16x8.relaxed_laneselect`,
+	//     `i32x4.relaxed_laneselect`, `i64x2.relaxed_laneselect`,
+	//     `f32x4.relaxed_min`, `f32x4.relaxed_max`, `f64x2.relaxed_min`,
+	//     `f64x2.relaxed_max`, `i16x8.relaxed_q15mulr_s`,
+	//     `i16x8.relaxed_dot_i8x16_i7x16_s` and
+	//     `i32x4.relaxed_dot_i8x16_i7x16_add_s` instructions.
+	//   - The results are deterministic by default: each instruction behaves
+	//     as its non-relaxed counterpart, e.g. `i8x16.swizzle`, and
+	//     `relaxed_madd` rounds the product before adding. The dot products
+	//     treat both operands as signed and wrap on overflow.
+	//   - The compiler engine on amd64 lowers `relaxed_madd` and
+	//     `relaxed_nmadd` to FMA (if the CPU supports it), and
+	//     `relaxed_swizzle` and the dot products to PSHUFB and PMADDUBSW
+	//     when experimental.RelaxedSIMDNativeKey is set, which can give
+	//     different results on different CPUs for inputs the proposal leaves
+	//     implementation-defined.
+	//
+	// See https://github.com/WebAssembly/relaxed-simd/blob/main/proposals/relaxed-simd/Overview.md
+	CoreFeatureRelaxedSIMD
 )
 
 // SetEnabled enables or disables the feature or group of features.
@@ -207,6 +357,33 @@ func featureName(f CoreFeatures) string {
 	case CoreFeatureSIMD:
 		// match https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 		return "simd"
+	case CoreFeatureThreads:
+		// match https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md
+		return "threads"
+	case CoreFeatureTailCall:
+		// match https://github.com/WebAssembly/tail-call/blob/main/proposals/tail-call/Overview.md
+		return "tail-call"
+	case CoreFeatureMultiMemory:
+		// match https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+		return "multi-memory"
+	case CoreFeatureMemory64:
+		// match https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+		return "memory64"
+	case CoreFeatureExceptionHandling:
+		// match https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+		return "exception-handling"
+	case CoreFeatureExtendedConst:
+		// match https://github.com/WebAssembly/extended-const/blob/main/proposals/extended-const/Overview.md
+		return "extended-const"
+	case CoreFeatureFunctionReferences:
+		// match https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md
+		return "function-references"
+	case CoreFeatureGC:
+		// match https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md
+		return "gc"
+	case CoreFeatureRelaxedSIMD:
+		// match https://github.com/WebAssembly/relaxed-simd/blob/main/proposals/relaxed-simd/Overview.md
+		return "relaxed-simd"
 	}
 	return ""
 }
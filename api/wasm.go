@@ -17,6 +17,8 @@ const (
 	ExternTypeTable  ExternType = 0x01
 	ExternTypeMemory ExternType = 0x02
 	ExternTypeGlobal ExternType = 0x03
+	// ExternTypeTag is a tag defined by CoreFeatureExceptionHandling.
+	ExternTypeTag ExternType = 0x04
 )
 
 // The below are exported to consolidate parsing behavior for external types.
@@ -29,6 +31,8 @@ const (
 	ExternTypeMemoryName = "memory"
 	// ExternTypeGlobalName is the name of the WebAssembly 1.0 (20191205) Text Format field for ExternTypeGlobal.
 	ExternTypeGlobalName = "global"
+	// ExternTypeTagName is the name of the Text Format field for ExternTypeTag.
+	ExternTypeTagName = "tag"
 )
 
 // ExternTypeName returns the name of the WebAssembly 1.0 (20191205) Text Format field of the given type.
@@ -44,6 +48,8 @@ func ExternTypeName(et ExternType) string {
 		return ExternTypeMemoryName
 	case ExternTypeGlobal:
 		return ExternTypeGlobalName
+	case ExternTypeTag:
+		return ExternTypeTagName
 	}
 	return fmt.Sprintf("%#x", et)
 }
@@ -59,6 +65,8 @@ func ExternTypeName(et ExternType) string {
 //   - ValueTypeF64 - EncodeF64 DecodeF64 from float64
 //   - ValueTypeExternref - unintptr(unsafe.Pointer(p)) where p is any pointer
 //     type in Go (e.g. *string)
+//   - ValueTypeAnyref - opaque reference to a GCObject, see Module.GCObject
+//   - ValueTypeFuncref - opaque reference to a function, see Table
 //
 // e.g. Given a Text Format type use (param i64) (result i64), no conversion is
 // necessary.
@@ -105,6 +113,31 @@ const (
 	//
 	// Note: The usage of this type is toggled with api.CoreFeatureBulkMemoryOperations.
 	ValueTypeExternref ValueType = 0x6f
+
+	// ValueTypeAnyref is a reference to a struct or an array allocated by
+	// a Wasm function (CoreFeatureGC), or zero for null.
+	//
+	// The value is an opaque handle, which a host function inspects with
+	// Module.GCObject. Unreachable objects are collected while functions
+	// of the same runtime allocate objects, and the handle of a collected
+	// object is invalid. A handle the host received is only valid while
+	// the host function it was passed to is called or, if it is a result
+	// of an api.Function call, until the next call of the same Function
+	// returns. The GCObject returned by Module.GCObject keeps its object
+	// alive as long as it is held.
+	//
+	// Note: The usage of this type is toggled with api.CoreFeatureGC.
+	ValueTypeAnyref ValueType = 0x6e
+
+	// ValueTypeFuncref is a reference to a function, or zero for null.
+	//
+	// The value is an opaque handle, which is only meaningful to the
+	// wazero.Runtime which created it. For example, a host function can read
+	// one from a Table and set it into another Table of the same runtime.
+	//
+	// Note: The usage of this type is toggled with api.CoreFeatureReferenceTypes,
+	// except as the type of a Table.
+	ValueTypeFuncref ValueType = 0x70
 )
 
 // ValueTypeName returns the type name of the given ValueType as a string.
@@ -123,6 +156,10 @@ func ValueTypeName(t ValueType) string {
 		return "f64"
 	case ValueTypeExternref:
 		return "externref"
+	case ValueTypeAnyref:
+		return "anyref"
+	case ValueTypeFuncref:
+		return "funcref"
 	}
 	return "unknown"
 }
@@ -142,6 +179,9 @@ type Module interface {
 	Name() string
 
 	// Memory returns a memory defined in this module or nil if there are none wasn't.
+	//
+	// Note: When CoreFeatureMultiMemory is enabled, this is the memory of index zero. Use ExportedMemory to access
+	// the others.
 	Memory() Memory
 
 	// ExportedFunction returns a function exported from this module or nil if it wasn't.
@@ -151,7 +191,12 @@ type Module interface {
 	// definitions in this module, keyed on export name.
 	ExportedFunctionDefinitions() map[string]FunctionDefinition
 
-	// TODO: Table
+	// ExportedTable returns a table exported from this module or nil if it wasn't.
+	ExportedTable(name string) Table
+
+	// ExportedTableDefinitions returns all the exported table definitions in
+	// this module, keyed on export name.
+	ExportedTableDefinitions() map[string]TableDefinition
 
 	// ExportedMemory returns a memory exported from this module or nil if it wasn't.
 	//
@@ -165,12 +210,23 @@ type Module interface {
 	// in this module, keyed on export name.
 	//
 	// Note: As of WebAssembly Core Specification 2.0, there can be at most one
-	// memory.
+	// memory unless CoreFeatureMultiMemory is enabled.
 	ExportedMemoryDefinitions() map[string]MemoryDefinition
 
 	// ExportedGlobal a global exported from this module or nil if it wasn't.
 	ExportedGlobal(name string) Global
 
+	// ExportedTag returns a tag exported from this module or nil if it wasn't.
+	//
+	// Note: Tags are only defined when CoreFeatureExceptionHandling is enabled.
+	ExportedTag(name string) Tag
+
+	// GCObject returns the struct or array referenced by the ValueTypeAnyref value ref, or false if ref is null or
+	// no longer valid. The object isn't collected until the result is no longer reachable by Go.
+	//
+	// Note: Objects are only allocated when CoreFeatureGC is enabled.
+	GCObject(ref uint64) (GCObject, bool)
+
 	// CloseWithExitCode releases resources allocated for this Module. Use a non-zero exitCode parameter to indicate a
 	// failure to ExportedFunction callers.
 	//
@@ -238,6 +294,25 @@ type MemoryDefinition interface {
 	Max() (uint32, bool)
 }
 
+// TableDefinition is a WebAssembly table exported in a module
+// (wazero.CompiledModule). Units are in elements.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
+type TableDefinition interface {
+	ExportDefinition
+
+	// Type returns the type of elements, either ValueTypeFuncref or
+	// ValueTypeExternref.
+	Type() ValueType
+
+	// Min returns the possibly zero initial count of elements.
+	Min() uint32
+
+	// Max returns the possibly zero max count of elements, or false if
+	// unbounded.
+	Max() (uint32, bool)
+}
+
 // FunctionDefinition is a WebAssembly function exported in a module
 // (wazero.CompiledModule).
 //
@@ -325,6 +400,29 @@ type Function interface {
 	// WithCloseOnContextDone on wazero.RuntimeConfig for detail. See examples in context_done_example_test.go for
 	// the end-to-end demonstrations of how these terminations can be performed.
 	Call(ctx context.Context, params ...uint64) ([]uint64, error)
+
+	// CallWithStack is an optimized variation of Call that invokes the
+	// function with the given stack, instead of allocating a slice for the
+	// results. This is for those who call small functions in a hot loop.
+	//
+	// The stack includes any parameters encoded according to their ValueType.
+	// Its length must be at least the max of parameter or result length. When
+	// there are results, they are written in order beginning at index zero.
+	//
+	// Here's an example of calling an addition function with the same stack:
+	//
+	//	stack := make([]uint64, 2)
+	//	for i := uint32(0); i < 10; i++ {
+	//		stack[0], stack[1] = api.EncodeU32(i), api.EncodeU32(i)
+	//		if err := add.CallWithStack(ctx, stack); err != nil {
+	//			return err
+	//		}
+	//		sum := api.DecodeU32(stack[0])
+	//	--snip--
+	//
+	// Like Call, this is not goroutine-safe, and may return a sys.ExitError
+	// when the exporting Module was closed during this call.
+	CallWithStack(ctx context.Context, stack []uint64) error
 }
 
 // GoModuleFunction is a Function implemented in Go instead of a wasm binary.
@@ -444,6 +542,124 @@ type MutableGlobal interface {
 	Set(v uint64)
 }
 
+// Tag is a WebAssembly tag exported from an instantiated module, which identifies the exceptions thrown by the
+// instruction `throw` (CoreFeatureExceptionHandling).
+//
+// Two tags are the same only when they are the same instance, e.g. the same tag imported by two modules, regardless of
+// their types.
+//
+// Note: This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+type Tag interface {
+	fmt.Stringer
+
+	// ParamTypes are the possibly empty sequence of value types of the payload of exceptions with this tag.
+	//
+	// See ValueType documentation for encoding rules.
+	ParamTypes() []ValueType
+}
+
+// GCObject is a struct or an array allocated by a Wasm function (CoreFeatureGC), which is referenced by a
+// ValueTypeAnyref value.
+//
+// The fields of a struct and the elements of an array are both accessed by index. Packed `i8` and `i16` values are
+// zero-extended to ValueTypeI32.
+//
+// Note: This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md
+type GCObject interface {
+	fmt.Stringer
+
+	// IsArray returns true if this is an array, or false if this is a struct.
+	IsArray() bool
+
+	// Len returns the number of fields of the struct or elements of the array.
+	Len() uint32
+
+	// Type returns the value type of the i-th field or element, or zero if i is out of range.
+	Type(i uint32) ValueType
+
+	// Get returns the value of the i-th field or element, or false if i is out of range.
+	//
+	// See Type for how to decode this value to a Go type.
+	Get(i uint32) (uint64, bool)
+
+	// Set updates the value of the i-th field or element, or returns false if i is out of range or it is immutable.
+	//
+	// See Type for how to encode this value from a Go type.
+	Set(i uint32, v uint64) bool
+
+	// Ref returns the ValueTypeAnyref value referencing this object, which stays valid while this is reachable.
+	Ref() uint64
+}
+
+// Exception is a WebAssembly exception (CoreFeatureExceptionHandling), which is a Tag and its payload.
+//
+// A function defined by HostModuleBuilder throws an exception to its caller by panicking with it, e.g.
+//
+//	panic(api.NewException(mod.ExportedTag("error"), api.EncodeI32(code)))
+//
+// When api.Function Call finishes with an exception not caught by any `catch` instruction, the error returned is the
+// exception itself. Use errors.As to extract it.
+type Exception struct {
+	tag     Tag
+	payload []uint64
+}
+
+// NewException returns an exception of the tag with the payload encoded according to Tag.ParamTypes.
+func NewException(tag Tag, payload ...uint64) *Exception {
+	return &Exception{tag: tag, payload: payload}
+}
+
+// Tag returns the tag of this exception.
+func (e *Exception) Tag() Tag {
+	return e.tag
+}
+
+// Payload returns the values of this exception, encoded according to Tag.ParamTypes.
+func (e *Exception) Payload() []uint64 {
+	return e.payload
+}
+
+// Error implements error.
+func (e *Exception) Error() string {
+	return fmt.Sprintf("wasm exception: tag %s, payload %v", e.tag, e.payload)
+}
+
+// Table allows access to a table of references exported from an instantiated module, e.g. to add functions to it
+// for dynamic linking.
+//
+// The elements are encoded according to TableDefinition.Type: a ValueTypeExternref is api.EncodeExternref and a
+// ValueTypeFuncref is an opaque reference, which can only be read from a table of the same wazero.Runtime. Zero is
+// null for either type.
+//
+// Note: This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/exec/runtime.html#table-instances
+type Table interface {
+	// Definition is metadata about this table from its defining module.
+	Definition() TableDefinition
+
+	// Size returns the count of elements.
+	Size() uint32
+
+	// Grow appends the delta count of elements initialized to v. The return
+	// val is the previous count of elements, or false if the delta was
+	// ignored as it exceeds TableDefinition.Max.
+	//
+	// Note: This is the same as the "table.grow" instruction defined in the
+	// WebAssembly Core Specification, except returns false instead of -1.
+	Grow(delta uint32, v uint64) (previousSize uint32, ok bool)
+
+	// Get returns the element at the offset or false if out of range.
+	Get(offset uint32) (uint64, bool)
+
+	// Set updates the element at the offset or returns false if out of range.
+	Set(offset uint32, v uint64) bool
+}
+
 // Memory allows restricted access to a module's memory. Notably, this does not allow growing.
 //
 // # Notes
@@ -460,8 +676,15 @@ type Memory interface {
 	// has 1 page: 65536
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#-hrefsyntax-instr-memorymathsfmemorysize%E2%91%A0
+	//
+	// Note: The size of a 64-bit memory can exceed 4GiB, in which case this
+	// is truncated. Use Size64 instead.
 	Size() uint32
 
+	// Size64 is like Size, except it returns the size of a 64-bit memory
+	// (CoreFeatureMemory64) without truncating it.
+	Size64() uint64
+
 	// Grow increases memory by the delta in pages (65536 bytes per page).
 	// The return val is the previous memory size in pages, or false if the
 	// delta was ignored as it exceeds MemoryDefinition.Max.
@@ -532,8 +755,18 @@ type Memory interface {
 	// shared. Those who need a stable view must set Wasm memory min=max, or
 	// use wazero.RuntimeConfig WithMemoryCapacityPages to ensure max is always
 	// allocated.
+	//
+	// When the memory is reserved with wazero.RuntimeConfig
+	// WithMemoryReservation, the returned slice stays shared when the memory
+	// grows, even after the module defining the memory is closed. However,
+	// it doesn't keep the memory alive: it is only valid while the Memory
+	// is reachable, so copy what's needed beforehand otherwise.
 	Read(offset, byteCount uint32) ([]byte, bool)
 
+	// Read64 is like Read, except it allows reading a 64-bit memory
+	// (CoreFeatureMemory64) beyond offsets of 4GiB.
+	Read64(offset, byteCount uint64) ([]byte, bool)
+
 	// WriteByte writes a single byte to the underlying buffer at the offset in or returns false if out of range.
 	WriteByte(offset uint32, v byte) bool
 
@@ -564,6 +797,10 @@ type Memory interface {
 	// Write writes the slice to the underlying buffer at the offset or returns false if out of range.
 	Write(offset uint32, v []byte) bool
 
+	// Write64 is like Write, except it allows writing a 64-bit memory
+	// (CoreFeatureMemory64) beyond offsets of 4GiB.
+	Write64(offset uint64, v []byte) bool
+
 	// WriteString writes the string to the underlying buffer at the offset or returns false if out of range.
 	WriteString(offset uint32, v string) bool
 }
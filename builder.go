@@ -2,6 +2,7 @@ package wazero
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -72,6 +73,18 @@ type HostFunctionBuilder interface {
 	// See WithGoFunction if you don't need access to the calling module.
 	WithGoModuleFunction(fn api.GoModuleFunction, params, results []api.ValueType) HostFunctionBuilder
 
+	// WithTypedFunction defines a function whose signature is derived from
+	// Go type parameters, without the reflection used by WithFunc.
+	//
+	// Here's an example of an addition function:
+	//
+	//	builder.WithTypedFunction(wazero.HostFunc2(func(ctx context.Context, mod api.Module, x, y uint32) uint32 {
+	//		return x + y
+	//	}))
+	//
+	// See HostFunc0 and HostVoidFunc0 for the functions to create one.
+	WithTypedFunction(fn TypedHostFunction) HostFunctionBuilder
+
 	// WithFunc uses reflect.Value to map a go `func` to a WebAssembly
 	// compatible Signature. An input that isn't a `func` will fail to
 	// instantiate.
@@ -178,6 +191,40 @@ type HostModuleBuilder interface {
 	// NewFunctionBuilder begins the definition of a host function.
 	NewFunctionBuilder() HostFunctionBuilder
 
+	// ExportMemory exports a memory of minPages count of 64KB pages as the
+	// given name, e.g. "memory". A WebAssembly module importing it shares it
+	// with the host, which can access it via api.Module ExportedMemory.
+	//
+	// The maximum is the limit of RuntimeConfig.WithMemoryLimitPages. Use
+	// ExportMemoryWithMax to lower it.
+	ExportMemory(name string, minPages uint32) HostModuleBuilder
+
+	// ExportMemoryWithMax is like ExportMemory, except it defines a maximum
+	// count of pages the memory can grow to.
+	ExportMemoryWithMax(name string, minPages, maxPages uint32) HostModuleBuilder
+
+	// ExportGlobal exports an immutable global of the given value as the given
+	// name, e.g. "__heap_base". The value must be one of int32, uint32,
+	// int64, uint64, float32 or float64, which defines the global's type,
+	// e.g. an int32 is api.ValueTypeI32.
+	ExportGlobal(name string, v interface{}) HostModuleBuilder
+
+	// ExportMutableGlobal is like ExportGlobal, except the global is mutable.
+	// Each instantiation has its own value, which can be read and updated via
+	// api.Module ExportedGlobal.
+	ExportMutableGlobal(name string, v interface{}) HostModuleBuilder
+
+	// ExportTable exports a table of min count of null elements as the given
+	// name, e.g. "__indirect_function_table". The elemType is either
+	// api.ValueTypeFuncref or api.ValueTypeExternref.
+	//
+	// The host can access the table via api.Module ExportedTable.
+	ExportTable(name string, elemType api.ValueType, min uint32) HostModuleBuilder
+
+	// ExportTableWithMax is like ExportTable, except it defines a maximum
+	// count of elements the table can grow to.
+	ExportTableWithMax(name string, elemType api.ValueType, min, max uint32) HostModuleBuilder
+
 	// Compile returns a CompiledModule that can be instantiated by Runtime.
 	Compile(context.Context) (CompiledModule, error)
 
@@ -211,6 +258,21 @@ type hostModuleBuilder struct {
 	moduleName   string
 	nameToGoFunc map[string]interface{}
 	funcToNames  map[string]*wasm.HostFuncNames
+	nameToMemory map[string]*hostMemory
+	nameToGlobal map[string]*hostGlobal
+	nameToTable  map[string]*wasm.Table
+}
+
+// hostMemory is a memory defined by HostModuleBuilder, which is sized on Compile according to the RuntimeConfig.
+type hostMemory struct {
+	minPages uint32
+	maxPages *uint32
+}
+
+// hostGlobal is a global defined by HostModuleBuilder, whose value is validated on Compile.
+type hostGlobal struct {
+	v       interface{}
+	mutable bool
 }
 
 // NewHostModuleBuilder implements Runtime.NewHostModuleBuilder
@@ -220,6 +282,9 @@ func (r *runtime) NewHostModuleBuilder(moduleName string) HostModuleBuilder {
 		moduleName:   moduleName,
 		nameToGoFunc: map[string]interface{}{},
 		funcToNames:  map[string]*wasm.HostFuncNames{},
+		nameToMemory: map[string]*hostMemory{},
+		nameToGlobal: map[string]*hostGlobal{},
+		nameToTable:  map[string]*wasm.Table{},
 	}
 }
 
@@ -252,6 +317,11 @@ func (h *hostFunctionBuilder) WithGoModuleFunction(fn api.GoModuleFunction, para
 	return h
 }
 
+// WithTypedFunction implements HostFunctionBuilder.WithTypedFunction
+func (h *hostFunctionBuilder) WithTypedFunction(fn TypedHostFunction) HostFunctionBuilder {
+	return h.WithGoModuleFunction(fn.fn, fn.params, fn.results)
+}
+
 // WithFunc implements HostFunctionBuilder.WithFunc
 func (h *hostFunctionBuilder) WithFunc(fn interface{}) HostFunctionBuilder {
 	h.fn = fn
@@ -309,12 +379,58 @@ func (b *hostModuleBuilder) NewFunctionBuilder() HostFunctionBuilder {
 	return &hostFunctionBuilder{b: b}
 }
 
+// ExportMemory implements HostModuleBuilder.ExportMemory
+func (b *hostModuleBuilder) ExportMemory(name string, minPages uint32) HostModuleBuilder {
+	b.nameToMemory[name] = &hostMemory{minPages: minPages}
+	return b
+}
+
+// ExportMemoryWithMax implements HostModuleBuilder.ExportMemoryWithMax
+func (b *hostModuleBuilder) ExportMemoryWithMax(name string, minPages, maxPages uint32) HostModuleBuilder {
+	b.nameToMemory[name] = &hostMemory{minPages: minPages, maxPages: &maxPages}
+	return b
+}
+
+// ExportGlobal implements HostModuleBuilder.ExportGlobal
+func (b *hostModuleBuilder) ExportGlobal(name string, v interface{}) HostModuleBuilder {
+	b.nameToGlobal[name] = &hostGlobal{v: v}
+	return b
+}
+
+// ExportMutableGlobal implements HostModuleBuilder.ExportMutableGlobal
+func (b *hostModuleBuilder) ExportMutableGlobal(name string, v interface{}) HostModuleBuilder {
+	b.nameToGlobal[name] = &hostGlobal{v: v, mutable: true}
+	return b
+}
+
+// ExportTable implements HostModuleBuilder.ExportTable
+func (b *hostModuleBuilder) ExportTable(name string, elemType api.ValueType, min uint32) HostModuleBuilder {
+	b.nameToTable[name] = &wasm.Table{Min: min, Type: elemType}
+	return b
+}
+
+// ExportTableWithMax implements HostModuleBuilder.ExportTableWithMax
+func (b *hostModuleBuilder) ExportTableWithMax(name string, elemType api.ValueType, min, max uint32) HostModuleBuilder {
+	b.nameToTable[name] = &wasm.Table{Min: min, Max: &max, Type: elemType}
+	return b
+}
+
 // Compile implements HostModuleBuilder.Compile
 func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error) {
 	module, err := wasm.NewHostModule(b.moduleName, b.nameToGoFunc, b.funcToNames, b.r.enabledFeatures)
 	if err != nil {
 		return nil, err
-	} else if err = module.Validate(b.r.enabledFeatures); err != nil {
+	}
+
+	if len(b.nameToMemory) > 0 || len(b.nameToGlobal) > 0 || len(b.nameToTable) > 0 {
+		memories, globals, tables, err := b.hostExports()
+		if err != nil {
+			return nil, err
+		}
+		module.AddHostExports(memories, globals, tables)
+	}
+
+	if err = module.Validate(b.r.enabledFeatures); err != nil {
 		return nil, err
 	}
 
@@ -324,13 +440,51 @@ func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error)
 		return nil, err
 	}
 
-	if err = b.r.store.Engine.CompileModule(ctx, module, listeners, false); err != nil {
+	if err = b.r.store.Engine.CompileModule(ctx, module, listeners, false, false, false); err != nil {
 		return nil, err
 	}
 
 	return c, nil
 }
 
+// hostExports returns the memories, globals and tables defined by this builder, or an error if any is invalid.
+func (b *hostModuleBuilder) hostExports() (map[string]*wasm.Memory, map[string]*wasm.Global, map[string]*wasm.Table, error) {
+	memories := make(map[string]*wasm.Memory, len(b.nameToMemory))
+	for name, m := range b.nameToMemory {
+		// Size the memory the same way as one defined in a WebAssembly binary.
+		capacity, max := m.minPages, b.r.memoryLimitPages
+		if m.maxPages != nil {
+			max = *m.maxPages
+			if b.r.memoryCapacityFromMax {
+				capacity = max
+			}
+		}
+		mem := &wasm.Memory{Min: m.minPages, Cap: capacity, Max: max, IsMaxEncoded: m.maxPages != nil}
+		if err := mem.Validate(b.r.memoryLimitPages); err != nil {
+			return nil, nil, nil, fmt.Errorf("memory[%s] %v", name, err)
+		}
+		memories[name] = mem
+	}
+
+	globals := make(map[string]*wasm.Global, len(b.nameToGlobal))
+	for name, g := range b.nameToGlobal {
+		global, err := wasm.NewHostGlobal(g.v, g.mutable)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("global[%s] %v", name, err)
+		}
+		globals[name] = global
+	}
+
+	for name, t := range b.nameToTable {
+		if t.Type != api.ValueTypeFuncref && t.Type != api.ValueTypeExternref {
+			return nil, nil, nil, fmt.Errorf("table[%s] has unsupported element type %s", name, api.ValueTypeName(t.Type))
+		} else if t.Max != nil && t.Min > *t.Max {
+			return nil, nil, nil, fmt.Errorf("table[%s] min %d > max %d", name, t.Min, *t.Max)
+		}
+	}
+	return memories, globals, b.nameToTable, nil
+}
+
 // Instantiate implements HostModuleBuilder.Instantiate
 func (b *hostModuleBuilder) Instantiate(ctx context.Context) (api.Module, error) {
 	if compiled, err := b.Compile(ctx); err != nil {
@@ -264,6 +264,37 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ExportMemory ExportGlobal ExportTable",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("env").
+					ExportMutableGlobal("b", int64(-1)).
+					ExportMemoryWithMax("memory", 1, 2).
+					ExportGlobal("a", uint32(1)).
+					ExportTable("table", api.ValueTypeFuncref, 3)
+			},
+			expected: &wasm.Module{
+				TableSection:  []*wasm.Table{{Min: 3, Type: api.ValueTypeFuncref}},
+				MemorySection: []*wasm.Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}},
+				GlobalSection: []*wasm.Global{
+					{
+						Type: &wasm.GlobalType{ValType: i32},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+					},
+					{
+						Type: &wasm.GlobalType{ValType: i64, Mutable: true},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0x7f}},
+					},
+				},
+				ExportSection: []*wasm.Export{
+					{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+					{Name: "a", Type: wasm.ExternTypeGlobal, Index: 0},
+					{Name: "b", Type: wasm.ExternTypeGlobal, Index: 1},
+					{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "env"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -312,6 +343,48 @@ func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
 	have ()
 	want (i32)`,
 		},
+		{
+			name: "memory over limit",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportMemory("memory", 65537)
+			},
+			expectedErr: "memory[memory] min 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)",
+		},
+		{
+			name: "memory min > max",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportMemoryWithMax("memory", 2, 1)
+			},
+			expectedErr: "memory[memory] min 2 pages (128 Ki) > max 1 pages (64 Ki)",
+		},
+		{
+			name: "unsupported global type",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportGlobal("g", "1")
+			},
+			expectedErr: "global[g] unsupported global type string",
+		},
+		{
+			name: "unsupported table type",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportTable("t", api.ValueTypeI32, 1)
+			},
+			expectedErr: "table[t] has unsupported element type i32",
+		},
+		{
+			name: "table min > max",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportTableWithMax("t", api.ValueTypeExternref, 2, 1)
+			},
+			expectedErr: "table[t] min 2 > max 1",
+		},
+		{
+			name: "multiple memories",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("").ExportMemory("a", 1).ExportMemory("b", 1)
+			},
+			expectedErr: "multiple memories are invalid as feature \"multi-memory\" is disabled",
+		},
 	}
 
 	for _, tt := range tests {
@@ -338,6 +411,49 @@ func TestNewHostModuleBuilder_Instantiate(t *testing.T) {
 	require.Zero(t, r.(*runtime).store.Engine.CompiledModuleCount())
 }
 
+// TestNewHostModuleBuilder_Instantiate_Exports ensures a guest can import what the host exports, and both see the
+// same state.
+func TestNewHostModuleBuilder_Instantiate_Exports(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	env, err := r.NewHostModuleBuilder("env").
+		ExportMemory("memory", 1).
+		ExportGlobal("base", int32(16)).
+		ExportMutableGlobal("counter", int32(0)).
+		ExportTable("table", api.ValueTypeFuncref, 1).
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	guest, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+  (import "env" "memory" (memory 1))
+  (import "env" "base" (global $base i32))
+  (import "env" "counter" (global $counter (mut i32)))
+  (import "env" "table" (table 1 funcref))
+  (type $i32 (func (result i32)))
+  (func $seven (result i32) i32.const 7)
+  (elem (i32.const 0) $seven)
+  (func (export "run") (result i32)
+    (i32.store (global.get $base) (i32.const 42))
+    (global.set $counter (i32.add (global.get $counter) (i32.const 1)))
+    (call_indirect (type $i32) (i32.const 0))))`))
+	require.NoError(t, err)
+
+	results, err := guest.ExportedFunction("run").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{7}, results)
+
+	v, ok := env.ExportedMemory("memory").ReadUint32Le(16)
+	require.True(t, ok)
+	require.Equal(t, uint32(42), v)
+	require.Equal(t, uint64(16), env.ExportedGlobal("base").Get())
+	counter := env.ExportedGlobal("counter").(api.MutableGlobal)
+	require.Equal(t, uint64(1), counter.Get())
+	ref, ok := env.ExportedTable("table").Get(0)
+	require.True(t, ok)
+	require.NotEqual(t, uint64(0), ref)
+}
+
 // TestNewHostModuleBuilder_Instantiate_Errors ensures errors propagate from Runtime.InstantiateModule
 func TestNewHostModuleBuilder_Instantiate_Errors(t *testing.T) {
 	r := NewRuntime(testCtx)
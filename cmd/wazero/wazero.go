@@ -15,6 +15,8 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/experimental/profiling"
+	"github.com/tetratelabs/wazero/experimental/tracing"
 	gojs "github.com/tetratelabs/wazero/imports/go"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"github.com/tetratelabs/wazero/internal/version"
@@ -49,6 +51,8 @@ func doMain(stdOut io.Writer, stdErr logging.Writer, exit func(code int)) {
 	switch subCmd {
 	case "compile":
 		doCompile(flag.Args()[1:], stdErr, exit)
+	case "disassemble":
+		doDisassemble(flag.Args()[1:], stdOut, stdErr, exit)
 	case "run":
 		doRun(flag.Args()[1:], stdOut, stdErr, exit)
 	case "version":
@@ -107,6 +111,60 @@ func doCompile(args []string, stdErr io.Writer, exit func(code int)) {
 	}
 }
 
+func doDisassemble(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("disassemble", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var function string
+	flags.StringVar(&function, "func", "",
+		"The function to print with the offset of each instruction, either its index or debug name, e.g. 12 or "+
+			"\"main.add\". Defaults to the whole module.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printDisassembleUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printDisassembleUsage(stdErr, flags)
+		exit(1)
+	}
+	wasmPath := flags.Arg(0)
+
+	wasm, err := os.ReadFile(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	// The interpreter avoids compiling machine code only to print the module.
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer rt.Close(ctx)
+
+	code, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	if function == "" {
+		fmt.Fprint(stdOut, code.Disassemble())
+	} else if wat, err := code.DisassembleFunction(function); err != nil {
+		fmt.Fprintf(stdErr, "error disassembling function: %v\n", err)
+		exit(1)
+	} else {
+		fmt.Fprint(stdOut, wat)
+	}
+	exit(0)
+}
+
 func doRun(args []string, stdOut io.Writer, stdErr logging.Writer, exit func(code int)) {
 	flags := flag.NewFlagSet("run", flag.ExitOnError)
 	flags.SetOutput(stdErr)
@@ -138,6 +196,16 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer, exit func(cod
 		"a comma-separated list of host function scopes to log to stderr. "+
 			"This may be specified multiple times. Supported values: clock,exit,filesystem,memory,poll,random")
 
+	var wallProfile string
+	flags.StringVar(&wallProfile, "cpuprofile", "",
+		"writes a pprof wall-time profile of the sampled calls to the functions of the binary to the given file. "+
+			"Unlike a CPU profile, it includes the time blocked in host functions. This can be viewed with `go tool pprof`.")
+
+	var trace string
+	flags.StringVar(&trace, "trace", "",
+		"writes the calls of the functions of the binary to the given file, as Chrome trace events in JSON. "+
+			"This can be viewed with https://ui.perfetto.dev or chrome://tracing.")
+
 	cacheDir := cacheDirFlag(flags)
 
 	_ = flags.Parse(args)
@@ -186,7 +254,36 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer, exit func(cod
 
 	wasmExe := filepath.Base(wasmPath)
 
-	ctx := maybeHostLogging(context.Background(), logging.LogScopes(hostlogging), stdErr)
+	var listenerFactories []experimental.FunctionListenerFactory
+	if hostlogging != 0 {
+		listenerFactories = append(listenerFactories, logging.NewHostLoggingListenerFactory(stdErr, logging.LogScopes(hostlogging)))
+	}
+	var wallProfiler *profiling.WallProfiler
+	var wallProfileFile *os.File
+	if wallProfile != "" {
+		if wallProfileFile, err = os.Create(wallProfile); err != nil {
+			fmt.Fprintf(stdErr, "error creating wall profile: %v\n", err)
+			exit(1)
+		}
+		wallProfiler = profiling.NewWallProfiler(1)
+		listenerFactories = append(listenerFactories, wallProfiler)
+	}
+	var tracer *tracing.Tracer
+	var traceFile *os.File
+	if trace != "" {
+		if traceFile, err = os.Create(trace); err != nil {
+			fmt.Fprintf(stdErr, "error creating trace: %v\n", err)
+			exit(1)
+		}
+		tracer = tracing.NewTracer(traceFile)
+		listenerFactories = append(listenerFactories, tracer)
+	}
+
+	ctx := context.Background()
+	if len(listenerFactories) > 0 {
+		ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{},
+			experimental.MultiFunctionListenerFactory(listenerFactories...))
+	}
 
 	var rtc wazero.RuntimeConfig
 	if useInterpreter {
@@ -225,6 +322,9 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer, exit func(cod
 
 	needsWASI, needsGo := detectImports(code.ImportedFunctions())
 
+	if wallProfiler != nil {
+		wallProfiler.StartProfile()
+	}
 	if needsWASI {
 		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
 		_, err = rt.InstantiateModule(ctx, code, conf)
@@ -232,6 +332,12 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer, exit func(cod
 		gojs.MustInstantiate(ctx, rt)
 		err = gojs.Run(ctx, rt, code, conf)
 	}
+	if wallProfiler != nil {
+		writeWallProfile(wallProfiler, wallProfileFile, stdErr, exit)
+	}
+	if tracer != nil {
+		writeTrace(tracer, traceFile, stdErr, exit)
+	}
 
 	if err != nil {
 		if exitErr, ok := err.(*sys.ExitError); ok {
@@ -312,11 +418,26 @@ func detectImports(imports []api.FunctionDefinition) (needsWASI, needsGo bool) {
 	return
 }
 
-func maybeHostLogging(ctx context.Context, scopes logging.LogScopes, stdErr logging.Writer) context.Context {
-	if scopes != 0 {
-		return context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, logging.NewHostLoggingListenerFactory(stdErr, scopes))
+func writeWallProfile(p *profiling.WallProfiler, f *os.File, stdErr logging.Writer, exit func(code int)) {
+	err := p.StopProfile(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stdErr, "error writing wall profile: %v\n", err)
+		exit(1)
+	}
+}
+
+func writeTrace(t *tracing.Tracer, f *os.File, stdErr logging.Writer, exit func(code int)) {
+	err := t.Close()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stdErr, "error writing trace: %v\n", err)
+		exit(1)
 	}
-	return ctx
 }
 
 func cacheDirFlag(flags *flag.FlagSet) *string {
@@ -345,6 +466,7 @@ func printUsage(stdErr io.Writer) {
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Commands:")
 	fmt.Fprintln(stdErr, "  compile\tPre-compiles a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  disassemble\tPrints a WebAssembly binary in the text format")
 	fmt.Fprintln(stdErr, "  run\t\tRuns a WebAssembly binary")
 	fmt.Fprintln(stdErr, "  version\tDisplays the version of wazero CLI")
 }
@@ -358,6 +480,15 @@ func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	flags.PrintDefaults()
 }
 
+func printDisassembleUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero disassemble <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
+
 func printRunUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -179,6 +180,124 @@ func TestCompile_Errors(t *testing.T) {
 	}
 }
 
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedStdout string
+	}{
+		{
+			name: "module",
+			args: []string{"testdata/wasi_arg.wasm"},
+			expectedStdout: `(module
+  (type (;0;) (func (param i32) (param i32) (result i32)))
+  (type (;1;) (func (param i32) (param i32) (param i32) (param i32) (result i32)))
+  (type (;2;) (func))
+  (import "wasi_snapshot_preview1" "args_get" (func (;0;) (type 0) (param i32) (param i32) (result i32)))
+  (import "wasi_snapshot_preview1" "args_sizes_get" (func (;1;) (type 0) (param i32) (param i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func (;2;) (type 1) (param i32) (param i32) (param i32) (param i32) (result i32)))
+  (func (;3;) (type 2)
+    global.get 1
+    i32.const 0
+    call 0
+    drop
+    global.get 1
+    global.get 0
+    i32.const 4
+    i32.add
+    call 1
+    drop
+    i32.const 1
+    global.get 0
+    i32.const 1
+    global.get 1
+    call 2
+    drop)
+  (memory (;0;) 1)
+  (global (;0;) i32 (i32.const 1024))
+  (global (;1;) i32 (i32.const 32768))
+  (export "memory" (memory 0))
+  (export "_start" (func 3)))
+`,
+		},
+		{
+			name: "function index",
+			args: []string{"-func", "3", "testdata/wasi_arg.wasm"},
+			expectedStdout: `(func (;3;) (type 2)
+(;@3   ;)   global.get 1
+(;@5   ;)   i32.const 0
+(;@7   ;)   call 0
+(;@9   ;)   drop
+(;@a   ;)   global.get 1
+(;@c   ;)   global.get 0
+(;@e   ;)   i32.const 4
+(;@10  ;)   i32.add
+(;@11  ;)   call 1
+(;@13  ;)   drop
+(;@14  ;)   i32.const 1
+(;@16  ;)   global.get 0
+(;@18  ;)   i32.const 1
+(;@1a  ;)   global.get 1
+(;@1c  ;)   call 2
+(;@1e  ;)   drop)
+`,
+		},
+		{
+			name: "function debug name",
+			args: []string{"-func", "wasi_arg.wasi.fd_write", "testdata/wasi_arg.wat"},
+			expectedStdout: `(import "wasi_snapshot_preview1" "fd_write" (func $wasi.fd_write (type 1) (param $fd i32) (param $iovs i32) (param $iovs_len i32) (param $result.size i32) (result i32)))
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			exitCode, stdout, stderr := runMain(t, append([]string{"disassemble"}, tc.args...))
+			require.Equal(t, 0, exitCode, stderr)
+			require.Equal(t, "", stderr)
+			require.Equal(t, tc.expectedStdout, stdout)
+		})
+	}
+}
+
+func TestDisassemble_Errors(t *testing.T) {
+	notWasmPath := filepath.Join(t.TempDir(), "bears.wasm")
+	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error compiling wasm binary",
+			args:    []string{notWasmPath},
+		},
+		{
+			message: `error disassembling function: function "wasi_arg.nope" not found`,
+			args:    []string{"-func", "wasi_arg.nope", "testdata/wasi_arg.wat"},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stderr := runMain(t, append([]string{"disassemble"}, tt.args...))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stderr, tt.message)
+		})
+	}
+}
+
 func TestRun(t *testing.T) {
 	// Restore env logic borrowed from TestClearenv
 	defer func(origEnv []string) {
@@ -477,6 +596,51 @@ func TestVersion(t *testing.T) {
 	require.Equal(t, "", stderr)
 }
 
+func TestRun_cpuprofile(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "cat.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmCatTinygo, 0o700))
+	profilePath := filepath.Join(t.TempDir(), "wall.pprof")
+
+	exitCode, stdout, stderr := runMain(t, []string{"run", "--cpuprofile", profilePath, "--hostlogging=exit",
+		"--mount=testdata/fs:/animals:ro", wasmPath, "/animals/not-bear.txt"})
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, "", stdout)
+	require.Contains(t, stderr, "proc_exit") // the listeners of the profiler and logging are combined.
+
+	// The profile is written even when the binary exits with an error, and is gzip compressed.
+	b, err := os.ReadFile(profilePath)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(b, []byte{0x1f, 0x8b}))
+}
+
+func TestRun_trace(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "cat.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmCatTinygo, 0o700))
+	tracePath := filepath.Join(t.TempDir(), "trace.json")
+
+	exitCode, stdout, stderr := runMain(t, []string{"run", "--trace", tracePath, "--mount=testdata/fs:/animals:ro", wasmPath, "/animals/not-bear.txt"})
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, "", stdout)
+	require.Equal(t, "", stderr)
+
+	// The trace is written even when the binary exits with an error, and the calls unwound by proc_exit are ended.
+	b, err := os.ReadFile(tracePath)
+	require.NoError(t, err)
+	var events []struct{ Name, Ph string }
+	require.NoError(t, json.Unmarshal(b, &events))
+	require.True(t, strings.HasSuffix(events[0].Name, "._start"))
+	require.Equal(t, "B", events[0].Ph)
+	var depth int
+	for _, e := range events {
+		if e.Ph == "B" {
+			depth++
+		} else {
+			depth--
+		}
+	}
+	require.Equal(t, 0, depth)
+}
+
 func TestRun_Errors(t *testing.T) {
 	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
 	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o700))
@@ -512,6 +676,14 @@ func TestRun_Errors(t *testing.T) {
 			message: "invalid cachedir",
 			args:    []string{"--cachedir", notWasmPath, wasmPath},
 		},
+		{
+			message: "error creating wall profile",
+			args:    []string{"--cpuprofile", t.TempDir(), wasmPath},
+		},
+		{
+			message: "error creating trace",
+			args:    []string{"--trace", t.TempDir(), wasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -661,6 +833,7 @@ Usage:
 
 Commands:
   compile	Pre-compiles a WebAssembly binary
+  disassemble	Prints a WebAssembly binary in the text format
   run		Runs a WebAssembly binary
   version	Displays the version of wazero CLI
 `, stderr)
@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tetratelabs/wazero/api"
@@ -17,6 +19,8 @@ import (
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/internal/sysfs"
 	"github.com/tetratelabs/wazero/internal/wasm"
+	textformat "github.com/tetratelabs/wazero/internal/wasm/text"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -48,8 +52,7 @@ type RuntimeConfig interface {
 	WithCoreFeatures(api.CoreFeatures) RuntimeConfig
 
 	// WithMemoryLimitPages overrides the maximum pages allowed per memory. The
-	// default is 65536, allowing 4GB total memory per instance. Setting a
-	// value larger than default will panic.
+	// default is 65536, allowing 4GB total memory per instance.
 	//
 	// This example reduces the largest possible memory size from 4GB to 128KB:
 	//	rConfig = wazero.NewRuntimeConfig().WithMemoryLimitPages(2)
@@ -57,6 +60,14 @@ type RuntimeConfig interface {
 	// Note: Wasm has 32-bit memory and each page is 65536 (2^16) bytes. This
 	// implies a max of 65536 (2^16) addressable pages.
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
+	//
+	// # 64-bit memories
+	//
+	// A value larger than 65536 only raises the limit of 64-bit memories,
+	// which require api.CoreFeatureMemory64. 32-bit memories remain limited
+	// to 65536 pages. Setting a value larger than 2147483648 (2^31), which
+	// allows 128TB per 64-bit memory, will panic.
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
 	WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig
 
 	// WithMemoryCapacityFromMax eagerly allocates max memory, unless max is
@@ -69,6 +80,35 @@ type RuntimeConfig interface {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
 	WithMemoryCapacityFromMax(memoryCapacityFromMax bool) RuntimeConfig
 
+	// WithMemoryReservation reserves 8GiB of virtual memory for each memory
+	// defined by a module, and only commits the pages in use. The default is
+	// false, which means memory is allocated by Go.
+	//
+	// This ensures memory never re-allocates or copies on growth, so slices
+	// returned by api.Memory Read stay valid when the memory grows:
+	//	rConfig = wazero.NewRuntimeConfig().WithMemoryReservation(true)
+	//
+	// The pages after the ones in use are guard pages, so the compiler
+	// doesn't emit bounds checks for the loads and stores of a memory defined
+	// by the module: their faults trap as out of bounds memory accesses.
+	//
+	// # Notes
+	//
+	//   - This only has an effect on Linux. Bounds checks are only elided on
+	//     amd64, and not for imported memories, which might not be reserved.
+	//   - 64-bit memories aren't reserved, as they can exceed 8GiB: they are
+	//     allocated by Go as if this were false.
+	//   - Shared memories of the threads proposal are always reserved on
+	//     Linux, regardless of this, as other agents access them while they
+	//     grow. Elsewhere, or when 64-bit, instantiating them fails.
+	//   - Only the committed pages use physical memory, but each memory uses
+	//     8GiB of address space, regardless of its max.
+	//   - The memory is empty after the module defining it is closed, but
+	//     its reservation is only released once the api.Memory is no longer
+	//     reachable, as calls still running or slices returned by
+	//     api.Memory Read might use it.
+	WithMemoryReservation(memoryReservation bool) RuntimeConfig
+
 	// WithDebugInfoEnabled toggles DWARF based stack traces in the face of
 	// runtime errors. Defaults to true.
 	//
@@ -148,7 +188,82 @@ type RuntimeConfig interface {
 	//
 	// When the invocations of api.Function are closed due to this, sys.ExitError is raised to the callers and
 	// the api.Module from which the functions are derived is made closed.
+	//
+	// See WithInterruptOnContextDone to only fail the invocation instead.
 	WithCloseOnContextDone(bool) RuntimeConfig
+
+	// WithInterruptOnContextDone is like WithCloseOnContextDone, except that when the context.Context passed to
+	// the Call method of api.Function is canceled or reaches its deadline, only that invocation fails with an error
+	// matching ErrInterrupted. The api.Module is left open with its memory and globals intact, so that it can serve
+	// the next invocation:
+	//
+	//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithInterruptOnContextDone(true))
+	//	// --snip--
+	//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	//	defer cancel()
+	//	if _, err := fn.Call(ctx); errors.Is(err, wazero.ErrInterrupted) {
+	//		// --snip--
+	//	}
+	//
+	// This comes with the same extra cost as WithCloseOnContextDone, and takes precedence over it when both are
+	// enabled. Explicit calls of Close or CloseWithExitCode on api.Module still close the module during execution.
+	//
+	// Note: The interrupted invocation stops between instructions, so the memory and globals reflect what it did so
+	// far, e.g. a partially updated data structure. Guests relying on the consistency of their state across
+	// invocations should not be interrupted in the middle of updating it.
+	WithInterruptOnContextDone(bool) RuntimeConfig
+
+	// WithFuelMetering enables deterministic execution budgets for untrusted
+	// Wasm binaries. Defaults to false.
+	//
+	// When enabled, functions are instrumented at compilation to consume one
+	// unit of fuel per WebAssembly instruction. The fuel of a basic block is
+	// consumed on entering it, so a call fails before executing a block it
+	// cannot afford, with an error matching ErrFuelExhausted. Unlike
+	// WithCloseOnContextDone, the same call with the same budget always
+	// stops at the same point, regardless of the engine or the load on the
+	// host, and the api.Module is left open.
+	//
+	// The budget is given per call by WithFuel, which also reports the fuel
+	// remaining and consumed after the call:
+	//
+	//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithFuelMetering(true))
+	//	// --snip--
+	//	fuelCtx, fuel := wazero.WithFuel(ctx, 1_000_000)
+	//	_, err := fn.Call(fuelCtx)
+	//	if errors.Is(err, wazero.ErrFuelExhausted) {
+	//		// --snip--
+	//	}
+	//	consumed := fuel.Consumed()
+	//
+	// Calls without fuel in their context.Context are not limited. Reusing
+	// the context for multiple calls gives them a shared budget, e.g. per
+	// module instead of per call.
+	//
+	// Note: Host functions don't consume fuel, and this comes with extra cost
+	// of the instrumentation for every basic block, similar to
+	// WithCloseOnContextDone.
+	WithFuelMetering(bool) RuntimeConfig
+
+	// WithCoverage enables counting the times each basic block of the
+	// functions is executed, e.g. to measure the line coverage of the tests
+	// of a guest. Defaults to false.
+	//
+	// The counters are retrieved with CompiledModuleCoverage or
+	// ModuleCoverage, and written as a coverage report of the source files by
+	// Coverage.WriteLCOV:
+	//
+	//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCoverage(true))
+	//	// --snip--
+	//	_, err = mod.ExportedFunction("run_tests").Call(ctx)
+	//	// --snip--
+	//	err = wazero.ModuleCoverage(mod).WriteLCOV(w)
+	//
+	// Note: This comes with extra cost of the instrumentation for every basic
+	// block, similar to WithFuelMetering. The modules compiled with coverage
+	// are not added to the CompilationCache directory, as their counters
+	// can't be restored from it.
+	WithCoverage(bool) RuntimeConfig
 }
 
 // NewRuntimeConfig returns a RuntimeConfig using the compiler if it is supported in this environment,
@@ -163,12 +278,16 @@ type runtimeConfig struct {
 	enabledFeatures       api.CoreFeatures
 	memoryLimitPages      uint32
 	memoryCapacityFromMax bool
+	memoryReservation     bool
 	engineKind            engineKind
 	dwarfDisabled         bool // negative as defaults to enabled
 	newEngine             newEngine
 	cache                 CompilationCache
 	storeCustomSections   bool
 	ensureTermination     bool
+	interruptOnDone       bool
+	fuelMetering          bool
+	coverage              bool
 }
 
 // engineLessConfig helps avoid copy/pasting the wrong defaults.
@@ -236,12 +355,33 @@ func (c *runtimeConfig) WithCloseOnContextDone(ensure bool) RuntimeConfig {
 	return ret
 }
 
+// WithInterruptOnContextDone implements RuntimeConfig.WithInterruptOnContextDone
+func (c *runtimeConfig) WithInterruptOnContextDone(interrupt bool) RuntimeConfig {
+	ret := c.clone()
+	ret.interruptOnDone = interrupt
+	return ret
+}
+
+// WithFuelMetering implements RuntimeConfig.WithFuelMetering
+func (c *runtimeConfig) WithFuelMetering(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.fuelMetering = enabled
+	return ret
+}
+
+// WithCoverage implements RuntimeConfig.WithCoverage
+func (c *runtimeConfig) WithCoverage(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.coverage = enabled
+	return ret
+}
+
 // WithMemoryLimitPages implements RuntimeConfig.WithMemoryLimitPages
 func (c *runtimeConfig) WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig {
 	ret := c.clone()
 	// This panics instead of returning an error as it is unlikely.
-	if memoryLimitPages > wasm.MemoryLimitPages {
-		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages))
+	if memoryLimitPages > wasm.MemoryLimitPages64 {
+		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages64))
 	}
 	ret.memoryLimitPages = memoryLimitPages
 	return ret
@@ -261,6 +401,13 @@ func (c *runtimeConfig) WithMemoryCapacityFromMax(memoryCapacityFromMax bool) Ru
 	return ret
 }
 
+// WithMemoryReservation implements RuntimeConfig.WithMemoryReservation
+func (c *runtimeConfig) WithMemoryReservation(memoryReservation bool) RuntimeConfig {
+	ret := c.clone()
+	ret.memoryReservation = memoryReservation
+	return ret
+}
+
 // WithDebugInfoEnabled implements RuntimeConfig.WithDebugInfoEnabled
 func (c *runtimeConfig) WithDebugInfoEnabled(dwarfEnabled bool) RuntimeConfig {
 	ret := c.clone()
@@ -302,7 +449,7 @@ type CompiledModule interface {
 	//
 	// ## Notes
 	//   - As of WebAssembly Core Specification 2.0, there can be at most one
-	//     memory.
+	//     memory unless api.CoreFeatureMultiMemory is enabled.
 	//   - Unlike ExportedMemories, there is no unique constraint on imports.
 	ImportedMemories() []api.MemoryDefinition
 
@@ -310,13 +457,44 @@ type CompiledModule interface {
 	// (api.MemoryDefinition) in this module keyed on export name.
 	//
 	// Note: As of WebAssembly Core Specification 2.0, there can be at most one
-	// memory.
+	// memory unless api.CoreFeatureMultiMemory is enabled.
 	ExportedMemories() map[string]api.MemoryDefinition
 
+	// ImportedTables returns all the imported tables
+	// (api.TableDefinition) in this module or nil if there are none.
+	//
+	// Note: Unlike ExportedTables, there is no unique constraint on imports.
+	ImportedTables() []api.TableDefinition
+
+	// ExportedTables returns all the exported tables
+	// (api.TableDefinition) in this module keyed on export name.
+	ExportedTables() map[string]api.TableDefinition
+
 	// CustomSections returns all the custom sections
 	// (api.CustomSection) in this module keyed on the section name.
 	CustomSections() []api.CustomSection
 
+	// Disassemble returns this module in the WebAssembly Text Format (%.wat),
+	// using any names in its "name" custom section as identifiers.
+	//
+	// Note: This is intended for debugging. The result can be compiled again,
+	// but the exact formatting may change between versions of wazero.
+	Disassemble() string
+
+	// DisassembleFunction returns a function in this module in the
+	// WebAssembly Text Format (%.wat), where each instruction is preceded by
+	// a comment of its offset in the code section, e.g. "(;@1a;)".
+	//
+	// The function is either its decimal index, including imported
+	// functions, or its api.FunctionDefinition DebugName as printed in a
+	// stack trace, e.g. "math.add" or "math.add(i32,i32) i32". The module
+	// name may be omitted or differ, as it does when instantiated with
+	// ModuleConfig.WithName.
+	//
+	// Note: Offsets are those used by DWARF, so are only printed when the
+	// module was compiled from the binary format.
+	DisassembleFunction(function string) (string, error)
+
 	// Close releases all the allocated resources for this CompiledModule.
 	//
 	// Note: It is safe to call Close while having outstanding calls from an
@@ -370,6 +548,16 @@ func (c *compiledModule) ExportedMemories() map[string]api.MemoryDefinition {
 	return c.module.ExportedMemories()
 }
 
+// ImportedTables implements CompiledModule.ImportedTables
+func (c *compiledModule) ImportedTables() []api.TableDefinition {
+	return c.module.ImportedTables()
+}
+
+// ExportedTables implements CompiledModule.ExportedTables
+func (c *compiledModule) ExportedTables() map[string]api.TableDefinition {
+	return c.module.ExportedTables()
+}
+
 // CustomSections implements CompiledModule.CustomSections
 func (c *compiledModule) CustomSections() []api.CustomSection {
 	ret := make([]api.CustomSection, len(c.module.CustomSections))
@@ -379,6 +567,56 @@ func (c *compiledModule) CustomSections() []api.CustomSection {
 	return ret
 }
 
+// Disassemble implements CompiledModule.Disassemble
+func (c *compiledModule) Disassemble() string {
+	return string(textformat.EncodeModule(c.module))
+}
+
+// DisassembleFunction implements CompiledModule.DisassembleFunction
+func (c *compiledModule) DisassembleFunction(function string) (string, error) {
+	funcIdx, ok := c.lookupFunction(function)
+	if !ok {
+		return "", fmt.Errorf("function %q not found", function)
+	}
+	wat, err := textformat.EncodeFunction(c.module, funcIdx)
+	return string(wat), err
+}
+
+// lookupFunction returns the index of the function by its decimal index or debug name, ignoring any signature and
+// module name.
+func (c *compiledModule) lookupFunction(function string) (wasm.Index, bool) {
+	if i := strings.IndexByte(function, '('); i > 0 {
+		function = function[:i]
+	}
+	funcCount := c.module.ImportFuncCount() + wasm.Index(len(c.module.FunctionSection))
+	if idx, err := strconv.ParseUint(function, 10, 32); err == nil {
+		return wasm.Index(idx), wasm.Index(idx) < funcCount
+	}
+
+	names := map[wasm.Index]string{}
+	if ns := c.module.NameSection; ns != nil {
+		for _, n := range ns.FunctionNames {
+			names[n.Index] = n.Name
+		}
+	}
+	moduleName := c.Name()
+	var suffixMatch *wasm.Index
+	for idx := wasm.Index(0); idx < funcCount; idx++ {
+		debugName := wasmdebug.FuncName(moduleName, names[idx], idx)
+		funcName := debugName[len(moduleName)+1:]
+		if function == debugName || function == funcName {
+			return idx, true
+		} else if suffixMatch == nil && strings.HasSuffix(function, "."+funcName) {
+			i := idx
+			suffixMatch = &i
+		}
+	}
+	if suffixMatch != nil {
+		return *suffixMatch, true
+	}
+	return 0, false
+}
+
 // customSection implements wasm.CustomSection
 type customSection struct {
 	name string
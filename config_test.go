@@ -52,6 +52,15 @@ func TestRuntimeConfig(t *testing.T) {
 				memoryCapacityFromMax: true,
 			},
 		},
+		{
+			name: "memoryReservation",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithMemoryReservation(true)
+			},
+			expected: &runtimeConfig{
+				memoryReservation: true,
+			},
+		},
 		{
 			name: "WithDebugInfoEnabled",
 			with: func(c RuntimeConfig) RuntimeConfig {
@@ -75,6 +84,21 @@ func TestRuntimeConfig(t *testing.T) {
 			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCloseOnContextDone(true) },
 			expected: &runtimeConfig{ensureTermination: true},
 		},
+		{
+			name:     "WithInterruptOnContextDone",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithInterruptOnContextDone(true) },
+			expected: &runtimeConfig{interruptOnDone: true},
+		},
+		{
+			name:     "WithFuelMetering",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithFuelMetering(true) },
+			expected: &runtimeConfig{fuelMetering: true},
+		},
+		{
+			name:     "WithCoverage",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCoverage(true) },
+			expected: &runtimeConfig{coverage: true},
+		},
 	}
 
 	for _, tt := range tests {
@@ -92,9 +116,9 @@ func TestRuntimeConfig(t *testing.T) {
 	t.Run("memoryLimitPages invalid panics", func(t *testing.T) {
 		err := require.CapturePanic(func() {
 			input := &runtimeConfig{}
-			input.WithMemoryLimitPages(wasm.MemoryLimitPages + 1)
+			input.WithMemoryLimitPages(wasm.MemoryLimitPages64 + 1)
 		})
-		require.EqualError(t, err, "memoryLimitPages invalid: 65537 > 65536")
+		require.EqualError(t, err, "memoryLimitPages invalid: 2147483649 > 2147483648")
 	})
 }
 
@@ -655,6 +679,67 @@ func Test_compiledModule_CustomSections(t *testing.T) {
 	}
 }
 
+func Test_compiledModule_Disassemble(t *testing.T) {
+	c := &compiledModule{module: &wasm.Module{
+		TypeSection: []*wasm.FunctionType{{}},
+		ImportSection: []*wasm.Import{
+			{Type: wasm.ExternTypeFunc, Module: "env", Name: "log", DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeNop, wasm.OpcodeEnd}, BodyOffsetInCodeSection: 4},
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}, BodyOffsetInCodeSection: 8},
+		},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "test",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}, {Index: 1, Name: "nop"}},
+		},
+	}}
+
+	require.Equal(t, `(module $test
+  (type (;0;) (func))
+  (import "env" "log" (func $log (type 0)))
+  (func $nop (type 0)
+    nop)
+  (func (;2;) (type 0)
+    call $log))
+`, c.Disassemble())
+
+	nop := `(func $nop (type 0)
+(;@4   ;)   nop)
+`
+	unnamed := `(func (;2;) (type 0)
+(;@8   ;)   call $log)
+`
+	tests := []struct {
+		function, expected string
+	}{
+		{function: "0", expected: "(import \"env\" \"log\" (func $log (type 0)))\n"},
+		{function: "1", expected: nop},
+		{function: "test.nop", expected: nop},
+		{function: "nop", expected: nop},
+		{function: "test.nop()", expected: nop},
+		{function: "instance.nop()", expected: nop},
+		{function: "test.$2", expected: unnamed},
+		{function: "$2", expected: unnamed},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.function, func(t *testing.T) {
+			wat, err := c.DisassembleFunction(tc.function)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, wat)
+		})
+	}
+
+	_, err := c.DisassembleFunction("test.log2")
+	require.EqualError(t, err, `function "test.log2" not found`)
+	_, err = c.DisassembleFunction("3")
+	require.EqualError(t, err, `function "3" not found`)
+}
+
 func Test_compiledModule_Close(t *testing.T) {
 	for _, ctx := range []context.Context{nil, testCtx} { // Ensure it doesn't crash on nil!
 		e := &mockEngine{name: "1", cachedModules: map[*wasm.Module]struct{}{}}
@@ -662,7 +747,7 @@ func Test_compiledModule_Close(t *testing.T) {
 		var cs []*compiledModule
 		for i := 0; i < 10; i++ {
 			m := &wasm.Module{}
-			err := e.CompileModule(ctx, m, nil, false)
+			err := e.CompileModule(ctx, m, nil, false, false, false)
 			require.NoError(t, err)
 			cs = append(cs, &compiledModule{module: m, compiledEngine: e})
 		}
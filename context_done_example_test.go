@@ -3,6 +3,7 @@ package wazero_test
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -128,3 +129,40 @@ func ExampleRuntimeConfig_WithCloseOnContextDone_moduleClose() {
 	// Output:
 	//	module "malicious_wasm" closed with exit_code(1)
 }
+
+// ExampleRuntimeConfig_WithInterruptOnContextDone demonstrates how to interrupt the invocation of infinite loop
+// function with context.Context created by context.WithTimeout, while keeping the module instance usable.
+func ExampleRuntimeConfig_WithInterruptOnContextDone() {
+	r := wazero.NewRuntimeWithConfig(context.Background(),
+		// Enables the WithInterruptOnContextDone option.
+		wazero.NewRuntimeConfig().WithInterruptOnContextDone(true))
+
+	compiledModule, err := r.CompileModule(context.Background(), infiniteLoopWasm)
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	moduleInstance, err := r.InstantiateModule(context.Background(), compiledModule,
+		wazero.NewModuleConfig().WithName("malicious_wasm"))
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	infiniteLoop := moduleInstance.ExportedFunction("infinite_loop")
+
+	for i := 0; i < 2; i++ {
+		// Create the context.Context to be passed to the invocation of infinite_loop.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		// Invoke the infinite loop with the timeout context.
+		_, err = infiniteLoop.Call(ctx)
+		cancel()
+
+		// Timeout only interrupts this invocation, so the module instance can be called again.
+		fmt.Println(errors.Is(err, wazero.ErrInterrupted))
+	}
+
+	// Output:
+	// true
+	// true
+}
@@ -0,0 +1,225 @@
+package wazero
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Coverage is the coverage of the functions defined by a module compiled
+// with RuntimeConfig.WithCoverage, as returned by CompiledModuleCoverage or
+// ModuleCoverage.
+type Coverage struct {
+	// Functions are the functions defined by the module, in the order of
+	// their index.
+	Functions []FunctionCoverage
+}
+
+// FunctionCoverage is the coverage of a function, which is the times each of
+// its basic blocks was executed.
+type FunctionCoverage struct {
+	// Definition is the definition of the function.
+	Definition api.FunctionDefinition
+
+	// Blocks are the basic blocks of the function, in the order of their
+	// instructions.
+	Blocks []CoverageBlock
+}
+
+// CoverageBlock is a basic block of a function, which is a sequence of
+// instructions always executed together.
+type CoverageBlock struct {
+	// Start is the offset of the first instruction of the block in the code
+	// section of the Wasm binary, and End is the one of the instruction after
+	// the last. These are the offsets in the DWARF debug information.
+	Start, End uint64
+
+	// Count is the number of times the block was executed.
+	Count uint64
+}
+
+// CompiledModuleCoverage returns the coverage of the calls of all the
+// modules instantiated from compiled, or nil if it wasn't compiled with
+// RuntimeConfig.WithCoverage or is closed.
+//
+// Note: The counters are shared by the CompiledModule of the same binary
+// compiled by the same Runtime, or by the ones sharing a CompilationCache.
+func CompiledModuleCoverage(compiled CompiledModule) *Coverage {
+	c, ok := compiled.(*compiledModule)
+	if !ok {
+		return nil
+	}
+	return newCoverage(c.module, c.compiledEngine.Coverage(c.module))
+}
+
+// ModuleCoverage returns the coverage of the calls of mod, or nil if it
+// wasn't compiled with RuntimeConfig.WithCoverage.
+//
+// Note: The counters are the ones of the CompiledModule mod was instantiated
+// from, so this includes the calls of the other modules instantiated from it.
+func ModuleCoverage(mod api.Module) *Coverage {
+	c, ok := mod.(*wasm.CallContext)
+	if !ok {
+		return nil
+	}
+	m := c.Module()
+	if m.Engine == nil || m.Source == nil {
+		return nil
+	}
+	return newCoverage(m.Source, m.Engine.Coverage())
+}
+
+func newCoverage(m *wasm.Module, blocks [][]wasm.CoverageBlock) *Coverage {
+	if blocks == nil {
+		return nil
+	}
+	imported := m.ImportFuncCount()
+	ret := &Coverage{Functions: make([]FunctionCoverage, len(blocks))}
+	for i, bs := range blocks {
+		f := &ret.Functions[i]
+		f.Definition = m.FunctionDefinitionSection[imported+wasm.Index(i)]
+		f.Blocks = make([]CoverageBlock, len(bs))
+		for j, b := range bs {
+			f.Blocks[j] = CoverageBlock(b)
+		}
+	}
+	return ret
+}
+
+// WriteLCOV writes the coverage of the lines of the source files to w, in
+// the tracefile format of LCOV, e.g. for `genhtml`.
+//
+// The lines of the blocks are given by the DWARF debug information of the
+// module, so nothing is written for the functions without it, such as when
+// RuntimeConfig.WithDebugInfoEnabled is false. A line is executed as many
+// times as the block of its instructions executed the most.
+//
+// Note: LCOV is the only format written, as DWARF gives lines, not the
+// column ranges of statements, and the absolute paths of source files, not
+// the import paths of Go packages, which the profiles of `go tool cover`
+// need.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	var b strings.Builder
+	sources := c.sources()
+	for _, file := range sortedFiles(sources) {
+		s := sources[file]
+		fmt.Fprintf(&b, "TN:\nSF:%s\n", file)
+		var hit int
+		for _, f := range s.functions {
+			fmt.Fprintf(&b, "FN:%d,%s\n", f.line, f.name)
+		}
+		for _, f := range s.functions {
+			fmt.Fprintf(&b, "FNDA:%d,%s\n", f.count, f.name)
+			if f.count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(s.functions), hit)
+
+		hit = 0
+		lines := s.sortedLines()
+		for _, l := range lines {
+			fmt.Fprintf(&b, "DA:%d,%d\n", l, s.lines[l])
+			if s.lines[l] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sourceCoverage is the coverage of a source file.
+type sourceCoverage struct {
+	// lines are the times each line was executed.
+	lines map[int64]uint64
+	// functions are the functions beginning in the file, in the order of
+	// their line.
+	functions []sourceFunction
+}
+
+// sourceFunction is a function beginning at line, called count times.
+type sourceFunction struct {
+	name  string
+	line  int64
+	count uint64
+}
+
+func (s *sourceCoverage) sortedLines() []int64 {
+	lines := make([]int64, 0, len(s.lines))
+	for l := range s.lines {
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i] < lines[j] })
+	return lines
+}
+
+func sortedFiles(sources map[string]*sourceCoverage) []string {
+	files := make([]string, 0, len(sources))
+	for file := range sources {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// sources maps the blocks of the functions to the lines of the source files
+// of their instructions, including the ones of the calls of inlined
+// functions.
+func (c *Coverage) sources() map[string]*sourceCoverage {
+	ret := map[string]*sourceCoverage{}
+	source := func(file string) *sourceCoverage {
+		s, ok := ret[file]
+		if !ok {
+			s = &sourceCoverage{lines: map[int64]uint64{}}
+			ret[file] = s
+		}
+		return s
+	}
+
+	for _, f := range c.Functions {
+		def, ok := f.Definition.(*wasm.FunctionDefinition)
+		if !ok {
+			continue
+		}
+		lines := def.DWARFLines()
+		for i, b := range f.Blocks {
+			for j, offset := range lines.LineOffsets(b.Start, b.End) {
+				sourceLines := lines.SourceLines(offset)
+				for _, l := range sourceLines {
+					if l.Line == 0 {
+						continue
+					}
+					s := source(l.File)
+					if count, ok := s.lines[l.Line]; !ok || b.Count > count {
+						s.lines[l.Line] = b.Count
+					}
+				}
+
+				// The function begins at the line of its first instruction,
+				// outside the inlined functions, and is called as many times
+				// as its first block is executed.
+				if i == 0 && j == 0 && len(sourceLines) > 0 {
+					if l := sourceLines[len(sourceLines)-1]; l.Line != 0 {
+						name := def.Name()
+						if name == "" {
+							name = def.DebugName()
+						}
+						s := source(l.File)
+						s.functions = append(s.functions, sourceFunction{name: name, line: l.Line, count: b.Count})
+					}
+				}
+			}
+		}
+	}
+
+	for _, s := range ret {
+		sort.SliceStable(s.functions, func(i, j int) bool { return s.functions[i].line < s.functions[j].line })
+	}
+	return ret
+}
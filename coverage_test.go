@@ -0,0 +1,91 @@
+package wazero
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// zigCoverage returns the coverage of calling the start function of dwarftestdata.ZigWasm, which panics in main.main.
+func zigCoverage(t *testing.T, config RuntimeConfig) *Coverage {
+	r := NewRuntimeWithConfig(testCtx, config.WithCoverage(true))
+	t.Cleanup(func() { r.Close(testCtx) })
+
+	compiled, err := r.CompileModule(testCtx, dwarftestdata.ZigWasm)
+	require.NoError(t, err)
+	_, err = r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+	require.Error(t, err)
+	return CompiledModuleCoverage(compiled)
+}
+
+// trimZigPaths removes the directories of the source files of dwarftestdata.ZigWasm.
+func trimZigPaths(s string) string {
+	s = strings.ReplaceAll(s, "/Users/adrian/Downloads/zig-macos-x86_64-0.11.0-dev.1499+23b7d2889/", "")
+	return strings.ReplaceAll(s, "/Users/adrian/oss/wazero/internal/testing/dwarftestdata/testdata/", "")
+}
+
+func TestCoverage_WriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, zigCoverage(t, NewRuntimeConfig()).WriteLCOV(&buf))
+	// The lines of the calls of the functions inlined into main.main, and _start, are covered too.
+	require.Equal(t, `TN:
+SF:lib/std/builtin.zig
+FN:837,builtin.default_panic
+FNDA:1,builtin.default_panic
+FNF:1
+FNH:1
+DA:837,1
+DA:858,1
+LF:2
+LH:2
+end_of_record
+TN:
+SF:lib/std/start.zig
+FN:232,_start
+FNDA:1,_start
+FNF:1
+FNH:1
+DA:232,1
+DA:616,1
+DA:624,1
+LF:3
+LH:3
+end_of_record
+TN:
+SF:zig/main.zig
+FN:2,main.main
+FNDA:1,main.main
+FNF:1
+FNH:1
+DA:2,1
+DA:6,1
+DA:10,1
+LF:3
+LH:3
+end_of_record
+`, trimZigPaths(buf.String()))
+
+	// Without debug info, there are no source files.
+	buf.Reset()
+	require.NoError(t, zigCoverage(t, NewRuntimeConfig().WithDebugInfoEnabled(false)).WriteLCOV(&buf))
+	require.Equal(t, "", buf.String())
+}
+
+func TestCoverage_disabled(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := r.CompileModule(testCtx, []byte(`(module (func (export "f")))`))
+	require.NoError(t, err)
+	mod, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+	require.NoError(t, err)
+	host, err := r.NewHostModuleBuilder("env").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	require.Nil(t, CompiledModuleCoverage(compiled))
+	require.Nil(t, ModuleCoverage(mod))
+	require.Nil(t, ModuleCoverage(host))
+}
@@ -35,9 +35,11 @@ type FunctionListener interface {
 	//   - mod: the calling module.
 	//   - def: the function definition.
 	//   - paramValues:  api.ValueType encoded parameters.
+	//   - stackIterator: the active frames of the call stack, beginning
+	//	   with the frame of this function.
 	//
 	// Note: api.Memory is meant for inspection, not modification.
-	Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64) context.Context
+	Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64, stackIterator StackIterator) context.Context
 
 	// After is invoked after a function is called.
 	//
@@ -49,17 +51,128 @@ type FunctionListener interface {
 	//   - err: nil if the function didn't err
 	//   - resultValues: api.ValueType encoded results.
 	//
-	// Note: api.Memory is meant for inspection, not modification.
+	// # Notes
+	//
+	//   - api.Memory is meant for inspection, not modification.
+	//   - No StackIterator is passed, as the frame of the function was
+	//     already popped, and the frames of its callers are the ones passed
+	//     to Before. Listeners needing them here, such as profilers, record
+	//     what they need in Before and pass it via the returned context.
 	After(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error, resultValues []uint64)
 }
 
+// StackIterator iterates over the active frames of the call stack, beginning
+// with the frame of the function being called and ending with the frame of the
+// function called by the host. This includes the frames of host functions.
+//
+// Next must be called before reading the first frame:
+//
+//	for stackIterator.Next() {
+//		def := stackIterator.FunctionDefinition()
+//		...
+//	}
+//
+// Note: The iterator is only valid during the call to FunctionListener.Before
+// it was passed to, and must not be retained.
+type StackIterator interface {
+	// Next moves the iterator to the next frame, and returns false when there
+	// are no more frames.
+	Next() bool
+
+	// FunctionDefinition returns the function of the current frame.
+	FunctionDefinition() api.FunctionDefinition
+
+	// SourceOffset returns the offset in the code section of the Wasm binary
+	// of the instruction being executed in the current frame, which is the
+	// call of the previous frame, or zero when unknown.
+	//
+	// This is only known when wazero.RuntimeConfig WithDebugInfoEnabled is
+	// true and the module has DWARF sections. It is always zero for the frame
+	// of the function being called, and for host functions.
+	SourceOffset() uint64
+}
+
+// MultiFunctionListenerFactory returns a FunctionListenerFactory which
+// notifies the listeners of all the given factories, in order.
+//
+// This allows the use of multiple factories, as only one can be set as the
+// value of FunctionListenerFactoryKey.
+func MultiFunctionListenerFactory(factories ...FunctionListenerFactory) FunctionListenerFactory {
+	return multiListenerFactory(factories)
+}
+
+type multiListenerFactory []FunctionListenerFactory
+
+// NewListener implements FunctionListenerFactory.NewListener
+func (f multiListenerFactory) NewListener(def api.FunctionDefinition) FunctionListener {
+	var listeners multiListener
+	for _, factory := range f {
+		if l := factory.NewListener(def); l != nil {
+			listeners = append(listeners, l)
+		}
+	}
+	switch len(listeners) {
+	case 0:
+		return nil
+	case 1:
+		return listeners[0]
+	default:
+		return listeners
+	}
+}
+
+type multiListener []FunctionListener
+
+// Before implements FunctionListener.Before
+func (l multiListener) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64, stackIterator StackIterator) context.Context {
+	// Each listener gets its own iteration of the frames, as stackIterator can only be iterated once.
+	var frames []stackFrame
+	for stackIterator != nil && stackIterator.Next() {
+		frames = append(frames, stackFrame{def: stackIterator.FunctionDefinition(), sourceOffset: stackIterator.SourceOffset()})
+	}
+	for _, lsn := range l {
+		ctx = lsn.Before(ctx, mod, def, paramValues, &stackFramesIterator{frames: frames, index: -1})
+	}
+	return ctx
+}
+
+// After implements FunctionListener.After
+func (l multiListener) After(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error, resultValues []uint64) {
+	// Contexts returned by Before are nested, so the last one has the values of all listeners.
+	for i := len(l) - 1; i >= 0; i-- {
+		l[i].After(ctx, mod, def, err, resultValues)
+	}
+}
+
+type stackFrame struct {
+	def          api.FunctionDefinition
+	sourceOffset uint64
+}
+
+// stackFramesIterator implements StackIterator over frames copied from another StackIterator.
+type stackFramesIterator struct {
+	frames []stackFrame
+	index  int
+}
+
+// Next implements StackIterator.Next
+func (si *stackFramesIterator) Next() bool {
+	si.index++
+	return si.index < len(si.frames)
+}
+
+// FunctionDefinition implements StackIterator.FunctionDefinition
+func (si *stackFramesIterator) FunctionDefinition() api.FunctionDefinition {
+	return si.frames[si.index].def
+}
+
+// SourceOffset implements StackIterator.SourceOffset
+func (si *stackFramesIterator) SourceOffset() uint64 {
+	return si.frames[si.index].sourceOffset
+}
+
 // TODO: We need to add tests to enginetest to ensure contexts nest. A good test can use a combination of call and call
 // indirect in terms of depth and breadth. The test could show a tree 3 calls deep where the there are a couple calls at
 // each depth under the root. The main thing this can help prevent is accidentally swapping the context internally.
 
 // TODO: Errors aren't handled, and the After hook should accept one along with the result values.
-
-// TODO: The context parameter of the After hook is not the same as the Before hook. This means interceptor patterns
-// are awkward. e.g. something like timing is difficult as it requires propagating a stack. Otherwise, nested calls will
-// overwrite each other's "since" time. Propagating a stack is further awkward as the After hook needs to know the
-// position to read from which might be subtle.
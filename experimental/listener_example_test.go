@@ -43,7 +43,7 @@ func (u uniqGoFuncs) NewListener(def api.FunctionDefinition) FunctionListener {
 }
 
 // Before implements FunctionListener.Before
-func (u uniqGoFuncs) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64) context.Context {
+func (u uniqGoFuncs) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ StackIterator) context.Context {
 	u[def.DebugName()] = struct{}{}
 	return ctx
 }
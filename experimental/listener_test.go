@@ -3,11 +3,14 @@ package experimental_test
 import (
 	"context"
 	_ "embed"
+	"fmt"
 	"testing"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasm/binary"
@@ -21,7 +24,7 @@ type recorder struct {
 	beforeNames, afterNames []string
 }
 
-func (r *recorder) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64) context.Context {
+func (r *recorder) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ StackIterator) context.Context {
 	r.beforeNames = append(r.beforeNames, def.DebugName())
 	return ctx
 }
@@ -100,3 +103,108 @@ func TestFunctionListenerFactory(t *testing.T) {
 	require.Equal(t, []string{"test.fn1", "test.fn2", "test.fn2"}, factory.beforeNames)
 	require.Equal(t, []string{"test.fn2", "test.fn2", "test.fn1"}, factory.afterNames) // after is in the reverse order.
 }
+
+// stackRecorder records the stack of each call to Before, as the debug names of the functions followed by the source
+// offsets of their frames.
+type stackRecorder struct {
+	stacks [][]string
+}
+
+func (r *stackRecorder) NewListener(api.FunctionDefinition) FunctionListener {
+	return r
+}
+
+func (r *stackRecorder) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, si StackIterator) context.Context {
+	var stack []string
+	for si.Next() {
+		stack = append(stack, fmt.Sprintf("%s:%#x", si.FunctionDefinition().DebugName(), si.SourceOffset()))
+	}
+	r.stacks = append(r.stacks, stack)
+	return ctx
+}
+
+func (r *stackRecorder) After(context.Context, api.Module, api.FunctionDefinition, error, []uint64) {}
+
+func TestStackIterator(t *testing.T) {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			t.Run("host", func(t *testing.T) {
+				rec := &stackRecorder{}
+				ctx := context.WithValue(context.Background(), FunctionListenerFactoryKey{}, rec)
+				r := wazero.NewRuntimeWithConfig(ctx, config)
+				defer r.Close(ctx)
+
+				_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+					WithFunc(func(ctx context.Context, m api.Module) {
+						_, err := m.ExportedFunction("leaf").Call(ctx)
+						require.NoError(t, err)
+					}).Export("host").Instantiate(ctx)
+				require.NoError(t, err)
+
+				mod, err := r.InstantiateModuleFromBinary(ctx, []byte(`(module $test
+  (import "env" "host" (func $host))
+  (func $leaf (export "leaf"))
+  (func $main (export "main") (call $host)))`))
+				require.NoError(t, err)
+
+				_, err = mod.ExportedFunction("main").Call(ctx)
+				require.NoError(t, err)
+
+				// Without DWARF, the source offsets are unknown. A call from the host begins a new stack.
+				require.Equal(t, [][]string{
+					{"test.main:0x0"},
+					{"env.host:0x0", "test.main:0x0"},
+					{"test.leaf:0x0"},
+				}, rec.stacks)
+			})
+			t.Run("dwarf", func(t *testing.T) {
+				rec := &stackRecorder{}
+				ctx := context.WithValue(context.Background(), FunctionListenerFactoryKey{}, rec)
+				r := wazero.NewRuntimeWithConfig(ctx, config)
+				defer r.Close(ctx)
+
+				_, err := r.InstantiateModuleFromBinary(ctx, dwarftestdata.ZigWasm)
+				require.Error(t, err) // panics
+
+				require.Equal(t, []string{
+					".builtin.default_panic:0x0",
+					".main.main:0x60",
+					"._start:0x6a",
+				}, rec.stacks[len(rec.stacks)-1])
+			})
+		})
+	}
+}
+
+func TestMultiFunctionListenerFactory(t *testing.T) {
+	recorder1, recorder2 := &recorder{m: map[string]struct{}{}}, &recorder{m: map[string]struct{}{}}
+	stacks1, stacks2 := &stackRecorder{}, &stackRecorder{}
+	factory := MultiFunctionListenerFactory(recorder1, stacks1, recorder2, stacks2)
+	ctx := context.WithValue(context.Background(), FunctionListenerFactoryKey{}, factory)
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, []byte(`(module $test
+  (func $leaf)
+  (func $main (export "main") (call $leaf)))`))
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("main").Call(ctx)
+	require.NoError(t, err)
+
+	for _, r := range []*recorder{recorder1, recorder2} {
+		require.Equal(t, []string{"test.main", "test.leaf"}, r.beforeNames)
+		require.Equal(t, []string{"test.leaf", "test.main"}, r.afterNames)
+	}
+	// Each listener iterates over the whole stack.
+	for _, r := range []*stackRecorder{stacks1, stacks2} {
+		require.Equal(t, [][]string{{"test.main:0x0"}, {"test.leaf:0x0", "test.main:0x0"}}, r.stacks)
+	}
+}
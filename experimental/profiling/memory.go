@@ -0,0 +1,248 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// AllocatorFunctionKind is the kind of function of a memory allocator.
+type AllocatorFunctionKind uint8
+
+const (
+	// AllocatorFunctionAlloc allocates memory of the size given by AllocatorFunction.SizeParams, and returns the
+	// pointer to it, like malloc and calloc.
+	AllocatorFunctionAlloc AllocatorFunctionKind = iota
+	// AllocatorFunctionRealloc is like AllocatorFunctionAlloc, except it also frees the memory given by
+	// AllocatorFunction.PointerParam when it succeeds, like realloc.
+	AllocatorFunctionRealloc
+	// AllocatorFunctionFree frees the memory given by AllocatorFunction.PointerParam, like free.
+	AllocatorFunctionFree
+)
+
+// AllocatorFunction describes a function of a memory allocator, which is defined by the guest or imported from the
+// host.
+type AllocatorFunction struct {
+	// Name is the name of the function in the name section, or one of its export names.
+	Name string
+	// Kind is the kind of the function.
+	Kind AllocatorFunctionKind
+	// SizeParams are the indexes of the parameters whose product is the size of the memory allocated.
+	SizeParams []int
+	// PointerParam is the index of the parameter of the pointer to the memory freed.
+	PointerParam int
+}
+
+// matches returns true if the parameters and results of def are the ones of this function.
+func (fn *AllocatorFunction) matches(def api.FunctionDefinition) bool {
+	paramCount := len(def.ParamTypes())
+	for _, i := range fn.SizeParams {
+		if i >= paramCount {
+			return false
+		}
+	}
+	if fn.Kind == AllocatorFunctionFree {
+		return fn.PointerParam < paramCount
+	}
+	return len(def.ResultTypes()) > 0 && (fn.Kind == AllocatorFunctionAlloc || fn.PointerParam < paramCount)
+}
+
+// LibcAllocator are the functions of the memory allocator of libc, as used by C, TinyGo and Zig guests.
+var LibcAllocator = []AllocatorFunction{
+	{Name: "malloc", Kind: AllocatorFunctionAlloc, SizeParams: []int{0}},
+	{Name: "calloc", Kind: AllocatorFunctionAlloc, SizeParams: []int{0, 1}},
+	{Name: "realloc", Kind: AllocatorFunctionRealloc, SizeParams: []int{1}, PointerParam: 0},
+	{Name: "free", Kind: AllocatorFunctionFree, PointerParam: 0},
+}
+
+// RustAllocator are the functions of the global memory allocator of Rust guests.
+var RustAllocator = []AllocatorFunction{
+	{Name: "__rust_alloc", Kind: AllocatorFunctionAlloc, SizeParams: []int{0}},
+	{Name: "__rust_alloc_zeroed", Kind: AllocatorFunctionAlloc, SizeParams: []int{0}},
+	{Name: "__rust_realloc", Kind: AllocatorFunctionRealloc, SizeParams: []int{3}, PointerParam: 0},
+	{Name: "__rust_dealloc", Kind: AllocatorFunctionFree, PointerParam: 0},
+}
+
+// MemoryProfiler is an experimental.FunctionListenerFactory which records the memory allocated by the functions of
+// allocators, by the call stacks they were called from, and writes it as a pprof heap profile for `go tool pprof`.
+//
+// The profile has the memory allocated since the MemoryProfiler was created, and the memory in use, which is the one not
+// yet freed, such as leaks.
+//
+// Here's an example of profiling the memory allocated by a C guest:
+//
+//	p := profiling.NewMemoryProfiler(profiling.LibcAllocator...)
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, p)
+//	mod, _ := r.InstantiateModule(ctx, compiled, config)
+//	_ = p.WriteProfile(w)
+//
+// Note: The listeners are only notified of the functions compiled with the context.Context holding the
+// MemoryProfiler. The calls of allocator functions made by other ones, such as malloc called by calloc, are ignored.
+type MemoryProfiler struct {
+	functions map[string]*AllocatorFunction
+	start     time.Time
+
+	mux   sync.Mutex
+	table *stackTable
+	live  map[allocationKey]allocation
+}
+
+// allocationKey identifies an allocation by its pointer in a memory.
+type allocationKey struct {
+	mem api.Memory
+	ptr uint64
+}
+
+// allocation is memory in use, whose size is included in the values of sample.
+type allocation struct {
+	size   int64
+	sample *stackSample
+}
+
+// NewMemoryProfiler returns a MemoryProfiler which records the calls of the given allocator functions.
+func NewMemoryProfiler(functions ...AllocatorFunction) *MemoryProfiler {
+	p := &MemoryProfiler{
+		functions: map[string]*AllocatorFunction{},
+		start:     time.Now(),
+		table:     newStackTable(),
+		live:      map[allocationKey]allocation{},
+	}
+	for i := range functions {
+		p.functions[functions[i].Name] = &functions[i]
+	}
+	return p
+}
+
+// WriteProfile writes the profile of the memory allocated, and in use, to w.
+//
+// The profile is a gzip compressed protocol buffer, as described in https://github.com/google/pprof/tree/main/proto
+func (p *MemoryProfiler) WriteProfile(w io.Writer) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	prof := &profile{
+		// inuse_space is last, so that it is the default one like in Go heap profiles.
+		sampleTypes: []valueType{{"alloc_objects", "count"}, {"alloc_space", "bytes"}, {"inuse_objects", "count"}, {"inuse_space", "bytes"}},
+		scale:       1,
+		start:       p.start,
+		periodType:  valueType{"space", "bytes"},
+		period:      1,
+	}
+	return prof.write(w, p.table)
+}
+
+// NewListener implements experimental.FunctionListenerFactory.NewListener
+func (p *MemoryProfiler) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	fn, ok := p.functions[def.Name()]
+	for _, name := range def.ExportNames() {
+		if ok {
+			break
+		}
+		fn, ok = p.functions[name]
+	}
+	if !ok || !fn.matches(def) {
+		return nil
+	}
+	return &allocatorListener{p: p, fn: fn, paramTypes: def.ParamTypes(), resultTypes: def.ResultTypes()}
+}
+
+// allocatorCallKey is a context.Context Value key. Its associated value is the *allocatorCall of the current call of
+// an allocator function.
+type allocatorCallKey struct{}
+
+// allocatorCall holds the parameters of a call of an allocator function, until it returns.
+type allocatorCall struct {
+	size  int64
+	ptr   uint64
+	stack []frame
+}
+
+// nestedAllocatorCall is the allocatorCall of the calls of allocator functions made by other ones, which are ignored.
+var nestedAllocatorCall = &allocatorCall{}
+
+// allocatorListener implements experimental.FunctionListener for an allocator function.
+type allocatorListener struct {
+	p                       *MemoryProfiler
+	fn                      *AllocatorFunction
+	paramTypes, resultTypes []api.ValueType
+}
+
+// value returns the value v of type t, without the upper bits of i32 values, which are undefined.
+func value(t api.ValueType, v uint64) uint64 {
+	if t == api.ValueTypeI32 {
+		return uint64(uint32(v))
+	}
+	return v
+}
+
+// Before implements experimental.FunctionListener.Before
+func (l *allocatorListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, params []uint64, stackIterator experimental.StackIterator) context.Context {
+	if ctx.Value(allocatorCallKey{}) != nil {
+		return context.WithValue(ctx, allocatorCallKey{}, nestedAllocatorCall)
+	}
+
+	c := &allocatorCall{size: 1}
+	for _, i := range l.fn.SizeParams {
+		c.size *= int64(value(l.paramTypes[i], params[i]))
+	}
+	if l.fn.Kind != AllocatorFunctionAlloc {
+		c.ptr = value(l.paramTypes[l.fn.PointerParam], params[l.fn.PointerParam])
+	}
+	if l.fn.Kind != AllocatorFunctionFree {
+		c.stack = appendStack(nil, stackIterator)
+	}
+	return context.WithValue(ctx, allocatorCallKey{}, c)
+}
+
+// After implements experimental.FunctionListener.After
+func (l *allocatorListener) After(ctx context.Context, mod api.Module, _ api.FunctionDefinition, _ error, results []uint64) {
+	c, _ := ctx.Value(allocatorCallKey{}).(*allocatorCall)
+	if c == nil || c == nestedAllocatorCall {
+		return
+	}
+	mem := mod.Memory()
+	var ptr uint64
+	if len(results) > 0 {
+		ptr = value(l.resultTypes[0], results[0])
+	}
+
+	p := l.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	switch l.fn.Kind {
+	case AllocatorFunctionAlloc:
+		p.allocated(mem, ptr, c)
+	case AllocatorFunctionRealloc:
+		// realloc(ptr, 0) may free ptr and return null, otherwise ptr is only freed when the new memory is allocated.
+		if ptr != 0 || c.size == 0 {
+			p.freed(mem, c.ptr)
+			p.allocated(mem, ptr, c)
+		}
+	case AllocatorFunctionFree:
+		p.freed(mem, c.ptr)
+	}
+}
+
+// allocated records the memory allocated at ptr by c, unless it failed.
+func (p *MemoryProfiler) allocated(mem api.Memory, ptr uint64, c *allocatorCall) {
+	if ptr == 0 {
+		return
+	}
+	s := p.table.add(c.stack, 1, c.size, 1, c.size)
+	p.live[allocationKey{mem: mem, ptr: ptr}] = allocation{size: c.size, sample: s}
+}
+
+// freed records the memory at ptr is no longer in use, unless it wasn't recorded, such as null.
+func (p *MemoryProfiler) freed(mem api.Memory, ptr uint64) {
+	key := allocationKey{mem: mem, ptr: ptr}
+	if a, ok := p.live[key]; ok {
+		a.sample.values[2]--
+		a.sample.values[3] -= a.size
+		delete(p.live, key)
+	}
+}
@@ -0,0 +1,99 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// allocatorWat has a bump allocator with libc functions, where calloc calls malloc, and "leak" and "no_leak" which
+// allocate memory and free some of it.
+const allocatorWat = `(module $test
+  (memory (export "memory") 1)
+  (global $next (mut i32) (i32.const 16))
+  (func $malloc (export "malloc") (param $size i32) (result i32)
+    (global.get $next)
+    (global.set $next (i32.add (global.get $next) (local.get $size))))
+  (func $calloc (export "calloc") (param $n i32) (param $size i32) (result i32)
+    (call $malloc (i32.mul (local.get $n) (local.get $size))))
+  (func $realloc (export "realloc") (param $ptr i32) (param $size i32) (result i32)
+    (call $malloc (local.get $size)))
+  (func $free (export "free") (param $ptr i32))
+  (func $leak (export "leak")
+    (drop (call $malloc (i32.const 10)))
+    (call $free (call $calloc (i32.const 2) (i32.const 3))))
+  (func $no_leak (export "no_leak")
+    (call $free (call $realloc (call $malloc (i32.const 4)) (i32.const 8)))))`
+
+func TestMemoryProfiler(t *testing.T) {
+	for name, config := range runtimeConfigs() {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			p := NewMemoryProfiler(LibcAllocator...)
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx)
+
+			mod, err := r.InstantiateModuleFromBinary(ctx, []byte(allocatorWat))
+			require.NoError(t, err)
+			for _, fn := range []string{"leak", "leak", "no_leak"} {
+				_, err = mod.ExportedFunction(fn).Call(ctx)
+				require.NoError(t, err)
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, p.WriteProfile(&buf))
+			prof := decodeProfile(t, buf.Bytes())
+			require.Equal(t, []valueType{
+				{"alloc_objects", "count"}, {"alloc_space", "bytes"}, {"inuse_objects", "count"}, {"inuse_space", "bytes"},
+			}, prof.sampleTypes)
+			// The malloc called by calloc and realloc isn't recorded, and only the memory of malloc in leak is in use.
+			require.Equal(t, map[string][]int64{
+				"test.malloc;test.leak":     {2, 20, 2, 20},
+				"test.calloc;test.leak":     {2, 12, 0, 0},
+				"test.malloc;test.no_leak":  {1, 4, 0, 0},
+				"test.realloc;test.no_leak": {1, 8, 0, 0},
+			}, prof.samples)
+		})
+	}
+}
+
+func TestMemoryProfiler_NewListener(t *testing.T) {
+	p := NewMemoryProfiler(RustAllocator...)
+
+	i32 := wasm.ValueTypeI32
+	end := []byte{wasm.OpcodeEnd}
+	m := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}},
+			{},
+		},
+		FunctionSection: []wasm.Index{0, 1, 0, 2},
+		CodeSection:     []*wasm.Code{{Body: end}, {Body: end}, {Body: end}, {Body: end}},
+		ExportSection: []*wasm.Export{
+			{Name: "__rust_dealloc", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "__rust_alloc_zeroed", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+		NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{
+			{Index: 0, Name: "__rust_alloc"},
+			{Index: 2, Name: "malloc"},
+		}},
+	}
+	m.BuildFunctionDefinitions()
+
+	// The allocator functions are matched by their name, or export name, unless they don't have the parameters and
+	// results used, like $3.
+	var names []string
+	for _, def := range m.FunctionDefinitionSection {
+		if p.NewListener(def) != nil {
+			names = append(names, def.DebugName())
+		}
+	}
+	require.Equal(t, []string{".__rust_alloc", ".$1"}, names)
+}
@@ -0,0 +1,276 @@
+// Package profiling includes profilers of the functions of modules, which write pprof profiles.
+//
+// See https://github.com/google/pprof
+package profiling
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
+)
+
+// valueType is the type and unit of a value of the samples in a pprof profile, such as "wall" and "nanoseconds".
+type valueType struct {
+	typ, unit string
+}
+
+// frame is a frame of a call stack, as given by experimental.StackIterator.
+type frame struct {
+	def          api.FunctionDefinition
+	sourceOffset uint64
+}
+
+// appendStack appends the frames of stackIterator to stack.
+func appendStack(stack []frame, stackIterator experimental.StackIterator) []frame {
+	for stackIterator.Next() {
+		stack = append(stack, frame{def: stackIterator.FunctionDefinition(), sourceOffset: stackIterator.SourceOffset()})
+	}
+	return stack
+}
+
+// stackSample holds the values of the samples for a call stack.
+type stackSample struct {
+	// locations are the ids of the frames of the call stack in stackTable, beginning with the innermost one.
+	locations []uint64
+	values    []int64
+}
+
+// stackTable aggregates the values of samples by call stack. This is not goroutine-safe.
+type stackTable struct {
+	// frames are the frames of all the call stacks, where the id of a frame is its index plus one.
+	frames   []frame
+	frameIDs map[frame]uint64
+	samples  map[string]*stackSample
+	// order is the order in which the call stacks were first seen, so that the profile is deterministic.
+	order []*stackSample
+	// key is reused to build the keys of samples.
+	key []byte
+}
+
+func newStackTable() *stackTable {
+	return &stackTable{frameIDs: map[frame]uint64{}, samples: map[string]*stackSample{}}
+}
+
+// add adds the values to the sample of the call stack, beginning with the innermost frame, and returns the sample.
+func (t *stackTable) add(stack []frame, values ...int64) *stackSample {
+	t.key = t.key[:0]
+	var buf [binary.MaxVarintLen64]byte
+	for _, f := range stack {
+		id, ok := t.frameIDs[f]
+		if !ok {
+			t.frames = append(t.frames, f)
+			id = uint64(len(t.frames))
+			t.frameIDs[f] = id
+		}
+		t.key = append(t.key, buf[:binary.PutUvarint(buf[:], id)]...)
+	}
+
+	s, ok := t.samples[string(t.key)]
+	if !ok {
+		s = &stackSample{values: make([]int64, len(values))}
+		for _, f := range stack {
+			s.locations = append(s.locations, t.frameIDs[f])
+		}
+		t.samples[string(t.key)] = s
+		t.order = append(t.order, s)
+	}
+	for i, v := range values {
+		s.values[i] += v
+	}
+	return s
+}
+
+// profile holds the information of a pprof profile besides the samples of a stackTable.
+type profile struct {
+	sampleTypes []valueType
+	// scale multiplies the values of the samples, to estimate the values from a fraction of them.
+	scale      float64
+	start      time.Time
+	duration   time.Duration
+	periodType valueType
+	period     int64
+}
+
+// write writes the samples of t as a gzip compressed pprof profile, which is a protocol buffer message defined by
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+func (p *profile) write(w io.Writer, t *stackTable) error {
+	strs := stringTable{index: map[string]int64{}}
+	strs.add("") // The first string must be empty.
+
+	var b protobuf
+	for _, vt := range p.sampleTypes {
+		b.message(1, func(b *protobuf) { vt.encode(b, &strs) })
+	}
+
+	for _, s := range t.order {
+		b.message(2, func(b *protobuf) {
+			b.packedUint64(1, s.locations)
+			values := make([]uint64, len(s.values))
+			for i, v := range s.values {
+				values[i] = uint64(int64(float64(v) * p.scale))
+			}
+			b.packedUint64(2, values)
+		})
+	}
+
+	// A single mapping for all functions lets pprof know they are already symbolized.
+	b.message(3, func(b *protobuf) {
+		b.uint64(1, 1)               // id
+		b.int64(5, strs.add("wasm")) // filename
+		b.uint64(7, 1)               // has_functions
+		b.uint64(8, 1)               // has_filenames
+		b.uint64(9, 1)               // has_line_numbers
+		b.uint64(10, 1)              // has_inline_frames
+	})
+
+	functionIDs := map[[2]string]uint64{}
+	var functions [][2]string
+	functionID := func(name, file string) uint64 {
+		key := [2]string{name, file}
+		id, ok := functionIDs[key]
+		if !ok {
+			functions = append(functions, key)
+			id = uint64(len(functions))
+			functionIDs[key] = id
+		}
+		return id
+	}
+
+	for i, f := range t.frames {
+		name := f.def.DebugName()
+		var lines []wasmdebug.SourceLine
+		if d, ok := f.def.(*wasm.FunctionDefinition); ok && f.sourceOffset != 0 {
+			lines = d.DWARFLines().SourceLines(f.sourceOffset)
+		}
+		if len(lines) == 0 { // Only the function is known.
+			lines = []wasmdebug.SourceLine{{}}
+		}
+		b.message(4, func(b *protobuf) {
+			b.uint64(1, uint64(i+1))    // id
+			b.uint64(2, 1)              // mapping_id
+			b.uint64(3, f.sourceOffset) // address
+			for _, l := range lines {
+				id := functionID(name, l.File)
+				b.message(4, func(b *protobuf) {
+					b.uint64(1, id)    // function_id
+					b.int64(2, l.Line) // line
+				})
+			}
+		})
+	}
+
+	for i, f := range functions {
+		b.message(5, func(b *protobuf) {
+			b.uint64(1, uint64(i+1))   // id
+			b.int64(2, strs.add(f[0])) // name
+			b.int64(3, strs.add(f[0])) // system_name
+			b.int64(4, strs.add(f[1])) // filename
+		})
+	}
+
+	b.int64(9, p.start.UnixNano())
+	b.int64(10, int64(p.duration))
+	if p.periodType != (valueType{}) {
+		b.message(11, func(b *protobuf) { p.periodType.encode(b, &strs) })
+		b.int64(12, p.period)
+	}
+
+	// The string table is encoded last, as the other fields add to it.
+	for _, s := range strs.strings {
+		b.string(6, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (vt valueType) encode(b *protobuf, strs *stringTable) {
+	b.int64(1, strs.add(vt.typ))
+	b.int64(2, strs.add(vt.unit))
+}
+
+// stringTable is the table of strings of a pprof profile, which are referenced by their index.
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func (t *stringTable) add(s string) int64 {
+	i, ok := t.index[s]
+	if !ok {
+		i = int64(len(t.strings))
+		t.strings = append(t.strings, s)
+		t.index[s] = i
+	}
+	return i
+}
+
+// protobuf encodes the subset of the protocol buffer wire format used by pprof profiles, where fields with zero values
+// are omitted.
+type protobuf struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protobuf) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	if x != 0 {
+		b.key(field, wireVarint)
+		b.varint(x)
+	}
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+// string encodes s even when empty, as it is used for the string table where the index matters.
+func (b *protobuf) string(field int, s string) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+func (b *protobuf) packedUint64(field int, xs []uint64) {
+	if len(xs) == 0 {
+		return
+	}
+	b.message(field, func(b *protobuf) {
+		for _, x := range xs {
+			b.varint(x)
+		}
+	})
+}
+
+// message encodes the embedded message written by encode.
+func (b *protobuf) message(field int, encode func(b *protobuf)) {
+	var m protobuf
+	encode(&m)
+	b.key(field, wireBytes)
+	b.varint(uint64(len(m.buf)))
+	b.buf = append(b.buf, m.buf...)
+}
@@ -0,0 +1,184 @@
+package profiling
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// decodedProfile is the subset of a pprof profile needed by tests, where the call stacks of the samples are formatted
+// as the names of their functions and lines, beginning with the innermost one, separated by ';'.
+type decodedProfile struct {
+	sampleTypes []valueType
+	samples     map[string][]int64
+}
+
+// decodeProfile decodes a profile written by profile.write.
+func decodeProfile(t *testing.T, b []byte) *decodedProfile {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	b, err = io.ReadAll(zr)
+	require.NoError(t, err)
+
+	var strs []string
+	var sampleTypes [][]uint64
+	var samples [][2][]uint64
+	locations := map[uint64][][2]uint64{} // id -> lines of function id and line
+	functions := map[uint64]uint64{}      // id -> name
+	forEachField(t, b, func(field int, v uint64, m []byte) {
+		switch field {
+		case 1:
+			sampleTypes = append(sampleTypes, fieldValues(t, m, 1, 2))
+		case 2:
+			var s [2][]uint64
+			forEachField(t, m, func(field int, _ uint64, m []byte) {
+				s[field-1] = packed(t, m)
+			})
+			samples = append(samples, s)
+		case 4:
+			var id uint64
+			var lines [][2]uint64
+			forEachField(t, m, func(field int, v uint64, m []byte) {
+				switch field {
+				case 1:
+					id = v
+				case 4:
+					l := fieldValues(t, m, 1, 2)
+					lines = append(lines, [2]uint64{l[0], l[1]})
+				}
+			})
+			locations[id] = lines
+		case 5:
+			f := fieldValues(t, m, 1, 2)
+			functions[f[0]] = f[1]
+		case 6:
+			strs = append(strs, string(m))
+		}
+	})
+
+	p := &decodedProfile{samples: map[string][]int64{}}
+	for _, vt := range sampleTypes {
+		p.sampleTypes = append(p.sampleTypes, valueType{strs[vt[0]], strs[vt[1]]})
+	}
+	for _, s := range samples {
+		var stack []string
+		for _, id := range s[0] {
+			for _, l := range locations[id] {
+				name := strs[functions[l[0]]]
+				if l[1] != 0 {
+					name += ":" + strconv.FormatUint(l[1], 10)
+				}
+				stack = append(stack, name)
+			}
+		}
+		values := make([]int64, len(s[1]))
+		for i, v := range s[1] {
+			values[i] = int64(v)
+		}
+		p.samples[strings.Join(stack, ";")] = values
+	}
+	return p
+}
+
+// forEachField calls fn with each field of the protocol buffer message b, with v set for varints and m for bytes.
+func forEachField(t *testing.T, b []byte, fn func(field int, v uint64, m []byte)) {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		v, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		switch key & 7 {
+		case wireVarint:
+			fn(int(key>>3), v, nil)
+		case wireBytes:
+			fn(int(key>>3), 0, b[:v])
+			b = b[v:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+}
+
+// fieldValues returns the values of the given varint fields of m, which are zero when omitted.
+func fieldValues(t *testing.T, m []byte, fields ...int) []uint64 {
+	ret := make([]uint64, len(fields))
+	forEachField(t, m, func(field int, v uint64, _ []byte) {
+		for i, f := range fields {
+			if f == field {
+				ret[i] = v
+			}
+		}
+	})
+	return ret
+}
+
+// packed decodes packed varints.
+func packed(t *testing.T, m []byte) (ret []uint64) {
+	for len(m) > 0 {
+		v, n := binary.Uvarint(m)
+		require.True(t, n > 0)
+		ret = append(ret, v)
+		m = m[n:]
+	}
+	return
+}
+
+func TestProtobuf(t *testing.T) {
+	var b protobuf
+	b.uint64(1, 0) // omitted
+	b.uint64(1, 300)
+	b.string(2, "")
+	b.packedUint64(3, []uint64{1, 128})
+	b.message(4, func(b *protobuf) { b.int64(1, -1) })
+	require.Equal(t, []byte{
+		1<<3 | wireVarint, 0xac, 0x02,
+		2<<3 | wireBytes, 0,
+		3<<3 | wireBytes, 3, 1, 0x80, 0x01,
+		4<<3 | wireBytes, 11, 1<<3 | wireVarint, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01,
+	}, b.buf)
+}
+
+func TestProfile_write(t *testing.T) {
+	mod, err := binaryformat.DecodeModule(dwarftestdata.ZigWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+	mod.BuildFunctionDefinitions()
+	var panicDef, mainDef api.FunctionDefinition
+	for _, d := range mod.FunctionDefinitionSection {
+		switch d.DebugName() {
+		case ".builtin.default_panic":
+			panicDef = d
+		case ".main.main":
+			mainDef = d
+		}
+	}
+
+	table := newStackTable()
+	// The offset in main.main is the call of builtin.default_panic, which is inlined twice.
+	stack := []frame{{def: panicDef}, {def: mainDef, sourceOffset: 0xa6 - 0x46}}
+	table.add(stack, 1, 10)
+	table.add(stack, 2, 20)
+	table.add(stack[1:], 1, 5)
+
+	var buf bytes.Buffer
+	p := &profile{sampleTypes: []valueType{{"calls", "count"}, {"time", "nanoseconds"}}, scale: 2}
+	require.NoError(t, p.write(&buf, table))
+
+	prof := decodeProfile(t, buf.Bytes())
+	require.Equal(t, p.sampleTypes, prof.sampleTypes)
+	require.Equal(t, map[string][]int64{
+		".builtin.default_panic;.main.main:10;.main.main:6;.main.main:2": {6, 60},
+		".main.main:10;.main.main:6;.main.main:2":                        {2, 10},
+	}, prof.samples)
+}
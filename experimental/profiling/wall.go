@@ -0,0 +1,158 @@
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// WallProfiler is an experimental.FunctionListenerFactory which records the wall time spent in functions, by the call
+// stacks they were called from, and writes it as a pprof profile for `go tool pprof`.
+//
+// Every call is timed by the listeners, rather than sampled. The time of a call is the elapsed time between its start
+// and its end, minus the time of the calls it made, so it includes the time the call was blocked, such as in host
+// functions sleeping or polling. The samples are "wall" nanoseconds, not "cpu" ones, to make this clear.
+//
+// Here's an example of profiling the calls of a module:
+//
+//	p := profiling.NewWallProfiler(1)
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, p)
+//	mod, _ := r.InstantiateModule(ctx, compiled, config.WithStartFunctions())
+//
+//	p.StartProfile()
+//	_, _ = mod.ExportedFunction("run").Call(ctx)
+//	_ = p.StopProfile(w)
+//
+// Note: The listeners are only notified of the functions compiled with the context.Context holding the WallProfiler.
+// Calls that fail aren't recorded, as FunctionListener.After isn't notified for them.
+type WallProfiler struct {
+	sampleRate float64
+
+	// running is 1 between StartProfile and StopProfile. This allows skipping the calls when not profiling without
+	// locking mux.
+	running uint32
+
+	mux   sync.Mutex
+	start time.Time
+	// table is the table of the current profile, or nil if not profiling.
+	table *stackTable
+}
+
+// NewWallProfiler returns a WallProfiler which records the call stacks of the given fraction of the calls, greater
+// than 0 and at most 1. Greater values are the same as 1.
+//
+// The time of the other calls is still measured, so that it isn't attributed to their callers, but the cost of
+// recording their call stacks is saved. The values in the profile are scaled accordingly.
+//
+// This panics if sampleRate isn't greater than 0, as no call would be recorded.
+func NewWallProfiler(sampleRate float64) *WallProfiler {
+	if !(sampleRate > 0) { // also true for NaN
+		panic(fmt.Errorf("sampleRate invalid: %v <= 0", sampleRate))
+	} else if sampleRate > 1 {
+		sampleRate = 1
+	}
+	return &WallProfiler{sampleRate: sampleRate}
+}
+
+// StartProfile starts recording the calls, discarding the ones previously recorded.
+func (p *WallProfiler) StartProfile() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.start = time.Now()
+	p.table = newStackTable()
+	atomic.StoreUint32(&p.running, 1)
+}
+
+// StopProfile stops recording the calls, and writes the profile of the ones recorded since StartProfile to w.
+//
+// The profile is a gzip compressed protocol buffer, as described in https://github.com/google/pprof/tree/main/proto
+func (p *WallProfiler) StopProfile(w io.Writer) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	atomic.StoreUint32(&p.running, 0)
+	table := p.table
+	p.table = nil
+	if table == nil {
+		table = newStackTable() // not started
+	}
+	prof := &profile{
+		sampleTypes: []valueType{{"samples", "count"}, {"wall", "nanoseconds"}},
+		scale:       1 / p.sampleRate,
+		start:       p.start,
+		duration:    time.Since(p.start),
+		periodType:  valueType{"wall", "nanoseconds"},
+		period:      1,
+	}
+	return prof.write(w, table)
+}
+
+// NewListener implements experimental.FunctionListenerFactory.NewListener
+func (p *WallProfiler) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return p
+}
+
+// wallCallKey is a context.Context Value key. Its associated value is the *wallCall of the current call.
+type wallCallKey struct{}
+
+// wallCall measures the time of a call.
+type wallCall struct {
+	parent   *wallCall
+	start    time.Time
+	children time.Duration
+	// stack is the call stack, or nil if the call isn't sampled.
+	stack []frame
+	// table is the table of the profile the call started in, which it is only recorded in.
+	table *stackTable
+}
+
+// Before implements experimental.FunctionListener.Before
+func (p *WallProfiler) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, stackIterator experimental.StackIterator) context.Context {
+	parent, _ := ctx.Value(wallCallKey{}).(*wallCall)
+	if atomic.LoadUint32(&p.running) == 0 {
+		if parent != nil { // Hide the parent from After, as this call isn't measured.
+			return context.WithValue(ctx, wallCallKey{}, (*wallCall)(nil))
+		}
+		return ctx
+	}
+
+	c := &wallCall{parent: parent}
+	if p.sampleRate >= 1 || rand.Float64() < p.sampleRate {
+		c.stack = appendStack(nil, stackIterator)
+		p.mux.Lock()
+		c.table = p.table
+		p.mux.Unlock()
+	}
+	c.start = time.Now()
+	return context.WithValue(ctx, wallCallKey{}, c)
+}
+
+// After implements experimental.FunctionListener.After
+func (p *WallProfiler) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error, _ []uint64) {
+	c, _ := ctx.Value(wallCallKey{}).(*wallCall)
+	if c == nil {
+		return
+	}
+	elapsed := time.Since(c.start)
+	if c.parent != nil {
+		c.parent.children += elapsed
+	}
+	if c.table == nil {
+		return
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	// The call isn't recorded if the profile it started in was stopped, even if another one was started since.
+	if c.table == p.table {
+		p.table.add(c.stack, 1, int64(elapsed-c.children))
+	}
+}
@@ -0,0 +1,146 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// wallWat calls "env" "sleep" twice from "$work", and once from "$main".
+const wallWat = `(module $test
+  (import "env" "sleep" (func $sleep))
+  (func $work (call $sleep) (call $sleep))
+  (func $main (export "main") (call $work) (call $sleep)))`
+
+func runtimeConfigs() map[string]wazero.RuntimeConfig {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	return configs
+}
+
+func TestWallProfiler(t *testing.T) {
+	for name, config := range runtimeConfigs() {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			p := NewWallProfiler(1)
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx)
+
+			const sleep = 10 * time.Millisecond
+			_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+				WithFunc(func() { time.Sleep(sleep) }).Export("sleep").Instantiate(ctx)
+			require.NoError(t, err)
+			mod, err := r.InstantiateModuleFromBinary(ctx, []byte(wallWat))
+			require.NoError(t, err)
+			main := mod.ExportedFunction("main")
+
+			// Calls before the profile starts aren't recorded.
+			_, err = main.Call(ctx)
+			require.NoError(t, err)
+
+			p.StartProfile()
+			_, err = main.Call(ctx)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, p.StopProfile(&buf))
+
+			prof := decodeProfile(t, buf.Bytes())
+			require.Equal(t, []valueType{{"samples", "count"}, {"wall", "nanoseconds"}}, prof.sampleTypes)
+			require.Equal(t, 4, len(prof.samples))
+
+			// The time of the calls of $sleep isn't attributed to their callers.
+			for stack, calls := range map[string]int64{
+				"env.sleep;test.work;test.main": 2,
+				"env.sleep;test.main":           1,
+			} {
+				values := prof.samples[stack]
+				require.Equal(t, calls, values[0], stack)
+				require.True(t, values[1] >= calls*int64(sleep), stack)
+			}
+			for _, stack := range []string{"test.work;test.main", "test.main"} {
+				values := prof.samples[stack]
+				require.Equal(t, int64(1), values[0], stack)
+				require.True(t, values[1] < int64(sleep), stack)
+			}
+		})
+	}
+}
+
+func TestWallProfiler_sampleRate(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		sampleRate float64
+		samples    int
+	}{
+		{name: "all", sampleRate: 1, samples: 2},
+		{name: "more than all", sampleRate: 2, samples: 2}, // more than 1 is all
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewWallProfiler(tc.sampleRate)
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+			r := wazero.NewRuntime(ctx)
+			defer r.Close(ctx)
+
+			mod, err := r.InstantiateModuleFromBinary(ctx, []byte(`(module $test
+  (func $leaf)
+  (func (export "main") (call $leaf)))`))
+			require.NoError(t, err)
+
+			p.StartProfile()
+			_, err = mod.ExportedFunction("main").Call(ctx)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, p.StopProfile(&buf))
+			require.Equal(t, tc.samples, len(decodeProfile(t, buf.Bytes()).samples))
+		})
+	}
+}
+
+func TestNewWallProfiler_invalidSampleRate(t *testing.T) {
+	for _, sampleRate := range []float64{0, -1, math.NaN()} {
+		sampleRate := sampleRate
+		t.Run(fmt.Sprint(sampleRate), func(t *testing.T) {
+			err := require.CapturePanic(func() { NewWallProfiler(sampleRate) })
+			require.EqualError(t, err, fmt.Sprintf("sampleRate invalid: %v <= 0", sampleRate))
+		})
+	}
+}
+
+func TestWallProfiler_restart(t *testing.T) {
+	p := NewWallProfiler(1)
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	// The profile is restarted during the calls of $sleep.
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func() {
+			require.NoError(t, p.StopProfile(io.Discard))
+			p.StartProfile()
+		}).Export("sleep").Instantiate(ctx)
+	require.NoError(t, err)
+	mod, err := r.InstantiateModuleFromBinary(ctx, []byte(wallWat))
+	require.NoError(t, err)
+
+	p.StartProfile()
+	_, err = mod.ExportedFunction("main").Call(ctx)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, p.StopProfile(&buf))
+
+	// All the calls started before the last restart, which the last call of $sleep made, so none is recorded.
+	require.Equal(t, 0, len(decodeProfile(t, buf.Bytes()).samples))
+}
@@ -0,0 +1,12 @@
+package experimental
+
+// RelaxedSIMDNativeKey is a context.Context Value key. Its associated value should be a bool, which is true to let the
+// compiler lower the instructions of api.CoreFeatureRelaxedSIMD to the native ones of the CPU where it can, instead of
+// the deterministic ones. This is read when the runtime is created, e.g. by wazero.NewRuntimeWithConfig.
+//
+// The results can differ between CPUs for the inputs which the proposal leaves implementation-defined, e.g. the
+// rounding of `f32x4.relaxed_madd` or `i16x8.relaxed_dot_i8x16_i7x16_s` of lanes which don't fit in 7 bits. The
+// interpreter always uses the deterministic semantics.
+//
+// See https://github.com/WebAssembly/relaxed-simd/blob/main/proposals/relaxed-simd/Overview.md
+type RelaxedSIMDNativeKey struct{}
@@ -0,0 +1,242 @@
+// Package tracing includes tracers of the calls of the functions of modules, which write timelines of them in the
+// trace event format of Chrome, for Perfetto or chrome://tracing.
+//
+// See https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Tracer is an experimental.FunctionListenerFactory which writes the calls of functions as begin and end events of the
+// trace event format of Chrome, which can be opened with https://ui.perfetto.dev or chrome://tracing.
+//
+// Each event has the time it occurred at, the debug name of the function, whether it is defined by the host or the
+// guest as its category, and the names of the module and of the function as its arguments.
+//
+// The calls made from each call of an exported function by the host are on the same thread of the timeline. The
+// threads are reused by the next calls, unless the calls are concurrent.
+//
+// Here's an example of tracing the calls of a module:
+//
+//	t := tracing.NewTracer(w)
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, t)
+//	mod, _ := r.InstantiateModule(ctx, compiled, config)
+//	_ = t.Close()
+//
+// Note: The listeners are only notified of the functions compiled with the context.Context holding the Tracer. As
+// FunctionListener.After isn't notified for the calls which fail, such as the ones unwound by a trap or by proc_exit,
+// these end at the time of the last event of their thread, when their caller returns or the Tracer is closed.
+type Tracer struct {
+	start time.Time
+
+	mux     sync.Mutex
+	w       *bufio.Writer
+	err     error
+	written bool
+	threads []*traceThread
+	free    []*traceThread
+}
+
+// traceThread is a thread of the timeline, which holds the calls made from a call of the host.
+type traceThread struct {
+	id uint32
+	// calls are the debug names of the calls which haven't ended, beginning with the outermost one.
+	calls []string
+	// last is the time of the last event.
+	last time.Duration
+}
+
+// traceEvent is an event of the trace event format.
+type traceEvent struct {
+	Name string      `json:"name"`
+	Cat  string      `json:"cat,omitempty"`
+	Ph   string      `json:"ph"`
+	Ts   float64     `json:"ts"`
+	Pid  int         `json:"pid"`
+	Tid  uint32      `json:"tid"`
+	Args interface{} `json:"args,omitempty"`
+}
+
+// callArgs are the arguments of the begin event of a call.
+type callArgs struct {
+	Module   string `json:"module"`
+	Function string `json:"function,omitempty"`
+}
+
+// unfinishedArgs are the arguments of the end event of a call which failed.
+type unfinishedArgs struct {
+	Unfinished bool `json:"unfinished"`
+}
+
+// NewTracer returns a Tracer which writes the events to w, until it is closed.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{start: time.Now(), w: bufio.NewWriter(w)}
+}
+
+// Close ends the calls which haven't ended, terminates the events written to the writer and flushes them. The calls
+// after Close aren't written.
+//
+// This returns the first error of writing the events, if any.
+func (t *Tracer) Close() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.w == nil {
+		return t.err
+	}
+	for _, thread := range t.threads {
+		t.endUnfinished(thread, 0)
+	}
+	if !t.written {
+		t.write("[")
+	}
+	t.write("\n]\n")
+	if err := t.w.Flush(); t.err == nil {
+		t.err = err
+	}
+	t.w = nil
+	return t.err
+}
+
+// NewListener implements experimental.FunctionListenerFactory.NewListener
+func (t *Tracer) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return t
+}
+
+// traceCallKey is a context.Context Value key. Its associated value is the *traceCall of the current call.
+type traceCallKey struct{}
+
+// traceCall is a call on a thread of the timeline.
+type traceCall struct {
+	thread *traceThread
+	// depth is the number of calls the call was made from.
+	depth int
+}
+
+// Before implements experimental.FunctionListener.Before
+func (t *Tracer) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	parent, _ := ctx.Value(traceCallKey{}).(*traceCall)
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.w == nil {
+		return ctx
+	}
+	var thread *traceThread
+	if parent != nil {
+		thread = parent.thread
+		t.endUnfinished(thread, parent.depth+1)
+	} else {
+		thread = t.newThread()
+	}
+
+	name := def.DebugName()
+	cat := "guest"
+	if def.GoFunction() != nil {
+		cat = "host"
+	}
+	thread.last = time.Since(t.start)
+	t.writeEvent(&traceEvent{
+		Name: name, Cat: cat, Ph: "B", Ts: micros(thread.last), Tid: thread.id,
+		Args: &callArgs{Module: def.ModuleName(), Function: def.Name()},
+	})
+	c := &traceCall{thread: thread, depth: len(thread.calls)}
+	thread.calls = append(thread.calls, name)
+	return context.WithValue(ctx, traceCallKey{}, c)
+}
+
+// After implements experimental.FunctionListener.After
+func (t *Tracer) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error, _ []uint64) {
+	c, _ := ctx.Value(traceCallKey{}).(*traceCall)
+	if c == nil {
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.w == nil {
+		return
+	}
+	thread := c.thread
+	t.endUnfinished(thread, c.depth+1)
+	thread.last = time.Since(t.start)
+	t.endCall(thread, nil)
+	if len(thread.calls) == 0 {
+		t.free = append(t.free, thread)
+	}
+}
+
+// newThread returns a thread without calls, reusing the one with the lowest id.
+func (t *Tracer) newThread() *traceThread {
+	if len(t.free) == 0 {
+		thread := &traceThread{id: uint32(len(t.threads)) + 1}
+		t.threads = append(t.threads, thread)
+		return thread
+	}
+	lowest := 0
+	for i, thread := range t.free {
+		if thread.id < t.free[lowest].id {
+			lowest = i
+		}
+	}
+	thread := t.free[lowest]
+	t.free = append(t.free[:lowest], t.free[lowest+1:]...)
+	return thread
+}
+
+// endUnfinished ends the calls of thread made from depth calls, which failed, at the time of its last event.
+func (t *Tracer) endUnfinished(thread *traceThread, depth int) {
+	for len(thread.calls) > depth {
+		t.endCall(thread, &unfinishedArgs{Unfinished: true})
+	}
+}
+
+// endCall ends the innermost call of thread at the time of its last event.
+func (t *Tracer) endCall(thread *traceThread, args interface{}) {
+	last := len(thread.calls) - 1
+	t.writeEvent(&traceEvent{Name: thread.calls[last], Ph: "E", Ts: micros(thread.last), Tid: thread.id, Args: args})
+	thread.calls = thread.calls[:last]
+}
+
+// writeEvent writes the event as an element of the JSON array of events.
+func (t *Tracer) writeEvent(e *traceEvent) {
+	e.Pid = 1
+	b, err := json.Marshal(e)
+	if err != nil {
+		if t.err == nil {
+			t.err = err
+		}
+		return
+	}
+	if t.written {
+		t.write(",\n")
+	} else {
+		t.write("[\n")
+		t.written = true
+	}
+	t.write(string(b))
+}
+
+// write writes s, unless writing failed before.
+func (t *Tracer) write(s string) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = t.w.WriteString(s)
+}
+
+// micros returns d in microseconds, which is the unit of the timestamps of the events.
+func micros(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// tracerWat calls "env" "host" from "$work", which calls back "$fail" when its parameter is 1, and recovers from its
+// trap.
+const tracerWat = `(module $test
+  (import "env" "host" (func $host (param i32)))
+  (func $work (param i32) (call $host (local.get 0)))
+  (func $main (export "main") (param i32) (call $work (local.get 0)))
+  (func $fail (export "fail") unreachable))`
+
+func runtimeConfigs() map[string]wazero.RuntimeConfig {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	return configs
+}
+
+// event is an event decoded from the trace, without its timestamp.
+type event struct {
+	Name string
+	Cat  string
+	Ph   string
+	Tid  uint32
+	Args map[string]interface{}
+}
+
+// decodeTrace decodes the events of the trace, and checks their timestamps don't decrease.
+func decodeTrace(t *testing.T, b []byte) []event {
+	var raw []struct {
+		event
+		Ts  float64
+		Pid int
+	}
+	require.NoError(t, json.Unmarshal(b, &raw))
+	events := make([]event, len(raw))
+	for i, e := range raw {
+		if i > 0 {
+			require.True(t, e.Ts >= raw[i-1].Ts, i)
+		}
+		require.Equal(t, 1, e.Pid)
+		events[i] = e.event
+	}
+	return events
+}
+
+func begin(name, cat string, tid uint32, function string) event {
+	args := map[string]interface{}{"module": "test"}
+	if cat == "host" {
+		args["module"] = "env"
+	}
+	if function != "" {
+		args["function"] = function
+	}
+	return event{Name: name, Cat: cat, Ph: "B", Tid: tid, Args: args}
+}
+
+func end(name string, tid uint32) event {
+	return event{Name: name, Ph: "E", Tid: tid}
+}
+
+func unfinished(name string, tid uint32) event {
+	return event{Name: name, Ph: "E", Tid: tid, Args: map[string]interface{}{"unfinished": true}}
+}
+
+func TestTracer(t *testing.T) {
+	for name, config := range runtimeConfigs() {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tracer := NewTracer(&buf)
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, tracer)
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx)
+
+			_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, mod api.Module, fail uint32) {
+					if fail == 1 {
+						_, err := mod.ExportedFunction("fail").Call(ctx)
+						require.Error(t, err)
+					}
+				}).Export("host").Instantiate(ctx)
+			require.NoError(t, err)
+			mod, err := r.InstantiateModuleFromBinary(ctx, []byte(tracerWat))
+			require.NoError(t, err)
+			main := mod.ExportedFunction("main")
+
+			_, err = main.Call(ctx, 0)
+			require.NoError(t, err)
+			_, err = main.Call(ctx, 1)
+			require.NoError(t, err)
+			_, err = mod.ExportedFunction("fail").Call(ctx)
+			require.Error(t, err)
+
+			require.NoError(t, tracer.Close())
+			require.Equal(t, []event{
+				begin("test.main", "guest", 1, "main"),
+				begin("test.work", "guest", 1, "work"),
+				begin("env.host", "host", 1, "host"),
+				end("env.host", 1),
+				end("test.work", 1),
+				end("test.main", 1),
+				// The thread is reused by the next call, and the call of $fail which trapped ends when its caller
+				// returns.
+				begin("test.main", "guest", 1, "main"),
+				begin("test.work", "guest", 1, "work"),
+				begin("env.host", "host", 1, "host"),
+				begin("test.fail", "guest", 1, "fail"),
+				unfinished("test.fail", 1),
+				end("env.host", 1),
+				end("test.work", 1),
+				end("test.main", 1),
+				// The call which trapped ends when the tracer is closed.
+				begin("test.fail", "guest", 1, "fail"),
+				unfinished("test.fail", 1),
+			}, decodeTrace(t, buf.Bytes()))
+		})
+	}
+}
+
+func TestTracer_concurrentCalls(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(&buf)
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, tracer)
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	// The host function calls main again, as if it were called concurrently, without the context of the first call.
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, again uint32) {
+			if again == 1 {
+				_, err := mod.ExportedFunction("main").Call(context.Background(), 0)
+				require.NoError(t, err)
+			}
+		}).Export("host").Instantiate(ctx)
+	require.NoError(t, err)
+	mod, err := r.InstantiateModuleFromBinary(ctx, []byte(tracerWat))
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("main").Call(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, tracer.Close())
+
+	// The listeners of the calls are the ones of the functions compiled with the tracer, regardless of the context of
+	// the calls, so the calls of the second main are on their own thread.
+	require.Equal(t, []event{
+		begin("test.main", "guest", 1, "main"),
+		begin("test.work", "guest", 1, "work"),
+		begin("env.host", "host", 1, "host"),
+		begin("test.main", "guest", 2, "main"),
+		begin("test.work", "guest", 2, "work"),
+		begin("env.host", "host", 2, "host"),
+		end("env.host", 2),
+		end("test.work", 2),
+		end("test.main", 2),
+		end("env.host", 1),
+		end("test.work", 1),
+		end("test.main", 1),
+	}, decodeTrace(t, buf.Bytes()))
+}
+
+func TestTracer_Close(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(&buf)
+	require.NoError(t, tracer.Close())
+	require.Equal(t, "[\n]\n", buf.String())
+	require.Equal(t, 0, len(decodeTrace(t, buf.Bytes())))
+
+	// Closing again does nothing.
+	require.NoError(t, tracer.Close())
+	require.Equal(t, "[\n]\n", buf.String())
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("error writing") }
+
+func TestTracer_Close_error(t *testing.T) {
+	tracer := NewTracer(errWriter{})
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, tracer)
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, []byte(`(module $test (func (export "f")))`))
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("f").Call(ctx)
+	require.NoError(t, err)
+
+	err = tracer.Close()
+	require.EqualError(t, err, "error writing")
+	require.EqualError(t, tracer.Close(), fmt.Sprint(err))
+}
@@ -0,0 +1,51 @@
+package wazero
+
+import (
+	"context"
+	"math"
+
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// ErrFuelExhausted is the error, possibly wrapped, of a call which ran out of
+// the fuel given by WithFuel. See RuntimeConfig.WithFuelMetering.
+var ErrFuelExhausted error = wasmruntime.ErrRuntimeFuelExhausted
+
+// Fuel is the budget of the calls made with a context.Context returned by
+// WithFuel, and reports the fuel remaining and consumed after they return.
+//
+// Note: The calls update Fuel without synchronization, so it must not be
+// shared by concurrent calls or read until they return.
+type Fuel struct {
+	budget, remaining int64
+}
+
+// WithFuel returns a context.Context which limits the calls made with it to
+// the given amount of fuel, where each WebAssembly instruction consumes one.
+// This only has an effect when RuntimeConfig.WithFuelMetering is enabled.
+//
+// All the calls made with the returned context.Context share the fuel,
+// including the ones nested in host functions. A fuel larger than
+// math.MaxInt64 is reduced to it.
+func WithFuel(ctx context.Context, fuel uint64) (context.Context, *Fuel) {
+	if fuel > math.MaxInt64 {
+		fuel = math.MaxInt64
+	}
+	f := &Fuel{budget: int64(fuel), remaining: int64(fuel)}
+	return context.WithValue(ctx, wasm.FuelKey{}, &f.remaining), f
+}
+
+// Remaining returns the fuel left for further calls, which is zero once a
+// call failed with ErrFuelExhausted.
+func (f *Fuel) Remaining() uint64 {
+	if f.remaining < 0 {
+		return 0
+	}
+	return uint64(f.remaining)
+}
+
+// Consumed returns the fuel consumed by the calls so far.
+func (f *Fuel) Consumed() uint64 {
+	return uint64(f.budget) - f.Remaining()
+}
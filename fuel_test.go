@@ -0,0 +1,56 @@
+package wazero
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestWithFuel(t *testing.T) {
+	ctx, fuel := WithFuel(testCtx, 100)
+	remaining := ctx.Value(wasm.FuelKey{}).(*int64)
+	require.Equal(t, uint64(100), fuel.Remaining())
+	require.Equal(t, uint64(0), fuel.Consumed())
+
+	*remaining = 30
+	require.Equal(t, uint64(30), fuel.Remaining())
+	require.Equal(t, uint64(70), fuel.Consumed())
+
+	// The remaining fuel becomes negative when a basic block can't be afforded.
+	*remaining = -5
+	require.Equal(t, uint64(0), fuel.Remaining())
+	require.Equal(t, uint64(100), fuel.Consumed())
+
+	_, fuel = WithFuel(testCtx, math.MaxUint64)
+	require.Equal(t, uint64(math.MaxInt64), fuel.Remaining())
+}
+
+func TestRuntimeConfig_WithFuelMetering(t *testing.T) {
+	const loopWat = `(module (func (export "loop") (param i32)
+  (loop $loop (br_if $loop (local.tee 0 (i32.sub (local.get 0) (i32.const 1)))))))`
+
+	// Share the compilation cache to ensure the code compiled with fuel metering isn't reused without it.
+	cache := NewCompilationCache()
+	defer cache.Close(testCtx)
+
+	for _, fuelMetering := range []bool{true, false} {
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithCompilationCache(cache).WithFuelMetering(fuelMetering))
+		defer r.Close(testCtx)
+
+		mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(loopWat))
+		require.NoError(t, err)
+
+		ctx, fuel := WithFuel(testCtx, 100)
+		_, err = mod.ExportedFunction("loop").Call(ctx, 1000)
+		if fuelMetering {
+			require.True(t, errors.Is(err, ErrFuelExhausted), err)
+			require.Equal(t, uint64(100), fuel.Consumed())
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, uint64(0), fuel.Consumed())
+		}
+	}
+}
@@ -16,6 +16,11 @@ type Assembler interface {
 	// are `from` and `to` registers.
 	CompileRegisterToRegisterWithArg(instruction asm.Instruction, from, to asm.Register, arg byte)
 
+	// CompileTwoRegistersToRegister adds an instruction where source operands are `src1` and `src2` registers,
+	// and the destination is the `dst` register, which is also an operand of FMA instructions. For example,
+	// VFMADD231PS computes dst = src1*src2 + dst.
+	CompileTwoRegistersToRegister(instruction asm.Instruction, src1, src2, dst asm.Register)
+
 	// CompileMemoryWithIndexToRegister adds an instruction where source operand is the memory address
 	// specified as `srcBaseReg + srcOffsetConst + srcIndex*srcScale` and destination is the register `dstReg`.
 	// Note: sourceScale must be one of 1, 2, 4, 8.
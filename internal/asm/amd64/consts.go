@@ -567,6 +567,14 @@ const (
 	PMADDUBSW
 	// CVTTPD2DQ is the CVTTPD2DQ instruction https://www.felixcloutier.com/x86/cvttpd2dq
 	CVTTPD2DQ
+	// VFMADD231PS is the VFMADD231PS instruction https://www.felixcloutier.com/x86/vfmadd132ps:vfmadd213ps:vfmadd231ps
+	VFMADD231PS
+	// VFMADD231PD is the VFMADD231PD instruction https://www.felixcloutier.com/x86/vfmadd132pd:vfmadd213pd:vfmadd231pd
+	VFMADD231PD
+	// VFNMADD231PS is the VFNMADD231PS instruction https://www.felixcloutier.com/x86/vfnmadd132ps:vfnmadd213ps:vfnmadd231ps
+	VFNMADD231PS
+	// VFNMADD231PD is the VFNMADD231PD instruction https://www.felixcloutier.com/x86/vfnmadd132pd:vfnmadd213pd:vfnmadd231pd
+	VFNMADD231PD
 
 	// instructionEnd is always placed at the bottom of this iota definition to be used in the test.
 	instructionEnd
@@ -1099,6 +1107,14 @@ func InstructionName(instruction asm.Instruction) string {
 		return "PMADDUBSW"
 	case CVTTPD2DQ:
 		return "CVTTPD2DQ"
+	case VFMADD231PS:
+		return "VFMADD231PS"
+	case VFMADD231PD:
+		return "VFMADD231PD"
+	case VFNMADD231PS:
+		return "VFNMADD231PS"
+	case VFNMADD231PD:
+		return "VFNMADD231PD"
 	}
 	panic(fmt.Errorf("unknown instruction %d", instruction))
 }
@@ -27,6 +27,8 @@ type nodeImpl struct {
 	srcConst, dstConst       asm.ConstantValue
 	srcMemIndex, dstMemIndex asm.Register
 	srcMemScale, dstMemScale byte
+	// srcReg2 is the second source register of operandTypesTwoRegistersToRegister.
+	srcReg2 asm.Register
 
 	arg byte
 
@@ -118,6 +120,8 @@ func (n *nodeImpl) String() (ret string) {
 		ret = fmt.Sprintf("%s %s", instName, RegisterName(n.srcReg))
 	case operandTypesRegisterToRegister:
 		ret = fmt.Sprintf("%s %s, %s", instName, RegisterName(n.srcReg), RegisterName(n.dstReg))
+	case operandTypesTwoRegistersToRegister:
+		ret = fmt.Sprintf("%s %s, %s, %s", instName, RegisterName(n.srcReg), RegisterName(n.srcReg2), RegisterName(n.dstReg))
 	case operandTypesRegisterToMemory:
 		if n.dstMemIndex != asm.NilRegister {
 			ret = fmt.Sprintf("%s %s, [%s + 0x%x + %s*0x%x]", instName, RegisterName(n.srcReg),
@@ -169,6 +173,7 @@ const (
 	operandTypeConst
 	operandTypeStaticConst
 	operandTypeBranch
+	operandTypeTwoRegisters
 )
 
 func (o operandType) String() (ret string) {
@@ -185,6 +190,8 @@ func (o operandType) String() (ret string) {
 		ret = "branch"
 	case operandTypeStaticConst:
 		ret = "static-const"
+	case operandTypeTwoRegisters:
+		ret = "two-registers"
 	}
 	return
 }
@@ -193,20 +200,21 @@ func (o operandType) String() (ret string) {
 type operandTypes struct{ src, dst operandType }
 
 var (
-	operandTypesNoneToNone            = operandTypes{operandTypeNone, operandTypeNone}
-	operandTypesNoneToRegister        = operandTypes{operandTypeNone, operandTypeRegister}
-	operandTypesNoneToMemory          = operandTypes{operandTypeNone, operandTypeMemory}
-	operandTypesNoneToBranch          = operandTypes{operandTypeNone, operandTypeBranch}
-	operandTypesRegisterToNone        = operandTypes{operandTypeRegister, operandTypeNone}
-	operandTypesRegisterToRegister    = operandTypes{operandTypeRegister, operandTypeRegister}
-	operandTypesRegisterToMemory      = operandTypes{operandTypeRegister, operandTypeMemory}
-	operandTypesRegisterToConst       = operandTypes{operandTypeRegister, operandTypeConst}
-	operandTypesMemoryToRegister      = operandTypes{operandTypeMemory, operandTypeRegister}
-	operandTypesMemoryToConst         = operandTypes{operandTypeMemory, operandTypeConst}
-	operandTypesConstToRegister       = operandTypes{operandTypeConst, operandTypeRegister}
-	operandTypesConstToMemory         = operandTypes{operandTypeConst, operandTypeMemory}
-	operandTypesStaticConstToRegister = operandTypes{operandTypeStaticConst, operandTypeRegister}
-	operandTypesRegisterToStaticConst = operandTypes{operandTypeRegister, operandTypeStaticConst}
+	operandTypesNoneToNone             = operandTypes{operandTypeNone, operandTypeNone}
+	operandTypesNoneToRegister         = operandTypes{operandTypeNone, operandTypeRegister}
+	operandTypesNoneToMemory           = operandTypes{operandTypeNone, operandTypeMemory}
+	operandTypesNoneToBranch           = operandTypes{operandTypeNone, operandTypeBranch}
+	operandTypesRegisterToNone         = operandTypes{operandTypeRegister, operandTypeNone}
+	operandTypesRegisterToRegister     = operandTypes{operandTypeRegister, operandTypeRegister}
+	operandTypesRegisterToMemory       = operandTypes{operandTypeRegister, operandTypeMemory}
+	operandTypesRegisterToConst        = operandTypes{operandTypeRegister, operandTypeConst}
+	operandTypesMemoryToRegister       = operandTypes{operandTypeMemory, operandTypeRegister}
+	operandTypesMemoryToConst          = operandTypes{operandTypeMemory, operandTypeConst}
+	operandTypesConstToRegister        = operandTypes{operandTypeConst, operandTypeRegister}
+	operandTypesConstToMemory          = operandTypes{operandTypeConst, operandTypeMemory}
+	operandTypesStaticConstToRegister  = operandTypes{operandTypeStaticConst, operandTypeRegister}
+	operandTypesRegisterToStaticConst  = operandTypes{operandTypeRegister, operandTypeStaticConst}
+	operandTypesTwoRegistersToRegister = operandTypes{operandTypeTwoRegisters, operandTypeRegister}
 )
 
 // String implements fmt.Stringer
@@ -345,6 +353,8 @@ func (a *AssemblerImpl) EncodeNode(n *nodeImpl) (err error) {
 		err = a.encodeStaticConstToRegister(n)
 	case operandTypesRegisterToStaticConst:
 		err = a.encodeRegisterToStaticConst(n)
+	case operandTypesTwoRegistersToRegister:
+		err = a.encodeTwoRegistersToRegister(n)
 	default:
 		err = fmt.Errorf("encoder undefined for [%s] operand type", n.types)
 	}
@@ -677,6 +687,14 @@ func (a *AssemblerImpl) CompileRegisterToRegisterWithArg(
 	n.arg = arg
 }
 
+// CompileTwoRegistersToRegister implements the same method as documented on amd64.Assembler.
+func (a *AssemblerImpl) CompileTwoRegistersToRegister(instruction asm.Instruction, src1, src2, dst asm.Register) {
+	n := a.newNode(instruction, operandTypesTwoRegistersToRegister)
+	n.srcReg = src1
+	n.srcReg2 = src2
+	n.dstReg = dst
+}
+
 // CompileMemoryWithIndexToRegister implements the same method as documented on amd64.Assembler.
 func (a *AssemblerImpl) CompileMemoryWithIndexToRegister(
 	instruction asm.Instruction,
@@ -1556,6 +1574,71 @@ var registerToRegisterMOVOpcodes = map[asm.Instruction]struct {
 	},
 }
 
+// twoRegistersToRegisterOpcode holds the VEX-encoded instructions of operandTypesTwoRegistersToRegister, which
+// currently are only the FMA instructions in the 0F38 opcode map with the 0x66 implied prefix.
+//
+// See https://wiki.osdev.org/X86-64_Instruction_Encoding#VEX.2FXOP_opcodes
+var twoRegistersToRegisterOpcode = map[asm.Instruction]struct {
+	opcode byte
+	// vexW is true when VEX.W is set, which selects the double precision variant of FMA instructions.
+	vexW bool
+}{
+	// https://www.felixcloutier.com/x86/vfmadd132ps:vfmadd213ps:vfmadd231ps
+	VFMADD231PS: {opcode: 0xb8},
+	// https://www.felixcloutier.com/x86/vfmadd132pd:vfmadd213pd:vfmadd231pd
+	VFMADD231PD: {opcode: 0xb8, vexW: true},
+	// https://www.felixcloutier.com/x86/vfnmadd132ps:vfnmadd213ps:vfnmadd231ps
+	VFNMADD231PS: {opcode: 0xbc},
+	// https://www.felixcloutier.com/x86/vfnmadd132pd:vfnmadd213pd:vfnmadd231pd
+	VFNMADD231PD: {opcode: 0xbc, vexW: true},
+}
+
+// encodeTwoRegistersToRegister encodes the 128-bit VEX instruction whose operands are ModRM:reg = dstReg,
+// VEX.vvvv = srcReg and ModRM:r/m = srcReg2.
+func (a *AssemblerImpl) encodeTwoRegistersToRegister(n *nodeImpl) (err error) {
+	op, ok := twoRegistersToRegisterOpcode[n.instruction]
+	if !ok {
+		return errorEncodingUnsupported(n)
+	}
+	for _, r := range []asm.Register{n.srcReg, n.srcReg2, n.dstReg} {
+		if !IsVectorRegister(r) {
+			return fmt.Errorf("%s require float registers but got %s", InstructionName(n.instruction), RegisterName(r))
+		}
+	}
+
+	reg3bits, regPrefix, err := register3bits(n.dstReg, registerSpecifierPositionModRMFieldReg)
+	if err != nil {
+		return err
+	}
+	rm3bits, rmPrefix, err := register3bits(n.srcReg2, registerSpecifierPositionModRMFieldRM)
+	if err != nil {
+		return err
+	}
+	vvvv, vvvvPrefix, err := register3bits(n.srcReg, registerSpecifierPositionModRMFieldRM)
+	if err != nil {
+		return err
+	}
+	if vvvvPrefix != RexPrefixNone {
+		vvvv |= 0b1000
+	}
+
+	// The R, X and B bits of the three-byte VEX prefix are the inverted ones of the REX prefix.
+	byte1 := byte(0b111_00010) // 0F38 opcode map.
+	if regPrefix != RexPrefixNone {
+		byte1 &^= 0b100_00000
+	}
+	if rmPrefix != RexPrefixNone {
+		byte1 &^= 0b001_00000
+	}
+	byte2 := (^vvvv&0b1111)<<3 | 0b01 // VEX.L = 0 for 128-bit vectors, and the 0x66 implied prefix.
+	if op.vexW {
+		byte2 |= 0b1000_0000
+	}
+
+	a.buf.Write([]byte{0xc4, byte1, byte2, op.opcode, 0b11_000_000 | reg3bits<<3 | rm3bits})
+	return nil
+}
+
 func (a *AssemblerImpl) encodeRegisterToRegister(n *nodeImpl) (err error) {
 	// Alias for readability
 	inst := n.instruction
@@ -1263,3 +1263,65 @@ func TestAssemblerImpl_EncodeRegisterToRegister(t *testing.T) {
 		require.Equal(t, tc.exp, actual, tc.name)
 	}
 }
+
+func TestAssemblerImpl_EncodeTwoRegistersToRegister(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		tests := []struct {
+			n      *nodeImpl
+			expErr string
+		}{
+			{
+				n:      &nodeImpl{instruction: ADDL, types: operandTypesTwoRegistersToRegister, srcReg: RegX0, srcReg2: RegX1, dstReg: RegX2},
+				expErr: "ADDL is unsupported for from:two-registers,to:register type",
+			},
+			{
+				n:      &nodeImpl{instruction: VFMADD231PS, types: operandTypesTwoRegistersToRegister, srcReg: RegX0, srcReg2: RegAX, dstReg: RegX2},
+				expErr: "VFMADD231PS require float registers but got AX",
+			},
+		}
+
+		for _, tt := range tests {
+			a := NewAssembler()
+			err := a.encodeTwoRegistersToRegister(tt.n)
+			require.EqualError(t, err, tt.expErr, tt.expErr)
+		}
+	})
+
+	tests := []struct {
+		name string
+		n    *nodeImpl
+		exp  []byte
+	}{
+		{
+			name: "vfmadd231ps xmm1, xmm2, xmm3",
+			n:    &nodeImpl{instruction: VFMADD231PS, srcReg: RegX2, srcReg2: RegX3, dstReg: RegX1},
+			exp:  []byte{0xc4, 0xe2, 0x69, 0xb8, 0xcb},
+		},
+		{
+			name: "vfmadd231pd xmm10, xmm9, xmm11",
+			n:    &nodeImpl{instruction: VFMADD231PD, srcReg: RegX9, srcReg2: RegX11, dstReg: RegX10},
+			exp:  []byte{0xc4, 0x42, 0xb1, 0xb8, 0xd3},
+		},
+		{
+			name: "vfnmadd231ps xmm8, xmm0, xmm15",
+			n:    &nodeImpl{instruction: VFNMADD231PS, srcReg: RegX0, srcReg2: RegX15, dstReg: RegX8},
+			exp:  []byte{0xc4, 0x42, 0x79, 0xbc, 0xc7},
+		},
+		{
+			name: "vfnmadd231pd xmm3, xmm14, xmm2",
+			n:    &nodeImpl{instruction: VFNMADD231PD, srcReg: RegX14, srcReg2: RegX2, dstReg: RegX3},
+			exp:  []byte{0xc4, 0xe2, 0x89, 0xbc, 0xda},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		a := NewAssembler()
+		err := a.encodeTwoRegistersToRegister(tc.n)
+		require.NoError(t, err, tc.name)
+
+		actual, err := a.Assemble()
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.exp, actual, tc.name)
+	}
+}
@@ -8,7 +8,9 @@ import (
 // compiler is the interface of architecture-specific native code compiler,
 // and this is responsible for compiling native code for all wazeroir operations.
 type compiler interface {
-	Init(ir *wazeroir.CompilationResult, withListener bool)
+	// Init prepares the compiler for the function of ir. relaxedSIMDNative is true if the instructions of
+	// api.CoreFeatureRelaxedSIMD can be lowered to the native ones. See experimental.RelaxedSIMDNativeKey.
+	Init(ir *wazeroir.CompilationResult, withListener, relaxedSIMDNative bool)
 
 	// String is for debugging purpose.
 	String() string
@@ -43,6 +45,18 @@ type compiler interface {
 	compileCall(o *wazeroir.OperationCall) error
 	// compileCallIndirect adds instructions to perform wazeroir.OperationCallIndirect.
 	compileCallIndirect(o *wazeroir.OperationCallIndirect) error
+	// compileTailCall adds instructions to perform wazeroir.OperationTailCall.
+	compileTailCall(o *wazeroir.OperationTailCall) error
+	// compileTailCallIndirect adds instructions to perform wazeroir.OperationTailCallIndirect.
+	compileTailCallIndirect(o *wazeroir.OperationTailCallIndirect) error
+	// compileCallRef adds instructions to perform wazeroir.OperationCallRef.
+	compileCallRef(o *wazeroir.OperationCallRef) error
+	// compileTailCallRef adds instructions to perform wazeroir.OperationTailCallRef.
+	compileTailCallRef(o *wazeroir.OperationTailCallRef) error
+	// compileRefAsNonNull adds instructions to perform wazeroir.OperationRefAsNonNull.
+	compileRefAsNonNull() error
+	// compileSelectMemory adds instructions to perform wazeroir.OperationSelectMemory.
+	compileSelectMemory(o *wazeroir.OperationSelectMemory) error
 	// compileDrop adds instructions to perform wazeroir.OperationDrop.
 	compileDrop(o *wazeroir.OperationDrop) error
 	// compileSelect adds instructions to perform wazeroir.OperationSelect.
@@ -152,7 +166,7 @@ type compiler interface {
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemoryGrow.
 	compileMemoryGrow() error
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemorySize.
-	compileMemorySize() error
+	compileMemorySize(o *wazeroir.OperationMemorySize) error
 	// compileConstI32 adds instruction to perform wazeroir.OperationConstI32.
 	compileConstI32(o *wazeroir.OperationConstI32) error
 	// compileConstI64 adds instruction to perform wazeroir.OperationConstI64.
@@ -176,9 +190,9 @@ type compiler interface {
 	// compileDataDrop adds instructions to perform wazeroir.OperationDataDrop.
 	compileDataDrop(*wazeroir.OperationDataDrop) error
 	// compileMemoryCopy adds instructions to perform wazeroir.OperationMemoryCopy.
-	compileMemoryCopy() error
+	compileMemoryCopy(o *wazeroir.OperationMemoryCopy) error
 	// compileMemoryFill adds instructions to perform wazeroir.OperationMemoryFill.
-	compileMemoryFill() error
+	compileMemoryFill(o *wazeroir.OperationMemoryFill) error
 	// compileTableInit adds instructions to perform wazeroir.OperationTableInit.
 	compileTableInit(*wazeroir.OperationTableInit) error
 	// compileTableCopy adds instructions to perform wazeroir.OperationTableCopy.
@@ -302,6 +316,42 @@ type compiler interface {
 
 	// compileBuiltinFunctionCheckExitCode adds instructions to perform wazeroir.OperationBuiltinFunctionCheckExitCode.
 	compileBuiltinFunctionCheckExitCode() error
+	// compileConsumeFuel adds instructions to perform wazeroir.OperationConsumeFuel.
+	compileConsumeFuel(o *wazeroir.OperationConsumeFuel) error
+	// compileCoverBlock adds instructions to perform wazeroir.OperationCoverBlock.
+	compileCoverBlock(o *wazeroir.OperationCoverBlock) error
+
+	// compileAtomicMemoryWait adds instructions to perform wazeroir.OperationAtomicMemoryWait.
+	compileAtomicMemoryWait(o *wazeroir.OperationAtomicMemoryWait) error
+	// compileAtomicMemoryNotify adds instructions to perform wazeroir.OperationAtomicMemoryNotify.
+	compileAtomicMemoryNotify(o *wazeroir.OperationAtomicMemoryNotify) error
+	// compileAtomicFence adds instructions to perform wazeroir.OperationAtomicFence.
+	compileAtomicFence() error
+	// compileAtomicLoad adds instructions to perform wazeroir.OperationAtomicLoad.
+	compileAtomicLoad(o *wazeroir.OperationAtomicLoad) error
+	// compileAtomicStore adds instructions to perform wazeroir.OperationAtomicStore.
+	compileAtomicStore(o *wazeroir.OperationAtomicStore) error
+	// compileAtomicRMW adds instructions to perform wazeroir.OperationAtomicRMW.
+	compileAtomicRMW(o *wazeroir.OperationAtomicRMW) error
+	// compileAtomicRMWCmpxchg adds instructions to perform wazeroir.OperationAtomicRMWCmpxchg.
+	compileAtomicRMWCmpxchg(o *wazeroir.OperationAtomicRMWCmpxchg) error
+
+	// compileThrow adds instructions to perform wazeroir.OperationThrow.
+	compileThrow(o *wazeroir.OperationThrow) error
+	// compileRethrow adds instructions to perform wazeroir.OperationRethrow.
+	compileRethrow() error
+	// compileExceptionPending adds instructions to perform wazeroir.OperationExceptionPending.
+	compileExceptionPending() error
+	// compileExceptionMatch adds instructions to perform wazeroir.OperationExceptionMatch.
+	compileExceptionMatch(o *wazeroir.OperationExceptionMatch) error
+	// compileCatch adds instructions to perform wazeroir.OperationCatch.
+	compileCatch(o *wazeroir.OperationCatch) error
+	// compileGC adds instructions to perform wazeroir.OperationGC.
+	compileGC(o *wazeroir.OperationGC) error
+	// compileV128RelaxedMadd adds instructions to perform wazeroir.OperationV128RelaxedMadd.
+	compileV128RelaxedMadd(o *wazeroir.OperationV128RelaxedMadd) error
+	// compileV128RelaxedDot adds instructions to perform wazeroir.OperationV128RelaxedDot.
+	compileV128RelaxedDot(o *wazeroir.OperationV128RelaxedDot) error
 
 	// compileReleaseRegisterToStack adds instructions to write the value on a register back to memory stack region.
 	compileReleaseRegisterToStack(loc *runtimeValueLocation)
@@ -26,7 +26,7 @@ func BenchmarkCompiler_compileMemoryCopy(b *testing.B) {
 				}
 
 				compiler := newCompiler()
-				compiler.Init(&wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}}, false)
+				compiler.Init(&wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}}, false, false)
 				err := compiler.compilePreamble()
 				requireNoError(b, err)
 
@@ -43,7 +43,7 @@ func BenchmarkCompiler_compileMemoryCopy(b *testing.B) {
 				requireNoError(b, err)
 				err = compiler.compileConstI32(&wazeroir.OperationConstI32{Value: size})
 				requireNoError(b, err)
-				err = compiler.compileMemoryCopy()
+				err = compiler.compileMemoryCopy(&wazeroir.OperationMemoryCopy{})
 				requireNoError(b, err)
 				err = compiler.(compilerImpl).compileReturnFunction()
 				requireNoError(b, err)
@@ -79,7 +79,7 @@ func BenchmarkCompiler_compileMemoryFill(b *testing.B) {
 			}
 
 			compiler := newCompiler()
-			compiler.Init(&wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}}, false)
+			compiler.Init(&wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}}, false, false)
 
 			var startOffset uint32 = 100
 			var value uint8 = 5
@@ -90,7 +90,7 @@ func BenchmarkCompiler_compileMemoryFill(b *testing.B) {
 			requireNoError(b, err)
 			err = compiler.compileConstI32(&wazeroir.OperationConstI32{Value: size})
 			requireNoError(b, err)
-			err = compiler.compileMemoryFill()
+			err = compiler.compileMemoryFill(&wazeroir.OperationMemoryFill{})
 			requireNoError(b, err)
 			err = compiler.(compilerImpl).compileReturnFunction()
 			requireNoError(b, err)
@@ -58,7 +58,7 @@ func TestCompiler_compileMemorySize(t *testing.T) {
 	require.NoError(t, err)
 
 	// Emit memory.size instructions.
-	err = compiler.compileMemorySize()
+	err = compiler.compileMemorySize(&wazeroir.OperationMemorySize{})
 	require.NoError(t, err)
 	// At this point, the size of memory should be pushed onto the stack.
 	requireRuntimeLocationStackPointerEqual(t, uint64(1), compiler)
@@ -80,7 +80,7 @@ func TestCompiler_compileLoad(t *testing.T) {
 	loadTargetValue := uint64(0x12_34_56_78_9a_bc_ef_fe)
 	baseOffset := uint32(100)
 	arg := &wazeroir.MemoryArg{Offset: 361}
-	offset := baseOffset + arg.Offset
+	offset := baseOffset + uint32(arg.Offset)
 
 	tests := []struct {
 		name                string
@@ -279,7 +279,7 @@ func TestCompiler_compileStore(t *testing.T) {
 	storeTargetValue := uint64(math.MaxUint64)
 	baseOffset := uint32(100)
 	arg := &wazeroir.MemoryArg{Offset: 361}
-	offset := arg.Offset + baseOffset
+	offset := uint32(arg.Offset) + baseOffset
 
 	tests := []struct {
 		name                string
@@ -446,7 +446,7 @@ func TestCompiler_MemoryOutOfBounds(t *testing.T) {
 					err = compiler.compileConstI32(&wazeroir.OperationConstI32{Value: base})
 					require.NoError(t, err)
 
-					arg := &wazeroir.MemoryArg{Offset: offset}
+					arg := &wazeroir.MemoryArg{Offset: uint64(offset)}
 
 					switch targetSizeInByte {
 					case 1:
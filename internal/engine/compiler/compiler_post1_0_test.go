@@ -208,7 +208,7 @@ func TestCompiler_compileMemoryCopy(t *testing.T) {
 			err = compiler.compileConstI32(&wazeroir.OperationConstI32{Value: tc.size})
 			require.NoError(t, err)
 
-			err = compiler.compileMemoryCopy()
+			err = compiler.compileMemoryCopy(&wazeroir.OperationMemoryCopy{})
 			require.NoError(t, err)
 
 			// Generate the code under test.
@@ -292,7 +292,7 @@ func TestCompiler_compileMemoryFill(t *testing.T) {
 			err = compiler.compileConstI32(&wazeroir.OperationConstI32{Value: tc.size})
 			require.NoError(t, err)
 
-			err = compiler.compileMemoryFill()
+			err = compiler.compileMemoryFill(&wazeroir.OperationMemoryFill{})
 			require.NoError(t, err)
 
 			// Generate the code under test.
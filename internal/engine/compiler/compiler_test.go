@@ -58,6 +58,8 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(ce.statusCode)), callEngineExitContextNativeCallStatusCodeOffset, "callEngineExitContextNativeCallStatusCodeOffset")
 	requireEqual(int(unsafe.Offsetof(ce.builtinFunctionCallIndex)), callEngineExitContextBuiltinFunctionCallIndexOffset, "callEngineExitContextBuiltinFunctionCallIndexOffset")
 	requireEqual(int(unsafe.Offsetof(ce.returnAddress)), callEngineExitContextReturnAddressOffset, "callEngineExitContextReturnAddressOffset")
+	requireEqual(int(unsafe.Offsetof(ce.exceptionPending)), callEngineExitContextExceptionPendingOffset, "callEngineExitContextExceptionPendingOffset")
+	requireEqual(int(unsafe.Offsetof(ce.fuel)), callEngineExitContextFuelOffset, "callEngineExitContextFuelOffset")
 
 	// Size and offsets for callFrame.
 	var frame callFrame
@@ -68,8 +70,12 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(compiledFunc.codeInitialAddress)), functionCodeInitialAddressOffset, "functionCodeInitialAddressOffset")
 	requireEqual(int(unsafe.Offsetof(compiledFunc.source)), functionSourceOffset, "functionSourceOffset")
 	requireEqual(int(unsafe.Offsetof(compiledFunc.moduleInstanceAddress)), functionModuleInstanceAddressOffset, "functionModuleInstanceAddressOffset")
+	requireEqual(int(unsafe.Offsetof(compiledFunc.parent)), functionParentOffset, "functionParentOffset")
 	requireEqual(int(unsafe.Sizeof(compiledFunc)), functionSize, "functionModuleInstanceAddressOffset")
 
+	var c code
+	requireEqual(int(unsafe.Offsetof(c.coverage)), codeCoverageOffset, "codeCoverageOffset")
+
 	// Offsets for wasm.ModuleInstance.
 	var moduleInstance wasm.ModuleInstance
 	requireEqual(int(unsafe.Offsetof(moduleInstance.Globals)), moduleInstanceGlobalsOffset, "moduleInstanceGlobalsOffset")
@@ -79,6 +85,7 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(moduleInstance.TypeIDs)), moduleInstanceTypeIDsOffset, "moduleInstanceTypeIDsOffset")
 	requireEqual(int(unsafe.Offsetof(moduleInstance.DataInstances)), moduleInstanceDataInstancesOffset, "moduleInstanceDataInstancesOffset")
 	requireEqual(int(unsafe.Offsetof(moduleInstance.ElementInstances)), moduleInstanceElementInstancesOffset, "moduleInstanceElementInstancesOffset")
+	requireEqual(int(unsafe.Offsetof(moduleInstance.Memories)), moduleInstanceMemoriesOffset, "moduleInstanceMemoriesOffset")
 
 	var functionInstance wasm.FunctionInstance
 	requireEqual(int(unsafe.Offsetof(functionInstance.TypeID)), functionInstanceTypeIDOffset, "functionInstanceTypeIDOffset")
@@ -237,7 +244,7 @@ func (j *compilerEnv) requireNewCompiler(t *testing.T, fn func() compiler, ir *w
 	}
 
 	c := fn()
-	c.Init(ir, false)
+	c.Init(ir, false, false)
 
 	ret, ok := c.(compilerImpl)
 	require.True(t, ok)
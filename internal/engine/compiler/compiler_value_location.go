@@ -352,7 +352,7 @@ func (v *runtimeValueLocationStack) init(sig *wasm.FunctionType) {
 		switch t {
 		case wasm.ValueTypeI32:
 			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeAnyref:
 			loc.valueType = runtimeValueTypeI64
 		case wasm.ValueTypeF32:
 			loc.valueType = runtimeValueTypeF32
@@ -417,3 +417,26 @@ func (v *runtimeValueLocationStack) pushCallFrame(callTargetFunctionType *wasm.F
 	callerFunction.valueType = runtimeValueTypeI64
 	return
 }
+
+// pushRuntimeValueLocationsOnStack pushes the locations on the stack for the values of the given types.
+func (v *runtimeValueLocationStack) pushRuntimeValueLocationsOnStack(types []wasm.ValueType) {
+	for _, t := range types {
+		loc := v.pushRuntimeValueLocationOnStack()
+		switch t {
+		case wasm.ValueTypeI32:
+			loc.valueType = runtimeValueTypeI32
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeAnyref:
+			loc.valueType = runtimeValueTypeI64
+		case wasm.ValueTypeF32:
+			loc.valueType = runtimeValueTypeF32
+		case wasm.ValueTypeF64:
+			loc.valueType = runtimeValueTypeF64
+		case wasm.ValueTypeV128:
+			loc.valueType = runtimeValueTypeV128Lo
+			hi := v.pushRuntimeValueLocationOnStack()
+			hi.valueType = runtimeValueTypeV128Hi
+		default:
+			panic("BUG: invalid type: " + wasm.ValueTypeName(t))
+		}
+	}
+}
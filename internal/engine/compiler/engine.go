@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
+	"runtime/debug"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
@@ -35,6 +38,8 @@ type (
 		// setFinalizer defaults to runtime.SetFinalizer, but overridable for tests.
 		setFinalizer  func(obj interface{}, finalizer interface{})
 		wazeroVersion string
+		// relaxedSIMDNative is set by experimental.RelaxedSIMDNativeKey.
+		relaxedSIMDNative bool
 	}
 
 	// moduleEngine implements wasm.ModuleEngine
@@ -49,6 +54,10 @@ type (
 		// as the underlying memory region is accessed by assembly directly by using
 		// codesElement0Address.
 		functions []function
+
+		// codes are the compiled functions defined in the module, which remain after the module is deleted from the
+		// engine.
+		codes []*code
 	}
 
 	// callEngine holds context per moduleEngine.Call, and shared across all the
@@ -134,6 +143,23 @@ type (
 		// contextStack is a stack of contexts which is pushed and popped by function listeners.
 		// This is used and modified when there are function listeners.
 		contextStack *contextStack
+
+		// exception is the exception thrown and not caught yet (api.CoreFeatureExceptionHandling).
+		// Use setException to modify this, so that exitContext.exceptionPending is kept in sync.
+		exception *api.Exception
+		// caughtExceptions hold the caught exceptions which might be rethrown, where the reference to each exception is
+		// its index plus one.
+		caughtExceptions []*api.Exception
+
+		// unlimitedFuel is the fuel pointed by exitContext.fuel when the context.Context of the call has none.
+		unlimitedFuel int64
+
+		// interrupted is set by wasm.CallContext WatchCanceledOrTimeout when the initial function is compiled with
+		// ensureTermination, and checked along with the exit code.
+		interrupted *uint32
+
+		// stackIterator is passed to experimental.FunctionListener Before, and reused to avoid allocations.
+		stackIterator stackIterator
 	}
 
 	// contextStack is a stack of context.Context.
@@ -229,6 +255,14 @@ type (
 		// returnAddress is the return address which the engine jumps into
 		// after executing a builtin function or host function.
 		returnAddress uintptr
+
+		// exceptionPending is 1 if callEngine.exception is not nil. Native code reads this after each function call
+		// in order to branch into the exception handler. See wazeroir.OperationExceptionPending.
+		exceptionPending uint32
+
+		// fuel points to the remaining fuel of the call, which the functions compiled with fuel metering decrement at
+		// the beginning of each basic block. See wazeroir.OperationConsumeFuel.
+		fuel *int64
 	}
 
 	// callFrame holds the information to which the caller function can return.
@@ -275,6 +309,10 @@ type (
 		// See the doc for codeStaticData type.
 		// stackPointerCeil is the max of the stack pointer this function can reach. Lazily applied via maybeGrowStack.
 		stackPointerCeil uint64
+		// coverage holds the times each of coverageBlocks was executed, incremented by wazeroir.OperationCoverBlock.
+		// These are nil unless compiled with coverage.
+		coverage       []uint64
+		coverageBlocks []wasm.CoverageBlock
 
 		// indexInModule is the index of this function in the module. For logging purpose.
 		indexInModule wasm.Index
@@ -285,6 +323,9 @@ type (
 		goFunc   interface{}
 
 		withEnsureTermination bool
+		// withExceptionHandling is true if api.CoreFeatureExceptionHandling is enabled, where the exceptions thrown by
+		// goFunc are caught.
+		withExceptionHandling bool
 		sourceOffsetMap       *sourceOffsetMap
 	}
 
@@ -331,13 +372,19 @@ const (
 	callEngineExitContextNativeCallStatusCodeOffset     = 120
 	callEngineExitContextBuiltinFunctionCallIndexOffset = 124
 	callEngineExitContextReturnAddressOffset            = 128
+	callEngineExitContextExceptionPendingOffset         = 136
+	callEngineExitContextFuelOffset                     = 144
 
 	// Offsets for function.
 	functionCodeInitialAddressOffset    = 0
 	functionSourceOffset                = 8
 	functionModuleInstanceAddressOffset = 16
+	functionParentOffset                = 24
 	functionSize                        = 32
 
+	// Offsets for code.
+	codeCoverageOffset = 32
+
 	// Offsets for wasm.ModuleInstance.
 	moduleInstanceGlobalsOffset          = 48
 	moduleInstanceMemoryOffset           = 72
@@ -346,6 +393,7 @@ const (
 	moduleInstanceTypeIDsOffset          = 128
 	moduleInstanceDataInstancesOffset    = 152
 	moduleInstanceElementInstancesOffset = 176
+	moduleInstanceMemoriesOffset         = 200
 
 	// Offsets for wasm.TableInstance.
 	tableInstanceTableOffset    = 0
@@ -404,6 +452,10 @@ const (
 	nativeCallStatusIntegerOverflow
 	nativeCallStatusIntegerDivisionByZero
 	nativeCallStatusModuleClosed
+	// nativeCallStatusCodeNullReference means a null reference was dereferenced by call_ref or ref.as_non_null.
+	nativeCallStatusCodeNullReference
+	// nativeCallStatusCodeFuelExhausted means a basic block consumed more than the remaining fuel of the call.
+	nativeCallStatusCodeFuelExhausted
 )
 
 // causePanic causes a panic with the corresponding error to the nativeCallStatusCode.
@@ -424,6 +476,10 @@ func (s nativeCallStatusCode) causePanic() {
 		err = wasmruntime.ErrRuntimeInvalidTableAccess
 	case nativeCallStatusCodeTypeMismatchOnIndirectCall:
 		err = wasmruntime.ErrRuntimeIndirectCallTypeMismatch
+	case nativeCallStatusCodeNullReference:
+		err = wasmruntime.ErrRuntimeNullReference
+	case nativeCallStatusCodeFuelExhausted:
+		err = wasmruntime.ErrRuntimeFuelExhausted
 	}
 	panic(err)
 }
@@ -452,6 +508,10 @@ func (s nativeCallStatusCode) String() (ret string) {
 		ret = "integer division by zero"
 	case nativeCallStatusModuleClosed:
 		ret = "module closed"
+	case nativeCallStatusCodeNullReference:
+		ret = "null reference"
+	case nativeCallStatusCodeFuelExhausted:
+		ret = "fuel exhausted"
 	default:
 		panic("BUG")
 	}
@@ -496,14 +556,14 @@ func (e *engine) Close() (err error) {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
+func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, coverage bool) error {
 	if _, ok, err := e.getCodes(module); ok { // cache hit!
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	irs, err := wazeroir.CompileFunctions(e.enabledFeatures, callFrameDataSizeInUint64, module, ensureTermination)
+	irs, err := wazeroir.CompileFunctions(e.enabledFeatures, callFrameDataSizeInUint64, module, ensureTermination, fuelMetering, coverage)
 	if err != nil {
 		return err
 	}
@@ -518,7 +578,7 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 		if i < ln {
 			lsn = listeners[i]
 		}
-		cmp.Init(ir, lsn != nil)
+		cmp.Init(ir, lsn != nil, e.relaxedSIMDNative)
 		funcIndex := wasm.Index(i)
 		var compiled *code
 		if ir.GoFunc != nil {
@@ -540,9 +600,42 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 		compiled.indexInModule = funcIndex
 		compiled.sourceModule = module
 		compiled.withEnsureTermination = ir.EnsureTermination
+		compiled.withExceptionHandling = e.enabledFeatures.IsEnabled(api.CoreFeatureExceptionHandling)
+		if ir.CoverageBlocks != nil {
+			compiled.coverage, compiled.coverageBlocks = make([]uint64, len(ir.CoverageBlocks)), ir.CoverageBlocks
+		}
 		funcs[funcIndex] = compiled
 	}
-	return e.addCodes(module, funcs, withGoFunc)
+	// The blocks counted by coverage aren't in the file cache, so such functions are only kept in memory.
+	return e.addCodes(module, funcs, withGoFunc || coverage)
+}
+
+// Coverage implements the same method as documented on wasm.Engine.
+func (e *engine) Coverage(module *wasm.Module) [][]wasm.CoverageBlock {
+	codes, _, _ := e.getCodes(module)
+	return coverage(codes)
+}
+
+// coverage returns the basic blocks of the given codes, with the times they were executed, or nil if none of them is
+// compiled with coverage.
+func coverage(codes []*code) (ret [][]wasm.CoverageBlock) {
+	for i, c := range codes {
+		if c.coverageBlocks == nil {
+			continue
+		}
+		if ret == nil {
+			ret = make([][]wasm.CoverageBlock, len(codes))
+		}
+		// The counters are incremented by the native code without synchronization, so concurrent calls might lose
+		// some of the increments.
+		blocks := make([]wasm.CoverageBlock, len(c.coverageBlocks))
+		for j, b := range c.coverageBlocks {
+			b.Count = atomic.LoadUint64(&c.coverage[j])
+			blocks[j] = b
+		}
+		ret[i] = blocks
+	}
+	return
 }
 
 // NewModuleEngine implements the same method as documented on wasm.Engine.
@@ -575,6 +668,7 @@ func (e *engine) NewModuleEngine(name string, module *wasm.Module, functions []w
 			parent:                c,
 		}
 	}
+	me.codes = codes
 	return me, nil
 }
 
@@ -583,6 +677,11 @@ func (e *moduleEngine) Name() string {
 	return e.name
 }
 
+// Coverage implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) Coverage() [][]wasm.CoverageBlock {
+	return coverage(e.codes)
+}
+
 // FunctionInstanceReference implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) FunctionInstanceReference(funcIndex wasm.Index) wasm.Reference {
 	return uintptr(unsafe.Pointer(&e.functions[funcIndex]))
@@ -648,7 +747,7 @@ func functionFromUintptr(ptr uintptr) *function {
 	return *(**function)(unsafe.Pointer(wrapped))
 }
 
-// Call implements the same method as documented on wasm.ModuleEngine.
+// Call implements the same method as documented on wasm.CallEngine.
 func (ce *callEngine) Call(ctx context.Context, callCtx *wasm.CallContext, params []uint64) (results []uint64, err error) {
 	tp := ce.initialFn.source.Type
 
@@ -657,13 +756,45 @@ func (ce *callEngine) Call(ctx context.Context, callCtx *wasm.CallContext, param
 		return nil, fmt.Errorf("expected %d params, but passed %d", ce.initialFn.source.Type.ParamNumInUint64, paramCount)
 	}
 
-	// We ensure that this Call method never panics as
-	// this Call method is indirectly invoked by embedders via store.CallFunction,
+	// This returns a safe copy of the results, instead of a slice view. If we
+	// returned a re-slice, the caller could accidentally or purposefully
+	// corrupt the stack of subsequent calls
+	if resultCount := tp.ResultNumInUint64; resultCount > 0 {
+		results = make([]uint64, resultCount)
+	}
+	if err = ce.call(ctx, callCtx, tp, params, results); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// CallWithStack implements the same method as documented on wasm.CallEngine.
+func (ce *callEngine) CallWithStack(ctx context.Context, callCtx *wasm.CallContext, stack []uint64) error {
+	tp := ce.initialFn.source.Type
+	paramCount, resultCount := tp.ParamNumInUint64, tp.ResultNumInUint64
+	if stackSize := len(stack); stackSize < paramCount || stackSize < resultCount {
+		return fmt.Errorf("need %d params and %d results, but stack size is %d", paramCount, resultCount, stackSize)
+	}
+	return ce.call(ctx, callCtx, tp, stack[:paramCount], stack[:resultCount])
+}
+
+// call invokes the initial function with the params, and copies its results
+// into the given slice. The params and results may share the same underlying
+// array, as the params are copied onto the stack before the function runs.
+func (ce *callEngine) call(ctx context.Context, callCtx *wasm.CallContext, tp *wasm.FunctionType, params, results []uint64) (err error) {
+	// We ensure that this call method never panics as
+	// this call method is indirectly invoked by embedders via store.CallFunction,
 	// and we have to make sure that all the runtime errors, including the one happening inside
 	// host functions, will be captured as errors, not panics.
+	//
+	// This includes the faults of the accesses out of the bounds of reserved memories, which aren't bounds checked.
+	// See memoryFault
+	panicOnFault := debug.SetPanicOnFault(true)
 	defer func() {
-		err = ce.deferredOnCall(recover())
-		if err == nil {
+		debug.SetPanicOnFault(panicOnFault)
+		if recoveredErr := ce.deferredOnCall(recover()); recoveredErr != nil {
+			err = recoveredErr
+		} else if err == nil {
 			// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
 			err = callCtx.FailIfClosed()
 		}
@@ -672,19 +803,26 @@ func (ce *callEngine) Call(ctx context.Context, callCtx *wasm.CallContext, param
 	ce.initializeStack(tp, params)
 
 	if ce.fn.parent.withEnsureTermination {
-		done := callCtx.CloseModuleOnCanceledOrTimeout(ctx)
+		var done context.CancelFunc
+		ce.interrupted, done = callCtx.WatchCanceledOrTimeout(ctx)
 		defer done()
 	}
 
+	// Unlike withEnsureTermination, this doesn't depend on the flags of the initial function, as they are not kept in
+	// the file cache. Functions without fuel metering never read it.
+	ce.fuel = wasm.GetFuel(ctx, &ce.unlimitedFuel)
+
 	ce.execWasmFunction(ctx, callCtx)
 
-	// This returns a safe copy of the results, instead of a slice view. If we
-	// returned a re-slice, the caller could accidentally or purposefully
-	// corrupt the stack of subsequent calls
-	if resultCount := tp.ResultNumInUint64; resultCount > 0 {
-		results = make([]uint64, resultCount)
-		copy(results, ce.stack[:resultCount])
+	copy(results, ce.stack[:len(results)])
+
+	// The exception uncaught in Wasm is returned as is.
+	if exception := ce.exception; exception != nil {
+		ce.setException(nil)
+		ce.caughtExceptions = nil
+		return exception
 	}
+	ce.caughtExceptions = nil
 	return
 }
 
@@ -743,37 +881,25 @@ func callFrameOffset(funcType *wasm.FunctionType) (ret int) {
 // This is defined for testability.
 func (ce *callEngine) deferredOnCall(recovered interface{}) (err error) {
 	if recovered != nil {
+		recovered = ce.memoryFault(recovered)
 		builder := wasmdebug.NewErrorBuilder()
 
 		// Unwinds call frames from the values stack, starting from the
 		// current function `ce.fn`, and the current stack base pointer `ce.stackBasePointerInBytes`.
-		fn := ce.fn
-		pc := uint64(ce.returnAddress)
-		stackBasePointer := int(ce.stackBasePointerInBytes >> 3)
-		for {
-			source := fn.source
-			def := source.Definition
+		var si stackIterator
+		si.reset(ce, false)
+		for si.Next() {
+			def := si.fn.source.Definition
 
 			// sourceInfo holds the source code information corresponding to the frame.
 			// It is not empty only when the DWARF is enabled.
 			var sources []string
-			if p := fn.parent; p.codeSegment != nil {
+			if p := si.fn.parent; p.codeSegment != nil {
 				if p.sourceOffsetMap != nil {
-					offset := fn.getSourceOffsetInWasmBinary(pc)
-					sources = p.sourceModule.DWARFLines.Line(offset)
+					sources = p.sourceModule.DWARFLines.Line(si.sourceOffset)
 				}
 			}
 			builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
-
-			callFrameOffset := callFrameOffset(source.Type)
-			if stackBasePointer != 0 {
-				frame := *(*callFrame)(unsafe.Pointer(&ce.stack[stackBasePointer+callFrameOffset]))
-				fn = frame.function
-				pc = uint64(frame.returnAddress)
-				stackBasePointer = int(frame.returnStackBasePointerInBytes >> 3)
-			} else { // base == 0 means that this was the last call frame stacked.
-				break
-			}
 		}
 		err = builder.FromRecovered(recovered)
 	}
@@ -781,9 +907,81 @@ func (ce *callEngine) deferredOnCall(recovered interface{}) (err error) {
 	// Allows the reuse of CallEngine.
 	ce.stackBasePointerInBytes, ce.stackPointer, ce.moduleInstanceAddress = 0, 0, 0
 	ce.moduleContext.fn = ce.initialFn
+	ce.setException(nil)
+	ce.caughtExceptions = nil
 	return
 }
 
+// memoryFault returns wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess if recovered is the fault of an access to the
+// reserved memory of the current function, whose bounds aren't checked by the compiled code. Otherwise, this returns
+// recovered as is. See wasm.NewReservedMemoryInstance
+func (ce *callEngine) memoryFault(recovered interface{}) interface{} {
+	fault, ok := recovered.(interface{ Addr() uintptr }) // The runtime.Error of faults with debug.SetPanicOnFault.
+	if !ok || ce.fn == nil {
+		return recovered
+	}
+	if mem := ce.fn.source.Module.Memory; mem != nil && mem.ReservedAddress(fault.Addr()) {
+		return wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess
+	}
+	return recovered
+}
+
+// stackIterator implements experimental.StackIterator by unwinding the call frames from the values stack, starting
+// from the current function of a callEngine.
+type stackIterator struct {
+	stack            []uint64
+	fn, next         *function
+	pc               uint64
+	stackBasePointer int
+	sourceOffset     uint64
+	// calling is true until the first frame, when it is the one of the function being called, which doesn't execute
+	// any instruction yet.
+	calling bool
+}
+
+// reset prepares the iterator for the current call stack of ce. calling is true when the current function is being
+// called, so that the source offset of its frame is zero, as documented on experimental.StackIterator.
+func (si *stackIterator) reset(ce *callEngine, calling bool) *stackIterator {
+	si.stack, si.fn, si.next = ce.stack, nil, ce.fn
+	si.pc, si.stackBasePointer = uint64(ce.returnAddress), int(ce.stackBasePointerInBytes>>3)
+	si.calling = calling
+	return si
+}
+
+// Next implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) Next() bool {
+	fn := si.next
+	if fn == nil {
+		return false
+	}
+	si.fn = fn
+	if si.calling {
+		si.sourceOffset, si.calling = 0, false
+	} else {
+		si.sourceOffset = fn.getSourceOffsetInWasmBinary(si.pc)
+	}
+
+	if si.stackBasePointer != 0 {
+		frame := *(*callFrame)(unsafe.Pointer(&si.stack[si.stackBasePointer+callFrameOffset(fn.source.Type)]))
+		si.next = frame.function
+		si.pc = uint64(frame.returnAddress)
+		si.stackBasePointer = int(frame.returnStackBasePointerInBytes >> 3)
+	} else { // base == 0 means that this was the last call frame stacked.
+		si.next = nil
+	}
+	return true
+}
+
+// FunctionDefinition implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) FunctionDefinition() api.FunctionDefinition {
+	return si.fn.source.Definition
+}
+
+// SourceOffset implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) SourceOffset() uint64 {
+	return si.sourceOffset
+}
+
 // getSourceOffsetInWasmBinary returns the corresponding offset in the original Wasm binary's code section
 // for the given pc (which is an absolute address in the memory).
 // If needPreviousInstr equals true, this returns the previous instruction's offset for the given pc.
@@ -823,12 +1021,19 @@ func newEngine(ctx context.Context, enabledFeatures api.CoreFeatures, fileCache
 	if v := ctx.Value(version.WazeroVersionKey{}); v != nil {
 		wazeroVersion = v.(string)
 	}
+	relaxedSIMDNative, _ := ctx.Value(experimental.RelaxedSIMDNativeKey{}).(bool)
+	if relaxedSIMDNative {
+		// The cached codes are only valid for the engines with the same setting. As the cache key is the module ID,
+		// this makes the codes compiled with the other setting stale.
+		wazeroVersion += "+relaxed-simd-native"
+	}
 	return &engine{
-		enabledFeatures: enabledFeatures,
-		codes:           map[wasm.ModuleID][]*code{},
-		setFinalizer:    runtime.SetFinalizer,
-		fileCache:       fileCache,
-		wazeroVersion:   wazeroVersion,
+		enabledFeatures:   enabledFeatures,
+		codes:             map[wasm.ModuleID][]*code{},
+		setFinalizer:      runtime.SetFinalizer,
+		fileCache:         fileCache,
+		wazeroVersion:     wazeroVersion,
+		relaxedSIMDNative: relaxedSIMDNative,
 	}
 }
 
@@ -899,6 +1104,20 @@ const (
 	builtinFunctionIndexFunctionListenerBefore
 	builtinFunctionIndexFunctionListenerAfter
 	builtinFunctionIndexCheckExitCode
+	builtinFunctionIndexAtomicMemoryWait
+	builtinFunctionIndexAtomicMemoryNotify
+	builtinFunctionIndexAtomicLoad
+	builtinFunctionIndexAtomicStore
+	builtinFunctionIndexAtomicRMW
+	builtinFunctionIndexAtomicRMWCmpxchg
+	builtinFunctionIndexMemoryCopy
+	builtinFunctionIndexMemoryInit
+	builtinFunctionIndexMemoryFill
+	builtinFunctionIndexThrow
+	builtinFunctionIndexRethrow
+	builtinFunctionIndexExceptionMatch
+	builtinFunctionIndexCatch
+	builtinFunctionIndexGC
 	// builtinFunctionIndexBreakPoint is internal (only for wazero developers). Disabled by default.
 	builtinFunctionIndexBreakPoint
 )
@@ -930,11 +1149,17 @@ entry:
 			stack := ce.stack[base : base+stackLen]
 
 			fn := calleeHostFunction.parent.goFunc
-			switch fn := fn.(type) {
-			case api.GoModuleFunction:
-				fn.Call(ce.ctx, callCtx.WithMemory(ce.memoryInstance), stack)
-			case api.GoFunction:
-				fn.Call(ce.ctx, stack)
+			heap := calleeHostFunction.source.Module.GCHeap
+			if heap != nil {
+				heap.PauseCall(ce)
+			}
+			if calleeHostFunction.parent.withExceptionHandling {
+				ce.setException(wasm.CatchException(func() { ce.callGoFunc(fn, callCtx, stack) }))
+			} else {
+				ce.callGoFunc(fn, callCtx, stack)
+			}
+			if heap != nil {
+				heap.ResumeCall(ce)
 			}
 
 			codeAddr, modAddr = ce.returnAddress, ce.moduleInstanceAddress
@@ -943,7 +1168,7 @@ entry:
 			caller := ce.moduleContext.fn
 			switch ce.exitContext.builtinFunctionCallIndex {
 			case builtinFunctionIndexMemoryGrow:
-				ce.builtinFunctionMemoryGrow(caller.source.Module.Memory)
+				ce.builtinFunctionMemoryGrow(ce.memoryInstance)
 			case builtinFunctionIndexGrowStack:
 				ce.builtinFunctionGrowStack(caller.parent.stackPointerCeil)
 			case builtinFunctionIndexTableGrow:
@@ -959,6 +1184,37 @@ entry:
 				if err := callCtx.FailIfClosed(); err != nil {
 					panic(err)
 				}
+				if err := wasm.FailIfInterrupted(ce.interrupted); err != nil {
+					panic(err)
+				}
+			case builtinFunctionIndexAtomicMemoryWait:
+				ce.builtinFunctionAtomicMemoryWait(ce.memoryInstance)
+			case builtinFunctionIndexAtomicMemoryNotify:
+				ce.builtinFunctionAtomicMemoryNotify(ce.memoryInstance)
+			case builtinFunctionIndexAtomicLoad:
+				ce.builtinFunctionAtomicLoad(ce.memoryInstance)
+			case builtinFunctionIndexAtomicStore:
+				ce.builtinFunctionAtomicStore(ce.memoryInstance)
+			case builtinFunctionIndexAtomicRMW:
+				ce.builtinFunctionAtomicRMW(ce.memoryInstance)
+			case builtinFunctionIndexAtomicRMWCmpxchg:
+				ce.builtinFunctionAtomicRMWCmpxchg(ce.memoryInstance)
+			case builtinFunctionIndexMemoryCopy:
+				ce.builtinFunctionMemoryCopy(caller.source.Module.Memories)
+			case builtinFunctionIndexMemoryInit:
+				ce.builtinFunctionMemoryInit(ce.memoryInstance, caller.source.Module.DataInstances)
+			case builtinFunctionIndexMemoryFill:
+				ce.builtinFunctionMemoryFill(ce.memoryInstance)
+			case builtinFunctionIndexThrow:
+				ce.builtinFunctionThrow(caller.source.Module.Tags)
+			case builtinFunctionIndexRethrow:
+				ce.builtinFunctionRethrow()
+			case builtinFunctionIndexExceptionMatch:
+				ce.builtinFunctionExceptionMatch(caller.source.Module.Tags)
+			case builtinFunctionIndexCatch:
+				ce.builtinFunctionCatch()
+			case builtinFunctionIndexGC:
+				ce.builtinFunctionGC(caller.source.Module)
 			}
 			if false {
 				if ce.exitContext.builtinFunctionCallIndex == builtinFunctionIndexBreakPoint {
@@ -974,6 +1230,139 @@ entry:
 	}
 }
 
+// callGoFunc calls the host function fn which is either api.GoModuleFunction or api.GoFunction.
+func (ce *callEngine) callGoFunc(fn interface{}, callCtx *wasm.CallContext, stack []uint64) {
+	switch fn := fn.(type) {
+	case api.GoModuleFunction:
+		fn.Call(ce.ctx, callCtx.WithMemory(ce.memoryInstance), stack)
+	case api.GoFunction:
+		fn.Call(ce.ctx, stack)
+	}
+}
+
+// GCRoots implements the same method as documented on wasm.CallEngine.
+func (ce *callEngine) GCRoots(fn func(v uint64)) {
+	for _, v := range ce.stack[:ce.stackTopIndex()] {
+		fn(v)
+	}
+	if ce.exception != nil {
+		for _, v := range ce.exception.Payload() {
+			fn(v)
+		}
+	}
+	for _, e := range ce.caughtExceptions {
+		for _, v := range e.Payload() {
+			fn(v)
+		}
+	}
+}
+
+// setException sets callEngine.exception and exitContext.exceptionPending accordingly.
+func (ce *callEngine) setException(exception *api.Exception) {
+	ce.exception = exception
+	if exception != nil {
+		ce.exceptionPending = 1
+	} else {
+		ce.exceptionPending = 0
+	}
+}
+
+// catchImmediate encodes wazeroir.OperationCatch into a single 64-bit constant, which is pushed onto the stack by
+// native code before calling builtinFunctionIndexCatch.
+func catchImmediate(o *wazeroir.OperationCatch) (imm uint64) {
+	if o.All {
+		imm |= 1
+	}
+	if o.Rethrown {
+		imm |= 2
+	}
+	return
+}
+
+// builtinFunctionThrow implements wazeroir.OperationThrow.
+func (ce *callEngine) builtinFunctionThrow(tags []*wasm.TagInstance) {
+	tag := tags[ce.popValue()]
+	payload := make([]uint64, tag.Type.ParamNumInUint64)
+	for i := len(payload) - 1; i >= 0; i-- {
+		payload[i] = ce.popValue()
+	}
+	ce.setException(api.NewException(tag, payload...))
+}
+
+// builtinFunctionRethrow implements wazeroir.OperationRethrow.
+func (ce *callEngine) builtinFunctionRethrow() {
+	_, ref := ce.popValue(), ce.popValue()
+	ce.setException(ce.caughtExceptions[ref-1])
+}
+
+// builtinFunctionExceptionMatch implements wazeroir.OperationExceptionMatch.
+func (ce *callEngine) builtinFunctionExceptionMatch(tags []*wasm.TagInstance) {
+	if ce.exception.Tag() == api.Tag(tags[ce.popValue()]) {
+		ce.pushValue(1)
+	} else {
+		ce.pushValue(0)
+	}
+}
+
+// builtinFunctionCatch implements wazeroir.OperationCatch, where the immediate is encoded by catchImmediate.
+func (ce *callEngine) builtinFunctionCatch() {
+	imm := ce.popValue()
+	exception := ce.exception
+	ce.setException(nil)
+	var ref uint64
+	if imm&2 != 0 {
+		ce.caughtExceptions = append(ce.caughtExceptions, exception)
+		ref = uint64(len(ce.caughtExceptions))
+	}
+	ce.pushValue(ref)
+	if imm&1 == 0 {
+		for _, v := range exception.Payload() {
+			ce.pushValue(v)
+		}
+	}
+}
+
+// gcImmediate encodes wazeroir.OperationGC into a single 64-bit constant, which is pushed onto the stack by native
+// code before calling builtinFunctionIndexGC.
+func gcImmediate(o *wazeroir.OperationGC) (imm uint64) {
+	imm = uint64(o.Opcode) | uint64(o.Operands)<<8 | uint64(o.TypeIndex)<<32
+	if o.HasResult {
+		imm |= 1 << 31
+	}
+	return
+}
+
+// gcResultType returns the runtimeValueType of the result of wazeroir.OperationGC.
+func gcResultType(o *wazeroir.OperationGC) runtimeValueType {
+	if !o.HasResult {
+		return runtimeValueTypeNone
+	}
+	switch o.Result {
+	case wazeroir.UnsignedTypeI32:
+		return runtimeValueTypeI32
+	case wazeroir.UnsignedTypeF32:
+		return runtimeValueTypeF32
+	case wazeroir.UnsignedTypeF64:
+		return runtimeValueTypeF64
+	default:
+		return runtimeValueTypeI64
+	}
+}
+
+// builtinFunctionGC implements wazeroir.OperationGC, where the immediate is encoded by gcImmediate except for the
+// index, which is pushed before it.
+func (ce *callEngine) builtinFunctionGC(m *wasm.ModuleInstance) {
+	imm, index := ce.popValue(), ce.popValue()
+	operands := make([]uint64, (imm>>8)&0x7fffff)
+	for i := len(operands) - 1; i >= 0; i-- {
+		operands[i] = ce.popValue()
+	}
+	v := m.ExecuteGC(byte(imm), uint32(imm>>32), uint32(index), operands)
+	if imm&(1<<31) != 0 {
+		ce.pushValue(v)
+	}
+}
+
 // callStackCeiling is the maximum WebAssembly call frame stack height. This allows wazero to raise
 // wasm.ErrCallStackOverflow instead of overflowing the Go runtime.
 //
@@ -1002,18 +1391,184 @@ func (ce *callEngine) builtinFunctionGrowStack(stackPointerCeil uint64) {
 func (ce *callEngine) builtinFunctionMemoryGrow(mem *wasm.MemoryInstance) {
 	newPages := ce.popValue()
 
-	if res, ok := mem.Grow(uint32(newPages)); !ok {
-		ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+	if mem.Is64 && newPages > math.MaxUint32 { // Always exceeds the limit.
+		ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+	} else if res, ok := mem.Grow(uint32(newPages)); !ok {
+		if mem.Is64 {
+			ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+		} else {
+			ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+		}
 	} else {
 		ce.pushValue(uint64(res))
 	}
 
 	// Update the moduleContext fields as they become stale after the update ^^.
 	bufSliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&mem.Buffer))
-	ce.moduleContext.memorySliceLen = uint64(bufSliceHeader.Len)
+	ce.moduleContext.memorySliceLen = mem.Size64() // The length of a shared memory is loaded atomically.
 	ce.moduleContext.memoryElement0Address = bufSliceHeader.Data
 }
 
+// memoryCopyImmediate encodes the memory indexes of wazeroir.OperationMemoryCopy between different memories into a
+// single 64-bit constant, which is pushed onto the stack by native code before calling builtinFunctionIndexMemoryCopy.
+func memoryCopyImmediate(o *wazeroir.OperationMemoryCopy) uint64 {
+	return uint64(o.DestinationMemoryIndex) | uint64(o.SourceMemoryIndex)<<32
+}
+
+// builtinFunctionMemoryCopy copies bytes between different memories, or within a 64-bit memory. Otherwise, memory.copy
+// is implemented in native code instead.
+func (ce *callEngine) builtinFunctionMemoryCopy(memories []*wasm.MemoryInstance) {
+	imm, size, srcOffset, dstOffset := ce.popValue(), ce.popValue(), ce.popValue(), ce.popValue()
+	dst, src := memories[uint32(imm)], memories[imm>>32]
+	dstOffset, srcOffset = memoryOperand(dst, dstOffset), memoryOperand(src, srcOffset)
+	if !dst.Is64 || !src.Is64 { // The size is i64 only when both memories are 64-bit.
+		size = uint64(uint32(size))
+	}
+	srcBuf, ok := src.Read64(srcOffset, size)
+	if !ok {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	dstBuf, ok := dst.Read64(dstOffset, size)
+	if !ok {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	copy(dstBuf, srcBuf)
+}
+
+// builtinFunctionMemoryInit implements memory.init on a 64-bit memory. Otherwise, it is implemented in native code.
+func (ce *callEngine) builtinFunctionMemoryInit(mem *wasm.MemoryInstance, dataInstances []wasm.DataInstance) {
+	imm, size, srcOffset, dstOffset := ce.popValue(), uint64(uint32(ce.popValue())), uint64(uint32(ce.popValue())), ce.popValue()
+	data := dataInstances[imm]
+	if srcOffset+size > uint64(len(data)) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	dstBuf, ok := mem.Read64(memoryOperand(mem, dstOffset), size)
+	if !ok {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	copy(dstBuf, data[srcOffset:])
+}
+
+// builtinFunctionMemoryFill implements memory.fill on a 64-bit memory. Otherwise, it is implemented in native code.
+func (ce *callEngine) builtinFunctionMemoryFill(mem *wasm.MemoryInstance) {
+	_, size, value, offset := ce.popValue(), ce.popValue(), byte(ce.popValue()), ce.popValue()
+	buf, ok := mem.Read64(memoryOperand(mem, offset), memoryOperand(mem, size))
+	if !ok {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	for i := range buf {
+		buf[i] = value
+	}
+}
+
+// memoryOperand returns the address or size operand v of a bulk memory instruction on mem. In a 32-bit memory, v is
+// i32, so this clears the upper 32 bits which are undefined in native code.
+func memoryOperand(mem *wasm.MemoryInstance, v uint64) uint64 {
+	if mem.Is64 {
+		return v
+	}
+	return uint64(uint32(v))
+}
+
+// memoryAccessOffsetConst returns the constant "arg.Offset + targetSizeInBytes" which native code adds to the base
+// address of memory accesses. This returns false when the access is out of bounds regardless of the base, as the
+// constant exceeds the maximum size of the memory.
+func memoryAccessOffsetConst(arg *wazeroir.MemoryArg, targetSizeInBytes int64) (int64, bool) {
+	maxOffsetConst := uint64(math.MaxUint32)
+	if arg.Memory64 {
+		maxOffsetConst = wasm.MemoryPagesToBytesNum(wasm.MemoryLimitPages64)
+	}
+	if arg.Offset > maxOffsetConst || arg.Offset+uint64(targetSizeInBytes) > maxOffsetConst {
+		return 0, false
+	}
+	return int64(arg.Offset) + targetSizeInBytes, true
+}
+
+// atomicImmediate encodes the immediates of atomic operations into a single 64-bit constant, which is pushed onto the
+// stack by native code before calling the atomic builtin functions.
+//
+// Note: The offset is saturated to 48 bits, which still results in out of bounds access as it exceeds the maximum size
+// of 64-bit memories.
+func atomicImmediate(arg *wazeroir.MemoryArg, size uint32, op wasm.AtomicArithmeticOp) uint64 {
+	offset := arg.Offset
+	if offset > atomicImmediateOffsetMask {
+		offset = atomicImmediateOffsetMask
+	}
+	return offset | uint64(size)<<48 | uint64(op)<<56
+}
+
+// atomicImmediateOffsetMask is the mask of the offset encoded by atomicImmediate.
+const atomicImmediateOffsetMask = 1<<48 - 1
+
+// atomicResultType returns the runtimeValueType of the result of atomic operations of the given type.
+func atomicResultType(t wazeroir.UnsignedInt) runtimeValueType {
+	if t == wazeroir.UnsignedInt32 {
+		return runtimeValueTypeI32
+	}
+	return runtimeValueTypeI64
+}
+
+// atomicAddress returns the effective address of the atomic operation encoded by atomicImmediate, after checking its
+// boundary and alignment.
+func (ce *callEngine) atomicAddress(mem *wasm.MemoryInstance, imm, addr uint64) (offset uint64, size uint32, op wasm.AtomicArithmeticOp) {
+	size, op = uint32(imm>>48)&0xff, wasm.AtomicArithmeticOp(imm>>56)
+	if !mem.Is64 {
+		addr = uint64(uint32(addr))
+	}
+	ea := addr + imm&atomicImmediateOffsetMask
+	if ea < addr || ea+uint64(size) < ea || ea+uint64(size) > mem.Size64() {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	if ea%uint64(size) != 0 {
+		panic(wasmruntime.ErrRuntimeUnalignedAtomic)
+	}
+	return ea, size, op
+}
+
+func (ce *callEngine) builtinFunctionAtomicMemoryWait(mem *wasm.MemoryInstance) {
+	imm, timeout, exp, addr := ce.popValue(), int64(ce.popValue()), ce.popValue(), ce.popValue()
+	offset, size, _ := ce.atomicAddress(mem, imm, addr)
+	if !mem.Shared {
+		panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+	}
+	if size == 4 {
+		ce.pushValue(mem.Wait32(offset, uint32(exp), timeout))
+	} else {
+		ce.pushValue(mem.Wait64(offset, exp, timeout))
+	}
+}
+
+func (ce *callEngine) builtinFunctionAtomicMemoryNotify(mem *wasm.MemoryInstance) {
+	imm, count, addr := ce.popValue(), ce.popValue(), ce.popValue()
+	offset, _, _ := ce.atomicAddress(mem, imm, addr)
+	// Notify on an unshared memory always returns zero as there can't be any waiter.
+	ce.pushValue(uint64(mem.Notify(offset, uint32(count))))
+}
+
+func (ce *callEngine) builtinFunctionAtomicLoad(mem *wasm.MemoryInstance) {
+	imm, addr := ce.popValue(), ce.popValue()
+	offset, size, _ := ce.atomicAddress(mem, imm, addr)
+	ce.pushValue(mem.AtomicLoad(offset, size))
+}
+
+func (ce *callEngine) builtinFunctionAtomicStore(mem *wasm.MemoryInstance) {
+	imm, val, addr := ce.popValue(), ce.popValue(), ce.popValue()
+	offset, size, _ := ce.atomicAddress(mem, imm, addr)
+	mem.AtomicStore(offset, size, val)
+}
+
+func (ce *callEngine) builtinFunctionAtomicRMW(mem *wasm.MemoryInstance) {
+	imm, val, addr := ce.popValue(), ce.popValue(), ce.popValue()
+	offset, size, op := ce.atomicAddress(mem, imm, addr)
+	ce.pushValue(mem.AtomicArithmetic(offset, size, op, val))
+}
+
+func (ce *callEngine) builtinFunctionAtomicRMWCmpxchg(mem *wasm.MemoryInstance) {
+	imm, replacement, exp, addr := ce.popValue(), ce.popValue(), ce.popValue(), ce.popValue()
+	offset, size, _ := ce.atomicAddress(mem, imm, addr)
+	ce.pushValue(mem.AtomicCompareAndSwap(offset, size, exp, replacement))
+}
+
 func (ce *callEngine) builtinFunctionTableGrow(tables []*wasm.TableInstance) {
 	tableIndex := uint32(ce.popValue())
 	table := tables[tableIndex] // verified not to be out of range by the func validation at compilation phase.
@@ -1025,7 +1580,8 @@ func (ce *callEngine) builtinFunctionTableGrow(tables []*wasm.TableInstance) {
 
 func (ce *callEngine) builtinFunctionFunctionListenerBefore(ctx context.Context, mod api.Module, fn *function) {
 	base := int(ce.stackBasePointerInBytes >> 3)
-	listerCtx := fn.parent.listener.Before(ctx, mod, fn.source.Definition, ce.stack[base:base+fn.source.Type.ParamNumInUint64])
+	listerCtx := fn.parent.listener.Before(ctx, mod, fn.source.Definition, ce.stack[base:base+fn.source.Type.ParamNumInUint64],
+		ce.stackIterator.reset(ce, true))
 	prevStackTop := ce.contextStack
 	ce.contextStack = &contextStack{self: ctx, prev: prevStackTop}
 	ce.ctx = listerCtx
@@ -1100,6 +1656,16 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 			err = cmp.compileCall(o)
 		case *wazeroir.OperationCallIndirect:
 			err = cmp.compileCallIndirect(o)
+		case *wazeroir.OperationTailCall:
+			err = cmp.compileTailCall(o)
+		case *wazeroir.OperationTailCallIndirect:
+			err = cmp.compileTailCallIndirect(o)
+		case *wazeroir.OperationCallRef:
+			err = cmp.compileCallRef(o)
+		case *wazeroir.OperationTailCallRef:
+			err = cmp.compileTailCallRef(o)
+		case *wazeroir.OperationRefAsNonNull:
+			err = cmp.compileRefAsNonNull()
 		case *wazeroir.OperationDrop:
 			err = cmp.compileDrop(o)
 		case *wazeroir.OperationSelect:
@@ -1129,7 +1695,7 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 		case *wazeroir.OperationStore32:
 			err = cmp.compileStore32(o)
 		case *wazeroir.OperationMemorySize:
-			err = cmp.compileMemorySize()
+			err = cmp.compileMemorySize(o)
 		case *wazeroir.OperationMemoryGrow:
 			err = cmp.compileMemoryGrow()
 		case *wazeroir.OperationConstI32:
@@ -1239,9 +1805,9 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 		case *wazeroir.OperationMemoryInit:
 			err = cmp.compileMemoryInit(o)
 		case *wazeroir.OperationMemoryCopy:
-			err = cmp.compileMemoryCopy()
+			err = cmp.compileMemoryCopy(o)
 		case *wazeroir.OperationMemoryFill:
-			err = cmp.compileMemoryFill()
+			err = cmp.compileMemoryFill(o)
 		case *wazeroir.OperationTableInit:
 			err = cmp.compileTableInit(o)
 		case *wazeroir.OperationTableCopy:
@@ -1364,6 +1930,42 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 			err = cmp.compileV128ITruncSatFromF(o)
 		case wazeroir.OperationBuiltinFunctionCheckExitCode:
 			err = cmp.compileBuiltinFunctionCheckExitCode()
+		case *wazeroir.OperationConsumeFuel:
+			err = cmp.compileConsumeFuel(o)
+		case *wazeroir.OperationCoverBlock:
+			err = cmp.compileCoverBlock(o)
+		case *wazeroir.OperationAtomicMemoryWait:
+			err = cmp.compileAtomicMemoryWait(o)
+		case *wazeroir.OperationAtomicMemoryNotify:
+			err = cmp.compileAtomicMemoryNotify(o)
+		case wazeroir.OperationAtomicFence:
+			err = cmp.compileAtomicFence()
+		case *wazeroir.OperationAtomicLoad:
+			err = cmp.compileAtomicLoad(o)
+		case *wazeroir.OperationAtomicStore:
+			err = cmp.compileAtomicStore(o)
+		case *wazeroir.OperationAtomicRMW:
+			err = cmp.compileAtomicRMW(o)
+		case *wazeroir.OperationAtomicRMWCmpxchg:
+			err = cmp.compileAtomicRMWCmpxchg(o)
+		case *wazeroir.OperationSelectMemory:
+			err = cmp.compileSelectMemory(o)
+		case *wazeroir.OperationThrow:
+			err = cmp.compileThrow(o)
+		case *wazeroir.OperationRethrow:
+			err = cmp.compileRethrow()
+		case *wazeroir.OperationExceptionPending:
+			err = cmp.compileExceptionPending()
+		case *wazeroir.OperationExceptionMatch:
+			err = cmp.compileExceptionMatch(o)
+		case *wazeroir.OperationCatch:
+			err = cmp.compileCatch(o)
+		case *wazeroir.OperationGC:
+			err = cmp.compileGC(o)
+		case *wazeroir.OperationV128RelaxedMadd:
+			err = cmp.compileV128RelaxedMadd(o)
+		case *wazeroir.OperationV128RelaxedDot:
+			err = cmp.compileV128RelaxedDot(o)
 		default:
 			err = errors.New("unsupported")
 		}
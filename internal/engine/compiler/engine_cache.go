@@ -21,9 +21,9 @@ func (e *engine) deleteCodes(module *wasm.Module) {
 	// the content is up to the implementation of extencache.Cache interface.
 }
 
-func (e *engine) addCodes(module *wasm.Module, codes []*code, withGoFunc bool) (err error) {
+func (e *engine) addCodes(module *wasm.Module, codes []*code, memoryOnly bool) (err error) {
 	e.addCodesToMemory(module, codes)
-	if !withGoFunc {
+	if !memoryOnly {
 		err = e.addCodesToCache(module, codes)
 	}
 	return
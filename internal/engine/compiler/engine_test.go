@@ -75,6 +75,8 @@ func TestCompiler_ModuleEngine_Call(t *testing.T) {
 	require.Equal(t, `
 --> .$0(1,2)
 <-- (1,2)
+--> .$0(1,2)
+<-- (1,2)
 `, "\n"+functionLog.String())
 }
 
@@ -180,11 +182,11 @@ func TestCompiler_CompileModule(t *testing.T) {
 			ID: wasm.ModuleID{},
 		}
 
-		err := e.CompileModule(testCtx, okModule, nil, false)
+		err := e.CompileModule(testCtx, okModule, nil, false, false, false)
 		require.NoError(t, err)
 
 		// Compiling same module shouldn't be compiled again, but instead should be cached.
-		err = e.CompileModule(testCtx, okModule, nil, false)
+		err = e.CompileModule(testCtx, okModule, nil, false, false, false)
 		require.NoError(t, err)
 
 		compiled, ok := e.codes[okModule.ID]
@@ -211,7 +213,7 @@ func TestCompiler_CompileModule(t *testing.T) {
 		errModule.BuildFunctionDefinitions()
 
 		e := et.NewEngine(api.CoreFeaturesV1).(*engine)
-		err := e.CompileModule(testCtx, errModule, nil, false)
+		err := e.CompileModule(testCtx, errModule, nil, false, false, false)
 		require.EqualError(t, err, "failed to lower func[.$2] to wazeroir: handling instruction: apply stack failed for call: reading immediates: EOF")
 
 		// On the compilation failure, the compiled functions must not be cached.
@@ -261,7 +263,7 @@ func TestCompiler_SliceAllocatedOnHeap(t *testing.T) {
 	}}, map[string]*wasm.HostFuncNames{hostFnName: {}}, enabledFeatures)
 	require.NoError(t, err)
 
-	err = s.Engine.CompileModule(testCtx, hm, nil, false)
+	err = s.Engine.CompileModule(testCtx, hm, nil, false, false, false)
 	require.NoError(t, err)
 
 	_, err = s.Instantiate(testCtx, hm, hostModuleName, nil)
@@ -317,7 +319,7 @@ func TestCompiler_SliceAllocatedOnHeap(t *testing.T) {
 	}
 	m.BuildFunctionDefinitions()
 
-	err = s.Engine.CompileModule(testCtx, m, nil, false)
+	err = s.Engine.CompileModule(testCtx, m, nil, false, false, false)
 	require.NoError(t, err)
 
 	mi, err := s.Instantiate(testCtx, m, t.Name(), nil)
@@ -636,7 +638,7 @@ type mockListener struct {
 	after  func(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error, resultValues []uint64)
 }
 
-func (m mockListener) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64) context.Context {
+func (m mockListener) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64, _ experimental.StackIterator) context.Context {
 	return m.before(ctx, mod, def, paramValues)
 }
 
@@ -97,6 +97,10 @@ type amd64Compiler struct {
 	// onStackPointerCeilDeterminedCallBack hold a callback which are called when the max stack pointer is determined BEFORE generating native code.
 	onStackPointerCeilDeterminedCallBack func(stackPointerCeil uint64)
 	withListener                         bool
+	// relaxedSIMDNative is true when the instructions of api.CoreFeatureRelaxedSIMD can be lowered to the native ones.
+	relaxedSIMDNative bool
+	// memoryIndex is the index of the memory selected by the last wazeroir.OperationSelectMemory.
+	memoryIndex uint32
 }
 
 func newAmd64Compiler() compiler {
@@ -108,7 +112,7 @@ func newAmd64Compiler() compiler {
 	return c
 }
 
-func (c *amd64Compiler) Init(ir *wazeroir.CompilationResult, withListener bool) {
+func (c *amd64Compiler) Init(ir *wazeroir.CompilationResult, withListener, relaxedSIMDNative bool) {
 	assembler, vstack := c.assembler, c.locationStack
 	assembler.Reset()
 	vstack.reset()
@@ -118,6 +122,8 @@ func (c *amd64Compiler) Init(ir *wazeroir.CompilationResult, withListener bool)
 		cpuFeatures:  c.cpuFeatures,
 		withListener: withListener,
 		currentLabel: wazeroir.EntrypointLabel,
+
+		relaxedSIMDNative: relaxedSIMDNative,
 	}
 	c.assembler, c.locationStack = assembler, vstack
 }
@@ -182,6 +188,64 @@ func (c *amd64Compiler) compileBuiltinFunctionCheckExitCode() error {
 	return nil
 }
 
+// compileConsumeFuel implements compiler.compileConsumeFuel for the amd64 architecture.
+func (c *amd64Compiler) compileConsumeFuel(o *wazeroir.OperationConsumeFuel) error {
+	fuelAddress, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(fuelAddress)
+
+	fuel, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// fuel = *ce.exitContext.fuel - o.Cost
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineExitContextFuelOffset, fuelAddress)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, fuelAddress, 0, fuel)
+	c.assembler.CompileConstToRegister(amd64.ADDQ, -int64(o.Cost), fuel)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, fuel, fuelAddress, 0)
+
+	// Exit with nativeCallStatusCodeFuelExhausted if the remaining fuel became negative.
+	jmpIfNotExhausted := c.assembler.CompileJump(amd64.JGE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeFuelExhausted)
+	c.assembler.SetJumpTargetOnNext(jmpIfNotExhausted)
+
+	c.locationStack.markRegisterUnused(fuelAddress)
+	return nil
+}
+
+// compileCoverBlock implements compiler.compileCoverBlock for the amd64 architecture.
+func (c *amd64Compiler) compileCoverBlock(o *wazeroir.OperationCoverBlock) error {
+	counters, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(counters)
+
+	count, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// counters = &ce.moduleContext.fn.parent.coverage[0]
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset, counters)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, counters, functionParentOffset, counters)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, counters, codeCoverageOffset, counters)
+
+	// counters[o.Index]++
+	offset := int64(o.Index) * 8
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, counters, offset, count)
+	c.assembler.CompileConstToRegister(amd64.ADDQ, 1, count)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, count, counters, offset)
+
+	c.locationStack.markRegisterUnused(counters)
+	return nil
+}
+
 // compileGoDefinedHostFunction constructs the entire code to enter the host function implementation,
 // and return to the caller.
 func (c *amd64Compiler) compileGoDefinedHostFunction() error {
@@ -294,7 +358,7 @@ func (c *amd64Compiler) compileGlobalGet(o *wazeroir.OperationGlobalGet) error {
 	case wasm.ValueTypeI32:
 		inst = amd64.MOVL
 		vt = runtimeValueTypeI32
-	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		inst = amd64.MOVQ
 		vt = runtimeValueTypeI64
 	case wasm.ValueTypeF32:
@@ -743,27 +807,48 @@ func (c *amd64Compiler) compileCall(o *wazeroir.OperationCall) error {
 
 // compileCallIndirect implements compiler.compileCallIndirect for the amd64 architecture.
 func (c *amd64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) error {
+	targetAddressRegister, err := c.compileLoadCallIndirectTarget(o.TypeIndex, o.TableIndex)
+	if err != nil {
+		return err
+	}
+
+	targetFunctionType := c.ir.Types[o.TypeIndex]
+	if err = c.compileCallFunctionImpl(targetAddressRegister, targetFunctionType); err != nil {
+		return err
+	}
+
+	// The target register should be marked as un-used as we consumed in the function call.
+	c.locationStack.markRegisterUnused(targetAddressRegister)
+	return nil
+}
+
+// compileLoadCallIndirectTarget pops the offset in the table from the stack, and adds instructions to load the
+// address of the *function at Tables[tableIndex][offset] into the returned register after checking that the
+// target is initialized, and its type matches the one at typeIndex.
+//
+// Note: the returned register is marked used, so the caller must mark it unused after the call.
+func (c *amd64Compiler) compileLoadCallIndirectTarget(typeIndex, tableIndex uint32) (asm.Register, error) {
 	offset := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(offset); err != nil {
-		return nil
+		return asm.NilRegister, err
 	}
 
 	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, err
 	}
 	c.locationStack.markRegisterUsed(tmp)
 
 	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, err
 	}
 	c.locationStack.markRegisterUsed(tmp2)
 
 	// Load the address of the target table: tmp = &module.Tables[0]
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ, amd64ReservedRegisterForCallEngine, callEngineModuleContextTablesElement0AddressOffset, tmp)
-	// tmp = &module.Tables[0] + Index*8 = &module.Tables[0] + sizeOf(*TableInstance)*index = module.Tables[o.TableIndex].
-	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, int64(o.TableIndex*8), tmp)
+	// tmp = &module.Tables[0] + Index*8 = &module.Tables[0] + sizeOf(*TableInstance)*index = module.Tables[tableIndex].
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, int64(tableIndex*8), tmp)
 
 	// Then, we need to check if the offset doesn't exceed the length of table.
 	c.assembler.CompileMemoryToRegister(amd64.CMPQ, tmp, tableInstanceTableLenOffset, offset.register)
@@ -799,16 +884,63 @@ func (c *amd64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) e
 
 	c.assembler.SetJumpTargetOnNext(jumpIfInitialized)
 
+	// The temporary registers are no longer necessary, but offset.register now holds the target address.
+	c.locationStack.markRegisterUnused(tmp, tmp2)
+	c.locationStack.markRegisterUsed(offset.register)
+
 	// next we need to check the type matches, i.e. table[offset].source.TypeID == targetFunctionType's typeID.
-	//
-	// "tmp = table[offset].source ( == *FunctionInstance type)"
-	c.assembler.CompileMemoryToRegister(amd64.MOVQ, offset.register, functionSourceOffset, tmp)
+	if err := c.compileCheckFunctionType(offset.register, typeIndex); err != nil {
+		return asm.NilRegister, err
+	}
+	return offset.register, nil
+}
+
+// compileLoadCallRefTarget pops the function reference from the stack, and adds instructions to load it into the
+// returned register after checking that it is not null, and its type matches the one at typeIndex.
+//
+// Note: the returned register is marked used, so the caller must mark it unused after the call.
+func (c *amd64Compiler) compileLoadCallRefTarget(typeIndex uint32) (asm.Register, error) {
+	ref := c.locationStack.pop()
+	if err := c.compileEnsureOnRegister(ref); err != nil {
+		return asm.NilRegister, err
+	}
+	c.locationStack.markRegisterUsed(ref.register)
+
+	// Check if the reference equals zero, meaning that it is null.
+	c.assembler.CompileRegisterToConst(amd64.CMPQ, ref.register, 0)
+	jumpIfNotNull := c.assembler.CompileJump(amd64.JNE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeNullReference)
+	c.assembler.SetJumpTargetOnNext(jumpIfNotNull)
+
+	if err := c.compileCheckFunctionType(ref.register, typeIndex); err != nil {
+		return asm.NilRegister, err
+	}
+	return ref.register, nil
+}
+
+// compileCheckFunctionType adds instructions to exit with nativeCallStatusCodeTypeMismatchOnIndirectCall unless the
+// type of the *function at the address in the target register matches the one at typeIndex.
+func (c *amd64Compiler) compileCheckFunctionType(target asm.Register, typeIndex uint32) error {
+	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmp)
+
+	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmp2)
+
+	// "tmp = target.source ( == *FunctionInstance type)"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, target, functionSourceOffset, tmp)
 
 	// "tmp2 = [&moduleInstance.TypeIDs[0] + index * 4] (== moduleInstance.TypeIDs[index])"
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
 		amd64ReservedRegisterForCallEngine, callEngineModuleContextTypeIDsElement0AddressOffset,
 		tmp2)
-	c.assembler.CompileMemoryToRegister(amd64.MOVL, tmp2, int64(o.TypeIndex)*4, tmp2)
+	c.assembler.CompileMemoryToRegister(amd64.MOVL, tmp2, int64(typeIndex)*4, tmp2)
 
 	// Jump if the type matches.
 	c.assembler.CompileMemoryToRegister(amd64.CMPL, tmp, functionInstanceTypeIDOffset, tmp2)
@@ -818,13 +950,226 @@ func (c *amd64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) e
 	c.compileExitFromNativeCode(nativeCallStatusCodeTypeMismatchOnIndirectCall)
 
 	c.assembler.SetJumpTargetOnNext(jumpIfTypeMatch)
+
+	c.locationStack.markRegisterUnused(tmp, tmp2)
+	return nil
+}
+
+// compileCallRef implements compiler.compileCallRef for the amd64 architecture.
+func (c *amd64Compiler) compileCallRef(o *wazeroir.OperationCallRef) error {
+	targetAddressRegister, err := c.compileLoadCallRefTarget(o.TypeIndex)
+	if err != nil {
+		return err
+	}
+
 	targetFunctionType := c.ir.Types[o.TypeIndex]
-	if err = c.compileCallFunctionImpl(offset.register, targetFunctionType); err != nil {
-		return nil
+	if err = c.compileCallFunctionImpl(targetAddressRegister, targetFunctionType); err != nil {
+		return err
+	}
+
+	// The target register should be marked as un-used as we consumed in the function call.
+	c.locationStack.markRegisterUnused(targetAddressRegister)
+	return nil
+}
+
+// compileTailCallRef implements compiler.compileTailCallRef for the amd64 architecture.
+func (c *amd64Compiler) compileTailCallRef(o *wazeroir.OperationTailCallRef) error {
+	targetType := c.ir.Types[o.TypeIndex]
+
+	if c.withListener {
+		// See the comment in compileTailCall.
+		if err := c.compileCallRef(&wazeroir.OperationCallRef{TypeIndex: o.TypeIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(targetType)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetAddressRegister, err := c.compileLoadCallRefTarget(o.TypeIndex)
+	if err != nil {
+		return err
+	}
+
+	return c.compileTailCallFunctionImpl(targetAddressRegister, targetType, callFrame)
+}
+
+// compileRefAsNonNull implements compiler.compileRefAsNonNull for the amd64 architecture.
+func (c *amd64Compiler) compileRefAsNonNull() error {
+	ref := c.locationStack.peek()
+	if err := c.compileEnsureOnRegister(ref); err != nil {
+		return err
+	}
+
+	// Exit with nativeCallStatusCodeNullReference if the reference equals zero, i.e. null.
+	c.assembler.CompileRegisterToConst(amd64.CMPQ, ref.register, 0)
+	jumpIfNotNull := c.assembler.CompileJump(amd64.JNE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeNullReference)
+	c.assembler.SetJumpTargetOnNext(jumpIfNotNull)
+	return nil
+}
+
+// compileTailCall implements compiler.compileTailCall for the amd64 architecture.
+func (c *amd64Compiler) compileTailCall(o *wazeroir.OperationTailCall) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	target := c.ir.Functions[o.FunctionIndex]
+	targetType := c.ir.Types[target]
+
+	if c.withListener {
+		// The listener must be notified when this function returns, so the current frame cannot be reused.
+		// Instead, this is compiled as a usual call followed by the return.
+		if err := c.compileCall(&wazeroir.OperationCall{FunctionIndex: o.FunctionIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(targetType)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetAddressRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(targetAddressRegister)
+
+	// targetAddressRegister = &callEngine.functions[0] + o.FunctionIndex*functionSize.
+	c.assembler.CompileConstToRegister(amd64.MOVQ, int64(o.FunctionIndex)*functionSize, targetAddressRegister)
+	c.assembler.CompileMemoryToRegister(amd64.ADDQ, amd64ReservedRegisterForCallEngine,
+		callEngineModuleContextFunctionsElement0AddressOffset, targetAddressRegister)
+
+	return c.compileTailCallFunctionImpl(targetAddressRegister, targetType, callFrame)
+}
+
+// compileTailCallIndirect implements compiler.compileTailCallIndirect for the amd64 architecture.
+func (c *amd64Compiler) compileTailCallIndirect(o *wazeroir.OperationTailCallIndirect) error {
+	targetType := c.ir.Types[o.TypeIndex]
+
+	if c.withListener {
+		// See the comment in compileTailCall.
+		if err := c.compileCallIndirect(&wazeroir.OperationCallIndirect{TypeIndex: o.TypeIndex, TableIndex: o.TableIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(targetType)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetAddressRegister, err := c.compileLoadCallIndirectTarget(o.TypeIndex, o.TableIndex)
+	if err != nil {
+		return err
+	}
+
+	return c.compileTailCallFunctionImpl(targetAddressRegister, targetType, callFrame)
+}
+
+// compileReturnAfterCall adds instructions to return the results of the function call which has just been made,
+// dropping all the other values in the current frame.
+func (c *amd64Compiler) compileReturnAfterCall(functype *wasm.FunctionType) error {
+	if end := int(c.locationStack.sp) - 1; functype.ResultNumInUint64 <= end {
+		if err := compileDropRange(c, &wazeroir.InclusiveRange{Start: functype.ResultNumInUint64, End: end}); err != nil {
+			return err
+		}
+	}
+	return c.compileReturnFunction()
+}
+
+// compileLoadCallFrame adds instructions to load the callFrame of the current function into the returned registers,
+// so that it can be passed to the callee of a tail call, which reuses the current function's frame.
+//
+// Note: the returned registers are marked used, and compileTailCallFunctionImpl marks them unused.
+func (c *amd64Compiler) compileLoadCallFrame() (callFrame [callFrameDataSizeInUint64]asm.Register, err error) {
+	returnAddress, callerStackBasePointerInBytes, callerFunction := c.locationStack.getCallFrameLocations(c.ir.Signature)
+	for i, loc := range []*runtimeValueLocation{returnAddress, callerStackBasePointerInBytes, callerFunction} {
+		var reg asm.Register
+		reg, err = c.allocateRegister(registerTypeGeneralPurpose)
+		if err != nil {
+			return
+		}
+		c.locationStack.markRegisterUsed(reg)
+		c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+			amd64ReservedRegisterForStackBasePointerAddress, int64(loc.stackPointer)*8, reg)
+		callFrame[i] = reg
+	}
+	return
+}
+
+// compileTailCallFunctionImpl adds instructions to call a function whose address equals the value on
+// functionAddressRegister by reusing the current function's frame, assuming that only the arguments to the function
+// remain on the stack.
+//
+// The callee's callFrame is initialized with the values on callFrame registers, which are loaded from the current
+// function's one with compileLoadCallFrame. Therefore, the callee directly returns to the caller of the current
+// function, and the stack doesn't grow regardless of the depth of tail calls.
+func (c *amd64Compiler) compileTailCallFunctionImpl(functionAddressRegister asm.Register, functype *wasm.FunctionType,
+	callFrame [callFrameDataSizeInUint64]asm.Register,
+) error {
+	// Release all the registers as the callee expects the arguments on the stack.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
+		return err
 	}
 
-	// The offset register should be marked as un-used as we consumed in the function call.
-	c.locationStack.markRegisterUnused(offset.register, tmp, tmp2)
+	// The stack should look like the following, where the stack base pointer is not changed:
+	//
+	//               reserved slots for results (if len(results) > len(args))
+	//                      |     |
+	//    ,arg0, ..., argN, ..., _, .returnAddress, .returnStackBasePointerInBytes, .function, ....
+	//      |                       |                                                        |
+	//      |             callFrame{^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^}
+	//      |
+	// stackBasePointer
+	//
+	// where callFrame is the same as the current function's one.
+	offset := int64(callFrameOffset(functype))
+	for i, reg := range callFrame {
+		c.assembler.CompileRegisterToMemory(amd64.MOVQ, reg,
+			amd64ReservedRegisterForStackBasePointerAddress, (offset+int64(i))*8)
+	}
+
+	// Set callEngine.moduleContext.fn to the next *function.
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, functionAddressRegister,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset)
+
+	// All the registers used are temporary, so we mark them unused.
+	c.locationStack.markRegisterUnused(functionAddressRegister)
+	c.locationStack.markRegisterUnused(callFrame[:]...)
+
+	if amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister == functionAddressRegister {
+		// This case we must move the value on functionAddressRegister to another register, otherwise
+		// the address (jump target below) will be modified and result in segfault. See #526.
+		c.assembler.CompileRegisterToRegister(amd64.MOVQ, functionAddressRegister, callFrame[0])
+		functionAddressRegister = callFrame[0]
+	}
+
+	// Also, we have to put the target function's *wasm.ModuleInstance into amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister.
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, functionAddressRegister, functionModuleInstanceAddressOffset,
+		amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister)
+
+	// And jump into the initial address of the target function.
+	c.assembler.CompileJumpToMemory(amd64.JMP, functionAddressRegister, functionCodeInitialAddressOffset)
 	return nil
 }
 
@@ -3213,7 +3558,7 @@ func (c *amd64Compiler) compileLoad(o *wazeroir.OperationLoad) error {
 		vt = runtimeValueTypeF64
 	}
 
-	reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -3246,7 +3591,7 @@ func (c *amd64Compiler) compileLoad(o *wazeroir.OperationLoad) error {
 // compileLoad8 implements compiler.compileLoad8 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad8(o *wazeroir.OperationLoad8) error {
 	const targetSizeInBytes = 1
-	reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -3282,7 +3627,7 @@ func (c *amd64Compiler) compileLoad8(o *wazeroir.OperationLoad8) error {
 // compileLoad16 implements compiler.compileLoad16 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad16(o *wazeroir.OperationLoad16) error {
 	const targetSizeInBytes = 16 / 8
-	reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -3318,7 +3663,7 @@ func (c *amd64Compiler) compileLoad16(o *wazeroir.OperationLoad16) error {
 // compileLoad32 implements compiler.compileLoad32 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad32(o *wazeroir.OperationLoad32) error {
 	const targetSizeInBytes = 32 / 8
-	reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -3344,16 +3689,21 @@ func (c *amd64Compiler) compileLoad32(o *wazeroir.OperationLoad32) error {
 //
 // Note: this also emits the instructions to check the out-of-bounds memory access.
 // In other words, if the ceil exceeds the memory size, the code exits with nativeCallStatusCodeMemoryOutOfBounds status.
-func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSizeInBytes int64) (asm.Register, error) {
+func (c *amd64Compiler) compileMemoryAccessCeilSetup(arg *wazeroir.MemoryArg, targetSizeInBytes int64) (asm.Register, error) {
 	base := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(base); err != nil {
 		return asm.NilRegister, err
 	}
 
 	result := base.register
-	if offsetConst := int64(offsetArg) + targetSizeInBytes; offsetConst <= math.MaxInt32 {
+	offsetConst, ok := memoryAccessOffsetConst(arg, targetSizeInBytes)
+	if !ok {
+		// If the offset const is too large, we exit with nativeCallStatusCodeMemoryOutOfBounds.
+		c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
+		return result, nil
+	} else if offsetConst <= math.MaxInt32 {
 		c.assembler.CompileConstToRegister(amd64.ADDQ, offsetConst, result)
-	} else if offsetConst <= math.MaxUint32 {
+	} else {
 		// Note: in practice, this branch rarely happens as in this case, the wasm binary know that
 		// memory has more than 1 GBi or at least tries to access above 1 GBi memory region.
 		//
@@ -3364,14 +3714,31 @@ func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSiz
 		if err != nil {
 			return asm.NilRegister, err
 		}
-		c.assembler.CompileConstToRegister(amd64.MOVL, int64(uint32(offsetConst)), tmp)
+		if offsetConst <= math.MaxUint32 {
+			c.assembler.CompileConstToRegister(amd64.MOVL, int64(uint32(offsetConst)), tmp)
+		} else { // Only possible in a 64-bit memory.
+			c.assembler.CompileConstToRegister(amd64.MOVQ, offsetConst, tmp)
+		}
 		c.assembler.CompileRegisterToRegister(amd64.ADDQ, tmp, result)
-	} else {
-		// If the offset const is too large, we exit with nativeCallStatusCodeMemoryOutOfBounds.
-		c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
+	}
+
+	// In a 64-bit memory, the base is an arbitrary i64, so the addition above might overflow.
+	var overflowJmp asm.Node
+	if arg.Memory64 {
+		overflowJmp = c.assembler.CompileJump(amd64.JCS)
+	}
+
+	// The accesses out of the bounds of a guarded memory fault, as it is followed by the rest of its reservation, which
+	// the effective address of a 32-bit memory can't exceed. The fault is recovered from by callEngine.memoryFault.
+	if c.ir.GuardedMemory && c.memoryIndex == 0 && !arg.Memory64 {
+		c.locationStack.markRegisterUnused(result)
 		return result, nil
 	}
 
+	if err := c.compileMaybeReloadMemorySliceLen(); err != nil {
+		return asm.NilRegister, err
+	}
+
 	// Now we compare the value with the memory length which is held by callEngine.
 	c.assembler.CompileMemoryToRegister(amd64.CMPQ,
 		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset, result)
@@ -3380,6 +3747,9 @@ func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSiz
 	okJmp := c.assembler.CompileJump(amd64.JCC)
 
 	// Otherwise, we exit the function with out-of-bounds status code.
+	if overflowJmp != nil {
+		c.assembler.SetJumpTargetOnNext(overflowJmp)
+	}
 	c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
 
 	c.assembler.SetJumpTargetOnNext(okJmp)
@@ -3388,6 +3758,24 @@ func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSiz
 	return result, nil
 }
 
+// compileMaybeReloadMemorySliceLen reloads callEngine.moduleContext.memorySliceLen from the memory instance, if the
+// memory might be shared, as other agents might have grown it since the function was entered.
+func (c *amd64Compiler) compileMaybeReloadMemorySliceLen() error {
+	if !c.ir.SharedMemory {
+		return nil
+	}
+	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset, tmp)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, memoryInstanceBufferLenOffset, tmp)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmp,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+	return nil
+}
+
 // compileStore implements compiler.compileStore for the amd64 architecture.
 func (c *amd64Compiler) compileStore(o *wazeroir.OperationStore) error {
 	var movInst asm.Instruction
@@ -3400,31 +3788,31 @@ func (c *amd64Compiler) compileStore(o *wazeroir.OperationStore) error {
 		movInst = amd64.MOVQ
 		targetSizeInByte = 64 / 8
 	}
-	return c.compileStoreImpl(o.Arg.Offset, movInst, targetSizeInByte)
+	return c.compileStoreImpl(o.Arg, movInst, targetSizeInByte)
 }
 
 // compileStore8 implements compiler.compileStore8 for the amd64 architecture.
 func (c *amd64Compiler) compileStore8(o *wazeroir.OperationStore8) error {
-	return c.compileStoreImpl(o.Arg.Offset, amd64.MOVB, 1)
+	return c.compileStoreImpl(o.Arg, amd64.MOVB, 1)
 }
 
 // compileStore32 implements compiler.compileStore32 for the amd64 architecture.
 func (c *amd64Compiler) compileStore16(o *wazeroir.OperationStore16) error {
-	return c.compileStoreImpl(o.Arg.Offset, amd64.MOVW, 16/8)
+	return c.compileStoreImpl(o.Arg, amd64.MOVW, 16/8)
 }
 
 // compileStore32 implements compiler.compileStore32 for the amd64 architecture.
 func (c *amd64Compiler) compileStore32(o *wazeroir.OperationStore32) error {
-	return c.compileStoreImpl(o.Arg.Offset, amd64.MOVL, 32/8)
+	return c.compileStoreImpl(o.Arg, amd64.MOVL, 32/8)
 }
 
-func (c *amd64Compiler) compileStoreImpl(offsetConst uint32, inst asm.Instruction, targetSizeInBytes int64) error {
+func (c *amd64Compiler) compileStoreImpl(arg *wazeroir.MemoryArg, inst asm.Instruction, targetSizeInBytes int64) error {
 	val := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(val); err != nil {
 		return err
 	}
 
-	reg, err := c.compileMemoryAccessCeilSetup(offsetConst, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(arg, targetSizeInBytes)
 	if err != nil {
 		return nil
 	}
@@ -3457,16 +3845,23 @@ func (c *amd64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compiler.compileMemorySize for the amd64 architecture.
-func (c *amd64Compiler) compileMemorySize() error {
+func (c *amd64Compiler) compileMemorySize(o *wazeroir.OperationMemorySize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
+	if err := c.compileMaybeReloadMemorySliceLen(); err != nil {
+		return err
+	}
 
 	reg, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
 		return err
 	}
-	loc := c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	vt := runtimeValueTypeI32
+	if o.Memory64 {
+		vt = runtimeValueTypeI64
+	}
+	loc := c.pushRuntimeValueLocationOnRegister(reg, vt)
 
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ, amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset, loc.register)
 
@@ -3479,6 +3874,12 @@ func (c *amd64Compiler) compileMemorySize() error {
 
 // compileMemoryInit implements compiler.compileMemoryInit for the amd64 architecture.
 func (c *amd64Compiler) compileMemoryInit(o *wazeroir.OperationMemoryInit) error {
+	if o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryInit, uint64(o.DataIndex), 3, runtimeValueTypeNone)
+	}
+	if err := c.compileMaybeReloadMemorySliceLen(); err != nil {
+		return err
+	}
 	return c.compileInitImpl(false, o.DataIndex, 0)
 }
 
@@ -3736,7 +4137,14 @@ func (c *amd64Compiler) compileMemoryCopyLoopImpl(destinationOffset, sourceOffse
 //
 // This uses efficient `REP MOVSQ` instructions to copy in quadword (8 bytes) batches. The remaining bytes
 // are copied with a simple `MOV` loop. It uses backward copying for overlapped segments.
-func (c *amd64Compiler) compileMemoryCopy() error {
+func (c *amd64Compiler) compileMemoryCopy(o *wazeroir.OperationMemoryCopy) error {
+	if o.DestinationMemoryIndex != o.SourceMemoryIndex || o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryCopy, memoryCopyImmediate(o), 3, runtimeValueTypeNone)
+	}
+	if err := c.compileMaybeReloadMemorySliceLen(); err != nil {
+		return err
+	}
+
 	copySize := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(copySize); err != nil {
 		return err
@@ -3949,7 +4357,13 @@ func (c *amd64Compiler) compileFillImpl(isTable bool, tableIndex uint32) error {
 //
 // TODO: the compiled code in this function should be reused and compile at once as
 // the code is independent of any module.
-func (c *amd64Compiler) compileMemoryFill() error {
+func (c *amd64Compiler) compileMemoryFill(o *wazeroir.OperationMemoryFill) error {
+	if o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryFill, 0, 3, runtimeValueTypeNone)
+	}
+	if err := c.compileMaybeReloadMemorySliceLen(); err != nil {
+		return err
+	}
 	return c.compileFillImpl(false, 0)
 }
 
@@ -4214,6 +4628,136 @@ func (c *amd64Compiler) compileTableGrow(o *wazeroir.OperationTableGrow) error {
 	return nil
 }
 
+// compileAtomicMemoryWait implements compiler.compileAtomicMemoryWait for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicMemoryWait(o *wazeroir.OperationAtomicMemoryWait) error {
+	size := uint32(4)
+	if o.Type == wazeroir.UnsignedInt64 {
+		size = 8
+	}
+	// Consumes the address, the expected value and the timeout, then pushes the i32 result.
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicMemoryWait,
+		atomicImmediate(o.Arg, size, 0), 3, runtimeValueTypeI32)
+}
+
+// compileAtomicMemoryNotify implements compiler.compileAtomicMemoryNotify for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicMemoryNotify(o *wazeroir.OperationAtomicMemoryNotify) error {
+	// Consumes the address and the count, then pushes the number of woken waiters.
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicMemoryNotify,
+		atomicImmediate(o.Arg, 4, 0), 2, runtimeValueTypeI32)
+}
+
+// compileAtomicFence implements compiler.compileAtomicFence for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicFence() error {
+	// All atomic operations are done by the builtin functions which are sequentially consistent, so there's nothing
+	// to order here.
+	return nil
+}
+
+// compileAtomicLoad implements compiler.compileAtomicLoad for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicLoad(o *wazeroir.OperationAtomicLoad) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicLoad,
+		atomicImmediate(o.Arg, o.Size, 0), 1, atomicResultType(o.Type))
+}
+
+// compileAtomicStore implements compiler.compileAtomicStore for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicStore(o *wazeroir.OperationAtomicStore) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicStore,
+		atomicImmediate(o.Arg, o.Size, 0), 2, runtimeValueTypeNone)
+}
+
+// compileAtomicRMW implements compiler.compileAtomicRMW for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicRMW(o *wazeroir.OperationAtomicRMW) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicRMW,
+		atomicImmediate(o.Arg, o.Size, o.Op), 2, atomicResultType(o.Type))
+}
+
+// compileAtomicRMWCmpxchg implements compiler.compileAtomicRMWCmpxchg for the amd64 architecture.
+func (c *amd64Compiler) compileAtomicRMWCmpxchg(o *wazeroir.OperationAtomicRMWCmpxchg) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicRMWCmpxchg,
+		atomicImmediate(o.Arg, o.Size, 0), 3, atomicResultType(o.Type))
+}
+
+// compileCallBuiltinFunctionWithImmediate pushes the immediate imm, and calls the builtin function of the given index.
+// The builtin function consumes the immediate and the given number of operands, then pushes the result unless
+// resultType is runtimeValueTypeNone.
+//
+// For example, atomic operations are implemented in Go with this, so that both architectures share the same
+// semantics, including wait and notify which need to block the goroutine.
+func (c *amd64Compiler) compileCallBuiltinFunctionWithImmediate(index wasm.Index, imm uint64, operands int, resultType runtimeValueType) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	if err := c.compileConstI64(&wazeroir.OperationConstI64{Value: imm}); err != nil {
+		return err
+	}
+
+	if err := c.compileCallBuiltinFunction(index); err != nil {
+		return err
+	}
+
+	// Pops the operands and the immediate.
+	for i := 0; i < operands+1; i++ {
+		c.locationStack.pop()
+	}
+
+	if resultType != runtimeValueTypeNone {
+		loc := c.locationStack.pushRuntimeValueLocationOnStack()
+		loc.valueType = resultType
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
+// compileSelectMemory implements compiler.compileSelectMemory for the amd64 architecture.
+func (c *amd64Compiler) compileSelectMemory(o *wazeroir.OperationSelectMemory) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmp)
+
+	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// tmp = ce.moduleContext.moduleInstanceAddress
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceAddressOffset, tmp)
+	if o.MemoryIndex == 0 {
+		// tmp = moduleInstance.Memory
+		c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, moduleInstanceMemoryOffset, tmp)
+	} else {
+		// tmp = &moduleInstance.Memories[0]
+		c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, moduleInstanceMemoriesOffset, tmp)
+		// tmp = moduleInstance.Memories[o.MemoryIndex]
+		c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, int64(o.MemoryIndex)*8, tmp)
+	}
+
+	// Update the memory related fields of moduleContext just like compileModuleContextInitialization does.
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmp,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, memoryInstanceBufferLenOffset, tmp2)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmp2,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmp, memoryInstanceBufferOffset, tmp2)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmp2,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemoryElement0AddressOffset)
+
+	c.locationStack.markRegisterUnused(tmp)
+	c.compileReservedMemoryPointerInitialization()
+	c.memoryIndex = o.MemoryIndex
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the amd64 architecture.
 func (c *amd64Compiler) compileTableSize(o *wazeroir.OperationTableSize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -4579,7 +5123,7 @@ func (c *amd64Compiler) compileCallFunctionImpl(functionAddressRegister asm.Regi
 		switch t {
 		case wasm.ValueTypeI32:
 			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeAnyref:
 			loc.valueType = runtimeValueTypeI64
 		case wasm.ValueTypeF32:
 			loc.valueType = runtimeValueTypeF32
@@ -4929,8 +5473,11 @@ func (c *amd64Compiler) compileModuleContextInitialization() error {
 		// Here we read the value into tmpRegister2.
 		c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmpRegister,
 			amd64ReservedRegisterForCallEngine, callEngineModuleContextTablesElement0AddressOffset)
+	}
 
-		// Finally, we put &ModuleInstance.TypeIDs[0] into moduleContext.typeIDsElement0Address.
+	// Update typeIDsElement0Address, which is used by call_indirect and call_ref.
+	if c.ir.HasTable || c.ir.HasCallRef {
+		// We put &ModuleInstance.TypeIDs[0] into moduleContext.typeIDsElement0Address.
 		c.assembler.CompileMemoryToRegister(amd64.MOVQ,
 			amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister, moduleInstanceTypeIDsOffset, tmpRegister)
 		c.assembler.CompileRegisterToMemory(amd64.MOVQ,
@@ -5084,3 +5631,58 @@ func (c *amd64Compiler) compilePreventCrossedTargetRegisters(locs []*runtimeValu
 		}
 	}
 }
+
+// compileThrow implements compiler.compileThrow for the amd64 architecture.
+func (c *amd64Compiler) compileThrow(o *wazeroir.OperationThrow) error {
+	payload := c.ir.Types[c.ir.Tags[o.TagIndex]].ParamNumInUint64
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexThrow, uint64(o.TagIndex), payload, runtimeValueTypeNone)
+}
+
+// compileRethrow implements compiler.compileRethrow for the amd64 architecture.
+func (c *amd64Compiler) compileRethrow() error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexRethrow, 0, 1, runtimeValueTypeNone)
+}
+
+// compileExceptionPending implements compiler.compileExceptionPending for the amd64 architecture.
+func (c *amd64Compiler) compileExceptionPending() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	reg, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// reg = ce.exitContext.exceptionPending
+	c.assembler.CompileMemoryToRegister(amd64.MOVL,
+		amd64ReservedRegisterForCallEngine, callEngineExitContextExceptionPendingOffset, reg)
+	c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	return nil
+}
+
+// compileExceptionMatch implements compiler.compileExceptionMatch for the amd64 architecture.
+func (c *amd64Compiler) compileExceptionMatch(o *wazeroir.OperationExceptionMatch) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexExceptionMatch, uint64(o.TagIndex), 0, runtimeValueTypeI32)
+}
+
+// compileGC implements compiler.compileGC for the amd64 architecture.
+func (c *amd64Compiler) compileGC(o *wazeroir.OperationGC) error {
+	// The field index or the number of values of array.new_fixed doesn't fit in the immediate, so it is pushed first.
+	if err := c.compileConstI64(&wazeroir.OperationConstI64{Value: uint64(o.Index)}); err != nil {
+		return err
+	}
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexGC, gcImmediate(o), o.Operands+1, gcResultType(o))
+}
+
+// compileCatch implements compiler.compileCatch for the amd64 architecture.
+func (c *amd64Compiler) compileCatch(o *wazeroir.OperationCatch) error {
+	// The reference to the exception is pushed followed by the payload.
+	if err := c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexCatch, catchImmediate(o), 0, runtimeValueTypeI64); err != nil {
+		return err
+	}
+	if !o.All {
+		c.locationStack.pushRuntimeValueLocationsOnStack(c.ir.Types[c.ir.Tags[o.TagIndex]].Params)
+	}
+	return nil
+}
@@ -38,7 +38,9 @@ func newArm64Compiler() compiler {
 	}
 }
 
-func (c *arm64Compiler) Init(ir *wazeroir.CompilationResult, withListener bool) {
+// Init implements compiler.Init. The instructions of api.CoreFeatureRelaxedSIMD are always deterministic on arm64, so
+// relaxedSIMDNative is ignored.
+func (c *arm64Compiler) Init(ir *wazeroir.CompilationResult, withListener, _ bool) {
 	assembler, vstack := c.assembler, c.locationStack
 	assembler.Reset()
 	vstack.reset()
@@ -85,11 +87,11 @@ var arm64CallingConventionModuleInstanceAddressRegister = arm64.RegR29
 
 const (
 	// arm64CallEngineArchContextCompilerCallReturnAddressOffset is the offset of archContext.nativeCallReturnAddress in callEngine.
-	arm64CallEngineArchContextCompilerCallReturnAddressOffset = 136
+	arm64CallEngineArchContextCompilerCallReturnAddressOffset = 152
 	// arm64CallEngineArchContextMinimum32BitSignedIntOffset is the offset of archContext.minimum32BitSignedIntAddress in callEngine.
-	arm64CallEngineArchContextMinimum32BitSignedIntOffset = 144
+	arm64CallEngineArchContextMinimum32BitSignedIntOffset = 160
 	// arm64CallEngineArchContextMinimum64BitSignedIntOffset is the offset of archContext.minimum64BitSignedIntAddress in callEngine.
-	arm64CallEngineArchContextMinimum64BitSignedIntOffset = 152
+	arm64CallEngineArchContextMinimum64BitSignedIntOffset = 168
 )
 
 func isZeroRegister(r asm.Register) bool {
@@ -431,6 +433,64 @@ func (c *arm64Compiler) compileBuiltinFunctionCheckExitCode() error {
 	return nil
 }
 
+// compileConsumeFuel implements compiler.compileConsumeFuel for the arm64 architecture.
+func (c *arm64Compiler) compileConsumeFuel(o *wazeroir.OperationConsumeFuel) error {
+	fuelAddress, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(fuelAddress)
+
+	fuel, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// fuel = *ce.exitContext.fuel - o.Cost
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineExitContextFuelOffset, fuelAddress)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, fuelAddress, 0, fuel)
+	c.assembler.CompileConstToRegister(arm64.SUBS, int64(o.Cost), fuel)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, fuel, fuelAddress, 0)
+
+	// Exit with nativeCallStatusCodeFuelExhausted if the remaining fuel became negative.
+	brIfNotExhausted := c.assembler.CompileJump(arm64.BCONDGE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeFuelExhausted)
+	c.assembler.SetJumpTargetOnNext(brIfNotExhausted)
+
+	c.markRegisterUnused(fuelAddress)
+	return nil
+}
+
+// compileCoverBlock implements compiler.compileCoverBlock for the arm64 architecture.
+func (c *arm64Compiler) compileCoverBlock(o *wazeroir.OperationCoverBlock) error {
+	counters, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(counters)
+
+	count, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// counters = &ce.moduleContext.fn.parent.coverage[0]
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset, counters)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, counters, functionParentOffset, counters)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, counters, codeCoverageOffset, counters)
+
+	// counters[o.Index]++
+	offset := int64(o.Index) * 8
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, counters, offset, count)
+	c.assembler.CompileConstToRegister(arm64.ADD, 1, count)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, count, counters, offset)
+
+	c.markRegisterUnused(counters)
+	return nil
+}
+
 // compileLabel implements compiler.compileLabel for the arm64 architecture.
 func (c *arm64Compiler) compileLabel(o *wazeroir.OperationLabel) (skipThisLabel bool) {
 	labelKey := o.Label.String()
@@ -528,7 +588,7 @@ func (c *arm64Compiler) compileGlobalGet(o *wazeroir.OperationGlobalGet) error {
 			ldr = arm64.LDRW
 			vt = runtimeValueTypeI32
 			result = globalAddressReg
-		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 			ldr = arm64.LDRD
 			vt = runtimeValueTypeI64
 			result = globalAddressReg
@@ -589,7 +649,7 @@ func (c *arm64Compiler) compileGlobalSet(o *wazeroir.OperationGlobalSet) error {
 		switch c.ir.Globals[o.Index].ValType {
 		case wasm.ValueTypeI32:
 			str = arm64.STRW
-		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 			str = arm64.STRD
 		case wasm.ValueTypeF32:
 			str = arm64.FSTRS
@@ -1021,7 +1081,7 @@ func (c *arm64Compiler) compileCallImpl(targetFunctionAddressRegister asm.Regist
 		switch t {
 		case wasm.ValueTypeI32:
 			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeAnyref:
 			loc.valueType = runtimeValueTypeI64
 		case wasm.ValueTypeF32:
 			loc.valueType = runtimeValueTypeF32
@@ -1047,16 +1107,37 @@ func (c *arm64Compiler) compileCallImpl(targetFunctionAddressRegister asm.Regist
 
 // compileCallIndirect implements compiler.compileCallIndirect for the arm64 architecture.
 func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (err error) {
+	targetFunctionAddressReg, err := c.compileLoadCallIndirectTarget(o.TypeIndex, o.TableIndex)
+	if err != nil {
+		return err
+	}
+
+	targetFunctionType := c.ir.Types[o.TypeIndex]
+	if err := c.compileCallImpl(targetFunctionAddressReg, targetFunctionType); err != nil {
+		return err
+	}
+
+	// The target register should be marked as un-used as we consumed in the function call.
+	c.markRegisterUnused(targetFunctionAddressReg)
+	return nil
+}
+
+// compileLoadCallIndirectTarget pops the offset in the table from the stack, and adds instructions to load the
+// address of the *function at Tables[tableIndex][offset] into the returned register after checking that the
+// target is initialized, and its type matches the one at typeIndex.
+//
+// Note: the returned register is marked used, so the caller must mark it unused after the call.
+func (c *arm64Compiler) compileLoadCallIndirectTarget(typeIndex, tableIndex uint32) (offsetReg asm.Register, err error) {
 	offset := c.locationStack.pop()
 	if err = c.compileEnsureOnRegister(offset); err != nil {
-		return err
+		return
 	}
 
-	offsetReg := offset.register
+	offsetReg = offset.register
 	if isZeroRegister(offsetReg) {
 		offsetReg, err = c.allocateRegister(registerTypeGeneralPurpose)
 		if err != nil {
-			return err
+			return
 		}
 		c.markRegisterUsed(offsetReg)
 
@@ -1066,13 +1147,13 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (
 
 	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return
 	}
 	c.markRegisterUsed(tmp)
 
 	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return
 	}
 	c.markRegisterUsed(tmp2)
 
@@ -1084,7 +1165,7 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (
 	)
 	// tmp = [tmp + TableIndex*8] = [&Tables[0] + TableIndex*sizeOf(*tableInstance)] = Tables[tableIndex]
 	c.assembler.CompileMemoryToRegister(arm64.LDRD,
-		tmp, int64(o.TableIndex)*8,
+		tmp, int64(tableIndex)*8,
 		tmp,
 	)
 	// tmp2 = [tmp + tableInstanceTableLenOffset] = len(Tables[tableIndex])
@@ -1126,14 +1207,70 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (
 	c.compileExitFromNativeCode(nativeCallStatusCodeInvalidTableAccess)
 
 	c.assembler.SetJumpTargetOnNext(brIfInitialized)
+
+	// The temporary registers are no longer necessary, but offsetReg now holds the target address.
+	c.markRegisterUnused(tmp, tmp2)
+	c.markRegisterUsed(offsetReg)
+
 	// next we check the type matches, i.e. table[offset].source.TypeID == targetFunctionType.
-	// "tmp = table[offset].source ( == *FunctionInstance type)"
+	err = c.compileCheckFunctionType(offsetReg, typeIndex)
+	return
+}
+
+// compileLoadCallRefTarget pops the function reference from the stack, and adds instructions to load it into the
+// returned register after checking that it is not null, and its type matches the one at typeIndex.
+//
+// Note: the returned register is marked used, so the caller must mark it unused after the call.
+func (c *arm64Compiler) compileLoadCallRefTarget(typeIndex uint32) (refReg asm.Register, err error) {
+	ref := c.locationStack.pop()
+	if err = c.compileEnsureOnRegister(ref); err != nil {
+		return
+	}
+
+	refReg = ref.register
+	if isZeroRegister(refReg) {
+		refReg, err = c.allocateRegister(registerTypeGeneralPurpose)
+		if err != nil {
+			return
+		}
+
+		// Zero the value on a picked register.
+		c.assembler.CompileRegisterToRegister(arm64.MOVD, arm64.RegRZR, refReg)
+	}
+	c.markRegisterUsed(refReg)
+
+	// Check if the reference equals zero, meaning that it is null.
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, arm64.RegRZR, refReg)
+	brIfNotNull := c.assembler.CompileJump(arm64.BCONDNE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeNullReference)
+	c.assembler.SetJumpTargetOnNext(brIfNotNull)
+
+	err = c.compileCheckFunctionType(refReg, typeIndex)
+	return
+}
+
+// compileCheckFunctionType adds instructions to exit with nativeCallStatusCodeTypeMismatchOnIndirectCall unless the
+// type of the *function at the address in the target register matches the one at typeIndex.
+func (c *arm64Compiler) compileCheckFunctionType(target asm.Register, typeIndex uint32) error {
+	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmp)
+
+	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmp2)
+
+	// "tmp = target.source ( == *FunctionInstance type)"
 	c.assembler.CompileMemoryToRegister(
 		arm64.LDRD,
-		offsetReg, functionSourceOffset,
+		target, functionSourceOffset,
 		tmp,
 	)
-	// "tmp = [tmp + functionInstanceTypeIDOffset] (== table[offset].source.TypeID)"
+	// "tmp = [tmp + functionInstanceTypeIDOffset] (== target.source.TypeID)"
 	c.assembler.CompileMemoryToRegister(
 		arm64.LDRW, tmp, functionInstanceTypeIDOffset,
 		tmp,
@@ -1142,7 +1279,7 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (
 	c.assembler.CompileMemoryToRegister(arm64.LDRD,
 		arm64ReservedRegisterForCallEngine, callEngineModuleContextTypeIDsElement0AddressOffset,
 		tmp2)
-	c.assembler.CompileMemoryToRegister(arm64.LDRW, tmp2, int64(o.TypeIndex)*4, tmp2)
+	c.assembler.CompileMemoryToRegister(arm64.LDRW, tmp2, int64(typeIndex)*4, tmp2)
 
 	// Compare these two values, and if they equal, we are ready to make function call.
 	c.assembler.CompileTwoRegistersToNone(arm64.CMPW, tmp, tmp2)
@@ -1151,13 +1288,232 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.OperationCallIndirect) (
 
 	c.assembler.SetJumpTargetOnNext(brIfTypeMatched)
 
+	c.markRegisterUnused(tmp, tmp2)
+	return nil
+}
+
+// compileCallRef implements compiler.compileCallRef for the arm64 architecture.
+func (c *arm64Compiler) compileCallRef(o *wazeroir.OperationCallRef) error {
+	targetFunctionAddressReg, err := c.compileLoadCallRefTarget(o.TypeIndex)
+	if err != nil {
+		return err
+	}
+
 	targetFunctionType := c.ir.Types[o.TypeIndex]
-	if err := c.compileCallImpl(offsetReg, targetFunctionType); err != nil {
+	if err := c.compileCallImpl(targetFunctionAddressReg, targetFunctionType); err != nil {
+		return err
+	}
+
+	// The target register should be marked as un-used as we consumed in the function call.
+	c.markRegisterUnused(targetFunctionAddressReg)
+	return nil
+}
+
+// compileTailCallRef implements compiler.compileTailCallRef for the arm64 architecture.
+func (c *arm64Compiler) compileTailCallRef(o *wazeroir.OperationTailCallRef) error {
+	tp := c.ir.Types[o.TypeIndex]
+
+	if c.withListener {
+		// See the comment in compileTailCall.
+		if err := c.compileCallRef(&wazeroir.OperationCallRef{TypeIndex: o.TypeIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(tp)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetFunctionAddressReg, err := c.compileLoadCallRefTarget(o.TypeIndex)
+	if err != nil {
+		return err
+	}
+
+	return c.compileTailCallImpl(targetFunctionAddressReg, tp, callFrame)
+}
+
+// compileRefAsNonNull implements compiler.compileRefAsNonNull for the arm64 architecture.
+func (c *arm64Compiler) compileRefAsNonNull() error {
+	ref := c.locationStack.peek()
+	if err := c.compileEnsureOnRegister(ref); err != nil {
+		return err
+	}
+
+	// Exit with nativeCallStatusCodeNullReference if the reference equals zero, i.e. null.
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, arm64.RegRZR, ref.register)
+	brIfNotNull := c.assembler.CompileJump(arm64.BCONDNE)
+	c.compileExitFromNativeCode(nativeCallStatusCodeNullReference)
+	c.assembler.SetJumpTargetOnNext(brIfNotNull)
+	return nil
+}
+
+// compileTailCall implements compiler.compileTailCall for the arm64 architecture.
+func (c *arm64Compiler) compileTailCall(o *wazeroir.OperationTailCall) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tp := c.ir.Types[c.ir.Functions[o.FunctionIndex]]
+
+	if c.withListener {
+		// The listener must be notified when this function returns, so the current frame cannot be reused.
+		// Instead, this is compiled as a usual call followed by the return.
+		if err := c.compileCall(&wazeroir.OperationCall{FunctionIndex: o.FunctionIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(tp)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetFunctionAddressReg, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(targetFunctionAddressReg)
+
+	// targetFunctionAddressReg = &ce.functions[0] + o.FunctionIndex*functionSize.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextFunctionsElement0AddressOffset,
+		targetFunctionAddressReg)
+	c.assembler.CompileConstToRegister(arm64.ADD, int64(o.FunctionIndex)*functionSize, targetFunctionAddressReg)
+
+	return c.compileTailCallImpl(targetFunctionAddressReg, tp, callFrame)
+}
+
+// compileTailCallIndirect implements compiler.compileTailCallIndirect for the arm64 architecture.
+func (c *arm64Compiler) compileTailCallIndirect(o *wazeroir.OperationTailCallIndirect) error {
+	tp := c.ir.Types[o.TypeIndex]
+
+	if c.withListener {
+		// See the comment in compileTailCall.
+		if err := c.compileCallIndirect(&wazeroir.OperationCallIndirect{TypeIndex: o.TypeIndex, TableIndex: o.TableIndex}); err != nil {
+			return err
+		}
+		return c.compileReturnAfterCall(tp)
+	}
+
+	callFrame, err := c.compileLoadCallFrame()
+	if err != nil {
+		return err
+	}
+
+	if err = compileDropRange(c, o.Drop); err != nil {
+		return err
+	}
+
+	targetFunctionAddressReg, err := c.compileLoadCallIndirectTarget(o.TypeIndex, o.TableIndex)
+	if err != nil {
+		return err
+	}
+
+	return c.compileTailCallImpl(targetFunctionAddressReg, tp, callFrame)
+}
+
+// compileReturnAfterCall adds instructions to return the results of the function call which has just been made,
+// dropping all the other values in the current frame.
+func (c *arm64Compiler) compileReturnAfterCall(functype *wasm.FunctionType) error {
+	if end := int(c.locationStack.sp) - 1; functype.ResultNumInUint64 <= end {
+		if err := compileDropRange(c, &wazeroir.InclusiveRange{Start: functype.ResultNumInUint64, End: end}); err != nil {
+			return err
+		}
+	}
+	return c.compileReturnFunction()
+}
+
+// compileLoadCallFrame adds instructions to load the callFrame of the current function into the returned registers,
+// so that it can be passed to the callee of a tail call, which reuses the current function's frame.
+//
+// Note: the returned registers are marked used, and compileTailCallImpl marks them unused.
+func (c *arm64Compiler) compileLoadCallFrame() (callFrame [callFrameDataSizeInUint64]asm.Register, err error) {
+	returnAddress, callerStackBasePointerInBytes, callerFunction := c.locationStack.getCallFrameLocations(c.ir.Signature)
+	for i, loc := range []*runtimeValueLocation{returnAddress, callerStackBasePointerInBytes, callerFunction} {
+		var reg asm.Register
+		reg, err = c.allocateRegister(registerTypeGeneralPurpose)
+		if err != nil {
+			return
+		}
+		c.markRegisterUsed(reg)
+		c.assembler.CompileMemoryToRegister(arm64.LDRD,
+			arm64ReservedRegisterForStackBasePointerAddress, int64(loc.stackPointer)*8, reg)
+		callFrame[i] = reg
+	}
+	return
+}
+
+// compileTailCallImpl adds instructions to call a function whose address equals the value on
+// targetFunctionAddressRegister by reusing the current function's frame, assuming that only the arguments to the
+// function remain on the stack.
+//
+// The callee's callFrame is initialized with the values on callFrame registers, which are loaded from the current
+// function's one with compileLoadCallFrame. Therefore, the callee directly returns to the caller of the current
+// function, and the stack doesn't grow regardless of the depth of tail calls.
+func (c *arm64Compiler) compileTailCallImpl(targetFunctionAddressRegister asm.Register, functype *wasm.FunctionType,
+	callFrame [callFrameDataSizeInUint64]asm.Register,
+) error {
+	// Release all the registers as the callee expects the arguments on the stack.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
 		return err
 	}
 
-	// The offset register should be marked as un-used as we consumed in the function call.
-	c.markRegisterUnused(offsetReg, tmp, tmp2)
+	// The stack should look like the following, where the stack base pointer is not changed:
+	//
+	//               reserved slots for results (if len(results) > len(args))
+	//                      |     |
+	//    ,arg0, ..., argN, ..., _, .returnAddress, .returnStackBasePointerInBytes, .function, ....
+	//      |                       |                                                        |
+	//      |             callFrame{^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^}
+	//      |
+	// stackBasePointer
+	//
+	// where callFrame is the same as the current function's one.
+	offset := int64(callFrameOffset(functype))
+	for i, reg := range callFrame {
+		c.assembler.CompileRegisterToMemory(arm64.STRD, reg,
+			arm64ReservedRegisterForStackBasePointerAddress, (offset+int64(i))*8)
+	}
+
+	// Set callEngine.moduleContext.fn to the next *function.
+	c.assembler.CompileRegisterToMemory(arm64.STRD,
+		targetFunctionAddressRegister,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset)
+
+	// All the registers used are temporary, so we mark them unused.
+	c.markRegisterUnused(targetFunctionAddressRegister)
+	c.markRegisterUnused(callFrame[:]...)
+
+	if targetFunctionAddressRegister == arm64CallingConventionModuleInstanceAddressRegister {
+		// This case we must move the value on targetFunctionAddressRegister to another register, otherwise
+		// the address (jump target below) will be modified and result in segfault. See #526.
+		c.assembler.CompileRegisterToRegister(arm64.MOVD, targetFunctionAddressRegister, callFrame[0])
+		targetFunctionAddressRegister = callFrame[0]
+	}
+
+	// Also, we have to put the code's moduleInstance address into arm64CallingConventionModuleInstanceAddressRegister.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		targetFunctionAddressRegister, functionModuleInstanceAddressOffset,
+		arm64CallingConventionModuleInstanceAddressRegister,
+	)
+
+	// Then, br into the target function's initial address.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		targetFunctionAddressRegister, functionCodeInitialAddressOffset,
+		targetFunctionAddressRegister)
+
+	c.assembler.CompileJumpToRegister(arm64.B, targetFunctionAddressRegister)
 	return nil
 }
 
@@ -2562,7 +2918,7 @@ func (c *arm64Compiler) compileLoad(o *wazeroir.OperationLoad) error {
 		targetSizeInBytes = 64 / 8
 		vt = runtimeValueTypeF64
 	}
-	return c.compileLoadImpl(o.Arg.Offset, loadInst, targetSizeInBytes, isFloat, vt)
+	return c.compileLoadImpl(o.Arg, loadInst, targetSizeInBytes, isFloat, vt)
 }
 
 // compileLoad8 implements compiler.compileLoad8 for the arm64 architecture.
@@ -2583,7 +2939,7 @@ func (c *arm64Compiler) compileLoad8(o *wazeroir.OperationLoad8) error {
 		loadInst = arm64.LDRB
 		vt = runtimeValueTypeI64
 	}
-	return c.compileLoadImpl(o.Arg.Offset, loadInst, 1, false, vt)
+	return c.compileLoadImpl(o.Arg, loadInst, 1, false, vt)
 }
 
 // compileLoad16 implements compiler.compileLoad16 for the arm64 architecture.
@@ -2604,7 +2960,7 @@ func (c *arm64Compiler) compileLoad16(o *wazeroir.OperationLoad16) error {
 		loadInst = arm64.LDRH
 		vt = runtimeValueTypeI64
 	}
-	return c.compileLoadImpl(o.Arg.Offset, loadInst, 16/8, false, vt)
+	return c.compileLoadImpl(o.Arg, loadInst, 16/8, false, vt)
 }
 
 // compileLoad32 implements compiler.compileLoad32 for the arm64 architecture.
@@ -2615,14 +2971,14 @@ func (c *arm64Compiler) compileLoad32(o *wazeroir.OperationLoad32) error {
 	} else {
 		loadInst = arm64.LDRW
 	}
-	return c.compileLoadImpl(o.Arg.Offset, loadInst, 32/8, false, runtimeValueTypeI64)
+	return c.compileLoadImpl(o.Arg, loadInst, 32/8, false, runtimeValueTypeI64)
 }
 
 // compileLoadImpl implements compileLoadImpl* variants for arm64 architecture.
-func (c *arm64Compiler) compileLoadImpl(offsetArg uint32, loadInst asm.Instruction,
+func (c *arm64Compiler) compileLoadImpl(arg *wazeroir.MemoryArg, loadInst asm.Instruction,
 	targetSizeInBytes int64, isFloat bool, resultRuntimeValueType runtimeValueType,
 ) error {
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -2665,26 +3021,26 @@ func (c *arm64Compiler) compileStore(o *wazeroir.OperationStore) error {
 		movInst = arm64.FSTRD
 		targetSizeInBytes = 64 / 8
 	}
-	return c.compileStoreImpl(o.Arg.Offset, movInst, targetSizeInBytes)
+	return c.compileStoreImpl(o.Arg, movInst, targetSizeInBytes)
 }
 
 // compileStore8 implements compiler.compileStore8 for the arm64 architecture.
 func (c *arm64Compiler) compileStore8(o *wazeroir.OperationStore8) error {
-	return c.compileStoreImpl(o.Arg.Offset, arm64.STRB, 1)
+	return c.compileStoreImpl(o.Arg, arm64.STRB, 1)
 }
 
 // compileStore16 implements compiler.compileStore16 for the arm64 architecture.
 func (c *arm64Compiler) compileStore16(o *wazeroir.OperationStore16) error {
-	return c.compileStoreImpl(o.Arg.Offset, arm64.STRH, 16/8)
+	return c.compileStoreImpl(o.Arg, arm64.STRH, 16/8)
 }
 
 // compileStore32 implements compiler.compileStore32 for the arm64 architecture.
 func (c *arm64Compiler) compileStore32(o *wazeroir.OperationStore32) error {
-	return c.compileStoreImpl(o.Arg.Offset, arm64.STRW, 32/8)
+	return c.compileStoreImpl(o.Arg, arm64.STRW, 32/8)
 }
 
 // compileStoreImpl implements compleStore* variants for arm64 architecture.
-func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruction, targetSizeInBytes int64) error {
+func (c *arm64Compiler) compileStoreImpl(arg *wazeroir.MemoryArg, storeInst asm.Instruction, targetSizeInBytes int64) error {
 	val, err := c.popValueOnRegister()
 	if err != nil {
 		return err
@@ -2692,7 +3048,7 @@ func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruc
 	// Mark temporarily used as compileMemoryAccessOffsetSetup might try allocating register.
 	c.markRegisterUsed(val.register)
 
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -2714,7 +3070,8 @@ func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruc
 //
 // Note: this also emits the instructions to check the out of bounds memory access.
 // In other words, if the offset+targetSizeInBytes exceeds the memory size, the code exits with nativeCallStatusCodeMemoryOutOfBounds status.
-func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetSizeInBytes int64) (offsetRegister asm.Register, err error) {
+// Unlike on amd64, this is the case even when ir.GuardedMemory, as recovering from faults is only relied on for amd64.
+func (c *arm64Compiler) compileMemoryAccessOffsetSetup(arg *wazeroir.MemoryArg, targetSizeInBytes int64) (offsetRegister asm.Register, err error) {
 	base, err := c.popValueOnRegister()
 	if err != nil {
 		return 0, err
@@ -2729,15 +3086,23 @@ func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetS
 		c.assembler.CompileRegisterToRegister(arm64.MOVD, arm64.RegRZR, offsetRegister)
 	}
 
-	if offsetConst := int64(offsetArg) + targetSizeInBytes; offsetConst <= math.MaxUint32 {
-		// "offsetRegister = base + offsetArg + targetSizeInBytes"
-		c.assembler.CompileConstToRegister(arm64.ADD, offsetConst, offsetRegister)
-	} else {
+	var overflow asm.Node
+	if offsetConst, ok := memoryAccessOffsetConst(arg, targetSizeInBytes); !ok {
 		// If the offset const is too large, we exit with nativeCallStatusCodeMemoryOutOfBounds.
 		c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
 		return
+	} else if arg.Memory64 {
+		// "offsetRegister = base + offsetArg + targetSizeInBytes", where the base is an arbitrary i64 which might
+		// overflow on the addition.
+		c.assembler.CompileConstToRegister(arm64.ADDS, offsetConst, offsetRegister)
+		overflow = c.assembler.CompileJump(arm64.BCONDHS)
+	} else {
+		// "offsetRegister = base + offsetArg + targetSizeInBytes"
+		c.assembler.CompileConstToRegister(arm64.ADD, offsetConst, offsetRegister)
 	}
 
+	c.compileMaybeReloadMemorySliceLen()
+
 	// "arm64ReservedRegisterForTemporary = len(memory.Buffer)"
 	c.assembler.CompileMemoryToRegister(arm64.LDRD,
 		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset,
@@ -2749,6 +3114,9 @@ func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetS
 
 	// If offsetRegister(= base+offsetArg+targetSizeInBytes) exceeds the memory length,
 	//  we exit the function with nativeCallStatusCodeMemoryOutOfBounds.
+	if overflow != nil {
+		c.assembler.SetJumpTargetOnNext(overflow)
+	}
 	c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
 
 	// Otherwise, we subtract targetSizeInBytes from offsetRegister.
@@ -2757,6 +3125,26 @@ func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetS
 	return offsetRegister, nil
 }
 
+// compileMaybeReloadMemorySliceLen reloads callEngine.moduleContext.memorySliceLen from the memory instance, if the
+// memory might be shared, as other agents might have grown it since the function was entered.
+func (c *arm64Compiler) compileMaybeReloadMemorySliceLen() {
+	if !c.ir.SharedMemory {
+		return
+	}
+	// "arm64ReservedRegisterForTemporary = ce.moduleContext.memoryInstance"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset,
+		arm64ReservedRegisterForTemporary)
+	// "arm64ReservedRegisterForTemporary = len(memoryInstance.Buffer)"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForTemporary, memoryInstanceBufferLenOffset,
+		arm64ReservedRegisterForTemporary)
+	// "ce.moduleContext.memorySliceLen = arm64ReservedRegisterForTemporary"
+	c.assembler.CompileRegisterToMemory(arm64.STRD,
+		arm64ReservedRegisterForTemporary,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+}
+
 // compileMemoryGrow implements compileMemoryGrow variants for arm64 architecture.
 func (c *arm64Compiler) compileMemoryGrow() error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -2774,10 +3162,11 @@ func (c *arm64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compileMemorySize variants for arm64 architecture.
-func (c *arm64Compiler) compileMemorySize() error {
+func (c *arm64Compiler) compileMemorySize(o *wazeroir.OperationMemorySize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
+	c.compileMaybeReloadMemorySliceLen()
 
 	reg, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
@@ -2799,7 +3188,11 @@ func (c *arm64Compiler) compileMemorySize() error {
 		reg,
 	)
 
-	c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	vt := runtimeValueTypeI32
+	if o.Memory64 {
+		vt = runtimeValueTypeI64
+	}
+	c.pushRuntimeValueLocationOnRegister(reg, vt)
 	return nil
 }
 
@@ -2931,6 +3324,10 @@ func (c *arm64Compiler) compileFloatConstant(is32bit bool, value uint64) error {
 
 // compileMemoryInit implements compiler.compileMemoryInit for the arm64 architecture.
 func (c *arm64Compiler) compileMemoryInit(o *wazeroir.OperationMemoryInit) error {
+	if o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryInit, uint64(o.DataIndex), 3, runtimeValueTypeNone)
+	}
+	c.compileMaybeReloadMemorySliceLen()
 	return c.compileInitImpl(false, o.DataIndex, 0)
 }
 
@@ -3152,7 +3549,11 @@ func (c *arm64Compiler) compileLoadDataInstanceAddress(dataIndex uint32, dst asm
 }
 
 // compileMemoryCopy implements compiler.compileMemoryCopy for the arm64 architecture.
-func (c *arm64Compiler) compileMemoryCopy() error {
+func (c *arm64Compiler) compileMemoryCopy(o *wazeroir.OperationMemoryCopy) error {
+	if o.DestinationMemoryIndex != o.SourceMemoryIndex || o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryCopy, memoryCopyImmediate(o), 3, runtimeValueTypeNone)
+	}
+	c.compileMaybeReloadMemorySliceLen()
 	return c.compileCopyImpl(false, 0, 0)
 }
 
@@ -3419,7 +3820,11 @@ func (c *arm64Compiler) compileCopyImpl(isTable bool, srcTableIndex, dstTableInd
 }
 
 // compileMemoryFill implements compiler.compileMemoryCopy for the arm64 architecture.
-func (c *arm64Compiler) compileMemoryFill() error {
+func (c *arm64Compiler) compileMemoryFill(o *wazeroir.OperationMemoryFill) error {
+	if o.Memory64 {
+		return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexMemoryFill, 0, 3, runtimeValueTypeNone)
+	}
+	c.compileMaybeReloadMemorySliceLen()
 	return c.compileFillImpl(false, 0)
 }
 
@@ -3774,6 +4179,135 @@ func (c *arm64Compiler) compileTableGrow(o *wazeroir.OperationTableGrow) error {
 	return nil
 }
 
+// compileAtomicMemoryWait implements compiler.compileAtomicMemoryWait for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicMemoryWait(o *wazeroir.OperationAtomicMemoryWait) error {
+	size := uint32(4)
+	if o.Type == wazeroir.UnsignedInt64 {
+		size = 8
+	}
+	// Consumes the address, the expected value and the timeout, then pushes the i32 result.
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicMemoryWait,
+		atomicImmediate(o.Arg, size, 0), 3, runtimeValueTypeI32)
+}
+
+// compileAtomicMemoryNotify implements compiler.compileAtomicMemoryNotify for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicMemoryNotify(o *wazeroir.OperationAtomicMemoryNotify) error {
+	// Consumes the address and the count, then pushes the number of woken waiters.
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicMemoryNotify,
+		atomicImmediate(o.Arg, 4, 0), 2, runtimeValueTypeI32)
+}
+
+// compileAtomicFence implements compiler.compileAtomicFence for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicFence() error {
+	// All atomic operations are done by the builtin functions which are sequentially consistent, so there's nothing
+	// to order here.
+	return nil
+}
+
+// compileAtomicLoad implements compiler.compileAtomicLoad for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicLoad(o *wazeroir.OperationAtomicLoad) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicLoad,
+		atomicImmediate(o.Arg, o.Size, 0), 1, atomicResultType(o.Type))
+}
+
+// compileAtomicStore implements compiler.compileAtomicStore for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicStore(o *wazeroir.OperationAtomicStore) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicStore,
+		atomicImmediate(o.Arg, o.Size, 0), 2, runtimeValueTypeNone)
+}
+
+// compileAtomicRMW implements compiler.compileAtomicRMW for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicRMW(o *wazeroir.OperationAtomicRMW) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicRMW,
+		atomicImmediate(o.Arg, o.Size, o.Op), 2, atomicResultType(o.Type))
+}
+
+// compileAtomicRMWCmpxchg implements compiler.compileAtomicRMWCmpxchg for the arm64 architecture.
+func (c *arm64Compiler) compileAtomicRMWCmpxchg(o *wazeroir.OperationAtomicRMWCmpxchg) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexAtomicRMWCmpxchg,
+		atomicImmediate(o.Arg, o.Size, 0), 3, atomicResultType(o.Type))
+}
+
+// compileCallBuiltinFunctionWithImmediate pushes the immediate imm, and calls the builtin function of the given index.
+// The builtin function consumes the immediate and the given number of operands, then pushes the result unless
+// resultType is runtimeValueTypeNone.
+//
+// For example, atomic operations are implemented in Go with this, so that both architectures share the same
+// semantics, including wait and notify which need to block the goroutine.
+func (c *arm64Compiler) compileCallBuiltinFunctionWithImmediate(index wasm.Index, imm uint64, operands int, resultType runtimeValueType) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	if err := c.compileConstI64(&wazeroir.OperationConstI64{Value: imm}); err != nil {
+		return err
+	}
+
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, index); err != nil {
+		return err
+	}
+
+	// Pops the operands and the immediate.
+	for i := 0; i < operands+1; i++ {
+		c.locationStack.pop()
+	}
+
+	if resultType != runtimeValueTypeNone {
+		loc := c.locationStack.pushRuntimeValueLocationOnStack()
+		loc.valueType = resultType
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
+// compileSelectMemory implements compiler.compileSelectMemory for the arm64 architecture.
+func (c *arm64Compiler) compileSelectMemory(o *wazeroir.OperationSelectMemory) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpX, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmpX)
+
+	tmpY, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpX = ce.moduleContext.moduleInstanceAddress"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceAddressOffset, tmpX)
+	if o.MemoryIndex == 0 {
+		// "tmpX = moduleInstance.Memory"
+		c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceMemoryOffset, tmpX)
+	} else {
+		// "tmpX = &moduleInstance.Memories[0]"
+		c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceMemoriesOffset, tmpX)
+		// "tmpX = moduleInstance.Memories[o.MemoryIndex]"
+		c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, int64(o.MemoryIndex)*8, tmpX)
+	}
+
+	// Update the memory related fields of moduleContext just like compileModuleContextInitialization does.
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpX,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, memoryInstanceBufferLenOffset, tmpY)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpY,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, memoryInstanceBufferOffset, tmpY)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpY,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemoryElement0AddressOffset)
+
+	c.markRegisterUnused(tmpX)
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the arm64 architecture.
 func (c *arm64Compiler) compileTableSize(o *wazeroir.OperationTableSize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -4128,7 +4662,7 @@ func (c *arm64Compiler) compileModuleContextInitialization() error {
 		)
 	}
 
-	// Update tableElement0Address, tableSliceLen and typeIDsElement0Address.
+	// Update tableElement0Address and tableSliceLen.
 	//
 	// Note: if there's table instruction in the function, the existence of the table
 	// is ensured by function validation at module instantiation phase, and that's
@@ -4148,8 +4682,11 @@ func (c *arm64Compiler) compileModuleContextInitialization() error {
 			tmpX,
 			arm64ReservedRegisterForCallEngine, callEngineModuleContextTablesElement0AddressOffset,
 		)
+	}
 
-		// Finally, we put &ModuleInstance.TypeIDs[0] into moduleContext.typeIDsElement0Address.
+	// Update typeIDsElement0Address, which is used by call_indirect and call_ref.
+	if c.ir.HasTable || c.ir.HasCallRef {
+		// We put &ModuleInstance.TypeIDs[0] into moduleContext.typeIDsElement0Address.
 		c.assembler.CompileMemoryToRegister(arm64.LDRD,
 			arm64CallingConventionModuleInstanceAddressRegister, moduleInstanceTypeIDsOffset, tmpX)
 		c.assembler.CompileRegisterToMemory(arm64.STRD,
@@ -4223,3 +4760,58 @@ func (c *arm64Compiler) compileModuleContextInitialization() error {
 	c.markRegisterUnused(regs...)
 	return nil
 }
+
+// compileThrow implements compiler.compileThrow for the arm64 architecture.
+func (c *arm64Compiler) compileThrow(o *wazeroir.OperationThrow) error {
+	payload := c.ir.Types[c.ir.Tags[o.TagIndex]].ParamNumInUint64
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexThrow, uint64(o.TagIndex), payload, runtimeValueTypeNone)
+}
+
+// compileRethrow implements compiler.compileRethrow for the arm64 architecture.
+func (c *arm64Compiler) compileRethrow() error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexRethrow, 0, 1, runtimeValueTypeNone)
+}
+
+// compileExceptionPending implements compiler.compileExceptionPending for the arm64 architecture.
+func (c *arm64Compiler) compileExceptionPending() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	reg, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// reg = ce.exitContext.exceptionPending
+	c.assembler.CompileMemoryToRegister(arm64.LDRW,
+		arm64ReservedRegisterForCallEngine, callEngineExitContextExceptionPendingOffset, reg)
+	c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	return nil
+}
+
+// compileExceptionMatch implements compiler.compileExceptionMatch for the arm64 architecture.
+func (c *arm64Compiler) compileExceptionMatch(o *wazeroir.OperationExceptionMatch) error {
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexExceptionMatch, uint64(o.TagIndex), 0, runtimeValueTypeI32)
+}
+
+// compileGC implements compiler.compileGC for the arm64 architecture.
+func (c *arm64Compiler) compileGC(o *wazeroir.OperationGC) error {
+	// The field index or the number of values of array.new_fixed doesn't fit in the immediate, so it is pushed first.
+	if err := c.compileConstI64(&wazeroir.OperationConstI64{Value: uint64(o.Index)}); err != nil {
+		return err
+	}
+	return c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexGC, gcImmediate(o), o.Operands+1, gcResultType(o))
+}
+
+// compileCatch implements compiler.compileCatch for the arm64 architecture.
+func (c *arm64Compiler) compileCatch(o *wazeroir.OperationCatch) error {
+	// The reference to the exception is pushed followed by the payload.
+	if err := c.compileCallBuiltinFunctionWithImmediate(builtinFunctionIndexCatch, catchImmediate(o), 0, runtimeValueTypeI64); err != nil {
+		return err
+	}
+	if !o.All {
+		c.locationStack.pushRuntimeValueLocationsOnStack(c.ir.Types[c.ir.Tags[o.TagIndex]].Params)
+	}
+	return nil
+}
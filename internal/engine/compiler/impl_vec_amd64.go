@@ -5,6 +5,7 @@ import (
 
 	"github.com/tetratelabs/wazero/internal/asm"
 	"github.com/tetratelabs/wazero/internal/asm/amd64"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/wazeroir"
 )
 
@@ -121,21 +122,21 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 
 	switch o.Type {
 	case wazeroir.V128LoadType128:
-		err = c.compileV128LoadImpl(amd64.MOVDQU, o.Arg.Offset, 16, result)
+		err = c.compileV128LoadImpl(amd64.MOVDQU, o.Arg, 16, result)
 	case wazeroir.V128LoadType8x8s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXBW, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXBW, o.Arg, 8, result)
 	case wazeroir.V128LoadType8x8u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXBW, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXBW, o.Arg, 8, result)
 	case wazeroir.V128LoadType16x4s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXWD, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXWD, o.Arg, 8, result)
 	case wazeroir.V128LoadType16x4u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXWD, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXWD, o.Arg, 8, result)
 	case wazeroir.V128LoadType32x2s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXDQ, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXDQ, o.Arg, 8, result)
 	case wazeroir.V128LoadType32x2u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXDQ, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXDQ, o.Arg, 8, result)
 	case wazeroir.V128LoadType8Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, 1)
+		reg, err := c.compileMemoryAccessCeilSetup(o.Arg, 1)
 		if err != nil {
 			return err
 		}
@@ -153,7 +154,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 		c.assembler.CompileRegisterToRegister(amd64.PXOR, tmpVReg, tmpVReg)
 		c.assembler.CompileRegisterToRegister(amd64.PSHUFB, tmpVReg, result)
 	case wazeroir.V128LoadType16Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, 2)
+		reg, err := c.compileMemoryAccessCeilSetup(o.Arg, 2)
 		if err != nil {
 			return err
 		}
@@ -166,7 +167,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRW, reg, result, 1)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PSHUFD, result, result, 0)
 	case wazeroir.V128LoadType32Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, 4)
+		reg, err := c.compileMemoryAccessCeilSetup(o.Arg, 4)
 		if err != nil {
 			return err
 		}
@@ -177,7 +178,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRD, reg, result, 0)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PSHUFD, result, result, 0)
 	case wazeroir.V128LoadType64Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, 8)
+		reg, err := c.compileMemoryAccessCeilSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -188,9 +189,9 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRQ, reg, result, 0)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRQ, reg, result, 1)
 	case wazeroir.V128LoadType32zero:
-		err = c.compileV128LoadImpl(amd64.MOVL, o.Arg.Offset, 4, result)
+		err = c.compileV128LoadImpl(amd64.MOVL, o.Arg, 4, result)
 	case wazeroir.V128LoadType64zero:
-		err = c.compileV128LoadImpl(amd64.MOVQ, o.Arg.Offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.MOVQ, o.Arg, 8, result)
 	}
 
 	if err != nil {
@@ -201,8 +202,8 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.OperationV128Load) error {
 	return nil
 }
 
-func (c *amd64Compiler) compileV128LoadImpl(inst asm.Instruction, offset uint32, targetSizeInBytes int64, dst asm.Register) error {
-	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+func (c *amd64Compiler) compileV128LoadImpl(inst asm.Instruction, arg *wazeroir.MemoryArg, targetSizeInBytes int64, dst asm.Register) error {
+	offsetReg, err := c.compileMemoryAccessCeilSetup(arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -231,7 +232,7 @@ func (c *amd64Compiler) compileV128LoadLane(o *wazeroir.OperationV128LoadLane) e
 	}
 
 	targetSizeInBytes := int64(o.LaneSize / 8)
-	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -250,7 +251,7 @@ func (c *amd64Compiler) compileV128Store(o *wazeroir.OperationV128Store) error {
 	}
 
 	const targetSizeInBytes = 16
-	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -282,7 +283,7 @@ func (c *amd64Compiler) compileV128StoreLane(o *wazeroir.OperationV128StoreLane)
 	}
 
 	targetSizeInBytes := int64(o.LaneSize / 8)
-	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg.Offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessCeilSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -523,7 +524,7 @@ var swizzleConst = [16]byte{
 }
 
 // compileV128Swizzle implements compiler.compileV128Swizzle for amd64.
-func (c *amd64Compiler) compileV128Swizzle(*wazeroir.OperationV128Swizzle) error {
+func (c *amd64Compiler) compileV128Swizzle(o *wazeroir.OperationV128Swizzle) error {
 	index := c.locationStack.popV128()
 	if err := c.compileEnsureOnRegister(index); err != nil {
 		return err
@@ -536,6 +537,15 @@ func (c *amd64Compiler) compileV128Swizzle(*wazeroir.OperationV128Swizzle) error
 
 	idxReg, baseReg := index.register, base.register
 
+	if o.Relaxed && c.relaxedSIMDNative {
+		// PSHUFB results in zero for indexes with the highest bit set, and uses the lowest four bits otherwise, which
+		// is allowed for the out-of-range indexes of i8x16.relaxed_swizzle.
+		c.assembler.CompileRegisterToRegister(amd64.PSHUFB, idxReg, baseReg)
+		c.pushVectorRuntimeValueLocationOnRegister(baseReg)
+		c.locationStack.markRegisterUnused(idxReg)
+		return nil
+	}
+
 	tmp, err := c.allocateRegister(registerTypeVector)
 	if err != nil {
 		return err
@@ -2751,3 +2761,139 @@ func (c *amd64Compiler) compileV128ITruncSatFromF(o *wazeroir.OperationV128ITrun
 	c.pushVectorRuntimeValueLocationOnRegister(vr)
 	return nil
 }
+
+// compileV128RelaxedMadd implements compiler.compileV128RelaxedMadd for amd64.
+func (c *amd64Compiler) compileV128RelaxedMadd(o *wazeroir.OperationV128RelaxedMadd) error {
+	x3 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x3); err != nil {
+		return err
+	}
+
+	x2 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x2); err != nil {
+		return err
+	}
+
+	x1 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x1); err != nil {
+		return err
+	}
+
+	if c.relaxedSIMDNative && c.cpuFeatures.Has(platform.CpuFeatureFMA) {
+		// x3 = x1*x2 + x3 (or -(x1*x2) + x3) without rounding the product.
+		var inst asm.Instruction
+		switch {
+		case o.Shape == wazeroir.ShapeF32x4 && !o.Negate:
+			inst = amd64.VFMADD231PS
+		case o.Shape == wazeroir.ShapeF32x4 && o.Negate:
+			inst = amd64.VFNMADD231PS
+		case !o.Negate:
+			inst = amd64.VFMADD231PD
+		default:
+			inst = amd64.VFNMADD231PD
+		}
+		c.assembler.CompileTwoRegistersToRegister(inst, x1.register, x2.register, x3.register)
+		c.locationStack.markRegisterUnused(x1.register, x2.register)
+		c.pushVectorRuntimeValueLocationOnRegister(x3.register)
+		return nil
+	}
+
+	mul, add, sub := amd64.MULPS, amd64.ADDPS, amd64.SUBPS
+	if o.Shape == wazeroir.ShapeF64x2 {
+		mul, add, sub = amd64.MULPD, amd64.ADDPD, amd64.SUBPD
+	}
+
+	c.assembler.CompileRegisterToRegister(mul, x2.register, x1.register)
+	c.locationStack.markRegisterUnused(x2.register)
+	if o.Negate {
+		// x3 = x3 - x1*x2.
+		c.assembler.CompileRegisterToRegister(sub, x1.register, x3.register)
+		c.locationStack.markRegisterUnused(x1.register)
+		c.pushVectorRuntimeValueLocationOnRegister(x3.register)
+	} else {
+		// x1 = x1*x2 + x3.
+		c.assembler.CompileRegisterToRegister(add, x3.register, x1.register)
+		c.locationStack.markRegisterUnused(x3.register)
+		c.pushVectorRuntimeValueLocationOnRegister(x1.register)
+	}
+	return nil
+}
+
+// compileV128RelaxedDot implements compiler.compileV128RelaxedDot for amd64.
+func (c *amd64Compiler) compileV128RelaxedDot(o *wazeroir.OperationV128RelaxedDot) error {
+	var x3 *runtimeValueLocation
+	if o.Add {
+		x3 = c.locationStack.popV128()
+		if err := c.compileEnsureOnRegister(x3); err != nil {
+			return err
+		}
+	}
+
+	x2 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x2); err != nil {
+		return err
+	}
+
+	x1 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x1); err != nil {
+		return err
+	}
+
+	var result asm.Register
+	if c.relaxedSIMDNative {
+		// PMADDUBSW multiplies the unsigned bytes of the destination and the signed bytes of the source, and adds the
+		// adjacent pairs with the signed saturation, which is allowed as the lanes of x2 are expected to be 7-bit.
+		c.assembler.CompileRegisterToRegister(amd64.PMADDUBSW, x1.register, x2.register)
+		c.locationStack.markRegisterUnused(x1.register)
+		result = x2.register
+	} else {
+		tmp1, err := c.allocateRegister(registerTypeVector)
+		if err != nil {
+			return err
+		}
+		c.locationStack.markRegisterUsed(tmp1)
+
+		tmp2, err := c.allocateRegister(registerTypeVector)
+		if err != nil {
+			return err
+		}
+
+		// Sign-extend the even bytes of x1 and x2 to words in tmp1 and tmp2, and the odd bytes in place.
+		c.assembler.CompileRegisterToRegister(amd64.MOVDQA, x1.register, tmp1)
+		c.assembler.CompileRegisterToRegister(amd64.MOVDQA, x2.register, tmp2)
+		for _, r := range []asm.Register{tmp1, tmp2} {
+			c.assembler.CompileConstToRegister(amd64.PSLLW, 8, r)
+			c.assembler.CompileConstToRegister(amd64.PSRAW, 8, r)
+		}
+		c.assembler.CompileConstToRegister(amd64.PSRAW, 8, x1.register)
+		c.assembler.CompileConstToRegister(amd64.PSRAW, 8, x2.register)
+
+		// x1 = even(x1)*even(x2) + odd(x1)*odd(x2) on each word, which wraps on overflow.
+		c.assembler.CompileRegisterToRegister(amd64.PMULLW, tmp2, tmp1)
+		c.assembler.CompileRegisterToRegister(amd64.PMULLW, x2.register, x1.register)
+		c.assembler.CompileRegisterToRegister(amd64.PADDW, tmp1, x1.register)
+
+		c.locationStack.markRegisterUnused(tmp1, x2.register)
+		result = x1.register
+	}
+
+	if o.Add {
+		ones, err := c.allocateRegister(registerTypeVector)
+		if err != nil {
+			return err
+		}
+
+		if err = c.assembler.CompileStaticConstToRegister(amd64.MOVDQU,
+			asm.NewStaticConst(allOnesI16x8[:]), ones); err != nil {
+			return err
+		}
+
+		// Add the adjacent pairs of the signed words as doublewords, and then add x3.
+		c.assembler.CompileRegisterToRegister(amd64.PMADDWD, ones, result)
+		c.assembler.CompileRegisterToRegister(amd64.PADDD, x3.register, result)
+		c.locationStack.markRegisterUnused(x3.register)
+	}
+
+	c.pushVectorRuntimeValueLocationOnRegister(result)
+	return nil
+}
@@ -142,7 +142,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 
 	switch o.Type {
 	case wazeroir.V128LoadType128:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 16)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 16)
 		if err != nil {
 			return err
 		}
@@ -150,7 +150,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 			arm64ReservedRegisterForMemory, offset, result, arm64.VectorArrangementQ,
 		)
 	case wazeroir.V128LoadType8x8s:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -160,7 +160,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement8B, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType8x8u:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -170,7 +170,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement8B, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType16x4s:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -180,7 +180,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement4H, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType16x4u:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -190,7 +190,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement4H, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType32x2s:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -200,7 +200,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement2S, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType32x2u:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -210,35 +210,35 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement2S, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType8Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 1)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 1)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement16B)
 	case wazeroir.V128LoadType16Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 2)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 2)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement8H)
 	case wazeroir.V128LoadType32Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 4)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 4)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement4S)
 	case wazeroir.V128LoadType64Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement2D)
 	case wazeroir.V128LoadType32zero:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 4)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 4)
 		if err != nil {
 			return err
 		}
@@ -246,7 +246,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.OperationV128Load) (err erro
 			arm64ReservedRegisterForMemory, offset, result, arm64.VectorArrangementS,
 		)
 	case wazeroir.V128LoadType64zero:
-		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, 8)
 		if err != nil {
 			return err
 		}
@@ -267,7 +267,7 @@ func (c *arm64Compiler) compileV128LoadLane(o *wazeroir.OperationV128LoadLane) (
 	}
 
 	targetSizeInBytes := int64(o.LaneSize / 8)
-	source, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, targetSizeInBytes)
+	source, err := c.compileMemoryAccessOffsetSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -305,7 +305,7 @@ func (c *arm64Compiler) compileV128Store(o *wazeroir.OperationV128Store) (err er
 	}
 
 	const targetSizeInBytes = 16
-	offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, targetSizeInBytes)
+	offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -342,7 +342,7 @@ func (c *arm64Compiler) compileV128StoreLane(o *wazeroir.OperationV128StoreLane)
 	}
 
 	targetSizeInBytes := int64(o.LaneSize / 8)
-	offset, err := c.compileMemoryAccessOffsetSetup(o.Arg.Offset, targetSizeInBytes)
+	offset, err := c.compileMemoryAccessOffsetSetup(o.Arg, targetSizeInBytes)
 	if err != nil {
 		return err
 	}
@@ -1529,3 +1529,101 @@ func (c *arm64Compiler) compileV128ITruncSatFromF(o *wazeroir.OperationV128ITrun
 	c.pushVectorRuntimeValueLocationOnRegister(v.register)
 	return
 }
+
+// compileV128RelaxedMadd implements compiler.compileV128RelaxedMadd for arm64.
+//
+// Note: this always rounds the intermediate product, which is the deterministic semantics of relaxed SIMD.
+func (c *arm64Compiler) compileV128RelaxedMadd(o *wazeroir.OperationV128RelaxedMadd) error {
+	x3 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x3); err != nil {
+		return err
+	}
+
+	x2 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x2); err != nil {
+		return err
+	}
+
+	x1 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x1); err != nil {
+		return err
+	}
+
+	x1r, x2r, x3r := x1.register, x2.register, x3.register
+
+	arr := defaultArrangementForShape(o.Shape)
+	add, sub := arm64.VFADDS, arm64.VFSUBS
+	if o.Shape == wazeroir.ShapeF64x2 {
+		add, sub = arm64.VFADDD, arm64.VFSUBD
+	}
+
+	// x1r = x1r * x2r.
+	c.assembler.CompileVectorRegisterToVectorRegister(arm64.VFMUL, x2r, x1r, arr,
+		arm64.VectorIndexNone, arm64.VectorIndexNone)
+	c.markRegisterUnused(x2r)
+
+	if o.Negate {
+		// x3r = x3r - x1r.
+		c.assembler.CompileVectorRegisterToVectorRegister(sub, x1r, x3r, arr,
+			arm64.VectorIndexNone, arm64.VectorIndexNone)
+		c.markRegisterUnused(x1r)
+		c.pushVectorRuntimeValueLocationOnRegister(x3r)
+	} else {
+		// x1r = x1r + x3r.
+		c.assembler.CompileVectorRegisterToVectorRegister(add, x3r, x1r, arr,
+			arm64.VectorIndexNone, arm64.VectorIndexNone)
+		c.markRegisterUnused(x3r)
+		c.pushVectorRuntimeValueLocationOnRegister(x1r)
+	}
+	return nil
+}
+
+// compileV128RelaxedDot implements compiler.compileV128RelaxedDot for arm64.
+func (c *arm64Compiler) compileV128RelaxedDot(o *wazeroir.OperationV128RelaxedDot) error {
+	var x3 *runtimeValueLocation
+	if o.Add {
+		x3 = c.locationStack.popV128()
+		if err := c.compileEnsureOnRegister(x3); err != nil {
+			return err
+		}
+	}
+
+	x2 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x2); err != nil {
+		return err
+	}
+
+	x1 := c.locationStack.popV128()
+	if err := c.compileEnsureOnRegister(x1); err != nil {
+		return err
+	}
+
+	tmp, err := c.allocateRegister(registerTypeVector)
+	if err != nil {
+		return err
+	}
+
+	x1r, x2r := x1.register, x2.register
+
+	// Multiply lower integers and get the 16-bit results into tmp.
+	c.assembler.CompileTwoVectorRegistersToVectorRegister(arm64.SMULL, x1r, x2r, tmp, arm64.VectorArrangement8B)
+	// Multiply higher integers and get the 16-bit results into x1r.
+	c.assembler.CompileTwoVectorRegistersToVectorRegister(arm64.SMULL2, x1r, x2r, x1r, arm64.VectorArrangement16B)
+	// Adds these two results into x1r, which wraps on overflow.
+	c.assembler.CompileTwoVectorRegistersToVectorRegister(arm64.VADDP, x1r, tmp, x1r, arm64.VectorArrangement8H)
+	c.markRegisterUnused(x2r)
+
+	if o.Add {
+		// Add the adjacent pairs of the signed 16-bit integers as 32-bit ones, and then add x3.
+		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SADDLP, x1r, x1r, arm64.VectorArrangement8H,
+			arm64.VectorIndexNone, arm64.VectorIndexNone)
+		c.assembler.CompileVectorRegisterToVectorRegister(arm64.VADD, x1r, x3.register, arm64.VectorArrangement4S,
+			arm64.VectorIndexNone, arm64.VectorIndexNone)
+		c.markRegisterUnused(x1r)
+		c.pushVectorRuntimeValueLocationOnRegister(x3.register)
+		return nil
+	}
+
+	c.pushVectorRuntimeValueLocationOnRegister(x1r)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"math/bits"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
@@ -83,6 +84,9 @@ type moduleEngine struct {
 	// The index is module instance-scoped.
 	functions []function
 
+	// codes are the compiled functions defined in the module, which remain after the module is deleted from the engine.
+	codes []*code
+
 	// parentEngine holds *engine from which this module engine is created from.
 	parentEngine *engine
 }
@@ -101,6 +105,25 @@ type callEngine struct {
 	compiled *function
 	// source is the FunctionInstance from which compiled is created from.
 	source *wasm.FunctionInstance
+
+	// exception is the exception thrown and not caught yet (api.CoreFeatureExceptionHandling).
+	exception *api.Exception
+	// caughtExceptions hold the caught exceptions which might be rethrown, where the reference to each exception is
+	// its index plus one.
+	caughtExceptions []*api.Exception
+
+	// fuel is the remaining fuel of this call, consumed by wazeroir.OperationConsumeFuel. This is only set when the
+	// initial function is compiled with fuel metering, in which case it points to unlimitedFuel unless the
+	// context.Context of the call has the fuel given by wasm.FuelKey.
+	fuel          *int64
+	unlimitedFuel int64
+
+	// interrupted is set by wasm.CallContext WatchCanceledOrTimeout when the initial function is compiled with
+	// ensureTermination, and checked along with the exit code.
+	interrupted *uint32
+
+	// stackIterator is passed to experimental.FunctionListener Before, and reused to avoid allocations.
+	stackIterator stackIterator
 }
 
 func (e *moduleEngine) newCallEngine(source *wasm.FunctionInstance, compiled *function) *callEngine {
@@ -158,6 +181,21 @@ func (ce *callEngine) pushFrame(frame *callFrame) {
 	ce.frames = append(ce.frames, frame)
 }
 
+// pushNewFrame pushes a new call frame for f. Frames popped by previous calls are reused, so that a call doesn't
+// allocate once the call stack has been that deep.
+func (ce *callEngine) pushNewFrame(f *function) (frame *callFrame) {
+	if depth := len(ce.frames); depth < cap(ce.frames) {
+		if frame = ce.frames[:depth+1][depth]; frame != nil {
+			frame.pc, frame.f = 0, f
+		}
+	}
+	if frame == nil {
+		frame = &callFrame{f: f}
+	}
+	ce.pushFrame(frame)
+	return
+}
+
 func (ce *callEngine) popFrame() (frame *callFrame) {
 	// No need to check stack bound as we can assume that all the operations are valid thanks to validateFunction at
 	// module validation phase and wazeroir translation before compilation.
@@ -174,6 +212,50 @@ type callFrame struct {
 	f *function
 }
 
+// stackIterator implements experimental.StackIterator over the frames of a callEngine, beginning with the function
+// being called, whose frame isn't pushed yet.
+type stackIterator struct {
+	frames       []*callFrame
+	fn           *function
+	sourceOffset uint64
+	started      bool
+}
+
+// reset prepares the iterator for the call of f with the given frames.
+func (si *stackIterator) reset(frames []*callFrame, f *function) *stackIterator {
+	si.frames, si.fn, si.sourceOffset, si.started = frames, f, 0, false
+	return si
+}
+
+// Next implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) Next() bool {
+	if !si.started {
+		si.started = true
+		return true
+	}
+	last := len(si.frames) - 1
+	if last < 0 {
+		return false
+	}
+	frame := si.frames[last]
+	si.frames = si.frames[:last]
+	si.fn, si.sourceOffset = frame.f, 0
+	if body := frame.f.parent.body; body != nil {
+		si.sourceOffset = body[frame.pc].sourcePC
+	}
+	return true
+}
+
+// FunctionDefinition implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) FunctionDefinition() api.FunctionDefinition {
+	return si.fn.source.Definition
+}
+
+// SourceOffset implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) SourceOffset() uint64 {
+	return si.sourceOffset
+}
+
 type code struct {
 	source            *wasm.Module
 	body              []*interpreterOp
@@ -181,6 +263,12 @@ type code struct {
 	hostFn            interface{}
 	isHostFunction    bool
 	ensureTermination bool
+	fuelMetering      bool
+
+	// coverage holds the times each of coverageBlocks was executed, incremented by wazeroir.OperationCoverBlock.
+	// These are nil unless compiled with coverage.
+	coverage       []uint64
+	coverageBlocks []wasm.CoverageBlock
 }
 
 type function struct {
@@ -200,6 +288,19 @@ func functionFromUintptr(ptr uintptr) *function {
 	return *(**function)(unsafe.Pointer(wrapped))
 }
 
+// functionFromRef returns the function of the function reference ref, which is the target of call_ref, after checking
+// that the reference is not null and its type ID is typeID.
+func functionFromRef(ref uint64, typeID wasm.FunctionTypeID) *function {
+	if ref == 0 {
+		panic(wasmruntime.ErrRuntimeNullReference)
+	}
+	tf := functionFromUintptr(uintptr(ref))
+	if tf.source.TypeID != typeID {
+		panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+	}
+	return tf
+}
+
 // interpreterOp is the compilation (engine.lowerIR) result of a wazeroir.Operation.
 //
 // Not all operations result in an interpreterOp, e.g. wazeroir.OperationI32ReinterpretFromF32, and some operations are
@@ -221,13 +322,13 @@ type interpreterOp struct {
 const callFrameStackSize = 0
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, coverage bool) error {
 	if _, ok := e.getCodes(module); ok { // cache hit!
 		return nil
 	}
 
 	funcs := make([]*code, len(module.FunctionSection))
-	irs, err := wazeroir.CompileFunctions(e.enabledFeatures, callFrameStackSize, module, ensureTermination)
+	irs, err := wazeroir.CompileFunctions(e.enabledFeatures, callFrameStackSize, module, ensureTermination, fuelMetering, coverage)
 	if err != nil {
 		return err
 	}
@@ -254,12 +355,39 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 		compiled.source = module
 		compiled.isHostFunction = ir.IsHostFunction
 		compiled.ensureTermination = ir.EnsureTermination
+		compiled.fuelMetering = ir.FuelMetering
 		funcs[i] = compiled
 	}
 	e.addCodes(module, funcs)
 	return nil
 }
 
+// Coverage implements the same method as documented on wasm.Engine.
+func (e *engine) Coverage(module *wasm.Module) [][]wasm.CoverageBlock {
+	codes, _ := e.getCodes(module)
+	return coverage(codes)
+}
+
+// coverage returns the basic blocks of the given codes, with the times they were executed, or nil if none of them is
+// compiled with coverage.
+func coverage(codes []*code) (ret [][]wasm.CoverageBlock) {
+	for i, c := range codes {
+		if c.coverageBlocks == nil {
+			continue
+		}
+		if ret == nil {
+			ret = make([][]wasm.CoverageBlock, len(codes))
+		}
+		blocks := make([]wasm.CoverageBlock, len(c.coverageBlocks))
+		for j, b := range c.coverageBlocks {
+			b.Count = atomic.LoadUint64(&c.coverage[j])
+			blocks[j] = b
+		}
+		ret[i] = blocks
+	}
+	return
+}
+
 // NewModuleEngine implements the same method as documented on wasm.Engine.
 func (e *engine) NewModuleEngine(name string, module *wasm.Module, functions []wasm.FunctionInstance) (wasm.ModuleEngine, error) {
 	me := &moduleEngine{
@@ -284,6 +412,7 @@ func (e *engine) NewModuleEngine(name string, module *wasm.Module, functions []w
 		f := &functions[offset]
 		me.functions[offset] = function{source: f, parent: c}
 	}
+	me.codes = codes
 	return me, nil
 }
 
@@ -292,6 +421,9 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 	hasSourcePCs := len(ir.IROperationSourceOffsetsInWasmBinary) > 0
 	ops := ir.Operations
 	ret := &code{}
+	if ir.CoverageBlocks != nil {
+		ret.coverage, ret.coverageBlocks = make([]uint64, len(ir.CoverageBlocks)), ir.CoverageBlocks
+	}
 	labelAddress := map[string]uint64{}
 	onLabelAddressResolved := map[string][]func(addr uint64){}
 	for i, original := range ops {
@@ -301,6 +433,10 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 		}
 		switch o := original.(type) {
 		case wazeroir.OperationBuiltinFunctionCheckExitCode:
+		case *wazeroir.OperationConsumeFuel:
+			op.us = []uint64{o.Cost}
+		case *wazeroir.OperationCoverBlock:
+			op.us = []uint64{uint64(o.Index)}
 		case *wazeroir.OperationUnreachable:
 		case *wazeroir.OperationLabel:
 			labelKey := o.Label.String()
@@ -391,6 +527,18 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 			op.us = make([]uint64, 2)
 			op.us[0] = uint64(o.TypeIndex)
 			op.us[1] = uint64(o.TableIndex)
+		case *wazeroir.OperationTailCall:
+			op.us = []uint64{uint64(o.FunctionIndex)}
+			op.rs = []*wazeroir.InclusiveRange{o.Drop}
+		case *wazeroir.OperationTailCallIndirect:
+			op.us = []uint64{uint64(o.TypeIndex), uint64(o.TableIndex)}
+			op.rs = []*wazeroir.InclusiveRange{o.Drop}
+		case *wazeroir.OperationCallRef:
+			op.us = []uint64{uint64(o.TypeIndex)}
+		case *wazeroir.OperationTailCallRef:
+			op.us = []uint64{uint64(o.TypeIndex)}
+			op.rs = []*wazeroir.InclusiveRange{o.Drop}
+		case *wazeroir.OperationRefAsNonNull:
 		case *wazeroir.OperationDrop:
 			op.rs = make([]*wazeroir.InclusiveRange, 1)
 			op.rs[0] = o.Depth
@@ -451,6 +599,7 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 			op.us[1] = uint64(o.Arg.Offset)
 		case *wazeroir.OperationMemorySize:
 		case *wazeroir.OperationMemoryGrow:
+			op.b3 = o.Memory64
 		case *wazeroir.OperationConstI32:
 			op.us = make([]uint64, 1)
 			op.us[0] = uint64(o.Value)
@@ -559,6 +708,10 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 			op.us = make([]uint64, 1)
 			op.us[0] = uint64(o.DataIndex)
 		case *wazeroir.OperationMemoryCopy:
+			if o.DestinationMemoryIndex != o.SourceMemoryIndex {
+				op.b3 = true
+				op.us = []uint64{uint64(o.DestinationMemoryIndex), uint64(o.SourceMemoryIndex)}
+			}
 		case *wazeroir.OperationMemoryFill:
 		case *wazeroir.OperationTableInit:
 			op.us = make([]uint64, 2)
@@ -710,12 +863,70 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 			op.b1 = o.DestinationShape
 			op.b3 = o.Signed
 		case *wazeroir.OperationV128Dot:
+		case *wazeroir.OperationV128RelaxedMadd:
+			op.b1 = o.Shape
+			op.b3 = o.Negate
+		case *wazeroir.OperationV128RelaxedDot:
+			op.b3 = o.Add
 		case *wazeroir.OperationV128Narrow:
 			op.b1 = o.OriginShape
 			op.b3 = o.Signed
 		case *wazeroir.OperationV128ITruncSatFromF:
 			op.b1 = o.OriginShape
 			op.b3 = o.Signed
+		case *wazeroir.OperationAtomicMemoryWait:
+			op.b1 = byte(o.Type)
+			op.us = make([]uint64, 2)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+		case *wazeroir.OperationAtomicMemoryNotify:
+			op.us = make([]uint64, 2)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+		case wazeroir.OperationAtomicFence:
+		case *wazeroir.OperationAtomicLoad:
+			op.b1 = byte(o.Type)
+			op.us = make([]uint64, 3)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+			op.us[2] = uint64(o.Size)
+		case *wazeroir.OperationAtomicStore:
+			op.b1 = byte(o.Type)
+			op.us = make([]uint64, 3)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+			op.us[2] = uint64(o.Size)
+		case *wazeroir.OperationAtomicRMW:
+			op.b1 = byte(o.Type)
+			op.b2 = byte(o.Op)
+			op.us = make([]uint64, 3)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+			op.us[2] = uint64(o.Size)
+		case *wazeroir.OperationAtomicRMWCmpxchg:
+			op.b1 = byte(o.Type)
+			op.us = make([]uint64, 3)
+			op.us[0] = uint64(o.Arg.Alignment)
+			op.us[1] = uint64(o.Arg.Offset)
+			op.us[2] = uint64(o.Size)
+		case *wazeroir.OperationSelectMemory:
+			op.us = []uint64{uint64(o.MemoryIndex)}
+		case *wazeroir.OperationThrow:
+			op.us = []uint64{uint64(o.TagIndex)}
+		case *wazeroir.OperationRethrow:
+		case *wazeroir.OperationExceptionPending:
+		case *wazeroir.OperationExceptionMatch:
+			op.us = []uint64{uint64(o.TagIndex)}
+		case *wazeroir.OperationCatch:
+			op.us = []uint64{uint64(o.TagIndex)}
+			if o.All {
+				op.b1 = 1
+			}
+			op.b3 = o.Rethrown
+		case *wazeroir.OperationGC:
+			op.b1 = o.Opcode
+			op.b3 = o.HasResult
+			op.us = []uint64{uint64(o.TypeIndex), uint64(o.Index), uint64(o.Operands)}
 		default:
 			panic(fmt.Errorf("BUG: unimplemented operation %s", op.kind.String()))
 		}
@@ -751,6 +962,11 @@ func (e *moduleEngine) CreateFuncElementInstance(indexes []*wasm.Index) *wasm.El
 	}
 }
 
+// Coverage implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) Coverage() [][]wasm.CoverageBlock {
+	return coverage(e.codes)
+}
+
 // FunctionInstanceReference implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) FunctionInstanceReference(funcIndex wasm.Index) wasm.Reference {
 	return uintptr(unsafe.Pointer(&e.functions[funcIndex]))
@@ -788,17 +1004,39 @@ func (e *moduleEngine) LookupFunction(t *wasm.TableInstance, typeId wasm.Functio
 
 // Call implements the same method as documented on wasm.CallEngine.
 func (ce *callEngine) Call(ctx context.Context, m *wasm.CallContext, params []uint64) (results []uint64, err error) {
-	return ce.call(ctx, m, ce.compiled, params)
-}
-
-func (ce *callEngine) call(ctx context.Context, callCtx *wasm.CallContext, tf *function, params []uint64) (results []uint64, err error) {
-	ft := tf.source.Type
+	ft := ce.compiled.source.Type
 	paramSignature := ft.ParamNumInUint64
 	paramCount := len(params)
 	if paramSignature != paramCount {
 		return nil, fmt.Errorf("expected %d params, but passed %d", paramSignature, paramCount)
 	}
 
+	// This returns a safe copy of the results, instead of a slice view. If we
+	// returned a re-slice, the caller could accidentally or purposefully
+	// corrupt the stack of subsequent calls.
+	if resultCount := ft.ResultNumInUint64; resultCount > 0 {
+		results = make([]uint64, resultCount)
+	}
+	if err = ce.call(ctx, m, ce.compiled, params, results); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// CallWithStack implements the same method as documented on wasm.CallEngine.
+func (ce *callEngine) CallWithStack(ctx context.Context, m *wasm.CallContext, stack []uint64) error {
+	ft := ce.compiled.source.Type
+	paramCount, resultCount := ft.ParamNumInUint64, ft.ResultNumInUint64
+	if stackSize := len(stack); stackSize < paramCount || stackSize < resultCount {
+		return fmt.Errorf("need %d params and %d results, but stack size is %d", paramCount, resultCount, stackSize)
+	}
+	return ce.call(ctx, m, ce.compiled, stack[:paramCount], stack[:resultCount])
+}
+
+// call invokes tf with the params, and writes its results into the given
+// slice. The params and results may share the same underlying array, as the
+// params are pushed onto the stack before any results are written.
+func (ce *callEngine) call(ctx context.Context, callCtx *wasm.CallContext, tf *function, params, results []uint64) (err error) {
 	defer func() {
 		// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
 		if err == nil {
@@ -816,19 +1054,47 @@ func (ce *callEngine) call(ctx context.Context, callCtx *wasm.CallContext, tf *f
 	}
 
 	if ce.compiled.parent.ensureTermination {
-		done := callCtx.CloseModuleOnCanceledOrTimeout(ctx)
+		var done context.CancelFunc
+		ce.interrupted, done = callCtx.WatchCanceledOrTimeout(ctx)
 		defer done()
 	}
 
+	if ce.compiled.parent.fuelMetering {
+		ce.fuel = wasm.GetFuel(ctx, &ce.unlimitedFuel)
+	}
+
 	ce.callFunction(ctx, callCtx, tf)
 
-	// This returns a safe copy of the results, instead of a slice view. If we
-	// returned a re-slice, the caller could accidentally or purposefully
-	// corrupt the stack of subsequent calls.
-	results = wasm.PopValues(ft.ResultNumInUint64, ce.popValue)
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = ce.popValue()
+	}
+
+	// The exception uncaught in Wasm is returned as is.
+	if exception := ce.exception; exception != nil {
+		ce.exception, ce.caughtExceptions = nil, nil
+		return exception
+	}
+	ce.caughtExceptions = nil
 	return
 }
 
+// GCRoots implements the same method as documented on wasm.CallEngine.
+func (ce *callEngine) GCRoots(fn func(v uint64)) {
+	for _, v := range ce.stack {
+		fn(v)
+	}
+	if ce.exception != nil {
+		for _, v := range ce.exception.Payload() {
+			fn(v)
+		}
+	}
+	for _, e := range ce.caughtExceptions {
+		for _, v := range e.Payload() {
+			fn(v)
+		}
+	}
+}
+
 // recoverOnCall takes the recovered value `recoverOnCall`, and wraps it
 // with the call frame stack traces. Also, reset the state of callEngine
 // so that it can be used for the subsequent calls.
@@ -848,6 +1114,7 @@ func (ce *callEngine) recoverOnCall(v interface{}) (err error) {
 
 	// Allows the reuse of CallEngine.
 	ce.stack, ce.frames = ce.stack[:0], ce.frames[:0]
+	ce.exception, ce.caughtExceptions = nil, nil
 	return
 }
 
@@ -861,22 +1128,41 @@ func (ce *callEngine) callFunction(ctx context.Context, callCtx *wasm.CallContex
 	}
 }
 
+// tailCall prepares the tail call of f from the current frame, assuming that only the arguments to f remain in the
+// current frame. This returns true when the frame has been reused for f, and the caller must start executing f in it.
+// Otherwise, f has been called as usual and its results are on the stack, so the caller must return.
+func (ce *callEngine) tailCall(ctx context.Context, callCtx *wasm.CallContext, frame *callFrame, f *function) bool {
+	// Host functions and the functions with listeners require their own frame, so they can't be executed in place.
+	if f.parent.hostFn != nil || f.parent.listener != nil {
+		ce.callFunction(ctx, callCtx, f)
+		return false
+	}
+	frame.f = f
+	frame.pc = 0
+	return true
+}
+
 func (ce *callEngine) callGoFunc(ctx context.Context, callCtx *wasm.CallContext, f *function, stack []uint64) {
 	lsn := f.parent.listener
 	callCtx = callCtx.WithMemory(ce.callerMemory())
 	if lsn != nil {
 		params := stack[:f.source.Type.ParamNumInUint64]
-		ctx = lsn.Before(ctx, callCtx, f.source.Definition, params)
+		ctx = lsn.Before(ctx, callCtx, f.source.Definition, params, ce.stackIterator.reset(ce.frames, f))
 	}
-	frame := &callFrame{f: f}
-	ce.pushFrame(frame)
+	ce.pushNewFrame(f)
 
 	fn := f.parent.hostFn
-	switch fn := fn.(type) {
-	case api.GoModuleFunction:
-		fn.Call(ctx, callCtx, stack)
-	case api.GoFunction:
-		fn.Call(ctx, stack)
+	heap := f.source.Module.GCHeap
+	if heap != nil {
+		heap.PauseCall(ce)
+	}
+	if f.source.Module.Engine.(*moduleEngine).parentEngine.enabledFeatures.IsEnabled(api.CoreFeatureExceptionHandling) {
+		ce.exception = wasm.CatchException(func() { callHostFunc(ctx, callCtx, fn, stack) })
+	} else {
+		callHostFunc(ctx, callCtx, fn, stack)
+	}
+	if heap != nil {
+		heap.ResumeCall(ce)
 	}
 
 	ce.popFrame()
@@ -887,8 +1173,21 @@ func (ce *callEngine) callGoFunc(ctx context.Context, callCtx *wasm.CallContext,
 	}
 }
 
+// callHostFunc calls the host function fn which is either api.GoModuleFunction or api.GoFunction.
+func callHostFunc(ctx context.Context, callCtx *wasm.CallContext, fn interface{}, stack []uint64) {
+	switch fn := fn.(type) {
+	case api.GoModuleFunction:
+		fn.Call(ctx, callCtx, stack)
+	case api.GoFunction:
+		fn.Call(ctx, stack)
+	}
+}
+
 func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallContext, f *function) {
-	frame := &callFrame{f: f}
+	frame := ce.pushNewFrame(f)
+	// A tail call replaces frame.f, and jumps back here to execute the callee in the same frame.
+tailCall:
+	f = frame.f
 	moduleInst := f.source.Module
 	functions := moduleInst.Engine.(*moduleEngine).functions
 	var memoryInst *wasm.MemoryInstance
@@ -902,7 +1201,6 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 	typeIDs := f.source.Module.TypeIDs
 	dataInstances := f.source.Module.DataInstances
 	elementInstances := f.source.Module.ElementInstances
-	ce.pushFrame(frame)
 	body := frame.f.parent.body
 	bodyLen := uint64(len(body))
 	for frame.pc < bodyLen {
@@ -915,6 +1213,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			if err := callCtx.FailIfClosed(); err != nil {
 				panic(err)
 			}
+			if err := wasm.FailIfInterrupted(ce.interrupted); err != nil {
+				panic(err)
+			}
+			frame.pc++
+		case wazeroir.OperationKindConsumeFuel:
+			if *ce.fuel -= int64(op.us[0]); *ce.fuel < 0 {
+				panic(wasmruntime.ErrRuntimeFuelExhausted)
+			}
+			frame.pc++
+		case wazeroir.OperationKindCoverBlock:
+			atomic.AddUint64(&frame.f.parent.coverage[op.us[0]], 1)
 			frame.pc++
 		case wazeroir.OperationKindUnreachable:
 			panic(wasmruntime.ErrRuntimeUnreachable)
@@ -958,6 +1267,49 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 
 			ce.callFunction(ctx, callCtx, tf)
 			frame.pc++
+		case wazeroir.OperationKindTailCall:
+			ce.drop(op.rs[0])
+			if ce.tailCall(ctx, callCtx, frame, &functions[op.us[0]]) {
+				goto tailCall
+			}
+			frame.pc = bodyLen
+		case wazeroir.OperationKindTailCallIndirect:
+			ce.drop(op.rs[0])
+			offset := ce.popValue()
+			table := tables[op.us[1]]
+			if offset >= uint64(len(table.References)) {
+				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+			}
+			rawPtr := table.References[offset]
+			if rawPtr == 0 {
+				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+			}
+
+			tf := functionFromUintptr(rawPtr)
+			if tf.source.TypeID != typeIDs[op.us[0]] {
+				panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+			}
+
+			if ce.tailCall(ctx, callCtx, frame, tf) {
+				goto tailCall
+			}
+			frame.pc = bodyLen
+		case wazeroir.OperationKindCallRef:
+			tf := functionFromRef(ce.popValue(), typeIDs[op.us[0]])
+			ce.callFunction(ctx, callCtx, tf)
+			frame.pc++
+		case wazeroir.OperationKindTailCallRef:
+			ce.drop(op.rs[0])
+			tf := functionFromRef(ce.popValue(), typeIDs[op.us[0]])
+			if ce.tailCall(ctx, callCtx, frame, tf) {
+				goto tailCall
+			}
+			frame.pc = bodyLen
+		case wazeroir.OperationKindRefAsNonNull:
+			if ce.stack[len(ce.stack)-1] == 0 {
+				panic(wasmruntime.ErrRuntimeNullReference)
+			}
+			frame.pc++
 		case wazeroir.OperationKindDrop:
 			ce.drop(op.rs[0])
 			frame.pc++
@@ -1014,24 +1366,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			offset := ce.popMemoryOffset(op)
 			switch wazeroir.UnsignedType(op.b1) {
 			case wazeroir.UnsignedTypeI32, wazeroir.UnsignedTypeF32:
-				if val, ok := memoryInst.ReadUint32Le(offset); !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				} else {
-					ce.pushValue(uint64(val))
-				}
+				ce.pushValue(uint64(binary.LittleEndian.Uint32(memoryBytes(memoryInst, offset, 4))))
 			case wazeroir.UnsignedTypeI64, wazeroir.UnsignedTypeF64:
-				if val, ok := memoryInst.ReadUint64Le(offset); !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				} else {
-					ce.pushValue(val)
-				}
+				ce.pushValue(binary.LittleEndian.Uint64(memoryBytes(memoryInst, offset, 8)))
 			}
 			frame.pc++
 		case wazeroir.OperationKindLoad8:
-			val, ok := memoryInst.ReadByte(ce.popMemoryOffset(op))
-			if !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			val := memoryBytes(memoryInst, ce.popMemoryOffset(op), 1)[0]
 
 			switch wazeroir.SignedInt(op.b1) {
 			case wazeroir.SignedInt32:
@@ -1044,10 +1385,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			frame.pc++
 		case wazeroir.OperationKindLoad16:
 
-			val, ok := memoryInst.ReadUint16Le(ce.popMemoryOffset(op))
-			if !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			val := binary.LittleEndian.Uint16(memoryBytes(memoryInst, ce.popMemoryOffset(op), 2))
 
 			switch wazeroir.SignedInt(op.b1) {
 			case wazeroir.SignedInt32:
@@ -1059,10 +1397,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			}
 			frame.pc++
 		case wazeroir.OperationKindLoad32:
-			val, ok := memoryInst.ReadUint32Le(ce.popMemoryOffset(op))
-			if !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			val := binary.LittleEndian.Uint32(memoryBytes(memoryInst, ce.popMemoryOffset(op), 4))
 
 			if op.b1 == 1 { // Signed
 				ce.pushValue(uint64(int32(val)))
@@ -1075,43 +1410,39 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			offset := ce.popMemoryOffset(op)
 			switch wazeroir.UnsignedType(op.b1) {
 			case wazeroir.UnsignedTypeI32, wazeroir.UnsignedTypeF32:
-				if !memoryInst.WriteUint32Le(offset, uint32(val)) {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				binary.LittleEndian.PutUint32(memoryBytes(memoryInst, offset, 4), uint32(val))
 			case wazeroir.UnsignedTypeI64, wazeroir.UnsignedTypeF64:
-				if !memoryInst.WriteUint64Le(offset, val) {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				binary.LittleEndian.PutUint64(memoryBytes(memoryInst, offset, 8), val)
 			}
 			frame.pc++
 		case wazeroir.OperationKindStore8:
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteByte(offset, val) {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			memoryBytes(memoryInst, offset, 1)[0] = val
 			frame.pc++
 		case wazeroir.OperationKindStore16:
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint16Le(offset, val) {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			binary.LittleEndian.PutUint16(memoryBytes(memoryInst, offset, 2), val)
 			frame.pc++
 		case wazeroir.OperationKindStore32:
 			val := uint32(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint32Le(offset, val) {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			binary.LittleEndian.PutUint32(memoryBytes(memoryInst, offset, 4), val)
 			frame.pc++
 		case wazeroir.OperationKindMemorySize:
 			ce.pushValue(uint64(memoryInst.PageSize()))
 			frame.pc++
 		case wazeroir.OperationKindMemoryGrow:
 			n := ce.popValue()
-			if res, ok := memoryInst.Grow(uint32(n)); !ok {
-				ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+			if n > math.MaxUint32 { // Only possible in a 64-bit memory, and always exceeds the limit.
+				ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+			} else if res, ok := memoryInst.Grow(uint32(n)); !ok {
+				if op.b3 { // 64-bit memory
+					ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+				} else {
+					ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+				}
 			} else {
 				ce.pushValue(uint64(res))
 			}
@@ -1878,38 +2209,36 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			copySize := ce.popValue()
 			inDataOffset := ce.popValue()
 			inMemoryOffset := ce.popValue()
-			if inDataOffset+copySize > uint64(len(dataInstance)) ||
-				inMemoryOffset+copySize > uint64(len(memoryInst.Buffer)) {
+			if inDataOffset+copySize > uint64(len(dataInstance)) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			} else if copySize != 0 {
-				copy(memoryInst.Buffer[inMemoryOffset:inMemoryOffset+copySize], dataInstance[inDataOffset:])
+			} else if buf := memoryBytes(memoryInst, inMemoryOffset, copySize); copySize != 0 {
+				copy(buf, dataInstance[inDataOffset:])
 			}
 			frame.pc++
 		case wazeroir.OperationKindDataDrop:
 			dataInstances[op.us[0]] = nil
 			frame.pc++
 		case wazeroir.OperationKindMemoryCopy:
-			memLen := uint64(len(memoryInst.Buffer))
+			dst, src := memoryInst, memoryInst
+			if op.b3 { // Copying between different memories.
+				dst, src = moduleInst.Memories[op.us[0]], moduleInst.Memories[op.us[1]]
+			}
 			copySize := ce.popValue()
 			sourceOffset := ce.popValue()
 			destinationOffset := ce.popValue()
-			if sourceOffset+copySize > memLen || destinationOffset+copySize > memLen {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			} else if copySize != 0 {
-				copy(memoryInst.Buffer[destinationOffset:],
-					memoryInst.Buffer[sourceOffset:sourceOffset+copySize])
+			// memoryBytes checks the bounds with overflow-safe arithmetic, as offsets are 64-bit in a 64-bit memory.
+			srcBuf := memoryBytes(src, sourceOffset, copySize)
+			if dstBuf := memoryBytes(dst, destinationOffset, copySize); copySize != 0 {
+				copy(dstBuf, srcBuf)
 			}
 			frame.pc++
 		case wazeroir.OperationKindMemoryFill:
 			fillSize := ce.popValue()
 			value := byte(ce.popValue())
 			offset := ce.popValue()
-			if fillSize+offset > uint64(len(memoryInst.Buffer)) {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			} else if fillSize != 0 {
+			if buf := memoryBytes(memoryInst, offset, fillSize); fillSize != 0 {
 				// Uses the copy trick for faster filling buffer.
 				// https://gist.github.com/taylorza/df2f89d5f9ab3ffd06865062a4cf015d
-				buf := memoryInst.Buffer[offset : offset+fillSize]
 				buf[0] = value
 				for i := 1; i < len(buf); i *= 2 {
 					copy(buf[i:], buf[:i])
@@ -2091,21 +2420,11 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			offset := ce.popMemoryOffset(op)
 			switch op.b1 {
 			case wazeroir.V128LoadType128:
-				lo, ok := memoryInst.ReadUint64Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
-				ce.pushValue(lo)
-				hi, ok := memoryInst.ReadUint64Le(offset + 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
-				ce.pushValue(hi)
+				data := memoryBytes(memoryInst, offset, 16)
+				ce.pushValue(binary.LittleEndian.Uint64(data))
+				ce.pushValue(binary.LittleEndian.Uint64(data[8:]))
 			case wazeroir.V128LoadType8x8s:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(
 					uint64(uint16(int8(data[3])))<<48 | uint64(uint16(int8(data[2])))<<32 | uint64(uint16(int8(data[1])))<<16 | uint64(uint16(int8(data[0]))),
 				)
@@ -2113,10 +2432,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					uint64(uint16(int8(data[7])))<<48 | uint64(uint16(int8(data[6])))<<32 | uint64(uint16(int8(data[5])))<<16 | uint64(uint16(int8(data[4]))),
 				)
 			case wazeroir.V128LoadType8x8u:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(
 					uint64(data[3])<<48 | uint64(data[2])<<32 | uint64(data[1])<<16 | uint64(data[0]),
 				)
@@ -2124,10 +2440,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					uint64(data[7])<<48 | uint64(data[6])<<32 | uint64(data[5])<<16 | uint64(data[4]),
 				)
 			case wazeroir.V128LoadType16x4s:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(
 					uint64(int16(binary.LittleEndian.Uint16(data[2:])))<<32 |
 						uint64(uint32(int16(binary.LittleEndian.Uint16(data)))),
@@ -2137,10 +2450,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 						uint64(uint32(int16(binary.LittleEndian.Uint16(data[4:])))),
 				)
 			case wazeroir.V128LoadType16x4u:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(
 					uint64(binary.LittleEndian.Uint16(data[2:]))<<32 | uint64(binary.LittleEndian.Uint16(data)),
 				)
@@ -2148,63 +2458,39 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					uint64(binary.LittleEndian.Uint16(data[6:]))<<32 | uint64(binary.LittleEndian.Uint16(data[4:])),
 				)
 			case wazeroir.V128LoadType32x2s:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data))))
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data[4:]))))
 			case wazeroir.V128LoadType32x2u:
-				data, ok := memoryInst.Read(offset, 8)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				data := memoryBytes(memoryInst, offset, 8)
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data)))
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data[4:])))
 			case wazeroir.V128LoadType8Splat:
-				v, ok := memoryInst.ReadByte(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				v := memoryBytes(memoryInst, offset, 1)[0]
 				v8 := uint64(v)<<56 | uint64(v)<<48 | uint64(v)<<40 | uint64(v)<<32 |
 					uint64(v)<<24 | uint64(v)<<16 | uint64(v)<<8 | uint64(v)
 				ce.pushValue(v8)
 				ce.pushValue(v8)
 			case wazeroir.V128LoadType16Splat:
-				v, ok := memoryInst.ReadUint16Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				v := binary.LittleEndian.Uint16(memoryBytes(memoryInst, offset, 2))
 				v4 := uint64(v)<<48 | uint64(v)<<32 | uint64(v)<<16 | uint64(v)
 				ce.pushValue(v4)
 				ce.pushValue(v4)
 			case wazeroir.V128LoadType32Splat:
-				v, ok := memoryInst.ReadUint32Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				v := binary.LittleEndian.Uint32(memoryBytes(memoryInst, offset, 4))
 				vv := uint64(v)<<32 | uint64(v)
 				ce.pushValue(vv)
 				ce.pushValue(vv)
 			case wazeroir.V128LoadType64Splat:
-				lo, ok := memoryInst.ReadUint64Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				lo := binary.LittleEndian.Uint64(memoryBytes(memoryInst, offset, 8))
 				ce.pushValue(lo)
 				ce.pushValue(lo)
 			case wazeroir.V128LoadType32zero:
-				lo, ok := memoryInst.ReadUint32Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				lo := binary.LittleEndian.Uint32(memoryBytes(memoryInst, offset, 4))
 				ce.pushValue(uint64(lo))
 				ce.pushValue(0)
 			case wazeroir.V128LoadType64zero:
-				lo, ok := memoryInst.ReadUint64Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				lo := binary.LittleEndian.Uint64(memoryBytes(memoryInst, offset, 8))
 				ce.pushValue(lo)
 				ce.pushValue(0)
 			}
@@ -2214,10 +2500,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			offset := ce.popMemoryOffset(op)
 			switch op.b1 {
 			case 8:
-				b, ok := memoryInst.ReadByte(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				b := memoryBytes(memoryInst, offset, 1)[0]
 				if op.b2 < 8 {
 					s := op.b2 << 3
 					lo = (lo & ^(0xff << s)) | uint64(b)<<s
@@ -2226,10 +2509,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					hi = (hi & ^(0xff << s)) | uint64(b)<<s
 				}
 			case 16:
-				b, ok := memoryInst.ReadUint16Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				b := binary.LittleEndian.Uint16(memoryBytes(memoryInst, offset, 2))
 				if op.b2 < 4 {
 					s := op.b2 << 4
 					lo = (lo & ^(0xff_ff << s)) | uint64(b)<<s
@@ -2238,10 +2518,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					hi = (hi & ^(0xff_ff << s)) | uint64(b)<<s
 				}
 			case 32:
-				b, ok := memoryInst.ReadUint32Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				b := binary.LittleEndian.Uint32(memoryBytes(memoryInst, offset, 4))
 				if op.b2 < 2 {
 					s := op.b2 << 5
 					lo = (lo & ^(0xff_ff_ff_ff << s)) | uint64(b)<<s
@@ -2250,10 +2527,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 					hi = (hi & ^(0xff_ff_ff_ff << s)) | uint64(b)<<s
 				}
 			case 64:
-				b, ok := memoryInst.ReadUint64Le(offset)
-				if !ok {
-					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-				}
+				b := binary.LittleEndian.Uint64(memoryBytes(memoryInst, offset, 8))
 				if op.b2 == 0 {
 					lo = b
 				} else {
@@ -2266,46 +2540,39 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 		case wazeroir.OperationKindV128Store:
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
-			if ok := memoryInst.WriteUint64Le(offset, lo); !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
-			if ok := memoryInst.WriteUint64Le(offset+8, hi); !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
+			data := memoryBytes(memoryInst, offset, 16)
+			binary.LittleEndian.PutUint64(data, lo)
+			binary.LittleEndian.PutUint64(data[8:], hi)
 			frame.pc++
 		case wazeroir.OperationKindV128StoreLane:
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
-			var ok bool
 			switch op.b1 {
 			case 8:
 				if op.b2 < 8 {
-					ok = memoryInst.WriteByte(offset, byte(lo>>(op.b2*8)))
+					memoryBytes(memoryInst, offset, 1)[0] = byte(lo >> (op.b2 * 8))
 				} else {
-					ok = memoryInst.WriteByte(offset, byte(hi>>((op.b2-8)*8)))
+					memoryBytes(memoryInst, offset, 1)[0] = byte(hi >> ((op.b2 - 8) * 8))
 				}
 			case 16:
 				if op.b2 < 4 {
-					ok = memoryInst.WriteUint16Le(offset, uint16(lo>>(op.b2*16)))
+					binary.LittleEndian.PutUint16(memoryBytes(memoryInst, offset, 2), uint16(lo>>(op.b2*16)))
 				} else {
-					ok = memoryInst.WriteUint16Le(offset, uint16(hi>>((op.b2-4)*16)))
+					binary.LittleEndian.PutUint16(memoryBytes(memoryInst, offset, 2), uint16(hi>>((op.b2-4)*16)))
 				}
 			case 32:
 				if op.b2 < 2 {
-					ok = memoryInst.WriteUint32Le(offset, uint32(lo>>(op.b2*32)))
+					binary.LittleEndian.PutUint32(memoryBytes(memoryInst, offset, 4), uint32(lo>>(op.b2*32)))
 				} else {
-					ok = memoryInst.WriteUint32Le(offset, uint32(hi>>((op.b2-2)*32)))
+					binary.LittleEndian.PutUint32(memoryBytes(memoryInst, offset, 4), uint32(hi>>((op.b2-2)*32)))
 				}
 			case 64:
 				if op.b2 == 0 {
-					ok = memoryInst.WriteUint64Le(offset, lo)
+					binary.LittleEndian.PutUint64(memoryBytes(memoryInst, offset, 8), lo)
 				} else {
-					ok = memoryInst.WriteUint64Le(offset, hi)
+					binary.LittleEndian.PutUint64(memoryBytes(memoryInst, offset, 8), hi)
 				}
 			}
-			if !ok {
-				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
-			}
 			frame.pc++
 		case wazeroir.OperationKindV128ReplaceLane:
 			v := ce.popValue()
@@ -4158,11 +4425,196 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			ce.pushValue(retLo)
 			ce.pushValue(retHi)
 			frame.pc++
+		case wazeroir.OperationKindAtomicMemoryWait:
+			timeout := int64(ce.popValue())
+			exp := ce.popValue()
+			if wazeroir.UnsignedInt(op.b1) == wazeroir.UnsignedInt32 {
+				offset := ce.popAtomicMemoryOffset(op, memoryInst, 4)
+				if !memoryInst.Shared {
+					panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+				}
+				ce.pushValue(memoryInst.Wait32(offset, uint32(exp), timeout))
+			} else {
+				offset := ce.popAtomicMemoryOffset(op, memoryInst, 8)
+				if !memoryInst.Shared {
+					panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+				}
+				ce.pushValue(memoryInst.Wait64(offset, exp, timeout))
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicMemoryNotify:
+			count := ce.popValue()
+			offset := ce.popAtomicMemoryOffset(op, memoryInst, 4)
+			// Notify on an unshared memory always returns zero as there can't be any waiter.
+			ce.pushValue(uint64(memoryInst.Notify(offset, uint32(count))))
+			frame.pc++
+		case wazeroir.OperationKindAtomicFence:
+			// The atomic operations of this engine are sequentially consistent, so there's nothing to do.
+			frame.pc++
+		case wazeroir.OperationKindAtomicLoad:
+			size := uint32(op.us[2])
+			offset := ce.popAtomicMemoryOffset(op, memoryInst, size)
+			ce.pushValue(memoryInst.AtomicLoad(offset, size))
+			frame.pc++
+		case wazeroir.OperationKindAtomicStore:
+			size := uint32(op.us[2])
+			val := ce.popValue()
+			offset := ce.popAtomicMemoryOffset(op, memoryInst, size)
+			memoryInst.AtomicStore(offset, size, val)
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW:
+			size := uint32(op.us[2])
+			val := ce.popValue()
+			offset := ce.popAtomicMemoryOffset(op, memoryInst, size)
+			ce.pushValue(memoryInst.AtomicArithmetic(offset, size, wasm.AtomicArithmeticOp(op.b2), val))
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMWCmpxchg:
+			size := uint32(op.us[2])
+			replacement := ce.popValue()
+			exp := ce.popValue()
+			offset := ce.popAtomicMemoryOffset(op, memoryInst, size)
+			ce.pushValue(memoryInst.AtomicCompareAndSwap(offset, size, exp, replacement))
+			frame.pc++
+		case wazeroir.OperationKindSelectMemory:
+			if idx := op.us[0]; idx == 0 {
+				memoryInst = moduleInst.Memory
+			} else {
+				memoryInst = moduleInst.Memories[idx]
+			}
+			frame.pc++
+		case wazeroir.OperationKindThrow:
+			tag := moduleInst.Tags[op.us[0]]
+			payload := make([]uint64, tag.Type.ParamNumInUint64)
+			for i := len(payload) - 1; i >= 0; i-- {
+				payload[i] = ce.popValue()
+			}
+			ce.exception = api.NewException(tag, payload...)
+			frame.pc++
+		case wazeroir.OperationKindRethrow:
+			ce.exception = ce.caughtExceptions[ce.popValue()-1]
+			frame.pc++
+		case wazeroir.OperationKindExceptionPending:
+			if ce.exception != nil {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case wazeroir.OperationKindExceptionMatch:
+			if ce.exception.Tag() == api.Tag(moduleInst.Tags[op.us[0]]) {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case wazeroir.OperationKindCatch:
+			exception := ce.exception
+			ce.exception = nil
+			var ref uint64
+			if op.b3 {
+				ce.caughtExceptions = append(ce.caughtExceptions, exception)
+				ref = uint64(len(ce.caughtExceptions))
+			}
+			ce.pushValue(ref)
+			if op.b1 == 0 {
+				for _, v := range exception.Payload() {
+					ce.pushValue(v)
+				}
+			}
+			frame.pc++
+		case wazeroir.OperationKindGC:
+			base := len(ce.stack) - int(op.us[2])
+			operands := ce.stack[base:]
+			ce.stack = ce.stack[:base]
+			v := moduleInst.ExecuteGC(op.b1, uint32(op.us[0]), uint32(op.us[1]), operands)
+			if op.b3 {
+				ce.pushValue(v)
+			}
+			frame.pc++
+		case wazeroir.OperationKindV128RelaxedMadd:
+			cHi, cLo := ce.popValue(), ce.popValue()
+			bHi, bLo := ce.popValue(), ce.popValue()
+			aHi, aLo := ce.popValue(), ce.popValue()
+			if op.b1 == wazeroir.ShapeF32x4 {
+				ce.pushValue(relaxedMaddF32x2(aLo, bLo, cLo, op.b3))
+				ce.pushValue(relaxedMaddF32x2(aHi, bHi, cHi, op.b3))
+			} else {
+				ce.pushValue(relaxedMaddF64(aLo, bLo, cLo, op.b3))
+				ce.pushValue(relaxedMaddF64(aHi, bHi, cHi, op.b3))
+			}
+			frame.pc++
+		case wazeroir.OperationKindV128RelaxedDot:
+			var cHi, cLo uint64
+			if op.b3 {
+				cHi, cLo = ce.popValue(), ce.popValue()
+			}
+			bHi, bLo := ce.popValue(), ce.popValue()
+			aHi, aLo := ce.popValue(), ce.popValue()
+			lo, hi := relaxedDotI16x4(aLo, bLo), relaxedDotI16x4(aHi, bHi)
+			if op.b3 {
+				lo, hi = extAddPairwiseI32x2(lo, cLo), extAddPairwiseI32x2(hi, cHi)
+			}
+			ce.pushValue(lo)
+			ce.pushValue(hi)
+			frame.pc++
 		}
 	}
 	ce.popFrame()
 }
 
+// relaxedMaddF32x2 returns the two f32 lanes of a*b+c, or -(a*b)+c if negate is true. The product is rounded before
+// the addition, as the interpreter implements the deterministic semantics of api.CoreFeatureRelaxedSIMD.
+func relaxedMaddF32x2(a, b, c uint64, negate bool) (ret uint64) {
+	for i := 0; i < 64; i += 32 {
+		// The explicit conversion prevents the Go compiler from fusing the multiplication and the addition.
+		p := float32(math.Float32frombits(uint32(a>>i)) * math.Float32frombits(uint32(b>>i)))
+		if negate {
+			p = -p
+		}
+		ret |= uint64(math.Float32bits(p+math.Float32frombits(uint32(c>>i)))) << i
+	}
+	return
+}
+
+// relaxedMaddF64 is the same as relaxedMaddF32x2 except that this is for a f64 lane.
+func relaxedMaddF64(a, b, c uint64, negate bool) uint64 {
+	p := float64(math.Float64frombits(a) * math.Float64frombits(b))
+	if negate {
+		p = -p
+	}
+	return math.Float64bits(p + math.Float64frombits(c))
+}
+
+// relaxedDotI16x4 returns the four i16 lanes of the sums of the products of the adjacent pairs of the signed i8 lanes
+// of a and b, which wrap on overflow.
+func relaxedDotI16x4(a, b uint64) (ret uint64) {
+	for i := 0; i < 64; i += 16 {
+		sum := int16(int8(a>>i))*int16(int8(b>>i)) + int16(int8(a>>(i+8)))*int16(int8(b>>(i+8)))
+		ret |= uint64(uint16(sum)) << i
+	}
+	return
+}
+
+// extAddPairwiseI32x2 returns the two i32 lanes of c plus the sums of the adjacent pairs of the signed i16 lanes of v.
+func extAddPairwiseI32x2(v, c uint64) (ret uint64) {
+	for i := 0; i < 64; i += 32 {
+		sum := int32(int16(v>>i)) + int32(int16(v>>(i+16))) + int32(uint32(c>>i))
+		ret |= uint64(uint32(sum)) << i
+	}
+	return
+}
+
+// popAtomicMemoryOffset is like popMemoryOffset, but also checks the boundary and the alignment of size bytes at the
+// resulting offset, as required by atomic instructions.
+func (ce *callEngine) popAtomicMemoryOffset(op *interpreterOp, memoryInst *wasm.MemoryInstance, size uint32) uint64 {
+	offset := ce.popMemoryOffset(op)
+	memoryBytes(memoryInst, offset, uint64(size))
+	if offset%uint64(size) != 0 {
+		panic(wasmruntime.ErrRuntimeUnalignedAtomic)
+	}
+	return offset
+}
+
 // callerMemory returns the caller context memory.
 func (ce *callEngine) callerMemory() *wasm.MemoryInstance {
 	// Search through the call frame stack from the top until we find a non host function.
@@ -4375,7 +4827,7 @@ func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, callCtx *w
 	if f.parent.isHostFunction {
 		callCtx = callCtx.WithMemory(ce.callerMemory())
 	}
-	ctx = fnl.Before(ctx, callCtx, f.source.Definition, ce.peekValues(len(f.source.Type.Params)))
+	ctx = fnl.Before(ctx, callCtx, f.source.Definition, ce.peekValues(len(f.source.Type.Params)), ce.stackIterator.reset(ce.frames, f))
 	ce.callNativeFunc(ctx, callCtx, f)
 	// TODO: This doesn't get the error due to use of panic to propagate them.
 	fnl.After(ctx, callCtx, f.source.Definition, nil, ce.peekValues(len(f.source.Type.Results)))
@@ -4383,14 +4835,24 @@ func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, callCtx *w
 }
 
 // popMemoryOffset takes a memory offset off the stack for use in load and store instructions.
-// As the top of stack value is 64-bit, this ensures it is in range before returning it.
-func (ce *callEngine) popMemoryOffset(op *interpreterOp) uint32 {
+// As the address may be i64 in a 64-bit memory, this ensures the addition of the static offset doesn't overflow.
+func (ce *callEngine) popMemoryOffset(op *interpreterOp) uint64 {
 	// TODO: Document what 'us' is and why we expect to look at value 1.
-	offset := op.us[1] + ce.popValue()
-	if offset > math.MaxUint32 {
+	addr := ce.popValue()
+	offset := op.us[1] + addr
+	if offset < addr {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	return offset
+}
+
+// memoryBytes returns the byteCount bytes of memoryInst at the given offset, or panics if they are out of bounds.
+func memoryBytes(memoryInst *wasm.MemoryInstance, offset, byteCount uint64) []byte {
+	b, ok := memoryInst.Read64(offset, byteCount)
+	if !ok {
 		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 	}
-	return uint32(offset)
+	return b
 }
 
 func (ce *callEngine) callGoFuncWithStack(ctx context.Context, callCtx *wasm.CallContext, f *function) {
@@ -44,6 +44,21 @@ func TestInterpreter_CallEngine_PushFrame(t *testing.T) {
 	require.Equal(t, []*callFrame{f1, f2}, ce.frames)
 }
 
+func TestInterpreter_CallEngine_PushNewFrame(t *testing.T) {
+	f1, f2 := &function{}, &function{}
+
+	ce := callEngine{}
+	frame := ce.pushNewFrame(f1)
+	require.Equal(t, []*callFrame{{f: f1}}, ce.frames)
+
+	// A popped frame is reused by the next call at the same depth.
+	frame.pc = 10
+	ce.popFrame()
+	reused := ce.pushNewFrame(f2)
+	require.Same(t, frame, reused)
+	require.Equal(t, []*callFrame{{f: f2}}, ce.frames)
+}
+
 func TestInterpreter_CallEngine_PushFrame_StackOverflow(t *testing.T) {
 	saved := callStackCeiling
 	defer func() { callStackCeiling = saved }()
@@ -114,6 +129,8 @@ func TestInterpreter_ModuleEngine_Call(t *testing.T) {
 	require.Equal(t, `
 --> .$0(1,2)
 <-- (1,2)
+--> .$0(1,2)
+<-- (1,2)
 `, "\n"+functionLog.String())
 }
 
@@ -519,7 +536,7 @@ func TestInterpreter_Compile(t *testing.T) {
 		}
 		errModule.BuildFunctionDefinitions()
 
-		err := e.CompileModule(testCtx, errModule, nil, false)
+		err := e.CompileModule(testCtx, errModule, nil, false, false, false)
 		require.EqualError(t, err, "failed to lower func[.$2] to wazeroir: handling instruction: apply stack failed for call: reading immediates: EOF")
 
 		// On the compilation failure, all the compiled functions including succeeded ones must be released.
@@ -540,7 +557,7 @@ func TestInterpreter_Compile(t *testing.T) {
 			},
 			ID: wasm.ModuleID{},
 		}
-		err := e.CompileModule(testCtx, okModule, nil, false)
+		err := e.CompileModule(testCtx, okModule, nil, false, false, false)
 		require.NoError(t, err)
 
 		compiled, ok := e.codes[okModule.ID]
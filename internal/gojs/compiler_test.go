@@ -113,8 +113,8 @@ func TestMain(m *testing.M) {
 		log.Panicln(err)
 	}
 	// Set max to a high value, e.g. so that Test_stdio_large can pass
-	parsed.MemorySection.Max = 1024 // 64MB
-	parsed.MemorySection.IsMaxEncoded = true
+	parsed.MemorySection[0].Max = 1024 // 64MB
+	parsed.MemorySection[0].IsMaxEncoded = true
 	testBin = binaryformat.EncodeModule(parsed)
 
 	// Seed wazero's compilation cache to see any error up-front and to prevent
@@ -0,0 +1,90 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// addWat is a tiny function, so that the cost of the call itself dominates.
+const addWat = `(module
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1))))`
+
+// BenchmarkCall compares api.Function Call with CallWithStack, which reuses
+// the caller's stack instead of allocating results.
+func BenchmarkCall(b *testing.B) {
+	b.Run("interpreter", func(b *testing.B) {
+		runCallBenches(b, wazero.NewRuntimeConfigInterpreter())
+	})
+	if platform.CompilerSupported() {
+		b.Run("compiler", func(b *testing.B) {
+			runCallBenches(b, wazero.NewRuntimeConfigCompiler())
+		})
+	}
+}
+
+func runCallBenches(b *testing.B, config wazero.RuntimeConfig) {
+	r := wazero.NewRuntimeWithConfig(testCtx, config)
+	defer r.Close(testCtx)
+
+	add := instantiateAdd(b, r)
+
+	b.Run("Call", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := add.Call(testCtx, 1, 2); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("CallWithStack", func(b *testing.B) {
+		b.ReportAllocs()
+		stack := make([]uint64, 2)
+		for i := 0; i < b.N; i++ {
+			stack[0], stack[1] = 1, 2
+			if err := add.CallWithStack(testCtx, stack); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCallWithStack_NoAllocations(t *testing.T) {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			add := instantiateAdd(t, r)
+
+			stack := make([]uint64, 2)
+			allocs := testing.AllocsPerRun(100, func() {
+				stack[0], stack[1] = 1, 2
+				if err := add.CallWithStack(testCtx, stack); err != nil {
+					t.Fatal(err)
+				}
+			})
+			require.Equal(t, uint64(3), stack[0])
+			require.Equal(t, float64(0), allocs)
+		})
+	}
+}
+
+func instantiateAdd(tb testing.TB, r wazero.Runtime) api.Function {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(addWat))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return mod.ExportedFunction("add")
+}
@@ -192,7 +192,7 @@ func setupHostCallBench(requireNoError func(error)) *wasm.ModuleInstance {
 	goReflectFn := &host.Functions[host.Exports["go-reflect"].Index]
 	wasnFn := &host.Functions[host.Exports["wasm"].Index]
 
-	err := eng.CompileModule(testCtx, hostModule, nil, false)
+	err := eng.CompileModule(testCtx, hostModule, nil, false, false, false)
 	requireNoError(err)
 
 	hostME, err := eng.NewModuleEngine(host.Name, hostModule, host.Functions)
@@ -220,12 +220,12 @@ func setupHostCallBench(requireNoError func(error)) *wasm.ModuleInstance {
 			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}}, // Calling the index 2 = host.wasm.
 		},
 		// Indicates that this module has a memory so that compilers are able to assemble memory-related initialization.
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []*wasm.Memory{{Min: 1}},
 		ID:            wasm.ModuleID{1},
 	}
 
 	importingModule.BuildFunctionDefinitions()
-	err = eng.CompileModule(testCtx, importingModule, nil, false)
+	err = eng.CompileModule(testCtx, importingModule, nil, false, false, false)
 	requireNoError(err)
 
 	importing := &wasm.ModuleInstance{TypeIDs: []wasm.FunctionTypeID{0}}
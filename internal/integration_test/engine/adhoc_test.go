@@ -694,7 +694,7 @@ func testMultipleInstantiation(t *testing.T, r wazero.Runtime) {
 	bin := binary.EncodeModule(&wasm.Module{
 		TypeSection:     []*wasm.FunctionType{{}},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
 		CodeSection: []*wasm.Code{{
 			Body: []byte{
 				wasm.OpcodeI32Const, 1, // i32.const 1    ;; memory offset
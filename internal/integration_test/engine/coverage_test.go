@@ -0,0 +1,125 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var coverageTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"counts":             testCoverageCounts,
+	"shared by modules":  testCoverageSharedByModules,
+	"loop":               testCoverageLoop,
+	"trap in the middle": testCoverageTrap,
+}
+
+func TestEngineCompiler_coverage(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, coverageTests, wazero.NewRuntimeConfigCompiler().WithCoverage(true))
+}
+
+func TestEngineInterpreter_coverage(t *testing.T) {
+	runAllTests(t, coverageTests, wazero.NewRuntimeConfigInterpreter().WithCoverage(true))
+}
+
+// coverageWat exports functions with basic blocks executed a different number of times.
+const coverageWat = `(module
+  (func (export "abs") (param $x i32) (result i32)
+    (if (result i32) (i32.lt_s (local.get $x) (i32.const 0))
+      (then (i32.sub (i32.const 0) (local.get $x)))
+      (else (local.get $x))))
+  (func (export "sum") (param $n i32) (result i32) (local $acc i32)
+    (block $done
+      (loop $loop
+        (br_if $done (i32.eqz (local.get $n)))
+        (local.set $acc (i32.add (local.get $acc) (local.get $n)))
+        (local.set $n (i32.sub (local.get $n) (i32.const 1)))
+        (br $loop)))
+    (local.get $acc))
+  (func (export "trap") (param $x i32)
+    (drop (i32.div_u (i32.const 1) (local.get $x)))
+    (if (local.get $x) (then (nop)))))`
+
+// coverageCounts returns the counts of the blocks of each function.
+func coverageCounts(c *wazero.Coverage) (ret [][]uint64) {
+	for _, f := range c.Functions {
+		var counts []uint64
+		for _, b := range f.Blocks {
+			counts = append(counts, b.Count)
+		}
+		ret = append(ret, counts)
+	}
+	return
+}
+
+func call(t *testing.T, mod api.Module, name string, params ...uint64) {
+	_, err := mod.ExportedFunction(name).Call(testCtx, params...)
+	require.NoError(t, err)
+}
+
+func testCoverageCounts(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(coverageWat))
+	require.NoError(t, err)
+	require.Equal(t, [][]uint64{{0, 0, 0, 0}, {0, 0, 0, 0, 0}, {0, 0, 0}}, coverageCounts(wazero.ModuleCoverage(mod)))
+
+	for _, x := range []int32{-1, 2, 3} {
+		call(t, mod, "abs", api.EncodeI32(x))
+	}
+	// The entry and the end of abs are executed for each call, then only for negative values, and else for others.
+	require.Equal(t, []uint64{3, 1, 2, 3}, coverageCounts(wazero.ModuleCoverage(mod))[0])
+
+	// The blocks are in the order of their instructions.
+	blocks := wazero.ModuleCoverage(mod).Functions[0].Blocks
+	for i := 1; i < len(blocks); i++ {
+		require.Equal(t, blocks[i-1].End, blocks[i].Start)
+	}
+	require.Equal(t, "abs", wazero.ModuleCoverage(mod).Functions[0].Definition.ExportNames()[0])
+}
+
+func testCoverageSharedByModules(t *testing.T, r wazero.Runtime) {
+	compiled, err := r.CompileModule(testCtx, []byte(coverageWat))
+	require.NoError(t, err)
+
+	mod1, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName("1"))
+	require.NoError(t, err)
+	mod2, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName("2"))
+	require.NoError(t, err)
+
+	call(t, mod1, "abs", api.EncodeI32(-1))
+	call(t, mod2, "abs", api.EncodeI32(1))
+
+	expected := [][]uint64{{2, 1, 1, 2}, {0, 0, 0, 0, 0}, {0, 0, 0}}
+	require.Equal(t, expected, coverageCounts(wazero.CompiledModuleCoverage(compiled)))
+	require.Equal(t, expected, coverageCounts(wazero.ModuleCoverage(mod1)))
+
+	// The counters remain after the compiled module is closed, as long as modules instantiated from it.
+	require.NoError(t, compiled.Close(testCtx))
+	require.Nil(t, wazero.CompiledModuleCoverage(compiled))
+	require.Equal(t, expected, coverageCounts(wazero.ModuleCoverage(mod2)))
+}
+
+func testCoverageLoop(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(coverageWat))
+	require.NoError(t, err)
+
+	call(t, mod, "sum", 3)
+	// The loop is entered once, and its condition is checked for n of 3, 2, 1 and 0, which breaks out of it. The end
+	// of the block after the loop is never executed, as it is only reached by falling through the loop.
+	require.Equal(t, []uint64{1, 4, 3, 0, 1}, coverageCounts(wazero.ModuleCoverage(mod))[1])
+}
+
+func testCoverageTrap(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(coverageWat))
+	require.NoError(t, err)
+
+	// The block is counted on entering it, even if it traps before its end.
+	_, err = mod.ExportedFunction("trap").Call(testCtx, 0)
+	require.Error(t, err)
+	call(t, mod, "trap", 1)
+	require.Equal(t, []uint64{2, 1, 1}, coverageCounts(wazero.ModuleCoverage(mod))[2])
+}
@@ -0,0 +1,270 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var exceptionTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"throw and catch": testExceptionThrowCatch,
+	"uncaught":        testExceptionUncaught,
+	"catch_all":       testExceptionCatchAll,
+	"rethrow":         testExceptionRethrow,
+	"delegate":        testExceptionDelegate,
+	"host function":   testExceptionHostFunction,
+	"trap":            testExceptionTrap,
+}
+
+const exceptionFeatures = api.CoreFeaturesV2 | api.CoreFeatureExceptionHandling
+
+func TestEngineCompiler_exception(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, exceptionTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(exceptionFeatures))
+}
+
+func TestEngineInterpreter_exception(t *testing.T) {
+	runAllTests(t, exceptionTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(exceptionFeatures))
+}
+
+// exceptionWasm returns a module with the tag "e" of an i32 payload, and functions throwing and catching it.
+func exceptionWasm(t *testing.T) []byte {
+	const blockTypeI32, blockTypeEmpty = 0x7f, 0x40
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+			{},
+		},
+		ImportSection: []*wasm.Import{
+			{Module: "env", Name: "throw", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		},
+		FunctionSection: []wasm.Index{1, 0, 0, 2, 0, 0, 0, 2},
+		TagSection:      []wasm.Index{1, 3},
+		ExportSection: []*wasm.Export{
+			{Name: "e", Type: wasm.ExternTypeTag, Index: 0},
+			{Name: "throw_catch", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "uncaught", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "catch_all", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "rethrow", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "delegate", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "host", Type: wasm.ExternTypeFunc, Index: 7},
+			{Name: "trap", Type: wasm.ExternTypeFunc, Index: 8},
+		},
+		CodeSection: []*wasm.Code{
+			// (func $thrower (param i32) (throw 0 (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeThrow, 0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32)
+			//   (try (result i32)
+			//     (do (i32.add (i32.const 7) (call $thrower (local.get 0)) (i32.const 1)))
+			//     (catch 0 (i32.add (i32.const 1)))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeI32Const, 7,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32) (call $thrower (local.get 0)) (i32.const 0))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (result i32)
+			//   (try (result i32)
+			//     (do (call $thrower (i32.const 5)) (i32.const 0))
+			//     (catch 1 (i32.const 1))
+			//     (catch_all (i32.const 2))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeI32Const, 5,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeCatch, 1,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeCatchAll,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32)
+			//   (try (result i32)
+			//     (do
+			//       (try (do (call $thrower (local.get 0))) (catch 0 (drop) (rethrow 0)))
+			//       (i32.const 0))
+			//     (catch 0 (i32.add (i32.const 10)))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeTry, blockTypeEmpty,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeRethrow, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeI32Const, 10,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32)
+			//   (try (result i32)
+			//     (do
+			//       (try (result i32)
+			//         (do (try (result i32) (do (call $thrower (local.get 0)) (i32.const 0)) (delegate 1)))
+			//         (catch 0 (drop) (i32.const -1))))
+			//     (catch 0 (i32.add (i32.const 100)))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeDelegate, 1,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeI32Const, 0x7f,
+				wasm.OpcodeEnd,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeI32Const, 0xe4, 0x00,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32)
+			//   (try (result i32)
+			//     (do (call $host_throw (local.get 0)) (i32.const 0))
+			//     (catch 0 (i32.add (i32.const 1000)))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeI32Const, 0xe8, 0x07,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// (func (result i32) (try (result i32) (do (unreachable)) (catch_all (i32.const 1))))
+			{Body: []byte{
+				wasm.OpcodeTry, blockTypeI32,
+				wasm.OpcodeUnreachable,
+				wasm.OpcodeCatchAll,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(exceptionFeatures))
+	return binary.EncodeModule(module)
+}
+
+// instantiateExceptionWasm instantiates exceptionWasm with the host function throwing the exception of the tag "e"
+// of the calling module.
+func instantiateExceptionWasm(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			panic(api.NewException(mod.ExportedTag("e"), stack[0]))
+		}), []api.ValueType{i32}, nil).
+		Export("throw").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, exceptionWasm(t))
+	require.NoError(t, err)
+	return mod
+}
+
+func testExceptionThrowCatch(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	res, err := mod.ExportedFunction("throw_catch").Call(testCtx, 41)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testExceptionUncaught(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	_, err := mod.ExportedFunction("uncaught").Call(testCtx, 42)
+	require.Error(t, err)
+	var exception *api.Exception
+	require.True(t, errors.As(err, &exception))
+	require.Equal(t, mod.ExportedTag("e"), exception.Tag())
+	require.Equal(t, []uint64{42}, exception.Payload())
+
+	// The function must be callable again after the exception.
+	res, err := mod.ExportedFunction("throw_catch").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+}
+
+func testExceptionCatchAll(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	res, err := mod.ExportedFunction("catch_all").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+}
+
+func testExceptionRethrow(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	res, err := mod.ExportedFunction("rethrow").Call(testCtx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(15), res[0])
+}
+
+func testExceptionDelegate(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	// The inner exception skips the catch of the middle try.
+	res, err := mod.ExportedFunction("delegate").Call(testCtx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(105), res[0])
+}
+
+func testExceptionHostFunction(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	res, err := mod.ExportedFunction("host").Call(testCtx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1005), res[0])
+}
+
+func testExceptionTrap(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionWasm(t, r)
+
+	// Traps are not exceptions, so catch_all doesn't catch them.
+	_, err := mod.ExportedFunction("trap").Call(testCtx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unreachable")
+	var exception *api.Exception
+	require.False(t, errors.As(err, &exception))
+}
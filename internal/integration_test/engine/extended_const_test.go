@@ -0,0 +1,149 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var extendedConstTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"global":         testExtendedConstGlobal,
+	"data offset":    testExtendedConstDataOffset,
+	"element offset": testExtendedConstElementOffset,
+}
+
+const extendedConstFeatures = api.CoreFeaturesV2 | api.CoreFeatureExtendedConst
+
+func TestEngineCompiler_extendedConst(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, extendedConstTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(extendedConstFeatures))
+}
+
+func TestEngineInterpreter_extendedConst(t *testing.T) {
+	runAllTests(t, extendedConstTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(extendedConstFeatures))
+}
+
+// instantiateExtendedConstWasm instantiates a module exporting the global "base" of 1024, and a module importing it
+// to compute the initial values of its global, and the offsets of its data and element segments like PIC code does.
+func instantiateExtendedConstWasm(t *testing.T, r wazero.Runtime) api.Module {
+	env := &wasm.Module{
+		GlobalSection: []*wasm.Global{
+			{
+				Type: &wasm.GlobalType{ValType: i32},
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0x80, 0x08}}, // 1024
+			},
+		},
+		ExportSection: []*wasm.Export{{Name: "base", Type: wasm.ExternTypeGlobal, Index: 0}},
+		NameSection:   &wasm.NameSection{ModuleName: "env"},
+	}
+	_, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(env))
+	require.NoError(t, err)
+
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{{Results: []wasm.ValueType{i32}}, {Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		ImportSection: []*wasm.Import{
+			{Module: "env", Name: "base", Type: wasm.ExternTypeGlobal, DescGlobal: &wasm.GlobalType{ValType: i32}},
+		},
+		FunctionSection: []wasm.Index{0, 0, 1},
+		TableSection:    []*wasm.Table{{Min: 4, Type: wasm.RefTypeFuncref}},
+		MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 1}},
+		GlobalSection: []*wasm.Global{
+			{
+				Type: &wasm.GlobalType{ValType: i32},
+				// (i32.add (global.get $base) (i32.mul (i32.const 16) (i32.const 3)))
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeGlobalGet, Data: []byte{
+					0,
+					wasm.OpcodeI32Const, 16,
+					wasm.OpcodeI32Const, 3,
+					wasm.OpcodeI32Mul,
+					wasm.OpcodeI32Add,
+				}},
+			},
+			{
+				Type: &wasm.GlobalType{ValType: wasm.ValueTypeI64},
+				// (i64.sub (i64.const 0) (i64.const 1))
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{
+					0,
+					wasm.OpcodeI64Const, 1,
+					wasm.OpcodeI64Sub,
+				}},
+			},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "g32", Type: wasm.ExternTypeGlobal, Index: 1},
+			{Name: "g64", Type: wasm.ExternTypeGlobal, Index: 2},
+			{Name: "call", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+		ElementSection: []*wasm.ElementSegment{
+			{
+				// (i32.sub (global.get $base) (i32.const 1022))
+				OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeGlobalGet, Data: []byte{
+					0,
+					wasm.OpcodeI32Const, 0x82, 0x78, // -1022
+					wasm.OpcodeI32Add,
+				}},
+				Init: []*wasm.Index{uint32Ptr(0), uint32Ptr(1)},
+				Type: wasm.RefTypeFuncref,
+			},
+		},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI32Const, 2, wasm.OpcodeEnd}},
+			// (func (param i32) (result i32) (call_indirect (result i32) (local.get 0)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCallIndirect, 0, 0, wasm.OpcodeEnd}},
+		},
+		DataSection: []*wasm.DataSegment{
+			{
+				// (i32.add (global.get $base) (i32.const 8))
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeGlobalGet, Data: []byte{
+					0,
+					wasm.OpcodeI32Const, 8,
+					wasm.OpcodeI32Add,
+				}},
+				Init: []byte("hello"),
+			},
+		},
+	}
+	require.NoError(t, module.Validate(extendedConstFeatures))
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(module))
+	require.NoError(t, err)
+	return mod
+}
+
+func testExtendedConstGlobal(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConstWasm(t, r)
+
+	require.Equal(t, uint64(1024+48), mod.ExportedGlobal("g32").Get())
+	require.Equal(t, uint64(1<<64-1), mod.ExportedGlobal("g64").Get())
+}
+
+func testExtendedConstDataOffset(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConstWasm(t, r)
+
+	b, ok := mod.Memory().Read(1024+8, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(b))
+}
+
+func testExtendedConstElementOffset(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConstWasm(t, r)
+
+	call := mod.ExportedFunction("call")
+	for _, tc := range []struct{ index, expected uint64 }{{index: 2, expected: 1}, {index: 3, expected: 2}} {
+		res, err := call.Call(testCtx, tc.index)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res[0])
+	}
+
+	// The element segment starts at the offset two, so the table index zero is uninitialized.
+	_, err := call.Call(testCtx, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var fuelTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"infinite loop":          testFuelInfiniteLoop,
+	"deterministic":          testFuelDeterministic,
+	"shared by nested calls": testFuelNestedCalls,
+	"unlimited without fuel": testFuelUnlimited,
+}
+
+func TestEngineCompiler_fuel(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, fuelTests, wazero.NewRuntimeConfigCompiler().WithFuelMetering(true))
+}
+
+func TestEngineInterpreter_fuel(t *testing.T) {
+	runAllTests(t, fuelTests, wazero.NewRuntimeConfigInterpreter().WithFuelMetering(true))
+}
+
+// fuelWat exports functions to consume fuel, where "sum_via_host" calls "env" "sum", which calls back "sum" with n-1.
+const fuelWat = `(module
+  (import "env" "sum" (func $host_sum (param i32) (result i32)))
+  (func (export "infinite_loop")
+    (loop $loop (br $loop)))
+  (func $sum (export "sum") (param $n i32) (result i32) (local $acc i32)
+    (block $done
+      (loop $loop
+        (br_if $done (i32.eqz (local.get $n)))
+        (local.set $acc (i32.add (local.get $acc) (local.get $n)))
+        (local.set $n (i32.sub (local.get $n) (i32.const 1)))
+        (br $loop)))
+    (local.get $acc))
+  (func (export "sum_via_host") (param $n i32) (result i32)
+    (i32.add (local.get $n) (call $host_sum (i32.sub (local.get $n) (i32.const 1))))))`
+
+func instantiateFuelModule(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, n uint32) uint32 {
+			res, err := m.ExportedFunction("sum").Call(ctx, uint64(n))
+			if err != nil {
+				panic(err)
+			}
+			return uint32(res[0])
+		}).
+		Export("sum").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(fuelWat))
+	require.NoError(t, err)
+	return mod
+}
+
+func testFuelInfiniteLoop(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFuelModule(t, r)
+
+	ctx, fuel := wazero.WithFuel(testCtx, 1000)
+	_, err := mod.ExportedFunction("infinite_loop").Call(ctx)
+	require.True(t, errors.Is(err, wazero.ErrFuelExhausted), err)
+	require.Contains(t, err.Error(), "wasm error: fuel exhausted")
+	require.Equal(t, uint64(0), fuel.Remaining())
+	require.Equal(t, uint64(1000), fuel.Consumed())
+
+	// The exhausted fuel can't be used anymore, but the module is still usable with another one.
+	_, err = mod.ExportedFunction("sum").Call(ctx, 1)
+	require.True(t, errors.Is(err, wazero.ErrFuelExhausted), err)
+	ctx, _ = wazero.WithFuel(testCtx, 1000)
+	res, err := mod.ExportedFunction("sum").Call(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+}
+
+func testFuelDeterministic(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFuelModule(t, r)
+	sum := mod.ExportedFunction("sum")
+
+	// The consumption only depends on the executed instructions, so it is the same for every call and engine.
+	for _, n := range []uint64{0, 1, 10} {
+		ctx, fuel := wazero.WithFuel(testCtx, 1000)
+		_, err := sum.Call(ctx, n)
+		require.NoError(t, err)
+		require.Equal(t, 7+12*n, fuel.Consumed(), n)
+		require.Equal(t, 1000-fuel.Consumed(), fuel.Remaining())
+	}
+
+	// The call fails on entering the basic block it can't afford.
+	ctx, fuel := wazero.WithFuel(testCtx, 7+12*10-1)
+	_, err := sum.Call(ctx, 10)
+	require.True(t, errors.Is(err, wazero.ErrFuelExhausted), err)
+	require.Equal(t, uint64(0), fuel.Remaining())
+}
+
+func testFuelNestedCalls(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFuelModule(t, r)
+
+	ctx, fuel := wazero.WithFuel(testCtx, 1000)
+	res, err := mod.ExportedFunction("sum_via_host").Call(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(55), res[0])
+	// sum_via_host consumes 7, and its nested call of sum with 9 consumes 7+12*9.
+	require.Equal(t, uint64(7+7+12*9), fuel.Consumed())
+
+	ctx, fuel = wazero.WithFuel(testCtx, 100)
+	_, err = mod.ExportedFunction("sum_via_host").Call(ctx, 10)
+	require.True(t, errors.Is(err, wazero.ErrFuelExhausted), err)
+	require.Equal(t, uint64(0), fuel.Remaining())
+}
+
+func testFuelUnlimited(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFuelModule(t, r)
+
+	res, err := mod.ExportedFunction("sum").Call(testCtx, 100_000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5_000_050_000&0xffffffff), res[0])
+}
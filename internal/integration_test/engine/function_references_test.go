@@ -0,0 +1,228 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var functionReferencesTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"call_ref":        testFunctionReferencesCallRef,
+	"call_ref null":   testFunctionReferencesCallRefNull,
+	"return_call_ref": testFunctionReferencesReturnCallRef,
+	"ref.as_non_null": testFunctionReferencesRefAsNonNull,
+	"br_on_null":      testFunctionReferencesBrOnNull,
+	"br_on_non_null":  testFunctionReferencesBrOnNonNull,
+}
+
+const functionReferencesFeatures = api.CoreFeaturesV2 | api.CoreFeatureFunctionReferences | api.CoreFeatureTailCall
+
+func TestEngineCompiler_functionReferences(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, functionReferencesTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(functionReferencesFeatures))
+}
+
+func TestEngineInterpreter_functionReferences(t *testing.T) {
+	runAllTests(t, functionReferencesTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(functionReferencesFeatures))
+}
+
+// instantiateFunctionReferencesWasm instantiates a module whose exported functions call $double or $inc through typed
+// function references of the type $i32_i32.
+func instantiateFunctionReferencesWasm(t *testing.T, r wazero.Runtime) api.Module {
+	const blockTypeI32, blockTypeEmpty = 0x7f, 0x40
+	nullableI32I32 := &wasm.TypedRef{Nullable: true, HeapType: 0}
+	nonNullableI32I32 := &wasm.TypedRef{HeapType: 0}
+	funcref := wasm.ValueTypeFuncref
+
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			// $i32_i32
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			// (func (param i32 (ref null $i32_i32)) (result i32))
+			{
+				Params:    []wasm.ValueType{i32, funcref},
+				ParamRefs: []*wasm.TypedRef{nil, nullableI32I32},
+				Results:   []wasm.ValueType{i32},
+			},
+		},
+		FunctionSection: []wasm.Index{0, 0, 1, 0, 0, 0, 0, 0, 0},
+		ExportSection: []*wasm.Export{
+			{Name: "double", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "inc", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "call_double", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "call_null", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "tail_call_inc", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "as_non_null", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "br_on_null", Type: wasm.ExternTypeFunc, Index: 7},
+			{Name: "br_on_non_null", Type: wasm.ExternTypeFunc, Index: 8},
+		},
+		CodeSection: []*wasm.Code{
+			// (func $double (type $i32_i32) (i32.mul (local.get 0) (i32.const 2)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Mul, wasm.OpcodeEnd}},
+			// (func $inc (type $i32_i32) (i32.add (local.get 0) (i32.const 1)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+			// (func $apply (param i32 (ref null $i32_i32)) (result i32)
+			//   (call_ref $i32_i32 (local.get 0) (local.get 1)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeCallRef, 0, wasm.OpcodeEnd}},
+			// (func (type $i32_i32) (call $apply (local.get 0) (ref.func $double)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeRefFunc, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+			// (func (type $i32_i32) (call $apply (local.get 0) (ref.null $i32_i32)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeRefNull, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+			// (func (type $i32_i32) (return_call_ref $i32_i32 (local.get 0) (ref.func $inc)))
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeRefFunc, 1, wasm.OpcodeReturnCallRef, 0, wasm.OpcodeEnd}},
+			// (func (type $i32_i32) (local (ref null $i32_i32))
+			//   (if (local.get 0) (then (local.set 1 (ref.func $inc))))
+			//   (call_ref $i32_i32 (local.get 0) (ref.as_non_null (local.get 1))))
+			{
+				LocalTypes: []wasm.ValueType{funcref},
+				LocalRefs:  []*wasm.TypedRef{nullableI32I32},
+				Body: []byte{
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeIf, blockTypeEmpty,
+					wasm.OpcodeRefFunc, 1,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeRefAsNonNull,
+					wasm.OpcodeCallRef, 0,
+					wasm.OpcodeEnd,
+				},
+			},
+			// (func (type $i32_i32) (local (ref $i32_i32))
+			//   (block (result i32)
+			//     (i32.const -1)
+			//     (br_on_null 0 (if (result (ref null $i32_i32)) (local.get 0)
+			//       (then (ref.func $double)) (else (ref.null $i32_i32))))
+			//     (local.set 1)
+			//     (drop)
+			//     (call_ref $i32_i32 (local.get 0) (local.get 1))))
+			{
+				LocalTypes: []wasm.ValueType{funcref},
+				LocalRefs:  []*wasm.TypedRef{nonNullableI32I32},
+				Body: []byte{
+					wasm.OpcodeBlock, blockTypeI32,
+					wasm.OpcodeI32Const, 0x7f,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeIf, wasm.RefTypePrefixNullable, 0,
+					wasm.OpcodeRefFunc, 0,
+					wasm.OpcodeElse,
+					wasm.OpcodeRefNull, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeBrOnNull, 0,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeDrop,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeCallRef, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeEnd,
+				},
+			},
+			// (func (type $i32_i32) (local (ref $i32_i32))
+			//   (local.set 1
+			//     (block (result (ref $i32_i32))
+			//       (br_on_non_null 0 (if (result (ref null $i32_i32)) (local.get 0)
+			//         (then (ref.func $inc)) (else (ref.null $i32_i32))))
+			//       (return (i32.const 100))))
+			//   (call_ref $i32_i32 (local.get 0) (local.get 1)))
+			{
+				LocalTypes: []wasm.ValueType{funcref},
+				LocalRefs:  []*wasm.TypedRef{nonNullableI32I32},
+				Body: []byte{
+					wasm.OpcodeBlock, wasm.RefTypePrefixNonNullable, 0,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeIf, wasm.RefTypePrefixNullable, 0,
+					wasm.OpcodeRefFunc, 1,
+					wasm.OpcodeElse,
+					wasm.OpcodeRefNull, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeBrOnNonNull, 0,
+					wasm.OpcodeI32Const, 0xe4, 0x00, // 100
+					wasm.OpcodeReturn,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeCallRef, 0,
+					wasm.OpcodeEnd,
+				},
+			},
+		},
+	}
+	require.NoError(t, module.Validate(functionReferencesFeatures))
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(module))
+	require.NoError(t, err)
+	return mod
+}
+
+func testFunctionReferencesCallRef(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	res, err := mod.ExportedFunction("call_double").Call(testCtx, 21)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testFunctionReferencesCallRefNull(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	_, err := mod.ExportedFunction("call_null").Call(testCtx, 21)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "null reference")
+}
+
+func testFunctionReferencesReturnCallRef(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	res, err := mod.ExportedFunction("tail_call_inc").Call(testCtx, 41)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testFunctionReferencesRefAsNonNull(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	asNonNull := mod.ExportedFunction("as_non_null")
+	res, err := asNonNull.Call(testCtx, 41)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	// The local is null when the argument is zero.
+	_, err = asNonNull.Call(testCtx, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "null reference")
+}
+
+func testFunctionReferencesBrOnNull(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	brOnNull := mod.ExportedFunction("br_on_null")
+	res, err := brOnNull.Call(testCtx, 21)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	res, err = brOnNull.Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), int32(res[0]))
+}
+
+func testFunctionReferencesBrOnNonNull(t *testing.T, r wazero.Runtime) {
+	mod := instantiateFunctionReferencesWasm(t, r)
+
+	brOnNonNull := mod.ExportedFunction("br_on_non_null")
+	res, err := brOnNonNull.Call(testCtx, 41)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	res, err = brOnNonNull.Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), res[0])
+}
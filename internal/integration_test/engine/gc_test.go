@@ -0,0 +1,440 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var gcTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"struct":        testGCStruct,
+	"array":         testGCArray,
+	"ref.test":      testGCRefTest,
+	"ref.cast":      testGCRefCast,
+	"ref.eq":        testGCRefEq,
+	"host function": testGCHostFunction,
+	"collect":       testGCCollect,
+	"results":       testGCResults,
+}
+
+const gcFeatures = api.CoreFeaturesV2 | api.CoreFeatureFunctionReferences | api.CoreFeatureGC
+
+func TestEngineCompiler_gc(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, gcTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(gcFeatures))
+}
+
+func TestEngineInterpreter_gc(t *testing.T) {
+	runAllTests(t, gcTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(gcFeatures))
+}
+
+// instantiateGCWasm instantiates a module which uses the struct type $point and the array type $i64s, after the host
+// module "env" whose function "x" returns the first field of a $point.
+func instantiateGCWasm(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			o, ok := mod.GCObject(stack[0])
+			if !ok || o.IsArray() || o.Len() != 2 || o.Type(1) != api.ValueTypeI32 {
+				panic("not a $point")
+			}
+			x, _ := o.Get(0)
+			if !o.Set(0, x*2) || o.Set(1, 0) { // The second field is immutable.
+				panic("unexpected mutability")
+			}
+			stack[0] = x
+		}), []api.ValueType{api.ValueTypeAnyref}, []api.ValueType{i32}).
+		Export("x").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	nullablePoint := &wasm.TypedRef{Nullable: true, HeapType: 0}
+	nullableI64s := &wasm.TypedRef{Nullable: true, HeapType: 1}
+	nullableEq := &wasm.TypedRef{Nullable: true, HeapType: wasm.HeapTypeEq}
+	anyref := wasm.ValueTypeAnyref
+
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			// $point: (struct (field (mut i32)) (field i8))
+			{Composite: &wasm.CompositeType{Final: true, Fields: []*wasm.FieldType{
+				{Type: i32, Mutable: true},
+				{Type: wasm.PackedTypeI8},
+			}}},
+			// $i64s: (array (mut i64))
+			{Composite: &wasm.CompositeType{Final: true, IsArray: true, Fields: []*wasm.FieldType{
+				{Type: i64, Mutable: true},
+			}}},
+			// (func (param i32) (result i32))
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			// (func (param i32 i32) (result (ref null $point)))
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{anyref}, ResultRefs: []*wasm.TypedRef{nullablePoint}},
+			// (func (param anyref) (result i32))
+			{Params: []wasm.ValueType{anyref}, Results: []wasm.ValueType{i32}},
+			// (func (param eqref eqref) (result i32))
+			{Params: []wasm.ValueType{anyref, anyref}, ParamRefs: []*wasm.TypedRef{nullableEq, nullableEq}, Results: []wasm.ValueType{i32}},
+		},
+		ImportSection:   []*wasm.Import{{Module: "env", Name: "x", Type: wasm.ExternTypeFunc, DescFunc: 4}},
+		FunctionSection: []wasm.Index{2, 2, 3, 4, 4, 5, 2},
+		ExportSection: []*wasm.Export{
+			{Name: "struct", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "array", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "new_point", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "is_point", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "array_len", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "ref_eq", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "host_x", Type: wasm.ExternTypeFunc, Index: 7},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (type 2) (local (ref null $point))
+			//   (local.set 1 (struct.new $point (local.get 0) (i32.const -1)))
+			//   (struct.set $point 0 (local.get 1)
+			//     (i32.add (struct.get $point 0 (local.get 1)) (struct.get_s $point 1 (local.get 1))))
+			//   (struct.get $point 0 (local.get 1)))
+			{
+				LocalTypes: []wasm.ValueType{anyref},
+				LocalRefs:  []*wasm.TypedRef{nullablePoint},
+				Body: []byte{
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeI32Const, 0x7f,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructGet, 0, 0,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructGetS, 0, 1,
+					wasm.OpcodeI32Add,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructSet, 0, 0,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructGet, 0, 0,
+					wasm.OpcodeEnd,
+				},
+			},
+			// (func (type 2) (local (ref null $i64s))
+			//   (local.set 1 (array.new $i64s (i64.const 7) (i32.const 4)))
+			//   (array.set $i64s (local.get 1) (local.get 0) (i64.const 35))
+			//   (i32.add (array.len (local.get 1)) (i32.wrap_i64 (array.get $i64s (local.get 1) (local.get 0)))))
+			{
+				LocalTypes: []wasm.ValueType{anyref},
+				LocalRefs:  []*wasm.TypedRef{nullableI64s},
+				Body: []byte{
+					wasm.OpcodeI64Const, 7,
+					wasm.OpcodeI32Const, 4,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCArrayNew, 1,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeI64Const, 35,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCArraySet, 1,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCArrayLen,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCArrayGet, 1,
+					wasm.OpcodeI32WrapI64,
+					wasm.OpcodeI32Add,
+					wasm.OpcodeEnd,
+				},
+			},
+			// (func (type 3) (struct.new $point (local.get 0) (local.get 1)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (type 4) (ref.test (ref $point) (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCRefTest, 0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (type 4) (array.len (ref.cast (ref $i64s) (local.get 0))))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCRefCast, 1,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCArrayLen,
+				wasm.OpcodeEnd,
+			}},
+			// (func (type 5) (ref.eq (local.get 0) (local.get 1)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeRefEq,
+				wasm.OpcodeEnd,
+			}},
+			// (func (type 2) (call $x (struct.new $point (local.get 0) (i32.const 1))))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(gcFeatures))
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(module))
+	require.NoError(t, err)
+	return mod
+}
+
+func testGCStruct(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	// The packed field is sign-extended by struct.get_s.
+	res, err := mod.ExportedFunction("struct").Call(testCtx, 43)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testGCArray(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	array := mod.ExportedFunction("array")
+	res, err := array.Call(testCtx, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(39), res[0])
+
+	_, err = array.Call(testCtx, 4)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds array access")
+}
+
+func testGCRefTest(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	res, err := mod.ExportedFunction("new_point").Call(testCtx, 1, 2)
+	require.NoError(t, err)
+	point := res[0]
+
+	isPoint := mod.ExportedFunction("is_point")
+	res, err = isPoint.Call(testCtx, point)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	// Null is not a (ref $point).
+	res, err = isPoint.Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testGCRefCast(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	res, err := mod.ExportedFunction("new_point").Call(testCtx, 1, 2)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("array_len").Call(testCtx, res[0])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cast failure")
+}
+
+func testGCRefEq(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	newPoint := mod.ExportedFunction("new_point")
+	res, err := newPoint.Call(testCtx, 1, 2)
+	require.NoError(t, err)
+	p1 := res[0]
+	res, err = newPoint.Call(testCtx, 1, 2)
+	require.NoError(t, err)
+	p2 := res[0]
+
+	refEq := mod.ExportedFunction("ref_eq")
+	res, err = refEq.Call(testCtx, p1, p1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = refEq.Call(testCtx, p1, p2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testGCHostFunction(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCWasm(t, r)
+
+	res, err := mod.ExportedFunction("host_x").Call(testCtx, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	res, err = mod.ExportedFunction("new_point").Call(testCtx, 21, 0x1ff)
+	require.NoError(t, err)
+
+	o, ok := mod.GCObject(res[0])
+	require.True(t, ok)
+	require.Equal(t, "struct[2]", o.String())
+	x, _ := o.Get(0)
+	require.Equal(t, uint64(21), x)
+	y, _ := o.Get(1)
+	require.Equal(t, uint64(0xff), y) // truncated to i8
+
+	_, ok = mod.GCObject(0)
+	require.False(t, ok)
+}
+
+// testGCCollect ensures the objects are collected during a call, except the ones on the stack and the ones held by the
+// host.
+func testGCCollect(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCCollectWasm(t, r)
+
+	newPoint := mod.ExportedFunction("new_point")
+	res, err := newPoint.Call(testCtx, 1)
+	require.NoError(t, err)
+	held, ok := mod.GCObject(res[0])
+	require.True(t, ok)
+	res, err = newPoint.Call(testCtx, 2)
+	require.NoError(t, err)
+	result := res[0]
+
+	// The object in the local survives the collections, but not the one only the host function knew of.
+	collect := mod.ExportedFunction("collect")
+	res, err = collect.Call(testCtx, 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	// The object held by the host survives.
+	o, ok := mod.GCObject(held.Ref())
+	require.True(t, ok)
+	x, _ := o.Get(0)
+	require.Equal(t, uint64(1), x)
+	runtime.KeepAlive(held)
+
+	// The result of the last call of new_point is kept until it returns another one. See testGCResults
+	_, err = newPoint.Call(testCtx, 3)
+	require.NoError(t, err)
+	_, err = collect.Call(testCtx, 4096)
+	require.NoError(t, err)
+	_, ok = mod.GCObject(result)
+	require.False(t, ok)
+}
+
+// testGCResults ensures the result of a call survives the collections of concurrent calls, until the host takes it.
+func testGCResults(t *testing.T, r wazero.Runtime) {
+	mod := instantiateGCCollectWasm(t, r)
+
+	returned, collected := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(collected)
+		<-returned
+		_, err := mod.ExportedFunction("collect").Call(testCtx, 4096)
+		require.NoError(t, err)
+	}()
+
+	res, err := mod.ExportedFunction("new_point").Call(testCtx, 7)
+	require.NoError(t, err)
+	close(returned)
+	<-collected
+
+	o, ok := mod.GCObject(res[0])
+	require.True(t, ok)
+	x, _ := o.Get(0)
+	require.Equal(t, uint64(7), x)
+}
+
+// instantiateGCCollectWasm instantiates a module which exports "new_point", returning a new $point, and "collect",
+// which allocates garbage.
+func instantiateGCCollectWasm(t *testing.T, r wazero.Runtime) api.Module {
+	var kept uint64
+	_, err := r.NewHostModuleBuilder("gc").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			kept = stack[0]
+		}), []api.ValueType{api.ValueTypeAnyref}, []api.ValueType{}).
+		Export("keep").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			_, ok := mod.GCObject(kept)
+			stack[0] = api.EncodeI32(boolToInt32(ok))
+		}), []api.ValueType{}, []api.ValueType{i32}).
+		Export("kept_alive").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	point := &wasm.TypedRef{Nullable: true, HeapType: 0}
+	anyref := wasm.ValueTypeAnyref
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			// $point: (struct (field (mut i32)))
+			{Composite: &wasm.CompositeType{Final: true, Fields: []*wasm.FieldType{{Type: i32, Mutable: true}}}},
+			// (func (param anyref))
+			{Params: []wasm.ValueType{anyref}},
+			// (func (result i32))
+			{Results: []wasm.ValueType{i32}},
+			// (func (param i32) (result i32))
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			// (func (param i32) (result (ref null $point)))
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{anyref}, ResultRefs: []*wasm.TypedRef{point}},
+		},
+		ImportSection: []*wasm.Import{
+			{Module: "gc", Name: "keep", Type: wasm.ExternTypeFunc, DescFunc: 1},
+			{Module: "gc", Name: "kept_alive", Type: wasm.ExternTypeFunc, DescFunc: 2},
+		},
+		FunctionSection: []wasm.Index{3, 4},
+		ExportSection: []*wasm.Export{
+			{Name: "collect", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "new_point", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (type 3) (local (ref null $point))
+			//   (local.set 1 (struct.new $point (i32.const 42)))
+			//   (call $keep (struct.new $point (i32.const 0)))
+			//   (loop (drop (struct.new $point (i32.const 0)))
+			//     (br_if 0 (local.tee 0 (i32.sub (local.get 0) (i32.const 1)))))
+			//   (i32.add (struct.get $point 0 (local.get 1)) (call $kept_alive)))
+			{
+				LocalTypes: []wasm.ValueType{anyref},
+				LocalRefs:  []*wasm.TypedRef{point},
+				Body: []byte{
+					wasm.OpcodeI32Const, 42,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+					wasm.OpcodeLocalSet, 1,
+					wasm.OpcodeI32Const, 0,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+					wasm.OpcodeCall, 0,
+					wasm.OpcodeLoop, 0x40,
+					wasm.OpcodeI32Const, 0,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+					wasm.OpcodeDrop,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeI32Const, 1,
+					wasm.OpcodeI32Sub,
+					wasm.OpcodeLocalTee, 0,
+					wasm.OpcodeBrIf, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeGCPrefix, wasm.OpcodeGCStructGet, 0, 0,
+					wasm.OpcodeCall, 1,
+					wasm.OpcodeI32Add,
+					wasm.OpcodeEnd,
+				},
+			},
+			// (func (type 4) (struct.new $point (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(gcFeatures))
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(module))
+	require.NoError(t, err)
+	return mod
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var interruptTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"context cancel":           testInterruptOnCancel,
+	"context timeout":          testInterruptOnTimeout,
+	"explicit close of module": testInterruptExplicitClose,
+}
+
+func TestEngineCompiler_interrupt(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, interruptTests, wazero.NewRuntimeConfigCompiler().WithInterruptOnContextDone(true))
+}
+
+func TestEngineInterpreter_interrupt(t *testing.T) {
+	runAllTests(t, interruptTests, wazero.NewRuntimeConfigInterpreter().WithInterruptOnContextDone(true))
+}
+
+// interruptWat exports "count_forever", which counts the iterations of its infinite loop in the exported global.
+const interruptWat = `(module
+  (global (export "count") (mut i32) (i32.const 0))
+  (func (export "count_forever")
+    (loop $loop
+      (global.set 0 (i32.add (global.get 0) (i32.const 1)))
+      (br $loop)))
+  (func (export "get_count") (result i32) (global.get 0)))`
+
+func instantiateInterruptModule(t *testing.T, r wazero.Runtime) api.Module {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(interruptWat))
+	require.NoError(t, err)
+	return mod
+}
+
+// requireUsableAfterInterrupt ensures the module isn't closed, and keeps the state left by the interrupted call.
+func requireUsableAfterInterrupt(t *testing.T, mod api.Module) {
+	count := mod.ExportedGlobal("count").Get()
+	require.True(t, count > 0)
+
+	res, err := mod.ExportedFunction("get_count").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, count, res[0])
+}
+
+func testInterruptOnCancel(t *testing.T, r wazero.Runtime) {
+	mod := instantiateInterruptModule(t, r)
+
+	ctx, cancel := context.WithCancel(testCtx)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	_, err := mod.ExportedFunction("count_forever").Call(ctx)
+	require.True(t, errors.Is(err, wazero.ErrInterrupted), err)
+	require.Contains(t, err.Error(), "wasm error: interrupted")
+	requireUsableAfterInterrupt(t, mod)
+}
+
+func testInterruptOnTimeout(t *testing.T, r wazero.Runtime) {
+	mod := instantiateInterruptModule(t, r)
+	countForever := mod.ExportedFunction("count_forever")
+
+	// The same function can be interrupted again, as the interruption is per call.
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(testCtx, 100*time.Millisecond)
+		_, err := countForever.Call(ctx)
+		cancel()
+		require.True(t, errors.Is(err, wazero.ErrInterrupted), err)
+	}
+	requireUsableAfterInterrupt(t, mod)
+}
+
+func testInterruptExplicitClose(t *testing.T, r wazero.Runtime) {
+	mod := instantiateInterruptModule(t, r)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, mod.CloseWithExitCode(testCtx, 2))
+	}()
+	_, err := mod.ExportedFunction("count_forever").Call(testCtx)
+	require.EqualError(t, err, `module "" closed with exit_code(2)`)
+}
@@ -0,0 +1,246 @@
+package adhoc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var memory64Tests = map[string]func(t *testing.T, r wazero.Runtime){
+	"load and store": testMemory64LoadStore,
+	"bounds check":   testMemory64BoundsCheck,
+	"size and grow":  testMemory64SizeGrow,
+	"fill and copy":  testMemory64FillCopy,
+	"atomic":         testMemory64Atomic,
+}
+
+const memory64Features = api.CoreFeaturesV2 | api.CoreFeatureThreads | api.CoreFeatureMemory64
+
+func TestEngineCompiler_memory64(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(memory64Features))
+}
+
+func TestEngineInterpreter_memory64(t *testing.T) {
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(memory64Features))
+}
+
+// memory64Wasm returns a module with a 64-bit memory of one page, initialized with "hello", and functions accessing it
+// with i64 addresses.
+func memory64Wasm(t *testing.T) []byte {
+	i64 := wasm.ValueTypeI64
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i64, i32}},
+			{Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64, i32, i64}},
+			{Params: []wasm.ValueType{i64, i64, i64}},
+			{Params: []wasm.ValueType{i64, i32}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2, 3, 0, 4, 5, 6},
+		MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, Is64: true}},
+		DataSection: []*wasm.DataSegment{
+			{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0}},
+				Init:             []byte("hello"),
+			},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "load", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "store", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "size", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "grow", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "load_4GiB_offset", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "fill", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "copy", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "atomic_add", Type: wasm.ExternTypeFunc, Index: 7},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (param i64) (result i32) (i32.load8_u (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, 0x0, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64 i32) (i32.store8 (local.get 0) (local.get 1)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeI32Store8, 0x0, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (result i64) (memory.size))
+			{Body: []byte{
+				wasm.OpcodeMemorySize, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64) (result i64) (memory.grow (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeMemoryGrow, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64) (result i32) (i32.load8_u offset=0x100000000 (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, 0x0, 0x80, 0x80, 0x80, 0x80, 0x10,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64 i32 i64) (memory.fill (local.get 0) (local.get 1) (local.get 2)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64 i64 i64) (memory.copy (local.get 0) (local.get 1) (local.get 2)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0x0, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i64 i32) (result i32) (i32.atomic.rmw.add (local.get 0) (local.get 1)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RMWAdd, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(memory64Features))
+	return binary.EncodeModule(module)
+}
+
+func testMemory64LoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("load").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64('e'), res[0])
+
+	_, err = mod.ExportedFunction("store").Call(testCtx, 1, 'a')
+	require.NoError(t, err)
+
+	mem := mod.Memory()
+	require.Equal(t, uint64(wasm.MemoryPageSize), mem.Size64())
+	b, ok := mem.Read64(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hallo", string(b))
+
+	require.True(t, mem.Write64(uint64(wasm.MemoryPageSize)-1, []byte{'z'}))
+	res, err = mod.ExportedFunction("load").Call(testCtx, uint64(wasm.MemoryPageSize)-1)
+	require.NoError(t, err)
+	require.Equal(t, uint64('z'), res[0])
+}
+
+func testMemory64BoundsCheck(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	load := mod.ExportedFunction("load")
+	for _, addr := range []uint64{
+		uint64(wasm.MemoryPageSize),
+		// Must not be truncated to 32-bit.
+		1 << 32,
+		// Must not wrap around on the addition of the access size.
+		math.MaxUint64,
+	} {
+		_, err = load.Call(testCtx, addr)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "out of bounds memory access")
+	}
+
+	_, err = mod.ExportedFunction("store").Call(testCtx, 1<<32, 'a')
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+
+	// The static offset of 4GiB is above the memory size.
+	_, err = mod.ExportedFunction("load_4GiB_offset").Call(testCtx, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
+
+func testMemory64SizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	size, grow := mod.ExportedFunction("size"), mod.ExportedFunction("grow")
+	res, err := size.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = grow.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = size.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+
+	// The maximum is two pages, and the failure is -1 as i64.
+	for _, delta := range []uint64{1, 1 << 32} {
+		res, err = grow.Call(testCtx, delta)
+		require.NoError(t, err)
+		require.Equal(t, uint64(math.MaxUint64), res[0])
+	}
+}
+
+func testMemory64FillCopy(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	fill, cp := mod.ExportedFunction("fill"), mod.ExportedFunction("copy")
+	_, err = fill.Call(testCtx, 10, 'x', 3)
+	require.NoError(t, err)
+	_, err = cp.Call(testCtx, 1, 10, 2)
+	require.NoError(t, err)
+
+	b, ok := mod.Memory().Read64(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hxxlo", string(b))
+
+	for _, args := range [][]uint64{
+		{math.MaxUint64, 'x', 2},
+		{0, 'x', 1 << 32},
+	} {
+		_, err = fill.Call(testCtx, args...)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "out of bounds memory access")
+	}
+
+	for _, args := range [][]uint64{
+		{1 << 32, 0, 1},
+		{0, math.MaxUint64, 2},
+	} {
+		_, err = cp.Call(testCtx, args...)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "out of bounds memory access")
+	}
+}
+
+func testMemory64Atomic(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	add := mod.ExportedFunction("atomic_add")
+	_, err = add.Call(testCtx, 8, 42)
+	require.NoError(t, err)
+	v, ok := mod.Memory().ReadUint32Le(8)
+	require.True(t, ok)
+	require.Equal(t, uint32(42), v)
+
+	// Must not be truncated to the address 8.
+	_, err = add.Call(testCtx, 1<<32|8, 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
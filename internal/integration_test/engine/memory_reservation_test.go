@@ -0,0 +1,175 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestEngineCompiler_memoryReservation(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	testMemoryReservation(t, wazero.NewRuntimeConfigCompiler())
+}
+
+func TestEngineInterpreter_memoryReservation(t *testing.T) {
+	testMemoryReservation(t, wazero.NewRuntimeConfigInterpreter())
+}
+
+// testMemoryReservation ensures a view of a reserved memory stays valid when the memory grows.
+func testMemoryReservation(t *testing.T, config wazero.RuntimeConfig) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, config.WithMemoryReservation(true))
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+  (memory (export "memory") 1 10)
+  (func (export "grow_and_store") (param $addr i32) (param $v i32)
+    (drop (memory.grow (i32.const 3)))
+    (i32.store (local.get $addr) (local.get $v))))`))
+	require.NoError(t, err)
+
+	view, ok := mod.Memory().Read(0, 8)
+	require.True(t, ok)
+
+	_, err = mod.ExportedFunction("grow_and_store").Call(testCtx, 4, 0xdeadbeef)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4), mod.Memory().Size()/65536)
+
+	// The view sees the write after growth, as the memory wasn't copied.
+	require.Equal(t, []byte{0, 0, 0, 0, 0xef, 0xbe, 0xad, 0xde}, view)
+
+	// Pages committed by the growth are usable.
+	require.True(t, mod.Memory().WriteUint32Le(4*65536-4, 1))
+}
+
+func TestEngineCompiler_memoryReservation_outOfBounds(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	testMemoryReservationOutOfBounds(t, wazero.NewRuntimeConfigCompiler())
+}
+
+func TestEngineInterpreter_memoryReservation_outOfBounds(t *testing.T) {
+	testMemoryReservationOutOfBounds(t, wazero.NewRuntimeConfigInterpreter())
+}
+
+// testMemoryReservationOutOfBounds ensures the accesses out of the bounds of a reserved memory trap, including when the
+// bounds aren't checked by the compiled code but by the guard pages of the reservation.
+func testMemoryReservationOutOfBounds(t *testing.T, config wazero.RuntimeConfig) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, config.WithMemoryReservation(true))
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+  (memory (export "memory") 0 2)
+  (func (export "grow") (param $delta i32) (result i32) (memory.grow (local.get $delta)))
+  (func (export "load") (param $addr i32) (result i64) (i64.load (local.get $addr)))
+  (func (export "load_offset") (param $addr i32) (result i32) (i32.load8_u offset=4294967295 (local.get $addr)))
+  (func (export "store") (param $addr i32) (param $v i64) (i64.store (local.get $addr) (local.get $v))))`))
+	require.NoError(t, err)
+	load, loadOffset, store := mod.ExportedFunction("load"), mod.ExportedFunction("load_offset"), mod.ExportedFunction("store")
+
+	requireOutOfBounds := func(_ []uint64, err error) {
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "wasm error: out of bounds memory access")
+	}
+
+	// The memory is empty.
+	requireOutOfBounds(load.Call(testCtx, 0))
+	requireOutOfBounds(store.Call(testCtx, 0, 1))
+
+	res, err := mod.ExportedFunction("grow").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	_, err = store.Call(testCtx, 65536-8, 0x1122334455667788)
+	require.NoError(t, err)
+	res, err = load.Call(testCtx, 65536-8)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x1122334455667788), res[0])
+
+	// An access which begins in bounds, but ends out of them.
+	requireOutOfBounds(load.Call(testCtx, 65536-4))
+	requireOutOfBounds(store.Call(testCtx, 65536-4, 1))
+	// The largest effective addresses, out of the maximum of the memory.
+	requireOutOfBounds(load.Call(testCtx, 0xffffffff))
+	requireOutOfBounds(loadOffset.Call(testCtx, 0xffffffff))
+	requireOutOfBounds(loadOffset.Call(testCtx, 0))
+
+	// The module still works after the traps.
+	res, err = load.Call(testCtx, 65536-8)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x1122334455667788), res[0])
+
+	// Closing the module releases the memory, which is empty afterwards.
+	require.NoError(t, mod.Close(testCtx))
+	require.Equal(t, uint32(0), mod.Memory().Size())
+	_, ok := mod.Memory().Read(0, 1)
+	require.False(t, ok)
+}
+
+func TestEngineCompiler_memoryReservation_closeDuringCall(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	testMemoryReservationCloseDuringCall(t, wazero.NewRuntimeConfigCompiler(), true)
+}
+
+func TestEngineInterpreter_memoryReservation_closeDuringCall(t *testing.T) {
+	testMemoryReservationCloseDuringCall(t, wazero.NewRuntimeConfigInterpreter(), false)
+}
+
+// testMemoryReservationCloseDuringCall ensures a call still running when its module is closed can't access memory it
+// doesn't own, even when its compiled code doesn't check the bounds of the memory, which is guarded.
+func testMemoryReservationCloseDuringCall(t *testing.T, config wazero.RuntimeConfig, guarded bool) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, config.WithMemoryReservation(true))
+	defer r.Close(testCtx)
+
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module) {
+			require.NoError(t, mod.Close(ctx))
+		}).Export("close").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+  (import "env" "close" (func $close))
+  (memory (export "memory") 1 10)
+  (func (export "close_and_store") (param $addr i32) (param $v i32)
+    (call $close)
+    (i32.store (local.get $addr) (local.get $v))))`))
+	require.NoError(t, err)
+	mem := mod.Memory()
+	view, ok := mem.Read(0, 8)
+	require.True(t, ok)
+
+	_, err = mod.ExportedFunction("close_and_store").Call(testCtx, 4, 0xdeadbeef)
+	require.Error(t, err)
+	if guarded {
+		// The store isn't checked, but the reservation is still mapped, so it doesn't write into another mapping.
+		require.Contains(t, err.Error(), "module \"\" closed with exit_code(0)")
+		require.Equal(t, []byte{0, 0, 0, 0, 0xef, 0xbe, 0xad, 0xde}, view)
+	} else {
+		require.Contains(t, err.Error(), "out of bounds memory access")
+		require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0}, view)
+	}
+
+	// The view of the closed module stays valid while the memory is reachable, even across garbage collections.
+	runtime.GC()
+	view[0] = 1
+	require.Equal(t, byte(1), view[0])
+	runtime.KeepAlive(mem)
+}
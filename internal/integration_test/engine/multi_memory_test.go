@@ -0,0 +1,235 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var multiMemoryTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"load and store":    testMultiMemoryLoadStore,
+	"bounds check":      testMultiMemoryBoundsCheck,
+	"size and grow":     testMultiMemorySizeGrow,
+	"copy":              testMultiMemoryCopy,
+	"exported memories": testMultiMemoryExportedMemories,
+}
+
+func TestEngineCompiler_multiMemory(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureMultiMemory))
+}
+
+// TestEngineCompiler_multiMemory_memoryReservation ensures the accesses to memories other than the memory of index zero
+// are bounds checked, as only the latter is guarded.
+func TestEngineCompiler_multiMemory_memoryReservation(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureMultiMemory).
+		WithMemoryReservation(true))
+}
+
+func TestEngineInterpreter_multiMemory(t *testing.T) {
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureMultiMemory))
+}
+
+// multiMemoryWasm returns a module with two memories, where the memory 1 is initialized with "hello", and functions
+// accessing both of them.
+func multiMemoryWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}},
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}},
+		},
+		FunctionSection: []wasm.Index{0, 0, 1, 2, 0, 3},
+		MemorySection: []*wasm.Memory{
+			{Min: 2, Cap: 2, Max: wasm.MemoryLimitPages},
+			{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true},
+		},
+		DataSection: []*wasm.DataSegment{
+			{
+				MemoryIndex:      1,
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				Init:             []byte("hello"),
+			},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory0", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "memory1", Type: wasm.ExternTypeMemory, Index: 1},
+			{Name: "load0", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "load1", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "store1", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "size1", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "grow1", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "copy1to0", Type: wasm.ExternTypeFunc, Index: 5},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (param i32) (result i32) (i32.load8_u 0 (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, 0x0, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32) (i32.load8_u 1 (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, 0x40, 0x1, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32 i32) (i32.store8 1 (local.get 0) (local.get 1)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeI32Store8, 0x40, 0x1, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (result i32) (memory.size 1))
+			{Body: []byte{
+				wasm.OpcodeMemorySize, 0x1,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32) (memory.grow 1 (local.get 0)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeMemoryGrow, 0x1,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32 i32 i32) (memory.copy 0 1 (local.get 0) (local.get 1) (local.get 2)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0x0, 0x1,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2|api.CoreFeatureMultiMemory))
+	return binary.EncodeModule(module)
+}
+
+func testMultiMemoryLoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	// The data segment is only applied to the memory 1.
+	res, err := mod.ExportedFunction("load1").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64('h'), res[0])
+	res, err = mod.ExportedFunction("load0").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	_, err = mod.ExportedFunction("store1").Call(testCtx, 1, 'a')
+	require.NoError(t, err)
+
+	b, ok := mod.ExportedMemory("memory1").Read(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hallo", string(b))
+
+	// Memory() is the memory of index zero, which must be untouched.
+	require.Equal(t, mod.ExportedMemory("memory0"), mod.Memory())
+	v, ok := mod.Memory().ReadByte(1)
+	require.True(t, ok)
+	require.Equal(t, byte(0), v)
+}
+
+func testMultiMemoryBoundsCheck(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	// The memory 0 has two pages while the memory 1 has one.
+	_, err = mod.ExportedFunction("load0").Call(testCtx, uint64(wasm.MemoryPageSize))
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("load1").Call(testCtx, uint64(wasm.MemoryPageSize))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+
+	_, err = mod.ExportedFunction("store1").Call(testCtx, uint64(wasm.MemoryPageSize), 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+
+	// The memory 0 must be in use again after the trap above.
+	_, err = mod.ExportedFunction("load0").Call(testCtx, uint64(wasm.MemoryPageSize))
+	require.NoError(t, err)
+}
+
+func testMultiMemorySizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	size1, grow1 := mod.ExportedFunction("size1"), mod.ExportedFunction("grow1")
+	res, err := size1.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = grow1.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = size1.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+
+	// The maximum of the memory 1 is two pages.
+	res, err = grow1.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xffffffff), res[0])
+
+	// The grown page must be accessible.
+	_, err = mod.ExportedFunction("load1").Call(testCtx, uint64(wasm.MemoryPageSize))
+	require.NoError(t, err)
+
+	// The memory 0 must be unchanged.
+	require.Equal(t, uint32(2*wasm.MemoryPageSize), mod.Memory().Size())
+}
+
+func testMultiMemoryCopy(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	copy1to0 := mod.ExportedFunction("copy1to0")
+	_, err = copy1to0.Call(testCtx, 10, 0, 5)
+	require.NoError(t, err)
+
+	b, ok := mod.Memory().Read(10, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(b))
+
+	// The source is checked against the bounds of the memory 1.
+	_, err = copy1to0.Call(testCtx, 0, uint64(wasm.MemoryPageSize), 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+
+	// The destination is checked against the bounds of the memory 0.
+	_, err = copy1to0.Call(testCtx, uint64(2*wasm.MemoryPageSize), 0, 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
+
+func testMultiMemoryExportedMemories(t *testing.T, r wazero.Runtime) {
+	compiled, err := r.CompileModule(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	defs := compiled.ExportedMemories()
+	require.Equal(t, 2, len(defs))
+	require.Equal(t, uint32(2), defs["memory0"].Min())
+	require.Equal(t, uint32(1), defs["memory1"].Min())
+	maxPages, ok := defs["memory1"].Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(2), maxPages)
+
+	mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	require.Equal(t, uint32(2*wasm.MemoryPageSize), mod.ExportedMemory("memory0").Size())
+	require.Equal(t, uint32(wasm.MemoryPageSize), mod.ExportedMemory("memory1").Size())
+	require.Equal(t, 2, len(mod.ExportedMemoryDefinitions()))
+}
@@ -0,0 +1,125 @@
+package adhoc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var poolTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"reset on put":           testPoolResetOnPut,
+	"instantiate when empty": testPoolInstantiateWhenEmpty,
+}
+
+func TestEngineCompiler_pool(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, poolTests, wazero.NewRuntimeConfigCompiler())
+}
+
+func TestEngineInterpreter_pool(t *testing.T) {
+	runAllTests(t, poolTests, wazero.NewRuntimeConfigInterpreter())
+}
+
+// poolWat has a "dirty" function which changes the state set by its start function "init".
+const poolWat = `(module
+  (memory 1 4)
+  (table 1 funcref)
+  (global $starts (export "starts") (mut i32) (i32.const 0))
+  (global $value (mut i32) (i32.const 0))
+  (data $d "hello")
+  (elem declare func $forty_two)
+  (func $forty_two (result i32) (i32.const 42))
+  (func (export "init")
+    (global.set $starts (i32.add (global.get $starts) (i32.const 1)))
+    (global.set $value (i32.const 1))
+    (memory.init $d (i32.const 0) (i32.const 0) (i32.const 5)))
+  (func (export "dirty")
+    (global.set $value (i32.const 2))
+    (i32.store (i32.const 0) (i32.const -1))
+    (i32.store (memory.grow (i32.const 2)) (i32.const -1))
+    (i32.store (i32.const 131072) (i32.const -1))
+    (drop (table.grow (ref.func $forty_two) (i32.const 3)))
+    (data.drop $d))
+  (func (export "state") (result i32 i32 i32 i32)
+    (global.get $value) (memory.size) (table.size) (i32.load (i32.const 0)))
+  (func (export "reinit")
+    (memory.init $d (i32.const 8) (i32.const 0) (i32.const 5))))`
+
+func newTestPool(t *testing.T, r wazero.Runtime) wazero.ModulePool {
+	compiled, err := r.CompileModule(testCtx, []byte(poolWat))
+	require.NoError(t, err)
+	pool, err := r.NewModulePool(testCtx, compiled, wazero.NewModuleConfig().WithName("pool").WithStartFunctions("init"))
+	require.NoError(t, err)
+	return pool
+}
+
+// requireInitialState ensures the module has the state right after its instantiation.
+func requireInitialState(t *testing.T, mod api.Module) {
+	require.Equal(t, uint64(1), mod.ExportedGlobal("starts").Get())
+
+	state, err := mod.ExportedFunction("state").Call(testCtx)
+	require.NoError(t, err)
+	hello := uint64(binary.LittleEndian.Uint32([]byte("hell")))
+	require.Equal(t, []uint64{1, 1, 1, hello}, state)
+
+	// The data segment isn't dropped.
+	_, err = mod.ExportedFunction("reinit").Call(testCtx)
+	require.NoError(t, err)
+}
+
+func testPoolResetOnPut(t *testing.T, r wazero.Runtime) {
+	pool := newTestPool(t, r)
+	defer pool.Close(testCtx)
+
+	mod, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, "pool#1", mod.Name())
+	requireInitialState(t, mod)
+
+	for i := 0; i < 2; i++ {
+		_, err = mod.ExportedFunction("dirty").Call(testCtx)
+		require.NoError(t, err)
+		require.NoError(t, pool.Put(testCtx, mod))
+
+		// The same instance is reused.
+		reused, err := pool.Get(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, mod, reused)
+		requireInitialState(t, mod)
+	}
+
+	// The memory removed by the reset reads as zero when it grows again.
+	_, err = mod.ExportedFunction("dirty").Call(testCtx)
+	require.NoError(t, err)
+	require.NoError(t, pool.Put(testCtx, mod))
+	_, ok := mod.Memory().Grow(2)
+	require.True(t, ok)
+	v, ok := mod.Memory().ReadUint32Le(131072)
+	require.True(t, ok)
+	require.Equal(t, uint32(0), v)
+}
+
+func testPoolInstantiateWhenEmpty(t *testing.T, r wazero.Runtime) {
+	pool := newTestPool(t, r)
+	defer pool.Close(testCtx)
+
+	first, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	_, err = first.ExportedFunction("dirty").Call(testCtx)
+	require.NoError(t, err)
+
+	// The new instance starts from the state of the first one right after its instantiation.
+	second, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, "pool#2", second.Name())
+	requireInitialState(t, second)
+
+	require.NoError(t, pool.Put(testCtx, first))
+	require.NoError(t, pool.Put(testCtx, second))
+}
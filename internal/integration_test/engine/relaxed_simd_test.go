@@ -0,0 +1,258 @@
+package adhoc
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var relaxedSIMDTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"swizzle":    testRelaxedSIMDSwizzle,
+	"trunc":      testRelaxedSIMDTrunc,
+	"laneselect": testRelaxedSIMDLaneselect,
+	"min max":    testRelaxedSIMDMinMax,
+	"madd":       testRelaxedSIMDMadd,
+	"dot":        testRelaxedSIMDDot,
+}
+
+const relaxedSIMDFeatures = api.CoreFeaturesV2 | api.CoreFeatureRelaxedSIMD
+
+func TestEngineCompiler_relaxedSIMD(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, relaxedSIMDTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(relaxedSIMDFeatures))
+}
+
+func TestEngineInterpreter_relaxedSIMD(t *testing.T) {
+	runAllTests(t, relaxedSIMDTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(relaxedSIMDFeatures))
+}
+
+// relaxedSIMDOperands are the number of the operands of each relaxed SIMD instruction exported by relaxedSIMDWasm.
+var relaxedSIMDOperands = map[wasm.OpcodeVecRelaxed]int{
+	wasm.OpcodeVecI8x16RelaxedSwizzle:           2,
+	wasm.OpcodeVecI32x4RelaxedTruncF32x4S:       1,
+	wasm.OpcodeVecI32x4RelaxedTruncF32x4U:       1,
+	wasm.OpcodeVecF32x4RelaxedMadd:              3,
+	wasm.OpcodeVecF32x4RelaxedNmadd:             3,
+	wasm.OpcodeVecF64x2RelaxedMadd:              3,
+	wasm.OpcodeVecI8x16RelaxedLaneselect:        3,
+	wasm.OpcodeVecF32x4RelaxedMin:               2,
+	wasm.OpcodeVecF64x2RelaxedMax:               2,
+	wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:    2,
+	wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS: 3,
+}
+
+// relaxedSIMDWasm instantiates a module exporting a function per relaxedSIMDOperands, named after the instruction.
+// Each function loads the operands from the offsets 16, 32 and 48 of the memory, and stores the result at zero.
+func relaxedSIMDWasm(t *testing.T, ctx context.Context, r wazero.Runtime) api.Module {
+	module := &wasm.Module{
+		TypeSection:   []*wasm.FunctionType{{}},
+		MemorySection: []*wasm.Memory{{Min: 1, Cap: 1, Max: 1}},
+		ExportSection: []*wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+	}
+	for op, operands := range relaxedSIMDOperands {
+		body := []byte{wasm.OpcodeI32Const, 0}
+		for i := 1; i <= operands; i++ {
+			body = append(body, wasm.OpcodeI32Const, byte(i*16), wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Load, 4, 0)
+		}
+		// The relaxed opcodes are encoded as 0x100+op in LEB128.
+		body = append(body, wasm.OpcodeVecPrefix, 0x80|op, 0x02)
+		body = append(body, wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Store, 4, 0, wasm.OpcodeEnd)
+
+		module.ExportSection = append(module.ExportSection, &wasm.Export{
+			Name:  wasm.RelaxedVectorInstructionName(op),
+			Type:  wasm.ExternTypeFunc,
+			Index: uint32(len(module.FunctionSection)),
+		})
+		module.FunctionSection = append(module.FunctionSection, 0)
+		module.CodeSection = append(module.CodeSection, &wasm.Code{Body: body})
+	}
+	require.NoError(t, module.Validate(relaxedSIMDFeatures))
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, binaryformat.EncodeModule(module))
+	require.NoError(t, err)
+	return mod
+}
+
+// callRelaxedSIMD calls the function for the instruction with the given operands, and returns the result.
+func callRelaxedSIMD(t *testing.T, mod api.Module, name string, operands ...[]byte) []byte {
+	for i, operand := range operands {
+		require.True(t, mod.Memory().Write(uint32(i+1)*16, operand))
+	}
+	_, err := mod.ExportedFunction(name).Call(testCtx)
+	require.NoError(t, err)
+	ret, ok := mod.Memory().Read(0, 16)
+	require.True(t, ok)
+	return ret
+}
+
+func i8x16(lanes ...int8) []byte {
+	ret := make([]byte, 16)
+	for i, l := range lanes {
+		ret[i] = byte(l)
+	}
+	return ret
+}
+
+func i16x8(lanes ...int16) []byte {
+	ret := make([]byte, 16)
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint16(ret[i*2:], uint16(l))
+	}
+	return ret
+}
+
+func i32x4(lanes ...int32) []byte {
+	ret := make([]byte, 16)
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint32(ret[i*4:], uint32(l))
+	}
+	return ret
+}
+
+func f32x4(lanes ...float32) []byte {
+	ret := make([]byte, 16)
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint32(ret[i*4:], math.Float32bits(l))
+	}
+	return ret
+}
+
+func f64x2(lanes ...float64) []byte {
+	ret := make([]byte, 16)
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint64(ret[i*8:], math.Float64bits(l))
+	}
+	return ret
+}
+
+func testRelaxedSIMDSwizzle(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	base := i8x16(10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25)
+	// Out-of-range indexes result in zero as i8x16.swizzle does.
+	idx := i8x16(15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, -128, 16, 0)
+	require.Equal(t, i8x16(25, 24, 23, 22, 21, 20, 19, 18, 17, 16, 15, 14, 13, 0, 0, 10),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI8x16RelaxedSwizzleName, base, idx))
+}
+
+func testRelaxedSIMDTrunc(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	nan := float32(math.NaN())
+	// NaN and out-of-range lanes saturate as i32x4.trunc_sat_f32x4_{s,u} do.
+	require.Equal(t, i32x4(1, -2, 0, math.MaxInt32),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI32x4RelaxedTruncF32x4SName, f32x4(1.5, -2.5, nan, 3e10)))
+	require.Equal(t, i32x4(1, 0, 0, -1), // -1 is math.MaxUint32.
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI32x4RelaxedTruncF32x4UName, f32x4(1.5, -2.5, nan, 5e10)))
+}
+
+func testRelaxedSIMDLaneselect(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	a := i8x16(1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	b := i8x16(2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2)
+	// The mask selects bits as v128.bitselect does.
+	mask := i8x16(-1, 0, -1, 0, -1, 0, -1, 0, -1, 0, -1, 0, -1, 0, 1, 2)
+	require.Equal(t, i8x16(1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 3, 0),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI8x16RelaxedLaneselectName, a, b, mask))
+}
+
+func testRelaxedSIMDMinMax(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	require.Equal(t, f32x4(1, -2, float32(math.Copysign(0, -1)), 3),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedMinName,
+			f32x4(1, 5, 0, 3), f32x4(2, -2, float32(math.Copysign(0, -1)), 4)))
+	require.Equal(t, f64x2(2, 0),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecF64x2RelaxedMaxName, f64x2(1, float64(math.Copysign(0, -1))), f64x2(2, 0)))
+}
+
+func testRelaxedSIMDMadd(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	a, b, c := f32x4(1, 2, 3, 4), f32x4(5, 6, 7, 8), f32x4(1, 1, 1, 1)
+	require.Equal(t, f32x4(6, 13, 22, 33), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedMaddName, a, b, c))
+	require.Equal(t, f32x4(-4, -11, -20, -31), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedNmaddName, a, b, c))
+	require.Equal(t, f64x2(-5, 0.5), callRelaxedSIMD(t, mod, wasm.OpcodeVecF64x2RelaxedMaddName,
+		f64x2(2, 0.25), f64x2(-3, 2), f64x2(1, 0)))
+
+	// The product of (1+2^-23)*(1-2^-23) is rounded to one before adding -1, so the result is exactly zero.
+	require.Equal(t, f32x4(0, 0, 0, 0), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedMaddName,
+		f32x4(1+0x1p-23, 1+0x1p-23, 1+0x1p-23, 1+0x1p-23),
+		f32x4(1-0x1p-23, 1-0x1p-23, 1-0x1p-23, 1-0x1p-23),
+		f32x4(-1, -1, -1, -1)))
+}
+
+func testRelaxedSIMDDot(t *testing.T, r wazero.Runtime) {
+	mod := relaxedSIMDWasm(t, testCtx, r)
+	defer mod.Close(testCtx)
+
+	a := i8x16(1, 2, -3, 4, 127, 127, -128, -128, 0, 1, 2, 3, 4, 5, 6, 7)
+	b := i8x16(1, 1, 2, 2, 127, 127, 127, 127, 7, 6, 5, 4, 3, 2, 1, 0)
+	require.Equal(t, i16x8(3, 2, 32258, -32512, 6, 22, 22, 6),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName, a, b))
+	require.Equal(t, i32x4(5+1, 32258-32512+2, 28+3, 28-4),
+		callRelaxedSIMD(t, mod, wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName, a, b, i32x4(1, 2, 3, -4)))
+}
+
+// TestEngineCompiler_relaxedSIMDNative ensures the native lowering of relaxed SIMD instructions matches the
+// deterministic one for the inputs which the proposal defines, and that the native madd is fused where supported.
+func TestEngineCompiler_relaxedSIMDNative(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	ctx := context.WithValue(testCtx, experimental.RelaxedSIMDNativeKey{}, true)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(relaxedSIMDFeatures))
+	defer r.Close(ctx)
+
+	mod := relaxedSIMDWasm(t, ctx, r)
+
+	t.Run("swizzle", func(t *testing.T) {
+		base := i8x16(10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25)
+		idx := i8x16(15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, -128, 1, 0)
+		require.Equal(t, i8x16(25, 24, 23, 22, 21, 20, 19, 18, 17, 16, 15, 14, 13, 0, 11, 10),
+			callRelaxedSIMD(t, mod, wasm.OpcodeVecI8x16RelaxedSwizzleName, base, idx))
+	})
+
+	t.Run("madd", func(t *testing.T) {
+		a, b, c := f32x4(1, 2, 3, 4), f32x4(5, 6, 7, 8), f32x4(1, 1, 1, 1)
+		require.Equal(t, f32x4(6, 13, 22, 33), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedMaddName, a, b, c))
+		require.Equal(t, f32x4(-4, -11, -20, -31), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedNmaddName, a, b, c))
+
+		expected := float32(0)
+		if platform.CpuFeatures.Has(platform.CpuFeatureFMA) {
+			expected = -0x1p-46 // The product is not rounded.
+		}
+		require.Equal(t, f32x4(expected, expected, expected, expected), callRelaxedSIMD(t, mod, wasm.OpcodeVecF32x4RelaxedMaddName,
+			f32x4(1+0x1p-23, 1+0x1p-23, 1+0x1p-23, 1+0x1p-23),
+			f32x4(1-0x1p-23, 1-0x1p-23, 1-0x1p-23, 1-0x1p-23),
+			f32x4(-1, -1, -1, -1)))
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		// The lanes of the second operand fit in 7 bits.
+		a := i8x16(1, 2, -3, 4, 127, 127, -128, -128, 0, 1, 2, 3, 4, 5, 6, 7)
+		b := i8x16(1, 1, 2, 2, 127, 127, 127, 127, 7, 6, 5, 4, 3, 2, 1, 0)
+		require.Equal(t, i16x8(3, 2, 32258, -32512, 6, 22, 22, 6),
+			callRelaxedSIMD(t, mod, wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName, a, b))
+		require.Equal(t, i32x4(5+1, 32258-32512+2, 28+3, 28-4),
+			callRelaxedSIMD(t, mod, wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName, a, b, i32x4(1, 2, 3, -4)))
+	})
+}
@@ -0,0 +1,130 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var snapshotTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"instantiate from snapshot": testSnapshotInstantiate,
+	"pre-initialized binary":    testSnapshotPreInitializedBinary,
+}
+
+func TestEngineCompiler_snapshot(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, snapshotTests, wazero.NewRuntimeConfigCompiler())
+}
+
+func TestEngineInterpreter_snapshot(t *testing.T) {
+	runAllTests(t, snapshotTests, wazero.NewRuntimeConfigInterpreter())
+}
+
+// snapshotWat has an "init" function which changes the memory, globals and table, and drops its data segment.
+const snapshotWat = `(module
+  (memory (export "memory") 1 3)
+  (table 1 funcref)
+  (global $starts (export "starts") (mut i32) (i32.const 0))
+  (global $counter (mut i32) (i32.const 0))
+  (global $f (mut funcref) (ref.null func))
+  (data $d "hello")
+  (elem declare func $forty_two)
+  (func $forty_two (result i32) (i32.const 42))
+  (func $start (global.set $starts (i32.add (global.get $starts) (i32.const 1))))
+  (start $start)
+  (func (export "init")
+    (drop (memory.grow (i32.const 1)))
+    (memory.init $d (i32.const 65536) (i32.const 0) (i32.const 5))
+    (data.drop $d)
+    (drop (table.grow (ref.func $forty_two) (i32.const 1)))
+    (global.set $f (ref.func $forty_two))
+    (global.set $counter (i32.const 10)))
+  (func (export "increment") (result i32)
+    (global.set $counter (i32.add (global.get $counter) (i32.const 1)))
+    (global.get $counter))
+  (func (export "call_table") (result i32)
+    (call_indirect (result i32) (i32.const 1)))
+  (func (export "call_global") (result i32)
+    (table.set (i32.const 0) (global.get $f))
+    (call_indirect (result i32) (i32.const 0)))
+  (func (export "init_again")
+    (memory.init $d (i32.const 0) (i32.const 0) (i32.const 5))))`
+
+// takeInitializedSnapshot instantiates snapshotWat, calls "init" and "increment", then returns its snapshot.
+func takeInitializedSnapshot(t *testing.T, r wazero.Runtime) (wazero.CompiledModule, wazero.Snapshot) {
+	compiled, err := r.CompileModule(testCtx, []byte(snapshotWat))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithStartFunctions("init"))
+	require.NoError(t, err)
+	res, err := mod.ExportedFunction("increment").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), res[0])
+
+	snapshot, err := wazero.TakeSnapshot(mod)
+	require.NoError(t, err)
+
+	// Closing the module doesn't affect the snapshot.
+	require.NoError(t, mod.Close(testCtx))
+	return compiled, snapshot
+}
+
+// requireInitialized ensures the module has the state of the snapshot from takeInitializedSnapshot.
+func requireInitialized(t *testing.T, mod api.Module) {
+	require.Equal(t, uint32(2*65536), mod.Memory().Size())
+	b, ok := mod.Memory().Read(65536, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(b))
+
+	// The start function didn't run again.
+	require.Equal(t, uint64(1), mod.ExportedGlobal("starts").Get())
+
+	res, err := mod.ExportedFunction("increment").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), res[0])
+
+	res, err = mod.ExportedFunction("call_table").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	res, err = mod.ExportedFunction("call_global").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	// The data segment is still dropped.
+	_, err = mod.ExportedFunction("init_again").Call(testCtx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
+
+func testSnapshotInstantiate(t *testing.T, r wazero.Runtime) {
+	compiled, snapshot := takeInitializedSnapshot(t, r)
+
+	// Each instance has its own copy of the state.
+	for _, name := range []string{"a", "b"} {
+		mod, err := r.InstantiateModuleFromSnapshot(testCtx, compiled, snapshot,
+			wazero.NewModuleConfig().WithName(name).WithStartFunctions())
+		require.NoError(t, err)
+		requireInitialized(t, mod)
+	}
+}
+
+func testSnapshotPreInitializedBinary(t *testing.T, r wazero.Runtime) {
+	_, snapshot := takeInitializedSnapshot(t, r)
+
+	bin, err := snapshot.PreInitializedBinary()
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(testCtx, bin)
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b"} {
+		mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName(name))
+		require.NoError(t, err)
+		requireInitialized(t, mod)
+	}
+}
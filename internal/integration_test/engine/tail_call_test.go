@@ -0,0 +1,245 @@
+package adhoc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var tailCallTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"deep mutual recursion":          testTailCallMutualRecursion,
+	"more params than the caller":    testTailCallMoreParams,
+	"host function":                  testTailCallHostFunction,
+	"indirect":                       testTailCallIndirect,
+	"indirect type mismatch":         testTailCallIndirectTypeMismatch,
+	"mutual recursion with listener": testTailCallWithListener,
+}
+
+func TestEngineCompiler_tailCall(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, tailCallTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureTailCall))
+}
+
+func TestEngineInterpreter_tailCall(t *testing.T) {
+	runAllTests(t, tailCallTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureTailCall))
+}
+
+// tailCallWasm returns a module which imports "env" "double", and exports functions using tail calls.
+func tailCallWasm(t *testing.T) []byte {
+	i64 := wasm.ValueTypeI64
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64, i64, i64, i64, i64}, Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64, i32}, Results: []wasm.ValueType{i64}},
+		},
+		// (import "env" "double" (func $double (param i64) (result i64)))
+		ImportSection: []*wasm.Import{
+			{Module: "env", Name: "double", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{0, 0, 1, 0, 0, 2},
+		TableSection:    []*wasm.Table{{Min: 5, Type: wasm.RefTypeFuncref}},
+		ElementSection: []*wasm.ElementSegment{
+			{
+				OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				Init:       []*wasm.Index{uint32Ptr(0), uint32Ptr(1), uint32Ptr(2), uint32Ptr(4), uint32Ptr(3)},
+				Type:       wasm.RefTypeFuncref,
+			},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "is_even", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "is_odd", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "spread", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "to_host", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "indirect", Type: wasm.ExternTypeFunc, Index: 6},
+		},
+		CodeSection: []*wasm.Code{
+			// (func $is_even (param $n i64) (result i64) (local i64 i32)
+			//   (if (i64.eqz (local.get $n)) (then (return (i64.const 1))))
+			//   (return_call $is_odd (i64.sub (local.get $n) (i64.const 1))))
+			{LocalTypes: []wasm.ValueType{i64, i32}, Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Eqz,
+				wasm.OpcodeIf, 0x40, wasm.OpcodeI64Const, 1, wasm.OpcodeReturn, wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Const, 1, wasm.OpcodeI64Sub,
+				wasm.OpcodeReturnCall, 2,
+				wasm.OpcodeEnd,
+			}},
+			// (func $is_odd (param $n i64) (result i64)
+			//   (if (i64.eqz (local.get $n)) (then (return (i64.const 0))))
+			//   (return_call $is_even (i64.sub (local.get $n) (i64.const 1))))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Eqz,
+				wasm.OpcodeIf, 0x40, wasm.OpcodeI64Const, 0, wasm.OpcodeReturn, wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Const, 1, wasm.OpcodeI64Sub,
+				wasm.OpcodeReturnCall, 1,
+				wasm.OpcodeEnd,
+			}},
+			// (func $sum (param i64 i64 i64 i64 i64) (result i64)
+			//   local.get 0 local.get 1 i64.add local.get 2 i64.add local.get 3 i64.add local.get 4 i64.add)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 2, wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 3, wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 4, wasm.OpcodeI64Add,
+				wasm.OpcodeEnd,
+			}},
+			// (func $spread (param $x i64) (result i64)
+			//   (return_call $sum (local.get $x) (local.get $x) (local.get $x) (local.get $x) (local.get $x)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 3,
+				wasm.OpcodeEnd,
+			}},
+			// (func $to_host (param $x i64) (result i64) (return_call $double (local.get $x)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			}},
+			// (func $indirect (param $x i64) (param $i i32) (result i64)
+			//   (return_call_indirect (type 0) (local.get $x) (local.get $i)))
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeReturnCallIndirect, 0, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2|api.CoreFeatureTailCall))
+	return binary.EncodeModule(module)
+}
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func instantiateTailCallModule(ctx context.Context, t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(x uint64) uint64 { return x * 2 }).
+		Export("double").
+		Instantiate(ctx)
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(ctx, tailCallWasm(t))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+	return mod
+}
+
+func testTailCallMutualRecursion(t *testing.T, r wazero.Runtime) {
+	mod := instantiateTailCallModule(testCtx, t, r)
+
+	// This is deep enough to overflow the call stack unless frames are reused.
+	const n = 1_000_001
+	res, err := mod.ExportedFunction("is_even").Call(testCtx, n)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	res, err = mod.ExportedFunction("is_odd").Call(testCtx, n)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+}
+
+func testTailCallMoreParams(t *testing.T, r wazero.Runtime) {
+	mod := instantiateTailCallModule(testCtx, t, r)
+
+	res, err := mod.ExportedFunction("spread").Call(testCtx, 7)
+	require.NoError(t, err)
+	require.Equal(t, uint64(35), res[0])
+}
+
+func testTailCallHostFunction(t *testing.T, r wazero.Runtime) {
+	mod := instantiateTailCallModule(testCtx, t, r)
+
+	res, err := mod.ExportedFunction("to_host").Call(testCtx, 21)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testTailCallIndirect(t *testing.T, r wazero.Runtime) {
+	mod := instantiateTailCallModule(testCtx, t, r)
+
+	indirect := mod.ExportedFunction("indirect")
+	for _, tc := range []struct {
+		index         uint64
+		param, result uint64
+	}{
+		{index: 0, param: 21, result: 42},  // $double
+		{index: 1, param: 1001, result: 0}, // $is_even
+		{index: 2, param: 1001, result: 1}, // $is_odd
+		{index: 3, param: 3, result: 15},   // $spread
+	} {
+		res, err := indirect.Call(testCtx, tc.param, tc.index)
+		require.NoError(t, err)
+		require.Equal(t, tc.result, res[0])
+	}
+}
+
+func testTailCallIndirectTypeMismatch(t *testing.T, r wazero.Runtime) {
+	mod := instantiateTailCallModule(testCtx, t, r)
+
+	indirect := mod.ExportedFunction("indirect")
+	// $sum at index 4 has a different type.
+	_, err := indirect.Call(testCtx, 1, 4)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "indirect call type mismatch")
+
+	_, err = indirect.Call(testCtx, 1, 5)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid table access")
+}
+
+func testTailCallWithListener(t *testing.T, r wazero.Runtime) {
+	var returns []string
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, returnListenerFactory(func(name string) {
+		returns = append(returns, name)
+	}))
+	mod := instantiateTailCallModule(ctx, t, r)
+
+	res, err := mod.ExportedFunction("is_even").Call(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	// Frames aren't reused when listeners are attached, so that each of them is notified on return, innermost first.
+	require.Equal(t, []string{"is_odd", "is_even", "is_odd", "is_even"}, returns)
+}
+
+// returnListenerFactory is an experimental.FunctionListenerFactory which calls the function with the name of the
+// exported function on return.
+type returnListenerFactory func(name string)
+
+// NewListener implements experimental.FunctionListenerFactory.NewListener
+func (f returnListenerFactory) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	if names := def.ExportNames(); len(names) > 0 {
+		return returnListener{f: f, name: names[0]}
+	}
+	return nil
+}
+
+type returnListener struct {
+	f    returnListenerFactory
+	name string
+}
+
+// Before implements experimental.FunctionListener.Before
+func (returnListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	return ctx
+}
+
+// After implements experimental.FunctionListener.After
+func (l returnListener) After(context.Context, api.Module, api.FunctionDefinition, error, []uint64) {
+	l.f(l.name)
+}
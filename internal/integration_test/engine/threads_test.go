@@ -0,0 +1,277 @@
+package adhoc
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var threadsTests = map[string]func(t *testing.T, r wazero.Runtime){
+	"atomic rmw":       testAtomicRMW,
+	"atomic unaligned": testAtomicUnaligned,
+	"atomic wait":      testAtomicWait,
+	"atomic notify":    testAtomicNotify,
+	"shared grow":      testSharedMemoryGrow,
+}
+
+func TestEngineCompiler_threads(t *testing.T) {
+	if !platform.CompilerSupported() || !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+	runAllTests(t, threadsTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureThreads))
+}
+
+func TestEngineInterpreter_threads(t *testing.T) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+	runAllTests(t, threadsTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureThreads))
+}
+
+// atomicsWasm returns a module with a shared memory of one page and functions exercising atomic instructions.
+func atomicsWasm(t *testing.T) []byte {
+	i64 := wasm.ValueTypeI64
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i64}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2, 3, 0},
+		MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
+		ExportSection: []*wasm.Export{
+			{Name: "add", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "cmpxchg", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "load8_u", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "wait32", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "notify", Type: wasm.ExternTypeFunc, Index: 4},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (param i32 i32) (result i32) local.get 0 local.get 1 i32.atomic.rmw.add)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RMWAdd, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32 i32 i32) (result i32) local.get 0 local.get 1 local.get 2 i32.atomic.rmw.cmpxchg)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RMWCmpxchg, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32) (result i32) local.get 0 i32.atomic.load8_u)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32Load8U, 0x0, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32 i32 i64) (result i32) local.get 0 local.get 1 local.get 2 memory.atomic.wait32)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryWait32, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			// (func (param i32 i32) (result i32) local.get 0 local.get 1 memory.atomic.notify)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryNotify, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2|api.CoreFeatureThreads))
+	return binary.EncodeModule(module)
+}
+
+func testAtomicRMW(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, atomicsWasm(t))
+	require.NoError(t, err)
+
+	const goroutines, iterations = 8, 100
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			// api.Function is not goroutine-safe, so each goroutine needs its own.
+			add := mod.ExportedFunction("add")
+			for j := 0; j < iterations; j++ {
+				_, err := add.Call(testCtx, 0, 1)
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, ok := mod.Memory().ReadUint32Le(0)
+	require.True(t, ok)
+	require.Equal(t, uint32(goroutines*iterations), v)
+
+	cmpxchg := mod.ExportedFunction("cmpxchg")
+	// Mismatched expectation leaves the value unchanged.
+	res, err := cmpxchg.Call(testCtx, 0, 1, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(goroutines*iterations), res[0])
+	// Matched expectation replaces it.
+	res, err = cmpxchg.Call(testCtx, 0, goroutines*iterations, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(goroutines*iterations), res[0])
+
+	res, err = mod.ExportedFunction("load8_u").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testAtomicUnaligned(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, atomicsWasm(t))
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("add").Call(testCtx, 1, 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unaligned atomic")
+
+	_, err = mod.ExportedFunction("add").Call(testCtx, uint64(wasm.MemoryPageSize), 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
+
+func testAtomicWait(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, atomicsWasm(t))
+	require.NoError(t, err)
+
+	wait32 := mod.ExportedFunction("wait32")
+	// Value doesn't match the expectation.
+	res, err := wait32.Call(testCtx, 0, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	// Value matches, but nobody notifies within the timeout of 1ms.
+	res, err = wait32.Call(testCtx, 0, 0, 1_000_000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+}
+
+func testAtomicNotify(t *testing.T, r wazero.Runtime) {
+	mod, err := r.InstantiateModuleFromBinary(testCtx, atomicsWasm(t))
+	require.NoError(t, err)
+
+	notify := mod.ExportedFunction("notify")
+	// Nothing is waiting yet.
+	res, err := notify.Call(testCtx, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	done := make(chan uint64)
+	go func() {
+		res, err := mod.ExportedFunction("wait32").Call(testCtx, 0, 0, api.EncodeI64(-1))
+		require.NoError(t, err)
+		done <- res[0]
+	}()
+
+	// Retry until the waiter is registered and woken.
+	for {
+		res, err = notify.Call(testCtx, 0, 1)
+		require.NoError(t, err)
+		if res[0] == 1 {
+			break
+		}
+	}
+	require.Equal(t, uint64(0), <-done)
+}
+
+// sharedGrowWasm returns a module with a shared memory, defined or imported from the module "shared", and functions
+// accessing it after spinning until the word at address zero isn't zero, which another agent sets after growing it.
+// The functions set the word at address four before spinning.
+func sharedGrowWasm(t *testing.T, imported bool) []byte {
+	mem := &wasm.Memory{Min: 1, Cap: 1, Max: 8, IsMaxEncoded: true, IsShared: true}
+	// (i32.atomic.store (i32.const 4) (i32.const 1)) (loop (br_if 0 (i32.eqz (i32.atomic.load (i32.const 0)))))
+	spin := []byte{
+		wasm.OpcodeI32Const, 4, wasm.OpcodeI32Const, 1, wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32Store, 0x2, 0x0,
+		wasm.OpcodeLoop, 0x40,
+		wasm.OpcodeI32Const, 0, wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32Load, 0x2, 0x0,
+		wasm.OpcodeI32Eqz, wasm.OpcodeBrIf, 0,
+		wasm.OpcodeEnd,
+	}
+	module := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1},
+		ExportSection: []*wasm.Export{
+			{Name: "wait_and_load", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "wait_and_size", Type: wasm.ExternTypeFunc, Index: 1},
+		},
+		CodeSection: []*wasm.Code{
+			// (func (param i32) (result i32) spin (i32.load (local.get 0)))
+			{Body: append(append([]byte{}, spin...),
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			)},
+			// (func (result i32) spin (memory.size))
+			{Body: append(append([]byte{}, spin...),
+				wasm.OpcodeMemorySize, 0x0,
+				wasm.OpcodeEnd,
+			)},
+		},
+	}
+	if imported {
+		module.ImportSection = []*wasm.Import{{Module: "shared", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: mem}}
+	} else {
+		module.MemorySection = []*wasm.Memory{mem}
+		module.ExportSection = append(module.ExportSection, &wasm.Export{Name: "memory", Type: wasm.ExternTypeMemory})
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2|api.CoreFeatureThreads))
+	return binary.EncodeModule(module)
+}
+
+// testSharedMemoryGrow ensures the growth of a shared memory by another agent is visible to the running functions, with
+// the memory defined by their module or imported.
+func testSharedMemoryGrow(t *testing.T, r wazero.Runtime) {
+	instantiate := func(name string, imported bool) api.Module {
+		compiled, err := r.CompileModule(testCtx, sharedGrowWasm(t, imported))
+		require.NoError(t, err)
+		mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName(name))
+		require.NoError(t, err)
+		return mod
+	}
+	defining, importing := instantiate("shared", false), instantiate("importing", true)
+	mem := defining.Memory()
+
+	// callAfterGrow calls fn in another goroutine, and grows the memory once it spins.
+	callAfterGrow := func(fn api.Function, params ...uint64) uint64 {
+		done := make(chan uint64)
+		go func() {
+			res, err := fn.Call(testCtx, params...)
+			require.NoError(t, err)
+			done <- res[0]
+		}()
+		for started := uint32(0); started == 0; started, _ = mem.ReadUint32Le(4) {
+			runtime.Gosched()
+		}
+		_, ok := mem.Grow(1)
+		require.True(t, ok)
+		require.True(t, mem.WriteUint32Le(0, 1))
+		res := <-done
+		require.True(t, mem.WriteUint32Le(0, 0))
+		require.True(t, mem.WriteUint32Le(4, 0))
+		return res
+	}
+
+	for _, mod := range []api.Module{defining, importing} {
+		// The address is out of bounds until the memory grows.
+		size := mem.Size()
+		require.Equal(t, uint64(0), callAfterGrow(mod.ExportedFunction("wait_and_load"), uint64(size)))
+
+		res := callAfterGrow(mod.ExportedFunction("wait_and_size"))
+		require.Equal(t, uint64(mem.Size()/wasm.MemoryPageSize), res)
+	}
+}
@@ -329,12 +329,13 @@ func addSpectestModule(t *testing.T, ctx context.Context, s *wasm.Store, enabled
 
 	maybeSetMemoryCap(mod)
 	mod.BuildMemoryDefinitions()
+	mod.BuildTableDefinitions()
 	mod.BuildFunctionDefinitions()
 
 	err = mod.Validate(enabledFeatures)
 	require.NoError(t, err)
 
-	err = s.Engine.CompileModule(ctx, mod, nil, false)
+	err = s.Engine.CompileModule(ctx, mod, nil, false, false, false)
 	require.NoError(t, err)
 
 	_, err = s.Instantiate(ctx, mod, mod.NameSection.ModuleName, sys.DefaultContext(nil))
@@ -343,7 +344,7 @@ func addSpectestModule(t *testing.T, ctx context.Context, s *wasm.Store, enabled
 
 // maybeSetMemoryCap assigns wasm.Memory Cap to Min, which is what wazero.CompileModule would do.
 func maybeSetMemoryCap(mod *wasm.Module) {
-	if mem := mod.MemorySection; mem != nil {
+	for _, mem := range mod.MemorySection {
 		mem.Cap = mem.Min
 	}
 }
@@ -390,7 +391,7 @@ func Run(t *testing.T, testDataFS embed.FS, ctx context.Context, fc filecache.Ca
 						mod, err := binaryformat.DecodeModule(buf, enabledFeatures, wasm.MemoryLimitPages, false, false, false)
 						require.NoError(t, err, msg)
 						require.NoError(t, mod.Validate(enabledFeatures))
-						mod.AssignModuleID(buf)
+						mod.AssignModuleID(buf, false, false)
 
 						moduleName := c.Name
 						if moduleName == "" {
@@ -400,8 +401,9 @@ func Run(t *testing.T, testDataFS embed.FS, ctx context.Context, fc filecache.Ca
 
 						maybeSetMemoryCap(mod)
 						mod.BuildMemoryDefinitions()
+						mod.BuildTableDefinitions()
 						mod.BuildFunctionDefinitions()
-						err = s.Engine.CompileModule(ctx, mod, nil, false)
+						err = s.Engine.CompileModule(ctx, mod, nil, false, false, false)
 						require.NoError(t, err, msg)
 
 						_, err = s.Instantiate(ctx, mod, moduleName, nil)
@@ -535,11 +537,12 @@ func Run(t *testing.T, testDataFS embed.FS, ctx context.Context, fc filecache.Ca
 							err = mod.Validate(s.EnabledFeatures)
 							require.NoError(t, err, msg)
 
-							mod.AssignModuleID(buf)
+							mod.AssignModuleID(buf, false, false)
 
 							maybeSetMemoryCap(mod)
+							mod.BuildTableDefinitions()
 							mod.BuildFunctionDefinitions()
-							err = s.Engine.CompileModule(ctx, mod, nil, false)
+							err = s.Engine.CompileModule(ctx, mod, nil, false, false, false)
 							require.NoError(t, err, msg)
 
 							_, err = s.Instantiate(ctx, mod, t.Name(), nil)
@@ -568,12 +571,13 @@ func requireInstantiationError(t *testing.T, ctx context.Context, s *wasm.Store,
 		return
 	}
 
-	mod.AssignModuleID(buf)
+	mod.AssignModuleID(buf, false, false)
 
 	maybeSetMemoryCap(mod)
 	mod.BuildMemoryDefinitions()
+	mod.BuildTableDefinitions()
 	mod.BuildFunctionDefinitions()
-	err = s.Engine.CompileModule(ctx, mod, nil, false)
+	err = s.Engine.CompileModule(ctx, mod, nil, false, false, false)
 	if err != nil {
 		return
 	}
@@ -3,6 +3,9 @@ package platform
 const (
 	// CpuFeatureSSE3 is the flag to query CpuFeatureFlags.Has for SSEv3 capabilities
 	CpuFeatureSSE3 = uint64(1)
+	// CpuFeatureFMA is the flag to query CpuFeatureFlags.Has for fused multiply-add capabilities (e.g. VFMADD231PS).
+	// This is only set when the OS saves the AVX registers, as the instructions are VEX-encoded.
+	CpuFeatureFMA = uint64(1) << 12
 	// CpuFeatureSSE4_1 is the flag to query CpuFeatureFlags.Has for SSEv4.1 capabilities
 	CpuFeatureSSE4_1 = uint64(1) << 19
 	// CpuFeatureSSE4_2 is the flag to query CpuFeatureFlags.Has for SSEv4.2 capabilities
@@ -61,9 +64,22 @@ func loadExtendedRange(id uint32) uint64 {
 	return cpuidAsBitmap(id, 0)
 }
 
+// xgetbv exposes the XGETBV instruction with ECX=0 to the Go layer, which reads the XCR0 register.
+// implemented in cpuid_amd64.s
+func xgetbv() (eax, edx uint32)
+
+// cpuFeatureOSXSAVE is the flag of the standard range which is set when the OS enables XGETBV.
+const cpuFeatureOSXSAVE = uint64(1) << 27
+
 func loadCpuFeatureFlags() CpuFeatureFlags {
+	flags := loadStandardRange(1)
+	if flags&cpuFeatureOSXSAVE == 0 {
+		flags &^= CpuFeatureFMA
+	} else if xcr0, _ := xgetbv(); xcr0&0b110 != 0b110 { // The OS must save both XMM and YMM registers.
+		flags &^= CpuFeatureFMA
+	}
 	return &cpuFeatureFlags{
-		flags:      loadStandardRange(1),
+		flags:      flags,
 		extraFlags: loadExtendedRange(0x80000001),
 	}
 }
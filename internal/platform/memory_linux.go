@@ -0,0 +1,46 @@
+package platform
+
+import "syscall"
+
+// MemoryReservationSupported is true when ReserveMemory can be used.
+const MemoryReservationSupported = true
+
+// ReserveMemory reserves reserved bytes of virtual memory, of which only the first size bytes are readable and
+// writable. The result has a length of size and a capacity of reserved, so that it can grow without moving once
+// the bytes are made accessible with CommitMemory.
+//
+// Note: The result must be released with ReleaseMemory, as it isn't managed by the garbage collector.
+func ReserveMemory(size, reserved int) ([]byte, error) {
+	b, err := syscall.Mmap(-1, 0, reserved, syscall.PROT_NONE,
+		// No swap is reserved, as most of the reservation is never committed.
+		syscall.MAP_ANON|syscall.MAP_PRIVATE|syscall.MAP_NORESERVE)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if err = CommitMemory(b[:size]); err != nil {
+			_ = syscall.Munmap(b)
+			return nil, err
+		}
+	}
+	return b[:size], nil
+}
+
+// CommitMemory makes the reserved bytes readable and writable. They read as zero until written.
+func CommitMemory(b []byte) error {
+	return mprotect(b, syscall.PROT_READ|syscall.PROT_WRITE)
+}
+
+// DecommitMemory gives back the physical memory of the committed bytes, which become inaccessible again. They read as
+// zero when committed again.
+func DecommitMemory(b []byte) error {
+	if err := syscall.Madvise(b, syscall.MADV_DONTNEED); err != nil {
+		return err
+	}
+	return mprotect(b, syscall.PROT_NONE)
+}
+
+// ReleaseMemory releases the whole reservation of ReserveMemory, given a slice of it starting at its beginning.
+func ReleaseMemory(b []byte) error {
+	return syscall.Munmap(b[:cap(b)])
+}
@@ -0,0 +1,39 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestReserveMemory(t *testing.T) {
+	const pageSize = 65536
+	b, err := ReserveMemory(pageSize, 4*pageSize)
+	require.NoError(t, err)
+	require.Equal(t, pageSize, len(b))
+	require.Equal(t, 4*pageSize, cap(b))
+
+	b[pageSize-1] = 1
+
+	// Committing more bytes keeps the existing ones in place.
+	require.NoError(t, CommitMemory(b[pageSize:2*pageSize]))
+	grown := b[:2*pageSize]
+	require.Equal(t, &b[0], &grown[0])
+	require.Equal(t, byte(1), grown[pageSize-1])
+	grown[2*pageSize-1] = 1
+
+	// Decommitted bytes read as zero once committed again.
+	require.NoError(t, DecommitMemory(grown[pageSize:]))
+	require.NoError(t, CommitMemory(grown[pageSize:]))
+	require.Equal(t, byte(0), grown[2*pageSize-1])
+
+	require.NoError(t, ReleaseMemory(b))
+}
+
+func TestReserveMemory_zeroSize(t *testing.T) {
+	b, err := ReserveMemory(0, 65536)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(b))
+	require.Equal(t, 65536, cap(b))
+	require.NoError(t, ReleaseMemory(b))
+}
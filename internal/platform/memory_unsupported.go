@@ -0,0 +1,33 @@
+//go:build !linux
+
+package platform
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// MemoryReservationSupported is true when ReserveMemory can be used.
+const MemoryReservationSupported = false
+
+var errMemoryReservationUnsupported = fmt.Errorf("memory reservation unsupported on GOOS=%s", runtime.GOOS)
+
+// ReserveMemory is only supported on Linux.
+func ReserveMemory(size, reserved int) ([]byte, error) {
+	return nil, errMemoryReservationUnsupported
+}
+
+// CommitMemory is only supported on Linux.
+func CommitMemory(b []byte) error {
+	return errMemoryReservationUnsupported
+}
+
+// DecommitMemory is only supported on Linux.
+func DecommitMemory(b []byte) error {
+	return errMemoryReservationUnsupported
+}
+
+// ReleaseMemory is only supported on Linux.
+func ReleaseMemory(b []byte) error {
+	return errMemoryReservationUnsupported
+}
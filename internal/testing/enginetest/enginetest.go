@@ -71,7 +71,7 @@ func RunTestEngine_MemoryGrowInRecursiveCall(t *testing.T, et EngineTester) {
 	}}, map[string]*wasm.HostFuncNames{hostFnName: {}}, enabledFeatures)
 	require.NoError(t, err)
 
-	err = s.Engine.CompileModule(testCtx, hm, nil, false)
+	err = s.Engine.CompileModule(testCtx, hm, nil, false, false, false)
 	require.NoError(t, err)
 
 	_, err = s.Instantiate(testCtx, hm, hostModuleName, nil)
@@ -94,16 +94,16 @@ func RunTestEngine_MemoryGrowInRecursiveCall(t *testing.T, et EngineTester) {
 			},
 			{
 				// Grows memory by 1 page.
-				Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, wasm.OpcodeDrop, wasm.OpcodeEnd},
+				Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop, wasm.OpcodeEnd},
 			},
 		},
-		MemorySection: &wasm.Memory{Max: 1000},
+		MemorySection: []*wasm.Memory{{Max: 1000}},
 		ImportSection: []*wasm.Import{{Module: hostModuleName, Name: hostFnName, DescFunc: 0}},
 	}
 	m.BuildFunctionDefinitions()
 	m.BuildMemoryDefinitions()
 
-	err = s.Engine.CompileModule(testCtx, m, nil, false)
+	err = s.Engine.CompileModule(testCtx, m, nil, false, false, false)
 	require.NoError(t, err)
 
 	inst, err := s.Instantiate(testCtx, m, t.Name(), nil)
@@ -124,7 +124,7 @@ func RunTestEngine_NewModuleEngine(t *testing.T, et EngineTester) {
 
 	t.Run("sets module name", func(t *testing.T) {
 		m := &wasm.Module{}
-		err := e.CompileModule(testCtx, m, nil, false)
+		err := e.CompileModule(testCtx, m, nil, false, false, false)
 		require.NoError(t, err)
 		me, err := e.NewModuleEngine(t.Name(), m, nil)
 		require.NoError(t, err)
@@ -154,7 +154,7 @@ func RunTestModuleEngine_Call(t *testing.T, et EngineTester) {
 
 	m.BuildFunctionDefinitions()
 	listeners := buildListeners(et.ListenerFactory(), m)
-	err := e.CompileModule(testCtx, m, listeners, false)
+	err := e.CompileModule(testCtx, m, listeners, false, false, false)
 	require.NoError(t, err)
 
 	// To use the function, we first need to add it to a module.
@@ -191,6 +191,25 @@ func RunTestModuleEngine_Call(t *testing.T, et EngineTester) {
 		_, err = ce.Call(testCtx, module.CallCtx, []uint64{1, 2, 3})
 		require.EqualError(t, err, "expected 2 params, but passed 3")
 	})
+
+	t.Run("CallWithStack", func(t *testing.T) {
+		ce, err := me.NewCallEngine(module.CallCtx, fn)
+		require.NoError(t, err)
+
+		// Values past the params and results are untouched.
+		stack := []uint64{1, 2, 3}
+		err = ce.CallWithStack(testCtx, module.CallCtx, stack)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1, 2, 3}, stack)
+	})
+
+	t.Run("CallWithStack errs when stack is too small", func(t *testing.T) {
+		ce, err := me.NewCallEngine(module.CallCtx, fn)
+		require.NoError(t, err)
+
+		err = ce.CallWithStack(testCtx, module.CallCtx, []uint64{1})
+		require.EqualError(t, err, "need 2 params and 2 results, but stack size is 1")
+	})
 }
 
 func RunTestModuleEngine_LookupFunction(t *testing.T, et EngineTester) {
@@ -207,7 +226,7 @@ func RunTestModuleEngine_LookupFunction(t *testing.T, et EngineTester) {
 	}
 
 	mod.BuildFunctionDefinitions()
-	err := e.CompileModule(testCtx, mod, nil, false)
+	err := e.CompileModule(testCtx, mod, nil, false, false, false)
 	require.NoError(t, err)
 	m := &wasm.ModuleInstance{TypeIDs: []wasm.FunctionTypeID{0, 1}}
 	m.Tables = []*wasm.TableInstance{
@@ -489,7 +508,7 @@ func RunTestModuleEngine_Memory(t *testing.T, et EngineTester) {
 	m := &wasm.Module{
 		TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}, ParamNumInUint64: 1}, v_v},
 		FunctionSection: []wasm.Index{0, 1},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 2},
+		MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 2}},
 		DataSection: []*wasm.DataSegment{
 			{
 				OffsetExpression: nil, // passive
@@ -520,13 +539,13 @@ func RunTestModuleEngine_Memory(t *testing.T, et EngineTester) {
 	m.BuildFunctionDefinitions()
 	listeners := buildListeners(et.ListenerFactory(), m)
 
-	err := e.CompileModule(testCtx, m, listeners, false)
+	err := e.CompileModule(testCtx, m, listeners, false, false, false)
 	require.NoError(t, err)
 
 	// Assign memory to the module instance
 	module := &wasm.ModuleInstance{
 		Name:          t.Name(),
-		Memory:        wasm.NewMemoryInstance(m.MemorySection),
+		Memory:        wasm.NewMemoryInstance(m.MemorySection[0]),
 		DataInstances: []wasm.DataInstance{m.DataSection[0].Init},
 		TypeIDs:       []wasm.FunctionTypeID{0, 1},
 	}
@@ -662,7 +681,7 @@ func setupCallTests(t *testing.T, e wasm.Engine, divBy *wasm.Code, fnlf experime
 	}
 	hostModule.BuildFunctionDefinitions()
 	lns := buildListeners(fnlf, hostModule)
-	err := e.CompileModule(testCtx, hostModule, lns, false)
+	err := e.CompileModule(testCtx, hostModule, lns, false, false, false)
 	require.NoError(t, err)
 	host := &wasm.ModuleInstance{Name: hostModule.NameSection.ModuleName, TypeIDs: []wasm.FunctionTypeID{0}}
 	host.Functions = host.BuildFunctions(hostModule, nil)
@@ -697,7 +716,7 @@ func setupCallTests(t *testing.T, e wasm.Engine, divBy *wasm.Code, fnlf experime
 	}
 	importedModule.BuildFunctionDefinitions()
 	lns = buildListeners(fnlf, importedModule)
-	err = e.CompileModule(testCtx, importedModule, lns, false)
+	err = e.CompileModule(testCtx, importedModule, lns, false, false, false)
 	require.NoError(t, err)
 
 	imported := &wasm.ModuleInstance{Name: importedModule.NameSection.ModuleName, TypeIDs: []wasm.FunctionTypeID{0}}
@@ -730,7 +749,7 @@ func setupCallTests(t *testing.T, e wasm.Engine, divBy *wasm.Code, fnlf experime
 	}
 	importingModule.BuildFunctionDefinitions()
 	lns = buildListeners(fnlf, importingModule)
-	err = e.CompileModule(testCtx, importingModule, lns, false)
+	err = e.CompileModule(testCtx, importingModule, lns, false, false, false)
 	require.NoError(t, err)
 
 	// Add the exported function.
@@ -780,7 +799,7 @@ func setupCallMemTests(t *testing.T, e wasm.Engine, readMem *wasm.Code, fnlf exp
 		ID: wasm.ModuleID{0},
 	}
 	hostModule.BuildFunctionDefinitions()
-	err := e.CompileModule(testCtx, hostModule, nil, false)
+	err := e.CompileModule(testCtx, hostModule, nil, false, false, false)
 	require.NoError(t, err)
 	host := &wasm.ModuleInstance{Name: hostModule.NameSection.ModuleName, TypeIDs: []wasm.FunctionTypeID{0}}
 	host.Functions = host.BuildFunctions(hostModule, nil)
@@ -816,11 +835,11 @@ func setupCallMemTests(t *testing.T, e wasm.Engine, readMem *wasm.Code, fnlf exp
 			},
 		},
 		// Indicates that this module has a memory so that compilers are able to assembe memory-related initialization.
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []*wasm.Memory{{Min: 1}},
 		ID:            wasm.ModuleID{1},
 	}
 	importingModule.BuildFunctionDefinitions()
-	err = e.CompileModule(testCtx, importingModule, nil, false)
+	err = e.CompileModule(testCtx, importingModule, nil, false, false, false)
 	require.NoError(t, err)
 
 	// Add the exported function.
@@ -6,11 +6,12 @@ import (
 	"io"
 	"math"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-func decodeCode(r *bytes.Reader, codeSectionStart uint64) (*wasm.Code, error) {
+func decodeCode(r *bytes.Reader, codeSectionStart uint64, enabledFeatures api.CoreFeatures) (*wasm.Code, error) {
 	ss, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("get the size of code: %w", err)
@@ -28,6 +29,7 @@ func decodeCode(r *bytes.Reader, codeSectionStart uint64) (*wasm.Code, error) {
 
 	var nums []uint64
 	var types []wasm.ValueType
+	var refs []*wasm.TypedRef
 	var sum uint64
 	var n uint32
 	for i := uint32(0); i < ls; i++ {
@@ -50,8 +52,31 @@ func decodeCode(r *bytes.Reader, codeSectionStart uint64) (*wasm.Code, error) {
 		case wasm.ValueTypeI32, wasm.ValueTypeF32, wasm.ValueTypeI64, wasm.ValueTypeF64,
 			wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeV128:
 			types = append(types, vt)
+			refs = append(refs, nil)
+		case wasm.RefTypePrefixNonNullable, wasm.RefTypePrefixNullable:
+			if !enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) {
+				return nil, fmt.Errorf("invalid local type: 0x%x", vt)
+			}
+			before := r.Len()
+			heapType, err := decodeHeapType(r, enabledFeatures)
+			if err != nil {
+				return nil, fmt.Errorf("read type of local: %v", err)
+			}
+			if remaining -= int64(before - r.Len()); remaining < 0 {
+				return nil, io.EOF
+			}
+			ref := &wasm.TypedRef{Nullable: vt == wasm.RefTypePrefixNullable, HeapType: heapType}
+			types = append(types, ref.ValueType())
+			refs = append(refs, wasm.NewTypedRef(ref.Nullable, heapType))
 		default:
-			return nil, fmt.Errorf("invalid local type: 0x%x", vt)
+			// The shorthands of the nullable abstract reference types of api.CoreFeatureGC, e.g. eqref.
+			heapType, ok := wasm.HeapTypeOfByte(vt, enabledFeatures)
+			if !ok || !enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) {
+				return nil, fmt.Errorf("invalid local type: 0x%x", vt)
+			}
+			ref := &wasm.TypedRef{Nullable: true, HeapType: heapType}
+			types = append(types, ref.ValueType())
+			refs = append(refs, wasm.NewTypedRef(true, heapType))
 		}
 	}
 
@@ -60,10 +85,17 @@ func decodeCode(r *bytes.Reader, codeSectionStart uint64) (*wasm.Code, error) {
 	}
 
 	var localTypes []wasm.ValueType
+	var localRefs []*wasm.TypedRef
 	for i, num := range nums {
-		t := types[i]
+		t, ref := types[i], refs[i]
+		if ref != nil && localRefs == nil {
+			localRefs = make([]*wasm.TypedRef, len(localTypes), sum)
+		}
 		for j := uint64(0); j < num; j++ {
 			localTypes = append(localTypes, t)
+			if localRefs != nil {
+				localRefs = append(localRefs, ref)
+			}
 		}
 	}
 
@@ -77,7 +109,7 @@ func decodeCode(r *bytes.Reader, codeSectionStart uint64) (*wasm.Code, error) {
 		return nil, fmt.Errorf("expr not end with OpcodeEnd")
 	}
 
-	return &wasm.Code{Body: body, LocalTypes: localTypes, BodyOffsetInCodeSection: bodyOffsetInCodeSection}, nil
+	return &wasm.Code{Body: body, LocalTypes: localTypes, LocalRefs: localRefs, BodyOffsetInCodeSection: bodyOffsetInCodeSection}, nil
 }
 
 // encodeCode returns the wasm.Code encoded in WebAssembly 1.0 (20191205) Binary Format.
@@ -97,16 +129,25 @@ func encodeCode(c *wasm.Code) []byte {
 		i := localTypeLen - 1
 		var runCount uint32              // count of the same type
 		var lastValueType wasm.ValueType // initialize to an invalid type 0
+		var lastRef *wasm.TypedRef
 
 		// iterate backwards so it is easier to size prefix
 		for ; i >= 0; i-- {
 			vt := c.LocalTypes[i]
-			if lastValueType != vt {
+			var ref *wasm.TypedRef
+			if c.LocalRefs != nil {
+				ref = c.LocalRefs[i]
+			}
+			if lastValueType != vt || !sameTypedRef(lastRef, ref) {
 				if runCount != 0 { // Only on the first iteration, this is zero when vt is compared against invalid
 					localBlocks = append(leb128.EncodeUint32(runCount), localBlocks...)
 				}
-				lastValueType = vt
-				localBlocks = append(leb128.EncodeUint32(uint32(vt)), localBlocks...) // reuse the EncodeUint32 cache
+				lastValueType, lastRef = vt, ref
+				if ref != nil {
+					localBlocks = append(encodeValueType(vt, ref), localBlocks...)
+				} else {
+					localBlocks = append(leb128.EncodeUint32(uint32(vt)), localBlocks...) // reuse the EncodeUint32 cache
+				}
 				localBlockCount++
 				runCount = 1
 			} else {
@@ -121,3 +162,8 @@ func encodeCode(c *wasm.Code) []byte {
 	code := append(localBlocks, c.Body...)
 	return append(leb128.EncodeUint32(uint32(len(code))), code...)
 }
+
+// sameTypedRef returns true if a and b are the same typed reference, or both nil.
+func sameTypedRef(a, b *wasm.TypedRef) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
@@ -69,6 +69,22 @@ func TestEncodeCode(t *testing.T) {
 				addLocalZeroLocalTwo..., // Body
 			),
 		},
+		{
+			name: "typed reference locals",
+			input: &wasm.Code{ // e.g. (func (local (ref 0) (ref 0) i32) local.get 0 local.get 2 i32.add)
+				LocalTypes: []wasm.ValueType{wasm.ValueTypeFuncref, wasm.ValueTypeFuncref, wasm.ValueTypeI32},
+				LocalRefs:  []*wasm.TypedRef{{HeapType: 0}, {HeapType: 0}, nil},
+				Body:       addLocalZeroLocalTwo,
+			},
+			expected: append([]byte{
+				0x0c,                                      // 12 bytes to encode locals and the body
+				0x02,                                      // 2 local blocks
+				0x02, wasm.RefTypePrefixNonNullable, 0x00, // local block 1
+				0x01, wasm.ValueTypeI32, // local block 2
+			},
+				addLocalZeroLocalTwo..., // Body
+			),
+		},
 	}
 
 	for _, tt := range tests {
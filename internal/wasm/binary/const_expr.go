@@ -20,7 +20,45 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures)
 	remainingBeforeData := int64(r.Len())
 	offsetAtData := r.Size() - remainingBeforeData
 
-	opcode := b
+	// The first opcode is held in ConstantExpression.Opcode, and the following instructions (CoreFeatureExtendedConst)
+	// are retained in ConstantExpression.Data after its immediates.
+	opcode, err := decodeConstantInstruction(r, b, enabledFeatures)
+	if err != nil {
+		return nil, err
+	}
+	if b == wasm.OpcodeVecPrefix { // Data starts after the opcode suffix.
+		remainingBeforeData--
+		offsetAtData++
+	}
+
+	for {
+		if b, err = r.ReadByte(); err != nil {
+			return nil, fmt.Errorf("look for end opcode: %v", err)
+		}
+
+		if b == wasm.OpcodeEnd {
+			break
+		} else if !enabledFeatures.IsEnabled(api.CoreFeatureExtendedConst) {
+			return nil, fmt.Errorf("constant expression has been not terminated")
+		}
+
+		if _, err = decodeConstantInstruction(r, b, enabledFeatures); err != nil {
+			return nil, err
+		}
+	}
+
+	data := make([]byte, remainingBeforeData-int64(r.Len())-1)
+	if _, err := r.ReadAt(data, offsetAtData); err != nil {
+		return nil, fmt.Errorf("error re-buffering ConstantExpression.Data")
+	}
+
+	return &wasm.ConstantExpression{Opcode: opcode, Data: data}, nil
+}
+
+// decodeConstantInstruction reads the immediates of the constant instruction which starts with the byte b, and returns
+// its opcode. This is the opcode suffix if b is wasm.OpcodeVecPrefix.
+func decodeConstantInstruction(r *bytes.Reader, b byte, enabledFeatures api.CoreFeatures) (opcode wasm.Opcode, err error) {
+	opcode = b
 	switch opcode {
 	case wasm.OpcodeI32Const:
 		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
@@ -31,80 +69,70 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures)
 	case wasm.OpcodeF32Const:
 		buf := make([]byte, 4)
 		if _, err := io.ReadFull(r, buf); err != nil {
-			return nil, fmt.Errorf("read f32 constant: %v", err)
+			return 0, fmt.Errorf("read f32 constant: %v", err)
 		}
 		_, err = ieee754.DecodeFloat32(buf)
 	case wasm.OpcodeF64Const:
 		buf := make([]byte, 8)
 		if _, err := io.ReadFull(r, buf); err != nil {
-			return nil, fmt.Errorf("read f64 constant: %v", err)
+			return 0, fmt.Errorf("read f64 constant: %v", err)
 		}
 		_, err = ieee754.DecodeFloat64(buf)
 	case wasm.OpcodeGlobalGet:
 		_, _, err = leb128.DecodeUint32(r)
 	case wasm.OpcodeRefNull:
 		if err := enabledFeatures.RequireEnabled(api.CoreFeatureBulkMemoryOperations); err != nil {
-			return nil, fmt.Errorf("ref.null is not supported as %w", err)
+			return 0, fmt.Errorf("ref.null is not supported as %w", err)
 		}
 		reftype, err := r.ReadByte()
 		if err != nil {
-			return nil, fmt.Errorf("read reference type for ref.null: %w", err)
-		} else if reftype != wasm.RefTypeFuncref && reftype != wasm.RefTypeExternref {
-			return nil, fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
+			return 0, fmt.Errorf("read reference type for ref.null: %w", err)
+		} else if _, ok := wasm.HeapTypeOfByte(reftype, enabledFeatures); !ok {
+			return 0, fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
 		}
 	case wasm.OpcodeRefFunc:
 		if err := enabledFeatures.RequireEnabled(api.CoreFeatureBulkMemoryOperations); err != nil {
-			return nil, fmt.Errorf("ref.func is not supported as %w", err)
+			return 0, fmt.Errorf("ref.func is not supported as %w", err)
 		}
 		// Parsing index.
 		_, _, err = leb128.DecodeUint32(r)
 	case wasm.OpcodeVecPrefix:
 		if err := enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
-			return nil, fmt.Errorf("vector instructions are not supported as %w", err)
+			return 0, fmt.Errorf("vector instructions are not supported as %w", err)
 		}
 		opcode, err = r.ReadByte()
 		if err != nil {
-			return nil, fmt.Errorf("read vector instruction opcode suffix: %w", err)
+			return 0, fmt.Errorf("read vector instruction opcode suffix: %w", err)
 		}
 
 		if opcode != wasm.OpcodeVecV128Const {
-			return nil, fmt.Errorf("invalid vector opcode for const expression: %#x", opcode)
+			return 0, fmt.Errorf("invalid vector opcode for const expression: %#x", opcode)
 		}
 
-		remainingBeforeData = int64(r.Len())
-		offsetAtData = r.Size() - remainingBeforeData
-
 		n, err := r.Read(make([]byte, 16))
 		if err != nil {
-			return nil, fmt.Errorf("read vector const instruction immediates: %w", err)
+			return 0, fmt.Errorf("read vector const instruction immediates: %w", err)
 		} else if n != 16 {
-			return nil, fmt.Errorf("read vector const instruction immediates: needs 16 bytes but was %d bytes", n)
+			return 0, fmt.Errorf("read vector const instruction immediates: needs 16 bytes but was %d bytes", n)
+		}
+	case wasm.OpcodeI32Add, wasm.OpcodeI32Sub, wasm.OpcodeI32Mul, wasm.OpcodeI64Add, wasm.OpcodeI64Sub, wasm.OpcodeI64Mul:
+		if err := enabledFeatures.RequireEnabled(api.CoreFeatureExtendedConst); err != nil {
+			return 0, fmt.Errorf("%s is not supported in const expression as %w", wasm.InstructionName(opcode), err)
 		}
 	default:
-		return nil, fmt.Errorf("%v for const expression opt code: %#x", ErrInvalidByte, b)
+		return 0, fmt.Errorf("%v for const expression opt code: %#x", ErrInvalidByte, opcode)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("read value: %v", err)
-	}
-
-	if b, err = r.ReadByte(); err != nil {
-		return nil, fmt.Errorf("look for end opcode: %v", err)
-	}
-
-	if b != wasm.OpcodeEnd {
-		return nil, fmt.Errorf("constant expression has been not terminated")
+		return 0, fmt.Errorf("read value: %v", err)
 	}
-
-	data := make([]byte, remainingBeforeData-int64(r.Len())-1)
-	if _, err := r.ReadAt(data, offsetAtData); err != nil {
-		return nil, fmt.Errorf("error re-buffering ConstantExpression.Data")
-	}
-
-	return &wasm.ConstantExpression{Opcode: opcode, Data: data}, nil
+	return opcode, nil
 }
 
 func encodeConstantExpression(expr *wasm.ConstantExpression) (ret []byte) {
+	if expr.Opcode == wasm.OpcodeVecV128Const {
+		ret = append(ret, wasm.OpcodeVecPrefix)
+	}
 	ret = append(ret, expr.Opcode)
 	ret = append(ret, expr.Data...)
 	ret = append(ret, wasm.OpcodeEnd)
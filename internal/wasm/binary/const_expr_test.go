@@ -79,13 +79,47 @@ func TestDecodeConstantExpression(t *testing.T) {
 				},
 			},
 		},
+		{
+			in: []byte{
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeI32Const, 0x80, 0x01, // 128
+				wasm.OpcodeI32Mul,
+				wasm.OpcodeEnd,
+			},
+			exp: &wasm.ConstantExpression{
+				Opcode: wasm.OpcodeGlobalGet,
+				Data:   []byte{0, wasm.OpcodeI32Const, 0x80, 0x01, wasm.OpcodeI32Mul},
+			},
+		},
+		{
+			in: []byte{
+				wasm.OpcodeVecPrefix,
+				wasm.OpcodeVecV128Const,
+				1, 1, 1, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 1, 1, 1,
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeI64Sub,
+				wasm.OpcodeEnd,
+			},
+			exp: &wasm.ConstantExpression{
+				Opcode: wasm.OpcodeVecV128Const,
+				Data: []byte{
+					1, 1, 1, 1, 1, 1, 1, 1,
+					1, 1, 1, 1, 1, 1, 1, 1,
+					wasm.OpcodeI64Const, 1,
+					wasm.OpcodeI64Const, 2,
+					wasm.OpcodeI64Sub,
+				},
+			},
+		},
 	}
 
 	for i, tt := range tests {
 		tc := tt
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			actual, err := decodeConstantExpression(bytes.NewReader(tc.in),
-				api.CoreFeatureBulkMemoryOperations|api.CoreFeatureSIMD)
+				api.CoreFeatureBulkMemoryOperations|api.CoreFeatureSIMD|api.CoreFeatureExtendedConst)
 			require.NoError(t, err)
 			require.Equal(t, tc.exp, actual)
 		})
@@ -177,6 +211,42 @@ func TestDecodeConstantExpression_errors(t *testing.T) {
 			expectedErr: "read vector const instruction immediates: needs 16 bytes but was 8 bytes",
 			features:    api.CoreFeatureSIMD,
 		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "constant expression has been not terminated",
+			features:    api.CoreFeaturesV2,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "i32.add is not supported in const expression as feature \"extended-const\" is disabled",
+			features:    api.CoreFeaturesV2,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Add,
+			},
+			expectedErr: "look for end opcode: EOF",
+			features:    api.CoreFeatureExtendedConst,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Eqz,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "invalid byte for const expression opt code: 0x45",
+			features:    api.CoreFeatureExtendedConst,
+		},
 	}
 
 	for _, tt := range tests {
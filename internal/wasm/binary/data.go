@@ -37,17 +37,18 @@ func decodeDataSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm
 	}
 
 	var expr *wasm.ConstantExpression
+	var memoryIndex wasm.Index
 	switch dataSegmentPrefx {
 	case dataSegmentPrefixActive,
 		dataSegmentPrefixActiveWithMemoryIndex:
 		// Active data segment as in
 		// https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-section
 		if dataSegmentPrefx == 0x2 {
-			d, _, err := leb128.DecodeUint32(r)
+			memoryIndex, _, err = leb128.DecodeUint32(r)
 			if err != nil {
 				return nil, fmt.Errorf("read memory index: %v", err)
-			} else if d != 0 {
-				return nil, fmt.Errorf("memory index must be zero but was %d", d)
+			} else if memoryIndex != 0 && !enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+				return nil, fmt.Errorf("memory index must be zero but was %d", memoryIndex)
 			}
 		}
 
@@ -75,12 +76,21 @@ func decodeDataSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm
 	return &wasm.DataSegment{
 		OffsetExpression: expr,
 		Init:             b,
+		MemoryIndex:      memoryIndex,
 	}, nil
 }
 
 func encodeDataSegment(d *wasm.DataSegment) (ret []byte) {
-	// Currently multiple memories are not supported.
-	ret = append(ret, leb128.EncodeInt32(0)...)
+	if d.IsPassive() {
+		ret = append(ret, leb128.EncodeUint32(dataSegmentPrefixPassive)...)
+		ret = append(ret, leb128.EncodeUint32(uint32(len(d.Init)))...)
+		return append(ret, d.Init...)
+	} else if d.MemoryIndex == 0 {
+		ret = append(ret, leb128.EncodeUint32(dataSegmentPrefixActive)...)
+	} else {
+		ret = append(ret, leb128.EncodeUint32(dataSegmentPrefixActiveWithMemoryIndex)...)
+		ret = append(ret, leb128.EncodeUint32(d.MemoryIndex)...)
+	}
 	ret = append(ret, encodeConstantExpression(d.OffsetExpression)...)
 	ret = append(ret, leb128.EncodeUint32(uint32(len(d.Init)))...)
 	ret = append(ret, d.Init...)
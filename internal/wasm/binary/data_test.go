@@ -97,6 +97,25 @@ func Test_decodeDataSegment(t *testing.T) {
 			expErr:   "memory index must be zero but was 1",
 			features: api.CoreFeatureBulkMemoryOperations,
 		},
+		{
+			in: []byte{
+				0x2,
+				0x1, // Memory index.
+				// Const expression.
+				wasm.OpcodeI32Const, 0x1, wasm.OpcodeEnd,
+				// Two initial data.
+				0x2, 0xf, 0xf,
+			},
+			exp: &wasm.DataSegment{
+				MemoryIndex: 1,
+				OffsetExpression: &wasm.ConstantExpression{
+					Opcode: wasm.OpcodeI32Const,
+					Data:   []byte{0x1},
+				},
+				Init: []byte{0xf, 0xf},
+			},
+			features: api.CoreFeatureBulkMemoryOperations | api.CoreFeatureMultiMemory,
+		},
 		{
 			in: []byte{
 				0x2,
@@ -136,3 +155,46 @@ func Test_decodeDataSegment(t *testing.T) {
 		})
 	}
 }
+
+func Test_encodeDataSegment(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *wasm.DataSegment
+		expected []byte
+	}{
+		{
+			name: "active",
+			input: &wasm.DataSegment{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+				Init:             []byte{0xf, 0xf},
+			},
+			expected: []byte{0, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 2, 0xf, 0xf},
+		},
+		{
+			name: "active with memory index",
+			input: &wasm.DataSegment{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+				Init:             []byte{0xf},
+				MemoryIndex:      1,
+			},
+			expected: []byte{2, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 1, 0xf},
+		},
+		{
+			name:     "passive",
+			input:    &wasm.DataSegment{Init: []byte{0xf}},
+			expected: []byte{1, 1, 0xf},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := encodeDataSegment(tc.input)
+			require.Equal(t, tc.expected, encoded)
+
+			decoded, err := decodeDataSegment(bytes.NewReader(encoded), api.CoreFeaturesV2|api.CoreFeatureMultiMemory)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, decoded)
+		})
+	}
+}
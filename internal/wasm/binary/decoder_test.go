@@ -61,7 +61,7 @@ func TestDecodeModule(t *testing.T) {
 			name: "table and memory section",
 			input: &wasm.Module{
 				TableSection:  []*wasm.Table{{Min: 3, Type: wasm.RefTypeFuncref}},
-				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+				MemorySection: []*wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
 			},
 		},
 		{
@@ -169,6 +169,30 @@ func TestDecodeModule(t *testing.T) {
 		require.Nil(t, m.DWARFLines)
 	})
 
+	t.Run("tag section", func(t *testing.T) {
+		input := &wasm.Module{
+			TypeSection: []*wasm.FunctionType{{Params: []wasm.ValueType{i32}}},
+			ImportSection: []*wasm.Import{{
+				Module: "env", Name: "error",
+				Type:    wasm.ExternTypeTag,
+				DescTag: 0,
+			}},
+			TagSection:    []wasm.Index{0},
+			ExportSection: []*wasm.Export{{Name: "tag", Type: wasm.ExternTypeTag, Index: 1}},
+		}
+		m, e := DecodeModule(EncodeModule(input), api.CoreFeaturesV2|api.CoreFeatureExceptionHandling, wasm.MemoryLimitPages, false, false, false)
+		require.NoError(t, e)
+		_ = input.TypeSection[0].String()
+		require.Equal(t, input, m)
+	})
+
+	t.Run("tag section disabled", func(t *testing.T) {
+		input := append(append(Magic, version...),
+			wasm.SectionIDTag, 3, 1, 0, 0)
+		_, e := DecodeModule(input, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+		require.EqualError(t, e, `tag section not supported as feature "exception-handling" is disabled`)
+	})
+
 	t.Run("data count section disabled", func(t *testing.T) {
 		input := append(append(Magic, version...),
 			wasm.SectionIDDataCount, 1, 0)
@@ -282,19 +282,67 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*w
 	}
 }
 
-// encodeCode returns the wasm.ElementSegment encoded in WebAssembly 1.0 (20191205) Binary Format.
+// encodeElement returns the wasm.ElementSegment encoded in WebAssembly 2.0 Binary Format, which is the WebAssembly
+// 1.0 (20191205) one for an active segment of funcref on the table zero.
 //
-// https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#element-section%E2%91%A0
+// https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#element-section
 func encodeElement(e *wasm.ElementSegment) (ret []byte) {
+	// The init is encoded as vec(expr) when it has null references, and as vec(funcidx) otherwise.
+	useExprs := e.Type != wasm.RefTypeFuncref
+	for _, idx := range e.Init {
+		if idx == nil {
+			useExprs = true
+		}
+	}
+
+	var prefix uint32
+	switch e.Mode {
+	case wasm.ElementModeActive:
+		if e.TableIndex == 0 && e.Type == wasm.RefTypeFuncref {
+			prefix = elementSegmentPrefixLegacy
+		} else {
+			prefix = elementSegmentPrefixActiveFuncrefValueVectorWithTableIndex
+		}
+	case wasm.ElementModePassive:
+		prefix = elementSegmentPrefixPassiveFuncrefValueVector
+	case wasm.ElementModeDeclarative:
+		prefix = elementSegmentPrefixDeclarativeFuncrefValueVector
+	}
+	if useExprs {
+		// Each const expr prefix is the one of the value vector plus 4.
+		prefix += elementSegmentPrefixActiveFuncrefConstExprVector
+	}
+	ret = leb128.EncodeUint32(prefix)
+
 	if e.Mode == wasm.ElementModeActive {
-		ret = append(ret, leb128.EncodeInt32(int32(e.TableIndex))...)
+		if prefix != elementSegmentPrefixLegacy && prefix != elementSegmentPrefixActiveFuncrefConstExprVector {
+			ret = append(ret, leb128.EncodeUint32(e.TableIndex)...)
+		}
 		ret = append(ret, encodeConstantExpression(e.OffsetExpr)...)
-		ret = append(ret, leb128.EncodeUint32(uint32(len(e.Init)))...)
-		for _, idx := range e.Init {
-			ret = append(ret, leb128.EncodeInt32(int32(*idx))...)
+	}
+	switch prefix {
+	case elementSegmentPrefixLegacy, elementSegmentPrefixActiveFuncrefConstExprVector:
+		// The element kind and ref type are implicitly funcref.
+	case elementSegmentPrefixPassiveFuncrefValueVector,
+		elementSegmentPrefixActiveFuncrefValueVectorWithTableIndex,
+		elementSegmentPrefixDeclarativeFuncrefValueVector:
+		ret = append(ret, 0x0) // ElemKind is fixed to 0x0.
+	default:
+		ret = append(ret, e.Type)
+	}
+
+	ret = append(ret, leb128.EncodeUint32(uint32(len(e.Init)))...)
+	for _, idx := range e.Init {
+		switch {
+		case !useExprs:
+			ret = append(ret, leb128.EncodeUint32(*idx)...)
+		case idx == nil:
+			ret = append(ret, wasm.OpcodeRefNull, e.Type, wasm.OpcodeEnd)
+		default:
+			ret = append(ret, wasm.OpcodeRefFunc)
+			ret = append(ret, leb128.EncodeUint32(*idx)...)
+			ret = append(ret, wasm.OpcodeEnd)
 		}
-	} else {
-		panic("TODO: support encoding for non-active elements in bulk-memory-operations proposal")
 	}
 	return
 }
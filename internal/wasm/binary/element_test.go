@@ -441,3 +441,95 @@ func TestDecodeElementSegment_errors(t *testing.T) {
 	_, err := decodeElementSegment(bytes.NewReader([]byte{1}), api.CoreFeatureMultiValue)
 	require.EqualError(t, err, `non-zero prefix for element segment is invalid as feature "bulk-memory-operations" is disabled`)
 }
+
+func Test_encodeElement(t *testing.T) {
+	offset := func(v byte) *wasm.ConstantExpression {
+		return &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{v}}
+	}
+
+	tests := []struct {
+		name     string
+		input    *wasm.ElementSegment
+		expected []byte
+	}{
+		{
+			name: "active funcref on table zero",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset(1),
+				Init:       []*wasm.Index{uint32Ptr(1), uint32Ptr(2)},
+				Mode:       wasm.ElementModeActive,
+				Type:       wasm.RefTypeFuncref,
+			},
+			expected: []byte{0, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 2, 1, 2},
+		},
+		{
+			name: "active funcref with table index",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset(1),
+				TableIndex: 1,
+				Init:       []*wasm.Index{uint32Ptr(1)},
+				Mode:       wasm.ElementModeActive,
+				Type:       wasm.RefTypeFuncref,
+			},
+			expected: []byte{2, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 0, 1, 1},
+		},
+		{
+			name: "active funcref with null on table zero",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset(0),
+				Init:       []*wasm.Index{nil},
+				Mode:       wasm.ElementModeActive,
+				Type:       wasm.RefTypeFuncref,
+			},
+			expected: []byte{4, wasm.OpcodeI32Const, 0, wasm.OpcodeEnd, 1, wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd},
+		},
+		{
+			name: "active externref with table index",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset(0),
+				TableIndex: 1,
+				Init:       []*wasm.Index{nil},
+				Mode:       wasm.ElementModeActive,
+				Type:       wasm.RefTypeExternref,
+			},
+			expected: []byte{
+				6, 1, wasm.OpcodeI32Const, 0, wasm.OpcodeEnd, wasm.RefTypeExternref,
+				1, wasm.OpcodeRefNull, wasm.RefTypeExternref, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "passive funcref with null",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{uint32Ptr(1), nil},
+				Mode: wasm.ElementModePassive,
+				Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{
+				5, wasm.RefTypeFuncref, 2,
+				wasm.OpcodeRefFunc, 1, wasm.OpcodeEnd,
+				wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "declarative funcref",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{uint32Ptr(3)},
+				Mode: wasm.ElementModeDeclarative,
+				Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{3, 0, 1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := encodeElement(tc.input)
+			require.Equal(t, tc.expected, encoded)
+
+			decoded, err := decodeElementSegment(bytes.NewReader(encoded), api.CoreFeaturesV2)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, decoded)
+		})
+	}
+}
@@ -26,6 +26,9 @@ func EncodeModule(m *wasm.Module) (bytes []byte) {
 	if m.SectionElementCount(wasm.SectionIDMemory) > 0 {
 		bytes = append(bytes, encodeMemorySection(m.MemorySection)...)
 	}
+	if m.SectionElementCount(wasm.SectionIDTag) > 0 {
+		bytes = append(bytes, encodeTagSection(m.TagSection)...)
+	}
 	if m.SectionElementCount(wasm.SectionIDGlobal) > 0 {
 		bytes = append(bytes, encodeGlobalSection(m.GlobalSection)...)
 	}
@@ -38,6 +41,9 @@ func EncodeModule(m *wasm.Module) (bytes []byte) {
 	if m.SectionElementCount(wasm.SectionIDElement) > 0 {
 		bytes = append(bytes, encodeElementSection(m.ElementSection)...)
 	}
+	if m.DataCountSection != nil {
+		bytes = append(bytes, encodeDataCountSection(*m.DataCountSection)...)
+	}
 	if m.SectionElementCount(wasm.SectionIDCode) > 0 {
 		bytes = append(bytes, encodeCodeSection(m.CodeSection)...)
 	}
@@ -10,7 +10,7 @@ import (
 
 func TestModule_Encode(t *testing.T) {
 	i32, f32 := wasm.ValueTypeI32, wasm.ValueTypeF32
-	zero := uint32(0)
+	zero, one := uint32(0), uint32(1)
 
 	tests := []struct {
 		name     string
@@ -108,7 +108,7 @@ func TestModule_Encode(t *testing.T) {
 			name: "table and memory section",
 			input: &wasm.Module{
 				TableSection:  []*wasm.Table{{Min: 3, Type: wasm.RefTypeFuncref}},
-				MemorySection: &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true},
+				MemorySection: []*wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true}},
 			},
 			expected: append(append(Magic, version...),
 				wasm.SectionIDTable, 0x04, // 4 bytes in this section
@@ -119,6 +119,30 @@ func TestModule_Encode(t *testing.T) {
 				0x01, 0x01, 0x01, // min and max = 1
 			),
 		},
+		{
+			name: "multiple memories and data segment",
+			input: &wasm.Module{
+				MemorySection: []*wasm.Memory{{Min: 1}, {Min: 2}},
+				DataSection: []*wasm.DataSegment{
+					{
+						MemoryIndex:      1,
+						OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+						Init:             []byte{0xf},
+					},
+				},
+			},
+			expected: append(append(Magic, version...),
+				wasm.SectionIDMemory, 0x05, // 5 bytes in this section
+				0x02,       // 2 memories
+				0x00, 0x01, // min = 1
+				0x00, 0x02, // min = 2
+				wasm.SectionIDData, 0x08, // 8 bytes in this section
+				0x01,       // 1 data segment
+				0x02, 0x01, // active with memory index 1
+				wasm.OpcodeI32Const, 0x00, wasm.OpcodeEnd, // offset 0
+				0x01, 0xf, // 1 byte of data
+			),
+		},
 		{
 			name: "exported func with instructions",
 			input: &wasm.Module{
@@ -196,6 +220,20 @@ func TestModule_Encode(t *testing.T) {
 				wasm.ExternTypeGlobal, 0x00, // global[0]
 			),
 		},
+		{
+			name: "data count",
+			input: &wasm.Module{
+				DataSection:      []*wasm.DataSegment{{Init: []byte{0xf}}},
+				DataCountSection: &one,
+			},
+			expected: append(append(Magic, version...),
+				wasm.SectionIDDataCount, 0x01, // 1 byte in this section
+				0x01,                     // 1 data segment
+				wasm.SectionIDData, 0x04, // 4 bytes in this section
+				0x01,             // 1 data segment
+				0x01, 0x01, 0x0f, // passive data of 1 byte
+			),
+		},
 	}
 
 	for _, tt := range tests {
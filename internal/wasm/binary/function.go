@@ -9,14 +9,18 @@ import (
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-// encodeFunctionType returns the wasm.FunctionType encoded in WebAssembly 1.0 (20191205) Binary Format.
+// encodeFunctionType returns the wasm.FunctionType encoded in WebAssembly 1.0 (20191205) Binary Format, or the struct
+// or array type if it is not a function type.
 //
 // Note: Function types are encoded by the byte 0x60 followed by the respective vectors of parameter and result types.
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#function-types%E2%91%A4
 func encodeFunctionType(t *wasm.FunctionType) []byte {
+	if t.Composite != nil {
+		return encodeCompositeType(t.Composite)
+	}
 	// Only reached when "multi-value" is enabled because WebAssembly 1.0 (20191205) supports at most 1 result.
-	data := append([]byte{0x60}, encodeValTypes(t.Params)...)
-	return append(data, encodeValTypes(t.Results)...)
+	data := append([]byte{0x60}, encodeTypedValTypes(t.Params, t.ParamRefs)...)
+	return append(data, encodeTypedValTypes(t.Results, t.ResultRefs)...)
 }
 
 func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader) (*wasm.FunctionType, error) {
@@ -34,7 +38,7 @@ func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader) (*was
 		return nil, fmt.Errorf("could not read parameter count: %w", err)
 	}
 
-	paramTypes, err := decodeValueTypes(r, paramCount)
+	paramTypes, paramRefs, err := decodeTypedValueTypes(r, paramCount, enabledFeatures)
 	if err != nil {
 		return nil, fmt.Errorf("could not read parameter types: %w", err)
 	}
@@ -51,14 +55,16 @@ func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader) (*was
 		}
 	}
 
-	resultTypes, err := decodeValueTypes(r, resultCount)
+	resultTypes, resultRefs, err := decodeTypedValueTypes(r, resultCount, enabledFeatures)
 	if err != nil {
 		return nil, fmt.Errorf("could not read result types: %w", err)
 	}
 
 	ret := &wasm.FunctionType{
-		Params:  paramTypes,
-		Results: resultTypes,
+		Params:     paramTypes,
+		Results:    resultTypes,
+		ParamRefs:  paramRefs,
+		ResultRefs: resultRefs,
 	}
 
 	// cache the key for the function type
@@ -92,6 +92,60 @@ func TestFunctionType(t *testing.T) {
 	}
 }
 
+func TestFunctionType_FunctionReferences(t *testing.T) {
+	i32, funcRef, externRef := wasm.ValueTypeI32, wasm.ValueTypeFuncref, wasm.ValueTypeExternref
+	tests := []struct {
+		name     string
+		input    *wasm.FunctionType
+		expected []byte
+	}{
+		{
+			name: "typed param",
+			input: &wasm.FunctionType{
+				Params:    []wasm.ValueType{i32, funcRef},
+				ParamRefs: []*wasm.TypedRef{nil, {HeapType: 0}},
+			},
+			expected: []byte{0x60, 2, i32, wasm.RefTypePrefixNonNullable, 0, 0},
+		},
+		{
+			name: "typed results",
+			input: &wasm.FunctionType{
+				Results:    []wasm.ValueType{funcRef, externRef},
+				ResultRefs: []*wasm.TypedRef{{Nullable: true, HeapType: 1}, {HeapType: wasm.HeapTypeExtern}},
+			},
+			expected: []byte{0x60, 0, 2, wasm.RefTypePrefixNullable, 1, wasm.RefTypePrefixNonNullable, externRef},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		b := encodeFunctionType(tc.input)
+		t.Run(fmt.Sprintf("encode - %s", tc.name), func(t *testing.T) {
+			require.Equal(t, tc.expected, b)
+		})
+
+		t.Run(fmt.Sprintf("decode - %s", tc.name), func(t *testing.T) {
+			binary, err := decodeFunctionType(api.CoreFeaturesV2|api.CoreFeatureFunctionReferences, bytes.NewReader(b))
+			require.NoError(t, err)
+			// Set the FunctionType key on the input.
+			_ = tc.input.String()
+			require.Equal(t, binary, tc.input)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := decodeFunctionType(api.CoreFeaturesV2, bytes.NewReader([]byte{0x60, 1, wasm.RefTypePrefixNonNullable, 0, 0}))
+		require.EqualError(t, err, "could not read parameter types: invalid value type: 100")
+	})
+
+	t.Run("invalid heap type", func(t *testing.T) {
+		_, err := decodeFunctionType(api.CoreFeaturesV2|api.CoreFeatureFunctionReferences,
+			bytes.NewReader([]byte{0x60, 1, wasm.RefTypePrefixNonNullable, i32, 0}))
+		require.EqualError(t, err, "could not read parameter types: invalid heap type: -1")
+	})
+}
+
 func TestDecodeFunctionType_Errors(t *testing.T) {
 	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
 	tests := []struct {
@@ -0,0 +1,161 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// decodeRecTypes decodes the vs recursion groups of the type section when api.CoreFeatureGC is enabled. The types in
+// the groups are flattened into the type index space.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#type-definitions-1
+func decodeRecTypes(enabledFeatures api.CoreFeatures, r *bytes.Reader, vs uint32) ([]*wasm.FunctionType, error) {
+	var result []*wasm.FunctionType
+	for i := uint32(0); i < vs; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("read %d-th type: read leading byte: %w", i, err)
+		}
+		count := uint32(1)
+		if b == wasm.RecTypePrefix {
+			if count, _, err = leb128.DecodeUint32(r); err != nil {
+				return nil, fmt.Errorf("read %d-th type: read size of rec group: %w", i, err)
+			}
+		} else if err = r.UnreadByte(); err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < count; j++ {
+			t, err := decodeSubType(enabledFeatures, r)
+			if err != nil {
+				return nil, fmt.Errorf("read %d-th type: %v", len(result), err)
+			}
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// decodeSubType decodes a function, struct or array type, optionally with the declaration of its supertypes.
+func decodeSubType(enabledFeatures api.CoreFeatures, r *bytes.Reader) (*wasm.FunctionType, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read leading byte: %w", err)
+	}
+	final := true
+	var supertype *wasm.Index
+	if b == wasm.SubTypePrefix || b == wasm.SubTypePrefixFinal {
+		final = b == wasm.SubTypePrefixFinal
+		count, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("read size of supertypes: %w", err)
+		} else if count > 1 {
+			return nil, fmt.Errorf("at most one supertype is allowed but was %d", count)
+		} else if count == 1 {
+			index, _, err := leb128.DecodeUint32(r)
+			if err != nil {
+				return nil, fmt.Errorf("read supertype: %w", err)
+			}
+			supertype = &index
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return nil, fmt.Errorf("read leading byte: %w", err)
+		}
+	}
+
+	c := &wasm.CompositeType{Supertype: supertype, Final: final}
+	switch b {
+	case wasm.CompositeTypePrefixFunc:
+		if err = r.UnreadByte(); err != nil {
+			return nil, err
+		}
+		// The supertypes of a function type are not used as function types are only equivalent by their signature.
+		return decodeFunctionType(enabledFeatures, r)
+	case wasm.CompositeTypePrefixArray:
+		c.IsArray = true
+		f, err := decodeFieldType(enabledFeatures, r)
+		if err != nil {
+			return nil, fmt.Errorf("read element type: %w", err)
+		}
+		c.Fields = []*wasm.FieldType{f}
+	case wasm.CompositeTypePrefixStruct:
+		count, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("read size of fields: %w", err)
+		} else if uint64(count) > uint64(r.Len()) { // Each field is at least two bytes.
+			return nil, fmt.Errorf("too many fields: %d", count)
+		}
+		c.Fields = make([]*wasm.FieldType, count)
+		for i := range c.Fields {
+			if c.Fields[i], err = decodeFieldType(enabledFeatures, r); err != nil {
+				return nil, fmt.Errorf("read field %d: %w", i, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: %#x is not a type", ErrInvalidByte, b)
+	}
+	return &wasm.FunctionType{Composite: c}, nil
+}
+
+// decodeFieldType decodes the storage type and the mutability of a field of a struct or the element of an array.
+func decodeFieldType(enabledFeatures api.CoreFeatures, r *bytes.Reader) (*wasm.FieldType, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	f := &wasm.FieldType{Type: b}
+	if b != wasm.PackedTypeI8 && b != wasm.PackedTypeI16 {
+		if err = r.UnreadByte(); err != nil {
+			return nil, err
+		} else if f.Type, f.Ref, err = decodeValueType(r, enabledFeatures); err != nil {
+			return nil, err
+		}
+	}
+	mut, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read mutability: %w", err)
+	}
+	switch mut {
+	case 0x00:
+	case 0x01:
+		f.Mutable = true
+	default:
+		return nil, fmt.Errorf("invalid mutability: %#x", mut)
+	}
+	return f, nil
+}
+
+// encodeCompositeType returns the wasm.CompositeType encoded in the binary format, declaring its supertype if needed.
+func encodeCompositeType(c *wasm.CompositeType) (ret []byte) {
+	if c.Supertype != nil || !c.Final {
+		if c.Final {
+			ret = append(ret, wasm.SubTypePrefixFinal)
+		} else {
+			ret = append(ret, wasm.SubTypePrefix)
+		}
+		if c.Supertype != nil {
+			ret = append(ret, 1)
+			ret = append(ret, leb128.EncodeUint32(*c.Supertype)...)
+		} else {
+			ret = append(ret, 0)
+		}
+	}
+	if c.IsArray {
+		ret = append(ret, wasm.CompositeTypePrefixArray)
+	} else {
+		ret = append(ret, wasm.CompositeTypePrefixStruct)
+		ret = append(ret, leb128.EncodeUint32(uint32(len(c.Fields)))...)
+	}
+	for _, f := range c.Fields {
+		ret = append(ret, encodeValueType(f.Type, f.Ref)...)
+		if f.Mutable {
+			ret = append(ret, 1)
+		} else {
+			ret = append(ret, 0)
+		}
+	}
+	return
+}
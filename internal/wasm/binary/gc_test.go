@@ -0,0 +1,95 @@
+package binary
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const gcFeatures = api.CoreFeaturesV2 | api.CoreFeatureFunctionReferences | api.CoreFeatureGC
+
+func TestDecodeRecTypes(t *testing.T) {
+	// The value type of a reference to a type index is resolved by wasm.Module Validate, as the index can refer to a
+	// type defined later.
+	i32, unresolved := wasm.ValueTypeI32, wasm.ValueTypeFuncref
+	zero := wasm.Index(0)
+
+	// (type $node (sub (struct (field i32) (field (ref null $node)))))
+	// (rec
+	//   (type $leaf (sub final $node (struct (field i32) (field (ref null $node)) (field (mut i8)))))
+	//   (type $bytes (array (mut i16))))
+	// (type (func (param i32)))
+	input := []byte{
+		wasm.SubTypePrefix, 0, wasm.CompositeTypePrefixStruct, 2,
+		i32, 0,
+		wasm.RefTypePrefixNullable, 0, 0,
+		wasm.RecTypePrefix, 2,
+		wasm.SubTypePrefixFinal, 1, 0, wasm.CompositeTypePrefixStruct, 3,
+		i32, 0,
+		wasm.RefTypePrefixNullable, 0, 0,
+		wasm.PackedTypeI8, 1,
+		wasm.CompositeTypePrefixArray, wasm.PackedTypeI16, 1,
+		wasm.CompositeTypePrefixFunc, 1, i32, 0,
+	}
+	types, err := decodeRecTypes(gcFeatures, bytes.NewReader(input), 3)
+	require.NoError(t, err)
+
+	nodeRef := &wasm.TypedRef{Nullable: true, HeapType: 0}
+	expected := []*wasm.FunctionType{
+		{Composite: &wasm.CompositeType{Fields: []*wasm.FieldType{{Type: i32}, {Type: unresolved, Ref: nodeRef}}}},
+		{Composite: &wasm.CompositeType{Supertype: &zero, Final: true, Fields: []*wasm.FieldType{
+			{Type: i32}, {Type: unresolved, Ref: nodeRef}, {Type: wasm.PackedTypeI8, Mutable: true},
+		}}},
+		{Composite: &wasm.CompositeType{IsArray: true, Final: true, Fields: []*wasm.FieldType{
+			{Type: wasm.PackedTypeI16, Mutable: true},
+		}}},
+		{Params: []wasm.ValueType{i32}},
+	}
+	_ = expected[3].String()
+	require.Equal(t, expected, types)
+
+	// Composite types are encoded back without the rec group, which is only a syntax for forward references.
+	require.Equal(t, input[:9], encodeCompositeType(types[0].Composite))
+	require.Equal(t, input[11:23], encodeCompositeType(types[1].Composite))
+	require.Equal(t, input[23:26], encodeCompositeType(types[2].Composite))
+}
+
+func TestDecodeRecTypes_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{
+			name:        "invalid prefix",
+			input:       []byte{0x40},
+			expectedErr: "read 0-th type: invalid byte: 0x40 is not a type",
+		},
+		{
+			name:        "multiple supertypes",
+			input:       []byte{wasm.SubTypePrefix, 2, 0, 1, wasm.CompositeTypePrefixStruct, 0},
+			expectedErr: "read 0-th type: at most one supertype is allowed but was 2",
+		},
+		{
+			name:        "invalid mutability",
+			input:       []byte{wasm.CompositeTypePrefixArray, wasm.ValueTypeI32, 2},
+			expectedErr: "read 0-th type: read element type: invalid mutability: 0x2",
+		},
+		{
+			name:        "too many fields",
+			input:       []byte{wasm.CompositeTypePrefixStruct, 10, wasm.ValueTypeI32, 0},
+			expectedErr: "read 0-th type: too many fields: 10",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeRecTypes(gcFeatures, bytes.NewReader(tc.input), 1)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
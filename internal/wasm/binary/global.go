@@ -12,7 +12,7 @@ import (
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-global
 func decodeGlobal(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm.Global, error) {
-	gt, err := decodeGlobalType(r)
+	gt, err := decodeGlobalType(r, enabledFeatures)
 	if err != nil {
 		return nil, err
 	}
@@ -28,14 +28,15 @@ func decodeGlobal(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm.Glob
 // decodeGlobalType returns the wasm.GlobalType decoded with the WebAssembly 1.0 (20191205) Binary Format.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-globaltype
-func decodeGlobalType(r *bytes.Reader) (*wasm.GlobalType, error) {
-	vt, err := decodeValueTypes(r, 1)
+func decodeGlobalType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm.GlobalType, error) {
+	vt, ref, err := decodeValueType(r, enabledFeatures)
 	if err != nil {
 		return nil, fmt.Errorf("read value type: %w", err)
 	}
 
 	ret := &wasm.GlobalType{
-		ValType: vt[0],
+		ValType: vt,
+		Ref:     ref,
 	}
 
 	b, err := r.ReadByte()
@@ -61,7 +62,7 @@ func encodeGlobal(g *wasm.Global) (data []byte) {
 	if g.Type.Mutable {
 		mutable = 1
 	}
-	data = []byte{g.Type.ValType, mutable}
+	data = append(encodeValueType(g.Type.ValType, g.Type.Ref), mutable)
 	data = append(data, encodeConstantExpression(g.Init)...)
 	return
 }
@@ -36,6 +36,44 @@ func TestEncodeGlobal(t *testing.T) {
 				wasm.OpcodeI32Const, 0x01, wasm.OpcodeEnd,
 			},
 		},
+		{
+			name: "v128",
+			input: &wasm.Global{
+				Type: &wasm.GlobalType{ValType: wasm.ValueTypeV128},
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeVecV128Const, Data: make([]byte, 16)},
+			},
+			expected: []byte{
+				wasm.ValueTypeV128, 0x00, // 0 == const
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "extended const",
+			input: &wasm.Global{
+				Type: &wasm.GlobalType{ValType: wasm.ValueTypeI32},
+				Init: &wasm.ConstantExpression{
+					Opcode: wasm.OpcodeGlobalGet,
+					Data:   []byte{0x00, wasm.OpcodeI32Const, 0x01, wasm.OpcodeI32Add},
+				},
+			},
+			expected: []byte{
+				wasm.ValueTypeI32, 0x00, // 0 == const
+				wasm.OpcodeGlobalGet, 0x00, wasm.OpcodeI32Const, 0x01, wasm.OpcodeI32Add, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "typed reference",
+			input: &wasm.Global{
+				Type: &wasm.GlobalType{ValType: wasm.ValueTypeFuncref, Ref: &wasm.TypedRef{HeapType: 1}},
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeRefFunc, Data: []byte{0x00}},
+			},
+			expected: []byte{
+				wasm.RefTypePrefixNonNullable, 0x01, 0x00, // (ref 1), 0 == const
+				wasm.OpcodeRefFunc, 0x00, wasm.OpcodeEnd,
+			},
+		},
 	}
 
 	for _, tt := range tests {
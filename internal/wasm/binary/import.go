@@ -36,9 +36,13 @@ func decodeImport(
 	case wasm.ExternTypeTable:
 		i.DescTable, err = decodeTable(r, enabledFeatures)
 	case wasm.ExternTypeMemory:
-		i.DescMem, err = decodeMemory(r, memorySizer, memoryLimitPages)
+		i.DescMem, err = decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
 	case wasm.ExternTypeGlobal:
-		i.DescGlobal, err = decodeGlobalType(r)
+		i.DescGlobal, err = decodeGlobalType(r, enabledFeatures)
+	case wasm.ExternTypeTag:
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err == nil {
+			i.DescTag, err = decodeTagType(r)
+		}
 	default:
 		err = fmt.Errorf("%w: invalid byte for importdesc: %#x", ErrInvalidByte, b)
 	}
@@ -60,20 +64,19 @@ func encodeImport(i *wasm.Import) []byte {
 		data = append(data, leb128.EncodeUint32(i.DescFunc)...)
 	case wasm.ExternTypeTable:
 		data = append(data, wasm.RefTypeFuncref)
-		data = append(data, encodeLimitsType(i.DescTable.Min, i.DescTable.Max)...)
+		data = append(data, encodeLimitsType(i.DescTable.Min, i.DescTable.Max, false, false)...)
 	case wasm.ExternTypeMemory:
-		maxPtr := &i.DescMem.Max
-		if !i.DescMem.IsMaxEncoded {
-			maxPtr = nil
-		}
-		data = append(data, encodeLimitsType(i.DescMem.Min, maxPtr)...)
+		data = append(data, encodeMemory(i.DescMem)...)
 	case wasm.ExternTypeGlobal:
 		g := i.DescGlobal
 		var mutable byte
 		if g.Mutable {
 			mutable = 1
 		}
-		data = append(data, g.ValType, mutable)
+		data = append(data, encodeValueType(g.ValType, g.Ref)...)
+		data = append(data, mutable)
+	case wasm.ExternTypeTag:
+		data = append(data, encodeTagType(i.DescTag)...)
 	default:
 		panic(fmt.Errorf("invalid externtype: %s", wasm.ExternTypeName(i.Type)))
 	}
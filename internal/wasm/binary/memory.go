@@ -2,7 +2,9 @@ package binary
 
 import (
 	"bytes"
+	"fmt"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -11,16 +13,38 @@ import (
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-memory
 func decodeMemory(
 	r *bytes.Reader,
+	enabledFeatures api.CoreFeatures,
 	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
 	memoryLimitPages uint32,
 ) (*wasm.Memory, error) {
-	min, maxP, err := decodeLimitsType(r)
+	min, maxP, shared, is64, err := decodeLimitsType(r)
 	if err != nil {
 		return nil, err
 	}
+	if is64 {
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMemory64); err != nil {
+			return nil, fmt.Errorf("64-bit memory invalid as %w", err)
+		}
+	} else if memoryLimitPages > wasm.MemoryLimitPages {
+		// Only a 64-bit memory can address more than 4GiB.
+		memoryLimitPages = wasm.MemoryLimitPages
+	}
+	if shared {
+		if !enabledFeatures.IsEnabled(api.CoreFeatureThreads) {
+			return nil, fmt.Errorf("shared memory requested but threads feature not enabled")
+		}
+		// This restriction may be lifted in the future.
+		// https://webassembly.github.io/threads/core/binary/types.html#memory-types
+		if maxP == nil {
+			return nil, fmt.Errorf("shared memory requires a maximum size to be specified")
+		}
+	}
 
 	min, capacity, max := memorySizer(min, maxP)
-	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil}
+	if maxP == nil && max > memoryLimitPages {
+		max = memoryLimitPages
+	}
+	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil, IsShared: shared, Is64: is64}
 
 	return mem, mem.Validate(memoryLimitPages)
 }
@@ -33,5 +57,5 @@ func encodeMemory(i *wasm.Memory) []byte {
 	if !i.IsMaxEncoded {
 		maxPtr = nil
 	}
-	return encodeLimitsType(i.Min, maxPtr)
+	return encodeLimitsType(i.Min, maxPtr, i.IsShared, i.Is64)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -122,6 +123,21 @@ func TestMemoryType(t *testing.T) {
 			input:    &wasm.Memory{Min: max, Cap: max, Max: max, IsMaxEncoded: true},
 			expected: []byte{0x1, 0x80, 0x80, 0x4, 0x80, 0x80, 0x4},
 		},
+		{
+			name:     "shared",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, IsShared: true},
+			expected: []byte{0x3, 0x1, 0x2},
+		},
+		{
+			name:     "64-bit",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, Is64: true},
+			expected: []byte{0x5, 0x1, 0x2},
+		},
+		{
+			name:     "64-bit default max",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: max, Is64: true},
+			expected: []byte{0x4, 0x1},
+		},
 	}
 
 	for _, tt := range tests {
@@ -133,7 +149,7 @@ func TestMemoryType(t *testing.T) {
 		})
 
 		t.Run(fmt.Sprintf("decode %s", tc.name), func(t *testing.T) {
-			binary, err := decodeMemory(bytes.NewReader(b), newMemorySizer(max, false), max)
+			binary, err := decodeMemory(bytes.NewReader(b), api.CoreFeatureThreads|api.CoreFeatureMemory64, newMemorySizer(max, false), max)
 			require.NoError(t, err)
 			require.Equal(t, binary, tc.input)
 		})
@@ -146,8 +162,27 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 	tests := []struct {
 		name        string
 		input       []byte
+		features    api.CoreFeatures
 		expectedErr string
 	}{
+		{
+			name:        "shared without threads",
+			input:       []byte{0x3, 0, 1},
+			features:    api.CoreFeaturesV2,
+			expectedErr: "shared memory requested but threads feature not enabled",
+		},
+		{
+			name:        "shared without max",
+			input:       []byte{0x2, 0},
+			features:    api.CoreFeatureThreads,
+			expectedErr: "shared memory requires a maximum size to be specified",
+		},
+		{
+			name:        "64-bit without memory64",
+			input:       []byte{0x4, 0},
+			features:    api.CoreFeaturesV2,
+			expectedErr: "64-bit memory invalid as feature \"memory64\" is disabled",
+		},
 		{
 			name:        "max < min",
 			input:       []byte{0x1, 0x80, 0x80, 0x4, 0},
@@ -156,12 +191,12 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 		{
 			name:        "min > limit",
 			input:       []byte{0x0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			input:       []byte{0x1, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 	}
 
@@ -169,8 +204,54 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemory(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemory(bytes.NewReader(tc.input), tc.features, newMemorySizer(max, false), max)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
 }
+
+func TestDecodeMemoryType_Memory64Limit(t *testing.T) {
+	limit := wasm.MemoryLimitPages * 2
+
+	tests := []struct {
+		name        string
+		input       []byte
+		expected    *wasm.Memory
+		expectedErr string
+	}{
+		{
+			name:     "64-bit default max",
+			input:    []byte{0x4, 0},
+			expected: &wasm.Memory{Max: limit, Is64: true},
+		},
+		{
+			name:     "64-bit min over 32-bit limit",
+			input:    []byte{0x5, 0x81, 0x80, 0x4, 0x81, 0x80, 0x4},
+			expected: &wasm.Memory{Min: 65537, Cap: 65537, Max: 65537, IsMaxEncoded: true, Is64: true},
+		},
+		{
+			name:     "32-bit default max",
+			input:    []byte{0x0, 0},
+			expected: &wasm.Memory{Max: wasm.MemoryLimitPages},
+		},
+		{
+			name:        "32-bit min over 32-bit limit",
+			input:       []byte{0x0, 0x81, 0x80, 0x4},
+			expectedErr: "min 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			mem, err := decodeMemory(bytes.NewReader(tc.input), api.CoreFeatureMemory64, newMemorySizer(limit, false), limit)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, mem)
+			}
+		})
+	}
+}
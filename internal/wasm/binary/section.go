@@ -16,6 +16,10 @@ func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]*wa
 		return nil, fmt.Errorf("get size of vector: %w", err)
 	}
 
+	if enabledFeatures.IsEnabled(api.CoreFeatureGC) {
+		return decodeRecTypes(enabledFeatures, r, vs)
+	}
+
 	result := make([]*wasm.FunctionType, vs)
 	for i := uint32(0); i < vs; i++ {
 		if result[i], err = decodeFunctionType(enabledFeatures, r); err != nil {
@@ -84,21 +88,68 @@ func decodeTableSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]*w
 
 func decodeMemorySection(
 	r *bytes.Reader,
+	enabledFeatures api.CoreFeatures,
 	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
 	memoryLimitPages uint32,
-) (*wasm.Memory, error) {
+) ([]*wasm.Memory, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("error reading size")
 	}
 	if vs > 1 {
-		return nil, fmt.Errorf("at most one memory allowed in module, but read %d", vs)
+		if err := enabledFeatures.RequireEnabled(api.CoreFeatureMultiMemory); err != nil {
+			return nil, fmt.Errorf("at most one memory allowed in module as %w", err)
+		}
 	} else if vs == 0 {
 		// memory count can be zero.
 		return nil, nil
 	}
 
-	return decodeMemory(r, memorySizer, memoryLimitPages)
+	ret := make([]*wasm.Memory, vs)
+	for i := range ret {
+		memory, err := decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = memory
+	}
+	return ret, nil
+}
+
+func decodeTagSection(r *bytes.Reader) ([]wasm.Index, error) {
+	vs, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("get size of vector: %w", err)
+	}
+
+	result := make([]wasm.Index, vs)
+	for i := uint32(0); i < vs; i++ {
+		if result[i], err = decodeTagType(r); err != nil {
+			return nil, fmt.Errorf("read %d-th tag: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// tagAttributeException is the only attribute of a tag defined in the exception-handling proposal.
+const tagAttributeException = 0x00
+
+// decodeTagType returns the type index of a tag, which is preceded by the attribute of the tag.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+func decodeTagType(r *bytes.Reader) (wasm.Index, error) {
+	attribute, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("read attribute: %w", err)
+	}
+	if attribute != tagAttributeException {
+		return 0, fmt.Errorf("%w: invalid tag attribute: %#x", ErrInvalidByte, attribute)
+	}
+	typeIndex, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return 0, fmt.Errorf("get type index: %w", err)
+	}
+	return typeIndex, nil
 }
 
 func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]*wasm.Global, error) {
@@ -162,7 +213,7 @@ func decodeElementSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]
 	return result, nil
 }
 
-func decodeCodeSection(r *bytes.Reader) ([]*wasm.Code, error) {
+func decodeCodeSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]*wasm.Code, error) {
 	codeSectionStart := uint64(r.Len())
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
@@ -171,7 +222,7 @@ func decodeCodeSection(r *bytes.Reader) ([]*wasm.Code, error) {
 
 	result := make([]*wasm.Code, vs)
 	for i := uint32(0); i < vs; i++ {
-		c, err := decodeCode(r, codeSectionStart)
+		c, err := decodeCode(r, codeSectionStart, enabledFeatures)
 		if err != nil {
 			return nil, fmt.Errorf("read %d-th code segment: %v", i, err)
 		}
@@ -279,11 +330,30 @@ func encodeTableSection(tables []*wasm.Table) []byte {
 //
 // See encodeMemory
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-func encodeMemorySection(memory *wasm.Memory) []byte {
-	contents := append([]byte{1}, encodeMemory(memory)...)
+func encodeMemorySection(memories []*wasm.Memory) []byte {
+	contents := leb128.EncodeUint32(uint32(len(memories)))
+	for _, memory := range memories {
+		contents = append(contents, encodeMemory(memory)...)
+	}
 	return encodeSection(wasm.SectionIDMemory, contents)
 }
 
+// encodeTagSection encodes a wasm.SectionIDTag for the type indices associated with module-defined tags.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+func encodeTagSection(typeIndices []wasm.Index) []byte {
+	contents := leb128.EncodeUint32(uint32(len(typeIndices)))
+	for _, index := range typeIndices {
+		contents = append(contents, encodeTagType(index)...)
+	}
+	return encodeSection(wasm.SectionIDTag, contents)
+}
+
+// encodeTagType encodes the type index of a tag, preceded by its attribute.
+func encodeTagType(typeIndex wasm.Index) []byte {
+	return append([]byte{tagAttributeException}, leb128.EncodeUint32(typeIndex)...)
+}
+
 // encodeGlobalSection encodes a wasm.SectionIDGlobal for the given globals in WebAssembly 1.0 (20191205) Binary
 // Format.
 //
@@ -341,3 +411,11 @@ func encodeDataSection(datum []*wasm.DataSegment) []byte {
 	}
 	return encodeSection(wasm.SectionIDData, contents)
 }
+
+// encodeDataCountSection encodes a wasm.SectionIDDataCount for the count of data segments in WebAssembly 2.0 Binary
+// Format.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-count-section
+func encodeDataCountSection(count uint32) []byte {
+	return encodeSection(wasm.SectionIDDataCount, leb128.EncodeUint32(count))
+}
@@ -87,7 +87,8 @@ func TestMemorySection(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    []byte
-		expected *wasm.Memory
+		features api.CoreFeatures
+		expected []*wasm.Memory
 	}{
 		{
 			name: "min and min with max",
@@ -95,7 +96,21 @@ func TestMemorySection(t *testing.T) {
 				0x01,             // 1 memory
 				0x01, 0x02, 0x03, // (memory 2 3)
 			},
-			expected: &wasm.Memory{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			features: api.CoreFeaturesV2,
+			expected: []*wasm.Memory{{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true}},
+		},
+		{
+			name: "multiple memories",
+			input: []byte{
+				0x02,       // 2 memories
+				0x00, 0x01, // (memory 1)
+				0x01, 0x02, 0x03, // (memory 2 3)
+			},
+			features: api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+			expected: []*wasm.Memory{
+				{Min: 1, Cap: 1, Max: max},
+				{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			},
 		},
 	}
 
@@ -103,7 +118,7 @@ func TestMemorySection(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			memories, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			memories, err := decodeMemorySection(bytes.NewReader(tc.input), tc.features, newMemorySizer(max, false), max)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, memories)
 		})
@@ -125,7 +140,7 @@ func TestMemorySection_Errors(t *testing.T) {
 				0x01,       // (memory 1)
 				0x02, 0x03, // (memory 2 3)
 			},
-			expectedErr: "at most one memory allowed in module, but read 2",
+			expectedErr: "at most one memory allowed in module as feature \"multi-memory\" is disabled",
 		},
 	}
 
@@ -133,7 +148,7 @@ func TestMemorySection_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemorySection(bytes.NewReader(tc.input), api.CoreFeaturesV2, newMemorySizer(max, false), max)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -221,6 +236,23 @@ func TestEncodeStartSection(t *testing.T) {
 	require.Equal(t, []byte{wasm.SectionIDStart, 0x01, 0x05}, encodeStartSection(5))
 }
 
+func TestTagSection(t *testing.T) {
+	input := []byte{
+		0x02,       // 2 tags
+		0x00, 0x01, // (tag (type 1))
+		0x00, 0x00, // (tag (type 0))
+	}
+	tags, err := decodeTagSection(bytes.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []wasm.Index{1, 0}, tags)
+	require.Equal(t, append([]byte{wasm.SectionIDTag, byte(len(input))}, input...), encodeTagSection(tags))
+}
+
+func TestTagSection_Errors(t *testing.T) {
+	_, err := decodeTagSection(bytes.NewReader([]byte{0x01, 0x01, 0x00}))
+	require.EqualError(t, err, "read 0-th tag: invalid byte: invalid tag attribute: 0x1")
+}
+
 func TestDecodeDataCountSection(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		v, err := decodeDataCountSection(bytes.NewReader([]byte{0x1}))
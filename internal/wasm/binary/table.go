@@ -22,11 +22,21 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm.Table
 			return nil, fmt.Errorf("table type funcref is invalid: %w", err)
 		}
 	}
+	// Notably, a table can't hold references to structs and arrays (api.CoreFeatureGC), which wasm.GCHeap doesn't scan.
+	if tableType != wasm.RefTypeFuncref && tableType != wasm.RefTypeExternref {
+		return nil, fmt.Errorf("table type must be funcref or externref, but was %s", wasm.ValueTypeName(tableType))
+	}
 
-	min, max, err := decodeLimitsType(r)
+	min, max, shared, is64, err := decodeLimitsType(r)
 	if err != nil {
 		return nil, fmt.Errorf("read limits: %v", err)
 	}
+	if shared {
+		return nil, fmt.Errorf("tables cannot be marked as shared")
+	}
+	if is64 {
+		return nil, fmt.Errorf("tables cannot be 64-bit")
+	}
 	if min > wasm.MaximumFunctionIndex {
 		return nil, fmt.Errorf("table min must be at most %d", wasm.MaximumFunctionIndex)
 	}
@@ -42,5 +52,5 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm.Table
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-table
 func encodeTable(i *wasm.Table) []byte {
-	return append([]byte{i.Type}, encodeLimitsType(i.Min, i.Max)...)
+	return append([]byte{i.Type}, encodeLimitsType(i.Min, i.Max, false, false)...)
 }
@@ -79,6 +79,18 @@ func TestDecodeTableType_Errors(t *testing.T) {
 			input:       []byte{0x50, 0x1, 0x80, 0x80, 0x4, 0},
 			expectedErr: "table type funcref is invalid: feature \"reference-types\" is disabled",
 		},
+		{
+			name:        "not a reference",
+			input:       []byte{wasm.ValueTypeI32, 0x0, 0},
+			expectedErr: "table type must be funcref or externref, but was i32",
+			features:    api.CoreFeatureReferenceTypes,
+		},
+		{
+			name:        "anyref",
+			input:       []byte{wasm.ValueTypeAnyref, 0x0, 0},
+			expectedErr: "table type must be funcref or externref, but was anyref",
+			features:    api.CoreFeaturesV2 | api.CoreFeatureFunctionReferences | api.CoreFeatureGC,
+		},
 		{
 			name:        "max < min",
 			input:       []byte{wasm.RefTypeFuncref, 0x1, 0x80, 0x80, 0x4, 0},
@@ -91,6 +103,18 @@ func TestDecodeTableType_Errors(t *testing.T) {
 			expectedErr: "table min must be at most 134217728",
 			features:    api.CoreFeatureReferenceTypes,
 		},
+		{
+			name:        "shared",
+			input:       []byte{wasm.RefTypeFuncref, 0x3, 0, 1},
+			expectedErr: "tables cannot be marked as shared",
+			features:    api.CoreFeatureReferenceTypes | api.CoreFeatureThreads,
+		},
+		{
+			name:        "64-bit",
+			input:       []byte{wasm.RefTypeFuncref, 0x4, 0},
+			expectedErr: "tables cannot be 64-bit",
+			features:    api.CoreFeatureReferenceTypes | api.CoreFeatureMemory64,
+		},
 	}
 
 	for _, tt := range tests {
@@ -6,6 +6,7 @@ import (
 	"io"
 	"unicode/utf8"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -67,6 +68,102 @@ func decodeValueTypes(r *bytes.Reader, num uint32) ([]wasm.ValueType, error) {
 	return ret, nil
 }
 
+// decodeTypedValueTypes is like decodeValueTypes, but also decodes typed references when
+// api.CoreFeatureFunctionReferences is enabled. The returned refs are nil unless any of the value types is typed.
+func decodeTypedValueTypes(r *bytes.Reader, num uint32, enabledFeatures api.CoreFeatures) ([]wasm.ValueType, []*wasm.TypedRef, error) {
+	if !enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) {
+		vts, err := decodeValueTypes(r, num)
+		return vts, nil, err
+	} else if num == 0 {
+		return nil, nil, nil
+	}
+
+	vts := make([]wasm.ValueType, num)
+	var refs []*wasm.TypedRef
+	for i := range vts {
+		vt, ref, err := decodeValueType(r, enabledFeatures)
+		if err != nil {
+			return nil, nil, err
+		}
+		vts[i] = vt
+		if ref != nil {
+			if refs == nil {
+				refs = make([]*wasm.TypedRef, num)
+			}
+			refs[i] = ref
+		}
+	}
+	return vts, refs, nil
+}
+
+// decodeValueType decodes a single value type, which can be a typed reference `(ref ht)` or `(ref null ht)` when
+// api.CoreFeatureFunctionReferences is enabled.
+func decodeValueType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (wasm.ValueType, *wasm.TypedRef, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	switch b {
+	case wasm.ValueTypeI32, wasm.ValueTypeF32, wasm.ValueTypeI64, wasm.ValueTypeF64,
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeV128:
+		return b, nil, nil
+	case wasm.RefTypePrefixNonNullable, wasm.RefTypePrefixNullable:
+		if !enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) {
+			break
+		}
+		heapType, err := decodeHeapType(r, enabledFeatures)
+		if err != nil {
+			return 0, nil, err
+		}
+		ref := &wasm.TypedRef{Nullable: b == wasm.RefTypePrefixNullable, HeapType: heapType}
+		return ref.ValueType(), wasm.NewTypedRef(ref.Nullable, heapType), nil
+	default:
+		// The shorthands of the nullable abstract reference types of api.CoreFeatureGC, e.g. eqref.
+		if heapType, ok := wasm.HeapTypeOfByte(b, enabledFeatures); ok {
+			ref := &wasm.TypedRef{Nullable: true, HeapType: heapType}
+			return ref.ValueType(), wasm.NewTypedRef(true, heapType), nil
+		}
+	}
+	return 0, nil, fmt.Errorf("invalid value type: %d", b)
+}
+
+// decodeHeapType decodes the heap type of a typed reference, which is a signed 33-bit integer.
+func decodeHeapType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (wasm.HeapType, error) {
+	raw, _, err := leb128.DecodeInt33AsInt64(r)
+	if err != nil {
+		return 0, fmt.Errorf("read heap type: %w", err)
+	}
+	ht := wasm.HeapType(raw)
+	if err = wasm.ValidateHeapType(nil, ht, enabledFeatures); err != nil {
+		return 0, err
+	}
+	return ht, nil
+}
+
+// encodeValueType encodes the value type vt, or the typed reference ref if non-nil.
+func encodeValueType(vt wasm.ValueType, ref *wasm.TypedRef) []byte {
+	if ref == nil {
+		return []byte{vt}
+	}
+	prefix := wasm.RefTypePrefixNonNullable
+	if ref.Nullable {
+		prefix = wasm.RefTypePrefixNullable
+	}
+	return append([]byte{prefix}, leb128.EncodeInt64(int64(ref.HeapType))...)
+}
+
+// encodeTypedValTypes is like encodeValTypes, but encodes each value type with its typed reference in refs if any.
+func encodeTypedValTypes(vts []wasm.ValueType, refs []*wasm.TypedRef) []byte {
+	if refs == nil {
+		return encodeValTypes(vts)
+	}
+	ret := leb128.EncodeUint32(uint32(len(vts)))
+	for i, vt := range vts {
+		ret = append(ret, encodeValueType(vt, refs[i])...)
+	}
+	return ret
+}
+
 // decodeUTF8 decodes a size prefixed string from the reader, returning it and the count of bytes read.
 // contextFormat and contextArgs apply an error format when present
 func decodeUTF8(r *bytes.Reader, contextFormat string, contextArgs ...interface{}) (string, uint32, error) {
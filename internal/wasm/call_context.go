@@ -8,6 +8,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -78,6 +79,42 @@ func (m *CallContext) CloseModuleOnCanceledOrTimeout(ctx context.Context) contex
 	return cancelFn
 }
 
+// WatchCanceledOrTimeout is the same as CloseModuleOnCanceledOrTimeout, unless Store.InterruptOnContextDone is true.
+// In that case, the module is left open, and the returned interrupted is set to non-zero instead. Engines check it
+// with FailIfInterrupted where they check FailIfClosed, so that only the current call fails.
+//
+// Callers of this function must invoke the returned context.CancelFunc to release the spawned Goroutine.
+func (m *CallContext) WatchCanceledOrTimeout(ctx context.Context) (interrupted *uint32, done context.CancelFunc) {
+	if m.s == nil || !m.s.InterruptOnContextDone {
+		return nil, m.CloseModuleOnCanceledOrTimeout(ctx)
+	}
+	// The flag is allocated per call, as the Goroutine might set it after done is invoked.
+	interrupted = new(uint32)
+	goroutineDone, cancelFn := context.WithCancel(context.Background())
+	go interruptOnCanceledOrTimeoutClosure(ctx, goroutineDone, interrupted)()
+	return interrupted, cancelFn
+}
+
+// interruptOnCanceledOrTimeoutClosure is extracted from WatchCanceledOrTimeout for testing.
+func interruptOnCanceledOrTimeoutClosure(ctx, goroutineDone context.Context, interrupted *uint32) func() {
+	return func() {
+		select {
+		case <-ctx.Done():
+			atomic.StoreUint32(interrupted, 1)
+		case <-goroutineDone.Done():
+		}
+	}
+}
+
+// FailIfInterrupted returns wasmruntime.ErrRuntimeInterrupted if interrupted was set by WatchCanceledOrTimeout.
+// interrupted may be nil, which is never interrupted.
+func FailIfInterrupted(interrupted *uint32) error {
+	if interrupted != nil && atomic.LoadUint32(interrupted) != 0 {
+		return wasmruntime.ErrRuntimeInterrupted
+	}
+	return nil
+}
+
 // closeModuleOnCanceledOrTimeoutClosure is extracted from CloseModuleOnCanceledOrTimeout for testing.
 func (m *CallContext) closeModuleOnCanceledOrTimeoutClosure(ctx, goroutineDone context.Context) func() {
 	return func() {
@@ -104,6 +141,32 @@ func (m *CallContext) Name() string {
 	return m.module.Name
 }
 
+// Snapshot captures the state of this module, or fails if it is closed. See ModuleInstance.Snapshot
+func (m *CallContext) Snapshot() (*Snapshot, error) {
+	if err := m.FailIfClosed(); err != nil {
+		return nil, err
+	}
+	return m.module.Snapshot()
+}
+
+// Reset restores the state of this module from the Snapshot, and replaces its system context with sys after closing
+// the files opened by the previous one. This fails if the module is closed, as it can't be used anymore.
+func (m *CallContext) Reset(ctx context.Context, snapshot *Snapshot, sys *internalsys.Context) error {
+	if err := m.FailIfClosed(); err != nil {
+		return err
+	}
+	if err := m.module.restore(snapshot); err != nil {
+		return err
+	}
+	if m.Sys != nil {
+		if err := m.Sys.FS().Close(ctx); err != nil {
+			return err
+		}
+	}
+	m.Sys = sys
+	return nil
+}
+
 // WithMemory allows overriding memory without re-allocation when the result would be the same.
 func (m *CallContext) WithMemory(memory *MemoryInstance) *CallContext {
 	if memory != nil && memory != m.memory { // only re-allocate if it will change the effective memory
@@ -147,6 +210,17 @@ func (m *CallContext) setExitCode(exitCode uint32) bool {
 // ensureResourcesClosed ensures that resources assigned to CallContext is released.
 // Multiple calls to this function is safe.
 func (m *CallContext) ensureResourcesClosed(ctx context.Context) (err error) {
+	if m.module != nil && m.module.GCHeap != nil {
+		m.module.GCHeap.removeModule(m.module)
+	}
+
+	if m.module != nil && m.module.Source != nil {
+		// Release the reservations of the memories defined by the module, but not the imported ones.
+		for _, mem := range m.module.Memories[m.module.Source.ImportMemoryCount():] {
+			mem.Release()
+		}
+	}
+
 	if sysCtx := m.Sys; sysCtx != nil { // nil if from HostModuleBuilder
 		if err = sysCtx.FS().Close(ctx); err != nil {
 			return err
@@ -171,27 +245,44 @@ func (m *CallContext) Memory() api.Memory {
 
 // ExportedMemory implements the same method as documented on api.Module.
 func (m *CallContext) ExportedMemory(name string) api.Memory {
-	_, err := m.module.getExport(name, ExternTypeMemory)
+	exp, err := m.module.getExport(name, ExternTypeMemory)
 	if err != nil {
 		return nil
 	}
-	// We Assume that we have at most one memory.
-	return m.memory
+	return m.module.Memories[exp.Index]
 }
 
 // ExportedMemoryDefinitions implements the same method as documented on
 // api.Module.
 func (m *CallContext) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
-	// Special case as we currently only support one memory.
-	if mem := m.module.Memory; mem != nil {
-		// Now, find out if it is exported
-		for name, exp := range m.module.Exports {
-			if exp.Type == ExternTypeMemory {
-				return map[string]api.MemoryDefinition{name: mem.definition}
-			}
+	result := map[string]api.MemoryDefinition{}
+	for name, exp := range m.module.Exports {
+		if exp.Type == ExternTypeMemory {
+			result[name] = m.module.Memories[exp.Index].definition
+		}
+	}
+	return result
+}
+
+// ExportedTable implements the same method as documented on api.Module.
+func (m *CallContext) ExportedTable(name string) api.Table {
+	exp, err := m.module.getExport(name, ExternTypeTable)
+	if err != nil {
+		return nil
+	}
+	return table{m.module.Tables[exp.Index]}
+}
+
+// ExportedTableDefinitions implements the same method as documented on
+// api.Module.
+func (m *CallContext) ExportedTableDefinitions() map[string]api.TableDefinition {
+	result := map[string]api.TableDefinition{}
+	for name, exp := range m.module.Exports {
+		if exp.Type == ExternTypeTable {
+			result[name] = m.module.Tables[exp.Index].definition
 		}
 	}
-	return map[string]api.MemoryDefinition{}
+	return result
 }
 
 // ExportedFunction implements the same method as documented on api.Module.
@@ -241,6 +332,8 @@ func (m *CallContext) function(f *FunctionInstance) api.Function {
 type function struct {
 	fi *FunctionInstance
 	ce CallEngine
+	// gcResults are the references returned by the last call, which are kept alive until the next one returns.
+	gcResults *gcResults
 }
 
 // Definition implements the same method as documented on api.FunctionDefinition.
@@ -250,14 +343,46 @@ func (f *function) Definition() api.FunctionDefinition {
 
 // Call implements the same method as documented on api.Function.
 func (f *function) Call(ctx context.Context, params ...uint64) (ret []uint64, err error) {
+	if h := f.fi.Module.GCHeap; h != nil {
+		h.enter(f.ce)
+		defer func() {
+			f.gcResults.release()
+			f.gcResults = h.exit(f.ce, f.fi.Type, ret, err)
+		}()
+	}
 	return f.ce.Call(ctx, f.fi.Module.CallCtx, params)
 }
 
+// CallWithStack implements the same method as documented on api.Function.
+func (f *function) CallWithStack(ctx context.Context, stack []uint64) (err error) {
+	if h := f.fi.Module.GCHeap; h != nil {
+		h.enter(f.ce)
+		defer func() {
+			var results []uint64
+			if err == nil {
+				results = stack[:f.fi.Type.ResultNumInUint64]
+			}
+			f.gcResults.release()
+			f.gcResults = h.exit(f.ce, f.fi.Type, results, err)
+		}()
+	}
+	return f.ce.CallWithStack(ctx, f.fi.Module.CallCtx, stack)
+}
+
 // GlobalVal is an internal hack to get the lower 64 bits of a global.
 func (m *CallContext) GlobalVal(idx Index) uint64 {
 	return m.module.Globals[idx].Val
 }
 
+// ExportedTag implements the same method as documented on api.Module.
+func (m *CallContext) ExportedTag(name string) api.Tag {
+	exp, err := m.module.getExport(name, ExternTypeTag)
+	if err != nil {
+		return nil
+	}
+	return m.module.Tags[exp.Index]
+}
+
 // ExportedGlobal implements the same method as documented on api.Module.
 func (m *CallContext) ExportedGlobal(name string) api.Global {
 	exp, err := m.module.getExport(name, ExternTypeGlobal)
@@ -14,6 +14,7 @@ import (
 	testfs "github.com/tetratelabs/wazero/internal/testing/fs"
 	"github.com/tetratelabs/wazero/internal/testing/hammer"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 )
 
 func TestCallContext_WithMemory(t *testing.T) {
@@ -376,6 +377,51 @@ func TestCallContext_CloseModuleOnCanceledOrTimeout(t *testing.T) {
 	})
 }
 
+func TestCallContext_WatchCanceledOrTimeout(t *testing.T) {
+	t.Run("closes the module by default", func(t *testing.T) {
+		cc := &CallContext{Closed: new(uint64), module: &ModuleInstance{Name: "test"}, s: newStore()}
+		ctx, cancel := context.WithCancel(context.Background())
+		interrupted, done := cc.WatchCanceledOrTimeout(ctx)
+		defer done()
+		require.Nil(t, interrupted)
+		cancel()
+
+		time.Sleep(100 * time.Millisecond)
+		require.EqualError(t, cc.FailIfClosed(), "module \"test\" closed with context canceled")
+	})
+
+	t.Run("interrupts the call", func(t *testing.T) {
+		s := newStore()
+		s.InterruptOnContextDone = true
+		cc := &CallContext{Closed: new(uint64), module: &ModuleInstance{Name: "test"}, s: s}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		interrupted, done := cc.WatchCanceledOrTimeout(ctx)
+		defer done()
+		require.NoError(t, FailIfInterrupted(interrupted))
+
+		time.Sleep(200 * time.Millisecond)
+		require.Equal(t, wasmruntime.ErrRuntimeInterrupted, FailIfInterrupted(interrupted))
+		require.NoError(t, cc.FailIfClosed())
+	})
+
+	t.Run("done", func(t *testing.T) {
+		s := newStore()
+		s.InterruptOnContextDone = true
+		cc := &CallContext{Closed: new(uint64), module: &ModuleInstance{Name: "test"}, s: s}
+		ctx, cancel := context.WithCancel(context.Background())
+		interrupted, done := cc.WatchCanceledOrTimeout(ctx)
+		done()
+
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, FailIfInterrupted(interrupted))
+	})
+
+	require.NoError(t, FailIfInterrupted(nil))
+}
+
 type mockCloser struct{ called int }
 
 func (m *mockCloser) Close(context.Context) error {
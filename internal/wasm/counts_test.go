@@ -112,7 +112,7 @@ func TestModule_ImportMemoryCount(t *testing.T) {
 		},
 		{
 			name:  "none with memory section",
-			input: &Module{MemorySection: &Memory{Min: 1}},
+			input: &Module{MemorySection: []*Memory{{Min: 1}}},
 		},
 		{
 			name:     "one",
@@ -123,7 +123,7 @@ func TestModule_ImportMemoryCount(t *testing.T) {
 			name: "one with memory section",
 			input: &Module{
 				ImportSection: []*Import{{Type: ExternTypeMemory}},
-				MemorySection: &Memory{Min: 1},
+				MemorySection: []*Memory{{Min: 1}},
 			},
 			expected: 1,
 		},
@@ -266,7 +266,7 @@ func TestModule_SectionElementCount(t *testing.T) {
 		{
 			name: "MemorySection and DataSection",
 			input: &Module{
-				MemorySection: &Memory{Min: 1},
+				MemorySection: []*Memory{{Min: 1}},
 				DataSection:   []*DataSegment{{OffsetExpression: empty}},
 			},
 			expected: map[string]uint32{"data": 1, "memory": 1},
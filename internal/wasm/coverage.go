@@ -0,0 +1,10 @@
+package wasm
+
+// CoverageBlock is a basic block of a function compiled with coverage, which counts the times it is executed.
+type CoverageBlock struct {
+	// Start is the offset of the first instruction of the block in the code section of the original Wasm binary, and
+	// End is the one of the instruction after the last.
+	Start, End uint64
+	// Count is the number of times the block was executed, which is always zero in the compilation results.
+	Count uint64
+}
@@ -13,7 +13,17 @@ type Engine interface {
 	Close() (err error)
 
 	// CompileModule implements the same method as documented on wasm.Engine.
-	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination bool) error
+	//
+	// When fuelMetering is true, the functions consume the fuel of the call for their instructions, as configured by
+	// FuelKey, and fail with wasmruntime.ErrRuntimeFuelExhausted when it runs out.
+	//
+	// When coverage is true, the functions count the times each of their basic blocks is executed. See Coverage.
+	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, coverage bool) error
+
+	// Coverage returns the basic blocks of the functions defined by the given module, in the order of their index, with
+	// the times they were executed by all the module instances. This returns nil if the module isn't compiled with
+	// coverage, or is deleted.
+	Coverage(module *Module) [][]CoverageBlock
 
 	// CompiledModuleCount is exported for testing, to track the size of the compilation cache.
 	CompiledModuleCount() uint32
@@ -52,6 +62,9 @@ type ModuleEngine interface {
 	// FunctionInstanceReference returns Reference for the given Index for a FunctionInstance. The returned values are used by
 	// the initialization via ElementSegment.
 	FunctionInstanceReference(funcIndex Index) Reference
+
+	// Coverage is like Engine.Coverage, for the module from which this was instantiated.
+	Coverage() [][]CoverageBlock
 }
 
 // CallEngine implements function calls for a FunctionInstance. It manages its own call frame stack and value stack,
@@ -59,4 +72,13 @@ type ModuleEngine interface {
 type CallEngine interface {
 	// Call invokes a function instance f with given parameters.
 	Call(ctx context.Context, m *CallContext, params []uint64) (results []uint64, err error)
+
+	// CallWithStack is like Call, except the params are read from the stack and the results are written back to it,
+	// beginning at index zero. The length of stack must be at least the max of the parameter and result counts.
+	CallWithStack(ctx context.Context, m *CallContext, stack []uint64) error
+
+	// GCRoots calls fn with the values on the stack of the call in progress and the payloads of its exceptions, which
+	// GCHeap considers as roots when they are references. This is only called while the call is executing a GC
+	// instruction, or by GCHeap.PauseCall.
+	GCRoots(fn func(v uint64))
 }
@@ -0,0 +1,23 @@
+package wasm
+
+import (
+	"context"
+	"math"
+)
+
+// FuelKey is a context.Context Value key. Its associated value should be a *int64 holding the remaining fuel of the
+// calls made with the context, which is consumed by the functions compiled with fuel metering.
+//
+// The fuel becomes negative when it is exhausted, and is shared by all the calls made with the context, including the
+// nested ones from host functions.
+type FuelKey struct{}
+
+// GetFuel returns the remaining fuel of the calls made with ctx. If ctx has none, this resets unlimited to
+// math.MaxInt64 and returns it instead.
+func GetFuel(ctx context.Context, unlimited *int64) *int64 {
+	if fuel, ok := ctx.Value(FuelKey{}).(*int64); ok {
+		return fuel
+	}
+	*unlimited = math.MaxInt64
+	return unlimited
+}
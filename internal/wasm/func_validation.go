@@ -20,19 +20,27 @@ const maximumValuesOnStack = 1 << 27
 // * idx is the index in the FunctionSection
 // * functions are the function index, which is prefixed by imports. The value is the TypeSection index.
 // * globals are the global index, which is prefixed by imports.
-// * memory is the potentially imported memory and can be nil.
+// * memories are the memory index, which is prefixed by imports.
 // * table is the potentially imported table and can be nil.
 // * declaredFunctionIndexes is the set of function indexes declared by declarative element segments which can be acceed by OpcodeRefFunc instruction.
 //
 // Returns an error if the instruction sequence is not valid,
 // or potentially it can exceed the maximum number of values on the stack.
 func (m *Module) validateFunction(enabledFeatures api.CoreFeatures, idx Index, functions []Index,
-	globals []*GlobalType, memory *Memory, tables []*Table, declaredFunctionIndexes map[Index]struct{},
+	globals []*GlobalType, memories []*Memory, tables []*Table, declaredFunctionIndexes map[Index]struct{},
 ) error {
-	return m.validateFunctionWithMaxStackValues(enabledFeatures, idx, functions, globals, memory, tables, maximumValuesOnStack, declaredFunctionIndexes)
+	return m.validateFunctionWithMaxStackValues(enabledFeatures, idx, functions, globals, memories, tables, maximumValuesOnStack, declaredFunctionIndexes)
 }
 
-func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err error) {
+// memArgMemoryIndexFlag is the bit of the memarg alignment which indicates that the memory index follows the
+// alignment as per the multi-memory proposal.
+//
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md#binary-format
+const memArgMemoryIndexFlag = 1 << 6
+
+// readMemArg reads the memarg immediate of memory instructions at body[pc:]. When api.CoreFeatureMultiMemory is
+// enabled, the memory index follows the alignment if flagged by memArgMemoryIndexFlag. Otherwise, memoryIndex is zero.
+func readMemArg(pc uint64, body []byte, enabledFeatures api.CoreFeatures, memories []*Memory) (align, memoryIndex uint32, offset, read uint64, err error) {
 	align, num, err := leb128.LoadUint32(body[pc:])
 	if err != nil {
 		err = fmt.Errorf("read memory align: %v", err)
@@ -40,14 +48,62 @@ func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err
 	}
 	read += num
 
-	offset, num, err = leb128.LoadUint32(body[pc+num:])
+	if align&memArgMemoryIndexFlag != 0 {
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiMemory); err != nil {
+			err = fmt.Errorf("memory index invalid as %w", err)
+			return
+		}
+		align &^= memArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(body[pc+read:])
+		if err != nil {
+			err = fmt.Errorf("read memory index: %v", err)
+			return
+		}
+		read += num
+	}
+
+	// The offset is u64 for a 64-bit memory (api.CoreFeatureMemory64).
+	if memoryIndexType(memories, memoryIndex) == ValueTypeI64 {
+		offset, num, err = leb128.LoadUint64(body[pc+read:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(body[pc+read:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		err = fmt.Errorf("read memory offset: %v", err)
 		return
 	}
 
 	read += num
-	return align, offset, read, nil
+	return align, memoryIndex, offset, read, nil
+}
+
+// memoryIndexType returns the type of addresses and page counts of the memory at memoryIndex, which is i64 for a 64-bit
+// memory (api.CoreFeatureMemory64), or i32 otherwise.
+func memoryIndexType(memories []*Memory, memoryIndex uint32) ValueType {
+	if int(memoryIndex) < len(memories) && memories[memoryIndex].Is64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
+}
+
+// readMemoryIndex reads the memory index immediate of memory.size, memory.grow and bulk memory instructions at
+// body[pc:]. This is a reserved zero byte unless api.CoreFeatureMultiMemory is enabled.
+func readMemoryIndex(pc uint64, body []byte, enabledFeatures api.CoreFeatures, memories []*Memory, instName string) (index uint32, read uint64, err error) {
+	index, read, err = leb128.LoadUint32(body[pc:])
+	if err != nil {
+		err = fmt.Errorf("failed to read memory index for %s: %v", instName, err)
+		return
+	}
+	if enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+		if index >= uint32(len(memories)) {
+			err = fmt.Errorf("unknown memory %d for %s", index, instName)
+		}
+	} else if index != 0 || read != 1 {
+		err = fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", instName)
+	}
+	return
 }
 
 // validateFunctionWithMaxStackValues is like validateFunction, but allows overriding maxStackValues for testing.
@@ -58,7 +114,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	idx Index,
 	functions []Index,
 	globals []*GlobalType,
-	memory *Memory,
+	memories []*Memory,
 	tables []*Table,
 	maxStackValues int,
 	declaredFunctionIndexes map[Index]struct{},
@@ -69,10 +125,18 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	localTypes := code.LocalTypes
 	types := m.TypeSection
 
+	// tags are the type index of each tag, which are only used when api.CoreFeatureExceptionHandling is enabled.
+	var tags []Index
+	if enabledFeatures.IsEnabled(api.CoreFeatureExceptionHandling) {
+		tags = m.AllTags()
+	}
+
 	// We start with the outermost control block which is for function return if the code branches into it.
 	controlBlockStack := []*controlBlock{{blockType: functionType}}
 	// Create the valueTypeStack to track the state of Wasm value stacks at anypoint of execution.
-	valueTypeStack := &valueTypeStack{}
+	valueTypeStack := &valueTypeStack{types: types}
+	// localInits tracks the initialization of the locals of non-nullable reference types.
+	localInits := newLocalInitTracker(code.LocalRefs)
 
 	// Now start walking through all the instructions in the body while tracking
 	// control blocks and value types to check the validity of all instructions.
@@ -91,21 +155,25 @@ func (m *Module) validateFunctionWithMaxStackValues(
 		}
 
 		if OpcodeI32Load <= op && op <= OpcodeI64Store32 {
-			if memory == nil && !code.IsHostFunction {
+			if len(memories) == 0 && !code.IsHostFunction {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
-			align, _, read, err := readMemArg(pc, body)
+			align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 			if err != nil {
 				return err
 			}
+			if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+				return fmt.Errorf("unknown memory %d for %s", memoryIndex, InstructionName(op))
+			}
+			addressType := memoryIndexType(memories, memoryIndex)
 			pc += read - 1
 			switch op {
 			case OpcodeI32Load:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -113,7 +181,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF32)
@@ -124,7 +192,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF32Store:
@@ -134,14 +202,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load:
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -149,7 +217,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF64)
@@ -160,7 +228,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF64Store:
@@ -170,14 +238,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load8S:
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -185,7 +253,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -193,7 +261,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -204,7 +272,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store8:
@@ -214,14 +282,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load16S, OpcodeI32Load16U:
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -229,7 +297,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -240,7 +308,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store16:
@@ -250,14 +318,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load32S, OpcodeI64Load32U:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -268,12 +336,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			}
 		} else if OpcodeMemorySize <= op && op <= OpcodeMemoryGrow {
-			if memory == nil && !code.IsHostFunction {
+			if len(memories) == 0 && !code.IsHostFunction {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
@@ -281,17 +349,23 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			if err != nil {
 				return fmt.Errorf("read immediate: %v", err)
 			}
-			if val != 0 || num != 1 {
+			if enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+				if int(val) >= len(memories) && !code.IsHostFunction {
+					return fmt.Errorf("unknown memory %d for %s", val, InstructionName(op))
+				}
+			} else if val != 0 || num != 1 {
 				return fmt.Errorf("memory instruction reserved bytes not zero with 1 byte")
 			}
+			// The page count is i64 for a 64-bit memory.
+			indexType := memoryIndexType(memories, val)
 			switch Opcode(op) {
 			case OpcodeMemoryGrow:
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(indexType); err != nil {
 					return err
 				}
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(indexType)
 			case OpcodeMemorySize:
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(indexType)
 			}
 			pc += num - 1
 		} else if OpcodeI32Const <= op && op <= OpcodeF64Const {
@@ -333,9 +407,13 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						OpcodeLocalGetName, index, l)
 				}
 				if index < inputLen {
-					valueTypeStack.push(functionType.Params[index])
+					valueTypeStack.pushRef(functionType.Params[index], typedRefAt(functionType.ParamRefs, int(index)))
 				} else {
-					valueTypeStack.push(localTypes[index-inputLen])
+					ref := typedRefAt(code.LocalRefs, int(index-inputLen))
+					if ref != nil && !ref.Nullable && !localInits.initialized[index-inputLen] {
+						return fmt.Errorf("uninitialized local %d of non-nullable type %s for %s", index, ref, OpcodeLocalGetName)
+					}
+					valueTypeStack.pushRef(localTypes[index-inputLen], ref)
 				}
 			case OpcodeLocalSet:
 				inputLen := uint32(len(functionType.Params))
@@ -344,12 +422,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						OpcodeLocalSetName, index, l)
 				}
 				var expType ValueType
+				var expRef *TypedRef
 				if index < inputLen {
-					expType = functionType.Params[index]
+					expType, expRef = functionType.Params[index], typedRefAt(functionType.ParamRefs, int(index))
 				} else {
-					expType = localTypes[index-inputLen]
+					expType, expRef = localTypes[index-inputLen], typedRefAt(code.LocalRefs, int(index-inputLen))
+					localInits.init(int(index - inputLen))
 				}
-				if err := valueTypeStack.popAndVerifyType(expType); err != nil {
+				if err := valueTypeStack.popAndVerifyTypedRef(expType, expRef); err != nil {
 					return err
 				}
 			case OpcodeLocalTee:
@@ -359,27 +439,29 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						OpcodeLocalTeeName, index, l)
 				}
 				var expType ValueType
+				var expRef *TypedRef
 				if index < inputLen {
-					expType = functionType.Params[index]
+					expType, expRef = functionType.Params[index], typedRefAt(functionType.ParamRefs, int(index))
 				} else {
-					expType = localTypes[index-inputLen]
+					expType, expRef = localTypes[index-inputLen], typedRefAt(code.LocalRefs, int(index-inputLen))
+					localInits.init(int(index - inputLen))
 				}
-				if err := valueTypeStack.popAndVerifyType(expType); err != nil {
+				if err := valueTypeStack.popAndVerifyTypedRef(expType, expRef); err != nil {
 					return err
 				}
-				valueTypeStack.push(expType)
+				valueTypeStack.pushRef(expType, expRef)
 			case OpcodeGlobalGet:
 				if index >= uint32(len(globals)) {
 					return fmt.Errorf("invalid index for %s", OpcodeGlobalGetName)
 				}
-				valueTypeStack.push(globals[index].ValType)
+				valueTypeStack.pushRef(globals[index].ValType, globals[index].Ref)
 			case OpcodeGlobalSet:
 				if index >= uint32(len(globals)) {
 					return fmt.Errorf("invalid global index")
 				} else if !globals[index].Mutable {
 					return fmt.Errorf("%s when not mutable", OpcodeGlobalSetName)
-				} else if err := valueTypeStack.popAndVerifyType(
-					globals[index].ValType); err != nil {
+				} else if err := valueTypeStack.popAndVerifyTypedRef(
+					globals[index].ValType, globals[index].Ref); err != nil {
 					return err
 				}
 			}
@@ -394,13 +476,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			pc += num - 1
 			// Check type soundness.
 			target := controlBlockStack[len(controlBlockStack)-int(index)-1]
-			var targetResultType []ValueType
-			if target.op == OpcodeLoop {
-				targetResultType = target.blockType.Params
-			} else {
-				targetResultType = target.blockType.Results
-			}
-			if err = valueTypeStack.popResults(op, targetResultType, false); err != nil {
+			targetResultType, targetResultRefs := target.labelTypes()
+			if err = valueTypeStack.requireTypedStackValues(false, OpcodeBrName, targetResultType, targetResultRefs, false); err != nil {
 				return err
 			}
 			// br instruction is stack-polymorphic.
@@ -421,19 +498,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 			// Check type soundness.
 			target := controlBlockStack[len(controlBlockStack)-int(index)-1]
-			var targetResultType []ValueType
-			if target.op == OpcodeLoop {
-				targetResultType = target.blockType.Params
-			} else {
-				targetResultType = target.blockType.Results
-			}
-			if err := valueTypeStack.popResults(op, targetResultType, false); err != nil {
+			targetResultType, targetResultRefs := target.labelTypes()
+			if err := valueTypeStack.requireTypedStackValues(false, OpcodeBrIfName, targetResultType, targetResultRefs, false); err != nil {
 				return err
 			}
 			// Push back the result
-			for _, t := range targetResultType {
-				valueTypeStack.push(t)
-			}
+			valueTypeStack.pushAll(targetResultType, targetResultRefs)
 		} else if op == OpcodeBrTable {
 			pc++
 			r := bytes.NewReader(body[pc:])
@@ -536,14 +606,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("invalid function index")
 			}
 			funcType := types[functions[index]]
-			for i := 0; i < len(funcType.Params); i++ {
-				if err := valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
+			for i := len(funcType.Params) - 1; i >= 0; i-- {
+				if err := valueTypeStack.popAndVerifyTypedRef(funcType.Params[i], typedRefAt(funcType.ParamRefs, i)); err != nil {
 					return fmt.Errorf("type mismatch on %s operation param type: %v", OpcodeCallName, err)
 				}
 			}
-			for _, exp := range funcType.Results {
-				valueTypeStack.push(exp)
-			}
+			valueTypeStack.pushAll(funcType.Results, funcType.ResultRefs)
 		} else if op == OpcodeCallIndirect {
 			pc++
 			typeIndex, num, err := leb128.LoadUint32(body[pc:])
@@ -554,6 +622,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 
 			if int(typeIndex) >= len(types) {
 				return fmt.Errorf("invalid type index at %s: %d", OpcodeCallIndirectName, typeIndex)
+			} else if types[typeIndex].Composite != nil {
+				return fmt.Errorf("type %d is not a function type at %s", typeIndex, OpcodeCallIndirectName)
 			}
 
 			tableIndex, num, err := leb128.LoadUint32(body[pc:])
@@ -582,13 +652,131 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("cannot pop the offset in table for %s", OpcodeCallIndirectName)
 			}
 			funcType := types[typeIndex]
-			for i := 0; i < len(funcType.Params); i++ {
-				if err = valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
+			for i := len(funcType.Params) - 1; i >= 0; i-- {
+				if err = valueTypeStack.popAndVerifyTypedRef(funcType.Params[i], typedRefAt(funcType.ParamRefs, i)); err != nil {
 					return fmt.Errorf("type mismatch on %s operation input type", OpcodeCallIndirectName)
 				}
 			}
-			for _, exp := range funcType.Results {
-				valueTypeStack.push(exp)
+			valueTypeStack.pushAll(funcType.Results, funcType.ResultRefs)
+		} else if op == OpcodeReturnCall {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureTailCall); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeReturnCallName, err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			if int(index) >= len(functions) {
+				return fmt.Errorf("invalid function index")
+			}
+			funcType := types[functions[index]]
+			if !bytes.Equal(funcType.Results, functionType.Results) {
+				return fmt.Errorf("type mismatch on %s operation result type: %s != %s",
+					OpcodeReturnCallName, funcType.String(), functionType.String())
+			}
+			for i := len(funcType.Params) - 1; i >= 0; i-- {
+				if err := valueTypeStack.popAndVerifyTypedRef(funcType.Params[i], typedRefAt(funcType.ParamRefs, i)); err != nil {
+					return fmt.Errorf("type mismatch on %s operation param type: %v", OpcodeReturnCallName, err)
+				}
+			}
+			// return_call instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeReturnCallIndirect {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureTailCall); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeReturnCallIndirectName, err)
+			}
+			pc++
+			typeIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num
+
+			if int(typeIndex) >= len(types) {
+				return fmt.Errorf("invalid type index at %s: %d", OpcodeReturnCallIndirectName, typeIndex)
+			} else if types[typeIndex].Composite != nil {
+				return fmt.Errorf("type %d is not a function type at %s", typeIndex, OpcodeReturnCallIndirectName)
+			}
+
+			tableIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read table index: %v", err)
+			}
+			pc += num - 1
+			if tableIndex != 0 {
+				if err := enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes); err != nil {
+					return fmt.Errorf("table index must be zero but was %d: %w", tableIndex, err)
+				}
+			}
+
+			if tableIndex >= uint32(len(tables)) {
+				return fmt.Errorf("unknown table index: %d", tableIndex)
+			}
+
+			table := tables[tableIndex]
+			if table == nil {
+				return fmt.Errorf("table not given while having %s", OpcodeReturnCallIndirectName)
+			} else if table.Type != RefTypeFuncref {
+				return fmt.Errorf("table is not funcref type but was %s for %s", RefTypeName(table.Type), OpcodeReturnCallIndirectName)
+			}
+
+			funcType := types[typeIndex]
+			if !bytes.Equal(funcType.Results, functionType.Results) {
+				return fmt.Errorf("type mismatch on %s operation result type: %s != %s",
+					OpcodeReturnCallIndirectName, funcType.String(), functionType.String())
+			}
+			if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				return fmt.Errorf("cannot pop the offset in table for %s", OpcodeReturnCallIndirectName)
+			}
+			for i := len(funcType.Params) - 1; i >= 0; i-- {
+				if err = valueTypeStack.popAndVerifyTypedRef(funcType.Params[i], typedRefAt(funcType.ParamRefs, i)); err != nil {
+					return fmt.Errorf("type mismatch on %s operation input type", OpcodeReturnCallIndirectName)
+				}
+			}
+			// return_call_indirect instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeCallRef || op == OpcodeReturnCallRef {
+			instName := InstructionName(op)
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureFunctionReferences); err != nil {
+				return fmt.Errorf("%s invalid as %v", instName, err)
+			}
+			if op == OpcodeReturnCallRef {
+				if err := enabledFeatures.RequireEnabled(api.CoreFeatureTailCall); err != nil {
+					return fmt.Errorf("%s invalid as %v", instName, err)
+				}
+			}
+			pc++
+			typeIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			if int(typeIndex) >= len(types) {
+				return fmt.Errorf("invalid type index at %s: %d", instName, typeIndex)
+			} else if types[typeIndex].Composite != nil {
+				return fmt.Errorf("type %d is not a function type at %s", typeIndex, instName)
+			}
+
+			funcType := types[typeIndex]
+			if op == OpcodeReturnCallRef && !bytes.Equal(funcType.Results, functionType.Results) {
+				return fmt.Errorf("type mismatch on %s operation result type: %s != %s",
+					instName, funcType.String(), functionType.String())
+			}
+			if err = valueTypeStack.popAndVerifyTypedRef(ValueTypeFuncref, NewTypedRef(true, HeapType(typeIndex))); err != nil {
+				return fmt.Errorf("cannot pop the function reference for %s: %v", instName, err)
+			}
+			for i := len(funcType.Params) - 1; i >= 0; i-- {
+				if err = valueTypeStack.popAndVerifyTypedRef(funcType.Params[i], typedRefAt(funcType.ParamRefs, i)); err != nil {
+					return fmt.Errorf("type mismatch on %s operation input type", instName)
+				}
+			}
+			if op == OpcodeReturnCallRef {
+				// return_call_ref instruction is stack-polymorphic.
+				valueTypeStack.unreachable()
+			} else {
+				valueTypeStack.pushAll(funcType.Results, funcType.ResultRefs)
 			}
 		} else if OpcodeI32Eqz <= op && op <= OpcodeI64Extend32S {
 			switch op {
@@ -810,6 +998,20 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			switch op {
 			case OpcodeRefNull:
 				pc++
+				if enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) {
+					// The heap type can be a type index, encoded as a signed 33-bit integer.
+					heapType, num, err := leb128.DecodeInt33AsInt64(bytes.NewReader(body[pc:]))
+					if err != nil {
+						return fmt.Errorf("read heap type for ref.null: %v", err)
+					}
+					pc += num - 1
+					ht := HeapType(heapType)
+					if err = ValidateHeapType(types, ht, enabledFeatures); err != nil {
+						return fmt.Errorf("unknown type for ref.null: %d", heapType)
+					}
+					valueTypeStack.pushRef(refValueType(types, ht), NewTypedRef(true, ht))
+					break
+				}
 				switch reftype := body[pc]; reftype {
 				case ValueTypeExternref:
 					valueTypeStack.push(ValueTypeExternref)
@@ -836,7 +1038,66 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					return fmt.Errorf("undeclared function index %d for ref.func", index)
 				}
 				pc += num - 1
-				valueTypeStack.push(ValueTypeFuncref)
+				if enabledFeatures.IsEnabled(api.CoreFeatureFunctionReferences) && int(index) < len(functions) {
+					// The reference is typed with the function type.
+					valueTypeStack.pushRef(ValueTypeFuncref, NewTypedRef(false, HeapType(functions[index])))
+				} else {
+					valueTypeStack.push(ValueTypeFuncref)
+				}
+			}
+		} else if op == OpcodeRefAsNonNull || op == OpcodeBrOnNull || op == OpcodeBrOnNonNull {
+			instName := InstructionName(op)
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureFunctionReferences); err != nil {
+				return fmt.Errorf("%s invalid as %v", instName, err)
+			}
+			vt, ref, err := valueTypeStack.popRef()
+			if err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", instName, err)
+			} else if !isReferenceValueType(vt) && vt != valueTypeUnknown {
+				return fmt.Errorf("type mismatch: expected reference type but was %s", ValueTypeName(vt))
+			}
+			var nonNullRef *TypedRef
+			if vt != valueTypeUnknown {
+				nonNullRef = ref.AsNonNullable(vt)
+			}
+			if op == OpcodeRefAsNonNull {
+				valueTypeStack.pushRef(vt, nonNullRef)
+				continue
+			}
+
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			} else if int(index) >= len(controlBlockStack) {
+				return fmt.Errorf("invalid %s operation: index out of range", instName)
+			}
+			pc += num - 1
+			target := controlBlockStack[len(controlBlockStack)-int(index)-1]
+			targetResultType, targetResultRefs := target.labelTypes()
+			if op == OpcodeBrOnNonNull {
+				// The non-null reference is the last value to branch with.
+				last := len(targetResultType) - 1
+				if last < 0 {
+					return fmt.Errorf("type mismatch on %s operation: label %d has no reference type", instName, index)
+				}
+				lastType, lastRef := targetResultType[last], typedRefAt(targetResultRefs, last)
+				if vt != valueTypeUnknown && (vt != lastType || !isTypedRefSubtype(types, nonNullRef, lastRef)) {
+					return fmt.Errorf("type mismatch on %s operation: cannot use %s as %s",
+						instName, typedRefName(vt, nonNullRef), typedRefName(lastType, lastRef))
+				}
+				targetResultType = targetResultType[:last]
+				if targetResultRefs != nil {
+					targetResultRefs = targetResultRefs[:last]
+				}
+			}
+			if err = valueTypeStack.requireTypedStackValues(false, instName, targetResultType, targetResultRefs, false); err != nil {
+				return err
+			}
+			// Push back the values, and the non-null reference if not branching with it.
+			valueTypeStack.pushAll(targetResultType, targetResultRefs)
+			if op == OpcodeBrOnNull {
+				valueTypeStack.pushRef(vt, nonNullRef)
 			}
 		} else if op == OpcodeTableGet || op == OpcodeTableSet {
 			if err := enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes); err != nil {
@@ -923,11 +1184,9 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 					pc += num - 1
 				case OpcodeMiscMemoryInit, OpcodeMiscMemoryCopy, OpcodeMiscMemoryFill:
-					if memory == nil {
+					if len(memories) == 0 {
 						return fmt.Errorf("memory must exist for %s", MiscInstructionName(miscOpcode))
 					}
-					params = []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32}
-
 					if miscOpcode == OpcodeMiscMemoryInit {
 						if m.DataCountSection == nil {
 							return fmt.Errorf("%s requires data count section", MiscInstructionName(miscOpcode))
@@ -946,24 +1205,33 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 
 					pc++
-					val, num, err := leb128.LoadUint32(body[pc:])
+					index, num, err := readMemoryIndex(pc, body, enabledFeatures, memories, MiscInstructionName(miscOpcode))
 					if err != nil {
-						return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
-					}
-					if val != 0 || num != 1 {
-						return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
+						return err
 					}
-					if miscOpcode == OpcodeMiscMemoryCopy {
-						pc++
-						// memory.copy needs two memory index which are reserved as zero.
-						val, num, err := leb128.LoadUint32(body[pc:])
-						if err != nil {
-							return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
+					// Addresses and sizes are i64 for a 64-bit memory, except the ones into the data segment.
+					indexType := memoryIndexType(memories, index)
+					switch miscOpcode {
+					case OpcodeMiscMemoryInit:
+						params = []ValueType{indexType, ValueTypeI32, ValueTypeI32}
+					case OpcodeMiscMemoryCopy:
+						pc += num
+						// memory.copy needs two memory index: the destination followed by the source.
+						var srcIndex uint32
+						if srcIndex, num, err = readMemoryIndex(pc, body, enabledFeatures, memories, MiscInstructionName(miscOpcode)); err != nil {
+							return err
 						}
-						if val != 0 || num != 1 {
-							return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
+						srcIndexType := memoryIndexType(memories, srcIndex)
+						// The size is i64 only when both memories are 64-bit.
+						sizeType := ValueTypeI32
+						if indexType == ValueTypeI64 && srcIndexType == ValueTypeI64 {
+							sizeType = ValueTypeI64
 						}
+						params = []ValueType{indexType, srcIndexType, sizeType}
+					case OpcodeMiscMemoryFill:
+						params = []ValueType{indexType, ValueTypeI32, indexType}
 					}
+					pc += num - 1
 
 				case OpcodeMiscTableInit:
 					params = []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32}
@@ -1044,8 +1312,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 
 					pc += num - 1
 				}
-				for _, p := range params {
-					if err := valueTypeStack.popAndVerifyType(p); err != nil {
+				for i := len(params) - 1; i >= 0; i-- {
+					if err := valueTypeStack.popAndVerifyType(params[i]); err != nil {
 						return fmt.Errorf("cannot pop the operand for %s: %v", miscInstructionNames[miscOpcode], err)
 					}
 				}
@@ -1084,6 +1352,35 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					valueTypeStack.push(r)
 				}
 			}
+		} else if relaxedOpcode, relaxed := RelaxedVecOpcode(body[pc+1:]); op == OpcodeVecPrefix && relaxed {
+			pc += 2 // The opcode is encoded with two bytes.
+			name := relaxedVecInstructionNames[relaxedOpcode]
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureRelaxedSIMD); err != nil {
+				return fmt.Errorf("%s invalid as %v", name, err)
+			}
+
+			var operands int
+			switch relaxedOpcode {
+			case OpcodeVecI32x4RelaxedTruncF32x4S, OpcodeVecI32x4RelaxedTruncF32x4U,
+				OpcodeVecI32x4RelaxedTruncF64x2SZero, OpcodeVecI32x4RelaxedTruncF64x2UZero:
+				operands = 1
+			case OpcodeVecI8x16RelaxedSwizzle, OpcodeVecF32x4RelaxedMin, OpcodeVecF32x4RelaxedMax,
+				OpcodeVecF64x2RelaxedMin, OpcodeVecF64x2RelaxedMax, OpcodeVecI16x8RelaxedQ15mulrS,
+				OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+				operands = 2
+			case OpcodeVecF32x4RelaxedMadd, OpcodeVecF32x4RelaxedNmadd, OpcodeVecF64x2RelaxedMadd, OpcodeVecF64x2RelaxedNmadd,
+				OpcodeVecI8x16RelaxedLaneselect, OpcodeVecI16x8RelaxedLaneselect, OpcodeVecI32x4RelaxedLaneselect,
+				OpcodeVecI64x2RelaxedLaneselect, OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+				operands = 3
+			default:
+				return fmt.Errorf("invalid relaxed vector opcode: %#x", 0x100+uint32(relaxedOpcode))
+			}
+			for i := 0; i < operands; i++ {
+				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+				}
+			}
+			valueTypeStack.push(ValueTypeV128)
 		} else if op == OpcodeVecPrefix {
 			pc++
 			// Vector instructions come with two bytes where the first byte is always OpcodeVecPrefix,
@@ -1111,14 +1408,18 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				OpcodeVecV128Load32x2s, OpcodeVecV128Load32x2u, OpcodeVecV128Load8Splat, OpcodeVecV128Load16Splat,
 				OpcodeVecV128Load32Splat, OpcodeVecV128Load64Splat,
 				OpcodeVecV128Load32zero, OpcodeVecV128Load64zero:
-				if memory == nil && !code.IsHostFunction {
+				if len(memories) == 0 && !code.IsHostFunction {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
+				if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+					return fmt.Errorf("unknown memory %d for %s", memoryIndex, VectorInstructionName(vecOpcode))
+				}
+				addressType := memoryIndexType(memories, memoryIndex)
 				pc += read - 1
 				var maxAlign uint32
 				switch vecOpcode {
@@ -1144,19 +1445,23 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > maxAlign {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, VectorInstructionName(vecOpcode))
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", VectorInstructionName(vecOpcode), err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store:
-				if memory == nil && !code.IsHostFunction {
+				if len(memories) == 0 && !code.IsHostFunction {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
+				if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+					return fmt.Errorf("unknown memory %d for %s", memoryIndex, VectorInstructionName(vecOpcode))
+				}
+				addressType := memoryIndexType(memories, memoryIndex)
 				pc += read - 1
 				if 1<<align > 128/8 {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, OpcodeVecV128StoreName)
@@ -1164,19 +1469,23 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
 			case OpcodeVecV128Load8Lane, OpcodeVecV128Load16Lane, OpcodeVecV128Load32Lane, OpcodeVecV128Load64Lane:
-				if memory == nil && !code.IsHostFunction {
+				if len(memories) == 0 && !code.IsHostFunction {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecLoadLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
+				if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+					return fmt.Errorf("unknown memory %d for %s", memoryIndex, VectorInstructionName(vecOpcode))
+				}
+				addressType := memoryIndexType(memories, memoryIndex)
 				if 1<<align > attr.alignMax {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, vectorInstructionName[vecOpcode])
 				}
@@ -1191,20 +1500,24 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store8Lane, OpcodeVecV128Store16Lane, OpcodeVecV128Store32Lane, OpcodeVecV128Store64Lane:
-				if memory == nil && !code.IsHostFunction {
+				if len(memories) == 0 && !code.IsHostFunction {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecStoreLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
+				if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+					return fmt.Errorf("unknown memory %d for %s", memoryIndex, VectorInstructionName(vecOpcode))
+				}
+				addressType := memoryIndexType(memories, memoryIndex)
 				if 1<<align > attr.alignMax {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, vectorInstructionName[vecOpcode])
 				}
@@ -1219,7 +1532,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 			case OpcodeVecI8x16ExtractLaneS,
@@ -1388,23 +1701,239 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			default:
 				return fmt.Errorf("TODO: SIMD instruction %s will be implemented in #506", vectorInstructionName[vecOpcode])
 			}
+		} else if op == OpcodeAtomicPrefix {
+			pc++
+			// An atomic opcode is encoded as an unsigned variable 32-bit integer.
+			atomicOp32, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("failed to read atomic opcode: %v", err)
+			}
+			pc += num - 1
+			if uint32(byte(atomicOp32)) != atomicOp32 {
+				return fmt.Errorf("invalid atomic opcode: %#x", atomicOp32)
+			}
+			atomicOpcode := byte(atomicOp32)
+			instName := AtomicInstructionName(atomicOpcode)
+			if instName == "" {
+				return fmt.Errorf("invalid atomic opcode: %#x", atomicOpcode)
+			}
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureThreads); err != nil {
+				return fmt.Errorf("%s invalid as %v", instName, err)
+			}
+
+			if atomicOpcode == OpcodeAtomicFence {
+				// atomic.fence is followed by a reserved zero byte.
+				pc++
+				if int(pc) >= len(body) || body[pc] != 0 {
+					return fmt.Errorf("invalid immediate value for %s", instName)
+				}
+				continue
+			}
+
+			if len(memories) == 0 && !code.IsHostFunction {
+				return fmt.Errorf("memory must exist for %s", instName)
+			}
+			pc++
+			align, memoryIndex, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
+			if err != nil {
+				return err
+			}
+			if int(memoryIndex) >= len(memories) && !code.IsHostFunction {
+				return fmt.Errorf("unknown memory %d for %s", memoryIndex, instName)
+			}
+			addressType := memoryIndexType(memories, memoryIndex)
+			pc += read - 1
+
+			attr := atomicInstructionAttributes(atomicOpcode)
+			// Unlike other memory instructions, atomic ones require the natural alignment.
+			if 1<<align != attr.size {
+				return fmt.Errorf("invalid memory alignment %d for %s", align, instName)
+			}
+			for i := len(attr.params) - 1; i >= 0; i-- {
+				paramType := attr.params[i]
+				if i == 0 { // The address operand.
+					paramType = addressType
+				}
+				if err := valueTypeStack.popAndVerifyType(paramType); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", instName, err)
+				}
+			}
+			if attr.result != 0 {
+				valueTypeStack.push(attr.result)
+			}
+		} else if op == OpcodeGCPrefix {
+			pc++
+			// A GC opcode is encoded as an unsigned variable 32-bit integer.
+			gcOp32, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("failed to read gc opcode: %v", err)
+			}
+			pc += num
+			gcOpcode := byte(gcOp32)
+			instName := GCInstructionName(gcOpcode)
+			if uint32(gcOpcode) != gcOp32 || instName == "" {
+				return fmt.Errorf("invalid gc opcode: %#x", gcOp32)
+			}
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureGC); err != nil {
+				return fmt.Errorf("%s invalid as %v", instName, err)
+			}
+
+			if gcOpcode >= OpcodeGCRefTest {
+				heapType, num, err := leb128.DecodeInt33AsInt64(bytes.NewReader(body[pc:]))
+				if err != nil {
+					return fmt.Errorf("read heap type for %s: %v", instName, err)
+				}
+				pc += num - 1
+				ht := HeapType(heapType)
+				if err = ValidateHeapType(types, ht, enabledFeatures); err != nil {
+					return fmt.Errorf("%v for %s", err, instName)
+				} else if refValueType(types, ht) != ValueTypeAnyref {
+					return fmt.Errorf("%s is only supported on the any hierarchy but was %s", instName, heapTypeName(ht))
+				}
+				if err = valueTypeStack.popAndVerifyType(ValueTypeAnyref); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", instName, err)
+				}
+				switch gcOpcode {
+				case OpcodeGCRefTest, OpcodeGCRefTestNull:
+					valueTypeStack.push(ValueTypeI32)
+				default:
+					valueTypeStack.pushRef(ValueTypeAnyref, NewTypedRef(gcOpcode == OpcodeGCRefCastNull, ht))
+				}
+				continue
+			} else if gcOpcode == OpcodeGCArrayLen {
+				pc--
+				if err = valueTypeStack.popAndVerifyTypedRef(ValueTypeAnyref, &TypedRef{Nullable: true, HeapType: HeapTypeArray}); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", instName, err)
+				}
+				valueTypeStack.push(ValueTypeI32)
+				continue
+			}
+
+			typeIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read type index for %s: %v", instName, err)
+			}
+			pc += num - 1
+			isArray := gcOpcode >= OpcodeGCArrayNew
+			c, err := compositeTypeOf(types, typeIndex, isArray)
+			if err != nil {
+				return fmt.Errorf("%v for %s", err, instName)
+			}
+			ref := &TypedRef{Nullable: true, HeapType: HeapType(typeIndex)}
+
+			var field *FieldType
+			switch gcOpcode {
+			case OpcodeGCStructGet, OpcodeGCStructGetS, OpcodeGCStructGetU, OpcodeGCStructSet:
+				pc++
+				fieldIndex, num, err := leb128.LoadUint32(body[pc:])
+				if err != nil {
+					return fmt.Errorf("read field index for %s: %v", instName, err)
+				}
+				pc += num - 1
+				if int(fieldIndex) >= len(c.Fields) {
+					return fmt.Errorf("unknown field index %d for %s", fieldIndex, instName)
+				}
+				field = c.Fields[fieldIndex]
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+			default:
+				field = c.Fields[0]
+			}
+
+			switch gcOpcode {
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+				for i := len(c.Fields) - 1; i >= 0; i-- {
+					f := c.Fields[i]
+					if gcOpcode == OpcodeGCStructNewDefault {
+						if !f.isDefaultable() {
+							return fmt.Errorf("field %d of type %d has no default value for %s", i, typeIndex, instName)
+						}
+					} else if err = valueTypeStack.popAndVerifyTypedRef(f.UnpackedType(), f.Ref); err != nil {
+						return fmt.Errorf("cannot pop the field %d for %s: %v", i, instName, err)
+					}
+				}
+				valueTypeStack.pushRef(ValueTypeAnyref, &TypedRef{HeapType: HeapType(typeIndex)})
+			case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+				count := uint32(1)
+				if gcOpcode == OpcodeGCArrayNewFixed {
+					pc++
+					if count, num, err = leb128.LoadUint32(body[pc:]); err != nil {
+						return fmt.Errorf("read array size for %s: %v", instName, err)
+					} else if count > maximumArrayNewFixedSize {
+						return fmt.Errorf("too many elements %d for %s", count, instName)
+					}
+					pc += num - 1
+				} else if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+					return fmt.Errorf("cannot pop the array size for %s: %v", instName, err)
+				}
+				if gcOpcode == OpcodeGCArrayNewDefault {
+					if !field.isDefaultable() {
+						return fmt.Errorf("element of type %d has no default value for %s", typeIndex, instName)
+					}
+					count = 0
+				}
+				for i := uint32(0); i < count; i++ {
+					if err = valueTypeStack.popAndVerifyTypedRef(field.UnpackedType(), field.Ref); err != nil {
+						return fmt.Errorf("cannot pop the element for %s: %v", instName, err)
+					}
+				}
+				valueTypeStack.pushRef(ValueTypeAnyref, &TypedRef{HeapType: HeapType(typeIndex)})
+			case OpcodeGCStructGet, OpcodeGCStructGetS, OpcodeGCStructGetU, OpcodeGCArrayGet, OpcodeGCArrayGetS, OpcodeGCArrayGetU:
+				signed := gcOpcode == OpcodeGCStructGetS || gcOpcode == OpcodeGCArrayGetS
+				unsigned := gcOpcode == OpcodeGCStructGetU || gcOpcode == OpcodeGCArrayGetU
+				if field.IsPacked() != (signed || unsigned) {
+					return fmt.Errorf("%s is invalid for the field type %s", instName, field)
+				}
+				if isArray {
+					if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+						return fmt.Errorf("cannot pop the index for %s: %v", instName, err)
+					}
+				}
+				if err = valueTypeStack.popAndVerifyTypedRef(ValueTypeAnyref, ref); err != nil {
+					return fmt.Errorf("cannot pop the reference for %s: %v", instName, err)
+				}
+				valueTypeStack.pushRef(field.UnpackedType(), field.Ref)
+			case OpcodeGCStructSet, OpcodeGCArraySet:
+				if !field.Mutable {
+					return fmt.Errorf("%s is invalid for the immutable field type %s", instName, field)
+				}
+				if err = valueTypeStack.popAndVerifyTypedRef(field.UnpackedType(), field.Ref); err != nil {
+					return fmt.Errorf("cannot pop the value for %s: %v", instName, err)
+				}
+				if isArray {
+					if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+						return fmt.Errorf("cannot pop the index for %s: %v", instName, err)
+					}
+				}
+				if err = valueTypeStack.popAndVerifyTypedRef(ValueTypeAnyref, ref); err != nil {
+					return fmt.Errorf("cannot pop the reference for %s: %v", instName, err)
+				}
+			}
+		} else if op == OpcodeRefEq {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureGC); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeRefEqName, err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := valueTypeStack.popAndVerifyTypedRef(ValueTypeAnyref, &TypedRef{Nullable: true, HeapType: HeapTypeEq}); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeRefEqName, err)
+				}
+			}
+			valueTypeStack.push(ValueTypeI32)
 		} else if op == OpcodeBlock {
 			bt, num, err := DecodeBlockType(types, bytes.NewReader(body[pc+1:]), enabledFeatures)
 			if err != nil {
 				return fmt.Errorf("read block: %w", err)
 			}
 			controlBlockStack = append(controlBlockStack, &controlBlock{
-				startAt:        pc,
-				blockType:      bt,
-				blockTypeBytes: num,
+				startAt:          pc,
+				blockType:        bt,
+				blockTypeBytes:   num,
+				localInitsHeight: len(localInits.set),
 			})
-			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
+			if err = valueTypeStack.popTypedParams(op, bt, false); err != nil {
 				return err
 			}
 			// Plus we have to push any block params again.
-			for _, p := range bt.Params {
-				valueTypeStack.push(p)
-			}
+			valueTypeStack.pushAll(bt.Params, bt.ParamRefs)
 			valueTypeStack.pushStackLimit(len(bt.Params))
 			pc += num
 		} else if op == OpcodeLoop {
@@ -1413,18 +1942,17 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("read block: %w", err)
 			}
 			controlBlockStack = append(controlBlockStack, &controlBlock{
-				startAt:        pc,
-				blockType:      bt,
-				blockTypeBytes: num,
-				op:             op,
+				startAt:          pc,
+				blockType:        bt,
+				blockTypeBytes:   num,
+				op:               op,
+				localInitsHeight: len(localInits.set),
 			})
-			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
+			if err = valueTypeStack.popTypedParams(op, bt, false); err != nil {
 				return err
 			}
 			// Plus we have to push any block params again.
-			for _, p := range bt.Params {
-				valueTypeStack.push(p)
-			}
+			valueTypeStack.pushAll(bt.Params, bt.ParamRefs)
 			valueTypeStack.pushStackLimit(len(bt.Params))
 			pc += num
 		} else if op == OpcodeIf {
@@ -1433,23 +1961,142 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("read block: %w", err)
 			}
 			controlBlockStack = append(controlBlockStack, &controlBlock{
-				startAt:        pc,
-				blockType:      bt,
-				blockTypeBytes: num,
-				op:             op,
+				startAt:          pc,
+				blockType:        bt,
+				blockTypeBytes:   num,
+				op:               op,
+				localInitsHeight: len(localInits.set),
 			})
 			if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 				return fmt.Errorf("cannot pop the operand for 'if': %v", err)
 			}
-			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
+			if err = valueTypeStack.popTypedParams(op, bt, false); err != nil {
 				return err
 			}
 			// Plus we have to push any block params again.
-			for _, p := range bt.Params {
-				valueTypeStack.push(p)
+			valueTypeStack.pushAll(bt.Params, bt.ParamRefs)
+			valueTypeStack.pushStackLimit(len(bt.Params))
+			pc += num
+		} else if op == OpcodeTry {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeTryName, err)
 			}
+			bt, num, err := DecodeBlockType(types, bytes.NewReader(body[pc+1:]), enabledFeatures)
+			if err != nil {
+				return fmt.Errorf("read block: %w", err)
+			}
+			controlBlockStack = append(controlBlockStack, &controlBlock{
+				startAt:          pc,
+				blockType:        bt,
+				blockTypeBytes:   num,
+				op:               op,
+				localInitsHeight: len(localInits.set),
+			})
+			if err = valueTypeStack.popTypedParams(op, bt, false); err != nil {
+				return err
+			}
+			// Plus we have to push any block params again.
+			valueTypeStack.pushAll(bt.Params, bt.ParamRefs)
 			valueTypeStack.pushStackLimit(len(bt.Params))
 			pc += num
+		} else if op == OpcodeCatch || op == OpcodeCatchAll {
+			instName := InstructionName(op)
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", instName, err)
+			}
+			bl := controlBlockStack[len(controlBlockStack)-1]
+			if bl.op != OpcodeTry && bl.op != OpcodeCatch {
+				return fmt.Errorf("%s must follow %s or %s", instName, OpcodeTryName, OpcodeCatchName)
+			}
+			var params []ValueType
+			var paramRefs []*TypedRef
+			if op == OpcodeCatch {
+				pc++
+				tagIndex, num, err := leb128.LoadUint32(body[pc:])
+				if err != nil {
+					return fmt.Errorf("read immediate: %v", err)
+				}
+				pc += num - 1
+				if tagIndex >= uint32(len(tags)) {
+					return fmt.Errorf("unknown tag %d for %s", tagIndex, instName)
+				}
+				params, paramRefs = types[tags[tagIndex]].Params, types[tags[tagIndex]].ParamRefs
+			}
+			// Check the type soundness of the instructions *before* entering this catch.
+			if err := valueTypeStack.popTypedResults(bl.op, bl.blockType, true); err != nil {
+				return err
+			}
+			// Before entering instructions inside catch, we pop all the values pushed by the previous ones, and push
+			// the payload of the caught exception.
+			valueTypeStack.resetAtStackLimit()
+			valueTypeStack.pushAll(params, paramRefs)
+			// The locals initialized by the previous instructions are not initialized in this catch.
+			localInits.rollback(bl.localInitsHeight)
+			bl.op = op
+		} else if op == OpcodeThrow {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeThrowName, err)
+			}
+			pc++
+			tagIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			if tagIndex >= uint32(len(tags)) {
+				return fmt.Errorf("unknown tag %d for %s", tagIndex, OpcodeThrowName)
+			}
+			if err = valueTypeStack.popTypedParams(op, types[tags[tagIndex]], false); err != nil {
+				return err
+			}
+			// throw instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeRethrow {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeRethrowName, err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			} else if int(index) >= len(controlBlockStack) {
+				return fmt.Errorf("invalid %s operation: index out of range", OpcodeRethrowName)
+			}
+			pc += num - 1
+			if target := controlBlockStack[len(controlBlockStack)-int(index)-1]; target.op != OpcodeCatch && target.op != OpcodeCatchAll {
+				return fmt.Errorf("invalid %s operation: label %d is not a %s or %s", OpcodeRethrowName, index, OpcodeCatchName, OpcodeCatchAllName)
+			}
+			// rethrow instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeDelegate {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeDelegateName, err)
+			}
+			bl := controlBlockStack[len(controlBlockStack)-1]
+			if bl.op != OpcodeTry {
+				return fmt.Errorf("%s must end %s without %s", OpcodeDelegateName, OpcodeTryName, OpcodeCatchName)
+			}
+			bl.endAt = pc
+			controlBlockStack = controlBlockStack[:len(controlBlockStack)-1]
+
+			pc++
+			// The label is relative to the enclosing block of the try.
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			} else if int(index) >= len(controlBlockStack) {
+				return fmt.Errorf("invalid %s operation: index out of range", OpcodeDelegateName)
+			}
+			pc += num - 1
+
+			// Same as OpcodeEnd of the try.
+			if err := valueTypeStack.requireTypedStackValues(false, OpcodeTryName, bl.blockType.Results, bl.blockType.ResultRefs, true); err != nil {
+				return err
+			}
+			valueTypeStack.resetAtStackLimit()
+			valueTypeStack.pushAll(bl.blockType.Results, bl.blockType.ResultRefs)
+			valueTypeStack.popStackLimit()
+			localInits.rollback(bl.localInitsHeight)
 		} else if op == OpcodeElse {
 			if len(controlBlockStack) == 0 {
 				return fmt.Errorf("redundant Else instruction at %#x", pc)
@@ -1457,15 +2104,15 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			bl := controlBlockStack[len(controlBlockStack)-1]
 			bl.elseAt = pc
 			// Check the type soundness of the instructions *before* entering this else Op.
-			if err := valueTypeStack.popResults(OpcodeIf, bl.blockType.Results, true); err != nil {
+			if err := valueTypeStack.popTypedResults(OpcodeIf, bl.blockType, true); err != nil {
 				return err
 			}
 			// Before entering instructions inside else, we pop all the values pushed by then block.
 			valueTypeStack.resetAtStackLimit()
 			// Plus we have to push any block params again.
-			for _, p := range bl.blockType.Params {
-				valueTypeStack.push(p)
-			}
+			valueTypeStack.pushAll(bl.blockType.Params, bl.blockType.ParamRefs)
+			// The locals initialized in the then block are not initialized in the else block.
+			localInits.rollback(bl.localInitsHeight)
 		} else if op == OpcodeEnd {
 			if len(controlBlockStack) == 0 {
 				return fmt.Errorf("redundant End instruction at %#x", pc)
@@ -1496,22 +2143,22 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 
 			// Check return types match
-			if err := valueTypeStack.requireStackValues(false, ctx, bl.blockType.Results, true); err != nil {
+			if err := valueTypeStack.requireTypedStackValues(false, ctx, bl.blockType.Results, bl.blockType.ResultRefs, true); err != nil {
 				return err
 			}
 
 			// Put the result types at the end after resetting at the stack limit
 			// since we might have Any type between the limit and the current top.
 			valueTypeStack.resetAtStackLimit()
-			for _, exp := range bl.blockType.Results {
-				valueTypeStack.push(exp)
-			}
+			valueTypeStack.pushAll(bl.blockType.Results, bl.blockType.ResultRefs)
 			// We exit if/loop/block, so reset the constraints on the stack manipulation
 			// on values previously pushed by outer blocks.
 			valueTypeStack.popStackLimit()
+			// The locals initialized in the block are no longer initialized after it.
+			localInits.rollback(bl.localInitsHeight)
 		} else if op == OpcodeReturn {
 			// Same formatting as OpcodeEnd on the outer-most block
-			if err := valueTypeStack.requireStackValues(false, "", functionType.Results, false); err != nil {
+			if err := valueTypeStack.requireTypedStackValues(false, "", functionType.Results, functionType.ResultRefs, false); err != nil {
 				return err
 			}
 			// return instruction is stack-polymorphic.
@@ -1545,7 +2192,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				pc++
 				tp := body[pc]
 				if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
-					tp != api.ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 {
+					tp != api.ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 &&
+					(tp != ValueTypeAnyref || !enabledFeatures.IsEnabled(api.CoreFeatureGC)) {
 					return fmt.Errorf("invalid type %s for %s", ValueTypeName(tp), OpcodeTypedSelectName)
 				}
 			} else if isReferenceValueType(v1) || isReferenceValueType(v2) {
@@ -1578,6 +2226,71 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	return nil
 }
 
+// atomicAttributes are the attributes of an atomic instruction, except atomic.fence, used for validation.
+type atomicAttributes struct {
+	// size is the number of bytes accessed, which is also the required alignment.
+	size uint32
+	// params are the types of operands, including the address.
+	params []ValueType
+	// result is the type of the result, or zero if there's none.
+	result ValueType
+}
+
+// atomicInstructionAttributes returns the atomicAttributes for a valid OpcodeAtomic except OpcodeAtomicFence.
+func atomicInstructionAttributes(op OpcodeAtomic) atomicAttributes {
+	switch op {
+	case OpcodeAtomicMemoryNotify:
+		return atomicAttributes{size: 4, params: []ValueType{ValueTypeI32, ValueTypeI32}, result: ValueTypeI32}
+	case OpcodeAtomicMemoryWait32:
+		return atomicAttributes{size: 4, params: []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI64}, result: ValueTypeI32}
+	case OpcodeAtomicMemoryWait64:
+		return atomicAttributes{size: 8, params: []ValueType{ValueTypeI32, ValueTypeI64, ValueTypeI64}, result: ValueTypeI32}
+	}
+
+	// The remaining instructions are laid out by their value type and size, in the same order for loads, stores and
+	// each read-modify-write operation.
+	var idx OpcodeAtomic
+	switch {
+	case op <= OpcodeAtomicI64Load32U:
+		idx = op - OpcodeAtomicI32Load
+	case op <= OpcodeAtomicI64Store32:
+		idx = op - OpcodeAtomicI32Store
+	default:
+		idx = (op - OpcodeAtomicI32RMWAdd) % (OpcodeAtomicI32RMWSub - OpcodeAtomicI32RMWAdd)
+	}
+
+	var attr atomicAttributes
+	valueType := ValueTypeI64
+	switch idx {
+	case 0: // e.g. i32.atomic.load
+		attr.size, valueType = 4, ValueTypeI32
+	case 1: // e.g. i64.atomic.load
+		attr.size = 8
+	case 2: // e.g. i32.atomic.load8_u
+		attr.size, valueType = 1, ValueTypeI32
+	case 3: // e.g. i32.atomic.load16_u
+		attr.size, valueType = 2, ValueTypeI32
+	case 4: // e.g. i64.atomic.load8_u
+		attr.size = 1
+	case 5: // e.g. i64.atomic.load16_u
+		attr.size = 2
+	case 6: // e.g. i64.atomic.load32_u
+		attr.size = 4
+	}
+
+	switch {
+	case op <= OpcodeAtomicI64Load32U:
+		attr.params, attr.result = []ValueType{ValueTypeI32}, valueType
+	case op <= OpcodeAtomicI64Store32:
+		attr.params = []ValueType{ValueTypeI32, valueType}
+	case op >= OpcodeAtomicI32RMWCmpxchg:
+		attr.params, attr.result = []ValueType{ValueTypeI32, valueType, valueType}, valueType
+	default:
+		attr.params, attr.result = []ValueType{ValueTypeI32, valueType}, valueType
+	}
+	return attr
+}
+
 var vecExtractLanes = [...]struct {
 	laneCeil   byte
 	resultType ValueType
@@ -1634,9 +2347,13 @@ var vecSplatValueTypes = [...]ValueType{
 }
 
 type valueTypeStack struct {
-	stack               []ValueType
+	stack []ValueType
+	// refs are the TypedRef of each value in stack, which is nil unless the value is a typed reference.
+	refs                []*TypedRef
 	stackLimits         []int
 	maximumStackPointer int
+	// types are used to resolve the type indexes of typed references.
+	types []*FunctionType
 }
 
 const (
@@ -1645,6 +2362,12 @@ const (
 )
 
 func (s *valueTypeStack) tryPop() (vt ValueType, limit int, ok bool) {
+	vt, _, limit, ok = s.tryPopRef()
+	return
+}
+
+// tryPopRef is like tryPop, but also returns the TypedRef of the popped value.
+func (s *valueTypeStack) tryPopRef() (vt ValueType, ref *TypedRef, limit int, ok bool) {
 	if len(s.stackLimits) > 0 {
 		limit = s.stackLimits[len(s.stackLimits)-1]
 	}
@@ -1656,8 +2379,8 @@ func (s *valueTypeStack) tryPop() (vt ValueType, limit int, ok bool) {
 		ok = true
 		return
 	} else {
-		vt = s.stack[stackLen-1]
-		s.stack = s.stack[:stackLen-1]
+		vt, ref = s.stack[stackLen-1], s.refs[stackLen-1]
+		s.stack, s.refs = s.stack[:stackLen-1], s.refs[:stackLen-1]
 		ok = true
 		return
 	}
@@ -1671,35 +2394,68 @@ func (s *valueTypeStack) pop() (ValueType, error) {
 	}
 }
 
+// popRef is like pop, but also returns the TypedRef of the popped value.
+func (s *valueTypeStack) popRef() (ValueType, *TypedRef, error) {
+	if vt, ref, limit, ok := s.tryPopRef(); ok {
+		return vt, ref, nil
+	} else {
+		return 0, nil, fmt.Errorf("invalid operation: trying to pop at %d with limit %d", len(s.stack), limit)
+	}
+}
+
 // popAndVerifyType returns an error if the stack value is unexpected.
 func (s *valueTypeStack) popAndVerifyType(expected ValueType) error {
-	have, _, ok := s.tryPop()
+	return s.popAndVerifyTypedRef(expected, nil)
+}
+
+// popAndVerifyTypedRef is like popAndVerifyType, but also returns an error if the stack value is not a subtype of the
+// typed reference expectedRef if non-nil.
+func (s *valueTypeStack) popAndVerifyTypedRef(expected ValueType, expectedRef *TypedRef) error {
+	have, haveRef, _, ok := s.tryPopRef()
 	if !ok {
-		return fmt.Errorf("%s missing", ValueTypeName(expected))
+		return fmt.Errorf("%s missing", typedRefName(expected, expectedRef))
 	}
-	if have != expected && have != valueTypeUnknown && expected != valueTypeUnknown {
-		return fmt.Errorf("type mismatch: expected %s, but was %s", ValueTypeName(expected), ValueTypeName(have))
+	if have == valueTypeUnknown || expected == valueTypeUnknown {
+		return nil
+	} else if have != expected || !isTypedRefSubtype(s.types, haveRef, expectedRef) {
+		return fmt.Errorf("type mismatch: expected %s, but was %s", typedRefName(expected, expectedRef), typedRefName(have, haveRef))
 	}
 	return nil
 }
 
 func (s *valueTypeStack) push(v ValueType) {
+	s.pushRef(v, nil)
+}
+
+// pushRef is like push, but also sets the TypedRef of the value.
+func (s *valueTypeStack) pushRef(v ValueType, ref *TypedRef) {
 	s.stack = append(s.stack, v)
+	s.refs = append(s.refs, ref)
 	if sp := len(s.stack); sp > s.maximumStackPointer {
 		s.maximumStackPointer = sp
 	}
 }
 
+// pushAll pushes the value types vts with their TypedRef in refs, which can be nil.
+func (s *valueTypeStack) pushAll(vts []ValueType, refs []*TypedRef) {
+	for i, vt := range vts {
+		s.pushRef(vt, typedRefAt(refs, i))
+	}
+}
+
 func (s *valueTypeStack) unreachable() {
 	s.resetAtStackLimit()
 	s.stack = append(s.stack, valueTypeUnknown)
+	s.refs = append(s.refs, nil)
 }
 
 func (s *valueTypeStack) resetAtStackLimit() {
 	if len(s.stackLimits) != 0 {
 		s.stack = s.stack[:s.stackLimits[len(s.stackLimits)-1]]
+		s.refs = s.refs[:s.stackLimits[len(s.stackLimits)-1]]
 	} else {
 		s.stack = []ValueType{}
+		s.refs = []*TypedRef{}
 	}
 }
 
@@ -1723,11 +2479,33 @@ func (s *valueTypeStack) popResults(oc Opcode, want []ValueType, checkAboveLimit
 	return s.requireStackValues(false, InstructionName(oc), want, checkAboveLimit)
 }
 
+// popTypedParams is like popParams, but also verifies the typed references of the params of the function type.
+func (s *valueTypeStack) popTypedParams(oc Opcode, ft *FunctionType, checkAboveLimit bool) error {
+	return s.requireTypedStackValues(true, InstructionName(oc), ft.Params, ft.ParamRefs, checkAboveLimit)
+}
+
+// popTypedResults is like popResults, but also verifies the typed references of the results of the function type.
+func (s *valueTypeStack) popTypedResults(oc Opcode, ft *FunctionType, checkAboveLimit bool) error {
+	return s.requireTypedStackValues(false, InstructionName(oc), ft.Results, ft.ResultRefs, checkAboveLimit)
+}
+
 func (s *valueTypeStack) requireStackValues(
 	isParam bool,
 	context string,
 	want []ValueType,
 	checkAboveLimit bool,
+) error {
+	return s.requireTypedStackValues(isParam, context, want, nil, checkAboveLimit)
+}
+
+// requireTypedStackValues is like requireStackValues, but also verifies the stack values are the subtypes of the
+// typed references in wantRefs, which can be nil.
+func (s *valueTypeStack) requireTypedStackValues(
+	isParam bool,
+	context string,
+	want []ValueType,
+	wantRefs []*TypedRef,
+	checkAboveLimit bool,
 ) error {
 	limit := 0
 	if len(s.stackLimits) > 0 {
@@ -1738,8 +2516,9 @@ func (s *valueTypeStack) requireStackValues(
 
 	// First, check if there are enough values on the stack.
 	have := make([]ValueType, 0, countWanted)
+	haveRefs := make([]*TypedRef, 0, countWanted)
 	for i := countWanted - 1; i >= 0; i-- {
-		popped, _, ok := s.tryPop()
+		popped, poppedRef, _, ok := s.tryPopRef()
 		if !ok {
 			if len(have) > len(want) {
 				return typeCountError(isParam, context, have, want)
@@ -1747,6 +2526,7 @@ func (s *valueTypeStack) requireStackValues(
 			return typeCountError(isParam, context, have, want)
 		}
 		have = append(have, popped)
+		haveRefs = append(haveRefs, poppedRef)
 	}
 
 	// Now, check if there are too many values.
@@ -1759,8 +2539,12 @@ func (s *valueTypeStack) requireStackValues(
 	// Finally, check the types of the values:
 	for i, v := range have {
 		nextWant := want[countWanted-i-1] // have is in reverse order (stack)
-		if v != nextWant && v != valueTypeUnknown && nextWant != valueTypeUnknown {
+		if v == valueTypeUnknown || nextWant == valueTypeUnknown {
+			continue
+		} else if v != nextWant {
 			return typeMismatchError(isParam, context, v, nextWant, i)
+		} else if wantRef := typedRefAt(wantRefs, countWanted-i-1); !isTypedRefSubtype(s.types, haveRefs[i], wantRef) {
+			return typedRefMismatchError(isParam, context, typedRefName(v, haveRefs[i]), typedRefName(nextWant, wantRef), i)
 		}
 	}
 	return nil
@@ -1768,9 +2552,14 @@ func (s *valueTypeStack) requireStackValues(
 
 // typeMismatchError returns an error similar to go compiler's error on type mismatch.
 func typeMismatchError(isParam bool, context string, have ValueType, want ValueType, i int) error {
+	return typedRefMismatchError(isParam, context, ValueTypeName(have), ValueTypeName(want), i)
+}
+
+// typedRefMismatchError is like typeMismatchError, but accepts the names of the types including typed references.
+func typedRefMismatchError(isParam bool, context string, have, want string, i int) error {
 	var ret strings.Builder
 	ret.WriteString("cannot use ")
-	ret.WriteString(ValueTypeName(have))
+	ret.WriteString(have)
 	if context != "" {
 		ret.WriteString(" in ")
 		ret.WriteString(context)
@@ -1784,7 +2573,7 @@ func typeMismatchError(isParam bool, context string, have ValueType, want ValueT
 	ret.WriteString("[")
 	ret.WriteString(strconv.Itoa(i))
 	ret.WriteString("] type ")
-	ret.WriteString(ValueTypeName(want))
+	ret.WriteString(want)
 	return errors.New(ret.String())
 }
 
@@ -1856,6 +2645,53 @@ type controlBlock struct {
 	blockTypeBytes         uint64
 	// op is zero when the outermost block
 	op Opcode
+	// localInitsHeight is the number of the locals initialized at the start of this block.
+	localInitsHeight int
+}
+
+// labelTypes returns the types of the values to branch to this block with, and their typed references.
+func (b *controlBlock) labelTypes() ([]ValueType, []*TypedRef) {
+	if b.op == OpcodeLoop {
+		return b.blockType.Params, b.blockType.ParamRefs
+	}
+	return b.blockType.Results, b.blockType.ResultRefs
+}
+
+// localInitTracker tracks the initialization of the locals of non-nullable reference types, which must be set before
+// they are read. A local initialized by local.set or local.tee is considered initialized until the end of the block.
+//
+// See https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md#local-bounds
+type localInitTracker struct {
+	// initialized is true for each local of non-nullable reference type which is initialized, excluding params.
+	initialized []bool
+	// set are the indexes in initialized in the order of initialization.
+	set []int
+}
+
+func newLocalInitTracker(localRefs []*TypedRef) (t localInitTracker) {
+	for _, r := range localRefs {
+		if r != nil && !r.Nullable {
+			t.initialized = make([]bool, len(localRefs))
+			break
+		}
+	}
+	return
+}
+
+// init marks the i-th local as initialized.
+func (t *localInitTracker) init(i int) {
+	if t.initialized != nil && !t.initialized[i] {
+		t.initialized[i] = true
+		t.set = append(t.set, i)
+	}
+}
+
+// rollback marks the locals initialized after the height as uninitialized.
+func (t *localInitTracker) rollback(height int) {
+	for _, i := range t.set[height:] {
+		t.initialized[i] = false
+	}
+	t.set = t.set[:height]
 }
 
 // DecodeBlockType decodes the type index from a positive 33-bit signed integer. Negative numbers indicate up to one
@@ -1888,12 +2724,35 @@ func DecodeBlockType(types []*FunctionType, r *bytes.Reader, enabledFeatures api
 		ret = blockType_v_funcref
 	case -17: // 0x6f in original byte = externref
 		ret = blockType_v_externref
+	case -28, -29: // 0x64 or 0x63 in original byte = (ref ht) or (ref null ht)
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureFunctionReferences); err != nil {
+			return nil, num, fmt.Errorf("block with typed reference result invalid as %v", err)
+		}
+		heapType, n, err := leb128.DecodeInt33AsInt64(r)
+		if err != nil {
+			return nil, 0, fmt.Errorf("decode heap type: %w", err)
+		}
+		num += n
+		ht := HeapType(heapType)
+		if err = ValidateHeapType(types, ht, enabledFeatures); err != nil {
+			return nil, 0, fmt.Errorf("invalid heap type: %d", heapType)
+		}
+		ref := NewTypedRef(raw == -29, ht)
+		ret = &FunctionType{Results: []ValueType{refValueType(types, ht)}, ResultRefs: []*TypedRef{ref}, ResultNumInUint64: 1}
+	case -0x12, -0x13, -0x15, -0x16, -0x0f, -0x0e, -0x0d: // anyref, eqref, structref, arrayref, nullref, etc.
+		ht := HeapType(raw)
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureGC); err != nil {
+			return nil, num, fmt.Errorf("block with %s result invalid as %v", heapTypeName(ht), err)
+		}
+		ret = &FunctionType{Results: []ValueType{refValueType(types, ht)}, ResultRefs: []*TypedRef{NewTypedRef(true, ht)}, ResultNumInUint64: 1}
 	default:
 		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiValue); err != nil {
 			return nil, num, fmt.Errorf("block with function type return invalid as %v", err)
 		}
 		if raw < 0 || (raw >= int64(len(types))) {
 			return nil, 0, fmt.Errorf("type index out of range: %d", raw)
+		} else if types[raw].Composite != nil {
+			return nil, 0, fmt.Errorf("type %d is not a function type", raw)
 		}
 		ret = types[raw]
 	}
@@ -290,7 +290,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					ElementSection:   []*ElementSegment{{}},
 					DataCountSection: &c,
 				}
-				err := m.validateFunction(api.CoreFeatureBulkMemoryOperations, 0, []Index{0}, nil, &Memory{}, []*Table{{}, {}}, nil)
+				err := m.validateFunction(api.CoreFeatureBulkMemoryOperations, 0, []Index{0}, nil, []*Memory{{}}, []*Table{{}, {}}, nil)
 				require.NoError(t, err)
 			})
 		}
@@ -301,7 +301,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			dataSection         []*DataSegment
 			elementSection      []*ElementSegment
 			dataCountSectionNil bool
-			memory              *Memory
+			memory              []*Memory
 			tables              []*Table
 			flag                api.CoreFeatures
 			expectedErr         string
@@ -327,48 +327,48 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read data segment index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "failed to read memory index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "memory.init reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
@@ -381,20 +381,20 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:                []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				dataCountSectionNil: true,
-				memory:              &Memory{},
+				memory:              []*Memory{{}},
 				flag:                api.CoreFeatureBulkMemoryOperations,
 				expectedErr:         `data.drop requires data count section`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read data segment index for data.drop: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []*DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
@@ -413,37 +413,37 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.copy: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read memory index for memory.copy: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "memory.copy reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			// memory.fill
@@ -461,31 +461,31 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.fill: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `memory.fill reserved byte must be zero encoded with 1 byte`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			// table.init
@@ -2199,7 +2199,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 				OpcodeEnd,
 			}}},
 		}
-		err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, &Memory{}, []*Table{{Type: RefTypeFuncref}}, nil)
+		err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, []*Memory{{}}, []*Table{{Type: RefTypeFuncref}}, nil)
 		require.NoError(t, err)
 	})
 	t.Run("non zero table index", func(t *testing.T) {
@@ -2213,11 +2213,11 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		t.Run("disabled", func(t *testing.T) {
-			err := m.validateFunction(api.CoreFeaturesV1, 0, []Index{0}, nil, &Memory{}, []*Table{{}, {}}, nil)
+			err := m.validateFunction(api.CoreFeaturesV1, 0, []Index{0}, nil, []*Memory{{}}, []*Table{{}, {}}, nil)
 			require.EqualError(t, err, "table index must be zero but was 100: feature \"reference-types\" is disabled")
 		})
 		t.Run("enabled but out of range", func(t *testing.T) {
-			err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, &Memory{}, []*Table{{}, {}}, nil)
+			err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, []*Memory{{}}, []*Table{{}, {}}, nil)
 			require.EqualError(t, err, "unknown table index: 100")
 		})
 	})
@@ -2231,7 +2231,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 				OpcodeEnd,
 			}}},
 		}
-		err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, &Memory{}, []*Table{{Type: RefTypeExternref}}, nil)
+		err := m.validateFunction(api.CoreFeatureReferenceTypes, 0, []Index{0}, nil, []*Memory{{}}, []*Table{{Type: RefTypeExternref}}, nil)
 		require.EqualError(t, err, "table is not funcref type but was externref for call_indirect")
 	})
 }
@@ -3148,7 +3148,7 @@ func TestModule_funcValidation_SIMD(t *testing.T) {
 				FunctionSection: []Index{0},
 				CodeSection:     []*Code{{Body: tc.body}},
 			}
-			err := m.validateFunction(api.CoreFeatureSIMD, 0, []Index{0}, nil, &Memory{}, nil, nil)
+			err := m.validateFunction(api.CoreFeatureSIMD, 0, []Index{0}, nil, []*Memory{{}}, nil, nil)
 			require.NoError(t, err)
 		})
 	}
@@ -3293,12 +3293,1236 @@ func TestModule_funcValidation_SIMD_error(t *testing.T) {
 				FunctionSection: []Index{0},
 				CodeSection:     []*Code{{Body: tc.body}},
 			}
-			err := m.validateFunction(tc.flag, 0, []Index{0}, nil, &Memory{}, nil, nil)
+			err := m.validateFunction(tc.flag, 0, []Index{0}, nil, []*Memory{{}}, nil, nil)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
 }
 
+func TestModule_funcValidation_Atomics(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{
+			name: "memory.atomic.notify",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 1,
+				OpcodeAtomicPrefix, OpcodeAtomicMemoryNotify, 2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.atomic.wait64",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 1,
+				OpcodeI64Const, 2,
+				OpcodeAtomicPrefix, OpcodeAtomicMemoryWait64, 3, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "atomic.fence",
+			body: []byte{
+				OpcodeAtomicPrefix, OpcodeAtomicFence, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i64.atomic.load32_u",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI64Load32U, 2, 8,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i32.atomic.store8",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 1,
+				OpcodeAtomicPrefix, OpcodeAtomicI32Store8, 0, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i64.atomic.rmw16.sub_u",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 1,
+				OpcodeAtomicPrefix, OpcodeAtomicI64RMW16SubU, 1, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i32.atomic.rmw.cmpxchg",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 1,
+				OpcodeI32Const, 2,
+				OpcodeAtomicPrefix, OpcodeAtomicI32RMWCmpxchg, 2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i64.atomic.rmw.xchg",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 1,
+				OpcodeAtomicPrefix, OpcodeAtomicI64RMWXchg, 3, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(api.CoreFeatureThreads, 0, []Index{0}, nil, []*Memory{{IsShared: true}}, nil, nil)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestModule_funcValidation_Atomics_error(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		memory      []*Memory
+		expectedErr string
+	}{
+		{
+			name: "threads disabled",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI32Load, 2, 0,
+			},
+			flag:        api.CoreFeaturesV2,
+			memory:      []*Memory{{}},
+			expectedErr: "i32.atomic.load invalid as feature \"threads\" is disabled",
+		},
+		{
+			name: "invalid opcode",
+			body: []byte{
+				OpcodeAtomicPrefix, 0x04,
+			},
+			flag:        api.CoreFeatureThreads,
+			memory:      []*Memory{{}},
+			expectedErr: "invalid atomic opcode: 0x4",
+		},
+		{
+			name: "memory missing",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI32Load, 2, 0,
+			},
+			flag:        api.CoreFeatureThreads,
+			expectedErr: "memory must exist for i32.atomic.load",
+		},
+		{
+			name: "smaller alignment",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI32Load, 1, 0,
+			},
+			flag:        api.CoreFeatureThreads,
+			memory:      []*Memory{{}},
+			expectedErr: "invalid memory alignment 1 for i32.atomic.load",
+		},
+		{
+			name: "larger alignment",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI64Load8U, 1, 0,
+			},
+			flag:        api.CoreFeatureThreads,
+			memory:      []*Memory{{}},
+			expectedErr: "invalid memory alignment 1 for i64.atomic.load8_u",
+		},
+		{
+			name: "type mismatch",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI64Store, 3, 0,
+			},
+			flag:        api.CoreFeatureThreads,
+			memory:      []*Memory{{}},
+			expectedErr: "cannot pop the operand for i64.atomic.store: type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "fence immediate",
+			body: []byte{
+				OpcodeAtomicPrefix, OpcodeAtomicFence, 1,
+			},
+			flag:        api.CoreFeatureThreads,
+			memory:      []*Memory{{}},
+			expectedErr: "invalid immediate value for atomic.fence",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(tc.flag, 0, []Index{0}, nil, tc.memory, nil, nil)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestModule_funcValidation_TailCall(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		tables      []*Table
+		expectedErr string
+	}{
+		{
+			name: "return_call",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeReturnCall, 1,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureTailCall,
+		},
+		{
+			name: "return_call followed by unreachable code",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeReturnCall, 1,
+				OpcodeDrop,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureTailCall,
+		},
+		{
+			name: "return_call_indirect",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			flag:   api.CoreFeatureTailCall,
+			tables: []*Table{{Type: RefTypeFuncref}},
+		},
+		{
+			name: "return_call disabled",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeReturnCall, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "return_call invalid as feature \"tail-call\" is disabled",
+		},
+		{
+			name: "return_call_indirect disabled",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			tables:      []*Table{{Type: RefTypeFuncref}},
+			expectedErr: "return_call_indirect invalid as feature \"tail-call\" is disabled",
+		},
+		{
+			name: "return_call invalid function index",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeReturnCall, 5,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			expectedErr: "invalid function index",
+		},
+		{
+			name: "return_call result mismatch",
+			body: []byte{
+				OpcodeReturnCall, 2,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			expectedErr: "type mismatch on return_call operation result type: v_v != i32_i32",
+		},
+		{
+			name: "return_call param mismatch",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeReturnCall, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			expectedErr: "type mismatch on return_call operation param type: type mismatch: expected i32, but was i64",
+		},
+		{
+			name: "return_call_indirect result mismatch",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeReturnCallIndirect, 1, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			tables:      []*Table{{Type: RefTypeFuncref}},
+			expectedErr: "type mismatch on return_call_indirect operation result type: v_v != i32_i32",
+		},
+		{
+			name: "return_call_indirect missing offset",
+			body: []byte{
+				OpcodeReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			tables:      []*Table{{Type: RefTypeFuncref}},
+			expectedErr: "cannot pop the offset in table for return_call_indirect",
+		},
+		{
+			name: "return_call_indirect unknown table",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureTailCall,
+			expectedErr: "unknown table index: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{i32_i32, v_v},
+				FunctionSection: []Index{0, 0, 1},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(tc.flag, 0, []Index{0, 0, 1}, nil, nil, tc.tables, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_MultiMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "i32.load with memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | 0x40, 1, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureMultiMemory,
+		},
+		{
+			name: "memory.grow and memory.size with memory index",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeMemoryGrow, 1,
+				OpcodeDrop,
+				OpcodeMemorySize, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureMultiMemory,
+		},
+		{
+			name: "memory.copy between memories",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 1, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureBulkMemoryOperations | api.CoreFeatureMultiMemory,
+		},
+		{
+			name: "memory.fill with memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureBulkMemoryOperations | api.CoreFeatureMultiMemory,
+		},
+		{
+			name: "i32.load unknown memory",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | 0x40, 2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureMultiMemory,
+			expectedErr: "unknown memory 2 for i32.load",
+		},
+		{
+			name: "i32.load memory index disabled",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | 0x40, 1, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "memory index invalid as feature \"multi-memory\" is disabled",
+		},
+		{
+			name: "memory.size unknown memory",
+			body: []byte{
+				OpcodeMemorySize, 2,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureMultiMemory,
+			expectedErr: "unknown memory 2 for memory.size",
+		},
+		{
+			name: "memory.grow memory index disabled",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeMemoryGrow, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "memory instruction reserved bytes not zero with 1 byte",
+		},
+		{
+			name: "memory.copy unknown source memory",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 2,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureBulkMemoryOperations | api.CoreFeatureMultiMemory,
+			expectedErr: "unknown memory 2 for memory.copy",
+		},
+		{
+			name: "memory.fill memory index disabled",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureBulkMemoryOperations,
+			expectedErr: "memory.fill reserved byte must be zero encoded with 1 byte",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(tc.flag, 0, []Index{0}, nil, []*Memory{{}, {}}, nil, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_Memory64(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "i64.load with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI64Load, 0x3, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i32.store with i64 address and offset over 32-bit",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Store, 0x2, 0x80, 0x80, 0x80, 0x80, 0x10,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "i32.load with i32 address",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "i32.load of 32-bit memory with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Load, 0x2 | 0x40, 1, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i32, but was i64",
+		},
+		{
+			name: "i32.load of 32-bit memory with offset over 32-bit",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | 0x40, 1, 0x80, 0x80, 0x80, 0x80, 0x10,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "read memory offset: overflows a 32-bit integer",
+		},
+		{
+			name: "memory.size and memory.grow with i64",
+			body: []byte{
+				OpcodeMemorySize, 0,
+				OpcodeMemoryGrow, 0,
+				OpcodeI64Const, 0,
+				OpcodeI64Add,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.grow with i32",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeMemoryGrow, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "memory.fill with i64 address and size",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.copy from 32-bit to 64-bit memory",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.copy from 32-bit to 64-bit memory with i64 size",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+			expectedErr: "cannot pop the operand for memory.copy: type mismatch: expected i32, but was i64",
+		},
+		{
+			name: "i32.atomic.load with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0x2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "v128.load with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeVecPrefix, OpcodeVecV128Load, 0x4, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:      []*FunctionType{v_v},
+				FunctionSection:  []Index{0},
+				CodeSection:      []*Code{{Body: tc.body}},
+				DataCountSection: new(uint32),
+			}
+			features := api.CoreFeaturesV2 | api.CoreFeatureMultiMemory | api.CoreFeatureThreads | api.CoreFeatureMemory64
+			err := m.validateFunction(features, 0, []Index{0}, nil, []*Memory{{Is64: true}, {}}, nil, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_ExceptionHandling(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "try catch",
+			body: []byte{
+				OpcodeTry, 0x7f, // (result i32)
+				OpcodeI32Const, 1,
+				OpcodeThrow, 0,
+				OpcodeCatch, 0,
+				OpcodeCatchAll,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureExceptionHandling,
+		},
+		{
+			name: "rethrow",
+			body: []byte{
+				OpcodeTry, 0x40,
+				OpcodeCatchAll,
+				OpcodeBlock, 0x40,
+				OpcodeRethrow, 1,
+				OpcodeEnd,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureExceptionHandling,
+		},
+		{
+			name: "delegate",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeTry, 0x40,
+				OpcodeDelegate, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureExceptionHandling,
+		},
+		{
+			name: "try disabled",
+			body: []byte{
+				OpcodeTry, 0x40,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "try invalid as feature \"exception-handling\" is disabled",
+		},
+		{
+			name: "throw unknown tag",
+			body: []byte{
+				OpcodeThrow, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "unknown tag 1 for throw",
+		},
+		{
+			name: "throw payload mismatch",
+			body: []byte{
+				OpcodeI64Const, 1,
+				OpcodeThrow, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "cannot use i64 in throw block as param[0] type i32",
+		},
+		{
+			name: "catch without try",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeCatchAll,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "catch_all must follow try or catch",
+		},
+		{
+			name: "catch payload mismatch",
+			body: []byte{
+				OpcodeTry, 0x7e, // (result i64)
+				OpcodeI64Const, 0,
+				OpcodeCatch, 0,
+				OpcodeEnd,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "cannot use i32 in catch block as result[0] type i64",
+		},
+		{
+			name: "rethrow outside catch",
+			body: []byte{
+				OpcodeTry, 0x40,
+				OpcodeRethrow, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "invalid rethrow operation: label 0 is not a catch or catch_all",
+		},
+		{
+			name: "delegate after catch",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeTry, 0x40,
+				OpcodeCatchAll,
+				OpcodeDelegate, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "delegate must end try without catch",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v, {Params: []ValueType{ValueTypeI32}}},
+				FunctionSection: []Index{0},
+				TagSection:      []Index{1},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(api.CoreFeaturesV2|tc.flag, 0, []Index{0}, nil, nil, nil, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_FunctionReferences(t *testing.T) {
+	funcref := ValueTypeFuncref
+	nullableV := &TypedRef{Nullable: true, HeapType: 0}
+	nonNullableV := &TypedRef{HeapType: 0}
+
+	tests := []struct {
+		name        string
+		localTypes  []ValueType
+		localRefs   []*TypedRef
+		body        []byte
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "call_ref",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name: "call_ref null",
+			body: []byte{
+				OpcodeRefNull, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name: "call_ref disabled",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "call_ref invalid as feature \"function-references\" is disabled",
+		},
+		{
+			name: "call_ref type mismatch",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "cannot pop the function reference for call_ref: type mismatch: expected (ref null 1), but was (ref 0)",
+		},
+		{
+			name:       "call_ref funcref",
+			localTypes: []ValueType{funcref},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "cannot pop the function reference for call_ref: type mismatch: expected (ref null 0), but was funcref",
+		},
+		{
+			name: "return_call_ref",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeReturnCallRef, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences | api.CoreFeatureTailCall,
+		},
+		{
+			name: "return_call_ref without tail call",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeReturnCallRef, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "return_call_ref invalid as feature \"tail-call\" is disabled",
+		},
+		{
+			name:       "non-nullable local",
+			localTypes: []ValueType{funcref},
+			localRefs:  []*TypedRef{nonNullableV},
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeLocalSet, 0,
+				OpcodeLocalGet, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name:       "non-nullable local uninitialized",
+			localTypes: []ValueType{funcref},
+			localRefs:  []*TypedRef{nonNullableV},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "uninitialized local 0 of non-nullable type (ref 0) for local.get",
+		},
+		{
+			name:       "non-nullable local initialized in block",
+			localTypes: []ValueType{funcref},
+			localRefs:  []*TypedRef{nonNullableV},
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeRefFunc, 0,
+				OpcodeLocalSet, 0,
+				OpcodeEnd,
+				OpcodeLocalGet, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "uninitialized local 0 of non-nullable type (ref 0) for local.get",
+		},
+		{
+			name:       "non-nullable local set with nullable",
+			localTypes: []ValueType{funcref},
+			localRefs:  []*TypedRef{nonNullableV},
+			body: []byte{
+				OpcodeRefNull, 0,
+				OpcodeLocalSet, 0,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "type mismatch: expected (ref 0), but was (ref null 0)",
+		},
+		{
+			name:       "ref.as_non_null",
+			localTypes: []ValueType{funcref, funcref},
+			localRefs:  []*TypedRef{nullableV, nonNullableV},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefAsNonNull,
+				OpcodeLocalSet, 1,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name: "ref.as_non_null not reference",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeRefAsNonNull,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "type mismatch: expected reference type but was i32",
+		},
+		{
+			name: "br_on_null",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeRefNull, 0,
+				OpcodeBrOnNull, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name: "br_on_non_null",
+			body: []byte{
+				OpcodeBlock, RefTypePrefixNonNullable, 0,
+				OpcodeRefNull, 0,
+				OpcodeBrOnNonNull, 0,
+				OpcodeUnreachable,
+				OpcodeEnd,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureFunctionReferences,
+		},
+		{
+			name: "br_on_non_null label mismatch",
+			body: []byte{
+				OpcodeBlock, 0x7f, // (result i32)
+				OpcodeRefNull, 0,
+				OpcodeBrOnNonNull, 0,
+				OpcodeUnreachable,
+				OpcodeEnd,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "type mismatch on br_on_non_null operation: cannot use (ref 0) as i32",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v, i32_i32},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{{LocalTypes: tc.localTypes, LocalRefs: tc.localRefs, Body: tc.body}},
+			}
+			err := m.validateFunction(api.CoreFeaturesV2|tc.flag, 0, []Index{0}, nil, nil, nil, map[Index]struct{}{0: {}})
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_GC(t *testing.T) {
+	// $point: (struct (field (mut i32)) (field i8)), $refs: (array (ref $point))
+	point := &FunctionType{Composite: &CompositeType{Final: true, Fields: []*FieldType{
+		{Type: ValueTypeI32, Mutable: true},
+		{Type: PackedTypeI8},
+	}}}
+	refs := &FunctionType{Composite: &CompositeType{Final: true, IsArray: true, Fields: []*FieldType{
+		{Type: ValueTypeAnyref, Ref: &TypedRef{HeapType: 0}},
+	}}}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "struct.new",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeI32Const, 2,
+				OpcodeGCPrefix, OpcodeGCStructNew, 0,
+				OpcodeGCPrefix, OpcodeGCStructGetS, 0, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureGC,
+		},
+		{
+			name: "struct.new disabled",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeI32Const, 2,
+				OpcodeGCPrefix, OpcodeGCStructNew, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureFunctionReferences,
+			expectedErr: "struct.new invalid as feature \"gc\" is disabled",
+		},
+		{
+			name: "struct.new array type",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNew, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "type 1 is not a struct type for struct.new",
+		},
+		{
+			name: "struct.get packed",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeGCPrefix, OpcodeGCStructGet, 0, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "struct.get is invalid for the field type i8",
+		},
+		{
+			name: "struct.set immutable",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeI32Const, 1,
+				OpcodeGCPrefix, OpcodeGCStructSet, 0, 1,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "struct.set is invalid for the immutable field type i8",
+		},
+		{
+			name: "unknown field",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeGCPrefix, OpcodeGCStructGet, 0, 2,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "unknown field index 2 for struct.get",
+		},
+		{
+			name: "array.new_fixed",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeGCPrefix, OpcodeGCArrayNewFixed, 1, 2,
+				OpcodeGCPrefix, OpcodeGCArrayLen,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureGC,
+		},
+		{
+			name: "array.new_default non-defaultable",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeGCPrefix, OpcodeGCArrayNewDefault, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "element of type 1 has no default value for array.new_default",
+		},
+		{
+			name: "array.get struct",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeI32Const, 0,
+				OpcodeGCPrefix, OpcodeGCArrayGet, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "cannot pop the reference for array.get: type mismatch: expected (ref null 1), but was (ref 0)",
+		},
+		{
+			name: "ref.cast",
+			body: []byte{
+				OpcodeRefNull, 0x6e, // any
+				OpcodeGCPrefix, OpcodeGCRefCast, 0,
+				OpcodeGCPrefix, OpcodeGCStructGet, 0, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureGC,
+		},
+		{
+			name: "ref.test func",
+			body: []byte{
+				OpcodeRefNull, 0x6e, // any
+				OpcodeGCPrefix, OpcodeGCRefTest, 0x70, // func
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "ref.test is only supported on the any hierarchy but was func",
+		},
+		{
+			name: "ref.eq",
+			body: []byte{
+				OpcodeGCPrefix, OpcodeGCStructNewDefault, 0,
+				OpcodeRefNull, 0x71, // none
+				OpcodeRefEq,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag: api.CoreFeatureGC,
+		},
+		{
+			name: "ref.eq any",
+			body: []byte{
+				OpcodeRefNull, 0x6e, // any
+				OpcodeRefNull, 0x6e, // any
+				OpcodeRefEq,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			flag:        api.CoreFeatureGC,
+			expectedErr: "cannot pop the operand for ref.eq: type mismatch: expected (ref null eq), but was anyref",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{point, refs, v_v},
+				FunctionSection: []Index{2},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(api.CoreFeaturesV2|api.CoreFeatureFunctionReferences|tc.flag, 0, []Index{0}, nil, nil, nil, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_RelaxedSIMD(t *testing.T) {
+	// relaxed returns the body which pushes the number of v128 constants and then executes the relaxed instruction.
+	relaxed := func(op OpcodeVecRelaxed, operands int) (ret []byte) {
+		for i := 0; i < operands; i++ {
+			ret = append(ret, OpcodeVecPrefix, OpcodeVecV128Const,
+				1, 1, 1, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 1, 1, 1)
+		}
+		return append(ret, OpcodeVecPrefix, 0x80|op, 0x02, OpcodeDrop, OpcodeEnd)
+	}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{name: OpcodeVecI8x16RelaxedSwizzleName, body: relaxed(OpcodeVecI8x16RelaxedSwizzle, 2), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI32x4RelaxedTruncF32x4SName, body: relaxed(OpcodeVecI32x4RelaxedTruncF32x4S, 1), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI32x4RelaxedTruncF64x2UZeroName, body: relaxed(OpcodeVecI32x4RelaxedTruncF64x2UZero, 1), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecF32x4RelaxedMaddName, body: relaxed(OpcodeVecF32x4RelaxedMadd, 3), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecF64x2RelaxedNmaddName, body: relaxed(OpcodeVecF64x2RelaxedNmadd, 3), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI64x2RelaxedLaneselectName, body: relaxed(OpcodeVecI64x2RelaxedLaneselect, 3), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecF64x2RelaxedMaxName, body: relaxed(OpcodeVecF64x2RelaxedMax, 2), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI16x8RelaxedQ15mulrSName, body: relaxed(OpcodeVecI16x8RelaxedQ15mulrS, 2), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI16x8RelaxedDotI8x16I7x16SName, body: relaxed(OpcodeVecI16x8RelaxedDotI8x16I7x16S, 2), flag: api.CoreFeatureRelaxedSIMD},
+		{name: OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName, body: relaxed(OpcodeVecI32x4RelaxedDotI8x16I7x16AddS, 3), flag: api.CoreFeatureRelaxedSIMD},
+		{
+			name:        "disabled",
+			body:        relaxed(OpcodeVecF32x4RelaxedMadd, 3),
+			expectedErr: "f32x4.relaxed_madd invalid as feature \"relaxed-simd\" is disabled",
+		},
+		{
+			name:        "too few operands",
+			body:        relaxed(OpcodeVecI32x4RelaxedDotI8x16I7x16AddS, 2),
+			flag:        api.CoreFeatureRelaxedSIMD,
+			expectedErr: "cannot pop the operand for i32x4.relaxed_dot_i8x16_i7x16_add_s: v128 missing",
+		},
+		{
+			name:        "invalid opcode",
+			body:        relaxed(0x14, 0),
+			flag:        api.CoreFeatureRelaxedSIMD,
+			expectedErr: "invalid relaxed vector opcode: 0x114",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []*FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(api.CoreFeaturesV2|tc.flag, 0, []Index{0}, nil, nil, nil, nil)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestDecodeBlockType(t *testing.T) {
 	t.Run("primitive", func(t *testing.T) {
 		for _, tc := range []struct {
@@ -68,9 +68,10 @@ func (m *Module) BuildFunctionDefinitions() {
 	for codeIndex, typeIndex := range m.FunctionSection {
 		code := m.CodeSection[codeIndex]
 		m.FunctionDefinitionSection = append(m.FunctionDefinitionSection, &FunctionDefinition{
-			index:    Index(codeIndex) + importCount,
-			funcType: m.TypeSection[typeIndex],
-			goFunc:   code.GoFunc,
+			index:      Index(codeIndex) + importCount,
+			funcType:   m.TypeSection[typeIndex],
+			goFunc:     code.GoFunc,
+			dwarfLines: m.DWARFLines,
 		})
 	}
 
@@ -116,6 +117,7 @@ type FunctionDefinition struct {
 	exportNames []string
 	paramNames  []string
 	resultNames []string
+	dwarfLines  *wasmdebug.DWARFLines
 }
 
 // ModuleName implements the same method as documented on api.FunctionDefinition.
@@ -123,6 +125,11 @@ func (f *FunctionDefinition) ModuleName() string {
 	return f.moduleName
 }
 
+// DWARFLines returns the DWARF line information of the module defining this function, or nil if it has none.
+func (f *FunctionDefinition) DWARFLines() *wasmdebug.DWARFLines {
+	return f.dwarfLines
+}
+
 // Index implements the same method as documented on api.FunctionDefinition.
 func (f *FunctionDefinition) Index() uint32 {
 	return f.index
@@ -0,0 +1,201 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PackedTypeI8 is the 8-bit integer storage type of a field, which is only valid in a CompositeType.
+	PackedTypeI8 ValueType = 0x78
+	// PackedTypeI16 is the 16-bit integer storage type of a field, which is only valid in a CompositeType.
+	PackedTypeI16 ValueType = 0x77
+)
+
+const (
+	// CompositeTypePrefixFunc is the prefix of a function type in the type section.
+	CompositeTypePrefixFunc byte = 0x60
+	// CompositeTypePrefixStruct is the prefix of a struct type in the type section (CoreFeatureGC).
+	CompositeTypePrefixStruct byte = 0x5f
+	// CompositeTypePrefixArray is the prefix of an array type in the type section (CoreFeatureGC).
+	CompositeTypePrefixArray byte = 0x5e
+	// SubTypePrefix is the prefix of a type which declares its supertypes and can have subtypes (CoreFeatureGC).
+	SubTypePrefix byte = 0x50
+	// SubTypePrefixFinal is the prefix of a type which declares its supertypes and has no subtypes (CoreFeatureGC).
+	SubTypePrefixFinal byte = 0x4f
+	// RecTypePrefix is the prefix of a recursion group of types, which can refer to each other (CoreFeatureGC).
+	RecTypePrefix byte = 0x4e
+)
+
+// maximumArrayNewFixedSize is the limit of the number of operands of array.new_fixed, which is the same as the
+// implementation limit of the JS API.
+const maximumArrayNewFixedSize = 10000
+
+// CompositeType is a struct or an array type defined by CoreFeatureGC. This is held by FunctionType.Composite, so that
+// it shares the type index space and the type IDs of function types.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#types
+type CompositeType struct {
+	// IsArray is true if this is an array type whose element type is Fields[0], or false if this is a struct type.
+	IsArray bool
+
+	// Fields are the possibly empty fields of a struct type, or the only element type of an array type.
+	Fields []*FieldType
+
+	// Supertype is the index of the declared supertype in Module.TypeSection, or nil if there is none.
+	//
+	// Note: The proposal allows at most one supertype.
+	Supertype *Index
+
+	// Final is true when the type cannot have subtypes. Types not declared with `sub` are final.
+	Final bool
+}
+
+// FieldType is the type of a field of a struct type or the element of an array type.
+type FieldType struct {
+	// Type is the storage type, which is either a ValueType or PackedTypeI8 or PackedTypeI16.
+	Type ValueType
+
+	// Ref is the TypedRef of Type, if any.
+	Ref *TypedRef
+
+	// Mutable is true when the field can be updated by struct.set or array.set.
+	Mutable bool
+}
+
+// UnpackedType returns the ValueType of the values read from or written to the field, which is ValueTypeI32 for
+// packed types.
+func (f *FieldType) UnpackedType() ValueType {
+	if f.IsPacked() {
+		return ValueTypeI32
+	}
+	return f.Type
+}
+
+// IsPacked returns true if the storage type is PackedTypeI8 or PackedTypeI16.
+func (f *FieldType) IsPacked() bool {
+	return f.Type == PackedTypeI8 || f.Type == PackedTypeI16
+}
+
+// isDefaultable returns true if the field has a default value, i.e. it is not a non-nullable reference.
+func (f *FieldType) isDefaultable() bool {
+	return f.Ref == nil || f.Ref.Nullable
+}
+
+// String implements fmt.Stringer.
+func (f *FieldType) String() string {
+	var t string
+	switch f.Type {
+	case PackedTypeI8:
+		t = "i8"
+	case PackedTypeI16:
+		t = "i16"
+	default:
+		t = typedRefName(f.Type, f.Ref)
+	}
+	if f.Mutable {
+		return "(mut " + t + ")"
+	}
+	return t
+}
+
+// equals returns true if the composite types are structurally the same, including their declared supertype.
+func (c *CompositeType) equals(o *CompositeType) bool {
+	if c.IsArray != o.IsArray || c.Final != o.Final || len(c.Fields) != len(o.Fields) ||
+		(c.Supertype == nil) != (o.Supertype == nil) || (c.Supertype != nil && *c.Supertype != *o.Supertype) {
+		return false
+	}
+	for i, f := range c.Fields {
+		if g := o.Fields[i]; f.Type != g.Type || f.Mutable != g.Mutable || !typedRefsEqual([]*TypedRef{f.Ref}, []*TypedRef{g.Ref}) {
+			return false
+		}
+	}
+	return true
+}
+
+// key returns the key of the composite type for Store.typeIDs, e.g. "struct{(mut i32),i8}".
+//
+// Note: A type index in a field type is specific to the module, so structurally equivalent composite types of different
+// modules can have different keys.
+func (c *CompositeType) key() string {
+	var b strings.Builder
+	if c.Supertype != nil {
+		b.WriteString(fmt.Sprintf("sub %d ", *c.Supertype))
+	}
+	if !c.Final {
+		b.WriteString("open ")
+	}
+	if c.IsArray {
+		b.WriteString("array{")
+	} else {
+		b.WriteString("struct{")
+	}
+	for i, f := range c.Fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(f.String())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// validateCompositeType returns an error if the composite type of the index refers to an unknown type, or doesn't
+// match its supertype.
+func (m *Module) validateCompositeType(index Index) error {
+	c := m.TypeSection[index].Composite
+	for i, f := range c.Fields {
+		// Each value of a GC object is held by a uint64, so v128 fields are not supported yet.
+		if f.Type == ValueTypeV128 {
+			return fmt.Errorf("field %d: v128 fields are not supported", i)
+		}
+		if err := validateTypeIndexes(m.TypeSection, []*TypedRef{f.Ref}); err != nil {
+			return err
+		}
+	}
+	if c.Supertype == nil {
+		return nil
+	}
+
+	superIndex := *c.Supertype
+	if superIndex >= index {
+		return fmt.Errorf("supertype %d must be defined before the type", superIndex)
+	}
+	super := m.TypeSection[superIndex].Composite
+	if super == nil {
+		return fmt.Errorf("supertype %d must be a struct or an array type", superIndex)
+	} else if super.Final {
+		return fmt.Errorf("supertype %d is final", superIndex)
+	} else if super.IsArray != c.IsArray || len(c.Fields) < len(super.Fields) || (c.IsArray && len(c.Fields) != 1) {
+		return fmt.Errorf("type doesn't match the supertype %d", superIndex)
+	}
+	for i, sf := range super.Fields {
+		f := c.Fields[i]
+		if f.Mutable != sf.Mutable || f.Type != sf.Type {
+			return fmt.Errorf("field %d %s doesn't match %s of the supertype %d", i, f, sf, superIndex)
+		}
+		// Immutable fields are covariant, and mutable fields are invariant.
+		if sf.Mutable && !typedRefsEqual([]*TypedRef{f.Ref}, []*TypedRef{sf.Ref}) ||
+			!sf.Mutable && !isTypedRefSubtype(m.TypeSection, f.Ref, sf.Ref) {
+			return fmt.Errorf("field %d %s doesn't match %s of the supertype %d", i, f, sf, superIndex)
+		}
+	}
+	return nil
+}
+
+// compositeTypeOf returns the composite type of the index in types, or an error if it is out of range or is a function
+// type. isArray specifies the expected kind.
+func compositeTypeOf(types []*FunctionType, index uint32, isArray bool) (*CompositeType, error) {
+	if int(index) >= len(types) {
+		return nil, fmt.Errorf("unknown type index %d", index)
+	}
+	c := types[index].Composite
+	if c == nil || c.IsArray != isArray {
+		kind := "struct"
+		if isArray {
+			kind = "array"
+		}
+		return nil, fmt.Errorf("type %d is not a %s type", index, kind)
+	}
+	return c, nil
+}
@@ -0,0 +1,588 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// gcCollectThreshold is the minimum number of allocations between collections of GCHeap.
+const gcCollectThreshold = 1024
+
+// maximumArrayLength is the limit of the length of an array allocated by array.new or array.new_default, which is the
+// same as the maximum number of the elements of a table.
+const maximumArrayLength = MaximumTableIndex
+
+// GCHeap holds the structs and arrays allocated by the modules of a Store when api.CoreFeatureGC is enabled.
+//
+// A reference to an object is an opaque handle: the lower 32 bits are the index of the object in the heap plus one, so
+// that zero is null, and the upper 32 bits are the generation of the slot, so that the handle of a collected object is
+// detected even after the slot is reused. The objects themselves are Go values, so the memory of an object is released
+// by Go's garbage collector once the heap drops it.
+//
+// Unreachable objects are collected by mark-and-sweep when an object is allocated, if there have been enough
+// allocations and no other api.Function call is executing Wasm at that time, so that no stack changes during the
+// collection. The roots are the globals of the modules, the values on the stacks of the calls in progress and the
+// payloads of their exceptions, the results of the last call of each api.Function, and the objects held by the host
+// with Module.GCObject. Tables can't hold references to objects.
+// The values on the stacks aren't typed, so any value which is the handle of an object is considered a reference.
+type GCHeap struct {
+	// mux guards all fields. Note that the values of objects are not guarded, the same as memory instances.
+	mux sync.RWMutex
+
+	// objects are index-correlated with generations, and the slot is nil when it is free.
+	objects     []*gcObject
+	generations []uint32
+	// free are the indexes of the free slots.
+	free []uint32
+
+	// allocated is the number of the allocations since the last collection, and live is the number of the objects
+	// which survived it.
+	allocated, live int
+
+	// calls are the api.Function calls in progress, and running is the number of the ones which aren't paused.
+	calls   []*gcCall
+	running int
+
+	// pins are the number of the api.GCObject and gcResults held by the host for each reference, which are roots until
+	// they are released.
+	pins map[uint64]int
+
+	// modules are the instantiated modules whose globals are the roots.
+	modules map[*ModuleInstance]struct{}
+}
+
+// gcCall is an api.Function call in progress.
+type gcCall struct {
+	ce CallEngine
+	// paused is true while the call is calling a host function, in which case roots are the references on its stack
+	// when it paused, as the host function can change the stack concurrently with a collection.
+	paused bool
+	roots  []uint64
+}
+
+// NewGCHeap returns an empty GCHeap.
+func NewGCHeap() *GCHeap {
+	return &GCHeap{pins: map[uint64]int{}, modules: map[*ModuleInstance]struct{}{}}
+}
+
+// gcObject is a struct or an array. It implements api.GCObject with gcHostObject, which adds its reference.
+type gcObject struct {
+	// typeIDs are the FunctionTypeID of the type of this object, followed by the ones of its supertypes.
+	typeIDs []FunctionTypeID
+
+	composite *CompositeType
+
+	// values are the fields of a struct or the elements of an array. Packed values are zero-extended.
+	values []uint64
+
+	// marked is used during the collection.
+	marked bool
+}
+
+// IsArray implements the same method as documented on api.GCObject.
+func (o *gcObject) IsArray() bool {
+	return o.composite.IsArray
+}
+
+// Len implements the same method as documented on api.GCObject.
+func (o *gcObject) Len() uint32 {
+	return uint32(len(o.values))
+}
+
+// Type implements the same method as documented on api.GCObject.
+func (o *gcObject) Type(i uint32) ValueType {
+	if f := o.field(i); f != nil {
+		return f.UnpackedType()
+	}
+	return 0
+}
+
+// Get implements the same method as documented on api.GCObject.
+func (o *gcObject) Get(i uint32) (uint64, bool) {
+	if i >= uint32(len(o.values)) {
+		return 0, false
+	}
+	return o.values[i], true
+}
+
+// Set implements the same method as documented on api.GCObject.
+func (o *gcObject) Set(i uint32, v uint64) bool {
+	f := o.field(i)
+	if f == nil || !f.Mutable {
+		return false
+	}
+	o.values[i] = f.pack(v)
+	return true
+}
+
+// String implements fmt.Stringer.
+func (o *gcObject) String() string {
+	if o.composite.IsArray {
+		return fmt.Sprintf("array[%d]", len(o.values))
+	}
+	return fmt.Sprintf("struct[%d]", len(o.values))
+}
+
+// field returns the type of the i-th value, or nil if i is out of range.
+func (o *gcObject) field(i uint32) *FieldType {
+	if i >= uint32(len(o.values)) {
+		return nil
+	} else if o.composite.IsArray {
+		return o.composite.Fields[0]
+	}
+	return o.composite.Fields[i]
+}
+
+// pack returns v truncated to the size of the packed type of the field, if any.
+func (f *FieldType) pack(v uint64) uint64 {
+	switch f.Type {
+	case PackedTypeI8:
+		return uint64(uint8(v))
+	case PackedTypeI16:
+		return uint64(uint16(v))
+	}
+	return v
+}
+
+// unpack returns the value v of the field as the result of get instructions, sign-extending packed values if signed.
+func (f *FieldType) unpack(v uint64, signed bool) uint64 {
+	if !signed {
+		return v
+	}
+	switch f.Type {
+	case PackedTypeI8:
+		return uint64(uint32(int8(v)))
+	case PackedTypeI16:
+		return uint64(uint32(int16(v)))
+	}
+	return v
+}
+
+// alloc adds the object to the heap, and returns the reference to it. The unreachable objects are collected before,
+// except the ones the object refers to.
+func (h *GCHeap) alloc(o *gcObject) uint64 {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	// This is called by the call executing Wasm, so the others are paused if it is the only one running.
+	if h.running == 1 && h.allocated >= gcCollectThreshold && h.allocated >= h.live {
+		h.collectLocked(o)
+	}
+	h.allocated++
+	var index uint32
+	if n := len(h.free); n > 0 {
+		index = h.free[n-1]
+		h.free = h.free[:n-1]
+		h.objects[index] = o
+	} else {
+		index = uint32(len(h.objects))
+		h.objects = append(h.objects, o)
+		h.generations = append(h.generations, 0)
+	}
+	return uint64(h.generations[index])<<32 | uint64(index+1)
+}
+
+// object returns the object of the reference, or nil if it is null or invalid.
+func (h *GCHeap) object(ref uint64) *gcObject {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.objectLocked(ref)
+}
+
+func (h *GCHeap) objectLocked(ref uint64) *gcObject {
+	index := uint32(ref) - 1
+	if ref == 0 || index >= uint32(len(h.objects)) || h.generations[index] != uint32(ref>>32) {
+		return nil
+	}
+	return h.objects[index]
+}
+
+// addModule registers the globals of the module as roots.
+func (h *GCHeap) addModule(m *ModuleInstance) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.modules[m] = struct{}{}
+}
+
+// removeModule unregisters the globals of the module from the roots.
+func (h *GCHeap) removeModule(m *ModuleInstance) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	delete(h.modules, m)
+}
+
+// enter is called when an api.Function call of ce starts.
+func (h *GCHeap) enter(ce CallEngine) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.calls = append(h.calls, &gcCall{ce: ce})
+	h.running++
+}
+
+// exit is called when an api.Function call of ce, whose function type is ft, returns the results or err. This
+// returns the references among them, which are pinned until they are released, or nil if none.
+func (h *GCHeap) exit(ce CallEngine, ft *FunctionType, results []uint64, err error) *gcResults {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for i := len(h.calls) - 1; i >= 0; i-- {
+		if c := h.calls[i]; c.ce == ce {
+			if !c.paused {
+				h.running--
+			}
+			h.calls = append(h.calls[:i], h.calls[i+1:]...)
+			break
+		}
+	}
+
+	var refs []uint64
+	for i, vt := range ft.Results {
+		if vt == ValueTypeAnyref && i < len(results) && h.objectLocked(results[i]) != nil {
+			refs = append(refs, results[i])
+		}
+	}
+	var exception *api.Exception
+	if errors.As(err, &exception) {
+		for i, vt := range exception.Tag().ParamTypes() {
+			if v := exception.Payload()[i]; vt == ValueTypeAnyref && h.objectLocked(v) != nil {
+				refs = append(refs, v)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	for _, ref := range refs {
+		h.pins[ref]++
+	}
+	r := &gcResults{h: h, refs: refs}
+	runtime.SetFinalizer(r, (*gcResults).release)
+	return r
+}
+
+// gcResults are the references returned by an api.Function call, including the payload of its exception, which are
+// pinned until the next call of the same api.Function returns, or until Go collects it.
+type gcResults struct {
+	h    *GCHeap
+	refs []uint64
+}
+
+// release unpins the references, unless they are already released.
+func (r *gcResults) release() {
+	if r == nil {
+		return
+	}
+	runtime.SetFinalizer(r, nil)
+	h := r.h
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for _, ref := range r.refs {
+		h.unpinLocked(ref)
+	}
+	r.refs = nil
+}
+
+// PauseCall is called by the engine of an api.Function call before it calls a host function, which can call other
+// functions or be concurrent with them. Until ResumeCall, the references on the stack of the call are kept as roots,
+// and the other calls can collect objects.
+func (h *GCHeap) PauseCall(ce CallEngine) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	c := h.call(ce)
+	if c == nil || c.paused {
+		return
+	}
+	c.paused = true
+	h.running--
+	c.roots = c.roots[:0]
+	if len(h.objects) > len(h.free) {
+		ce.GCRoots(func(v uint64) {
+			if h.objectLocked(v) != nil {
+				c.roots = append(c.roots, v)
+			}
+		})
+	}
+}
+
+// ResumeCall is called by the engine of an api.Function call after the host function paused by PauseCall returns.
+func (h *GCHeap) ResumeCall(ce CallEngine) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if c := h.call(ce); c != nil && c.paused {
+		c.paused = false
+		h.running++
+		c.roots = c.roots[:0]
+	}
+}
+
+// call returns the innermost call of ce in progress, or nil if none.
+func (h *GCHeap) call(ce CallEngine) *gcCall {
+	for i := len(h.calls) - 1; i >= 0; i-- {
+		if c := h.calls[i]; c.ce == ce {
+			return c
+		}
+	}
+	return nil
+}
+
+// pin returns the object of ref, or nil if it is null or invalid. The object is kept alive until unpin is called as
+// many times as pin returned it.
+func (h *GCHeap) pin(ref uint64) *gcObject {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	o := h.objectLocked(ref)
+	if o != nil {
+		h.pins[ref]++
+	}
+	return o
+}
+
+// unpin reverts pin.
+func (h *GCHeap) unpin(ref uint64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.unpinLocked(ref)
+}
+
+func (h *GCHeap) unpinLocked(ref uint64) {
+	if h.pins[ref]--; h.pins[ref] == 0 {
+		delete(h.pins, ref)
+	}
+}
+
+// collectLocked frees the objects not reachable from the roots or from the values of o, which isn't allocated yet.
+func (h *GCHeap) collectLocked(o *gcObject) {
+	var stack []*gcObject
+	mark := func(ref uint64) {
+		if o := h.objectLocked(ref); o != nil && !o.marked {
+			o.marked = true
+			stack = append(stack, o)
+		}
+	}
+	markValues := func(o *gcObject) {
+		for i, v := range o.values {
+			if o.field(uint32(i)).Type == ValueTypeAnyref {
+				mark(v)
+			}
+		}
+	}
+	for m := range h.modules {
+		for _, g := range m.Globals {
+			if g.Type.ValType == ValueTypeAnyref {
+				mark(g.Val)
+			}
+		}
+	}
+	for _, c := range h.calls {
+		if c.paused {
+			for _, ref := range c.roots {
+				mark(ref)
+			}
+		} else {
+			c.ce.GCRoots(mark)
+		}
+	}
+	for ref := range h.pins {
+		mark(ref)
+	}
+	if o != nil {
+		markValues(o)
+	}
+	for len(stack) > 0 {
+		o := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		markValues(o)
+	}
+
+	h.live = 0
+	for i, o := range h.objects {
+		if o == nil {
+			continue
+		} else if o.marked {
+			o.marked = false
+			h.live++
+			continue
+		}
+		h.objects[i] = nil
+		h.generations[i]++
+		h.free = append(h.free, uint32(i))
+	}
+	h.allocated = 0
+}
+
+// buildGCTypes sets the fields of the module instance used by GC instructions.
+func (m *ModuleInstance) buildGCTypes(module *Module, heap *GCHeap) {
+	m.GCHeap = heap
+	m.Types = module.TypeSection
+	m.gcTypeIDs = make([][]FunctionTypeID, len(module.TypeSection))
+	for i, tp := range module.TypeSection {
+		if tp.Composite == nil {
+			continue
+		}
+		ids := []FunctionTypeID{m.TypeIDs[i]}
+		for c := tp.Composite; c.Supertype != nil; c = module.TypeSection[*c.Supertype].Composite {
+			ids = append(ids, m.TypeIDs[*c.Supertype])
+		}
+		m.gcTypeIDs[i] = ids
+	}
+	heap.addModule(m)
+}
+
+// ExecuteGC executes the GC instruction op on the operands, and returns the result if any. typeIndex and index are
+// the immediates of the instruction: the type index and the field index or the number of operands of array.new_fixed.
+// For ref.test and ref.cast, typeIndex is the heap type as a 32-bit signed integer.
+//
+// This panics with a wasmruntime.Error when the instruction traps.
+func (m *ModuleInstance) ExecuteGC(op OpcodeGC, typeIndex, index uint32, operands []uint64) uint64 {
+	switch op {
+	case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+		c := m.Types[typeIndex].Composite
+		values := make([]uint64, len(c.Fields))
+		if op == OpcodeGCStructNew {
+			for i, f := range c.Fields {
+				values[i] = f.pack(operands[i])
+			}
+		}
+		return m.GCHeap.alloc(&gcObject{typeIDs: m.gcTypeIDs[typeIndex], composite: c, values: values})
+	case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+		c := m.Types[typeIndex].Composite
+		var values []uint64
+		switch op {
+		case OpcodeGCArrayNew:
+			values = newArrayValues(operands[1])
+			if v := c.Fields[0].pack(operands[0]); v != 0 {
+				for i := range values {
+					values[i] = v
+				}
+			}
+		case OpcodeGCArrayNewDefault:
+			values = newArrayValues(operands[0])
+		default:
+			values = make([]uint64, index)
+			for i, v := range operands {
+				values[i] = c.Fields[0].pack(v)
+			}
+		}
+		return m.GCHeap.alloc(&gcObject{typeIDs: m.gcTypeIDs[typeIndex], composite: c, values: values})
+	case OpcodeGCStructGet, OpcodeGCStructGetS, OpcodeGCStructGetU:
+		o := m.gcObject(operands[0], false)
+		f := o.field(index)
+		if f == nil {
+			panic(wasmruntime.ErrRuntimeCastFailure)
+		}
+		return f.unpack(o.values[index], op == OpcodeGCStructGetS)
+	case OpcodeGCStructSet:
+		o := m.gcObject(operands[0], false)
+		f := o.field(index)
+		if f == nil {
+			panic(wasmruntime.ErrRuntimeCastFailure)
+		}
+		o.values[index] = f.pack(operands[1])
+	case OpcodeGCArrayGet, OpcodeGCArrayGetS, OpcodeGCArrayGetU:
+		o := m.gcObject(operands[0], true)
+		i := uint32(operands[1])
+		if i >= uint32(len(o.values)) {
+			panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+		}
+		return o.composite.Fields[0].unpack(o.values[i], op == OpcodeGCArrayGetS)
+	case OpcodeGCArraySet:
+		o := m.gcObject(operands[0], true)
+		i := uint32(operands[1])
+		if i >= uint32(len(o.values)) {
+			panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+		}
+		o.values[i] = o.composite.Fields[0].pack(operands[2])
+	case OpcodeGCArrayLen:
+		return uint64(len(m.gcObject(operands[0], true).values))
+	case OpcodeGCRefTest, OpcodeGCRefTestNull:
+		if m.refTest(operands[0], HeapType(int32(typeIndex)), op == OpcodeGCRefTestNull) {
+			return 1
+		}
+		return 0
+	case OpcodeGCRefCast, OpcodeGCRefCastNull:
+		if !m.refTest(operands[0], HeapType(int32(typeIndex)), op == OpcodeGCRefCastNull) {
+			panic(wasmruntime.ErrRuntimeCastFailure)
+		}
+		return operands[0]
+	default:
+		panic(fmt.Errorf("BUG: invalid gc opcode %#x", op))
+	}
+	return 0
+}
+
+// newArrayValues returns the zero values of an array of the length operand.
+func newArrayValues(length uint64) []uint64 {
+	if uint32(length) > maximumArrayLength {
+		panic(wasmruntime.ErrRuntimeArrayTooLarge)
+	}
+	return make([]uint64, uint32(length))
+}
+
+// gcObject returns the object of the reference ref, which is a struct unless isArray.
+//
+// This panics with wasmruntime.ErrRuntimeNullReference if ref is null or invalid, and with
+// wasmruntime.ErrRuntimeCastFailure if the object is not of the expected kind, which is only possible when the host
+// passed a wrong reference.
+func (m *ModuleInstance) gcObject(ref uint64, isArray bool) *gcObject {
+	o := m.GCHeap.object(ref)
+	if o == nil {
+		panic(wasmruntime.ErrRuntimeNullReference)
+	} else if o.composite.IsArray != isArray {
+		panic(wasmruntime.ErrRuntimeCastFailure)
+	}
+	return o
+}
+
+// refTest returns true if the reference ref is of the heap type ht, or null if nullable.
+func (m *ModuleInstance) refTest(ref uint64, ht HeapType, nullable bool) bool {
+	o := m.GCHeap.object(ref)
+	if o == nil {
+		return nullable
+	}
+	switch ht {
+	case HeapTypeAny, HeapTypeEq:
+		return true
+	case HeapTypeStruct:
+		return !o.composite.IsArray
+	case HeapTypeArray:
+		return o.composite.IsArray
+	case HeapTypeNone:
+		return false
+	}
+	id := m.TypeIDs[ht]
+	for _, have := range o.typeIDs {
+		if have == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GCObject implements the same method as documented on api.Module.
+func (m *CallContext) GCObject(ref uint64) (api.GCObject, bool) {
+	h := m.module.GCHeap
+	if h == nil {
+		return nil, false
+	}
+	o := h.pin(ref)
+	if o == nil {
+		return nil, false
+	}
+	ho := &gcHostObject{gcObject: o, ref: ref}
+	runtime.SetFinalizer(ho, func(ho *gcHostObject) { h.unpin(ho.ref) })
+	return ho, true
+}
+
+// gcHostObject implements api.GCObject for the host, which keeps the object alive until Go collects it.
+type gcHostObject struct {
+	*gcObject
+	ref uint64
+}
+
+// Ref implements the same method as documented on api.GCObject.
+func (o *gcHostObject) Ref() uint64 {
+	return o.ref
+}
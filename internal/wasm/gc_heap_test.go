@@ -0,0 +1,246 @@
+package wasm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+func TestGCHeap_alloc(t *testing.T) {
+	h := NewGCHeap()
+	c := &CompositeType{Fields: []*FieldType{{Type: ValueTypeI32}}}
+
+	ref := h.alloc(&gcObject{composite: c, values: []uint64{1}})
+	require.Equal(t, uint64(1), ref)
+	require.NotNil(t, h.object(ref))
+	require.Nil(t, h.object(0))
+	require.Nil(t, h.object(2))
+
+	// The slot is reused after the collection with the next generation, so the old reference is invalid.
+	h.collectLocked(nil)
+	require.Nil(t, h.object(ref))
+	newRef := h.alloc(&gcObject{composite: c, values: []uint64{2}})
+	require.Equal(t, uint64(1<<32|1), newRef)
+	require.Nil(t, h.object(ref))
+	require.Equal(t, []uint64{2}, h.object(newRef).values)
+}
+
+func TestGCHeap_collect(t *testing.T) {
+	h := NewGCHeap()
+	leaf := &CompositeType{Fields: []*FieldType{{Type: ValueTypeI32}}}
+	node := &CompositeType{IsArray: true, Fields: []*FieldType{{Type: ValueTypeAnyref, Mutable: true}}}
+
+	// The global refers to the array which refers to the first leaf, and the second leaf is pinned.
+	leaf1 := h.alloc(&gcObject{composite: leaf, values: []uint64{1}})
+	leaf2 := h.alloc(&gcObject{composite: leaf, values: []uint64{2}})
+	garbage := h.alloc(&gcObject{composite: leaf, values: []uint64{3}})
+	array := h.alloc(&gcObject{composite: node, values: []uint64{leaf1, 0}})
+	m := &ModuleInstance{Globals: []*GlobalInstance{
+		{Type: &GlobalType{ValType: ValueTypeI64}, Val: garbage}, // not a reference
+		{Type: &GlobalType{ValType: ValueTypeAnyref}, Val: array},
+	}}
+	h.addModule(m)
+
+	h.pin(leaf2)
+	h.collectLocked(nil)
+	require.NotNil(t, h.object(leaf1))
+	require.NotNil(t, h.object(leaf2))
+	require.NotNil(t, h.object(array))
+	require.Nil(t, h.object(garbage))
+	require.Equal(t, 3, h.live)
+
+	// Nothing is reachable once the module is removed, except the values of the object being allocated.
+	h.removeModule(m)
+	h.unpin(leaf2)
+	h.collectLocked(&gcObject{composite: node, values: []uint64{leaf2}})
+	require.Nil(t, h.object(leaf1))
+	require.Nil(t, h.object(array))
+	require.NotNil(t, h.object(leaf2))
+	require.Equal(t, 1, h.live)
+}
+
+// gcCallEngine is a CallEngine whose stack is the GC roots.
+type gcCallEngine struct {
+	CallEngine
+	stack []uint64
+}
+
+// GCRoots implements the same method as documented on wasm.CallEngine.
+func (ce *gcCallEngine) GCRoots(fn func(uint64)) {
+	for _, v := range ce.stack {
+		fn(v)
+	}
+}
+
+func TestGCHeap_calls(t *testing.T) {
+	h := NewGCHeap()
+	c := &CompositeType{}
+	ft := &FunctionType{Results: []ValueType{ValueTypeAnyref}}
+	allocGarbage := func() {
+		for i := 0; i < gcCollectThreshold; i++ {
+			h.alloc(&gcObject{composite: c})
+		}
+	}
+
+	outer := &gcCallEngine{}
+	h.enter(outer)
+	onStack := h.alloc(&gcObject{composite: c})
+	outer.stack = []uint64{onStack, 0}
+	allocGarbage()
+
+	// The call collects the garbage when it allocates, but not the object on its stack.
+	h.alloc(&gcObject{composite: c})
+	require.NotNil(t, h.object(onStack))
+	require.Equal(t, 1, h.live)
+
+	// The references on the stack of a paused call are kept while another call collects, even if it changes.
+	h.PauseCall(outer)
+	outer.stack[0] = 0
+	inner := &gcCallEngine{}
+	h.enter(inner)
+	result := h.alloc(&gcObject{composite: c})
+	inner.stack = []uint64{result}
+	allocGarbage()
+	require.NotNil(t, h.object(onStack))
+	require.NotNil(t, h.object(result))
+	require.True(t, h.allocated < gcCollectThreshold)
+
+	// No collection while another call is running, as its stack could change.
+	concurrent := &gcCallEngine{}
+	h.enter(concurrent)
+	allocGarbage()
+	allocGarbage()
+	require.True(t, h.allocated > 2*gcCollectThreshold)
+	h.exit(concurrent, &FunctionType{}, nil, nil)
+
+	// The result of the inner call is kept until the next call returns, but the stack of the resumed call is current.
+	results := h.exit(inner, ft, []uint64{result}, nil)
+	h.ResumeCall(outer)
+	h.alloc(&gcObject{composite: c})
+	require.Nil(t, h.object(onStack))
+	require.NotNil(t, h.object(result))
+
+	h.PauseCall(outer)
+	inner.stack = nil
+	h.enter(inner)
+	allocGarbage()
+	h.alloc(&gcObject{composite: c})
+	require.NotNil(t, h.object(result))
+	require.Nil(t, h.exit(inner, ft, []uint64{0}, errors.New("error")))
+	results.release()
+	h.ResumeCall(outer)
+	h.exit(outer, ft, nil, nil)
+	require.Equal(t, 0, len(h.calls))
+	require.Equal(t, 0, h.running)
+	require.Equal(t, 0, len(h.pins))
+	h.collectLocked(nil)
+	require.Nil(t, h.object(result))
+}
+
+func TestGCHeap_exit_exception(t *testing.T) {
+	h := NewGCHeap()
+	ce := &gcCallEngine{}
+	ref := h.alloc(&gcObject{composite: &CompositeType{}})
+	tag := &TagInstance{Type: &FunctionType{Params: []ValueType{ValueTypeI32, ValueTypeAnyref}}}
+
+	// The references in the payload of the exception are pinned like results.
+	h.enter(ce)
+	results := h.exit(ce, &FunctionType{}, nil, api.NewException(tag, ref, ref))
+	require.Equal(t, []uint64{ref}, results.refs)
+	h.collectLocked(nil)
+	require.NotNil(t, h.object(ref))
+
+	results.release()
+	results.release() // Releasing again does nothing.
+	h.collectLocked(nil)
+	require.Nil(t, h.object(ref))
+}
+
+func TestGCHeap_pin(t *testing.T) {
+	h := NewGCHeap()
+	c := &CompositeType{}
+
+	ref := h.alloc(&gcObject{composite: c})
+	require.NotNil(t, h.pin(ref))
+	require.NotNil(t, h.pin(ref))
+	require.Nil(t, h.pin(0))
+
+	// The object is kept until it is unpinned as many times as it was pinned.
+	h.unpin(ref)
+	h.collectLocked(nil)
+	require.NotNil(t, h.object(ref))
+	h.unpin(ref)
+	h.collectLocked(nil)
+	require.Nil(t, h.object(ref))
+	require.Equal(t, 0, len(h.pins))
+}
+
+func TestModuleInstance_ExecuteGC(t *testing.T) {
+	point := &FunctionType{Composite: &CompositeType{Fields: []*FieldType{
+		{Type: ValueTypeI32, Mutable: true},
+		{Type: PackedTypeI16},
+	}}}
+	bytes := &FunctionType{Composite: &CompositeType{IsArray: true, Fields: []*FieldType{
+		{Type: PackedTypeI8, Mutable: true},
+	}}}
+	m := &ModuleInstance{TypeIDs: []FunctionTypeID{0, 1}}
+	m.buildGCTypes(&Module{TypeSection: []*FunctionType{point, bytes}}, NewGCHeap())
+
+	p := m.ExecuteGC(OpcodeGCStructNew, 0, 0, []uint64{1, 0xffff})
+	require.Equal(t, uint64(0xffff), m.ExecuteGC(OpcodeGCStructGetU, 0, 1, []uint64{p}))
+	require.Equal(t, uint64(0xffffffff), m.ExecuteGC(OpcodeGCStructGetS, 0, 1, []uint64{p}))
+	m.ExecuteGC(OpcodeGCStructSet, 0, 0, []uint64{p, 5})
+	require.Equal(t, uint64(5), m.ExecuteGC(OpcodeGCStructGet, 0, 0, []uint64{p}))
+
+	a := m.ExecuteGC(OpcodeGCArrayNewFixed, 1, 3, []uint64{1, 2, 0x103})
+	require.Equal(t, uint64(3), m.ExecuteGC(OpcodeGCArrayLen, 0, 0, []uint64{a}))
+	require.Equal(t, uint64(3), m.ExecuteGC(OpcodeGCArrayGetU, 1, 0, []uint64{a, 2}))
+
+	// The heap type of ref.test and ref.cast is passed as a signed integer.
+	heapType := func(ht HeapType) uint32 { return uint32(int32(ht)) }
+	require.Equal(t, uint64(1), m.ExecuteGC(OpcodeGCRefTest, 0, 0, []uint64{p}))
+	require.Equal(t, uint64(0), m.ExecuteGC(OpcodeGCRefTest, 1, 0, []uint64{p}))
+	require.Equal(t, uint64(1), m.ExecuteGC(OpcodeGCRefTest, heapType(HeapTypeArray), 0, []uint64{a}))
+	require.Equal(t, uint64(0), m.ExecuteGC(OpcodeGCRefTest, heapType(HeapTypeAny), 0, []uint64{0}))
+	require.Equal(t, uint64(1), m.ExecuteGC(OpcodeGCRefTestNull, heapType(HeapTypeNone), 0, []uint64{0}))
+
+	tests := []struct {
+		name        string
+		exec        func()
+		expectedErr error
+	}{
+		{
+			name:        "struct.get null",
+			exec:        func() { m.ExecuteGC(OpcodeGCStructGet, 0, 0, []uint64{0}) },
+			expectedErr: wasmruntime.ErrRuntimeNullReference,
+		},
+		{
+			name:        "array.get out of bounds",
+			exec:        func() { m.ExecuteGC(OpcodeGCArrayGet, 1, 0, []uint64{a, 3}) },
+			expectedErr: wasmruntime.ErrRuntimeOutOfBoundsArrayAccess,
+		},
+		{
+			name:        "array.new too large",
+			exec:        func() { m.ExecuteGC(OpcodeGCArrayNewDefault, 1, 0, []uint64{uint64(maximumArrayLength) + 1}) },
+			expectedErr: wasmruntime.ErrRuntimeArrayTooLarge,
+		},
+		{
+			name:        "ref.cast",
+			exec:        func() { m.ExecuteGC(OpcodeGCRefCast, 1, 0, []uint64{p}) },
+			expectedErr: wasmruntime.ErrRuntimeCastFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				require.Equal(t, tc.expectedErr, recover())
+			}()
+			tc.exec()
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCompositeType_key(t *testing.T) {
+	zero := Index(0)
+	tests := []struct {
+		input    *CompositeType
+		expected string
+	}{
+		{input: &CompositeType{Final: true}, expected: "struct{}"},
+		{
+			input: &CompositeType{Final: true, Fields: []*FieldType{
+				{Type: ValueTypeI32, Mutable: true},
+				{Type: PackedTypeI8},
+			}},
+			expected: "struct{(mut i32),i8}",
+		},
+		{
+			input:    &CompositeType{IsArray: true, Fields: []*FieldType{{Type: ValueTypeAnyref, Ref: &TypedRef{HeapType: 0}}}},
+			expected: "open array{(ref 0)}",
+		},
+		{
+			input:    &CompositeType{Supertype: &zero, Final: true, Fields: []*FieldType{{Type: PackedTypeI16}}},
+			expected: "sub 0 struct{i16}",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.input.key())
+		})
+	}
+}
+
+func TestModule_validateCompositeType(t *testing.T) {
+	zero, one := Index(0), Index(1)
+	base := &FunctionType{Composite: &CompositeType{Fields: []*FieldType{
+		{Type: ValueTypeI32, Mutable: true},
+		{Type: ValueTypeAnyref},
+	}}}
+
+	tests := []struct {
+		name        string
+		types       []*FunctionType
+		expectedErr string
+	}{
+		{
+			name: "subtype",
+			types: []*FunctionType{base, {Composite: &CompositeType{Supertype: &zero, Fields: []*FieldType{
+				{Type: ValueTypeI32, Mutable: true},
+				{Type: ValueTypeAnyref, Ref: &TypedRef{HeapType: HeapTypeStruct}}, // immutable fields are covariant
+				{Type: PackedTypeI8},
+			}}}},
+		},
+		{
+			name: "mutable field",
+			types: []*FunctionType{base, {Composite: &CompositeType{Supertype: &zero, Fields: []*FieldType{
+				{Type: ValueTypeI32},
+				{Type: ValueTypeAnyref},
+			}}}},
+			expectedErr: "field 0 i32 doesn't match (mut i32) of the supertype 0",
+		},
+		{
+			name: "fewer fields",
+			types: []*FunctionType{base, {Composite: &CompositeType{Supertype: &zero, Fields: []*FieldType{
+				{Type: ValueTypeI32, Mutable: true},
+			}}}},
+			expectedErr: "type doesn't match the supertype 0",
+		},
+		{
+			name: "array of struct",
+			types: []*FunctionType{base, {Composite: &CompositeType{Supertype: &zero, IsArray: true, Fields: []*FieldType{
+				{Type: ValueTypeI32, Mutable: true},
+			}}}},
+			expectedErr: "type doesn't match the supertype 0",
+		},
+		{
+			name:        "final",
+			types:       []*FunctionType{{Composite: &CompositeType{Final: true}}, {Composite: &CompositeType{Supertype: &zero}}},
+			expectedErr: "supertype 0 is final",
+		},
+		{
+			name:        "function type",
+			types:       []*FunctionType{{}, {Composite: &CompositeType{Supertype: &zero}}},
+			expectedErr: "supertype 0 must be a struct or an array type",
+		},
+		{
+			name:        "forward",
+			types:       []*FunctionType{base, {Composite: &CompositeType{Supertype: &one}}},
+			expectedErr: "supertype 1 must be defined before the type",
+		},
+		{
+			name:        "v128",
+			types:       []*FunctionType{base, {Composite: &CompositeType{Fields: []*FieldType{{Type: ValueTypeV128}}}}},
+			expectedErr: "field 0: v128 fields are not supported",
+		},
+		{
+			name: "unknown type index",
+			types: []*FunctionType{base, {Composite: &CompositeType{Fields: []*FieldType{
+				{Type: ValueTypeAnyref, Ref: &TypedRef{HeapType: 2}},
+			}}}},
+			expectedErr: "unknown type index 2 of (ref 2)",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&Module{TypeSection: tc.types}).validateCompositeType(1)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
 )
 
@@ -198,7 +199,7 @@ func TestPublicModule_Global(t *testing.T) {
 						Type: &GlobalType{ValType: ValueTypeF32},
 						Init: &ConstantExpression{
 							Opcode: OpcodeF32Const,
-							Data:   u64.LeBytes(api.EncodeF32(1.0)),
+							Data:   u32.LeBytes(uint32(api.EncodeF32(1.0))),
 						},
 					},
 				},
@@ -260,7 +261,7 @@ func TestPublicModule_Global(t *testing.T) {
 						Type: &GlobalType{ValType: ValueTypeF32, Mutable: true},
 						Init: &ConstantExpression{
 							Opcode: OpcodeF32Const,
-							Data:   u64.LeBytes(api.EncodeF32(1.0)),
+							Data:   u32.LeBytes(uint32(api.EncodeF32(1.0))),
 						},
 					},
 				},
@@ -1,10 +1,13 @@
 package wasm
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
 	"sort"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
@@ -110,7 +113,7 @@ func NewHostModule(
 	// Wasm codes for Wasm-implemented host functions) are not available and compiles each time. On the other hand,
 	// compilation of host modules is not costly as it's merely small trampolines vs the real-world native Wasm binary.
 	// TODO: refactor engines so that we can properly cache compiled machine codes for host modules.
-	m.AssignModuleID([]byte(fmt.Sprintf("@@@@@@@@%p", m))) // @@@@@@@@ = any 8 bytes different from Wasm header.
+	m.AssignModuleID([]byte(fmt.Sprintf("@@@@@@@@%p", m)), false, false) // @@@@@@@@ = any 8 bytes different from Wasm header.
 	m.BuildFunctionDefinitions()
 	return
 }
@@ -216,6 +219,74 @@ func addFuncs(
 	return nil
 }
 
+// AddHostExports adds the memories, globals and tables to a module returned by NewHostModule, each exported as the
+// name it is keyed on. Like functions, they are added in order of their names.
+func (m *Module) AddHostExports(nameToMemory map[string]*Memory, nameToGlobal map[string]*Global, nameToTable map[string]*Table) {
+	memoryNames := make([]string, 0, len(nameToMemory))
+	for name := range nameToMemory {
+		memoryNames = append(memoryNames, name)
+	}
+	sort.Strings(memoryNames)
+	for _, name := range memoryNames {
+		idx := Index(len(m.MemorySection))
+		m.MemorySection = append(m.MemorySection, nameToMemory[name])
+		m.ExportSection = append(m.ExportSection, &Export{Type: ExternTypeMemory, Name: name, Index: idx})
+	}
+
+	globalNames := make([]string, 0, len(nameToGlobal))
+	for name := range nameToGlobal {
+		globalNames = append(globalNames, name)
+	}
+	sort.Strings(globalNames)
+	for _, name := range globalNames {
+		idx := Index(len(m.GlobalSection))
+		m.GlobalSection = append(m.GlobalSection, nameToGlobal[name])
+		m.ExportSection = append(m.ExportSection, &Export{Type: ExternTypeGlobal, Name: name, Index: idx})
+	}
+
+	tableNames := make([]string, 0, len(nameToTable))
+	for name := range nameToTable {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+	for _, name := range tableNames {
+		idx := Index(len(m.TableSection))
+		m.TableSection = append(m.TableSection, nameToTable[name])
+		m.ExportSection = append(m.ExportSection, &Export{Type: ExternTypeTable, Name: name, Index: idx})
+	}
+
+	m.BuildMemoryDefinitions()
+	m.BuildTableDefinitions()
+}
+
+// NewHostGlobal returns a global initialized to the Go value, which must be one of int32, uint32, int64, uint64,
+// float32 or float64.
+func NewHostGlobal(v interface{}, mutable bool) (*Global, error) {
+	var valType ValueType
+	var init *ConstantExpression
+	switch v := v.(type) {
+	case int32:
+		valType, init = ValueTypeI32, &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(v)}
+	case uint32:
+		valType, init = ValueTypeI32, &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(int32(v))}
+	case int64:
+		valType, init = ValueTypeI64, &ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(v)}
+	case uint64:
+		valType, init = ValueTypeI64, &ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(int64(v))}
+	case float32:
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, math.Float32bits(v))
+		valType, init = ValueTypeF32, &ConstantExpression{Opcode: OpcodeF32Const, Data: data}
+	case float64:
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, math.Float64bits(v))
+		valType, init = ValueTypeF64, &ConstantExpression{Opcode: OpcodeF64Const, Data: data}
+	default:
+		return nil, fmt.Errorf("unsupported global type %T", v)
+	}
+	return &Global{Type: &GlobalType{ValType: valType, Mutable: mutable}, Init: init}, nil
+}
+
 func (m *Module) maybeAddType(params, results []ValueType, enabledFeatures api.CoreFeatures) (Index, error) {
 	if len(results) > 1 {
 		// Guard >1.0 feature multi-value
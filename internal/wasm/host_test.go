@@ -181,3 +181,36 @@ func TestNewHostModule_Errors(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHostGlobal(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        interface{}
+		expected uint64
+		valType  ValueType
+	}{
+		{name: "int32", v: int32(-1), expected: 0xffffffff, valType: ValueTypeI32},
+		{name: "uint32", v: uint32(0xffffffff), expected: 0xffffffff, valType: ValueTypeI32},
+		{name: "int64", v: int64(-1), expected: 0xffffffffffffffff, valType: ValueTypeI64},
+		{name: "uint64", v: uint64(1 << 63), expected: 1 << 63, valType: ValueTypeI64},
+		{name: "float32", v: float32(1.5), expected: api.EncodeF32(1.5), valType: ValueTypeF32},
+		{name: "float64", v: 1.5, expected: api.EncodeF64(1.5), valType: ValueTypeF64},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewHostGlobal(tc.v, true)
+			require.NoError(t, err)
+			require.Equal(t, &GlobalType{ValType: tc.valType, Mutable: true}, g.Type)
+
+			m := &Module{GlobalSection: []*Global{g}}
+			globals := m.buildGlobals(nil, nil)
+			require.Equal(t, tc.expected, globals[0].Val)
+		})
+	}
+
+	_, err := NewHostGlobal(1, false)
+	require.EqualError(t, err, "unsupported global type int")
+}
@@ -43,6 +43,35 @@ const (
 	OpcodeCall         Opcode = 0x10
 	OpcodeCallIndirect Opcode = 0x11
 
+	// Below are toggled with CoreFeatureTailCall
+
+	OpcodeReturnCall         Opcode = 0x12
+	OpcodeReturnCallIndirect Opcode = 0x13
+
+	// Below are toggled with CoreFeatureExceptionHandling
+
+	// OpcodeTry brackets a sequence of instructions, and is followed by any number of OpcodeCatch, optionally followed
+	// by OpcodeCatchAll, which handle the exceptions thrown in that sequence. Otherwise, it is terminated by
+	// OpcodeDelegate, which handles them as if thrown from the enclosing label.
+	OpcodeTry Opcode = 0x06
+	// OpcodeCatch brackets a sequence of instructions, which handle the exceptions of its tag enclosed by OpcodeTry.
+	OpcodeCatch Opcode = 0x07
+	// OpcodeThrow throws an exception of its tag, whose payload is popped from the stack.
+	OpcodeThrow Opcode = 0x08
+	// OpcodeRethrow throws the exception caught by the label of an OpcodeCatch or OpcodeCatchAll again.
+	OpcodeRethrow Opcode = 0x09
+	// OpcodeDelegate terminates an OpcodeTry, and delegates the exceptions thrown in it to the given label.
+	OpcodeDelegate Opcode = 0x18
+	// OpcodeCatchAll brackets a sequence of instructions, which handle any exception enclosed by OpcodeTry.
+	OpcodeCatchAll Opcode = 0x19
+
+	// Below are toggled with CoreFeatureFunctionReferences
+
+	// OpcodeCallRef calls the function referenced by the typed reference popped from the stack.
+	OpcodeCallRef Opcode = 0x14
+	// OpcodeReturnCallRef is the tail call variant of OpcodeCallRef, which also requires CoreFeatureTailCall.
+	OpcodeReturnCallRef Opcode = 0x15
+
 	// parametric instructions
 
 	OpcodeDrop        Opcode = 0x1a
@@ -251,6 +280,21 @@ const (
 	// Currently, this is only supported in the constant expression in element segments.
 	OpcodeRefFunc = 0xd2
 
+	// Below are toggled with CoreFeatureFunctionReferences
+
+	// OpcodeRefAsNonNull traps if the reference on the top of the stack is null, and otherwise makes it non-nullable.
+	OpcodeRefAsNonNull Opcode = 0xd4
+	// OpcodeBrOnNull branches to the label if the reference on the top of the stack is null, which is dropped on the
+	// branch. Otherwise, the reference is kept on the stack as non-nullable.
+	OpcodeBrOnNull Opcode = 0xd5
+	// OpcodeBrOnNonNull branches to the label with the reference on the top of the stack if it is not null.
+	// Otherwise, the null reference is dropped.
+	OpcodeBrOnNonNull Opcode = 0xd6
+
+	// OpcodeRefEq pops two references, and pushes 1 if they are the same, 0 otherwise.
+	// This is toggled with CoreFeatureGC.
+	OpcodeRefEq Opcode = 0xd3
+
 	// Below are toggled with CoreFeatureSignExtensionOps
 
 	// OpcodeI32Extend8S extends a signed 8-bit integer to a 32-bit integer.
@@ -281,6 +325,14 @@ const (
 	// OpcodeVecPrefix is the prefix of all vector isntructions introduced in
 	// CoreFeatureSIMD.
 	OpcodeVecPrefix Opcode = 0xfd
+
+	// OpcodeAtomicPrefix is the prefix of all atomic instructions introduced in
+	// CoreFeatureThreads.
+	OpcodeAtomicPrefix Opcode = 0xfe
+
+	// OpcodeGCPrefix is the prefix of the instructions on structs, arrays and reference casts introduced in
+	// CoreFeatureGC.
+	OpcodeGCPrefix Opcode = 0xfb
 )
 
 // OpcodeMisc represents opcodes of the miscellaneous operations.
@@ -623,6 +675,188 @@ const (
 	OpcodeVecF64x2PromoteLowF32x4Zero OpcodeVec = 0x5f
 )
 
+// OpcodeVecRelaxed represents an opcode of a relaxed vector instruction, which is prefixed by OpcodeVecPrefix like
+// OpcodeVec. Its actual opcode is 0x100 plus OpcodeVecRelaxed, so the LEB128 encoding is always the two bytes
+// 0x80|OpcodeVecRelaxed and 0x02. See RelaxedVecOpcode.
+//
+// These opcodes are toggled with CoreFeatureRelaxedSIMD.
+type OpcodeVecRelaxed = byte
+
+const (
+	// See https://github.com/WebAssembly/relaxed-simd/blob/main/proposals/relaxed-simd/Overview.md#binary-format
+
+	OpcodeVecI8x16RelaxedSwizzle           OpcodeVecRelaxed = 0x00
+	OpcodeVecI32x4RelaxedTruncF32x4S       OpcodeVecRelaxed = 0x01
+	OpcodeVecI32x4RelaxedTruncF32x4U       OpcodeVecRelaxed = 0x02
+	OpcodeVecI32x4RelaxedTruncF64x2SZero   OpcodeVecRelaxed = 0x03
+	OpcodeVecI32x4RelaxedTruncF64x2UZero   OpcodeVecRelaxed = 0x04
+	OpcodeVecF32x4RelaxedMadd              OpcodeVecRelaxed = 0x05
+	OpcodeVecF32x4RelaxedNmadd             OpcodeVecRelaxed = 0x06
+	OpcodeVecF64x2RelaxedMadd              OpcodeVecRelaxed = 0x07
+	OpcodeVecF64x2RelaxedNmadd             OpcodeVecRelaxed = 0x08
+	OpcodeVecI8x16RelaxedLaneselect        OpcodeVecRelaxed = 0x09
+	OpcodeVecI16x8RelaxedLaneselect        OpcodeVecRelaxed = 0x0a
+	OpcodeVecI32x4RelaxedLaneselect        OpcodeVecRelaxed = 0x0b
+	OpcodeVecI64x2RelaxedLaneselect        OpcodeVecRelaxed = 0x0c
+	OpcodeVecF32x4RelaxedMin               OpcodeVecRelaxed = 0x0d
+	OpcodeVecF32x4RelaxedMax               OpcodeVecRelaxed = 0x0e
+	OpcodeVecF64x2RelaxedMin               OpcodeVecRelaxed = 0x0f
+	OpcodeVecF64x2RelaxedMax               OpcodeVecRelaxed = 0x10
+	OpcodeVecI16x8RelaxedQ15mulrS          OpcodeVecRelaxed = 0x11
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S    OpcodeVecRelaxed = 0x12
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS OpcodeVecRelaxed = 0x13
+)
+
+// RelaxedVecOpcode returns the OpcodeVecRelaxed and true if the opcode following OpcodeVecPrefix at the beginning of
+// body is a relaxed vector instruction, or false otherwise.
+func RelaxedVecOpcode(body []byte) (OpcodeVecRelaxed, bool) {
+	if len(body) < 2 || body[0]&0x80 == 0 || body[1] != 0x02 {
+		return 0, false
+	}
+	return body[0] &^ 0x80, true
+}
+
+const (
+	OpcodeVecI8x16RelaxedSwizzleName           = "i8x16.relaxed_swizzle"
+	OpcodeVecI32x4RelaxedTruncF32x4SName       = "i32x4.relaxed_trunc_f32x4_s"
+	OpcodeVecI32x4RelaxedTruncF32x4UName       = "i32x4.relaxed_trunc_f32x4_u"
+	OpcodeVecI32x4RelaxedTruncF64x2SZeroName   = "i32x4.relaxed_trunc_f64x2_s_zero"
+	OpcodeVecI32x4RelaxedTruncF64x2UZeroName   = "i32x4.relaxed_trunc_f64x2_u_zero"
+	OpcodeVecF32x4RelaxedMaddName              = "f32x4.relaxed_madd"
+	OpcodeVecF32x4RelaxedNmaddName             = "f32x4.relaxed_nmadd"
+	OpcodeVecF64x2RelaxedMaddName              = "f64x2.relaxed_madd"
+	OpcodeVecF64x2RelaxedNmaddName             = "f64x2.relaxed_nmadd"
+	OpcodeVecI8x16RelaxedLaneselectName        = "i8x16.relaxed_laneselect"
+	OpcodeVecI16x8RelaxedLaneselectName        = "i16x8.relaxed_laneselect"
+	OpcodeVecI32x4RelaxedLaneselectName        = "i32x4.relaxed_laneselect"
+	OpcodeVecI64x2RelaxedLaneselectName        = "i64x2.relaxed_laneselect"
+	OpcodeVecF32x4RelaxedMinName               = "f32x4.relaxed_min"
+	OpcodeVecF32x4RelaxedMaxName               = "f32x4.relaxed_max"
+	OpcodeVecF64x2RelaxedMinName               = "f64x2.relaxed_min"
+	OpcodeVecF64x2RelaxedMaxName               = "f64x2.relaxed_max"
+	OpcodeVecI16x8RelaxedQ15mulrSName          = "i16x8.relaxed_q15mulr_s"
+	OpcodeVecI16x8RelaxedDotI8x16I7x16SName    = "i16x8.relaxed_dot_i8x16_i7x16_s"
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName = "i32x4.relaxed_dot_i8x16_i7x16_add_s"
+)
+
+var relaxedVecInstructionNames = [128]string{
+	OpcodeVecI8x16RelaxedSwizzle:           OpcodeVecI8x16RelaxedSwizzleName,
+	OpcodeVecI32x4RelaxedTruncF32x4S:       OpcodeVecI32x4RelaxedTruncF32x4SName,
+	OpcodeVecI32x4RelaxedTruncF32x4U:       OpcodeVecI32x4RelaxedTruncF32x4UName,
+	OpcodeVecI32x4RelaxedTruncF64x2SZero:   OpcodeVecI32x4RelaxedTruncF64x2SZeroName,
+	OpcodeVecI32x4RelaxedTruncF64x2UZero:   OpcodeVecI32x4RelaxedTruncF64x2UZeroName,
+	OpcodeVecF32x4RelaxedMadd:              OpcodeVecF32x4RelaxedMaddName,
+	OpcodeVecF32x4RelaxedNmadd:             OpcodeVecF32x4RelaxedNmaddName,
+	OpcodeVecF64x2RelaxedMadd:              OpcodeVecF64x2RelaxedMaddName,
+	OpcodeVecF64x2RelaxedNmadd:             OpcodeVecF64x2RelaxedNmaddName,
+	OpcodeVecI8x16RelaxedLaneselect:        OpcodeVecI8x16RelaxedLaneselectName,
+	OpcodeVecI16x8RelaxedLaneselect:        OpcodeVecI16x8RelaxedLaneselectName,
+	OpcodeVecI32x4RelaxedLaneselect:        OpcodeVecI32x4RelaxedLaneselectName,
+	OpcodeVecI64x2RelaxedLaneselect:        OpcodeVecI64x2RelaxedLaneselectName,
+	OpcodeVecF32x4RelaxedMin:               OpcodeVecF32x4RelaxedMinName,
+	OpcodeVecF32x4RelaxedMax:               OpcodeVecF32x4RelaxedMaxName,
+	OpcodeVecF64x2RelaxedMin:               OpcodeVecF64x2RelaxedMinName,
+	OpcodeVecF64x2RelaxedMax:               OpcodeVecF64x2RelaxedMaxName,
+	OpcodeVecI16x8RelaxedQ15mulrS:          OpcodeVecI16x8RelaxedQ15mulrSName,
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S:    OpcodeVecI16x8RelaxedDotI8x16I7x16SName,
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS: OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName,
+}
+
+// RelaxedVectorInstructionName returns the instruction name corresponding to the relaxed vector Opcode.
+func RelaxedVectorInstructionName(oc OpcodeVecRelaxed) (ret string) {
+	return relaxedVecInstructionNames[oc]
+}
+
+// OpcodeAtomic represents an opcode of atomic instructions which has
+// multi-byte encoding and is prefixed by OpcodeAtomicPrefix.
+//
+// These opcodes are toggled with CoreFeatureThreads.
+type OpcodeAtomic = byte
+
+const (
+	// See https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md#instruction-summary
+
+	OpcodeAtomicMemoryNotify OpcodeAtomic = 0x00
+	OpcodeAtomicMemoryWait32 OpcodeAtomic = 0x01
+	OpcodeAtomicMemoryWait64 OpcodeAtomic = 0x02
+	OpcodeAtomicFence        OpcodeAtomic = 0x03
+
+	// Loads and stores.
+
+	OpcodeAtomicI32Load    OpcodeAtomic = 0x10
+	OpcodeAtomicI64Load    OpcodeAtomic = 0x11
+	OpcodeAtomicI32Load8U  OpcodeAtomic = 0x12
+	OpcodeAtomicI32Load16U OpcodeAtomic = 0x13
+	OpcodeAtomicI64Load8U  OpcodeAtomic = 0x14
+	OpcodeAtomicI64Load16U OpcodeAtomic = 0x15
+	OpcodeAtomicI64Load32U OpcodeAtomic = 0x16
+	OpcodeAtomicI32Store   OpcodeAtomic = 0x17
+	OpcodeAtomicI64Store   OpcodeAtomic = 0x18
+	OpcodeAtomicI32Store8  OpcodeAtomic = 0x19
+	OpcodeAtomicI32Store16 OpcodeAtomic = 0x1a
+	OpcodeAtomicI64Store8  OpcodeAtomic = 0x1b
+	OpcodeAtomicI64Store16 OpcodeAtomic = 0x1c
+	OpcodeAtomicI64Store32 OpcodeAtomic = 0x1d
+
+	// Read-modify-write operations.
+
+	OpcodeAtomicI32RMWAdd    OpcodeAtomic = 0x1e
+	OpcodeAtomicI64RMWAdd    OpcodeAtomic = 0x1f
+	OpcodeAtomicI32RMW8AddU  OpcodeAtomic = 0x20
+	OpcodeAtomicI32RMW16AddU OpcodeAtomic = 0x21
+	OpcodeAtomicI64RMW8AddU  OpcodeAtomic = 0x22
+	OpcodeAtomicI64RMW16AddU OpcodeAtomic = 0x23
+	OpcodeAtomicI64RMW32AddU OpcodeAtomic = 0x24
+
+	OpcodeAtomicI32RMWSub    OpcodeAtomic = 0x25
+	OpcodeAtomicI64RMWSub    OpcodeAtomic = 0x26
+	OpcodeAtomicI32RMW8SubU  OpcodeAtomic = 0x27
+	OpcodeAtomicI32RMW16SubU OpcodeAtomic = 0x28
+	OpcodeAtomicI64RMW8SubU  OpcodeAtomic = 0x29
+	OpcodeAtomicI64RMW16SubU OpcodeAtomic = 0x2a
+	OpcodeAtomicI64RMW32SubU OpcodeAtomic = 0x2b
+
+	OpcodeAtomicI32RMWAnd    OpcodeAtomic = 0x2c
+	OpcodeAtomicI64RMWAnd    OpcodeAtomic = 0x2d
+	OpcodeAtomicI32RMW8AndU  OpcodeAtomic = 0x2e
+	OpcodeAtomicI32RMW16AndU OpcodeAtomic = 0x2f
+	OpcodeAtomicI64RMW8AndU  OpcodeAtomic = 0x30
+	OpcodeAtomicI64RMW16AndU OpcodeAtomic = 0x31
+	OpcodeAtomicI64RMW32AndU OpcodeAtomic = 0x32
+
+	OpcodeAtomicI32RMWOr    OpcodeAtomic = 0x33
+	OpcodeAtomicI64RMWOr    OpcodeAtomic = 0x34
+	OpcodeAtomicI32RMW8OrU  OpcodeAtomic = 0x35
+	OpcodeAtomicI32RMW16OrU OpcodeAtomic = 0x36
+	OpcodeAtomicI64RMW8OrU  OpcodeAtomic = 0x37
+	OpcodeAtomicI64RMW16OrU OpcodeAtomic = 0x38
+	OpcodeAtomicI64RMW32OrU OpcodeAtomic = 0x39
+
+	OpcodeAtomicI32RMWXor    OpcodeAtomic = 0x3a
+	OpcodeAtomicI64RMWXor    OpcodeAtomic = 0x3b
+	OpcodeAtomicI32RMW8XorU  OpcodeAtomic = 0x3c
+	OpcodeAtomicI32RMW16XorU OpcodeAtomic = 0x3d
+	OpcodeAtomicI64RMW8XorU  OpcodeAtomic = 0x3e
+	OpcodeAtomicI64RMW16XorU OpcodeAtomic = 0x3f
+	OpcodeAtomicI64RMW32XorU OpcodeAtomic = 0x40
+
+	OpcodeAtomicI32RMWXchg    OpcodeAtomic = 0x41
+	OpcodeAtomicI64RMWXchg    OpcodeAtomic = 0x42
+	OpcodeAtomicI32RMW8XchgU  OpcodeAtomic = 0x43
+	OpcodeAtomicI32RMW16XchgU OpcodeAtomic = 0x44
+	OpcodeAtomicI64RMW8XchgU  OpcodeAtomic = 0x45
+	OpcodeAtomicI64RMW16XchgU OpcodeAtomic = 0x46
+	OpcodeAtomicI64RMW32XchgU OpcodeAtomic = 0x47
+
+	OpcodeAtomicI32RMWCmpxchg    OpcodeAtomic = 0x48
+	OpcodeAtomicI64RMWCmpxchg    OpcodeAtomic = 0x49
+	OpcodeAtomicI32RMW8CmpxchgU  OpcodeAtomic = 0x4a
+	OpcodeAtomicI32RMW16CmpxchgU OpcodeAtomic = 0x4b
+	OpcodeAtomicI64RMW8CmpxchgU  OpcodeAtomic = 0x4c
+	OpcodeAtomicI64RMW16CmpxchgU OpcodeAtomic = 0x4d
+	OpcodeAtomicI64RMW32CmpxchgU OpcodeAtomic = 0x4e
+)
+
 const (
 	OpcodeUnreachableName       = "unreachable"
 	OpcodeNopName               = "nop"
@@ -813,8 +1047,27 @@ const (
 	OpcodeI64Extend16SName = "i64.extend16_s"
 	OpcodeI64Extend32SName = "i64.extend32_s"
 
-	OpcodeMiscPrefixName = "misc_prefix"
-	OpcodeVecPrefixName  = "vector_prefix"
+	OpcodeReturnCallName         = "return_call"
+	OpcodeReturnCallIndirectName = "return_call_indirect"
+
+	OpcodeTryName      = "try"
+	OpcodeCatchName    = "catch"
+	OpcodeThrowName    = "throw"
+	OpcodeRethrowName  = "rethrow"
+	OpcodeDelegateName = "delegate"
+	OpcodeCatchAllName = "catch_all"
+
+	OpcodeCallRefName       = "call_ref"
+	OpcodeReturnCallRefName = "return_call_ref"
+	OpcodeRefAsNonNullName  = "ref.as_non_null"
+	OpcodeBrOnNullName      = "br_on_null"
+	OpcodeBrOnNonNullName   = "br_on_non_null"
+	OpcodeRefEqName         = "ref.eq"
+
+	OpcodeMiscPrefixName   = "misc_prefix"
+	OpcodeVecPrefixName    = "vector_prefix"
+	OpcodeAtomicPrefixName = "atomic_prefix"
+	OpcodeGCPrefixName     = "gc_prefix"
 )
 
 var instructionNames = [256]string{
@@ -1007,8 +1260,27 @@ var instructionNames = [256]string{
 	OpcodeI64Extend16S: OpcodeI64Extend16SName,
 	OpcodeI64Extend32S: OpcodeI64Extend32SName,
 
-	OpcodeMiscPrefix: OpcodeMiscPrefixName,
-	OpcodeVecPrefix:  OpcodeVecPrefixName,
+	OpcodeReturnCall:         OpcodeReturnCallName,
+	OpcodeReturnCallIndirect: OpcodeReturnCallIndirectName,
+
+	OpcodeTry:      OpcodeTryName,
+	OpcodeCatch:    OpcodeCatchName,
+	OpcodeThrow:    OpcodeThrowName,
+	OpcodeRethrow:  OpcodeRethrowName,
+	OpcodeDelegate: OpcodeDelegateName,
+	OpcodeCatchAll: OpcodeCatchAllName,
+
+	OpcodeCallRef:       OpcodeCallRefName,
+	OpcodeReturnCallRef: OpcodeReturnCallRefName,
+	OpcodeRefAsNonNull:  OpcodeRefAsNonNullName,
+	OpcodeBrOnNull:      OpcodeBrOnNullName,
+	OpcodeBrOnNonNull:   OpcodeBrOnNonNullName,
+	OpcodeRefEq:         OpcodeRefEqName,
+
+	OpcodeMiscPrefix:   OpcodeMiscPrefixName,
+	OpcodeVecPrefix:    OpcodeVecPrefixName,
+	OpcodeAtomicPrefix: OpcodeAtomicPrefixName,
+	OpcodeGCPrefix:     OpcodeGCPrefixName,
 }
 
 // InstructionName returns the instruction corresponding to this binary Opcode.
@@ -1548,3 +1820,224 @@ var vectorInstructionName = map[OpcodeVec]string{
 func VectorInstructionName(oc OpcodeVec) (ret string) {
 	return vectorInstructionName[oc]
 }
+
+const (
+	OpcodeAtomicMemoryNotifyName     = "memory.atomic.notify"
+	OpcodeAtomicMemoryWait32Name     = "memory.atomic.wait32"
+	OpcodeAtomicMemoryWait64Name     = "memory.atomic.wait64"
+	OpcodeAtomicFenceName            = "atomic.fence"
+	OpcodeAtomicI32LoadName          = "i32.atomic.load"
+	OpcodeAtomicI64LoadName          = "i64.atomic.load"
+	OpcodeAtomicI32Load8UName        = "i32.atomic.load8_u"
+	OpcodeAtomicI32Load16UName       = "i32.atomic.load16_u"
+	OpcodeAtomicI64Load8UName        = "i64.atomic.load8_u"
+	OpcodeAtomicI64Load16UName       = "i64.atomic.load16_u"
+	OpcodeAtomicI64Load32UName       = "i64.atomic.load32_u"
+	OpcodeAtomicI32StoreName         = "i32.atomic.store"
+	OpcodeAtomicI64StoreName         = "i64.atomic.store"
+	OpcodeAtomicI32Store8Name        = "i32.atomic.store8"
+	OpcodeAtomicI32Store16Name       = "i32.atomic.store16"
+	OpcodeAtomicI64Store8Name        = "i64.atomic.store8"
+	OpcodeAtomicI64Store16Name       = "i64.atomic.store16"
+	OpcodeAtomicI64Store32Name       = "i64.atomic.store32"
+	OpcodeAtomicI32RMWAddName        = "i32.atomic.rmw.add"
+	OpcodeAtomicI64RMWAddName        = "i64.atomic.rmw.add"
+	OpcodeAtomicI32RMW8AddUName      = "i32.atomic.rmw8.add_u"
+	OpcodeAtomicI32RMW16AddUName     = "i32.atomic.rmw16.add_u"
+	OpcodeAtomicI64RMW8AddUName      = "i64.atomic.rmw8.add_u"
+	OpcodeAtomicI64RMW16AddUName     = "i64.atomic.rmw16.add_u"
+	OpcodeAtomicI64RMW32AddUName     = "i64.atomic.rmw32.add_u"
+	OpcodeAtomicI32RMWSubName        = "i32.atomic.rmw.sub"
+	OpcodeAtomicI64RMWSubName        = "i64.atomic.rmw.sub"
+	OpcodeAtomicI32RMW8SubUName      = "i32.atomic.rmw8.sub_u"
+	OpcodeAtomicI32RMW16SubUName     = "i32.atomic.rmw16.sub_u"
+	OpcodeAtomicI64RMW8SubUName      = "i64.atomic.rmw8.sub_u"
+	OpcodeAtomicI64RMW16SubUName     = "i64.atomic.rmw16.sub_u"
+	OpcodeAtomicI64RMW32SubUName     = "i64.atomic.rmw32.sub_u"
+	OpcodeAtomicI32RMWAndName        = "i32.atomic.rmw.and"
+	OpcodeAtomicI64RMWAndName        = "i64.atomic.rmw.and"
+	OpcodeAtomicI32RMW8AndUName      = "i32.atomic.rmw8.and_u"
+	OpcodeAtomicI32RMW16AndUName     = "i32.atomic.rmw16.and_u"
+	OpcodeAtomicI64RMW8AndUName      = "i64.atomic.rmw8.and_u"
+	OpcodeAtomicI64RMW16AndUName     = "i64.atomic.rmw16.and_u"
+	OpcodeAtomicI64RMW32AndUName     = "i64.atomic.rmw32.and_u"
+	OpcodeAtomicI32RMWOrName         = "i32.atomic.rmw.or"
+	OpcodeAtomicI64RMWOrName         = "i64.atomic.rmw.or"
+	OpcodeAtomicI32RMW8OrUName       = "i32.atomic.rmw8.or_u"
+	OpcodeAtomicI32RMW16OrUName      = "i32.atomic.rmw16.or_u"
+	OpcodeAtomicI64RMW8OrUName       = "i64.atomic.rmw8.or_u"
+	OpcodeAtomicI64RMW16OrUName      = "i64.atomic.rmw16.or_u"
+	OpcodeAtomicI64RMW32OrUName      = "i64.atomic.rmw32.or_u"
+	OpcodeAtomicI32RMWXorName        = "i32.atomic.rmw.xor"
+	OpcodeAtomicI64RMWXorName        = "i64.atomic.rmw.xor"
+	OpcodeAtomicI32RMW8XorUName      = "i32.atomic.rmw8.xor_u"
+	OpcodeAtomicI32RMW16XorUName     = "i32.atomic.rmw16.xor_u"
+	OpcodeAtomicI64RMW8XorUName      = "i64.atomic.rmw8.xor_u"
+	OpcodeAtomicI64RMW16XorUName     = "i64.atomic.rmw16.xor_u"
+	OpcodeAtomicI64RMW32XorUName     = "i64.atomic.rmw32.xor_u"
+	OpcodeAtomicI32RMWXchgName       = "i32.atomic.rmw.xchg"
+	OpcodeAtomicI64RMWXchgName       = "i64.atomic.rmw.xchg"
+	OpcodeAtomicI32RMW8XchgUName     = "i32.atomic.rmw8.xchg_u"
+	OpcodeAtomicI32RMW16XchgUName    = "i32.atomic.rmw16.xchg_u"
+	OpcodeAtomicI64RMW8XchgUName     = "i64.atomic.rmw8.xchg_u"
+	OpcodeAtomicI64RMW16XchgUName    = "i64.atomic.rmw16.xchg_u"
+	OpcodeAtomicI64RMW32XchgUName    = "i64.atomic.rmw32.xchg_u"
+	OpcodeAtomicI32RMWCmpxchgName    = "i32.atomic.rmw.cmpxchg"
+	OpcodeAtomicI64RMWCmpxchgName    = "i64.atomic.rmw.cmpxchg"
+	OpcodeAtomicI32RMW8CmpxchgUName  = "i32.atomic.rmw8.cmpxchg_u"
+	OpcodeAtomicI32RMW16CmpxchgUName = "i32.atomic.rmw16.cmpxchg_u"
+	OpcodeAtomicI64RMW8CmpxchgUName  = "i64.atomic.rmw8.cmpxchg_u"
+	OpcodeAtomicI64RMW16CmpxchgUName = "i64.atomic.rmw16.cmpxchg_u"
+	OpcodeAtomicI64RMW32CmpxchgUName = "i64.atomic.rmw32.cmpxchg_u"
+)
+
+var atomicInstructionNames = [256]string{
+	OpcodeAtomicMemoryNotify:     OpcodeAtomicMemoryNotifyName,
+	OpcodeAtomicMemoryWait32:     OpcodeAtomicMemoryWait32Name,
+	OpcodeAtomicMemoryWait64:     OpcodeAtomicMemoryWait64Name,
+	OpcodeAtomicFence:            OpcodeAtomicFenceName,
+	OpcodeAtomicI32Load:          OpcodeAtomicI32LoadName,
+	OpcodeAtomicI64Load:          OpcodeAtomicI64LoadName,
+	OpcodeAtomicI32Load8U:        OpcodeAtomicI32Load8UName,
+	OpcodeAtomicI32Load16U:       OpcodeAtomicI32Load16UName,
+	OpcodeAtomicI64Load8U:        OpcodeAtomicI64Load8UName,
+	OpcodeAtomicI64Load16U:       OpcodeAtomicI64Load16UName,
+	OpcodeAtomicI64Load32U:       OpcodeAtomicI64Load32UName,
+	OpcodeAtomicI32Store:         OpcodeAtomicI32StoreName,
+	OpcodeAtomicI64Store:         OpcodeAtomicI64StoreName,
+	OpcodeAtomicI32Store8:        OpcodeAtomicI32Store8Name,
+	OpcodeAtomicI32Store16:       OpcodeAtomicI32Store16Name,
+	OpcodeAtomicI64Store8:        OpcodeAtomicI64Store8Name,
+	OpcodeAtomicI64Store16:       OpcodeAtomicI64Store16Name,
+	OpcodeAtomicI64Store32:       OpcodeAtomicI64Store32Name,
+	OpcodeAtomicI32RMWAdd:        OpcodeAtomicI32RMWAddName,
+	OpcodeAtomicI64RMWAdd:        OpcodeAtomicI64RMWAddName,
+	OpcodeAtomicI32RMW8AddU:      OpcodeAtomicI32RMW8AddUName,
+	OpcodeAtomicI32RMW16AddU:     OpcodeAtomicI32RMW16AddUName,
+	OpcodeAtomicI64RMW8AddU:      OpcodeAtomicI64RMW8AddUName,
+	OpcodeAtomicI64RMW16AddU:     OpcodeAtomicI64RMW16AddUName,
+	OpcodeAtomicI64RMW32AddU:     OpcodeAtomicI64RMW32AddUName,
+	OpcodeAtomicI32RMWSub:        OpcodeAtomicI32RMWSubName,
+	OpcodeAtomicI64RMWSub:        OpcodeAtomicI64RMWSubName,
+	OpcodeAtomicI32RMW8SubU:      OpcodeAtomicI32RMW8SubUName,
+	OpcodeAtomicI32RMW16SubU:     OpcodeAtomicI32RMW16SubUName,
+	OpcodeAtomicI64RMW8SubU:      OpcodeAtomicI64RMW8SubUName,
+	OpcodeAtomicI64RMW16SubU:     OpcodeAtomicI64RMW16SubUName,
+	OpcodeAtomicI64RMW32SubU:     OpcodeAtomicI64RMW32SubUName,
+	OpcodeAtomicI32RMWAnd:        OpcodeAtomicI32RMWAndName,
+	OpcodeAtomicI64RMWAnd:        OpcodeAtomicI64RMWAndName,
+	OpcodeAtomicI32RMW8AndU:      OpcodeAtomicI32RMW8AndUName,
+	OpcodeAtomicI32RMW16AndU:     OpcodeAtomicI32RMW16AndUName,
+	OpcodeAtomicI64RMW8AndU:      OpcodeAtomicI64RMW8AndUName,
+	OpcodeAtomicI64RMW16AndU:     OpcodeAtomicI64RMW16AndUName,
+	OpcodeAtomicI64RMW32AndU:     OpcodeAtomicI64RMW32AndUName,
+	OpcodeAtomicI32RMWOr:         OpcodeAtomicI32RMWOrName,
+	OpcodeAtomicI64RMWOr:         OpcodeAtomicI64RMWOrName,
+	OpcodeAtomicI32RMW8OrU:       OpcodeAtomicI32RMW8OrUName,
+	OpcodeAtomicI32RMW16OrU:      OpcodeAtomicI32RMW16OrUName,
+	OpcodeAtomicI64RMW8OrU:       OpcodeAtomicI64RMW8OrUName,
+	OpcodeAtomicI64RMW16OrU:      OpcodeAtomicI64RMW16OrUName,
+	OpcodeAtomicI64RMW32OrU:      OpcodeAtomicI64RMW32OrUName,
+	OpcodeAtomicI32RMWXor:        OpcodeAtomicI32RMWXorName,
+	OpcodeAtomicI64RMWXor:        OpcodeAtomicI64RMWXorName,
+	OpcodeAtomicI32RMW8XorU:      OpcodeAtomicI32RMW8XorUName,
+	OpcodeAtomicI32RMW16XorU:     OpcodeAtomicI32RMW16XorUName,
+	OpcodeAtomicI64RMW8XorU:      OpcodeAtomicI64RMW8XorUName,
+	OpcodeAtomicI64RMW16XorU:     OpcodeAtomicI64RMW16XorUName,
+	OpcodeAtomicI64RMW32XorU:     OpcodeAtomicI64RMW32XorUName,
+	OpcodeAtomicI32RMWXchg:       OpcodeAtomicI32RMWXchgName,
+	OpcodeAtomicI64RMWXchg:       OpcodeAtomicI64RMWXchgName,
+	OpcodeAtomicI32RMW8XchgU:     OpcodeAtomicI32RMW8XchgUName,
+	OpcodeAtomicI32RMW16XchgU:    OpcodeAtomicI32RMW16XchgUName,
+	OpcodeAtomicI64RMW8XchgU:     OpcodeAtomicI64RMW8XchgUName,
+	OpcodeAtomicI64RMW16XchgU:    OpcodeAtomicI64RMW16XchgUName,
+	OpcodeAtomicI64RMW32XchgU:    OpcodeAtomicI64RMW32XchgUName,
+	OpcodeAtomicI32RMWCmpxchg:    OpcodeAtomicI32RMWCmpxchgName,
+	OpcodeAtomicI64RMWCmpxchg:    OpcodeAtomicI64RMWCmpxchgName,
+	OpcodeAtomicI32RMW8CmpxchgU:  OpcodeAtomicI32RMW8CmpxchgUName,
+	OpcodeAtomicI32RMW16CmpxchgU: OpcodeAtomicI32RMW16CmpxchgUName,
+	OpcodeAtomicI64RMW8CmpxchgU:  OpcodeAtomicI64RMW8CmpxchgUName,
+	OpcodeAtomicI64RMW16CmpxchgU: OpcodeAtomicI64RMW16CmpxchgUName,
+	OpcodeAtomicI64RMW32CmpxchgU: OpcodeAtomicI64RMW32CmpxchgUName,
+}
+
+// AtomicInstructionName returns the instruction name corresponding to the atomic Opcode.
+func AtomicInstructionName(oc OpcodeAtomic) (ret string) {
+	return atomicInstructionNames[oc]
+}
+
+// OpcodeGC represents an opcode of the instructions on structs, arrays and reference casts, which has multi-byte
+// encoding and is prefixed by OpcodeGCPrefix.
+//
+// These opcodes are toggled with CoreFeatureGC.
+type OpcodeGC = byte
+
+const (
+	// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#instructions
+
+	OpcodeGCStructNew        OpcodeGC = 0x00
+	OpcodeGCStructNewDefault OpcodeGC = 0x01
+	OpcodeGCStructGet        OpcodeGC = 0x02
+	OpcodeGCStructGetS       OpcodeGC = 0x03
+	OpcodeGCStructGetU       OpcodeGC = 0x04
+	OpcodeGCStructSet        OpcodeGC = 0x05
+	OpcodeGCArrayNew         OpcodeGC = 0x06
+	OpcodeGCArrayNewDefault  OpcodeGC = 0x07
+	OpcodeGCArrayNewFixed    OpcodeGC = 0x08
+	OpcodeGCArrayGet         OpcodeGC = 0x0b
+	OpcodeGCArrayGetS        OpcodeGC = 0x0c
+	OpcodeGCArrayGetU        OpcodeGC = 0x0d
+	OpcodeGCArraySet         OpcodeGC = 0x0e
+	OpcodeGCArrayLen         OpcodeGC = 0x0f
+	OpcodeGCRefTest          OpcodeGC = 0x14
+	OpcodeGCRefTestNull      OpcodeGC = 0x15
+	OpcodeGCRefCast          OpcodeGC = 0x16
+	OpcodeGCRefCastNull      OpcodeGC = 0x17
+)
+
+const (
+	OpcodeGCStructNewName        = "struct.new"
+	OpcodeGCStructNewDefaultName = "struct.new_default"
+	OpcodeGCStructGetName        = "struct.get"
+	OpcodeGCStructGetSName       = "struct.get_s"
+	OpcodeGCStructGetUName       = "struct.get_u"
+	OpcodeGCStructSetName        = "struct.set"
+	OpcodeGCArrayNewName         = "array.new"
+	OpcodeGCArrayNewDefaultName  = "array.new_default"
+	OpcodeGCArrayNewFixedName    = "array.new_fixed"
+	OpcodeGCArrayGetName         = "array.get"
+	OpcodeGCArrayGetSName        = "array.get_s"
+	OpcodeGCArrayGetUName        = "array.get_u"
+	OpcodeGCArraySetName         = "array.set"
+	OpcodeGCArrayLenName         = "array.len"
+	OpcodeGCRefTestName          = "ref.test"
+	OpcodeGCRefTestNullName      = "ref.test null"
+	OpcodeGCRefCastName          = "ref.cast"
+	OpcodeGCRefCastNullName      = "ref.cast null"
+)
+
+var gcInstructionNames = [256]string{
+	OpcodeGCStructNew:        OpcodeGCStructNewName,
+	OpcodeGCStructNewDefault: OpcodeGCStructNewDefaultName,
+	OpcodeGCStructGet:        OpcodeGCStructGetName,
+	OpcodeGCStructGetS:       OpcodeGCStructGetSName,
+	OpcodeGCStructGetU:       OpcodeGCStructGetUName,
+	OpcodeGCStructSet:        OpcodeGCStructSetName,
+	OpcodeGCArrayNew:         OpcodeGCArrayNewName,
+	OpcodeGCArrayNewDefault:  OpcodeGCArrayNewDefaultName,
+	OpcodeGCArrayNewFixed:    OpcodeGCArrayNewFixedName,
+	OpcodeGCArrayGet:         OpcodeGCArrayGetName,
+	OpcodeGCArrayGetS:        OpcodeGCArrayGetSName,
+	OpcodeGCArrayGetU:        OpcodeGCArrayGetUName,
+	OpcodeGCArraySet:         OpcodeGCArraySetName,
+	OpcodeGCArrayLen:         OpcodeGCArrayLenName,
+	OpcodeGCRefTest:          OpcodeGCRefTestName,
+	OpcodeGCRefTestNull:      OpcodeGCRefTestNullName,
+	OpcodeGCRefCast:          OpcodeGCRefCastName,
+	OpcodeGCRefCastNull:      OpcodeGCRefCastNullName,
+}
+
+// GCInstructionName returns the instruction name corresponding to the GC Opcode.
+func GCInstructionName(oc OpcodeGC) (ret string) {
+	return gcInstructionNames[oc]
+}
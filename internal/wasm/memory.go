@@ -1,14 +1,19 @@
 package wasm
 
 import (
+	"container/list"
 	"encoding/binary"
 	"fmt"
 	"math"
 	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
 )
 
 const (
@@ -19,8 +24,15 @@ const (
 	// MemoryLimitPages is maximum number of pages defined (2^16).
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
 	MemoryLimitPages = uint32(65536)
+	// MemoryLimitPages64 is maximum number of pages of a 64-bit memory (2^31), as defined by the memory64 proposal.
+	// While the proposal allows up to 2^48 pages, 2^31 pages (128Ti) already matches the user address space of hosts.
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+	MemoryLimitPages64 = uint32(1 << 31)
 	// MemoryPageSizeInBits satisfies the relation: "1 << MemoryPageSizeInBits == MemoryPageSize".
 	MemoryPageSizeInBits = 16
+	// MemoryReservationSize is the size of the virtual memory reserved for a memory by NewReservedMemoryInstance. The
+	// effective address of an access to a 32-bit memory is the sum of two 32-bit numbers, so any access ends below it.
+	MemoryReservationSize = uint64(1) << 33
 )
 
 // compile-time check to ensure MemoryInstance implements api.Memory
@@ -34,13 +46,33 @@ var _ api.Memory = &MemoryInstance{}
 type MemoryInstance struct {
 	Buffer        []byte
 	Min, Cap, Max uint32
+	// Shared is true when this memory was declared shared (threads proposal).
+	//
+	// The Buffer of a shared memory never moves while other goroutines access
+	// it, as it is reserved. Only its length changes, atomically. See buffer
+	Shared bool
+	// Is64 is true when this memory is indexed with i64 addresses (memory64 proposal).
+	Is64 bool
+	// reserved is true when Buffer is backed by a reservation of virtual memory of MemoryReservationSize, so that it
+	// never moves. See NewReservedMemoryInstance
+	reserved bool
+	// released is true when the reservation was released. See Release
+	released bool
 	// mux is used to prevent overlapping calls to Grow.
 	mux sync.RWMutex
+	// waitersMux guards waiters.
+	waitersMux sync.Mutex
+	// waiters are the goroutines blocked in memory.atomic.wait32 or
+	// memory.atomic.wait64, keyed by the address they wait on.
+	waiters map[uint64]*list.List
 	// definition is known at compile time.
 	definition api.MemoryDefinition
 }
 
 // NewMemoryInstance creates a new instance based on the parameters in the SectionIDMemory.
+//
+// Note: The Buffer of the result moves when it grows beyond Cap, so a shared memory must be created with
+// NewReservedMemoryInstance instead, unless it isn't grown while accessed concurrently, such as in tests.
 func NewMemoryInstance(memSec *Memory) *MemoryInstance {
 	min := MemoryPagesToBytesNum(memSec.Min)
 	capacity := MemoryPagesToBytesNum(memSec.Cap)
@@ -49,6 +81,86 @@ func NewMemoryInstance(memSec *Memory) *MemoryInstance {
 		Min:    memSec.Min,
 		Cap:    memSec.Cap,
 		Max:    memSec.Max,
+		Shared: memSec.IsShared,
+		Is64:   memSec.Is64,
+	}
+}
+
+// NewReservedMemoryInstance is like NewMemoryInstance, except Buffer is backed by a reservation of virtual memory of
+// MemoryReservationSize, where pages are only committed as the memory grows. This avoids copying on growth, and keeps
+// the slices of Buffer valid as long as the result is reachable.
+//
+// The pages after the ones committed are guard pages: any access out of bounds faults, regardless of the address and
+// offset, so the compiler engine doesn't emit bounds checks for accesses to such a memory, and turns the faults into
+// wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess instead. See ReservedAddress
+//
+// The reservation is only unmapped once the result is unreachable, as calls still running or slices of Buffer might
+// access it after Release.
+func NewReservedMemoryInstance(memSec *Memory) (*MemoryInstance, error) {
+	b, err := platform.ReserveMemory(int(MemoryPagesToBytesNum(memSec.Min)), int(MemoryReservationSize))
+	if err != nil {
+		return nil, fmt.Errorf("reserve memory: %w", err)
+	}
+	m := &MemoryInstance{
+		Buffer:   b,
+		Min:      memSec.Min,
+		Cap:      memSec.Max,
+		Max:      memSec.Max,
+		Shared:   memSec.IsShared,
+		Is64:     memSec.Is64,
+		reserved: true,
+	}
+	runtime.SetFinalizer(m, func(m *MemoryInstance) {
+		_ = platform.ReleaseMemory(m.Buffer)
+	})
+	return m, nil
+}
+
+// ReservedAddress returns true if addr is in the reservation of this memory, committed or not.
+func (m *MemoryInstance) ReservedAddress(addr uintptr) bool {
+	if !m.reserved {
+		return false
+	}
+	base := (*reflect.SliceHeader)(unsafe.Pointer(&m.Buffer)).Data
+	return addr >= base && addr-base < uintptr(MemoryReservationSize)
+}
+
+// Release empties a memory created by NewReservedMemoryInstance when the module defining it is closed, so that it can
+// no longer be read, written or grown through its methods.
+//
+// Note: The reservation isn't unmapped until this is unreachable, because a call might still be running, for example
+// when the module is closed as its context.Context is done, and its compiled code doesn't check the bounds of the
+// memory. Likewise, the slices of Buffer stay valid until then.
+func (m *MemoryInstance) Release() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if !m.reserved || m.released {
+		return
+	}
+	// Buffer keeps the address of the reservation, so that the accesses of the calls still running beyond the pages
+	// committed fault as out of bounds. See ReservedAddress
+	m.setLen(0)
+	m.Cap, m.Max, m.released = 0, 0, true
+}
+
+// buffer returns Buffer. The length of the Buffer of a shared memory is loaded atomically, as other goroutines might
+// grow it concurrently, which doesn't move it. See setLen
+func (m *MemoryInstance) buffer() []byte {
+	if !m.Shared {
+		return m.Buffer
+	}
+	sp := (*reflect.SliceHeader)(unsafe.Pointer(&m.Buffer))
+	return unsafe.Slice((*byte)(unsafe.Pointer(sp.Data)), atomic.LoadUintptr((*uintptr)(unsafe.Pointer(&sp.Len))))
+}
+
+// setLen sets the length of Buffer, within its capacity. This is atomic for a shared memory. See buffer
+func (m *MemoryInstance) setLen(n uint64) {
+	sp := (*reflect.SliceHeader)(unsafe.Pointer(&m.Buffer))
+	if m.Shared {
+		atomic.StoreUintptr((*uintptr)(unsafe.Pointer(&sp.Len)), uintptr(n))
+	} else {
+		sp.Len = int(n)
 	}
 }
 
@@ -59,23 +171,28 @@ func (m *MemoryInstance) Definition() api.MemoryDefinition {
 
 // Size implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Size() uint32 {
+	return uint32(m.size())
+}
+
+// Size64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Size64() uint64 {
 	return m.size()
 }
 
 // ReadByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadByte(offset uint32) (byte, bool) {
-	if offset >= m.size() {
+	if uint64(offset) >= m.size() {
 		return 0, false
 	}
-	return m.Buffer[offset], true
+	return m.buffer()[offset], true
 }
 
 // ReadUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint16Le(offset uint32) (uint16, bool) {
-	if !m.hasSize(offset, 2) {
+	if !m.hasSize(uint64(offset), 2) {
 		return 0, false
 	}
-	return binary.LittleEndian.Uint16(m.Buffer[offset : offset+2]), true
+	return binary.LittleEndian.Uint16(m.buffer()[offset : offset+2]), true
 }
 
 // ReadUint32Le implements the same method as documented on api.Memory.
@@ -108,27 +225,32 @@ func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
 
 // Read implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
+	return m.Read64(uint64(offset), uint64(byteCount))
+}
+
+// Read64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Read64(offset, byteCount uint64) ([]byte, bool) {
 	if !m.hasSize(offset, byteCount) {
 		return nil, false
 	}
-	return m.Buffer[offset : offset+byteCount : offset+byteCount], true
+	return m.buffer()[offset : offset+byteCount : offset+byteCount], true
 }
 
 // WriteByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
-	if offset >= m.size() {
+	if uint64(offset) >= m.size() {
 		return false
 	}
-	m.Buffer[offset] = v
+	m.buffer()[offset] = v
 	return true
 }
 
 // WriteUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
-	if !m.hasSize(offset, 2) {
+	if !m.hasSize(uint64(offset), 2) {
 		return false
 	}
-	binary.LittleEndian.PutUint16(m.Buffer[offset:], v)
+	binary.LittleEndian.PutUint16(m.buffer()[offset:], v)
 	return true
 }
 
@@ -154,19 +276,24 @@ func (m *MemoryInstance) WriteFloat64Le(offset uint32, v float64) bool {
 
 // Write implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
-	if !m.hasSize(offset, uint32(len(val))) {
+	return m.Write64(uint64(offset), val)
+}
+
+// Write64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Write64(offset uint64, val []byte) bool {
+	if !m.hasSize(offset, uint64(len(val))) {
 		return false
 	}
-	copy(m.Buffer[offset:], val)
+	copy(m.buffer()[offset:], val)
 	return true
 }
 
 // WriteString implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteString(offset uint32, val string) bool {
-	if !m.hasSize(offset, uint32(len(val))) {
+	if !m.hasSize(uint64(offset), uint64(len(val))) {
 		return false
 	}
-	copy(m.Buffer[offset:], val)
+	copy(m.buffer()[offset:], val)
 	return true
 }
 
@@ -181,36 +308,63 @@ func (m *MemoryInstance) Grow(delta uint32) (result uint32, ok bool) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	currentPages := memoryBytesNumToPages(uint64(len(m.Buffer)))
+	currentPages := memoryBytesNumToPages(m.size())
 	if delta == 0 {
 		return currentPages, true
 	}
 
 	// If exceeds the max of memory size, we push -1 according to the spec.
-	newPages := currentPages + delta
-	if newPages > m.Max {
+	// uint64 prevents overflow on add, as delta is arbitrary.
+	newPages := uint64(currentPages) + uint64(delta)
+	if newPages > uint64(m.Max) {
 		return 0, false
-	} else if newPages > m.Cap { // grow the memory.
+	} else if newPages > uint64(m.Cap) { // grow the memory.
 		m.Buffer = append(m.Buffer, make([]byte, MemoryPagesToBytesNum(delta))...)
-		m.Cap = newPages
+		m.Cap = uint32(newPages)
+		return currentPages, true
+	} else if m.reserved { // Commit the pages in the reservation.
+		newLen := MemoryPagesToBytesNum(uint32(newPages))
+		if err := platform.CommitMemory(m.Buffer[m.size():newLen]); err != nil {
+			return 0, false
+		}
+		m.setLen(newLen)
 		return currentPages, true
 	} else { // We already have the capacity we need.
-		sp := (*reflect.SliceHeader)(unsafe.Pointer(&m.Buffer))
-		sp.Len = int(MemoryPagesToBytesNum(newPages))
+		m.setLen(MemoryPagesToBytesNum(uint32(newPages)))
 		return currentPages, true
 	}
 }
 
+// shrink reduces the length of the memory to size bytes, zeroing the removed ones as they are exposed again when the
+// memory grows within its capacity.
+func (m *MemoryInstance) shrink(size int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	tail := m.Buffer[size:m.size()]
+	if m.reserved {
+		// Give back the pages, which read as zero when committed again.
+		if err := platform.DecommitMemory(tail); err != nil {
+			panic(err) // Only fails with invalid arguments.
+		}
+	} else {
+		for i := range tail {
+			tail[i] = 0
+		}
+	}
+	m.setLen(uint64(size))
+}
+
 // PageSize returns the current memory buffer size in pages.
 func (m *MemoryInstance) PageSize() (result uint32) {
-	return memoryBytesNumToPages(uint64(len(m.Buffer)))
+	return memoryBytesNumToPages(m.size())
 }
 
 // PagesToUnitOfBytes converts the pages to a human-readable form similar to what's specified. e.g. 1 -> "64Ki"
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instances%E2%91%A0
 func PagesToUnitOfBytes(pages uint32) string {
-	k := pages * 64
+	k := uint64(pages) * 64 // uint64 prevents overflow of pages of a 64-bit memory
 	if k < 1024 {
 		return fmt.Sprintf("%d Ki", k)
 	}
@@ -233,51 +387,288 @@ func memoryBytesNumToPages(bytesNum uint64) (pages uint32) {
 }
 
 // size returns the size in bytes of the buffer.
-func (m *MemoryInstance) size() uint32 {
-	return uint32(len(m.Buffer)) // We don't lock here because size can't become smaller.
+func (m *MemoryInstance) size() uint64 {
+	return uint64(len(m.buffer())) // We don't lock here because size can't become smaller.
 }
 
 // hasSize returns true if Len is sufficient for byteCount at the given offset.
 //
 // Note: This is always fine, because memory can grow, but never shrink.
-func (m *MemoryInstance) hasSize(offset uint32, byteCount uint32) bool {
-	return uint64(offset)+uint64(byteCount) <= uint64(len(m.Buffer)) // uint64 prevents overflow on add
+func (m *MemoryInstance) hasSize(offset, byteCount uint64) bool {
+	size := m.size()
+	return byteCount <= size && offset <= size-byteCount // subtraction prevents overflow of 64-bit offsets
 }
 
 // readUint32Le implements ReadUint32Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint32le.
 func (m *MemoryInstance) readUint32Le(offset uint32) (uint32, bool) {
-	if !m.hasSize(offset, 4) {
+	if !m.hasSize(uint64(offset), 4) {
 		return 0, false
 	}
-	return binary.LittleEndian.Uint32(m.Buffer[offset : offset+4]), true
+	return binary.LittleEndian.Uint32(m.buffer()[offset : offset+4]), true
 }
 
 // readUint64Le implements ReadUint64Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint64le.
 func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
-	if !m.hasSize(offset, 8) {
+	if !m.hasSize(uint64(offset), 8) {
 		return 0, false
 	}
-	return binary.LittleEndian.Uint64(m.Buffer[offset : offset+8]), true
+	return binary.LittleEndian.Uint64(m.buffer()[offset : offset+8]), true
 }
 
 // writeUint32Le implements WriteUint32Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint32le.
 func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
-	if !m.hasSize(offset, 4) {
+	if !m.hasSize(uint64(offset), 4) {
 		return false
 	}
-	binary.LittleEndian.PutUint32(m.Buffer[offset:], v)
+	binary.LittleEndian.PutUint32(m.buffer()[offset:], v)
 	return true
 }
 
 // writeUint64Le implements WriteUint64Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint64le.
 func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
-	if !m.hasSize(offset, 8) {
+	if !m.hasSize(uint64(offset), 8) {
 		return false
 	}
-	binary.LittleEndian.PutUint64(m.Buffer[offset:], v)
+	binary.LittleEndian.PutUint64(m.buffer()[offset:], v)
 	return true
 }
+
+// Below are functions used to implement the atomic instructions of the threads proposal. Callers must ensure the
+// address is in bounds of Buffer and aligned to size, which is the number of bytes accessed: 1, 2, 4 or 8.
+//
+// Note: As all wasm values are little-endian, these assume a little-endian host, which is the case for all platforms
+// supported by the compiler engine.
+
+// AtomicLoad atomically loads the value of size bytes at the given address, zero-extended to 64-bits.
+func (m *MemoryInstance) AtomicLoad(offset uint64, size uint32) uint64 {
+	switch size {
+	case 1, 2:
+		word, shift, mask := m.atomicWord(offset, size)
+		return uint64(atomic.LoadUint32(word)>>shift) & mask
+	case 4:
+		return uint64(atomic.LoadUint32(m.atomicUint32(offset)))
+	default:
+		return atomic.LoadUint64(m.atomicUint64(offset))
+	}
+}
+
+// AtomicStore atomically stores the lower size bytes of v at the given address.
+func (m *MemoryInstance) AtomicStore(offset uint64, size uint32, v uint64) {
+	switch size {
+	case 1, 2:
+		m.AtomicRMW(offset, size, func(uint64) uint64 { return v })
+	case 4:
+		atomic.StoreUint32(m.atomicUint32(offset), uint32(v))
+	default:
+		atomic.StoreUint64(m.atomicUint64(offset), v)
+	}
+}
+
+// AtomicRMW atomically replaces the value of size bytes at the given address with the result of f, and returns the
+// previous value. f may be called multiple times when there's contention.
+func (m *MemoryInstance) AtomicRMW(offset uint64, size uint32, f func(old uint64) uint64) (old uint64) {
+	switch size {
+	case 1, 2:
+		word, shift, mask := m.atomicWord(offset, size)
+		for {
+			w := atomic.LoadUint32(word)
+			old = uint64(w>>shift) & mask
+			replaced := w&^(uint32(mask)<<shift) | (uint32(f(old)&mask) << shift)
+			if atomic.CompareAndSwapUint32(word, w, replaced) {
+				return
+			}
+		}
+	case 4:
+		addr := m.atomicUint32(offset)
+		for {
+			w := atomic.LoadUint32(addr)
+			if atomic.CompareAndSwapUint32(addr, w, uint32(f(uint64(w)))) {
+				return uint64(w)
+			}
+		}
+	default:
+		addr := m.atomicUint64(offset)
+		for {
+			w := atomic.LoadUint64(addr)
+			if atomic.CompareAndSwapUint64(addr, w, f(w)) {
+				return w
+			}
+		}
+	}
+}
+
+// AtomicArithmeticOp is the operation of the read-modify-write atomic instructions, except cmpxchg.
+type AtomicArithmeticOp byte
+
+const (
+	AtomicArithmeticOpAdd AtomicArithmeticOp = iota
+	AtomicArithmeticOpSub
+	AtomicArithmeticOpAnd
+	AtomicArithmeticOpOr
+	AtomicArithmeticOpXor
+	// AtomicArithmeticOpXchg replaces the value with the operand.
+	AtomicArithmeticOpXchg
+)
+
+// String implements fmt.Stringer.
+func (a AtomicArithmeticOp) String() (ret string) {
+	switch a {
+	case AtomicArithmeticOpAdd:
+		ret = "add"
+	case AtomicArithmeticOpSub:
+		ret = "sub"
+	case AtomicArithmeticOpAnd:
+		ret = "and"
+	case AtomicArithmeticOpOr:
+		ret = "or"
+	case AtomicArithmeticOpXor:
+		ret = "xor"
+	case AtomicArithmeticOpXchg:
+		ret = "xchg"
+	}
+	return
+}
+
+// AtomicArithmetic atomically replaces the value of size bytes at the given address with the result of op between
+// it and v, and returns the previous value.
+func (m *MemoryInstance) AtomicArithmetic(offset uint64, size uint32, op AtomicArithmeticOp, v uint64) (old uint64) {
+	return m.AtomicRMW(offset, size, func(old uint64) uint64 {
+		switch op {
+		case AtomicArithmeticOpAdd:
+			return old + v
+		case AtomicArithmeticOpSub:
+			return old - v
+		case AtomicArithmeticOpAnd:
+			return old & v
+		case AtomicArithmeticOpOr:
+			return old | v
+		case AtomicArithmeticOpXor:
+			return old ^ v
+		default: // AtomicArithmeticOpXchg
+			return v
+		}
+	})
+}
+
+// AtomicCompareAndSwap atomically replaces the value of size bytes at the given address with replacement if it
+// equals expected, and returns the previous value. expected is wrapped to size bytes before the comparison.
+func (m *MemoryInstance) AtomicCompareAndSwap(offset uint64, size uint32, expected, replacement uint64) (old uint64) {
+	if size < 8 {
+		expected &= 1<<(size*8) - 1
+	}
+	return m.AtomicRMW(offset, size, func(old uint64) uint64 {
+		if old == expected {
+			return replacement
+		}
+		return old
+	})
+}
+
+// Wait32 implements memory.atomic.wait32. This blocks the caller until it is woken by Notify, or timeout nanoseconds
+// passed, unless the value at the given address doesn't equal expected. A negative timeout means no timeout.
+//
+// The result is 0 when woken, 1 when the value wasn't expected, and 2 on timeout.
+func (m *MemoryInstance) Wait32(offset uint64, expected uint32, timeout int64) uint64 {
+	return m.wait(offset, timeout, func() bool {
+		return atomic.LoadUint32(m.atomicUint32(offset)) == expected
+	})
+}
+
+// Wait64 is like Wait32, except it compares a 64-bit value.
+func (m *MemoryInstance) Wait64(offset uint64, expected uint64, timeout int64) uint64 {
+	return m.wait(offset, timeout, func() bool {
+		return atomic.LoadUint64(m.atomicUint64(offset)) == expected
+	})
+}
+
+// Notify implements memory.atomic.notify, waking up to count waiters on the given address in the order they started
+// waiting. This returns the number of woken waiters.
+func (m *MemoryInstance) Notify(offset uint64, count uint32) uint32 {
+	m.waitersMux.Lock()
+	defer m.waitersMux.Unlock()
+
+	waiters := m.waiters[offset]
+	if waiters == nil {
+		return 0
+	}
+
+	var woken uint32
+	for woken < count && waiters.Len() > 0 {
+		close(waiters.Remove(waiters.Front()).(chan struct{}))
+		woken++
+	}
+	if waiters.Len() == 0 {
+		delete(m.waiters, offset)
+	}
+	return woken
+}
+
+func (m *MemoryInstance) wait(offset uint64, timeout int64, isExpected func() bool) uint64 {
+	m.waitersMux.Lock()
+	// The value is compared while holding the lock, so that a concurrent Notify cannot be missed.
+	if !isExpected() {
+		m.waitersMux.Unlock()
+		return 1
+	}
+
+	if m.waiters == nil {
+		m.waiters = map[uint64]*list.List{}
+	}
+	waiters := m.waiters[offset]
+	if waiters == nil {
+		waiters = list.New()
+		m.waiters[offset] = waiters
+	}
+	woken := make(chan struct{})
+	elem := waiters.PushBack(woken)
+	m.waitersMux.Unlock()
+
+	if timeout < 0 {
+		<-woken
+		return 0
+	}
+
+	timer := time.NewTimer(time.Duration(timeout))
+	defer timer.Stop()
+	select {
+	case <-woken:
+		return 0
+	case <-timer.C:
+	}
+
+	m.waitersMux.Lock()
+	defer m.waitersMux.Unlock()
+	select {
+	case <-woken: // Notify raced with the timer.
+		return 0
+	default:
+		waiters.Remove(elem)
+		if waiters.Len() == 0 {
+			delete(m.waiters, offset)
+		}
+		return 2
+	}
+}
+
+func (m *MemoryInstance) atomicUint32(offset uint64) *uint32 {
+	return (*uint32)(unsafe.Pointer(&m.buffer()[offset]))
+}
+
+func (m *MemoryInstance) atomicUint64(offset uint64) *uint64 {
+	return (*uint64)(unsafe.Pointer(&m.buffer()[offset]))
+}
+
+// atomicWord returns the aligned 32-bit word containing the 8 or 16-bit value at the given address, as well as the
+// shift and mask to extract the value from the word.
+func (m *MemoryInstance) atomicWord(offset uint64, size uint32) (word *uint32, shift uint32, mask uint64) {
+	aligned := offset &^ 3
+	if aligned+4 > uint64(len(m.buffer())) {
+		// Can't happen in practice as the memory size is a multiple of the page size.
+		panic(fmt.Errorf("BUG: word at %d is out of bounds", aligned))
+	}
+	return m.atomicUint32(aligned), uint32(offset-aligned) * 8, 1<<(size*8) - 1
+}
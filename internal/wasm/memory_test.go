@@ -1,11 +1,16 @@
 package wasm
 
 import (
+	"encoding/binary"
 	"math"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
@@ -27,6 +32,58 @@ func TestMemoryBytesNumToPages(t *testing.T) {
 	}
 }
 
+func TestNewReservedMemoryInstance(t *testing.T) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+
+	m, err := NewReservedMemoryInstance(&Memory{Min: 1, Cap: 1, Max: 4})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), m.PageSize())
+	require.Equal(t, uint32(4), m.Cap)
+
+	view, ok := m.Read(0, 8)
+	require.True(t, ok)
+
+	// Growing doesn't move the buffer, so the view stays valid.
+	_, ok = m.Grow(2)
+	require.True(t, ok)
+	require.Equal(t, uint32(3), m.PageSize())
+	require.True(t, m.WriteUint64Le(0, 1))
+	require.Equal(t, uint64(1), binary.LittleEndian.Uint64(view))
+	require.True(t, m.WriteByte(3*MemoryPageSize-1, 1))
+
+	_, ok = m.Grow(2)
+	require.False(t, ok)
+
+	// The pages removed by a shrink read as zero when grown again.
+	m.shrink(int(MemoryPageSize))
+	_, ok = m.Grow(2)
+	require.True(t, ok)
+	b, ok := m.ReadByte(3*MemoryPageSize - 1)
+	require.True(t, ok)
+	require.Equal(t, byte(0), b)
+
+	// The whole reservation is guarded, beyond the maximum of the memory.
+	base := uintptr(unsafe.Pointer(&m.Buffer[0]))
+	require.True(t, m.ReservedAddress(base))
+	require.True(t, m.ReservedAddress(base+uintptr(MemoryReservationSize)-1))
+	require.False(t, m.ReservedAddress(base+uintptr(MemoryReservationSize)))
+	require.False(t, m.ReservedAddress(base-1))
+
+	// Releasing empties the memory, which can't grow anymore, but the reservation stays mapped for the calls still
+	// running and the slices of the memory.
+	view = m.Buffer[:3*MemoryPageSize]
+	m.Release()
+	require.Equal(t, uint32(0), m.PageSize())
+	_, ok = m.Grow(1)
+	require.False(t, ok)
+	require.True(t, m.ReservedAddress(base))
+	view[0] = 1
+	require.Equal(t, byte(1), view[0])
+	m.Release() // Releasing again does nothing.
+}
+
 func TestMemoryInstance_Grow_Size(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -79,6 +136,11 @@ func TestMemoryInstance_Grow_Size(t *testing.T) {
 			// Ensure that the current page size equals the max.
 			require.Equal(t, max, m.PageSize())
 
+			// A delta that overflows the page count in 32-bits must fail, too.
+			_, ok = m.Grow(math.MaxUint32)
+			require.False(t, ok)
+			require.Equal(t, max, m.PageSize())
+
 			if tc.capEqualsMax { // Ensure the capacity isn't more than max.
 				require.Equal(t, maxBytes, uint64(cap(m.Buffer)))
 			} else { // Slice doubles, so it should have a higher capacity than max.
@@ -127,10 +189,15 @@ func TestPagesToUnitOfBytes(t *testing.T) {
 			pages:    MemoryLimitPages,
 			expected: "4 Gi",
 		},
+		{
+			name:     "max memory64",
+			pages:    MemoryLimitPages64,
+			expected: "128 Ti",
+		},
 		{
 			name:     "max uint32",
 			pages:    math.MaxUint32,
-			expected: "3 Ti",
+			expected: "255 Ti",
 		},
 	}
 
@@ -148,7 +215,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		offset      uint32
+		offset      uint64
 		sizeInBytes uint64
 		expected    bool
 	}{
@@ -160,7 +227,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "maximum valid sizeInBytes",
-			offset:      memory.Size() - 8,
+			offset:      memory.Size64() - 8,
 			sizeInBytes: 8,
 			expected:    true,
 		},
@@ -172,7 +239,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "offset exceeds the memory size",
-			offset:      memory.Size(),
+			offset:      memory.Size64(),
 			sizeInBytes: 1, // arbitrary size
 			expected:    false,
 		},
@@ -182,6 +249,12 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 			sizeInBytes: 4,                  // if there's overflow, offset + sizeInBytes is 3, and it may pass the check
 			expected:    false,
 		},
+		{
+			name:        "offset + sizeInBytes overflows in uint64",
+			offset:      math.MaxUint64 - 1, // invalid too large offset of a 64-bit memory
+			sizeInBytes: 4,
+			expected:    false,
+		},
 		{
 			name:        "address.wast:200",
 			offset:      4294967295,
@@ -194,7 +267,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			require.Equal(t, tc.expected, memory.hasSize(tc.offset, uint32(tc.sizeInBytes)))
+			require.Equal(t, tc.expected, memory.hasSize(tc.offset, tc.sizeInBytes))
 		})
 	}
 }
@@ -463,6 +536,24 @@ func TestMemoryInstance_Read(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestMemoryInstance_Read64(t *testing.T) {
+	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 16, 0, 0, 0}, Min: 1, Is64: true}
+
+	buf, ok := mem.Read64(4, 4)
+	require.True(t, ok)
+	require.Equal(t, []byte{16, 0, 0, 0}, buf)
+
+	_, ok = mem.Read64(5, 4)
+	require.False(t, ok)
+
+	// Offsets beyond 32-bits must not wrap.
+	_, ok = mem.Read64(1<<32+4, 4)
+	require.False(t, ok)
+
+	_, ok = mem.Read64(math.MaxUint64, 2)
+	require.False(t, ok)
+}
+
 func TestMemoryInstance_WriteUint16Le(t *testing.T) {
 	memory := &MemoryInstance{Buffer: make([]byte, 100)}
 
@@ -735,6 +826,20 @@ func TestMemoryInstance_Write(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestMemoryInstance_Write64(t *testing.T) {
+	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 16, 0, 0, 0}, Min: 1, Is64: true}
+
+	require.True(t, mem.Write64(4, []byte{16, 0, 0, 4}))
+	require.Equal(t, []byte{0, 0, 0, 0, 16, 0, 0, 4}, mem.Buffer)
+
+	require.False(t, mem.Write64(5, []byte{16, 0, 0, 4}))
+
+	// Offsets beyond 32-bits must not wrap.
+	require.False(t, mem.Write64(1<<32+4, []byte{1}))
+	require.False(t, mem.Write64(math.MaxUint64, []byte{1, 2}))
+	require.Equal(t, []byte{0, 0, 0, 0, 16, 0, 0, 4}, mem.Buffer)
+}
+
 func TestMemoryInstance_WriteString(t *testing.T) {
 	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 16, 0, 0, 0}, Min: 1}
 
@@ -778,3 +883,151 @@ func BenchmarkWriteString(b *testing.B) {
 		})
 	}
 }
+
+func TestNewReservedMemoryInstance_Shared(t *testing.T) {
+	if !platform.MemoryReservationSupported {
+		t.Skip()
+	}
+
+	m, err := NewReservedMemoryInstance(&Memory{Min: 1, Cap: 1, Max: 8, IsMaxEncoded: true, IsShared: true})
+	require.NoError(t, err)
+	defer m.Release()
+
+	// Other goroutines see the growth while it happens, without synchronizing with it.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 7; i++ {
+			_, ok := m.Grow(1)
+			require.True(t, ok)
+		}
+	}()
+	for size := uint32(0); size < 8*MemoryPageSize; size = m.Size() {
+		if size > 0 {
+			require.True(t, m.WriteByte(size-1, 1))
+		}
+	}
+	wg.Wait()
+	require.Equal(t, uint32(8), m.PageSize())
+}
+
+func TestMemoryInstance_Atomics(t *testing.T) {
+	m := &MemoryInstance{Buffer: make([]byte, MemoryPageSize), Shared: true}
+
+	t.Run("load and store", func(t *testing.T) {
+		m.AtomicStore(8, 8, 0x0102030405060708)
+		require.Equal(t, uint64(0x0102030405060708), m.AtomicLoad(8, 8))
+		require.Equal(t, uint64(0x05060708), m.AtomicLoad(8, 4))
+		require.Equal(t, uint64(0x0304), m.AtomicLoad(12, 2))
+		require.Equal(t, uint64(0x02), m.AtomicLoad(14, 1))
+
+		m.AtomicStore(9, 1, 0xffff) // truncated to a byte
+		require.Equal(t, uint64(0x010203040506ff08), m.AtomicLoad(8, 8))
+		m.AtomicStore(12, 4, 0)
+		require.Equal(t, uint64(0x0506ff08), m.AtomicLoad(8, 8))
+	})
+
+	t.Run("rmw", func(t *testing.T) {
+		m.AtomicStore(16, 8, 0)
+		old := m.AtomicRMW(18, 2, func(old uint64) uint64 { return old - 1 })
+		require.Equal(t, uint64(0), old)
+		// Subtraction wraps within the 16-bit value without affecting the neighbors.
+		require.Equal(t, uint64(0xffff_0000), m.AtomicLoad(16, 8))
+	})
+
+	t.Run("arithmetic", func(t *testing.T) {
+		for _, tc := range []struct {
+			op       AtomicArithmeticOp
+			size     uint32
+			v, value uint64
+		}{
+			{op: AtomicArithmeticOpAdd, size: 1, v: 0x1ff, value: 0x0b},  // wraps at 8 bits
+			{op: AtomicArithmeticOpSub, size: 2, v: 0x0d, value: 0xffff}, // wraps at 16 bits
+			{op: AtomicArithmeticOpAnd, size: 4, v: 0x06, value: 0x04},
+			{op: AtomicArithmeticOpOr, size: 8, v: 0x1_0000_0000, value: 0x1_0000_000c},
+			{op: AtomicArithmeticOpXor, size: 4, v: 0x0f, value: 0x03},
+			{op: AtomicArithmeticOpXchg, size: 8, v: 0x2a, value: 0x2a},
+		} {
+			tc := tc
+			t.Run(tc.op.String(), func(t *testing.T) {
+				m.AtomicStore(40, 8, 0x0c)
+				require.Equal(t, uint64(0x0c), m.AtomicArithmetic(40, tc.size, tc.op, tc.v))
+				require.Equal(t, tc.value, m.AtomicLoad(40, 8))
+			})
+		}
+	})
+
+	t.Run("compare and swap", func(t *testing.T) {
+		m.AtomicStore(24, 4, 1)
+		require.Equal(t, uint64(1), m.AtomicCompareAndSwap(24, 4, 2, 3))
+		require.Equal(t, uint64(1), m.AtomicLoad(24, 4))
+		// The expected value is wrapped to the size.
+		require.Equal(t, uint64(1), m.AtomicCompareAndSwap(24, 4, 0xffff_ffff_0000_0001, 3))
+		require.Equal(t, uint64(3), m.AtomicLoad(24, 4))
+	})
+
+	t.Run("concurrent add", func(t *testing.T) {
+		m.AtomicStore(32, 4, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					m.AtomicRMW(33, 1, func(old uint64) uint64 { return old + 1 })
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, uint64(8000%256)<<8, m.AtomicLoad(32, 4))
+	})
+}
+
+func TestMemoryInstance_WaitNotify(t *testing.T) {
+	m := &MemoryInstance{Buffer: make([]byte, MemoryPageSize), Shared: true}
+
+	t.Run("not equal", func(t *testing.T) {
+		require.Equal(t, uint64(1), m.Wait32(0, 1, -1))
+		require.Equal(t, uint64(1), m.Wait64(0, 1, -1))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		require.Equal(t, uint64(2), m.Wait32(0, 0, 0))
+		require.Equal(t, uint64(2), m.Wait64(8, 0, int64(time.Millisecond)))
+		require.Equal(t, 0, len(m.waiters))
+	})
+
+	t.Run("notify without waiters", func(t *testing.T) {
+		require.Equal(t, uint32(0), m.Notify(0, 1))
+	})
+
+	t.Run("woken", func(t *testing.T) {
+		results := make(chan uint64, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				results <- m.Wait32(16, 0, -1)
+			}()
+		}
+
+		// Wait until both goroutines are blocked.
+		for {
+			m.waitersMux.Lock()
+			n := 0
+			if l := m.waiters[16]; l != nil {
+				n = l.Len()
+			}
+			m.waitersMux.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		require.Equal(t, uint32(1), m.Notify(16, 1))
+		require.Equal(t, uint64(0), <-results)
+		require.Equal(t, uint32(1), m.Notify(16, 5))
+		require.Equal(t, uint64(0), <-results)
+		require.Equal(t, 0, len(m.waiters))
+	})
+}
@@ -3,15 +3,16 @@ package wasm
 import (
 	"bytes"
 	"crypto/sha256"
-	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"sort"
 	"strings"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/ieee754"
 	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
@@ -91,16 +92,25 @@ type Module struct {
 	// MemorySection contains each memory defined in this module.
 	//
 	// Note: The memory Index space begins with imported memories and ends with those defined in this module.
-	// For example, if there are two imported memories and one defined in this module, the memory Index 3 is defined in
-	// this module at TableSection[0].
+	// For example, if there are two imported memories and one defined in this module, the memory Index 2 is defined in
+	// this module at MemorySection[0].
 	//
 	// Note: Version 1.0 (20191205) of the WebAssembly spec allows at most one memory definition per module, so the
-	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory.
+	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory. Multiple
+	// memories are only allowed with api.CoreFeatureMultiMemory.
 	//
 	// Note: In the Binary Format, this is SectionIDMemory.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-	MemorySection *Memory
+	// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+	MemorySection []*Memory
+
+	// TagSection contains the index in TypeSection of each tag defined in this module.
+	//
+	// Note: The tag index begins with any imported tags, similar to the function index.
+	// Note: In the Binary Format, this is SectionIDTag, which requires api.CoreFeatureExceptionHandling.
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+	TagSection []Index
 
 	// GlobalSection contains each global defined in this module.
 	//
@@ -180,6 +190,10 @@ type Module struct {
 	// ID is the sha256 value of the source wasm and is used for caching.
 	ID ModuleID
 
+	// ReserveMemory is true if the memories defined by this module are reserved in virtual memory when supported, so
+	// that they grow without moving. The compiled code of the module relies on it. See NewReservedMemoryInstance
+	ReserveMemory bool
+
 	// IsHostModule true if this is the host module, false otherwise.
 	IsHostModule bool
 
@@ -189,6 +203,9 @@ type Module struct {
 	// MemoryDefinitionSection is a wazero-specific section built on Validate.
 	MemoryDefinitionSection []*MemoryDefinition
 
+	// TableDefinitionSection is a wazero-specific section built on Validate.
+	TableDefinitionSection []*TableDefinition
+
 	// DWARFLines is used to emit DWARF based stack trace. This is created from the multiple custom sections
 	// as described in https://yurydelendik.github.io/webassembly-dwarf/, though it is not specified in the Wasm
 	// specification: https://github.com/WebAssembly/debugging/issues/1
@@ -206,9 +223,24 @@ const (
 	MaximumTableIndex    = uint32(1 << 27)
 )
 
-// AssignModuleID calculates a sha256 checksum on `wasm` and set Module.ID to the result.
-func (m *Module) AssignModuleID(wasm []byte) {
-	m.ID = sha256.Sum256(wasm)
+// AssignModuleID calculates a sha256 checksum on `wasm` and set Module.ID to the result. The ID also depends on the
+// flags the module is compiled with, including ReserveMemory.
+//
+// fuelMetering and coverage are included in the checksum, as the functions compiled with them differ from the ones
+// without, so they must not share the compilation cache.
+func (m *Module) AssignModuleID(wasm []byte, fuelMetering, coverage bool) {
+	h := sha256.New()
+	h.Write(wasm)
+	if fuelMetering {
+		h.Write([]byte{1})
+	}
+	if coverage {
+		h.Write([]byte{2})
+	}
+	if m.ReserveMemory {
+		h.Write([]byte{3})
+	}
+	h.Sum(m.ID[:0])
 }
 
 // TypeOfFunction returns the wasm.SectionIDType index for the given function space index or nil.
@@ -247,11 +279,16 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		tp.CacheNumInUint64()
 	}
 
+	m.resolveTypedRefValueTypes()
+	if err := m.validateTypedRefs(); err != nil {
+		return err
+	}
+
 	if err := m.validateStartSection(); err != nil {
 		return err
 	}
 
-	functions, globals, memory, tables, err := m.AllDeclarations()
+	functions, globals, memories, tables, err := m.AllDeclarations()
 	if err != nil {
 		return err
 	}
@@ -264,16 +301,21 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
-	if err = m.validateMemory(memory, globals, enabledFeatures); err != nil {
+	if err = m.validateMemory(memories, globals, enabledFeatures); err != nil {
+		return err
+	}
+
+	tags := m.AllTags()
+	if err = m.validateTags(enabledFeatures, tags); err != nil {
 		return err
 	}
 
-	if err = m.validateExports(enabledFeatures, functions, globals, memory, tables); err != nil {
+	if err = m.validateExports(enabledFeatures, functions, globals, memories, tables); err != nil {
 		return err
 	}
 
 	if m.CodeSection != nil {
-		if err = m.validateFunctions(enabledFeatures, functions, globals, memory, tables, MaximumFunctionIndex); err != nil {
+		if err = m.validateFunctions(enabledFeatures, functions, globals, memories, tables, MaximumFunctionIndex); err != nil {
 			return err
 		}
 	} // No need to validate host functions as NewHostModule validates
@@ -320,7 +362,7 @@ func (m *Module) validateGlobals(globals []*GlobalType, numFuncts, maxGlobals ui
 	return nil
 }
 
-func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []*GlobalType, memory *Memory, tables []*Table, maximumFunctionIndex uint32) error {
+func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []*GlobalType, memories []*Memory, tables []*Table, maximumFunctionIndex uint32) error {
 	if uint32(len(functions)) > maximumFunctionIndex {
 		return fmt.Errorf("too many functions in a store")
 	}
@@ -344,11 +386,13 @@ func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions [
 	for idx, typeIndex := range m.FunctionSection {
 		if typeIndex >= typeCount {
 			return fmt.Errorf("invalid %s: type section index %d out of range", m.funcDesc(SectionIDFunction, Index(idx)), typeIndex)
+		} else if m.TypeSection[typeIndex].Composite != nil {
+			return fmt.Errorf("invalid %s: type %d is not a function type", m.funcDesc(SectionIDFunction, Index(idx)), typeIndex)
 		}
 		if m.CodeSection[idx].GoFunc != nil {
 			continue
 		}
-		if err = m.validateFunction(enabledFeatures, Index(idx), functions, globals, memory, tables, declaredFuncIndexes); err != nil {
+		if err = m.validateFunction(enabledFeatures, Index(idx), functions, globals, memories, tables, declaredFuncIndexes); err != nil {
 			return fmt.Errorf("invalid %s: %w", m.funcDesc(SectionIDFunction, Index(idx)), err)
 		}
 	}
@@ -422,15 +466,17 @@ func (m *Module) funcDesc(sectionID SectionID, sectionIndex Index) string {
 	return fmt.Sprintf("%s[%d] export[%s]", sectionIDName, sectionIndex, strings.Join(exportNames, ","))
 }
 
-func (m *Module) validateMemory(memory *Memory, globals []*GlobalType, _ api.CoreFeatures) error {
-	var activeElementCount int
-	for _, sec := range m.DataSection {
-		if !sec.IsPassive() {
-			activeElementCount++
+func (m *Module) validateMemory(memories []*Memory, globals []*GlobalType, enabledFeatures api.CoreFeatures) error {
+	if len(memories) > 1 {
+		if err := enabledFeatures.RequireEnabled(api.CoreFeatureMultiMemory); err != nil {
+			return fmt.Errorf("multiple memories are invalid as %w", err)
 		}
 	}
-	if activeElementCount > 0 && memory == nil {
-		return fmt.Errorf("unknown memory")
+
+	for _, sec := range m.DataSection {
+		if !sec.IsPassive() && sec.MemoryIndex >= uint32(len(memories)) {
+			return fmt.Errorf("unknown memory")
+		}
 	}
 
 	// Constant expression can only reference imported globals.
@@ -438,7 +484,11 @@ func (m *Module) validateMemory(memory *Memory, globals []*GlobalType, _ api.Cor
 	importedGlobals := globals[:m.ImportGlobalCount()]
 	for _, d := range m.DataSection {
 		if !d.IsPassive() {
-			if err := validateConstExpression(importedGlobals, 0, d.OffsetExpression, ValueTypeI32); err != nil {
+			offsetType := ValueTypeI32
+			if memories[d.MemoryIndex].Is64 {
+				offsetType = ValueTypeI64
+			}
+			if err := validateConstExpression(importedGlobals, 0, d.OffsetExpression, offsetType); err != nil {
 				return fmt.Errorf("calculate offset: %w", err)
 			}
 		}
@@ -449,6 +499,10 @@ func (m *Module) validateMemory(memory *Memory, globals []*GlobalType, _ api.Cor
 func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 	for _, i := range m.ImportSection {
 		switch i.Type {
+		case ExternTypeFunc:
+			if i.DescFunc < uint32(len(m.TypeSection)) && m.TypeSection[i.DescFunc].Composite != nil {
+				return fmt.Errorf("invalid import[%q.%q] function: type %d is not a function type", i.Module, i.Name, i.DescFunc)
+			}
 		case ExternTypeGlobal:
 			if !i.DescGlobal.Mutable {
 				continue
@@ -461,7 +515,7 @@ func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 	return nil
 }
 
-func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []*GlobalType, memory *Memory, tables []*Table) error {
+func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []*GlobalType, memories []*Memory, tables []*Table) error {
 	for _, exp := range m.ExportSection {
 		index := exp.Index
 		switch exp.Type {
@@ -480,83 +534,83 @@ func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []I
 				return fmt.Errorf("invalid export[%q] global[%d]: %w", exp.Name, index, err)
 			}
 		case ExternTypeMemory:
-			if index > 0 || memory == nil {
+			if index >= uint32(len(memories)) {
 				return fmt.Errorf("memory for export[%q] out of range", exp.Name)
 			}
 		case ExternTypeTable:
 			if index >= uint32(len(tables)) {
 				return fmt.Errorf("table for export[%q] out of range", exp.Name)
 			}
+		case ExternTypeTag:
+			if index >= m.ImportTagCount()+uint32(len(m.TagSection)) {
+				return fmt.Errorf("tag for export[%q] out of range", exp.Name)
+			}
 		}
 	}
 	return nil
 }
 
 func validateConstExpression(globals []*GlobalType, numFuncs uint32, expr *ConstantExpression, expectedType ValueType) (err error) {
-	var actualType ValueType
-	switch expr.Opcode {
-	case OpcodeI32Const:
-		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
-		_, _, err = leb128.LoadInt32(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read i32: %w", err)
-		}
-		actualType = ValueTypeI32
-	case OpcodeI64Const:
-		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
-		_, _, err = leb128.LoadInt64(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read i64: %w", err)
-		}
-		actualType = ValueTypeI64
-	case OpcodeF32Const:
-		_, err = ieee754.DecodeFloat32(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read f32: %w", err)
-		}
-		actualType = ValueTypeF32
-	case OpcodeF64Const:
-		_, err = ieee754.DecodeFloat64(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read f64: %w", err)
-		}
-		actualType = ValueTypeF64
-	case OpcodeGlobalGet:
-		id, _, err := leb128.LoadUint32(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read index of global: %w", err)
-		}
-		if uint32(len(globals)) <= id {
-			return fmt.Errorf("global index out of range")
-		}
-		actualType = globals[id].ValType
-	case OpcodeRefNull:
-		if len(expr.Data) == 0 {
-			return fmt.Errorf("read reference type for ref.null: %w", io.ErrShortBuffer)
-		}
-		reftype := expr.Data[0]
-		if reftype != RefTypeFuncref && reftype != RefTypeExternref {
-			return fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
-		}
-		actualType = reftype
-	case OpcodeRefFunc:
-		index, _, err := leb128.LoadUint32(expr.Data)
-		if err != nil {
-			return fmt.Errorf("read i32: %w", err)
-		} else if index >= numFuncs {
-			return fmt.Errorf("ref.func index out of range [%d] with length %d", index, numFuncs-1)
-		}
-		actualType = ValueTypeFuncref
-	case OpcodeVecV128Const:
-		if len(expr.Data) != 16 {
-			return fmt.Errorf("%s needs 16 bytes but was %d bytes", OpcodeVecV128ConstName, len(expr.Data))
+	var stack []ValueType
+	err = expr.forEachInstruction(func(opcode Opcode, immediates []byte) error {
+		switch opcode {
+		case OpcodeI32Const:
+			// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
+			stack = append(stack, ValueTypeI32)
+		case OpcodeI64Const:
+			// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
+			stack = append(stack, ValueTypeI64)
+		case OpcodeF32Const:
+			if _, err := ieee754.DecodeFloat32(immediates); err != nil {
+				return fmt.Errorf("read f32: %w", err)
+			}
+			stack = append(stack, ValueTypeF32)
+		case OpcodeF64Const:
+			if _, err := ieee754.DecodeFloat64(immediates); err != nil {
+				return fmt.Errorf("read f64: %w", err)
+			}
+			stack = append(stack, ValueTypeF64)
+		case OpcodeGlobalGet:
+			id, _, _ := leb128.LoadUint32(immediates)
+			if uint32(len(globals)) <= id {
+				return fmt.Errorf("global index out of range")
+			}
+			stack = append(stack, globals[id].ValType)
+		case OpcodeRefNull:
+			reftype := immediates[0]
+			ht, ok := HeapTypeOfByte(reftype, api.CoreFeaturesV2|api.CoreFeatureGC)
+			if !ok {
+				return fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
+			}
+			stack = append(stack, (&TypedRef{HeapType: ht}).ValueType())
+		case OpcodeRefFunc:
+			index, _, _ := leb128.LoadUint32(immediates)
+			if index >= numFuncs {
+				return fmt.Errorf("ref.func index out of range [%d] with length %d", index, numFuncs-1)
+			}
+			stack = append(stack, ValueTypeFuncref)
+		case OpcodeVecV128Const:
+			stack = append(stack, ValueTypeV128)
+		default: // OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub or OpcodeI64Mul
+			t := ValueTypeI32
+			if opcode >= OpcodeI64Add {
+				t = ValueTypeI64
+			}
+			if len(stack) < 2 || stack[len(stack)-1] != t || stack[len(stack)-2] != t {
+				return fmt.Errorf("%s in const expression requires two %s operands", InstructionName(opcode), ValueTypeName(t))
+			}
+			stack = stack[:len(stack)-1]
 		}
-		actualType = ValueTypeV128
-	default:
-		return fmt.Errorf("invalid opcode for const expression: 0x%x", expr.Opcode)
+		return nil
+	})
+	if err != nil {
+		return
 	}
 
-	if actualType != expectedType {
+	if len(stack) != 1 {
+		return fmt.Errorf("const expression must result in exactly one value but was %d", len(stack))
+	}
+	if actualType := stack[0]; actualType != expectedType {
 		return fmt.Errorf("const expression type mismatch expected %s but got %s",
 			ValueTypeName(expectedType), ValueTypeName(actualType))
 	}
@@ -650,15 +704,50 @@ func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []stri
 	return nil
 }
 
-func (m *Module) buildMemory() (mem *MemoryInstance) {
-	memSec := m.MemorySection
-	if memSec != nil {
-		mem = NewMemoryInstance(memSec)
-		mem.definition = m.MemoryDefinitionSection[0]
+// buildMemories returns the memory index space: the imported memories followed by the ones defined in this module.
+// The defined memories are reserved in virtual memory when ReserveMemory is true or they are shared, and it is
+// supported. See NewReservedMemoryInstance
+//
+// This fails if a shared memory can't be reserved, as it would have to move when it grows, while other goroutines
+// access it, or else be allocated up to its maximum size.
+func (m *Module) buildMemories(importedMemories []*MemoryInstance) (memories []*MemoryInstance, err error) {
+	memories = importedMemories
+	importCount := len(importedMemories)
+	for i, memSec := range m.MemorySection {
+		var mem *MemoryInstance
+		if m.reservesMemory(memSec) {
+			if mem, err = NewReservedMemoryInstance(memSec); err != nil {
+				return nil, err
+			}
+		} else if memSec.IsShared {
+			reason := runtime.GOOS + "/" + runtime.GOARCH
+			if memSec.Is64 {
+				reason = "64-bit memories"
+			}
+			return nil, fmt.Errorf("memory[%d]: shared memories must be reserved in virtual memory, which isn't supported for %s",
+				importCount+i, reason)
+		} else {
+			mem = NewMemoryInstance(memSec)
+		}
+		mem.definition = m.MemoryDefinitionSection[importCount+i]
+		memories = append(memories, mem)
 	}
 	return
 }
 
+// reservesMemory returns true if the memory defined by memSec is reserved in virtual memory. Shared memories are
+// reserved regardless of ReserveMemory, so that they grow without moving, but only commit the pages in use.
+func (m *Module) reservesMemory(memSec *Memory) bool {
+	// 64-bit memories aren't reserved, as their maximum can exceed the address space.
+	return (m.ReserveMemory || memSec.IsShared) && platform.MemoryReservationSupported && !memSec.Is64
+}
+
+// ReservesMemoryZero returns true if the memory of index zero is defined by this module, and reserved in virtual
+// memory, so that the accesses out of its bounds fault. See NewReservedMemoryInstance
+func (m *Module) ReservesMemoryZero() bool {
+	return m.ImportMemoryCount() == 0 && len(m.MemorySection) > 0 && m.reservesMemory(m.MemorySection[0])
+}
+
 // Index is the offset in an index, not necessarily an absolute position in a Module section. This is because
 // indexs are often preceded by a corresponding type in the Module.ImportSection.
 //
@@ -681,6 +770,20 @@ type FunctionType struct {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#result-types%E2%91%A0
 	Results []ValueType
 
+	// ParamRefs are the TypedRef of each parameter, or nil when none of Params are typed references. This is only
+	// used when CoreFeatureFunctionReferences is enabled.
+	//
+	// Note: This doesn't affect the type ID of the function type, which is determined by Params and Results.
+	ParamRefs []*TypedRef
+
+	// ResultRefs are the TypedRef of each result, or nil when none of Results are typed references. This is only
+	// used when CoreFeatureFunctionReferences is enabled.
+	ResultRefs []*TypedRef
+
+	// Composite is non-nil when this is not a function type but a struct or an array type, in which case Params and
+	// Results are empty. This is only used when CoreFeatureGC is enabled.
+	Composite *CompositeType
+
 	// string is cached as it is used both for String and key
 	string string
 
@@ -720,6 +823,9 @@ func (f *FunctionType) EqualsSignature(params []ValueType, results []ValueType)
 func (f *FunctionType) key() string {
 	if f.string != "" {
 		return f.string
+	} else if f.Composite != nil {
+		f.string = f.Composite.key()
+		return f.string
 	}
 	var ret string
 	for _, b := range f.Params {
@@ -761,6 +867,8 @@ type Import struct {
 	DescMem *Memory
 	// DescGlobal is the inlined GlobalType when Type equals ExternTypeGlobal
 	DescGlobal *GlobalType
+	// DescTag is the index in Module.TypeSection when Type equals ExternTypeTag
+	DescTag Index
 }
 
 // Memory describes the limits of pages (64KB) in a memory.
@@ -768,6 +876,13 @@ type Memory struct {
 	Min, Cap, Max uint32
 	// IsMaxEncoded true if the Max is encoded in the original binary.
 	IsMaxEncoded bool
+	// IsShared true if the memory is shared between modules, as defined by
+	// the threads proposal. This requires IsMaxEncoded.
+	IsShared bool
+	// Is64 true if the memory is indexed with i64 addresses instead of i32, as
+	// defined by the memory64 proposal. Only such a memory can exceed
+	// MemoryLimitPages.
+	Is64 bool
 }
 
 // Validate ensures values assigned to Min, Cap and Max are within valid thresholds.
@@ -796,6 +911,9 @@ func (m *Memory) Validate(memoryLimitPages uint32) error {
 type GlobalType struct {
 	ValType ValueType
 	Mutable bool
+	// Ref is the TypedRef of ValType if it is a typed reference, which is only used when
+	// CoreFeatureFunctionReferences is enabled.
+	Ref *TypedRef
 }
 
 type Global struct {
@@ -803,11 +921,89 @@ type Global struct {
 	Init *ConstantExpression
 }
 
+// ConstantExpression is the initializer of a global, or the offset of an element or data segment.
+//
+// Opcode is the first instruction, and Data holds its immediates. When api.CoreFeatureExtendedConst is enabled, Data
+// is followed by the encoding of the remaining instructions, excluding the terminating OpcodeEnd.
 type ConstantExpression struct {
 	Opcode Opcode
 	Data   []byte
 }
 
+// IsExtended returns true if this consists of multiple instructions as per api.CoreFeatureExtendedConst.
+func (c *ConstantExpression) IsExtended() bool {
+	count := 0
+	_ = c.forEachInstruction(func(Opcode, []byte) error {
+		count++
+		return nil
+	})
+	return count > 1
+}
+
+// forEachInstruction calls fn with the opcode and the immediates of each instruction in this expression, and returns
+// the first error from either reading the instructions or fn.
+func (c *ConstantExpression) forEachInstruction(fn func(opcode Opcode, immediates []byte) error) error {
+	opcode, data := c.Opcode, c.Data
+	for {
+		var n uint64
+		var err error
+		switch opcode {
+		case OpcodeI32Const:
+			_, n, err = leb128.LoadInt32(data)
+			if err != nil {
+				return fmt.Errorf("read i32: %w", err)
+			}
+		case OpcodeI64Const:
+			_, n, err = leb128.LoadInt64(data)
+			if err != nil {
+				return fmt.Errorf("read i64: %w", err)
+			}
+		case OpcodeF32Const:
+			if n = 4; len(data) < 4 {
+				return fmt.Errorf("read f32: %w", io.ErrUnexpectedEOF)
+			}
+		case OpcodeF64Const:
+			if n = 8; len(data) < 8 {
+				return fmt.Errorf("read f64: %w", io.ErrUnexpectedEOF)
+			}
+		case OpcodeGlobalGet:
+			_, n, err = leb128.LoadUint32(data)
+			if err != nil {
+				return fmt.Errorf("read index of global: %w", err)
+			}
+		case OpcodeRefNull:
+			if n = 1; len(data) == 0 {
+				return fmt.Errorf("read reference type for ref.null: %w", io.ErrShortBuffer)
+			}
+		case OpcodeRefFunc:
+			_, n, err = leb128.LoadUint32(data)
+			if err != nil {
+				return fmt.Errorf("read i32: %w", err)
+			}
+		case OpcodeVecV128Const:
+			if n = 16; len(data) < 16 {
+				return fmt.Errorf("%s needs 16 bytes but was %d bytes", OpcodeVecV128ConstName, len(data))
+			}
+		case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul:
+		default:
+			return fmt.Errorf("invalid opcode for const expression: 0x%x", opcode)
+		}
+		if err = fn(opcode, data[:n]); err != nil {
+			return err
+		}
+
+		if data = data[n:]; len(data) == 0 {
+			return nil
+		}
+		opcode, data = data[0], data[1:]
+		if opcode == OpcodeVecPrefix && len(data) > 0 && data[0] == OpcodeVecV128Const {
+			opcode, data = OpcodeVecV128Const, data[1:]
+		} else if opcode == OpcodeVecV128Const { // Only valid with OpcodeVecPrefix.
+			return fmt.Errorf("invalid opcode for const expression: 0x%x", opcode)
+		}
+	}
+}
+
 // Export is the binary representation of an export indicated by Type
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-export
 type Export struct {
@@ -837,6 +1033,10 @@ type Code struct {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-local
 	LocalTypes []ValueType
 
+	// LocalRefs are the TypedRef of each of LocalTypes, or nil when none of them are typed references. This is only
+	// used when CoreFeatureFunctionReferences is enabled.
+	LocalRefs []*TypedRef
+
 	// Body is a sequence of expressions ending in OpcodeEnd
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-expr
 	Body []byte
@@ -857,6 +1057,10 @@ type Code struct {
 type DataSegment struct {
 	OffsetExpression *ConstantExpression
 	Init             []byte
+
+	// MemoryIndex is the index of the memory which an active data segment is copied into. This is always zero unless
+	// api.CoreFeatureMultiMemory is enabled.
+	MemoryIndex Index
 }
 
 // IsPassive returns true if this data segment is "passive" in the sense that memory offset and
@@ -940,7 +1144,7 @@ type NameMapAssoc struct {
 }
 
 // AllDeclarations returns all declarations for functions, globals, memories and tables in a module including imported ones.
-func (m *Module) AllDeclarations() (functions []Index, globals []*GlobalType, memory *Memory, tables []*Table, err error) {
+func (m *Module) AllDeclarations() (functions []Index, globals []*GlobalType, memories []*Memory, tables []*Table, err error) {
 	for _, imp := range m.ImportSection {
 		switch imp.Type {
 		case ExternTypeFunc:
@@ -948,7 +1152,7 @@ func (m *Module) AllDeclarations() (functions []Index, globals []*GlobalType, me
 		case ExternTypeGlobal:
 			globals = append(globals, imp.DescGlobal)
 		case ExternTypeMemory:
-			memory = imp.DescMem
+			memories = append(memories, imp.DescMem)
 		case ExternTypeTable:
 			tables = append(tables, imp.DescTable)
 		}
@@ -958,13 +1162,7 @@ func (m *Module) AllDeclarations() (functions []Index, globals []*GlobalType, me
 	for _, g := range m.GlobalSection {
 		globals = append(globals, g.Type)
 	}
-	if m.MemorySection != nil {
-		if memory != nil { // shouldn't be possible due to Validate
-			err = errors.New("at most one table allowed in module")
-			return
-		}
-		memory = m.MemorySection
-	}
+	memories = append(memories, m.MemorySection...)
 	if m.TableSection != nil {
 		tables = append(tables, m.TableSection...)
 	}
@@ -999,6 +1197,11 @@ const (
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-count-section
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/appendix/changes.html#bulk-memory-and-table-instructions
 	SectionIDDataCount
+
+	// SectionIDTag may exist when CoreFeatureExceptionHandling is enabled.
+	//
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+	SectionIDTag
 )
 
 // SectionIDName returns the canonical name of a module section.
@@ -1031,6 +1234,8 @@ func SectionIDName(sectionID SectionID) string {
 		return "data"
 	case SectionIDDataCount:
 		return "data_count"
+	case SectionIDTag:
+		return "tag"
 	}
 	return "unknown"
 }
@@ -1044,24 +1249,22 @@ const (
 	ValueTypeF32 = api.ValueTypeF32
 	ValueTypeF64 = api.ValueTypeF64
 	// TODO: ValueTypeV128 is not exposed in the api pkg yet.
-	ValueTypeV128 ValueType = 0x7b
-	// TODO: ValueTypeFuncref is not exposed in the api pkg yet.
-	ValueTypeFuncref   ValueType = 0x70
+	ValueTypeV128      ValueType = 0x7b
+	ValueTypeFuncref             = api.ValueTypeFuncref
 	ValueTypeExternref           = api.ValueTypeExternref
+	ValueTypeAnyref              = api.ValueTypeAnyref
 )
 
 // ValueTypeName is an alias of api.ValueTypeName defined to simplify imports.
 func ValueTypeName(t ValueType) string {
-	if t == ValueTypeFuncref {
-		return "funcref"
-	} else if t == ValueTypeV128 {
+	if t == ValueTypeV128 {
 		return "v128"
 	}
 	return api.ValueTypeName(t)
 }
 
 func isReferenceValueType(vt ValueType) bool {
-	return vt == ValueTypeExternref || vt == ValueTypeFuncref
+	return vt == ValueTypeExternref || vt == ValueTypeFuncref || vt == ValueTypeAnyref
 }
 
 // ExternType is an alias of api.ExternType defined to simplify imports.
@@ -1076,6 +1279,8 @@ const (
 	ExternTypeMemoryName = api.ExternTypeMemoryName
 	ExternTypeGlobal     = api.ExternTypeGlobal
 	ExternTypeGlobalName = api.ExternTypeGlobalName
+	ExternTypeTag        = api.ExternTypeTag
+	ExternTypeTagName    = api.ExternTypeTagName
 )
 
 // ExternTypeName is an alias of api.ExternTypeName defined to simplify imports.
@@ -3,11 +3,14 @@ package wasm
 import (
 	"fmt"
 	"math"
+	"runtime"
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
 )
 
@@ -86,7 +89,7 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "cap > maxLimit",
 			mem:         &Memory{Min: 2, Cap: math.MaxUint32, Max: 2},
-			expectedErr: "capacity 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "capacity 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max < min",
@@ -96,12 +99,12 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "min > limit",
 			mem:         &Memory{Min: math.MaxUint32},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			mem:         &Memory{Max: math.MaxUint32, IsMaxEncoded: true},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 	}
 
@@ -124,7 +127,7 @@ func TestModule_allDeclarations(t *testing.T) {
 		module            *Module
 		expectedFunctions []Index
 		expectedGlobals   []*GlobalType
-		expectedMemory    *Memory
+		expectedMemories  []*Memory
 		expectedTables    []*Table
 	}{
 		// Functions.
@@ -172,13 +175,20 @@ func TestModule_allDeclarations(t *testing.T) {
 			module: &Module{
 				ImportSection: []*Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
 			},
-			expectedMemory: &Memory{Min: 1, Max: 10},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}},
 		},
 		{
 			module: &Module{
-				MemorySection: &Memory{Min: 100},
+				MemorySection: []*Memory{{Min: 100}},
 			},
-			expectedMemory: &Memory{Min: 100},
+			expectedMemories: []*Memory{{Min: 100}},
+		},
+		{
+			module: &Module{
+				ImportSection: []*Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
+				MemorySection: []*Memory{{Min: 100}, {Min: 200}},
+			},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}, {Min: 100}, {Min: 200}},
 		},
 		// Tables.
 		{
@@ -198,12 +208,12 @@ func TestModule_allDeclarations(t *testing.T) {
 	for i, tt := range tests {
 		tc := tt
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			functions, globals, memory, tables, err := tc.module.AllDeclarations()
+			functions, globals, memories, tables, err := tc.module.AllDeclarations()
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedFunctions, functions)
 			require.Equal(t, tc.expectedGlobals, globals)
 			require.Equal(t, tc.expectedTables, tables)
-			require.Equal(t, tc.expectedMemory, memory)
+			require.Equal(t, tc.expectedMemories, memories)
 		})
 	}
 }
@@ -226,7 +236,7 @@ func TestValidateConstExpression(t *testing.T) {
 					expr.Data = []byte{2}
 					expr.Opcode = OpcodeI64Const
 				case ValueTypeF32:
-					expr.Data = u64.LeBytes(api.EncodeF32(math.MaxFloat32))
+					expr.Data = u32.LeBytes(uint32(api.EncodeF32(math.MaxFloat32)))
 					expr.Opcode = OpcodeF32Const
 				case ValueTypeF64:
 					expr.Data = u64.LeBytes(api.EncodeF64(math.MaxFloat64))
@@ -321,6 +331,84 @@ func TestValidateConstExpression(t *testing.T) {
 			}
 		})
 	})
+	t.Run("extended const", func(t *testing.T) {
+		globals := []*GlobalType{{ValType: ValueTypeI32}, {ValType: ValueTypeI64}}
+		tests := []struct {
+			name         string
+			expr         *ConstantExpression
+			expectedType ValueType
+			expectedErr  string
+		}{
+			{
+				name: "i32",
+				// (i32.add (global.get 0) (i32.mul (i32.const 16) (i32.const 3)))
+				expr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{
+					0, OpcodeI32Const, 16, OpcodeI32Const, 3, OpcodeI32Mul, OpcodeI32Add,
+				}},
+				expectedType: ValueTypeI32,
+			},
+			{
+				name: "i64",
+				// (i64.sub (global.get 1) (i64.const 1))
+				expr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{
+					1, OpcodeI64Const, 1, OpcodeI64Sub,
+				}},
+				expectedType: ValueTypeI64,
+			},
+			{
+				name: "result type mismatch",
+				expr: &ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{
+					1, OpcodeI32Const, 2, OpcodeI32Add,
+				}},
+				expectedType: ValueTypeI64,
+				expectedErr:  "const expression type mismatch expected i64 but got i32",
+			},
+			{
+				name: "operand type mismatch",
+				expr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{
+					1, OpcodeI32Const, 2, OpcodeI32Add,
+				}},
+				expectedType: ValueTypeI32,
+				expectedErr:  "i32.add in const expression requires two i32 operands",
+			},
+			{
+				name: "missing operand",
+				expr: &ConstantExpression{Opcode: OpcodeI64Const, Data: []byte{
+					1, OpcodeI64Mul,
+				}},
+				expectedType: ValueTypeI64,
+				expectedErr:  "i64.mul in const expression requires two i64 operands",
+			},
+			{
+				name: "multiple values",
+				expr: &ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{
+					1, OpcodeI32Const, 2,
+				}},
+				expectedType: ValueTypeI32,
+				expectedErr:  "const expression must result in exactly one value but was 2",
+			},
+			{
+				name: "invalid opcode",
+				expr: &ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{
+					1, OpcodeI32Eqz,
+				}},
+				expectedType: ValueTypeI32,
+				expectedErr:  "invalid opcode for const expression: 0x45",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				err := validateConstExpression(globals, 0, tc.expr, tc.expectedType)
+				if tc.expectedErr != "" {
+					require.EqualError(t, err, tc.expectedErr)
+				} else {
+					require.NoError(t, err)
+				}
+			})
+		}
+	})
 }
 
 func TestModule_Validate_Errors(t *testing.T) {
@@ -548,7 +636,7 @@ func TestModule_validateMemory(t *testing.T) {
 				Opcode: OpcodeUnreachable, // Invalid!
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.EqualError(t, err, "calculate offset: invalid opcode for const expression: 0x0")
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -559,7 +647,29 @@ func TestModule_validateMemory(t *testing.T) {
 				Data:   leb128.EncodeInt32(1),
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
+		require.NoError(t, err)
+	})
+	t.Run("multiple memories disabled", func(t *testing.T) {
+		m := Module{}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2)
+		require.EqualError(t, err, "multiple memories are invalid as feature \"multi-memory\" is disabled")
+	})
+	t.Run("active data segment on unknown memory", func(t *testing.T) {
+		m := Module{DataSection: []*DataSegment{{MemoryIndex: 2, OffsetExpression: &ConstantExpression{}}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|api.CoreFeatureMultiMemory)
+		require.EqualError(t, err, "unknown memory")
+	})
+	t.Run("multiple memories", func(t *testing.T) {
+		m := Module{DataSection: []*DataSegment{{
+			MemoryIndex: 1,
+			Init:        []byte{0x1},
+			OffsetExpression: &ConstantExpression{
+				Opcode: OpcodeI32Const,
+				Data:   leb128.EncodeInt32(1),
+			},
+		}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|api.CoreFeatureMultiMemory)
 		require.NoError(t, err)
 	})
 }
@@ -634,7 +744,7 @@ func TestModule_validateExports(t *testing.T) {
 		exportSection   []*Export
 		functions       []Index
 		globals         []*GlobalType
-		memory          *Memory
+		memory          []*Memory
 		tables          []*Table
 		expectedErr     string
 	}{
@@ -701,7 +811,7 @@ func TestModule_validateExports(t *testing.T) {
 			name:            "memory",
 			enabledFeatures: api.CoreFeaturesV1,
 			exportSection:   []*Export{{Type: ExternTypeMemory, Index: 0}},
-			memory:          &Memory{},
+			memory:          []*Memory{{}},
 		},
 		{
 			name:            "memory out of range",
@@ -833,25 +943,101 @@ func TestModule_buildFunctions(t *testing.T) {
 	}
 }
 
-func TestModule_buildMemoryInstance(t *testing.T) {
+func TestModule_buildMemoryInstances(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		m := Module{}
-		mem := m.buildMemory()
-		require.Nil(t, mem)
+		memories, err := m.buildMemories(nil)
+		require.NoError(t, err)
+		require.Nil(t, memories)
 	})
 	t.Run("non-nil", func(t *testing.T) {
 		min := uint32(1)
 		max := uint32(10)
 		mDef := &MemoryDefinition{moduleName: "foo"}
 		m := Module{
-			MemorySection:           &Memory{Min: min, Cap: min, Max: max},
+			MemorySection:           []*Memory{{Min: min, Cap: min, Max: max}},
 			MemoryDefinitionSection: []*MemoryDefinition{mDef},
 		}
-		mem := m.buildMemory()
+		memories, err := m.buildMemories(nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(memories))
+		mem := memories[0]
 		require.Equal(t, min, mem.Min)
 		require.Equal(t, max, mem.Max)
 		require.Equal(t, mDef, mem.definition)
 	})
+	t.Run("imported and multiple", func(t *testing.T) {
+		imported := &MemoryInstance{}
+		mDefs := []*MemoryDefinition{{index: 0}, {index: 1}, {index: 2}}
+		m := Module{
+			MemorySection:           []*Memory{{Min: 1, Cap: 1, Max: 1}, {Min: 2, Cap: 2, Max: 2}},
+			MemoryDefinitionSection: mDefs,
+		}
+		memories, err := m.buildMemories([]*MemoryInstance{imported})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(memories))
+		require.Equal(t, imported, memories[0])
+		for i, mem := range memories[1:] {
+			require.Equal(t, uint32(i+1), mem.Min)
+			require.Equal(t, mDefs[i+1], mem.definition)
+		}
+	})
+	t.Run("reserved", func(t *testing.T) {
+		m := Module{
+			ReserveMemory: true,
+			MemorySection: []*Memory{
+				{Min: 1, Cap: 1, Max: 10},
+				{Min: 1, Cap: 1, Max: 10, Is64: true},
+				{Min: 0, Cap: 0, Max: 0},
+			},
+			MemoryDefinitionSection: []*MemoryDefinition{{index: 0}, {index: 1}, {index: 2}},
+		}
+		memories, err := m.buildMemories(nil)
+		require.NoError(t, err)
+		require.Equal(t, platform.MemoryReservationSupported, m.ReservesMemoryZero())
+		require.Equal(t, platform.MemoryReservationSupported, memories[0].reserved)
+		require.False(t, memories[1].reserved) // 64-bit memories aren't reserved.
+		require.Equal(t, platform.MemoryReservationSupported, memories[2].reserved)
+		for _, mem := range memories {
+			mem.Release()
+		}
+	})
+	t.Run("shared", func(t *testing.T) {
+		m := Module{
+			MemorySection:           []*Memory{{Min: 1, Cap: 1, Max: 10, IsShared: true}, {Min: 1, Cap: 1, Max: 10}},
+			MemoryDefinitionSection: []*MemoryDefinition{{index: 0}, {index: 1}},
+		}
+		memories, err := m.buildMemories(nil)
+		if !platform.MemoryReservationSupported {
+			require.EqualError(t, err, fmt.Sprintf("memory[0]: shared memories must be reserved in virtual memory, "+
+				"which isn't supported for %s/%s", runtime.GOOS, runtime.GOARCH))
+			return
+		}
+		require.NoError(t, err)
+		// Shared memories are reserved regardless of ReserveMemory.
+		require.True(t, memories[0].reserved)
+		require.False(t, memories[1].reserved)
+		memories[0].Release()
+	})
+	t.Run("shared 64-bit", func(t *testing.T) {
+		m := Module{
+			ImportSection:           []*Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Cap: 1, Max: 1}}},
+			MemorySection:           []*Memory{{Min: 1, Cap: 1, Max: 10, IsShared: true, Is64: true}},
+			MemoryDefinitionSection: []*MemoryDefinition{{index: 0}, {index: 1}},
+		}
+		_, err := m.buildMemories([]*MemoryInstance{{}})
+		require.EqualError(t, err, "memory[1]: shared memories must be reserved in virtual memory, "+
+			"which isn't supported for 64-bit memories")
+	})
+	t.Run("imported memory zero isn't reserved", func(t *testing.T) {
+		m := Module{
+			ReserveMemory:           true,
+			ImportSection:           []*Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Cap: 1, Max: 1}}},
+			MemorySection:           []*Memory{{Min: 1, Cap: 1, Max: 1}},
+			MemoryDefinitionSection: []*MemoryDefinition{{index: 0}, {index: 1}},
+		}
+		require.False(t, m.ReservesMemoryZero())
+	})
 }
 
 func TestModule_validateDataCountSection(t *testing.T) {
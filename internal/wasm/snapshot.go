@@ -0,0 +1,334 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+)
+
+// Snapshot is the mutable state of the globals, memories and tables defined by a ModuleInstance, as well as which of
+// its data and element segments were dropped. The imported ones are excluded, as they belong to other modules.
+//
+// References are held as function indexes plus one, where zero is null, so that a Snapshot can be restored into any
+// instance of the same Module. See ModuleInstance.Snapshot
+type Snapshot struct {
+	// Source is the Module of the instance this was taken from. Its ID must be the one of the Module of the instance
+	// this is restored into.
+	Source *Module
+
+	// Globals are the values of the defined globals, index-correlated with Module.GlobalSection.
+	Globals []SnapshotGlobal
+
+	// Memories are copies of the defined memories, index-correlated with Module.MemorySection.
+	Memories [][]byte
+
+	// Tables are the references of the defined tables, index-correlated with Module.TableSection.
+	Tables [][]uint32
+
+	// DroppedData and DroppedElements are index-correlated with Module.DataSection and Module.ElementSection.
+	DroppedData, DroppedElements []bool
+}
+
+// SnapshotGlobal is the value of a global in a Snapshot, where ValHi is only used by ValueTypeV128.
+type SnapshotGlobal struct {
+	Val, ValHi uint64
+}
+
+// Snapshot captures the state of the definitions of this module, which must not be used by any call meanwhile.
+//
+// This fails when a reference isn't null or a function of this module, as it couldn't be restored, e.g. an externref.
+func (m *ModuleInstance) Snapshot() (*Snapshot, error) {
+	module := m.Source
+	s := &Snapshot{Source: module}
+
+	var funcIdxs map[Reference]Index // Lazily built, as most snapshots have no reference.
+	snapshotRef := func(ref Reference) (uint32, bool) {
+		if ref == 0 {
+			return 0, true
+		}
+		if funcIdxs == nil {
+			funcIdxs = make(map[Reference]Index, len(m.Functions))
+			for i := range m.Functions {
+				funcIdxs[m.Engine.FunctionInstanceReference(Index(i))] = Index(i)
+			}
+		}
+		idx, ok := funcIdxs[ref]
+		return idx + 1, ok
+	}
+
+	globalImportCount := module.importCount(ExternTypeGlobal)
+	for i, g := range m.Globals[globalImportCount:] {
+		v := SnapshotGlobal{Val: g.Val, ValHi: g.ValHi}
+		if isReferenceValueType(g.Type.ValType) {
+			idx, ok := snapshotRef(Reference(g.Val))
+			if !ok {
+				return nil, fmt.Errorf("cannot snapshot global[%d]: not a function of this module", int(globalImportCount)+i)
+			}
+			v.Val = uint64(idx)
+		}
+		s.Globals = append(s.Globals, v)
+	}
+
+	for _, mem := range m.Memories[module.importCount(ExternTypeMemory):] {
+		s.Memories = append(s.Memories, append([]byte{}, mem.Buffer...))
+	}
+
+	tableImportCount := module.importCount(ExternTypeTable)
+	for i, t := range m.Tables[tableImportCount:] {
+		refs := make([]uint32, len(t.References))
+		for j, ref := range t.References {
+			var ok bool
+			if refs[j], ok = snapshotRef(ref); !ok {
+				return nil, fmt.Errorf("cannot snapshot table[%d][%d]: not a function of this module", int(tableImportCount)+i, j)
+			}
+		}
+		s.Tables = append(s.Tables, refs)
+	}
+
+	s.DroppedData = make([]bool, len(m.DataInstances))
+	for i, d := range m.DataInstances {
+		s.DroppedData[i] = module.DataSection[i].IsPassive() && len(d) == 0
+	}
+	s.DroppedElements = make([]bool, len(m.ElementInstances))
+	for i, e := range m.ElementInstances {
+		elem := module.ElementSection[i]
+		s.DroppedElements[i] = elem.Mode == ElementModePassive && elem.Type == RefTypeFuncref &&
+			len(elem.Init) > 0 && len(e.References) == 0
+	}
+	return s, nil
+}
+
+// restore replaces the state of the definitions of this module with the Snapshot, whether it was just instantiated or
+// it is reset. See CallContext.Reset
+func (m *ModuleInstance) restore(s *Snapshot) error {
+	module := m.Source
+	if s.Source.ID != module.ID {
+		return errors.New("snapshot was taken from another module")
+	}
+	globals := m.Globals[module.importCount(ExternTypeGlobal):]
+	memories := m.Memories[module.importCount(ExternTypeMemory):]
+	tables := m.Tables[module.importCount(ExternTypeTable):]
+	if len(s.Globals) != len(globals) || len(s.Memories) != len(memories) || len(s.Tables) != len(tables) ||
+		len(s.DroppedData) != len(m.DataInstances) || len(s.DroppedElements) != len(m.ElementInstances) {
+		return fmt.Errorf("snapshot doesn't match the module: %d globals, %d memories, %d tables, %d data and %d elements, but expected %d, %d, %d, %d and %d",
+			len(s.Globals), len(s.Memories), len(s.Tables), len(s.DroppedData), len(s.DroppedElements),
+			len(globals), len(memories), len(tables), len(m.DataInstances), len(m.ElementInstances))
+	}
+
+	restoreRef := func(idx uint32) (Reference, bool) {
+		if idx == 0 {
+			return 0, true
+		} else if int(idx) > len(m.Functions) {
+			return 0, false
+		}
+		return m.Engine.FunctionInstanceReference(idx - 1), true
+	}
+
+	for i, g := range globals {
+		v := s.Globals[i]
+		if isReferenceValueType(g.Type.ValType) {
+			ref, ok := restoreRef(uint32(v.Val))
+			if !ok {
+				return fmt.Errorf("snapshot of global[%d] references unknown function %d", i, v.Val-1)
+			}
+			v.Val = uint64(ref)
+		}
+		g.Val, g.ValHi = v.Val, v.ValHi
+	}
+
+	for i, mem := range memories {
+		b := s.Memories[i]
+		if len(b)%int(MemoryPageSize) != 0 {
+			return fmt.Errorf("snapshot of memory[%d] has an invalid size %d", i, len(b))
+		}
+		if len(b) < len(mem.Buffer) {
+			mem.shrink(len(b))
+		} else if _, ok := mem.Grow(memoryBytesNumToPages(uint64(len(b) - len(mem.Buffer)))); !ok {
+			return fmt.Errorf("snapshot of memory[%d] exceeds its maximum size", i)
+		}
+		copy(mem.Buffer, b)
+	}
+
+	for i, t := range tables {
+		refs := s.Tables[i]
+		if len(refs) < len(t.References) {
+			t.shrink(len(refs))
+		} else if t.Grow(uint32(len(refs)-len(t.References)), 0) == math.MaxUint32 {
+			return fmt.Errorf("snapshot of table[%d] exceeds its maximum size", i)
+		}
+		for j, idx := range refs {
+			ref, ok := restoreRef(idx)
+			if !ok {
+				return fmt.Errorf("snapshot of table[%d][%d] references unknown function %d", i, j, idx-1)
+			}
+			t.References[j] = ref
+		}
+	}
+
+	// The segments dropped since the snapshot are restored, in case of a reset.
+	for i, dropped := range s.DroppedData {
+		if dropped {
+			m.DataInstances[i] = nil
+		} else {
+			m.DataInstances[i] = module.DataSection[i].Init
+		}
+	}
+	for i, dropped := range s.DroppedElements {
+		if dropped {
+			m.ElementInstances[i].References = nil
+		} else if elem := module.ElementSection[i]; elem.Mode == ElementModePassive && elem.Type == RefTypeFuncref &&
+			len(m.ElementInstances[i].References) != len(elem.Init) {
+			m.ElementInstances[i] = *m.Engine.CreateFuncElementInstance(elem.Init)
+		}
+	}
+	return nil
+}
+
+// PreInitialize returns a copy of Source whose initial state is the one of this Snapshot, and which has no start
+// function, so that it can be encoded as a new binary.
+//
+// The active data and element segments of the defined memories and tables are replaced by ones holding the contents
+// of this Snapshot, while the ones of imported memories and tables are kept, as their state isn't captured.
+func (s *Snapshot) PreInitialize() (*Module, error) {
+	source := s.Source
+	ret := *source
+	ret.StartSection = nil
+
+	globalImportCount := source.importCount(ExternTypeGlobal)
+	ret.GlobalSection = make([]*Global, len(source.GlobalSection))
+	for i, g := range source.GlobalSection {
+		init, err := snapshotGlobalInit(g.Type, s.Globals[i])
+		if err != nil {
+			return nil, fmt.Errorf("global[%d]: %w", int(globalImportCount)+i, err)
+		}
+		ret.GlobalSection[i] = &Global{Type: g.Type, Init: init}
+	}
+
+	memImportCount := source.importCount(ExternTypeMemory)
+	ret.MemorySection = make([]*Memory, len(source.MemorySection))
+	ret.DataSection = make([]*DataSegment, 0, len(source.DataSection))
+	for i, d := range source.DataSection {
+		if (!d.IsPassive() && d.MemoryIndex >= memImportCount) || s.DroppedData[i] {
+			// Keep the index space, as an empty passive segment behaves like a dropped one.
+			d = &DataSegment{}
+		}
+		ret.DataSection = append(ret.DataSection, d)
+	}
+	for i, mem := range source.MemorySection {
+		b := s.Memories[i]
+		memCopy := *mem
+		memCopy.Min = memoryBytesNumToPages(uint64(len(b)))
+		if memCopy.Cap < memCopy.Min {
+			memCopy.Cap = memCopy.Min
+		}
+		ret.MemorySection[i] = &memCopy
+		for _, r := range nonZeroRanges(b) {
+			ret.DataSection = append(ret.DataSection, &DataSegment{
+				OffsetExpression: offsetExpression(uint64(r[0]), mem.Is64),
+				Init:             b[r[0]:r[1]],
+				MemoryIndex:      memImportCount + Index(i),
+			})
+		}
+	}
+	if source.DataCountSection != nil {
+		count := uint32(len(ret.DataSection))
+		ret.DataCountSection = &count
+	}
+
+	tableImportCount := source.importCount(ExternTypeTable)
+	ret.TableSection = make([]*Table, len(source.TableSection))
+	ret.ElementSection = make([]*ElementSegment, 0, len(source.ElementSection))
+	for i, e := range source.ElementSection {
+		if (e.IsActive() && e.TableIndex >= tableImportCount) || s.DroppedElements[i] {
+			// Keep the index space and the function declarations, as a declarative segment behaves like a dropped one.
+			e = &ElementSegment{Init: e.Init, Type: e.Type, Mode: ElementModeDeclarative}
+		}
+		ret.ElementSection = append(ret.ElementSection, e)
+	}
+	for i, t := range source.TableSection {
+		refs := s.Tables[i]
+		tableCopy := *t
+		tableCopy.Min = uint32(len(refs))
+		ret.TableSection[i] = &tableCopy
+		for start := 0; start < len(refs); start++ {
+			if refs[start] == 0 {
+				continue
+			}
+			end := start
+			init := []*Index{}
+			for ; end < len(refs) && refs[end] != 0; end++ {
+				idx := refs[end] - 1
+				init = append(init, &idx)
+			}
+			ret.ElementSection = append(ret.ElementSection, &ElementSegment{
+				OffsetExpr: offsetExpression(uint64(start), false),
+				TableIndex: tableImportCount + Index(i),
+				Init:       init,
+				Type:       t.Type,
+				Mode:       ElementModeActive,
+			})
+			start = end
+		}
+	}
+	return &ret, nil
+}
+
+// snapshotGlobalInit returns the constant expression which initializes a global of the given type to the value.
+func snapshotGlobalInit(gt *GlobalType, v SnapshotGlobal) (*ConstantExpression, error) {
+	switch gt.ValType {
+	case ValueTypeI32:
+		return &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(int32(v.Val))}, nil
+	case ValueTypeI64:
+		return &ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(int64(v.Val))}, nil
+	case ValueTypeF32:
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, uint32(v.Val))
+		return &ConstantExpression{Opcode: OpcodeF32Const, Data: data}, nil
+	case ValueTypeF64:
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, v.Val)
+		return &ConstantExpression{Opcode: OpcodeF64Const, Data: data}, nil
+	case ValueTypeV128:
+		data := make([]byte, 16)
+		binary.LittleEndian.PutUint64(data, v.Val)
+		binary.LittleEndian.PutUint64(data[8:], v.ValHi)
+		return &ConstantExpression{Opcode: OpcodeVecV128Const, Data: data}, nil
+	}
+	if gt.Ref != nil {
+		return nil, errors.New("cannot pre-initialize a typed reference")
+	} else if v.Val == 0 {
+		return &ConstantExpression{Opcode: OpcodeRefNull, Data: []byte{gt.ValType}}, nil
+	}
+	return &ConstantExpression{Opcode: OpcodeRefFunc, Data: leb128.EncodeUint32(uint32(v.Val - 1))}, nil
+}
+
+// offsetExpression returns the constant expression of the offset of an active segment.
+func offsetExpression(offset uint64, is64 bool) *ConstantExpression {
+	if is64 {
+		return &ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(int64(offset))}
+	}
+	return &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(int32(offset))}
+}
+
+// minZeroGap is the minimum count of zeros which splits the data segments of a memory, as the header of a segment
+// costs a few bytes.
+const minZeroGap = 8
+
+// nonZeroRanges returns the [start, end) ranges of the non-zero bytes, merging the ones separated by less than
+// minZeroGap zeros.
+func nonZeroRanges(b []byte) (ranges [][2]int) {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && i-ranges[n-1][1] < minZeroGap {
+			ranges[n-1][1] = i + 1
+		} else {
+			ranges = append(ranges, [2]int{i, i + 1})
+		}
+	}
+	return
+}
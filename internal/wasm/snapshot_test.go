@@ -0,0 +1,110 @@
+package wasm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_nonZeroRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected [][2]int
+	}{
+		{name: "empty"},
+		{name: "zeros", input: make([]byte, 16)},
+		{name: "all", input: []byte{1, 2, 3}, expected: [][2]int{{0, 3}}},
+		{
+			name:     "short gap merged",
+			input:    []byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 2, 0},
+			expected: [][2]int{{1, 10}},
+		},
+		{
+			name:     "long gap split",
+			input:    []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+			expected: [][2]int{{0, 1}, {9, 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, nonZeroRanges(tc.input))
+		})
+	}
+}
+
+func Test_snapshotGlobalInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		gt       *GlobalType
+		v        SnapshotGlobal
+		expected *ConstantExpression
+		expErr   string
+	}{
+		{
+			name:     "i32",
+			gt:       &GlobalType{ValType: ValueTypeI32},
+			v:        SnapshotGlobal{Val: uint64(math.MaxUint32)},
+			expected: &ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{0x7f}},
+		},
+		{
+			name:     "i64",
+			gt:       &GlobalType{ValType: ValueTypeI64},
+			v:        SnapshotGlobal{Val: 64},
+			expected: &ConstantExpression{Opcode: OpcodeI64Const, Data: []byte{0xc0, 0x00}},
+		},
+		{
+			name:     "f32",
+			gt:       &GlobalType{ValType: ValueTypeF32},
+			v:        SnapshotGlobal{Val: uint64(math.Float32bits(1))},
+			expected: &ConstantExpression{Opcode: OpcodeF32Const, Data: []byte{0, 0, 0x80, 0x3f}},
+		},
+		{
+			name:     "f64",
+			gt:       &GlobalType{ValType: ValueTypeF64},
+			v:        SnapshotGlobal{Val: math.Float64bits(1)},
+			expected: &ConstantExpression{Opcode: OpcodeF64Const, Data: []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		},
+		{
+			name: "v128",
+			gt:   &GlobalType{ValType: ValueTypeV128},
+			v:    SnapshotGlobal{Val: 1, ValHi: 2},
+			expected: &ConstantExpression{
+				Opcode: OpcodeVecV128Const,
+				Data:   []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			name:     "null funcref",
+			gt:       &GlobalType{ValType: ValueTypeFuncref},
+			expected: &ConstantExpression{Opcode: OpcodeRefNull, Data: []byte{ValueTypeFuncref}},
+		},
+		{
+			name:     "funcref",
+			gt:       &GlobalType{ValType: ValueTypeFuncref},
+			v:        SnapshotGlobal{Val: 3},
+			expected: &ConstantExpression{Opcode: OpcodeRefFunc, Data: []byte{2}},
+		},
+		{
+			name:   "typed reference",
+			gt:     &GlobalType{ValType: ValueTypeFuncref, Ref: &TypedRef{HeapType: HeapTypeFunc}},
+			expErr: "cannot pre-initialize a typed reference",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := snapshotGlobalInit(tc.gt, tc.v)
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
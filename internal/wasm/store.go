@@ -43,6 +43,14 @@ type (
 		// do type-checks on indirect function calls.
 		typeIDs map[string]FunctionTypeID
 
+		// GCHeap holds the structs and arrays allocated by any module, which is only non-nil when
+		// api.CoreFeatureGC is enabled.
+		GCHeap *GCHeap
+
+		// InterruptOnContextDone is true if the calls whose context.Context is done fail with
+		// wasmruntime.ErrRuntimeInterrupted, instead of closing the module. See CallContext.WatchCanceledOrTimeout.
+		InterruptOnContextDone bool
+
 		// functionMaxTypes represents the limit on the number of function types in a store.
 		// Note: this is fixed to 2^27 but have this a field for testability.
 		functionMaxTypes uint32
@@ -62,6 +70,8 @@ type (
 		Functions []FunctionInstance
 		Globals   []*GlobalInstance
 		// Memory is set when Module.MemorySection had a memory, regardless of whether it was exported.
+		//
+		// Note: This is the memory of index zero, so it equals Memories[0] if any.
 		Memory *MemoryInstance
 		Tables []*TableInstance
 
@@ -84,6 +94,26 @@ type (
 		// ElementInstances holds the element instance, and each holds the references to either functions
 		// or external objects (unimplemented).
 		ElementInstances []ElementInstance
+
+		// Memories is the memory index space: the imported memories followed by the ones defined in the module.
+		// There can be more than one memory only when api.CoreFeatureMultiMemory is enabled.
+		Memories []*MemoryInstance
+
+		// Tags is the tag index space: the imported tags followed by the ones defined in the module. This is only
+		// non-empty when api.CoreFeatureExceptionHandling is enabled.
+		Tags []*TagInstance
+
+		// GCHeap is Store.GCHeap, and Types is Module.TypeSection. They are only set when api.CoreFeatureGC is
+		// enabled, and used by ExecuteGC.
+		GCHeap *GCHeap
+		Types  []*FunctionType
+
+		// gcTypeIDs are index-correlated with Types, and hold the type IDs of each struct or array type followed by the
+		// ones of its supertypes. This is used by ref.test and ref.cast.
+		gcTypeIDs [][]FunctionTypeID
+
+		// Source is the Module this was instantiated from.
+		Source *Module
 	}
 
 	// DataInstance holds bytes corresponding to the data segment in a module.
@@ -143,14 +173,13 @@ type (
 const maximumFunctionTypes = 1 << 27
 
 // addSections adds section elements to the ModuleInstance
-func (m *ModuleInstance) addSections(module *Module, importedGlobals, globals []*GlobalInstance, tables []*TableInstance, memory, importedMemory *MemoryInstance) {
+func (m *ModuleInstance) addSections(module *Module, importedGlobals, globals []*GlobalInstance, tables []*TableInstance, memories []*MemoryInstance) {
 	m.Globals = append(importedGlobals, globals...)
 	m.Tables = tables
 
-	if importedMemory != nil {
-		m.Memory = importedMemory
-	} else {
-		m.Memory = memory
+	m.Memories = memories
+	if len(memories) > 0 {
+		m.Memory = memories[0]
 	}
 
 	m.BuildExports(module.ExportSection)
@@ -210,9 +239,8 @@ func (m *ModuleInstance) BuildExports(exports []*Export) {
 func (m *ModuleInstance) validateData(data []*DataSegment) (err error) {
 	for i, d := range data {
 		if !d.IsPassive() {
-			offset := int(executeConstExpression(m.Globals, d.OffsetExpression).(int32))
-			ceil := offset + len(d.Init)
-			if offset < 0 || ceil > len(m.Memory.Buffer) {
+			offset, ok := m.dataOffset(d)
+			if !ok || !m.Memories[d.MemoryIndex].hasSize(offset, uint64(len(d.Init))) {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
 		}
@@ -228,16 +256,28 @@ func (m *ModuleInstance) applyData(data []*DataSegment) error {
 	for i, d := range data {
 		m.DataInstances[i] = d.Init
 		if !d.IsPassive() {
-			offset := executeConstExpression(m.Globals, d.OffsetExpression).(int32)
-			if offset < 0 || int(offset)+len(d.Init) > len(m.Memory.Buffer) {
+			mem := m.Memories[d.MemoryIndex]
+			offset, ok := m.dataOffset(d)
+			if !ok || !mem.hasSize(offset, uint64(len(d.Init))) {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
-			copy(m.Memory.Buffer[offset:], d.Init)
+			copy(mem.Buffer[offset:], d.Init)
 		}
 	}
 	return nil
 }
 
+// dataOffset returns the offset of the active data segment, or false if it is negative. The offset is an i64 when the
+// segment is copied into a 64-bit memory, which is never negative.
+func (m *ModuleInstance) dataOffset(d *DataSegment) (uint64, bool) {
+	switch v := executeConstExpression(m.Globals, d.OffsetExpression).(type) {
+	case int32:
+		return uint64(v), v >= 0
+	default:
+		return uint64(v.(int64)), true
+	}
+}
+
 // GetExport returns an export of the given name and type or errs if not exported or the wrong type.
 func (m *ModuleInstance) getExport(name string, et ExternType) (ExportInstance, error) {
 	exp, ok := m.Exports[name]
@@ -255,13 +295,17 @@ func NewStore(enabledFeatures api.CoreFeatures, engine Engine) *Store {
 	for k, v := range preAllocatedTypeIDs {
 		typeIDs[k] = v
 	}
-	return &Store{
+	s := &Store{
 		nameToNode:       map[string]*moduleListNode{},
 		EnabledFeatures:  enabledFeatures,
 		Engine:           engine,
 		typeIDs:          typeIDs,
 		functionMaxTypes: maximumFunctionTypes,
 	}
+	if enabledFeatures.IsEnabled(api.CoreFeatureGC) {
+		s.GCHeap = NewGCHeap()
+	}
+	return s
 }
 
 // Instantiate uses name instead of the Module.NameSection ModuleName as it allows instantiating the same module under
@@ -277,6 +321,29 @@ func (s *Store) Instantiate(
 	module *Module,
 	name string,
 	sys *internalsys.Context,
+) (*CallContext, error) {
+	return s.instantiateAndAdd(ctx, module, name, sys, nil)
+}
+
+// InstantiateSnapshot is like Instantiate, except the state of the definitions is replaced by the Snapshot, which was
+// taken from an instance of the same Module, after the data and element segments are applied. The start function
+// isn't executed, as its effects are already in the Snapshot.
+func (s *Store) InstantiateSnapshot(
+	ctx context.Context,
+	module *Module,
+	name string,
+	sys *internalsys.Context,
+	snapshot *Snapshot,
+) (*CallContext, error) {
+	return s.instantiateAndAdd(ctx, module, name, sys, snapshot)
+}
+
+func (s *Store) instantiateAndAdd(
+	ctx context.Context,
+	module *Module,
+	name string,
+	sys *internalsys.Context,
+	snapshot *Snapshot,
 ) (*CallContext, error) {
 	// Collect any imported modules to avoid locking the store too long.
 	importedModuleNames := map[string]struct{}{}
@@ -296,7 +363,7 @@ func (s *Store) Instantiate(
 	}
 
 	// Instantiate the module and add it to the store so that other modules can import it.
-	if callCtx, err := s.instantiate(ctx, module, name, sys, importedModules); err != nil {
+	if callCtx, err := s.instantiate(ctx, module, name, sys, importedModules, snapshot); err != nil {
 		_ = s.deleteModule(name)
 		return nil, err
 	} else {
@@ -316,13 +383,14 @@ func (s *Store) instantiate(
 	name string,
 	sysCtx *internalsys.Context,
 	modules map[string]*ModuleInstance,
+	snapshot *Snapshot,
 ) (*CallContext, error) {
 	typeIDs, err := s.getFunctionTypeIDs(module.TypeSection)
 	if err != nil {
 		return nil, err
 	}
 
-	importedFunctions, importedGlobals, importedTables, importedMemory, err := resolveImports(module, modules)
+	importedFunctions, importedGlobals, importedTables, importedMemories, importedTags, err := resolveImports(module, modules)
 	if err != nil {
 		return nil, err
 	}
@@ -334,7 +402,7 @@ func (s *Store) instantiate(
 		return nil, err
 	}
 
-	m := &ModuleInstance{Name: name, TypeIDs: typeIDs}
+	m := &ModuleInstance{Name: name, TypeIDs: typeIDs, Source: module}
 	functions := m.BuildFunctions(module, importedFunctions)
 
 	// Plus, we are ready to compile functions.
@@ -343,10 +411,18 @@ func (s *Store) instantiate(
 		return nil, err
 	}
 
-	globals, memory := module.buildGlobals(importedGlobals, m.Engine.FunctionInstanceReference), module.buildMemory()
+	globals := module.buildGlobals(importedGlobals, m.Engine.FunctionInstanceReference)
+	memories, err := module.buildMemories(importedMemories)
+	if err != nil {
+		return nil, err
+	}
 
 	// Now we have all instances from imports and local ones, so ready to create a new ModuleInstance.
-	m.addSections(module, importedGlobals, globals, tables, importedMemory, memory)
+	m.addSections(module, importedGlobals, globals, tables, memories)
+	m.Tags = module.buildTags(importedTags)
+	if s.GCHeap != nil {
+		m.buildGCTypes(module, s.GCHeap)
+	}
 
 	// As of reference types proposal, data segment validation must happen after instantiation,
 	// and the side effect must persist even if there's out of bounds error after instantiation.
@@ -367,12 +443,21 @@ func (s *Store) instantiate(
 
 	m.applyTableInits(tables, tableInit)
 
+	if snapshot != nil {
+		if err = m.restore(snapshot); err != nil {
+			if m.GCHeap != nil {
+				m.GCHeap.removeModule(m)
+			}
+			return nil, err
+		}
+	}
+
 	// Compile the default context for calls to this module.
 	callCtx := NewCallContext(s, m, sysCtx)
 	m.CallCtx = callCtx
 
-	// Execute the start function.
-	if module.StartSection != nil {
+	// Execute the start function, unless its effects were restored from the snapshot.
+	if module.StartSection != nil && snapshot == nil {
 		funcIdx := *module.StartSection
 		f := &m.Functions[funcIdx]
 
@@ -382,7 +467,11 @@ func (s *Store) instantiate(
 				module.funcDesc(SectionIDFunction, funcIdx), err)
 		}
 
-		_, err = ce.Call(ctx, callCtx, nil)
+		// Call via api.Function, so that the GC references on the stack of the call are roots.
+		_, err = (&function{fi: f, ce: ce}).Call(ctx)
+		if err != nil && m.GCHeap != nil {
+			m.GCHeap.removeModule(m)
+		}
 		if exitErr, ok := err.(*sys.ExitError); ok { // Don't wrap an exit error!
 			return nil, exitErr
 		} else if err != nil {
@@ -397,7 +486,8 @@ func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 	importedFunctions []*FunctionInstance,
 	importedGlobals []*GlobalInstance,
 	importedTables []*TableInstance,
-	importedMemory *MemoryInstance,
+	importedMemories []*MemoryInstance,
+	importedTags []*TagInstance,
 	err error,
 ) {
 	for idx, i := range module.ImportSection {
@@ -458,7 +548,7 @@ func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 			importedTables = append(importedTables, importedTable)
 		case ExternTypeMemory:
 			expected := i.DescMem
-			importedMemory = m.Memory
+			importedMemory := m.Memories[imported.Index]
 
 			if expected.Min > memoryBytesNumToPages(uint64(len(importedMemory.Buffer))) {
 				err = errorMinSizeMismatch(i, idx, expected.Min, importedMemory.Min)
@@ -469,6 +559,19 @@ func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 				err = errorMaxSizeMismatch(i, idx, expected.Max, importedMemory.Max)
 				return
 			}
+
+			if expected.IsShared != importedMemory.Shared {
+				err = errorInvalidImport(i, idx, fmt.Errorf("shared mismatch: %t != %t",
+					expected.IsShared, importedMemory.Shared))
+				return
+			}
+
+			if expected.Is64 != importedMemory.Is64 {
+				err = errorInvalidImport(i, idx, fmt.Errorf("64-bit mismatch: %t != %t",
+					expected.Is64, importedMemory.Is64))
+				return
+			}
+			importedMemories = append(importedMemories, importedMemory)
 		case ExternTypeGlobal:
 			expected := i.DescGlobal
 			importedGlobal := m.Globals[imported.Index]
@@ -485,6 +588,15 @@ func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 				return
 			}
 			importedGlobals = append(importedGlobals, importedGlobal)
+		case ExternTypeTag:
+			expectedType := module.TypeSection[i.DescTag]
+			importedTag := m.Tags[imported.Index]
+
+			if !expectedType.EqualsSignature(importedTag.Type.Params, importedTag.Type.Results) {
+				err = errorInvalidImport(i, idx, fmt.Errorf("tag type mismatch: %s != %s", expectedType, importedTag.Type))
+				return
+			}
+			importedTags = append(importedTags, importedTag)
 		}
 	}
 	return
@@ -508,48 +620,69 @@ func errorInvalidImport(i *Import, idx int, err error) error {
 
 // Global initialization constant expression can only reference the imported globals.
 // See the note on https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#constant-expressions%E2%91%A0
+//
+// Note: This assumes the expression is validated by validateConstExpression.
 func executeConstExpression(importedGlobals []*GlobalInstance, expr *ConstantExpression) (v interface{}) {
-	switch expr.Opcode {
-	case OpcodeI32Const:
-		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
-		v, _, _ = leb128.LoadInt32(expr.Data)
-	case OpcodeI64Const:
-		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
-		v, _, _ = leb128.LoadInt64(expr.Data)
-	case OpcodeF32Const:
-		v, _ = ieee754.DecodeFloat32(expr.Data)
-	case OpcodeF64Const:
-		v, _ = ieee754.DecodeFloat64(expr.Data)
-	case OpcodeGlobalGet:
-		id, _, _ := leb128.LoadUint32(expr.Data)
-		g := importedGlobals[id]
-		switch g.Type.ValType {
-		case ValueTypeI32:
-			v = int32(g.Val)
-		case ValueTypeI64:
-			v = int64(g.Val)
-		case ValueTypeF32:
-			v = api.DecodeF32(g.Val)
-		case ValueTypeF64:
-			v = api.DecodeF64(g.Val)
-		case ValueTypeV128:
-			v = [2]uint64{g.Val, g.ValHi}
-		case ValueTypeFuncref, ValueTypeExternref:
-			v = int64(g.Val)
-		}
-	case OpcodeRefNull:
-		switch expr.Data[0] {
-		case ValueTypeExternref, ValueTypeFuncref:
-			v = int64(0) // Reference types are opaque 64bit pointer at runtime.
+	var stack []interface{}
+	_ = expr.forEachInstruction(func(opcode Opcode, immediates []byte) error {
+		switch opcode {
+		case OpcodeI32Const:
+			// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
+			v, _, _ = leb128.LoadInt32(immediates)
+		case OpcodeI64Const:
+			// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
+			v, _, _ = leb128.LoadInt64(immediates)
+		case OpcodeF32Const:
+			v, _ = ieee754.DecodeFloat32(immediates)
+		case OpcodeF64Const:
+			v, _ = ieee754.DecodeFloat64(immediates)
+		case OpcodeGlobalGet:
+			id, _, _ := leb128.LoadUint32(immediates)
+			g := importedGlobals[id]
+			switch g.Type.ValType {
+			case ValueTypeI32:
+				v = int32(g.Val)
+			case ValueTypeI64:
+				v = int64(g.Val)
+			case ValueTypeF32:
+				v = api.DecodeF32(g.Val)
+			case ValueTypeF64:
+				v = api.DecodeF64(g.Val)
+			case ValueTypeV128:
+				v = [2]uint64{g.Val, g.ValHi}
+			case ValueTypeFuncref, ValueTypeExternref, ValueTypeAnyref:
+				v = int64(g.Val)
+			}
+		case OpcodeRefNull:
+			v = int64(0) // Reference types are opaque 64bit pointer at runtime, and validated to be any of them.
+		case OpcodeRefFunc:
+			// For ref.func const expression, we temporarily store the index as value,
+			// and if this is the const expr for global, the value will be further downed to
+			// opaque pointer of the engine-specific compiled function.
+			v, _, _ = leb128.LoadUint32(immediates)
+		case OpcodeVecV128Const:
+			v = [2]uint64{binary.LittleEndian.Uint64(immediates[0:8]), binary.LittleEndian.Uint64(immediates[8:16])}
+		default: // Binary operators of api.CoreFeatureExtendedConst, which are all on integers.
+			x1, x2 := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			switch opcode {
+			case OpcodeI32Add:
+				v = x1.(int32) + x2.(int32)
+			case OpcodeI32Sub:
+				v = x1.(int32) - x2.(int32)
+			case OpcodeI32Mul:
+				v = x1.(int32) * x2.(int32)
+			case OpcodeI64Add:
+				v = x1.(int64) + x2.(int64)
+			case OpcodeI64Sub:
+				v = x1.(int64) - x2.(int64)
+			case OpcodeI64Mul:
+				v = x1.(int64) * x2.(int64)
+			}
 		}
-	case OpcodeRefFunc:
-		// For ref.func const expression, we temporarily store the index as value,
-		// and if this is the const expr for global, the value will be further downed to
-		// opaque pointer of the engine-specific compiled function.
-		v, _, _ = leb128.LoadUint32(expr.Data)
-	case OpcodeVecV128Const:
-		v = [2]uint64{binary.LittleEndian.Uint64(expr.Data[0:8]), binary.LittleEndian.Uint64(expr.Data[8:16])}
-	}
+		stack = append(stack, v)
+		return nil
+	})
 	return
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/internal/testing/hammer"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
 )
 
@@ -31,14 +32,14 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory not exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 			},
 		},
 		{
 			name: "memory exported, different name",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 				ExportSection:           []*Export{{Type: ExternTypeMemory, Name: "momory", Index: 0}},
 			},
@@ -46,7 +47,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, but zero length",
 			input: &Module{
-				MemorySection:           &Memory{},
+				MemorySection:           []*Memory{{}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 				ExportSection:           []*Export{{Type: ExternTypeMemory, Name: "memory", Index: 0}},
 			},
@@ -55,7 +56,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 				ExportSection:           []*Export{{Type: ExternTypeMemory, Name: "memory", Index: 0}},
 			},
@@ -65,13 +66,23 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, two pages",
 			input: &Module{
-				MemorySection:           &Memory{Min: 2, Cap: 2},
+				MemorySection:           []*Memory{{Min: 2, Cap: 2}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 				ExportSection:           []*Export{{Type: ExternTypeMemory, Name: "memory", Index: 0}},
 			},
 			expected:    true,
 			expectedLen: 65536 * 2,
 		},
+		{
+			name: "second memory exported",
+			input: &Module{
+				MemorySection:           []*Memory{{Min: 1, Cap: 1}, {Min: 3, Cap: 3}},
+				MemoryDefinitionSection: []*MemoryDefinition{{}, {}},
+				ExportSection:           []*Export{{Type: ExternTypeMemory, Name: "memory", Index: 1}},
+			},
+			expected:    true,
+			expectedLen: 65536 * 3,
+		},
 	}
 
 	for _, tt := range tests {
@@ -156,10 +167,11 @@ func TestStore_CloseWithExitCode(t *testing.T) {
 			m2, err := s.Instantiate(testCtx, &Module{
 				TypeSection:             []*FunctionType{v_v},
 				ImportSection:           []*Import{{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0}},
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []*MemoryDefinition{{}},
 				GlobalSection:           []*Global{{Type: &GlobalType{}, Init: &ConstantExpression{Opcode: OpcodeI32Const, Data: const1}}},
 				TableSection:            []*Table{{Min: 10}},
+				TableDefinitionSection:  []*TableDefinition{{}},
 			}, importingModuleName, nil)
 			require.NoError(t, err)
 
@@ -197,13 +209,14 @@ func TestStore_hammer(t *testing.T) {
 		TypeSection:             []*FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []*Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []*MemoryDefinition{{}},
 		GlobalSection: []*Global{{
 			Type: &GlobalType{ValType: ValueTypeI32},
 			Init: &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(1)},
 		}},
-		TableSection: []*Table{{Min: 10}},
+		TableSection:           []*Table{{Min: 10}},
+		TableDefinitionSection: []*TableDefinition{{}},
 		ImportSection: []*Import{
 			{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0},
 		},
@@ -251,13 +264,14 @@ func TestStore_hammer_close(t *testing.T) {
 		TypeSection:             []*FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []*Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []*Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []*MemoryDefinition{{}},
 		GlobalSection: []*Global{{
 			Type: &GlobalType{ValType: ValueTypeI32},
 			Init: &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(1)},
 		}},
-		TableSection: []*Table{{Min: 10}},
+		TableSection:           []*Table{{Min: 10}},
+		TableDefinitionSection: []*TableDefinition{{}},
 		ImportSection: []*Import{
 			{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0},
 		},
@@ -412,10 +426,13 @@ func (e *mockEngine) Close() error {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener, bool) error {
+func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener, bool, bool, bool) error {
 	return nil
 }
 
+// Coverage implements the same method as documented on wasm.Engine.
+func (e *mockEngine) Coverage(*Module) [][]CoverageBlock { return nil }
+
 // LookupFunction implements the same method as documented on wasm.Engine.
 func (e *mockModuleEngine) LookupFunction(*TableInstance, FunctionTypeID, Index) (Index, error) {
 	return 0, nil
@@ -450,6 +467,11 @@ func (e *mockModuleEngine) CreateFuncElementInstance([]*Index) *ElementInstance
 	return nil
 }
 
+// Coverage implements the same method as documented on wasm.ModuleEngine.
+func (e *mockModuleEngine) Coverage() [][]CoverageBlock {
+	return nil
+}
+
 // InitializeFuncrefGlobals implements the same method as documented on wasm.ModuleEngine.
 func (e *mockModuleEngine) InitializeFuncrefGlobals(globals []*GlobalInstance) {}
 
@@ -471,6 +493,15 @@ func (ce *mockCallEngine) Call(ctx context.Context, callCtx *CallContext, _ []ui
 	return
 }
 
+// CallWithStack implements the same method as documented on wasm.CallEngine.
+func (ce *mockCallEngine) CallWithStack(ctx context.Context, callCtx *CallContext, stack []uint64) error {
+	_, err := ce.Call(ctx, callCtx, stack)
+	return err
+}
+
+// GCRoots implements the same method as documented on wasm.CallEngine.
+func (ce *mockCallEngine) GCRoots(func(uint64)) {}
+
 func TestStore_getFunctionTypeID(t *testing.T) {
 	t.Run("too many functions", func(t *testing.T) {
 		s := newStore()
@@ -519,7 +550,7 @@ func TestExecuteConstExpression(t *testing.T) {
 					expr.Data = []byte{2}
 					expr.Opcode = OpcodeI64Const
 				case ValueTypeF32:
-					expr.Data = u64.LeBytes(api.EncodeF32(math.MaxFloat32))
+					expr.Data = u32.LeBytes(uint32(api.EncodeF32(math.MaxFloat32)))
 					expr.Opcode = OpcodeF32Const
 				case ValueTypeF64:
 					expr.Data = u64.LeBytes(api.EncodeF64(math.MaxFloat64))
@@ -665,6 +696,59 @@ func TestExecuteConstExpression(t *testing.T) {
 		require.Equal(t, uint64(0x1), vector[0])
 		require.Equal(t, uint64(0x2), vector[1])
 	})
+
+	t.Run("extended const", func(t *testing.T) {
+		globals := []*GlobalInstance{
+			{Val: 1024, Type: &GlobalType{ValType: ValueTypeI32}},
+			{Val: 1 << 40, Type: &GlobalType{ValType: ValueTypeI64}},
+		}
+		tests := []struct {
+			name string
+			expr *ConstantExpression
+			exp  interface{}
+		}{
+			{
+				name: "i32",
+				// (i32.add (global.get 0) (i32.mul (i32.const 16) (i32.const 3)))
+				expr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{
+					0,
+					OpcodeI32Const, 16,
+					OpcodeI32Const, 3,
+					OpcodeI32Mul,
+					OpcodeI32Add,
+				}},
+				exp: int32(1024 + 48),
+			},
+			{
+				name: "i32 wraps",
+				// (i32.sub (i32.const 0) (global.get 0))
+				expr: &ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{
+					0,
+					OpcodeGlobalGet, 0,
+					OpcodeI32Sub,
+				}},
+				exp: int32(-1024),
+			},
+			{
+				name: "i64",
+				// (i64.sub (global.get 1) (i64.const 1))
+				expr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{
+					1,
+					OpcodeI64Const, 1,
+					OpcodeI64Sub,
+				}},
+				exp: int64(1<<40 - 1),
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				val := executeConstExpression(globals, tc.expr)
+				require.Equal(t, tc.exp, val)
+			})
+		}
+	})
 }
 
 func Test_resolveImports(t *testing.T) {
@@ -673,14 +757,14 @@ func Test_resolveImports(t *testing.T) {
 
 	t.Run("module not instantiated", func(t *testing.T) {
 		modules := map[string]*ModuleInstance{}
-		_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: "unknown", Name: "unknown"}}}, modules)
+		_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: "unknown", Name: "unknown"}}}, modules)
 		require.EqualError(t, err, "module[unknown] not instantiated")
 	})
 	t.Run("export instance not found", func(t *testing.T) {
 		modules := map[string]*ModuleInstance{
 			moduleName: {Exports: map[string]ExportInstance{}, Name: moduleName},
 		}
-		_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: "unknown"}}}, modules)
+		_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: "unknown"}}}, modules)
 		require.EqualError(t, err, "\"unknown\" is not exported in module \"test\"")
 	})
 	t.Run("func", func(t *testing.T) {
@@ -706,7 +790,7 @@ func Test_resolveImports(t *testing.T) {
 					{Module: moduleName, Name: "", Type: ExternTypeFunc, DescFunc: 1},
 				},
 			}
-			functions, _, _, _, err := resolveImports(m, modules)
+			functions, _, _, _, _, err := resolveImports(m, modules)
 			require.NoError(t, err)
 			require.True(t, functionsContain(functions, &externMod.Functions[0]), "expected to find %v in %v", &externMod.Functions[0], functions)
 			require.True(t, functionsContain(functions, &externMod.Functions[1]), "expected to find %v in %v", &externMod.Functions[1], functions)
@@ -715,7 +799,7 @@ func Test_resolveImports(t *testing.T) {
 			modules := map[string]*ModuleInstance{
 				moduleName: {Exports: map[string]ExportInstance{name: {}}, Name: moduleName},
 			}
-			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeFunc, DescFunc: 100}}}, modules)
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeFunc, DescFunc: 100}}}, modules)
 			require.EqualError(t, err, "import[0] func[test.target]: function type out of range")
 		})
 		t.Run("signature mismatch", func(t *testing.T) {
@@ -731,7 +815,7 @@ func Test_resolveImports(t *testing.T) {
 				TypeSection:   []*FunctionType{{Results: []ValueType{ValueTypeF32}}},
 				ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeFunc, DescFunc: 0}},
 			}
-			_, _, _, _, err := resolveImports(m, modules)
+			_, _, _, _, _, err := resolveImports(m, modules)
 			require.EqualError(t, err, "import[0] func[test.target]: signature mismatch: v_f32 != v_v")
 		})
 	})
@@ -744,7 +828,7 @@ func Test_resolveImports(t *testing.T) {
 					Exports: map[string]ExportInstance{name: {Type: ExternTypeGlobal, Index: 0}}, Name: moduleName,
 				},
 			}
-			_, globals, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: g.Type}}}, modules)
+			_, globals, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: g.Type}}}, modules)
 			require.NoError(t, err)
 			require.True(t, globalsContain(globals, g), "expected to find %v in %v", g, globals)
 		})
@@ -759,7 +843,7 @@ func Test_resolveImports(t *testing.T) {
 					Name: moduleName,
 				},
 			}
-			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: &GlobalType{Mutable: true}}}}, modules)
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: &GlobalType{Mutable: true}}}}, modules)
 			require.EqualError(t, err, "import[0] global[test.target]: mutability mismatch: true != false")
 		})
 		t.Run("type mismatch", func(t *testing.T) {
@@ -773,7 +857,7 @@ func Test_resolveImports(t *testing.T) {
 					Name: moduleName,
 				},
 			}
-			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeF64}}}}, modules)
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeF64}}}}, modules)
 			require.EqualError(t, err, "import[0] global[test.target]: value type mismatch: f64 != i32")
 		})
 	})
@@ -783,29 +867,29 @@ func Test_resolveImports(t *testing.T) {
 			memoryInst := &MemoryInstance{Max: max}
 			modules := map[string]*ModuleInstance{
 				moduleName: {
-					Memory: memoryInst,
+					Memories: []*MemoryInstance{memoryInst},
 					Exports: map[string]ExportInstance{name: {
 						Type: ExternTypeMemory,
 					}},
 					Name: moduleName,
 				},
 			}
-			_, _, _, memory, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: &Memory{Max: max}}}}, modules)
+			_, _, _, memories, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: &Memory{Max: max}}}}, modules)
 			require.NoError(t, err)
-			require.Equal(t, memory, memoryInst)
+			require.Equal(t, []*MemoryInstance{memoryInst}, memories)
 		})
 		t.Run("minimum size mismatch", func(t *testing.T) {
 			importMemoryType := &Memory{Min: 2, Cap: 2}
 			modules := map[string]*ModuleInstance{
 				moduleName: {
-					Memory: &MemoryInstance{Min: importMemoryType.Min - 1, Cap: 2},
+					Memories: []*MemoryInstance{&MemoryInstance{Min: importMemoryType.Min - 1, Cap: 2}},
 					Exports: map[string]ExportInstance{name: {
 						Type: ExternTypeMemory,
 					}},
 					Name: moduleName,
 				},
 			}
-			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
 			require.EqualError(t, err, "import[0] memory[test.target]: minimum size mismatch: 2 > 1")
 		})
 		t.Run("maximum size mismatch", func(t *testing.T) {
@@ -813,21 +897,51 @@ func Test_resolveImports(t *testing.T) {
 			importMemoryType := &Memory{Max: max}
 			modules := map[string]*ModuleInstance{
 				moduleName: {
-					Memory: &MemoryInstance{Max: MemoryLimitPages},
+					Memories: []*MemoryInstance{&MemoryInstance{Max: MemoryLimitPages}},
 					Exports: map[string]ExportInstance{name: {
 						Type: ExternTypeMemory,
 					}},
 					Name: moduleName,
 				},
 			}
-			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
 			require.EqualError(t, err, "import[0] memory[test.target]: maximum size mismatch: 10 < 65536")
 		})
+		t.Run("shared mismatch", func(t *testing.T) {
+			max := uint32(10)
+			importMemoryType := &Memory{Max: max, IsShared: true}
+			modules := map[string]*ModuleInstance{
+				moduleName: {
+					Memories: []*MemoryInstance{&MemoryInstance{Max: max}},
+					Exports: map[string]ExportInstance{name: {
+						Type: ExternTypeMemory,
+					}},
+					Name: moduleName,
+				},
+			}
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
+			require.EqualError(t, err, "import[0] memory[test.target]: shared mismatch: true != false")
+		})
+		t.Run("64-bit mismatch", func(t *testing.T) {
+			max := uint32(10)
+			importMemoryType := &Memory{Max: max, Is64: true}
+			modules := map[string]*ModuleInstance{
+				moduleName: {
+					Memories: []*MemoryInstance{&MemoryInstance{Max: max}},
+					Exports: map[string]ExportInstance{name: {
+						Type: ExternTypeMemory,
+					}},
+					Name: moduleName,
+				},
+			}
+			_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
+			require.EqualError(t, err, "import[0] memory[test.target]: 64-bit mismatch: true != false")
+		})
 	})
 }
 
 func TestModuleInstance_validateData(t *testing.T) {
-	m := &ModuleInstance{Memory: &MemoryInstance{Buffer: make([]byte, 5)}}
+	m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 5)}}}
 	tests := []struct {
 		name   string
 		data   []*DataSegment
@@ -872,17 +986,27 @@ func TestModuleInstance_validateData(t *testing.T) {
 
 func TestModuleInstance_applyData(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		m := &ModuleInstance{Memory: &MemoryInstance{Buffer: make([]byte, 10)}}
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 10)}}}
 		err := m.applyData([]*DataSegment{
 			{OffsetExpression: &ConstantExpression{Opcode: OpcodeI32Const, Data: const0}, Init: []byte{0xa, 0xf}},
 			{OffsetExpression: &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeUint32(8)}, Init: []byte{0x1, 0x5}},
 		})
 		require.NoError(t, err)
-		require.Equal(t, []byte{0xa, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x5}, m.Memory.Buffer)
+		require.Equal(t, []byte{0xa, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x5}, m.Memories[0].Buffer)
 		require.Equal(t, [][]byte{{0xa, 0xf}, {0x1, 0x5}}, m.DataInstances)
 	})
+	t.Run("multiple memories", func(t *testing.T) {
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 2)}, {Buffer: make([]byte, 4)}}}
+		err := m.applyData([]*DataSegment{
+			{OffsetExpression: &ConstantExpression{Opcode: OpcodeI32Const, Data: const0}, Init: []byte{0xa, 0xf}},
+			{OffsetExpression: &ConstantExpression{Opcode: OpcodeI32Const, Data: const1}, Init: []byte{0x1, 0x5}, MemoryIndex: 1},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xa, 0xf}, m.Memories[0].Buffer)
+		require.Equal(t, []byte{0x0, 0x1, 0x5, 0x0}, m.Memories[1].Buffer)
+	})
 	t.Run("error", func(t *testing.T) {
-		m := &ModuleInstance{Memory: &MemoryInstance{Buffer: make([]byte, 5)}}
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 5)}}}
 		err := m.applyData([]*DataSegment{
 			{OffsetExpression: &ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeUint32(8)}, Init: []byte{}},
 		})
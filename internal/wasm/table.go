@@ -103,6 +103,9 @@ type TableInstance struct {
 
 	// mux is used to prevent overlapping calls to Grow.
 	mux sync.RWMutex
+
+	// definition is known at compile time.
+	definition api.TableDefinition
 }
 
 // ElementInstance represents an element instance in a module.
@@ -122,7 +125,7 @@ type Reference = uintptr
 //
 // Note: The global imported at globalIdx may have an offset value that is out-of-bounds for the corresponding table.
 type validatedActiveElementSegment struct {
-	// opcode is OpcodeGlobalGet or OpcodeI32Const
+	// opcode is OpcodeGlobalGet or OpcodeI32Const, or zero if offsetExpr is set.
 	opcode Opcode
 
 	// arg is the only argument to opcode, which when applied results in the offset to add to init indices.
@@ -137,6 +140,10 @@ type validatedActiveElementSegment struct {
 
 	// tableIndex is the table's index to which this active element will be applied.
 	tableIndex Index
+
+	// offsetExpr is set instead of opcode and arg when the offset is computed by multiple instructions as per
+	// api.CoreFeatureExtendedConst.
+	offsetExpr *ConstantExpression
 }
 
 // validateTable ensures any ElementSegment is valid. This caches results via Module.validatedActiveElementSegments.
@@ -192,7 +199,17 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []*Table
 
 			// global.get needs to be discovered during initialization
 			oc := elem.OffsetExpr.Opcode
-			if oc == OpcodeGlobalGet {
+			if elem.OffsetExpr.IsExtended() {
+				if err := validateConstExpression(m.importedGlobalTypes(), 0, elem.OffsetExpr, ValueTypeI32); err != nil {
+					return nil, fmt.Errorf("%s[%d] has an invalid const expression: %w", SectionIDName(SectionIDElement), idx, err)
+				}
+
+				if initCount == 0 {
+					continue // Per https://github.com/WebAssembly/spec/issues/1427 init can be no-op, but validate anyway!
+				}
+
+				ret = append(ret, &validatedActiveElementSegment{offsetExpr: elem.OffsetExpr, init: elem.Init, tableIndex: elem.TableIndex})
+			} else if oc == OpcodeGlobalGet {
 				globalIdx, _, err := leb128.LoadUint32(elem.OffsetExpr.Data)
 				if err != nil {
 					return nil, fmt.Errorf("%s[%d] couldn't read global.get parameter: %w", SectionIDName(SectionIDElement), idx, err)
@@ -247,11 +264,12 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []*Table
 func (m *Module) buildTables(importedTables []*TableInstance, importedGlobals []*GlobalInstance, skipBoundCheck bool) (tables []*TableInstance, inits []tableInitEntry, err error) {
 	tables = importedTables
 
-	for _, tsec := range m.TableSection {
+	importCount := len(importedTables)
+	for i, tsec := range m.TableSection {
 		// The module defining the table is the one that sets its Min/Max etc.
 		tables = append(tables, &TableInstance{
 			References: make([]Reference, tsec.Min), Min: tsec.Min, Max: tsec.Max,
-			Type: tsec.Type,
+			Type: tsec.Type, definition: m.TableDefinitionSection[importCount+i],
 		})
 	}
 
@@ -263,7 +281,9 @@ func (m *Module) buildTables(importedTables []*TableInstance, importedGlobals []
 	for elemI, elem := range elementSegments {
 		table := tables[elem.tableIndex]
 		var offset uint32
-		if elem.opcode == OpcodeGlobalGet {
+		if elem.offsetExpr != nil {
+			offset = uint32(executeConstExpression(importedGlobals, elem.offsetExpr).(int32))
+		} else if elem.opcode == OpcodeGlobalGet {
 			global := importedGlobals[elem.arg]
 			offset = uint32(global.Val)
 		} else {
@@ -319,6 +339,17 @@ func checkSegmentBounds(min uint32, requireMin uint64, idx Index) error { // uin
 	return nil
 }
 
+// importedGlobalTypes returns the types of the imported globals, which are the only globals constant expressions of
+// element segments can refer to.
+func (m *Module) importedGlobalTypes() (globals []*GlobalType) {
+	for _, im := range m.ImportSection {
+		if im.Type == ExternTypeGlobal {
+			globals = append(globals, im.DescGlobal)
+		}
+	}
+	return
+}
+
 func (m *Module) verifyImportGlobalI32(sectionID SectionID, sectionIdx Index, idx uint32) error {
 	ig := uint32(math.MaxUint32) // +1 == 0
 	for i, im := range m.ImportSection {
@@ -364,3 +395,56 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	}
 	return
 }
+
+// shrink reduces the length of the table to size references.
+func (t *TableInstance) shrink(size int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.References = t.References[:size]
+}
+
+// table implements api.Table. This is a wrapper because TableInstance.Grow has a different signature.
+type table struct {
+	t *TableInstance
+}
+
+// Definition implements the same method as documented on api.Table.
+func (t table) Definition() api.TableDefinition {
+	return t.t.definition
+}
+
+// Size implements the same method as documented on api.Table.
+func (t table) Size() uint32 {
+	t.t.mux.RLock()
+	defer t.t.mux.RUnlock()
+	return uint32(len(t.t.References))
+}
+
+// Grow implements the same method as documented on api.Table.
+func (t table) Grow(delta uint32, v uint64) (previousSize uint32, ok bool) {
+	if previousSize = t.t.Grow(delta, Reference(v)); previousSize == 0xffffffff {
+		return 0, false
+	}
+	return previousSize, true
+}
+
+// Get implements the same method as documented on api.Table.
+func (t table) Get(offset uint32) (uint64, bool) {
+	t.t.mux.RLock()
+	defer t.t.mux.RUnlock()
+	if offset >= uint32(len(t.t.References)) {
+		return 0, false
+	}
+	return uint64(t.t.References[offset]), true
+}
+
+// Set implements the same method as documented on api.Table.
+func (t table) Set(offset uint32, v uint64) bool {
+	t.t.mux.RLock()
+	defer t.t.mux.RUnlock()
+	if offset >= uint32(len(t.t.References)) {
+		return false
+	}
+	t.t.References[offset] = Reference(v)
+	return true
+}
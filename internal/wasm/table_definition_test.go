@@ -0,0 +1,122 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestModule_BuildTableDefinitions(t *testing.T) {
+	three := uint32(3)
+
+	tests := []struct {
+		name            string
+		m               *Module
+		expected        []*TableDefinition
+		expectedImports []api.TableDefinition
+		expectedExports map[string]api.TableDefinition
+	}{
+		{
+			name:            "no exports",
+			m:               &Module{},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name: "no tables",
+			m: &Module{
+				ExportSection: []*Export{{Type: ExternTypeGlobal, Index: 0}},
+				GlobalSection: []*Global{{}},
+			},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name:            "defines table{0,}",
+			m:               &Module{TableSection: []*Table{{Type: RefTypeFuncref}}},
+			expected:        []*TableDefinition{{index: 0, table: &Table{Type: RefTypeFuncref}}},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name: "exports imported table{0,} and defined table{2,3}",
+			m: &Module{
+				ImportSection: []*Import{{
+					Type:      ExternTypeTable,
+					DescTable: &Table{Type: RefTypeFuncref},
+				}},
+				ExportSection: []*Export{
+					{Name: "imported_table", Type: ExternTypeTable, Index: 0},
+					{Name: "table_index=1", Type: ExternTypeTable, Index: 1},
+				},
+				TableSection: []*Table{{Min: 2, Max: &three, Type: RefTypeExternref}},
+			},
+			expected: []*TableDefinition{
+				{
+					index:       0,
+					importDesc:  &[2]string{"", ""},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeFuncref},
+				},
+				{
+					index:       1,
+					exportNames: []string{"table_index=1"},
+					table:       &Table{Min: 2, Max: &three, Type: RefTypeExternref},
+				},
+			},
+			expectedImports: []api.TableDefinition{
+				&TableDefinition{
+					index:       0,
+					importDesc:  &[2]string{"", ""},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeFuncref},
+				},
+			},
+			expectedExports: map[string]api.TableDefinition{
+				"imported_table": &TableDefinition{
+					index:       0,
+					importDesc:  &[2]string{"", ""},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeFuncref},
+				},
+				"table_index=1": &TableDefinition{
+					index:       1,
+					exportNames: []string{"table_index=1"},
+					table:       &Table{Min: 2, Max: &three, Type: RefTypeExternref},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.m.BuildTableDefinitions()
+			require.Equal(t, tc.expected, tc.m.TableDefinitionSection)
+			require.Equal(t, tc.expectedImports, tc.m.ImportedTables())
+			require.Equal(t, tc.expectedExports, tc.m.ExportedTables())
+		})
+	}
+}
+
+func TestTableDefinition(t *testing.T) {
+	three := uint32(3)
+	d := &TableDefinition{
+		moduleName:  "test",
+		index:       1,
+		exportNames: []string{"t"},
+		table:       &Table{Min: 2, Max: &three, Type: RefTypeExternref},
+	}
+	require.Equal(t, "test", d.ModuleName())
+	require.Equal(t, uint32(1), d.Index())
+	_, _, isImport := d.Import()
+	require.False(t, isImport)
+	require.Equal(t, []string{"t"}, d.ExportNames())
+	require.Equal(t, api.ValueTypeExternref, d.Type())
+	require.Equal(t, uint32(2), d.Min())
+	max, ok := d.Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(3), max)
+
+	d.table = &Table{Type: RefTypeFuncref}
+	_, ok = d.Max()
+	require.False(t, ok)
+}
@@ -27,7 +27,7 @@ func Test_resolveImports_table(t *testing.T) {
 				Name:    moduleName,
 			},
 		}
-		_, _, tables, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: &Table{Max: &max}}}}, modules)
+		_, _, tables, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: &Table{Max: &max}}}}, modules)
 		require.NoError(t, err)
 		require.Equal(t, 1, len(tables))
 		require.Equal(t, tables[0], tableInst)
@@ -41,7 +41,7 @@ func Test_resolveImports_table(t *testing.T) {
 				Name:    moduleName,
 			},
 		}
-		_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: importTableType}}}, modules)
+		_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: importTableType}}}, modules)
 		require.EqualError(t, err, "import[0] table[test.target]: minimum size mismatch: 2 > 1")
 	})
 	t.Run("maximum size mismatch", func(t *testing.T) {
@@ -54,7 +54,7 @@ func Test_resolveImports_table(t *testing.T) {
 				Name:    moduleName,
 			},
 		}
-		_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: importTableType}}}, modules)
+		_, _, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeTable, DescTable: importTableType}}}, modules)
 		require.EqualError(t, err, "import[0] table[test.target]: maximum size mismatch: 10, but actual has no max")
 	})
 }
@@ -318,6 +318,29 @@ func TestModule_validateTable(t *testing.T) {
 				{opcode: OpcodeGlobalGet, arg: 1, init: []*Index{uint32Ptr(1), uint32Ptr(2)}},
 			},
 		},
+		{
+			name: "extended const derived element offset",
+			input: &Module{
+				TypeSection: []*FunctionType{{}},
+				ImportSection: []*Import{
+					{Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeI64}},
+					{Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeI32}},
+				},
+				TableSection:    []*Table{{Min: 3, Type: RefTypeFuncref}},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{codeEnd},
+				ElementSection: []*ElementSegment{
+					{
+						OffsetExpr: extendedConstOffset,
+						Init:       []*Index{uint32Ptr(0)},
+						Type:       RefTypeFuncref,
+					},
+				},
+			},
+			expected: []*validatedActiveElementSegment{
+				{offsetExpr: extendedConstOffset, init: []*Index{uint32Ptr(0)}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -594,6 +617,27 @@ func TestModule_validateTable_Errors(t *testing.T) {
 			},
 			expectedErr: "element[0] (global.get 0): import[0].global.ValType != i32",
 		},
+		{
+			name: "extended const derived element offset - wrong ValType",
+			input: &Module{
+				TypeSection: []*FunctionType{{}},
+				ImportSection: []*Import{
+					{Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeI64}},
+				},
+				TableSection:    []*Table{{Type: RefTypeFuncref}},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{codeEnd},
+				ElementSection: []*ElementSegment{
+					{
+						// (i64.add (global.get 0) (i64.const 1))
+						OffsetExpr: &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{0x0, OpcodeI64Const, 1, OpcodeI64Add}},
+						Init:       []*Index{uint32Ptr(0)},
+						Type:       RefTypeFuncref,
+					},
+				},
+			},
+			expectedErr: "element[0] has an invalid const expression: const expression type mismatch expected i32 but got i64",
+		},
 		{
 			name: "imported global derived element offset - decode error",
 			input: &Module{
@@ -671,6 +715,9 @@ func TestModule_validateTable_Errors(t *testing.T) {
 var (
 	const0 = leb128.EncodeInt32(0)
 	const1 = leb128.EncodeInt32(1)
+
+	// extendedConstOffset is (i32.add (global.get 1) (i32.const 1)) as per api.CoreFeatureExtendedConst.
+	extendedConstOffset = &ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{0x1, OpcodeI32Const, 1, OpcodeI32Add}}
 )
 
 func TestModule_buildTables(t *testing.T) {
@@ -922,15 +969,42 @@ func TestModule_buildTables(t *testing.T) {
 				{tableIndex: 0, offset: 1, functionIndexes: []*Index{uint32Ptr(1), uint32Ptr(2)}},
 			},
 		},
+		{
+			name: "extended const derived element offset",
+			module: &Module{
+				TypeSection: []*FunctionType{{}},
+				ImportSection: []*Import{
+					{Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeI64}},
+					{Type: ExternTypeGlobal, DescGlobal: &GlobalType{ValType: ValueTypeI32}},
+				},
+				TableSection:    []*Table{{Min: 3}},
+				FunctionSection: []Index{0},
+				CodeSection:     []*Code{codeEnd},
+				validatedActiveElementSegments: []*validatedActiveElementSegment{
+					{offsetExpr: extendedConstOffset, init: []*Index{uint32Ptr(0)}},
+				},
+			},
+			importedGlobals: []*GlobalInstance{
+				{Type: &GlobalType{ValType: ValueTypeI64}, Val: 3},
+				{Type: &GlobalType{ValType: ValueTypeI32}, Val: 1},
+			},
+			expectedTables: []*TableInstance{{References: make([]Reference, 3), Min: 3}},
+			expectedInit:   []tableInitEntry{{tableIndex: 0, offset: 2, functionIndexes: []*Index{uint32Ptr(0)}}},
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
+			tc.module.BuildTableDefinitions()
 			tables, init, err := tc.module.buildTables(tc.importedTables, tc.importedGlobals, false)
 			require.NoError(t, err)
 
+			// Tables defined by the module have its definitions.
+			for i := len(tc.importedTables); i < len(tc.expectedTables); i++ {
+				tc.expectedTables[i].definition = tc.module.TableDefinitionSection[i]
+			}
 			require.Equal(t, tc.expectedTables, tables)
 			require.Equal(t, tc.expectedInit, init)
 		})
@@ -1020,6 +1094,7 @@ func TestModule_buildTable_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
+			tc.module.BuildTableDefinitions()
 			_, _, err := tc.module.buildTables(tc.importedTables, tc.importedGlobals, false)
 			require.EqualError(t, err, tc.expectedErr)
 		})
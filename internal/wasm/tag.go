@@ -0,0 +1,91 @@
+package wasm
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// compile time check to ensure TagInstance implements api.Tag
+var _ api.Tag = &TagInstance{}
+
+// TagInstance represents a tag instance in a store, which identifies exceptions by its pointer.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tags
+type TagInstance struct {
+	// Type is the type of the payload, which has no results.
+	Type *FunctionType
+}
+
+// ParamTypes implements the same method as documented on api.Tag.
+func (t *TagInstance) ParamTypes() []api.ValueType {
+	return t.Type.Params
+}
+
+// String implements fmt.Stringer.
+func (t *TagInstance) String() string {
+	return fmt.Sprintf("tag(%s)", t.Type)
+}
+
+// AllTags returns the type index of each tag in the tag index, imports first.
+func (m *Module) AllTags() (tags []Index) {
+	for _, imp := range m.ImportSection {
+		if imp.Type == ExternTypeTag {
+			tags = append(tags, imp.DescTag)
+		}
+	}
+	return append(tags, m.TagSection...)
+}
+
+// validateTags ensures each tag refers to a type without results.
+func (m *Module) validateTags(enabledFeatures api.CoreFeatures, tags []Index) error {
+	if len(tags) > 0 {
+		if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+			return fmt.Errorf("tags are invalid as %w", err)
+		}
+	}
+	for i, typeIndex := range tags {
+		if typeIndex >= uint32(len(m.TypeSection)) {
+			return fmt.Errorf("invalid tag[%d]: type section index %d out of range", i, typeIndex)
+		}
+		if m.TypeSection[typeIndex].Composite != nil {
+			return fmt.Errorf("invalid tag[%d]: type %d is not a function type", i, typeIndex)
+		} else if len(m.TypeSection[typeIndex].Results) > 0 {
+			return fmt.Errorf("invalid tag[%d]: type %s must have no results", i, m.TypeSection[typeIndex])
+		}
+	}
+	return nil
+}
+
+// buildTags returns the tag index space: the imported tags followed by the ones defined in this module.
+func (m *Module) buildTags(importedTags []*TagInstance) (tags []*TagInstance) {
+	tags = importedTags
+	for _, typeIndex := range m.TagSection {
+		tags = append(tags, &TagInstance{Type: m.TypeSection[typeIndex]})
+	}
+	return
+}
+
+// CatchException calls the Go function fn, and returns the exception if fn panics with *api.Exception. Other panics
+// are propagated as they are. Engines use this to call host functions when api.CoreFeatureExceptionHandling is
+// enabled, so that the exceptions thrown by them can be caught in Wasm.
+func CatchException(fn func()) (exception *api.Exception) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*api.Exception)
+			if !ok {
+				panic(r)
+			}
+			tag, ok := e.Tag().(*TagInstance)
+			if !ok {
+				panic(fmt.Errorf("exception with unknown tag %v", e.Tag()))
+			}
+			if len(e.Payload()) != tag.Type.ParamNumInUint64 {
+				panic(fmt.Errorf("exception payload length %d doesn't match %s", len(e.Payload()), tag))
+			}
+			exception = e
+		}
+	}()
+	fn()
+	return
+}
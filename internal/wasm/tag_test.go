@@ -0,0 +1,97 @@
+package wasm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestModule_validateTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		module      *Module
+		flag        api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name:   "no tags",
+			module: &Module{},
+			flag:   api.CoreFeaturesV2,
+		},
+		{
+			name: "valid",
+			module: &Module{
+				TypeSection: []*FunctionType{v_v, {Params: []ValueType{ValueTypeI32}}},
+				TagSection:  []Index{0, 1},
+			},
+			flag: api.CoreFeatureExceptionHandling,
+		},
+		{
+			name: "disabled",
+			module: &Module{
+				TypeSection: []*FunctionType{v_v},
+				TagSection:  []Index{0},
+			},
+			flag:        api.CoreFeaturesV2,
+			expectedErr: "tags are invalid as feature \"exception-handling\" is disabled",
+		},
+		{
+			name: "type out of range",
+			module: &Module{
+				TypeSection: []*FunctionType{v_v},
+				TagSection:  []Index{1},
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "invalid tag[0]: type section index 1 out of range",
+		},
+		{
+			name: "type with results",
+			module: &Module{
+				TypeSection: []*FunctionType{i32_i32},
+				TagSection:  []Index{0},
+			},
+			flag:        api.CoreFeatureExceptionHandling,
+			expectedErr: "invalid tag[0]: type i32_i32 must have no results",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.module.validateTags(tc.flag, tc.module.AllTags())
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCatchException(t *testing.T) {
+	tag := &TagInstance{Type: &FunctionType{Params: []ValueType{ValueTypeI32}, ParamNumInUint64: 1}}
+
+	t.Run("no exception", func(t *testing.T) {
+		require.Nil(t, CatchException(func() {}))
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		expected := api.NewException(tag, 42)
+		require.Equal(t, expected, CatchException(func() { panic(expected) }))
+	})
+
+	t.Run("other panic", func(t *testing.T) {
+		err := errors.New("boom")
+		require.EqualError(t, require.CapturePanic(func() {
+			CatchException(func() { panic(err) })
+		}), "boom")
+	})
+
+	t.Run("payload mismatch", func(t *testing.T) {
+		require.EqualError(t, require.CapturePanic(func() {
+			CatchException(func() { panic(api.NewException(tag)) })
+		}), "exception payload length 0 doesn't match tag(i32_v)")
+	})
+}
@@ -0,0 +1,1007 @@
+package text
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// DecodeModule decodes the WebAssembly Text Format (%.wat) into a wasm.Module, which isn't validated yet. This also
+// accepts a module of a WebAssembly Script (%.wast), but not its commands such as assertions.
+//
+// The source must have a module, which is either a (module ...) or its fields, so that an empty source, or one with only
+// whitespace and comments, isn't mistaken for an empty module.
+//
+// Identifiers, such as "$main" in (func $main), are retained in the wasm.NameSection without the leading '$'. Errors
+// are FormatError which has the line and column of the source.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+func DecodeModule(
+	source []byte,
+	enabledFeatures api.CoreFeatures,
+	memoryLimitPages uint32,
+	memoryCapacityFromMax bool,
+) (*wasm.Module, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs, err := parseSexprs(tokens)
+	if err != nil {
+		return nil, err
+	} else if len(exprs) == 0 {
+		return nil, errorf(tokens[len(tokens)-1], "expected module, but was EOF")
+	}
+
+	if memoryLimitPages > wasm.MemoryLimitPages {
+		// Only a 64-bit memory can address more than 4GiB, which isn't supported in the text format yet.
+		memoryLimitPages = wasm.MemoryLimitPages
+	}
+
+	p := &moduleParser{
+		enabledFeatures:       enabledFeatures,
+		memoryLimitPages:      memoryLimitPages,
+		memoryCapacityFromMax: memoryCapacityFromMax,
+		module:                &wasm.Module{},
+		typeNamespace:         newIndexNamespace("type"),
+		funcNamespace:         newIndexNamespace("func"),
+		tableNamespace:        newIndexNamespace("table"),
+		memoryNamespace:       newIndexNamespace("memory"),
+		globalNamespace:       newIndexNamespace("global"),
+		elemNamespace:         newIndexNamespace("elem"),
+		dataNamespace:         newIndexNamespace("data"),
+		exportNames:           map[string]struct{}{},
+	}
+
+	// The module fields can be written without the enclosing (module ...).
+	fields := &cursor{items: exprs, end: tokens[len(tokens)-1]}
+	if fields.peekList("module") {
+		module := fields.next()
+		if err = fields.expectDone(); err != nil {
+			return nil, err
+		}
+		fields = newCursor(module)
+		if id := fields.optionalID(); id != nil {
+			p.moduleName = id.tok.text[1:]
+		}
+	}
+
+	if err = p.parse(fields); err != nil {
+		return nil, err
+	}
+	return p.module, nil
+}
+
+// moduleParser builds a wasm.Module from its fields.
+type moduleParser struct {
+	enabledFeatures       api.CoreFeatures
+	memoryLimitPages      uint32
+	memoryCapacityFromMax bool
+
+	module     *wasm.Module
+	moduleName string
+
+	typeNamespace, funcNamespace, tableNamespace, memoryNamespace,
+	globalNamespace, elemNamespace, dataNamespace *indexNamespace
+
+	// localNames are the names of the parameters and locals of each function which has any.
+	localNames wasm.IndirectNameMap
+
+	// exportNames are the names exported so far, which must be unique.
+	exportNames map[string]struct{}
+
+	// pending are the parts of the fields parsed after all the identifiers are defined, as a field can refer to the
+	// ones defined after it, e.g. a function body can call a function defined later.
+	pending []func() error
+
+	// definedNonImport is true after a function, table, memory or global definition, which imports must precede.
+	definedNonImport bool
+
+	// usesDataCount is true if memory.init or data.drop is used, which require the wasm.Module DataCountSection.
+	usesDataCount bool
+}
+
+func (p *moduleParser) parse(fields *cursor) error {
+	// Type definitions are parsed first as the implicit types of type uses are appended after all of them.
+	//
+	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#abbreviations%E2%91%A0
+	var others []*sexpr
+	for !fields.done() {
+		field := fields.next()
+		if !field.isList {
+			return errorf(field.tok, "expected module field, but was %s", field)
+		} else if field.head() != "type" {
+			others = append(others, field)
+			continue
+		}
+		context := fmt.Sprintf("module.type[%d]", len(p.module.TypeSection))
+		if err := p.parseType(field); err != nil {
+			return withContext(err, context)
+		}
+	}
+
+	for _, field := range others {
+		if err := p.parseField(field); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range p.pending {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+
+	if p.usesDataCount {
+		dataCount := uint32(len(p.module.DataSection))
+		p.module.DataCountSection = &dataCount
+	}
+
+	if p.moduleName != "" || len(p.funcNamespace.names) > 0 || len(p.localNames) > 0 {
+		p.module.NameSection = &wasm.NameSection{
+			ModuleName:    p.moduleName,
+			FunctionNames: p.funcNamespace.names,
+			LocalNames:    p.localNames,
+		}
+	}
+	return nil
+}
+
+// defer adds the function to the pending ones, setting the context of its error.
+func (p *moduleParser) defer_(context string, f func() error) {
+	p.pending = append(p.pending, func() error {
+		return withContext(f(), context)
+	})
+}
+
+func (p *moduleParser) parseField(field *sexpr) error {
+	var context string
+	var err error
+	switch head := field.head(); head {
+	case "import":
+		context = fmt.Sprintf("module.import[%d]", len(p.module.ImportSection))
+		err = p.parseImport(field)
+	case "func", "table", "memory", "global":
+		context = fmt.Sprintf("module.%s[%d]", head, p.namespace(head).count)
+		c := newCursor(field)
+		id := c.optionalID()
+		var exports []*sexpr
+		for c.peekList("export") {
+			exports = append(exports, c.next())
+		}
+		var imp *wasm.Import
+		if c.peekList("import") {
+			if p.definedNonImport {
+				return withContext(errImportAfterDefinition(c.peek().tok), context)
+			}
+			ic := newCursor(c.next())
+			if imp, err = p.parseImportNames(ic); err == nil {
+				err = ic.expectDone()
+			}
+		}
+		if err == nil {
+			err = p.parseDescription(head, c, id, imp, exports)
+		}
+	case "export":
+		context = fmt.Sprintf("module.export[%d]", len(p.module.ExportSection))
+		err = p.parseExport(field, context)
+	case "start":
+		context = "module.start"
+		err = p.parseStart(field, context)
+	case "elem":
+		context = fmt.Sprintf("module.elem[%d]", p.elemNamespace.count)
+		err = p.parseElem(field, context)
+	case "data":
+		context = fmt.Sprintf("module.data[%d]", p.dataNamespace.count)
+		err = p.parseData(field, context)
+	default:
+		return errorf(field.tok, "expected module field, but was %s", field)
+	}
+	return withContext(err, context)
+}
+
+// namespace returns the index namespace of the kind of import or definition.
+func (p *moduleParser) namespace(kind string) *indexNamespace {
+	switch kind {
+	case "func":
+		return p.funcNamespace
+	case "table":
+		return p.tableNamespace
+	case "memory":
+		return p.memoryNamespace
+	}
+	return p.globalNamespace
+}
+
+// parseType parses a type definition, e.g. (type $t (func (param i32) (result i32))).
+func (p *moduleParser) parseType(field *sexpr) error {
+	c := newCursor(field)
+	id := c.optionalID()
+	if !c.peekList("func") {
+		return c.unexpected("(func ...)")
+	}
+	fc := newCursor(c.next())
+	ft, _, err := p.parseFuncType(fc)
+	if err != nil {
+		return err
+	} else if err = fc.expectDone(); err != nil {
+		return err
+	} else if err = c.expectDone(); err != nil {
+		return err
+	}
+
+	if _, err = p.typeNamespace.define(id); err != nil {
+		return err
+	}
+	p.module.TypeSection = append(p.module.TypeSection, ft)
+	return nil
+}
+
+// parseFuncType parses the parameters and results of a function type, and returns the identifiers of the parameters
+// in the same order, which are nil for unnamed ones.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#function-types%E2%91%A5
+func (p *moduleParser) parseFuncType(c *cursor) (*wasm.FunctionType, []*sexpr, error) {
+	ft := &wasm.FunctionType{}
+	var paramIDs []*sexpr
+	for c.peekList("param") {
+		pc := newCursor(c.next())
+		if id := pc.optionalID(); id != nil {
+			vt, err := p.parseValueType(pc)
+			if err != nil {
+				return nil, nil, err
+			} else if err = pc.expectDone(); err != nil {
+				return nil, nil, err
+			}
+			ft.Params = append(ft.Params, vt)
+			paramIDs = append(paramIDs, id)
+			continue
+		}
+		for !pc.done() {
+			vt, err := p.parseValueType(pc)
+			if err != nil {
+				return nil, nil, err
+			}
+			ft.Params = append(ft.Params, vt)
+			paramIDs = append(paramIDs, nil)
+		}
+	}
+
+	for c.peekList("result") {
+		rc := newCursor(c.next())
+		for !rc.done() {
+			vt, err := p.parseValueType(rc)
+			if err != nil {
+				return nil, nil, err
+			}
+			ft.Results = append(ft.Results, vt)
+			if len(ft.Results) > 1 {
+				if err = p.enabledFeatures.RequireEnabled(api.CoreFeatureMultiValue); err != nil {
+					return nil, nil, errorf(rc.end, "multiple result types invalid as %v", err)
+				}
+			}
+		}
+	}
+
+	// cache the key for the function type
+	_ = ft.String()
+	return ft, paramIDs, nil
+}
+
+// parseTypeUse parses a reference to a function type, which is either by the type index, the inline parameters and
+// results, or both, and returns the type index and the identifiers of the inline parameters.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#type-uses%E2%91%A0
+func (p *moduleParser) parseTypeUse(c *cursor) (wasm.Index, []*sexpr, error) {
+	if !c.peekList("type") {
+		ft, paramIDs, err := p.parseFuncType(c)
+		if err != nil {
+			return 0, nil, err
+		}
+		return p.typeIndexOf(ft), paramIDs, nil
+	}
+
+	tc := newCursor(c.next())
+	typeIdx, err := p.typeNamespace.resolve(tc)
+	if err != nil {
+		return 0, nil, err
+	} else if err = tc.expectDone(); err != nil {
+		return 0, nil, err
+	} else if typeIdx >= uint32(len(p.module.TypeSection)) {
+		return 0, nil, errorf(tc.end, "unknown type %d", typeIdx)
+	}
+
+	pos := c.position()
+	ft, paramIDs, err := p.parseFuncType(c)
+	if err != nil {
+		return 0, nil, err
+	}
+	if t := p.module.TypeSection[typeIdx]; len(ft.Params) > 0 || len(ft.Results) > 0 {
+		if !t.EqualsSignature(ft.Params, ft.Results) {
+			return 0, nil, errorf(pos, "inline function type %s doesn't match type %d %s", ft, typeIdx, t)
+		}
+	}
+	return typeIdx, paramIDs, nil
+}
+
+// typeIndexOf returns the index of the first type which equals the function type, after appending it if none.
+func (p *moduleParser) typeIndexOf(ft *wasm.FunctionType) wasm.Index {
+	for i, t := range p.module.TypeSection {
+		if t.Composite == nil && t.EqualsSignature(ft.Params, ft.Results) {
+			return wasm.Index(i)
+		}
+	}
+	p.module.TypeSection = append(p.module.TypeSection, ft)
+	return wasm.Index(len(p.module.TypeSection) - 1)
+}
+
+var valueTypes = map[string]wasm.ValueType{
+	"i32":       wasm.ValueTypeI32,
+	"i64":       wasm.ValueTypeI64,
+	"f32":       wasm.ValueTypeF32,
+	"f64":       wasm.ValueTypeF64,
+	"v128":      wasm.ValueTypeV128,
+	"funcref":   wasm.ValueTypeFuncref,
+	"externref": wasm.ValueTypeExternref,
+}
+
+func (p *moduleParser) parseValueType(c *cursor) (wasm.ValueType, error) {
+	if s := c.peek(); s != nil && s.isKeyword() {
+		if vt, ok := valueTypes[s.tok.text]; ok {
+			c.next()
+			return vt, nil
+		}
+	}
+	return 0, c.unexpected("value type")
+}
+
+func (p *moduleParser) parseRefType(c *cursor) (wasm.RefType, error) {
+	if c.peekKeyword("funcref") || c.peekKeyword("externref") {
+		return valueTypes[c.next().tok.text], nil
+	}
+	return 0, c.unexpected("reference type")
+}
+
+// parseLimits parses the minimum and the optional maximum of a table or a memory.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#limits%E2%91%A5
+func (p *moduleParser) parseLimits(c *cursor) (min uint32, max *uint32, err error) {
+	v, err := c.nextUint("minimum", 32)
+	if err != nil {
+		return 0, nil, err
+	}
+	min = uint32(v)
+	if c.peekAtom(tokenReserved) {
+		if v, err = c.nextUint("maximum", 32); err != nil {
+			return 0, nil, err
+		}
+		m := uint32(v)
+		max = &m
+	}
+	return
+}
+
+// parseImportNames parses the module and name of an import, and returns the wasm.Import without its description.
+func (p *moduleParser) parseImportNames(c *cursor) (*wasm.Import, error) {
+	module, err := c.nextName("module name")
+	if err != nil {
+		return nil, err
+	}
+	name, err := c.nextName("name")
+	if err != nil {
+		return nil, err
+	}
+	return &wasm.Import{Module: module, Name: name}, nil
+}
+
+// parseImport parses an import, e.g. (import "env" "f" (func $f (param i32))).
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#imports%E2%91%A0
+func (p *moduleParser) parseImport(field *sexpr) error {
+	if p.definedNonImport {
+		return errImportAfterDefinition(field.tok)
+	}
+	c := newCursor(field)
+	imp, err := p.parseImportNames(c)
+	if err != nil {
+		return err
+	}
+
+	switch c.peek().head() {
+	case "func", "table", "memory", "global":
+	default:
+		return c.unexpected("import description")
+	}
+	desc := c.next()
+	if err = c.expectDone(); err != nil {
+		return err
+	}
+
+	dc := newCursor(desc)
+	return p.parseDescription(desc.head(), dc, dc.optionalID(), imp, nil)
+}
+
+func errImportAfterDefinition(tok token) error {
+	return errorf(tok, "imports must occur before all non-import definitions")
+}
+
+// parseDescription parses the remaining of a function, table, memory or global after its identifier, inline exports
+// and an inline import if any. The imp is non-nil if this is an import.
+func (p *moduleParser) parseDescription(kind string, c *cursor, id *sexpr, imp *wasm.Import, exports []*sexpr) error {
+	if imp == nil {
+		p.definedNonImport = true
+	}
+
+	ns := p.namespace(kind)
+	idx, err := ns.define(id)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		ec := newCursor(export)
+		name, err := ec.nextName("export name")
+		if err != nil {
+			return err
+		} else if err = ec.expectDone(); err != nil {
+			return err
+		}
+		if _, err = p.addExport(export.tok, name, externTypeOf(kind), idx); err != nil {
+			return err
+		}
+	}
+
+	switch kind {
+	case "func":
+		return p.parseFunc(c, idx, imp)
+	case "table":
+		return p.parseTable(c, idx, imp)
+	case "memory":
+		return p.parseMemory(c, idx, imp)
+	default:
+		return p.parseGlobal(c, idx, imp)
+	}
+}
+
+func externTypeOf(kind string) wasm.ExternType {
+	switch kind {
+	case "func":
+		return wasm.ExternTypeFunc
+	case "table":
+		return wasm.ExternTypeTable
+	case "memory":
+		return wasm.ExternTypeMemory
+	}
+	return wasm.ExternTypeGlobal
+}
+
+// parseFunc parses the type use of a function, and its locals and body unless imported.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#functions%E2%91%A7
+func (p *moduleParser) parseFunc(c *cursor, idx wasm.Index, imp *wasm.Import) error {
+	typeIdx, paramIDs, err := p.parseTypeUse(c)
+	if err != nil {
+		return err
+	}
+
+	locals := newIndexNamespace("local")
+	for i := range p.module.TypeSection[typeIdx].Params {
+		var id *sexpr
+		if i < len(paramIDs) {
+			id = paramIDs[i]
+		}
+		if _, err = locals.define(id); err != nil {
+			return err
+		}
+	}
+
+	if imp != nil {
+		imp.Type, imp.DescFunc = wasm.ExternTypeFunc, typeIdx
+		p.module.ImportSection = append(p.module.ImportSection, imp)
+		if len(locals.names) > 0 {
+			p.localNames = append(p.localNames, &wasm.NameMapAssoc{Index: idx, NameMap: locals.names})
+		}
+		return c.expectDone()
+	}
+
+	code := &wasm.Code{}
+	p.module.FunctionSection = append(p.module.FunctionSection, typeIdx)
+	p.module.CodeSection = append(p.module.CodeSection, code)
+	for c.peekList("local") {
+		lc := newCursor(c.next())
+		if id := lc.optionalID(); id != nil {
+			vt, err := p.parseValueType(lc)
+			if err != nil {
+				return err
+			} else if err = lc.expectDone(); err != nil {
+				return err
+			}
+			if _, err = locals.define(id); err != nil {
+				return err
+			}
+			code.LocalTypes = append(code.LocalTypes, vt)
+			continue
+		}
+		for !lc.done() {
+			vt, err := p.parseValueType(lc)
+			if err != nil {
+				return err
+			}
+			_, _ = locals.define(nil)
+			code.LocalTypes = append(code.LocalTypes, vt)
+		}
+	}
+	if len(locals.names) > 0 {
+		p.localNames = append(p.localNames, &wasm.NameMapAssoc{Index: idx, NameMap: locals.names})
+	}
+
+	p.defer_(fmt.Sprintf("module.func[%d]", idx), func() error {
+		fp := &funcParser{m: p, locals: locals}
+		if err := fp.parseInstructions(c); err != nil {
+			return err
+		} else if len(fp.labels) > 0 {
+			return errorf(c.end, "expected end of %s", fp.labels[len(fp.labels)-1].kind)
+		}
+		code.Body = append(fp.body, wasm.OpcodeEnd)
+		return nil
+	})
+	return nil
+}
+
+// parseTable parses the limits and reference type of a table, or an inline element segment.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#tables%E2%91%A5
+func (p *moduleParser) parseTable(c *cursor, idx wasm.Index, imp *wasm.Import) error {
+	table := &wasm.Table{}
+	if imp == nil && (c.peekKeyword("funcref") || c.peekKeyword("externref")) {
+		// The table is sized by the inline element segment, e.g. (table funcref (elem $f $g)).
+		refType, err := p.parseRefType(c)
+		if err != nil {
+			return err
+		} else if !c.peekList("elem") {
+			return c.unexpected("(elem ...)")
+		}
+		ec := newCursor(c.next())
+		seg := &wasm.ElementSegment{
+			OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			TableIndex: idx,
+			Type:       refType,
+			Mode:       wasm.ElementModeActive,
+		}
+		// The items are either all function indexes or all element expressions.
+		s := ec.peek()
+		_, _ = p.elemNamespace.define(nil)
+		p.addElementSegment(ec, seg, s == nil || !s.isList)
+		size := uint32(len(ec.items))
+		table.Min, table.Max, table.Type = size, &size, refType
+	} else {
+		min, max, err := p.parseLimits(c)
+		if err != nil {
+			return err
+		}
+		refType, err := p.parseRefType(c)
+		if err != nil {
+			return err
+		}
+		table.Min, table.Max, table.Type = min, max, refType
+	}
+
+	if err := c.expectDone(); err != nil {
+		return err
+	}
+	if imp != nil {
+		imp.Type, imp.DescTable = wasm.ExternTypeTable, table
+		p.module.ImportSection = append(p.module.ImportSection, imp)
+	} else {
+		p.module.TableSection = append(p.module.TableSection, table)
+	}
+	return nil
+}
+
+// parseMemory parses the limits of a memory, or an inline data segment.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memories%E2%91%A5
+func (p *moduleParser) parseMemory(c *cursor, idx wasm.Index, imp *wasm.Import) error {
+	pos := c.position()
+	var min uint32
+	var max *uint32
+	if imp == nil && c.peekList("data") {
+		// The memory is sized by the inline data segment, e.g. (memory (data "hello")).
+		dc := newCursor(c.next())
+		seg := &wasm.DataSegment{
+			OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:             []byte{},
+			MemoryIndex:      idx,
+		}
+		for !dc.done() {
+			s, err := dc.nextString("data string")
+			if err != nil {
+				return err
+			}
+			seg.Init = append(seg.Init, s...)
+		}
+		_, _ = p.dataNamespace.define(nil)
+		p.module.DataSection = append(p.module.DataSection, seg)
+
+		pages := (uint32(len(seg.Init)) + wasm.MemoryPageSize - 1) / wasm.MemoryPageSize
+		min, max = pages, &pages
+	} else {
+		var err error
+		if min, max, err = p.parseLimits(c); err != nil {
+			return err
+		}
+	}
+
+	if err := c.expectDone(); err != nil {
+		return err
+	}
+
+	capacity, maxPages := min, p.memoryLimitPages
+	if max != nil {
+		maxPages = *max
+		if p.memoryCapacityFromMax {
+			capacity = maxPages
+		}
+	}
+	mem := &wasm.Memory{Min: min, Cap: capacity, Max: maxPages, IsMaxEncoded: max != nil}
+	if err := mem.Validate(p.memoryLimitPages); err != nil {
+		return errorf(pos, "%v", err)
+	}
+
+	if imp != nil {
+		imp.Type, imp.DescMem = wasm.ExternTypeMemory, mem
+		p.module.ImportSection = append(p.module.ImportSection, imp)
+	} else {
+		p.module.MemorySection = append(p.module.MemorySection, mem)
+	}
+	return nil
+}
+
+// parseGlobal parses the type of a global, and its initializer unless imported.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#globals%E2%91%A5
+func (p *moduleParser) parseGlobal(c *cursor, idx wasm.Index, imp *wasm.Import) error {
+	gt := &wasm.GlobalType{}
+	if c.peekList("mut") {
+		mc := newCursor(c.next())
+		vt, err := p.parseValueType(mc)
+		if err != nil {
+			return err
+		} else if err = mc.expectDone(); err != nil {
+			return err
+		}
+		gt.ValType, gt.Mutable = vt, true
+	} else {
+		vt, err := p.parseValueType(c)
+		if err != nil {
+			return err
+		}
+		gt.ValType = vt
+	}
+
+	if imp != nil {
+		imp.Type, imp.DescGlobal = wasm.ExternTypeGlobal, gt
+		p.module.ImportSection = append(p.module.ImportSection, imp)
+		return c.expectDone()
+	}
+
+	g := &wasm.Global{Type: gt}
+	p.module.GlobalSection = append(p.module.GlobalSection, g)
+	p.defer_(fmt.Sprintf("module.global[%d]", idx), func() (err error) {
+		g.Init, err = p.parseConstantExpression(c)
+		return
+	})
+	return nil
+}
+
+// parseExport parses an export, e.g. (export "f" (func $f)).
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
+func (p *moduleParser) parseExport(field *sexpr, context string) error {
+	c := newCursor(field)
+	name, err := c.nextName("export name")
+	if err != nil {
+		return err
+	}
+
+	kind := c.peek().head()
+	switch kind {
+	case "func", "table", "memory", "global":
+	default:
+		return c.unexpected("export description")
+	}
+	dc := newCursor(c.next())
+	if err = c.expectDone(); err != nil {
+		return err
+	}
+
+	export, err := p.addExport(field.tok, name, externTypeOf(kind), 0)
+	if err != nil {
+		return err
+	}
+	p.defer_(context, func() (err error) {
+		if export.Index, err = p.namespace(kind).resolve(dc); err != nil {
+			return err
+		}
+		return dc.expectDone()
+	})
+	return nil
+}
+
+func (p *moduleParser) addExport(tok token, name string, externType wasm.ExternType, idx wasm.Index) (*wasm.Export, error) {
+	if _, ok := p.exportNames[name]; ok {
+		return nil, errorf(tok, "duplicate export name %q", name)
+	}
+	p.exportNames[name] = struct{}{}
+	export := &wasm.Export{Type: externType, Name: name, Index: idx}
+	p.module.ExportSection = append(p.module.ExportSection, export)
+	return export, nil
+}
+
+// parseStart parses the start function, e.g. (start $main).
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#start-function%E2%91%A3
+func (p *moduleParser) parseStart(field *sexpr, context string) error {
+	if p.module.StartSection != nil {
+		return errorf(field.tok, "multiple start functions")
+	}
+	start := new(wasm.Index)
+	p.module.StartSection = start
+	c := newCursor(field)
+	p.defer_(context, func() (err error) {
+		if *start, err = p.funcNamespace.resolve(c); err != nil {
+			return err
+		}
+		return c.expectDone()
+	})
+	return nil
+}
+
+// parseElem parses an element segment, which is either active, passive or declarative.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/text/modules.html#element-segments
+func (p *moduleParser) parseElem(field *sexpr, context string) error {
+	c := newCursor(field)
+	if _, err := p.elemNamespace.define(c.optionalID()); err != nil {
+		return err
+	}
+
+	seg := &wasm.ElementSegment{Mode: wasm.ElementModePassive}
+	legacy := false
+	if c.peekKeyword("declare") {
+		c.next()
+		seg.Mode = wasm.ElementModeDeclarative
+	} else if s := c.peek(); s != nil && (s.isList || peekIndex(c)) {
+		seg.Mode = wasm.ElementModeActive
+		var table *cursor
+		if c.peekList("table") {
+			table = newCursor(c.next())
+		} else if !s.isList {
+			// WebAssembly 1.0 allows the table index without (table ...), e.g. (elem 0 (i32.const 0) $f).
+			table = &cursor{items: []*sexpr{c.next()}, end: s.tok}
+		}
+		offset, err := p.offsetCursor(c)
+		if err != nil {
+			return err
+		}
+		// The element type can be omitted to be compatible with WebAssembly 1.0.
+		legacy = !c.peekKeyword("func") && !c.peekKeyword("funcref") && !c.peekKeyword("externref")
+		p.defer_(context, func() (err error) {
+			if table != nil {
+				if seg.TableIndex, err = p.tableNamespace.resolve(table); err != nil {
+					return err
+				} else if err = table.expectDone(); err != nil {
+					return err
+				}
+			}
+			seg.OffsetExpr, err = p.parseConstantExpression(offset)
+			return
+		})
+	}
+
+	indexes := legacy
+	if legacy {
+		seg.Type = wasm.RefTypeFuncref
+	} else if c.peekKeyword("func") {
+		c.next()
+		seg.Type, indexes = wasm.RefTypeFuncref, true
+	} else {
+		refType, err := p.parseRefType(c)
+		if err != nil {
+			return err
+		}
+		seg.Type = refType
+	}
+	p.addElementSegment(c, seg, indexes)
+	return nil
+}
+
+// addElementSegment appends the element segment whose items are the remaining of the cursor, which are either function
+// indexes or element expressions, e.g. (ref.func $f). The items are resolved when pending.
+func (p *moduleParser) addElementSegment(c *cursor, seg *wasm.ElementSegment, indexes bool) {
+	p.module.ElementSection = append(p.module.ElementSection, seg)
+	context := fmt.Sprintf("module.elem[%d]", len(p.module.ElementSection)-1)
+	seg.Init = make([]*wasm.Index, len(c.items))
+	items := c.items
+	p.defer_(context, func() error {
+		for i, item := range items {
+			if indexes {
+				idx, err := p.funcNamespace.resolve(&cursor{items: []*sexpr{item}, end: c.end})
+				if err != nil {
+					return err
+				}
+				seg.Init[i] = &idx
+				continue
+			}
+
+			if !item.isList {
+				return errorf(item.tok, "expected element expression, but was %s", item)
+			}
+			expr, err := p.parseConstantExpression(p.itemCursor(item))
+			if err != nil {
+				return err
+			}
+			switch expr.Opcode {
+			case wasm.OpcodeRefNull:
+			case wasm.OpcodeRefFunc:
+				idx, _, err := leb128.LoadUint32(expr.Data)
+				if err != nil {
+					return errorf(item.tok, "%v", err)
+				}
+				seg.Init[i] = &idx
+			default:
+				return errorf(item.tok, "expected ref.func or ref.null, but was %s", item)
+			}
+		}
+		return nil
+	})
+}
+
+// parseData parses a data segment, which is either active or passive.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/text/modules.html#data-segments
+func (p *moduleParser) parseData(field *sexpr, context string) error {
+	c := newCursor(field)
+	if _, err := p.dataNamespace.define(c.optionalID()); err != nil {
+		return err
+	}
+
+	seg := &wasm.DataSegment{Init: []byte{}}
+	p.module.DataSection = append(p.module.DataSection, seg)
+	if s := c.peek(); s != nil && (s.isList || peekIndex(c)) {
+		var memory *cursor
+		if c.peekList("memory") {
+			memory = newCursor(c.next())
+		} else if !s.isList {
+			// WebAssembly 1.0 allows the memory index without (memory ...), e.g. (data 0 (i32.const 0) "hello").
+			memory = &cursor{items: []*sexpr{c.next()}, end: s.tok}
+		}
+		offset, err := p.offsetCursor(c)
+		if err != nil {
+			return err
+		}
+		p.defer_(context, func() (err error) {
+			if memory != nil {
+				if seg.MemoryIndex, err = p.memoryNamespace.resolve(memory); err != nil {
+					return err
+				} else if err = memory.expectDone(); err != nil {
+					return err
+				}
+			}
+			seg.OffsetExpression, err = p.parseConstantExpression(offset)
+			return
+		})
+	}
+
+	for !c.done() {
+		s, err := c.nextString("data string")
+		if err != nil {
+			return err
+		}
+		seg.Init = append(seg.Init, s...)
+	}
+	return nil
+}
+
+// offsetCursor returns the instructions of the offset of a segment, which is either (offset instr*) or a folded
+// instruction, and moves past it.
+func (p *moduleParser) offsetCursor(c *cursor) (*cursor, error) {
+	if c.peekList("offset") {
+		return newCursor(c.next()), nil
+	} else if s := c.peek(); s == nil || !s.isList {
+		return nil, c.unexpected("(offset ...)")
+	}
+	return p.itemCursor(c.next()), nil
+}
+
+// itemCursor returns the instructions of an element expression which is either (item instr*) or a folded instruction.
+func (p *moduleParser) itemCursor(s *sexpr) *cursor {
+	if s.head() == "item" {
+		return newCursor(s)
+	}
+	return &cursor{items: []*sexpr{s}, end: s.end}
+}
+
+// parseConstantExpression parses the remaining instructions of the cursor as a constant expression.
+func (p *moduleParser) parseConstantExpression(c *cursor) (*wasm.ConstantExpression, error) {
+	pos := c.position()
+	fp := &funcParser{m: p, locals: newIndexNamespace("local")}
+	if err := fp.parseInstructions(c); err != nil {
+		return nil, err
+	} else if fp.count == 0 {
+		return nil, errorf(pos, "expected constant expression")
+	} else if fp.count > 1 {
+		if err := p.enabledFeatures.RequireEnabled(api.CoreFeatureExtendedConst); err != nil {
+			return nil, errorf(pos, "multiple instructions in constant expression invalid as %v", err)
+		}
+	}
+
+	// The first opcode is held in ConstantExpression.Opcode, which is the opcode suffix of v128.const.
+	if fp.body[0] == wasm.OpcodeVecPrefix {
+		return &wasm.ConstantExpression{Opcode: fp.body[1], Data: fp.body[2:]}, nil
+	}
+	return &wasm.ConstantExpression{Opcode: fp.body[0], Data: fp.body[1:]}, nil
+}
+
+// indexNamespace is an index space of the module, e.g. functions, which can be referred by identifiers.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#indices%E2%91%A4
+type indexNamespace struct {
+	// kind is the kind of the index in errors, e.g. "func".
+	kind string
+	// count is the number of the indexes defined so far.
+	count wasm.Index
+	// ids are the indexes of identifiers including the leading '$'.
+	ids map[string]wasm.Index
+	// names are the identifiers without the leading '$' in ascending order of the index.
+	names wasm.NameMap
+}
+
+func newIndexNamespace(kind string) *indexNamespace {
+	return &indexNamespace{kind: kind, ids: map[string]wasm.Index{}}
+}
+
+// define returns the next index, and associates it with the identifier unless nil.
+func (n *indexNamespace) define(id *sexpr) (wasm.Index, error) {
+	idx := n.count
+	if id != nil {
+		if _, ok := n.ids[id.tok.text]; ok {
+			return 0, errorf(id.tok, "duplicate %s %s", n.kind, id.tok.text)
+		}
+		n.ids[id.tok.text] = idx
+		n.names = append(n.names, &wasm.NameAssoc{Index: idx, Name: id.tok.text[1:]})
+	}
+	n.count++
+	return idx, nil
+}
+
+// peekIndex returns true if the next item of the cursor is an identifier or an unsigned integer.
+func peekIndex(c *cursor) bool {
+	if c.peekAtom(tokenID) {
+		return true
+	}
+	return c.peekAtom(tokenReserved) && c.peek().tok.text[0] >= '0' && c.peek().tok.text[0] <= '9'
+}
+
+// resolve returns the index of the next item of the cursor which is either an identifier or an integer.
+func (n *indexNamespace) resolve(c *cursor) (wasm.Index, error) {
+	if !peekIndex(c) {
+		return 0, c.unexpected(n.kind + " index")
+	}
+	s := c.next()
+	if s.tok.tokenType == tokenID {
+		idx, ok := n.ids[s.tok.text]
+		if !ok {
+			return 0, errorf(s.tok, "unknown %s %s", n.kind, s.tok.text)
+		}
+		return idx, nil
+	}
+	v, err := parseUint(s.tok.text, 32)
+	if err != nil {
+		return 0, errorf(s.tok, "%s: %v", s.tok.text, err)
+	}
+	return wasm.Index(v), nil
+}
@@ -0,0 +1,629 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestDecodeModule(t *testing.T) {
+	i32, i64, f32, v128 := wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.ValueTypeF32, wasm.ValueTypeV128
+	zero, one, two := uint32(0), uint32(1), uint32(2)
+	i32Const0 := &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected *wasm.Module
+	}{
+		{
+			name:     "empty",
+			input:    "(module)",
+			expected: &wasm.Module{},
+		},
+		{
+			name:     "module name",
+			input:    "(module $simple)",
+			expected: &wasm.Module{NameSection: &wasm.NameSection{ModuleName: "simple"}},
+		},
+		{
+			name: "type section",
+			input: `(module
+	(type (func))
+	(type $add (func (param i32 i32) (result i32)))
+	(type (func (param $x i64) (param f32)))
+)`,
+			expected: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{
+					{},
+					{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+					{Params: []wasm.ValueType{i64, f32}},
+				},
+			},
+		},
+		{
+			name: "import section",
+			input: `(module
+	(type $i32_i32 (func (param i32) (result i32)))
+	(import "env" "f" (func $f (type $i32_i32)))
+	(import "env" "g" (func (param i64)))
+	(import "env" "memory" (memory 1 2))
+	(import "env" "table" (table 1 funcref))
+	(import "env" "global" (global (mut i32)))
+)`,
+			expected: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{
+					{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+					{Params: []wasm.ValueType{i64}},
+				},
+				ImportSection: []*wasm.Import{
+					{Module: "env", Name: "f", Type: wasm.ExternTypeFunc, DescFunc: 0},
+					{Module: "env", Name: "g", Type: wasm.ExternTypeFunc, DescFunc: 1},
+					{
+						Module: "env", Name: "memory", Type: wasm.ExternTypeMemory,
+						DescMem: &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true},
+					},
+					{
+						Module: "env", Name: "table", Type: wasm.ExternTypeTable,
+						DescTable: &wasm.Table{Min: 1, Type: wasm.RefTypeFuncref},
+					},
+					{
+						Module: "env", Name: "global", Type: wasm.ExternTypeGlobal,
+						DescGlobal: &wasm.GlobalType{ValType: i32, Mutable: true},
+					},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "f"}},
+				},
+			},
+		},
+		{
+			name: "inline import",
+			input: `(module
+	(func $log (import "env" "log") (param i32))
+)`,
+			expected: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{{Params: []wasm.ValueType{i32}}},
+				ImportSection: []*wasm.Import{
+					{Module: "env", Name: "log", Type: wasm.ExternTypeFunc, DescFunc: 0},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}},
+				},
+			},
+		},
+		{
+			name: "func with names",
+			input: `(module $math
+	(func $add (export "add") (param $x i32) (param $y i32) (result i32) (local $tmp i32) (local i64)
+		local.get $x
+		local.get $y
+		i32.add
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					LocalTypes: []wasm.ValueType{i32, i64},
+					Body: []byte{
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeI32Add,
+						wasm.OpcodeEnd,
+					},
+				}},
+				ExportSection: []*wasm.Export{{Type: wasm.ExternTypeFunc, Name: "add", Index: 0}},
+				NameSection: &wasm.NameSection{
+					ModuleName:    "math",
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "add"}},
+					LocalNames: wasm.IndirectNameMap{
+						{Index: 0, NameMap: wasm.NameMap{{Index: 0, Name: "x"}, {Index: 1, Name: "y"}, {Index: 2, Name: "tmp"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "imported func with names",
+			input: `(module
+	(import "env" "log" (func $log (param $msg i32) (param i32)))
+)`,
+			expected: &wasm.Module{
+				TypeSection:   []*wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}}},
+				ImportSection: []*wasm.Import{{Type: wasm.ExternTypeFunc, Module: "env", Name: "log", DescFunc: 0}},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}},
+					LocalNames: wasm.IndirectNameMap{
+						{Index: 0, NameMap: wasm.NameMap{{Index: 0, Name: "msg"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "func index after imports",
+			input: `(module
+	(import "env" "f" (func))
+	(func $g call $h)
+	(func $h call 0)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				ImportSection:   []*wasm.Import{{Module: "env", Name: "f", Type: wasm.ExternTypeFunc}},
+				FunctionSection: []wasm.Index{0, 0},
+				CodeSection: []*wasm.Code{
+					{Body: []byte{wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+					{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 1, Name: "g"}, {Index: 2, Name: "h"}},
+				},
+			},
+		},
+		{
+			name: "folded instructions",
+			input: `(module
+	(func (param i32) (result i32)
+		(if (result i32) (local.get 0)
+			(then (i32.add (local.get 0) (i32.const 1)))
+			(else (i32.const -1))
+		)
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeIf, i32,
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Const, 1,
+						wasm.OpcodeI32Add,
+						wasm.OpcodeElse,
+						wasm.OpcodeI32Const, 0x7f,
+						wasm.OpcodeEnd,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "labels",
+			input: `(module
+	(func
+		block $outer
+			loop $inner
+				br $inner
+				(br_if $outer (i32.const 1))
+				br_table $inner $outer 0
+			end $inner
+		end
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeBlock, 0x40,
+						wasm.OpcodeLoop, 0x40,
+						wasm.OpcodeBr, 0,
+						wasm.OpcodeI32Const, 1,
+						wasm.OpcodeBrIf, 1,
+						wasm.OpcodeBrTable, 2, 0, 1, 0,
+						wasm.OpcodeEnd,
+						wasm.OpcodeEnd,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "multi-value block type",
+			input: `(module
+	(func (result i32 i32)
+		(block (result i32 i32) (i32.const 1) (i32.const 2))
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Results: []wasm.ValueType{i32, i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeBlock, 0, // type index zero
+						wasm.OpcodeI32Const, 1,
+						wasm.OpcodeI32Const, 2,
+						wasm.OpcodeEnd,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "memory instructions",
+			input: `(module
+	(memory 1)
+	(func (param i32) (result i64)
+		(i64.load offset=8 align=4 (local.get 0))
+		(i32.store8 (local.get 0) (i32.const 0))
+		memory.size
+		drop
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i64}}},
+				FunctionSection: []wasm.Index{0},
+				MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI64Load, 2, 8, // align=4 is encoded as log2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Const, 0,
+						wasm.OpcodeI32Store8, 0, 0,
+						wasm.OpcodeMemorySize, 0,
+						wasm.OpcodeDrop,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "constants",
+			input: `(module
+	(func
+		(drop (i64.const 0xffff_ffff))
+		(drop (f32.const -0x1p-1))
+		(drop (f32.const nan))
+		(drop (v128.const i32x4 1 2 3 -1))
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeI64Const, 0xff, 0xff, 0xff, 0xff, 0x0f,
+						wasm.OpcodeDrop,
+						wasm.OpcodeF32Const, 0x00, 0x00, 0x00, 0xbf,
+						wasm.OpcodeDrop,
+						wasm.OpcodeF32Const, 0x00, 0x00, 0xc0, 0x7f,
+						wasm.OpcodeDrop,
+						wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+						1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 0xff, 0xff, 0xff, 0xff,
+						wasm.OpcodeDrop,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "typed select",
+			input: `(module
+	(func (param v128 v128 i32) (result v128)
+		(select (result v128) (local.get 0) (local.get 1) (local.get 2))
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{v128, v128, i32}, Results: []wasm.ValueType{v128}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeTypedSelect, 1, v128,
+						wasm.OpcodeEnd,
+					},
+				}},
+			},
+		},
+		{
+			name: "globals",
+			input: `(module
+	(global $g (mut i32) (i32.const 1))
+	(global i64 (i64.const -1))
+	(func (result i32) global.get $g)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				GlobalSection: []*wasm.Global{
+					{
+						Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+					},
+					{
+						Type: &wasm.GlobalType{ValType: i64},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0x7f}},
+					},
+				},
+				CodeSection: []*wasm.Code{{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd}}},
+			},
+		},
+		{
+			name: "exports and start",
+			input: `(module
+	(func $main)
+	(memory $mem 1)
+	(export "main" (func $main))
+	(export "memory" (memory $mem))
+	(start $main)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+				ExportSection: []*wasm.Export{
+					{Type: wasm.ExternTypeFunc, Name: "main", Index: 0},
+					{Type: wasm.ExternTypeMemory, Name: "memory", Index: 0},
+				},
+				StartSection: &zero,
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "main"}},
+				},
+			},
+		},
+		{
+			name: "inline data and elem",
+			input: `(module
+	(memory (data "hello" "\00"))
+	(table funcref (elem 0 1))
+	(func)
+	(func)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0, 0},
+				TableSection:    []*wasm.Table{{Min: 2, Max: &two, Type: wasm.RefTypeFuncref}},
+				MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+				ElementSection: []*wasm.ElementSegment{
+					{
+						OffsetExpr: i32Const0,
+						Init:       []*wasm.Index{&zero, &one},
+						Type:       wasm.RefTypeFuncref,
+						Mode:       wasm.ElementModeActive,
+					},
+				},
+				CodeSection: []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}, {Body: []byte{wasm.OpcodeEnd}}},
+				DataSection: []*wasm.DataSegment{
+					{OffsetExpression: i32Const0, Init: []byte("hello\x00")},
+				},
+			},
+		},
+		{
+			name: "element segments",
+			input: `(module
+	(table $t 2 funcref)
+	(func $f)
+	(elem (i32.const 0) $f)
+	(elem $passive funcref (ref.func $f) (ref.null func))
+	(elem declare func $f)
+	(func
+		(table.init $t $passive (i32.const 0) (i32.const 0) (i32.const 2))
+		elem.drop $passive
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0, 0},
+				TableSection:    []*wasm.Table{{Min: 2, Type: wasm.RefTypeFuncref}},
+				ElementSection: []*wasm.ElementSegment{
+					{
+						OffsetExpr: i32Const0,
+						Init:       []*wasm.Index{&zero},
+						Type:       wasm.RefTypeFuncref,
+						Mode:       wasm.ElementModeActive,
+					},
+					{
+						Init: []*wasm.Index{&zero, nil},
+						Type: wasm.RefTypeFuncref,
+						Mode: wasm.ElementModePassive,
+					},
+					{
+						Init: []*wasm.Index{&zero},
+						Type: wasm.RefTypeFuncref,
+						Mode: wasm.ElementModeDeclarative,
+					},
+				},
+				CodeSection: []*wasm.Code{
+					{Body: []byte{wasm.OpcodeEnd}},
+					{Body: []byte{
+						wasm.OpcodeI32Const, 0,
+						wasm.OpcodeI32Const, 0,
+						wasm.OpcodeI32Const, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscTableInit, 1, 0,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscElemDrop, 1,
+						wasm.OpcodeEnd,
+					}},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "f"}},
+				},
+			},
+		},
+		{
+			name: "data segments",
+			input: `(module
+	(memory 1)
+	(data (i32.const 8) "hi")
+	(data $passive "\de\ad")
+	(func
+		(memory.init $passive (i32.const 0) (i32.const 0) (i32.const 2))
+		data.drop $passive
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				MemorySection:   []*wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeI32Const, 0,
+					wasm.OpcodeI32Const, 0,
+					wasm.OpcodeI32Const, 2,
+					wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 0,
+					wasm.OpcodeMiscPrefix, wasm.OpcodeMiscDataDrop, 1,
+					wasm.OpcodeEnd,
+				}}},
+				DataSection: []*wasm.DataSegment{
+					{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{8}}, Init: []byte("hi")},
+					{Init: []byte{0xde, 0xad}},
+				},
+				DataCountSection: &two,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := DecodeModule([]byte(tc.input), api.CoreFeaturesV2, wasm.MemoryLimitPages, false)
+			require.NoError(t, err)
+			// Set the FunctionType keys on the expected.
+			for _, f := range tc.expected.TypeSection {
+				_ = f.String()
+			}
+			require.Equal(t, tc.expected, m)
+		})
+	}
+}
+
+func TestDecodeModule_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		enabledFeatures api.CoreFeatures
+		expectedErr     string
+	}{
+		{
+			name:        "empty",
+			input:       "",
+			expectedErr: "1:1: expected module, but was EOF",
+		},
+		{
+			name:        "only whitespace and comments",
+			input:       " \n;; (module)\n(; (module) ;)",
+			expectedErr: "3:15: expected module, but was EOF",
+		},
+		{
+			name:        "unexpected character",
+			input:       "(module\n  {)",
+			expectedErr: "2:3: unexpected character '{'",
+		},
+		{
+			name:        "unclosed module",
+			input:       "(module\n  (func)",
+			expectedErr: "2:9: expected ')' to close '(' at 1:1",
+		},
+		{
+			name:        "unknown field",
+			input:       "(module (fun))",
+			expectedErr: "1:9: expected module field, but was (fun ...)",
+		},
+		{
+			name:        "unknown instruction",
+			input:       "(module\n\t(func\n\t\ti32.ad))",
+			expectedErr: "3:3: unknown instruction i32.ad in module.func[0]",
+		},
+		{
+			name:        "unknown func",
+			input:       "(module (func call $nope))",
+			expectedErr: "1:20: unknown func $nope in module.func[0]",
+		},
+		{
+			name:        "unknown local",
+			input:       "(module (func (param $x i32) local.get $y drop))",
+			expectedErr: "1:40: unknown local $y in module.func[0]",
+		},
+		{
+			name:        "unknown label",
+			input:       "(module (func block $l br $m end))",
+			expectedErr: "1:27: unknown label $m in module.func[0]",
+		},
+		{
+			name:        "mismatching label",
+			input:       "(module (func block $l end $m))",
+			expectedErr: "1:28: mismatching label $m in module.func[0]",
+		},
+		{
+			name:        "block not ended",
+			input:       "(module (func block))",
+			expectedErr: "1:20: expected end of block in module.func[0]",
+		},
+		{
+			name:        "duplicate func",
+			input:       "(module (func $f) (func $f))",
+			expectedErr: "1:25: duplicate func $f in module.func[1]",
+		},
+		{
+			name:        "duplicate export",
+			input:       "(module (func (export \"f\")) (func (export \"f\")))",
+			expectedErr: "1:35: duplicate export name \"f\" in module.func[1]",
+		},
+		{
+			name:        "import after func",
+			input:       "(module (func) (import \"env\" \"f\" (func)))",
+			expectedErr: "1:16: imports must occur before all non-import definitions in module.import[0]",
+		},
+		{
+			name:        "i32 out of range",
+			input:       "(module (func i32.const 0x1_0000_0000 drop))",
+			expectedErr: "1:25: 0x1_0000_0000: constant out of range in module.func[0]",
+		},
+		{
+			name:        "invalid alignment",
+			input:       "(module (memory 1) (func i32.const 0 i32.load align=3 drop))",
+			expectedErr: "1:47: alignment must be a power of two: align=3 in module.func[0]",
+		},
+		{
+			name:        "inline type mismatch",
+			input:       "(module (type (func)) (func (type 0) (param i32)))",
+			expectedErr: "1:38: inline function type i32_v doesn't match type 0 v_v in module.func[0]",
+		},
+		{
+			name:            "multiple results without multi-value",
+			input:           "(module (func (result i32 i32)))",
+			enabledFeatures: api.CoreFeaturesV1,
+			expectedErr:     "1:30: multiple result types invalid as feature \"multi-value\" is disabled in module.func[0]",
+		},
+		{
+			name:            "extended constant expression disabled",
+			input:           "(module (global i32 (i32.add (i32.const 1) (i32.const 2))))",
+			enabledFeatures: api.CoreFeaturesV2,
+			expectedErr:     "1:21: multiple instructions in constant expression invalid as feature \"extended-const\" is disabled in module.global[0]",
+		},
+		{
+			name:        "memory too large",
+			input:       "(module (memory 1 70000))",
+			expectedErr: "1:17: max 70000 pages (4 Gi) over limit of 65536 pages (4 Gi) in module.memory[0]",
+		},
+		{
+			name:        "missing whitespace",
+			input:       "(module (data \"a\"\"b\"))",
+			expectedErr: "1:18: expected whitespace before '\"'",
+		},
+		{
+			name:        "invalid UTF-8 name",
+			input:       "(module (func (export \"\\80\")))",
+			expectedErr: "1:23: malformed UTF-8 encoding of export name in module.func[0]",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.enabledFeatures == 0 {
+				tc.enabledFeatures = api.CoreFeaturesV2
+			}
+			_, err := DecodeModule([]byte(tc.input), tc.enabledFeatures, wasm.MemoryLimitPages, false)
+			require.EqualError(t, err, tc.expectedErr)
+			_, ok := err.(*FormatError)
+			require.True(t, ok)
+		})
+	}
+}
@@ -0,0 +1,667 @@
+package text
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// EncodeModule encodes the module in the WebAssembly Text Format (%.wat), which DecodeModule can decode back.
+//
+// The names in the wasm.NameSection are used as identifiers, e.g. "$main", after replacing any characters not allowed.
+// Features beyond api.CoreFeaturesV2, except api.CoreFeatureRelaxedSIMD and api.CoreFeatureExtendedConst, aren't
+// supported by the text format yet, so the rest of a function body with such an instruction is a comment.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+func EncodeModule(m *wasm.Module) []byte {
+	e := newEncoder(m, false)
+	e.module()
+	return []byte(e.String())
+}
+
+// EncodeFunction encodes the function of the index in the WebAssembly Text Format (%.wat). Each instruction is
+// preceded by a comment of its offset in the code section, e.g. "(;@1a;)", which is what DWARF uses as the program
+// counter. There are no such comments unless the module was decoded from the binary format.
+//
+// Note: The function index includes imported functions.
+func EncodeFunction(m *wasm.Module, funcIdx wasm.Index) ([]byte, error) {
+	importCount := m.ImportFuncCount()
+	if funcIdx >= importCount+uint32(len(m.FunctionSection)) {
+		return nil, fmt.Errorf("function[%d] not found", funcIdx)
+	}
+	e := newEncoder(m, true)
+	if funcIdx < importCount {
+		i := funcIdx
+		for _, imp := range m.ImportSection {
+			if imp.Type != wasm.ExternTypeFunc {
+				continue
+			} else if i == 0 {
+				e.importField(imp, funcIdx)
+				break
+			}
+			i--
+		}
+	} else {
+		e.function(funcIdx, 0)
+	}
+	e.WriteString("\n")
+	return []byte(e.String()), nil
+}
+
+// encoder writes a module or a part of it in the text format.
+type encoder struct {
+	strings.Builder
+	m *wasm.Module
+	// offsets is true to comment the offset of each instruction in the code section.
+	offsets bool
+	// funcIDs are the identifiers of the named functions including the leading '$'.
+	funcIDs map[wasm.Index]string
+	// localIDs are the identifiers of the named parameters and locals of each function.
+	localIDs map[wasm.Index]map[wasm.Index]string
+	// byOpcode are the plain instructions keyed by their encoding without immediates.
+	byOpcode map[string]string
+}
+
+func newEncoder(m *wasm.Module, offsets bool) *encoder {
+	e := &encoder{m: m, offsets: offsets, funcIDs: map[wasm.Index]string{}, localIDs: map[wasm.Index]map[wasm.Index]string{}}
+	if ns := m.NameSection; ns != nil {
+		e.funcIDs = idsOf(ns.FunctionNames)
+		for _, locals := range ns.LocalNames {
+			e.localIDs[locals.Index] = idsOf(locals.NameMap)
+		}
+	}
+	return e
+}
+
+// idsOf returns the unique identifiers of the names, skipping any which would be a duplicate.
+func idsOf(names wasm.NameMap) map[wasm.Index]string {
+	ret := make(map[wasm.Index]string, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		id := toID(n.Name)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ret[n.Index] = id
+	}
+	return ret
+}
+
+// toID returns the identifier of the name, replacing characters not allowed, e.g. "main.(*T).f" is "$main._*T_.f".
+func toID(name string) string {
+	id := []byte("$" + name)
+	for i := 1; i < len(id); i++ {
+		if !isIDChar(id[i]) {
+			id[i] = '_'
+		}
+	}
+	return string(id)
+}
+
+// byOpcodeMap lazily inverts instructions, as it is only needed for the instructions of function bodies.
+func (e *encoder) byOpcodeMap() map[string]string {
+	if e.byOpcode == nil {
+		e.byOpcode = make(map[string]string, len(instructions))
+		for name, in := range instructions {
+			e.byOpcode[string(in.opcode)] = name
+		}
+	}
+	return e.byOpcode
+}
+
+// module writes the whole module, with the fields in the order of the binary format sections.
+func (e *encoder) module() {
+	m := e.m
+	e.WriteString("(module")
+	if ns := m.NameSection; ns != nil && ns.ModuleName != "" {
+		e.WriteString(" " + toID(ns.ModuleName))
+	}
+
+	for i, t := range m.TypeSection {
+		fmt.Fprintf(e, "\n  (type (;%d;) ", i)
+		if t.Composite != nil {
+			e.WriteString("(; struct and array types aren't supported ;))")
+			continue
+		}
+		e.WriteString("(func")
+		e.signature(t, nil)
+		e.WriteString("))")
+	}
+
+	var counts [wasm.ExternTypeTag + 1]wasm.Index // by wasm.ExternType
+	for _, imp := range m.ImportSection {
+		e.WriteString("\n  ")
+		e.importField(imp, counts[imp.Type])
+		counts[imp.Type]++
+	}
+
+	for i := range m.FunctionSection {
+		e.WriteString("\n  ")
+		e.function(counts[wasm.ExternTypeFunc]+wasm.Index(i), 1)
+	}
+
+	for i, t := range m.TableSection {
+		fmt.Fprintf(e, "\n  (table (;%d;) ", counts[wasm.ExternTypeTable]+wasm.Index(i))
+		e.table(t)
+		e.WriteString(")")
+	}
+
+	for i, mem := range m.MemorySection {
+		fmt.Fprintf(e, "\n  (memory (;%d;) ", counts[wasm.ExternTypeMemory]+wasm.Index(i))
+		e.memory(mem)
+		e.WriteString(")")
+	}
+
+	for i, g := range m.GlobalSection {
+		fmt.Fprintf(e, "\n  (global (;%d;) ", counts[wasm.ExternTypeGlobal]+wasm.Index(i))
+		e.globalType(g.Type)
+		for _, instr := range e.constantExpression(g.Init) {
+			e.WriteString(" (" + instr + ")")
+		}
+		e.WriteString(")")
+	}
+
+	for _, export := range m.ExportSection {
+		fmt.Fprintf(e, "\n  (export %s (%s ", quote(export.Name), wasm.ExternTypeName(export.Type))
+		if export.Type == wasm.ExternTypeFunc {
+			e.WriteString(e.funcRef(export.Index))
+		} else {
+			e.WriteString(strconv.FormatUint(uint64(export.Index), 10))
+		}
+		e.WriteString("))")
+	}
+
+	if m.StartSection != nil {
+		fmt.Fprintf(e, "\n  (start %s)", e.funcRef(*m.StartSection))
+	}
+
+	for i, seg := range m.ElementSection {
+		e.elementSegment(wasm.Index(i), seg)
+	}
+
+	for i, seg := range m.DataSection {
+		fmt.Fprintf(e, "\n  (data (;%d;) ", i)
+		if seg.OffsetExpression != nil {
+			if seg.MemoryIndex != 0 {
+				fmt.Fprintf(e, "(memory %d) ", seg.MemoryIndex)
+			}
+			e.offset(seg.OffsetExpression)
+			e.WriteString(" ")
+		}
+		e.WriteString(quote(string(seg.Init)) + ")")
+	}
+	e.WriteString(")\n")
+}
+
+// importField writes the import, where the index is the one in the index space of its type.
+func (e *encoder) importField(imp *wasm.Import, idx wasm.Index) {
+	fmt.Fprintf(e, "(import %s %s (%s ", quote(imp.Module), quote(imp.Name), wasm.ExternTypeName(imp.Type))
+	switch imp.Type {
+	case wasm.ExternTypeFunc:
+		e.WriteString(e.funcID(idx))
+		fmt.Fprintf(e, "(type %d)", imp.DescFunc)
+		if int(imp.DescFunc) < len(e.m.TypeSection) {
+			e.signature(e.m.TypeSection[imp.DescFunc], e.localIDs[idx])
+		}
+	case wasm.ExternTypeTable:
+		fmt.Fprintf(e, "(;%d;) ", idx)
+		e.table(imp.DescTable)
+	case wasm.ExternTypeMemory:
+		fmt.Fprintf(e, "(;%d;) ", idx)
+		e.memory(imp.DescMem)
+	case wasm.ExternTypeGlobal:
+		fmt.Fprintf(e, "(;%d;) ", idx)
+		e.globalType(imp.DescGlobal)
+	default:
+		e.WriteString("(; tags aren't supported ;)")
+	}
+	e.WriteString("))")
+}
+
+// funcID returns the identifier of the function followed by a space, or a comment of its index if unnamed.
+func (e *encoder) funcID(idx wasm.Index) string {
+	if id, ok := e.funcIDs[idx]; ok {
+		return id + " "
+	}
+	return fmt.Sprintf("(;%d;) ", idx)
+}
+
+// funcRef returns the identifier of the function if named, or its index.
+func (e *encoder) funcRef(idx wasm.Index) string {
+	if id, ok := e.funcIDs[idx]; ok {
+		return id
+	}
+	return strconv.FormatUint(uint64(idx), 10)
+}
+
+// signature writes the parameters and results of the function type, where the parameters are named if localIDs has
+// any of them.
+func (e *encoder) signature(t *wasm.FunctionType, localIDs map[wasm.Index]string) {
+	for i, vt := range t.Params {
+		if id, ok := localIDs[wasm.Index(i)]; ok {
+			fmt.Fprintf(e, " (param %s %s)", id, wasm.ValueTypeName(vt))
+		} else {
+			fmt.Fprintf(e, " (param %s)", wasm.ValueTypeName(vt))
+		}
+	}
+	for _, vt := range t.Results {
+		fmt.Fprintf(e, " (result %s)", wasm.ValueTypeName(vt))
+	}
+}
+
+func (e *encoder) table(t *wasm.Table) {
+	fmt.Fprintf(e, "%d ", t.Min)
+	if t.Max != nil {
+		fmt.Fprintf(e, "%d ", *t.Max)
+	}
+	e.WriteString(wasm.RefTypeName(t.Type))
+}
+
+func (e *encoder) memory(mem *wasm.Memory) {
+	fmt.Fprintf(e, "%d", mem.Min)
+	if mem.IsMaxEncoded {
+		fmt.Fprintf(e, " %d", mem.Max)
+	}
+}
+
+func (e *encoder) globalType(gt *wasm.GlobalType) {
+	if gt.Mutable {
+		fmt.Fprintf(e, "(mut %s)", wasm.ValueTypeName(gt.ValType))
+	} else {
+		e.WriteString(wasm.ValueTypeName(gt.ValType))
+	}
+}
+
+// function writes the function of the index, which must not be imported, indented by the depth.
+func (e *encoder) function(funcIdx wasm.Index, depth int) {
+	i := funcIdx - e.m.ImportFuncCount()
+	typeIdx, code := e.m.FunctionSection[i], e.m.CodeSection[i]
+	localIDs := e.localIDs[funcIdx]
+
+	indent := strings.Repeat("  ", depth+1)
+	fmt.Fprintf(e, "(func %s(type %d)", e.funcID(funcIdx), typeIdx)
+	var paramCount wasm.Index
+	if int(typeIdx) < len(e.m.TypeSection) {
+		t := e.m.TypeSection[typeIdx]
+		e.signature(t, localIDs)
+		paramCount = wasm.Index(len(t.Params))
+	}
+
+	for j, vt := range code.LocalTypes {
+		if id, ok := localIDs[paramCount+wasm.Index(j)]; ok {
+			fmt.Fprintf(e, "\n%s(local %s %s)", indent, id, wasm.ValueTypeName(vt))
+		} else {
+			fmt.Fprintf(e, "\n%s(local %s)", indent, wasm.ValueTypeName(vt))
+		}
+	}
+
+	// A body is never at offset zero of the code section, so zero means the module wasn't decoded from the binary
+	// format, and there are no offsets to comment.
+	if code.BodyOffsetInCodeSection == 0 {
+		e.offsets = false
+	}
+	f := &funcEncoder{e: e, localIDs: localIDs, depth: depth + 1}
+	f.instructions(code.Body, code.BodyOffsetInCodeSection)
+	e.WriteString(")")
+}
+
+// elementSegment writes the element segment, where the items are function indexes unless any is null.
+func (e *encoder) elementSegment(idx wasm.Index, seg *wasm.ElementSegment) {
+	fmt.Fprintf(e, "\n  (elem (;%d;)", idx)
+	switch seg.Mode {
+	case wasm.ElementModeActive:
+		if seg.TableIndex != 0 {
+			fmt.Fprintf(e, " (table %d)", seg.TableIndex)
+		}
+		e.WriteString(" ")
+		e.offset(seg.OffsetExpr)
+	case wasm.ElementModeDeclarative:
+		e.WriteString(" declare")
+	}
+
+	indexes := seg.Type == wasm.RefTypeFuncref
+	for _, item := range seg.Init {
+		if item == nil {
+			indexes = false
+		}
+	}
+	if indexes {
+		e.WriteString(" func")
+		for _, item := range seg.Init {
+			e.WriteString(" " + e.funcRef(*item))
+		}
+	} else {
+		e.WriteString(" " + wasm.RefTypeName(seg.Type))
+		for _, item := range seg.Init {
+			if item == nil {
+				fmt.Fprintf(e, " (ref.null %s)", strings.TrimSuffix(wasm.RefTypeName(seg.Type), "ref"))
+			} else {
+				fmt.Fprintf(e, " (ref.func %s)", e.funcRef(*item))
+			}
+		}
+	}
+	e.WriteString(")")
+}
+
+// offset writes the offset of a segment, which is a folded instruction unless there are multiple.
+func (e *encoder) offset(expr *wasm.ConstantExpression) {
+	instrs := e.constantExpression(expr)
+	if len(instrs) == 1 {
+		e.WriteString("(" + instrs[0] + ")")
+		return
+	}
+	e.WriteString("(offset")
+	for _, instr := range instrs {
+		e.WriteString(" (" + instr + ")")
+	}
+	e.WriteString(")")
+}
+
+// constantExpression returns the instructions of the constant expression.
+func (e *encoder) constantExpression(expr *wasm.ConstantExpression) []string {
+	body := append([]byte{expr.Opcode}, expr.Data...)
+	if expr.Opcode == wasm.OpcodeVecV128Const {
+		body = append([]byte{wasm.OpcodeVecPrefix}, body...)
+	}
+	var instrs []string
+	f := &funcEncoder{e: e}
+	for pc := 0; pc < len(body); {
+		instr, n, ok := f.instruction(body[pc:])
+		instrs = append(instrs, instr)
+		if !ok {
+			break
+		}
+		pc += n
+	}
+	return instrs
+}
+
+// funcEncoder writes the instructions of a function body.
+type funcEncoder struct {
+	e        *encoder
+	localIDs map[wasm.Index]string
+	// depth is the indentation of the current instruction.
+	depth int
+}
+
+// instructions writes the instructions of the body, one per line, indented by the structured instructions. The
+// offset is that of the body in the code section, used when encoder.offsets.
+func (f *funcEncoder) instructions(body []byte, offset uint64) {
+	for pc := 0; pc < len(body); {
+		op := body[pc]
+		// The final end of the body is implicit.
+		if op == wasm.OpcodeEnd && pc == len(body)-1 {
+			return
+		}
+		if op == wasm.OpcodeEnd || op == wasm.OpcodeElse {
+			f.depth--
+		}
+
+		f.e.WriteString("\n")
+		if f.e.offsets {
+			fmt.Fprintf(f.e, "(;@%-4x;) ", offset+uint64(pc))
+		}
+		f.e.WriteString(strings.Repeat("  ", f.depth))
+
+		instr, n, ok := f.instruction(body[pc:])
+		f.e.WriteString(instr)
+		if !ok {
+			return
+		}
+		pc += n
+
+		switch op {
+		case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf, wasm.OpcodeElse:
+			f.depth++
+		}
+	}
+}
+
+// instruction returns the text of the instruction at the beginning of the code and its size, or false and a comment
+// if the instruction isn't supported.
+func (f *funcEncoder) instruction(code []byte) (instr string, n int, ok bool) {
+	r := &immediateReader{code: code}
+	var sb strings.Builder
+
+	op := r.byte()
+	switch op {
+	case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+		sb.WriteString(wasm.InstructionName(op))
+		bt := r.int33()
+		switch {
+		case bt == -64: // 0x40 is the empty block type
+		case bt < 0:
+			fmt.Fprintf(&sb, " (result %s)", wasm.ValueTypeName(byte(bt&0x7f)))
+		default:
+			fmt.Fprintf(&sb, " (type %d)", bt)
+		}
+		return f.done(r, sb.String())
+	case wasm.OpcodeElse, wasm.OpcodeEnd:
+		return f.done(r, wasm.InstructionName(op))
+	case wasm.OpcodeTypedSelect:
+		sb.WriteString("select")
+		for i, count := uint32(0), r.u32(); i < count && r.err == nil; i++ {
+			fmt.Fprintf(&sb, " (result %s)", wasm.ValueTypeName(r.byte()))
+		}
+		return f.done(r, sb.String())
+	}
+
+	opcode := []byte{op}
+	if op == wasm.OpcodeMiscPrefix || op == wasm.OpcodeVecPrefix {
+		opcode = append(opcode, leb128.EncodeUint32(r.u32())...)
+	}
+	name, found := f.e.byOpcodeMap()[string(opcode)]
+	if !found || r.err != nil {
+		return fmt.Sprintf("(; unsupported instruction 0x%x ;)", opcode), 0, false
+	}
+	in := instructions[name]
+	sb.WriteString(name)
+
+	switch in.immediates {
+	case immediatesLabel, immediatesElem, immediatesData, immediatesGlobal:
+		fmt.Fprintf(&sb, " %d", r.u32())
+	case immediatesBrTable:
+		for i, count := uint32(0), r.u32(); i <= count && r.err == nil; i++ {
+			fmt.Fprintf(&sb, " %d", r.u32())
+		}
+	case immediatesFunc:
+		sb.WriteString(" " + f.e.funcRef(r.u32()))
+	case immediatesCallIndirect:
+		typeIdx, tableIdx := r.u32(), r.u32()
+		if tableIdx != 0 {
+			fmt.Fprintf(&sb, " %d", tableIdx)
+		}
+		fmt.Fprintf(&sb, " (type %d)", typeIdx)
+	case immediatesLocal:
+		idx := r.u32()
+		if id, ok := f.localIDs[idx]; ok {
+			sb.WriteString(" " + id)
+		} else {
+			fmt.Fprintf(&sb, " %d", idx)
+		}
+	case immediatesTable:
+		if idx := r.u32(); idx != 0 {
+			fmt.Fprintf(&sb, " %d", idx)
+		}
+	case immediatesTableCopy:
+		dst, src := r.u32(), r.u32()
+		if dst != 0 || src != 0 {
+			fmt.Fprintf(&sb, " %d %d", dst, src)
+		}
+	case immediatesTableInit:
+		elem, table := r.u32(), r.u32()
+		if table != 0 {
+			fmt.Fprintf(&sb, " %d", table)
+		}
+		fmt.Fprintf(&sb, " %d", elem)
+	case immediatesMemoryInit:
+		fmt.Fprintf(&sb, " %d", r.u32())
+		r.byte()
+	case immediatesMemory:
+		r.byte()
+	case immediatesMemoryCopy:
+		r.byte()
+		r.byte()
+	case immediatesMemarg, immediatesMemargLane:
+		align, offset := r.u32(), r.u32()
+		if offset != 0 {
+			fmt.Fprintf(&sb, " offset=%d", offset)
+		}
+		if align != in.align && align < 32 {
+			fmt.Fprintf(&sb, " align=%d", uint64(1)<<align)
+		}
+		if in.immediates == immediatesMemargLane {
+			fmt.Fprintf(&sb, " %d", r.byte())
+		}
+	case immediatesI32:
+		fmt.Fprintf(&sb, " %d", r.i32())
+	case immediatesI64:
+		fmt.Fprintf(&sb, " %d", r.i64())
+	case immediatesF32:
+		sb.WriteString(" " + formatFloat(uint64(binary.LittleEndian.Uint32(r.bytes(4))), 32))
+	case immediatesF64:
+		sb.WriteString(" " + formatFloat(binary.LittleEndian.Uint64(r.bytes(8)), 64))
+	case immediatesRefNull:
+		sb.WriteString(" " + strings.TrimSuffix(wasm.RefTypeName(r.byte()), "ref"))
+	case immediatesV128Const:
+		lanes := r.bytes(16)
+		sb.WriteString(" i32x4")
+		for i := 0; i < 16; i += 4 {
+			fmt.Fprintf(&sb, " 0x%08x", binary.LittleEndian.Uint32(lanes[i:]))
+		}
+	case immediatesShuffle:
+		for _, lane := range r.bytes(16) {
+			fmt.Fprintf(&sb, " %d", lane)
+		}
+	case immediatesLane:
+		fmt.Fprintf(&sb, " %d", r.byte())
+	}
+	return f.done(r, sb.String())
+}
+
+// done returns the instruction if its immediates were read successfully.
+func (f *funcEncoder) done(r *immediateReader, instr string) (string, int, bool) {
+	if r.err != nil {
+		return fmt.Sprintf("(; %s: %v ;)", instr, r.err), 0, false
+	}
+	return instr, r.pos, true
+}
+
+// formatFloat returns the text of the bits of a float32 or float64 depending on the bit size, which is exact.
+func formatFloat(bits uint64, bitSize int) string {
+	signBit, mantissaBits := uint64(1)<<63, 52
+	if bitSize == 32 {
+		signBit, mantissaBits = 1<<31, 23
+	}
+	sign := ""
+	if bits&signBit != 0 {
+		sign = "-"
+	}
+	f := math.Float64frombits(bits)
+	if bitSize == 32 {
+		f = float64(math.Float32frombits(uint32(bits)))
+	}
+
+	switch {
+	case math.IsInf(f, 0):
+		return sign + "inf"
+	case math.IsNaN(f):
+		payload := bits & (1<<mantissaBits - 1)
+		if payload == 1<<(mantissaBits-1) {
+			return sign + "nan"
+		}
+		return fmt.Sprintf("%snan:0x%x", sign, payload)
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// quote returns the string literal of the bytes, escaping any but printable ASCII characters.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#strings%E2%91%A0
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "\\%02x", c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// immediateReader reads the immediates of an instruction, retaining the first error.
+type immediateReader struct {
+	code []byte
+	pos  int
+	err  error
+}
+
+func (r *immediateReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *immediateReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	} else if r.pos+n > len(r.code) {
+		r.err = fmt.Errorf("unexpected end of code")
+		return nil
+	}
+	b := r.code[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *immediateReader) u32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	v, n, err := leb128.LoadUint32(r.code[r.pos:])
+	r.pos, r.err = r.pos+int(n), err
+	return v
+}
+
+func (r *immediateReader) i32() int32 {
+	if r.err != nil {
+		return 0
+	}
+	v, n, err := leb128.LoadInt32(r.code[r.pos:])
+	r.pos, r.err = r.pos+int(n), err
+	return v
+}
+
+func (r *immediateReader) i64() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n, err := leb128.LoadInt64(r.code[r.pos:])
+	r.pos, r.err = r.pos+int(n), err
+	return v
+}
+
+// int33 reads a block type, which is a value type or the empty type as a negative number, or a type index.
+func (r *immediateReader) int33() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n, err := leb128.DecodeInt33AsInt64(bytes.NewReader(r.code[r.pos:]))
+	r.pos, r.err = r.pos+int(n), err
+	return v
+}
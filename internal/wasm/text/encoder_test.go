@@ -0,0 +1,171 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+const mathWat = `(module $math
+  (import "env" "log" (func $log (param i32)))
+  (memory 1)
+  (func $add (export "add") (param $x i32) (param $y i32) (result i32)
+    (local $tmp i32)
+    local.get $x
+    local.get $y
+    i32.add
+    local.tee $tmp
+    call $log
+    local.get $tmp
+    block (result i32)
+      i32.const 1
+      i32.load offset=8
+    end
+    drop)
+  (data (i32.const 0) "hi\n"))`
+
+func TestEncodeModule(t *testing.T) {
+	m, err := DecodeModule([]byte(mathWat), api.CoreFeaturesV2, wasm.MemoryLimitPages, false)
+	require.NoError(t, err)
+
+	require.Equal(t, `(module $math
+  (type (;0;) (func (param i32)))
+  (type (;1;) (func (param i32) (param i32) (result i32)))
+  (import "env" "log" (func $log (type 0) (param i32)))
+  (func $add (type 1) (param $x i32) (param $y i32) (result i32)
+    (local $tmp i32)
+    local.get $x
+    local.get $y
+    i32.add
+    local.tee $tmp
+    call $log
+    local.get $tmp
+    block (result i32)
+      i32.const 1
+      i32.load offset=8
+    end
+    drop)
+  (memory (;0;) 1)
+  (export "add" (func $add))
+  (data (;0;) (i32.const 0) "hi\0a"))
+`, string(EncodeModule(m)))
+}
+
+func TestEncodeModule_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name, input string
+	}{
+		{name: "math", input: mathWat},
+		{
+			name: "unnamed",
+			input: `(module
+  (func (param i64 f32 f64) (result i64) local.get 0)
+  (func (result f32) f32.const nan:0x200000)
+  (func (result f64) f64.const -inf)
+  (func (result f64) f64.const 0x1.5p-3))`,
+		},
+		{
+			name: "structured instructions",
+			input: `(module
+  (func $f (param i32) (result i32)
+    (block $outer
+      (loop $inner
+        (br_if $outer (local.get 0))
+        (br_table 0 1 0 (local.get 0))))
+    (if (result i32) (local.get 0)
+      (then (i32.const 1))
+      (else (i32.const 2)))))`,
+		},
+		{
+			name: "tables and segments",
+			input: `(module
+  (type $v (func))
+  (table $t 2 10 funcref)
+  (table 1 externref)
+  (global $g (mut i32) (i32.const 1))
+  (global v128 (v128.const i64x2 1 2))
+  (func $a (type $v))
+  (func $b (type $v) (call_indirect (type $v) (i32.const 0)) (table.copy 0 0 (i32.const 0) (i32.const 1) (i32.const 1)))
+  (start $a)
+  (elem (i32.const 0) $a $b)
+  (elem declare func $b)
+  (elem funcref (ref.null func) (ref.func $a))
+  (memory 1 2)
+  (data (i32.const 16) "\00\ff\"")
+  (data "passive"))`,
+		},
+		{
+			name: "memory and SIMD instructions",
+			input: `(module
+  (memory 1)
+  (func (param v128) (result v128)
+    (i64.store offset=4 align=4 (i32.const 0) (i64.const -1))
+    (memory.fill (i32.const 0) (i32.const 0) (i32.const 0))
+    (i8x16.shuffle 0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 31 (local.get 0) (local.get 0))
+    (v128.load32_lane offset=1 2 (i32.const 0))
+    (i32x4.extract_lane 3)
+    (drop)))`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			features := api.CoreFeaturesV2
+			expected, err := DecodeModule([]byte(tc.input), features, wasm.MemoryLimitPages, false)
+			require.NoError(t, err)
+
+			m, err := DecodeModule(EncodeModule(expected), features, wasm.MemoryLimitPages, false)
+			require.NoError(t, err)
+			require.Equal(t, expected, m)
+		})
+	}
+}
+
+func TestEncodeFunction(t *testing.T) {
+	m, err := DecodeModule([]byte(mathWat), api.CoreFeaturesV2, wasm.MemoryLimitPages, false)
+	require.NoError(t, err)
+	// Decode from the binary format, so that the offsets in the code section are known.
+	m, err = binary.DecodeModule(binary.EncodeModule(m), api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+	require.NoError(t, err)
+
+	t.Run("imported", func(t *testing.T) {
+		wat, err := EncodeFunction(m, 0)
+		require.NoError(t, err)
+		require.Equal(t, `(import "env" "log" (func $log (type 0) (param i32)))
+`, string(wat))
+	})
+
+	t.Run("defined", func(t *testing.T) {
+		wat, err := EncodeFunction(m, 1)
+		require.NoError(t, err)
+		require.Equal(t, `(func $add (type 1) (param $x i32) (param $y i32) (result i32)
+  (local $tmp i32)
+(;@5   ;)   local.get $x
+(;@7   ;)   local.get $y
+(;@9   ;)   i32.add
+(;@a   ;)   local.tee $tmp
+(;@c   ;)   call $log
+(;@e   ;)   local.get $tmp
+(;@10  ;)   block (result i32)
+(;@12  ;)     i32.const 1
+(;@14  ;)     i32.load offset=8
+(;@17  ;)   end
+(;@18  ;)   drop)
+`, string(wat))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := EncodeFunction(m, 2)
+		require.EqualError(t, err, "function[2] not found")
+	})
+}
+
+func TestToID(t *testing.T) {
+	require.Equal(t, "$main._*T_.f", toID("main.(*T).f"))
+	require.Equal(t, "$a_b", toID("a b"))
+}
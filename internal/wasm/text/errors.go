@@ -0,0 +1,42 @@
+package text
+
+import (
+	"fmt"
+)
+
+// FormatError allows control over the format of errors parsing the WebAssembly Text Format.
+type FormatError struct {
+	// Line is the source line number determined by unescaped '\n' characters of the error or EOF
+	Line uint32
+	// Col is the UTF-8 column number of the error or EOF
+	Col uint32
+	// Context is where symbolically the error occurred. Ex "module.func[2]"
+	Context string
+	cause   error
+}
+
+// errorf returns a FormatError at the position of the token.
+func errorf(tok token, format string, args ...interface{}) *FormatError {
+	return &FormatError{Line: tok.line, Col: tok.col, cause: fmt.Errorf(format, args...)}
+}
+
+// Error implements error
+func (e *FormatError) Error() string {
+	if e.Context == "" { // error starting the file
+		return fmt.Sprintf("%d:%d: %v", e.Line, e.Col, e.cause)
+	}
+	return fmt.Sprintf("%d:%d: %v in %s", e.Line, e.Col, e.cause, e.Context)
+}
+
+// Unwrap implements the interface used in errors.Unwrap
+func (e *FormatError) Unwrap() error {
+	return e.cause
+}
+
+// withContext sets the FormatError.Context of the error if it isn't set yet.
+func withContext(err error, context string) error {
+	if fe, ok := err.(*FormatError); ok && fe.Context == "" {
+		fe.Context = context
+	}
+	return err
+}
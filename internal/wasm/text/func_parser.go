@@ -0,0 +1,545 @@
+package text
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// label is a structured instruction which is not ended yet.
+type label struct {
+	// id is the identifier of the label including the leading '$', or empty if there isn't.
+	id string
+	// kind is the name of the instruction, e.g. "block", used to match "else" and in errors.
+	kind string
+}
+
+// funcParser encodes the instructions of a function body or a constant expression.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#instructions%E2%91%A3
+type funcParser struct {
+	m *moduleParser
+	// locals are the parameters and locals of the function, which are empty in a constant expression.
+	locals *indexNamespace
+	// labels are the structured instructions enclosing the current instruction, innermost last.
+	labels []label
+	// body is the encoded instructions so far, without the trailing wasm.OpcodeEnd.
+	body []byte
+	// count is the number of instructions encoded so far, not counting "else" and "end".
+	count int
+}
+
+// parseInstructions parses the remaining of the cursor as instructions, which are either plain or folded.
+func (p *funcParser) parseInstructions(c *cursor) error {
+	for !c.done() {
+		s := c.peek()
+		if s.isList {
+			c.next()
+			if err := p.parseFoldedInstruction(s); err != nil {
+				return err
+			}
+			continue
+		} else if !s.isKeyword() {
+			return c.unexpected("instruction")
+		}
+
+		var err error
+		switch s.tok.text {
+		case "block", "loop", "if":
+			c.next()
+			err = p.parseBlock(c, s.tok.text)
+		case "else":
+			c.next()
+			err = p.parseElse(c, s)
+		case "end":
+			c.next()
+			err = p.parseEnd(c, s)
+		default:
+			err = p.parsePlainInstruction(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBlock parses the label and block type of a structured instruction after its keyword.
+func (p *funcParser) parseBlock(c *cursor, kind string) error {
+	var id string
+	if s := c.optionalID(); s != nil {
+		id = s.tok.text
+	}
+	blockType, err := p.parseBlockType(c)
+	if err != nil {
+		return err
+	}
+
+	var opcode wasm.Opcode
+	switch kind {
+	case "block":
+		opcode = wasm.OpcodeBlock
+	case "loop":
+		opcode = wasm.OpcodeLoop
+	default:
+		opcode = wasm.OpcodeIf
+	}
+	p.body = append(append(p.body, opcode), blockType...)
+	p.labels = append(p.labels, label{id: id, kind: kind})
+	p.count++
+	return nil
+}
+
+// parseBlockType returns the encoding of the block type, which is either empty, a value type or a type index.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/text/instructions.html#control-instructions
+func (p *funcParser) parseBlockType(c *cursor) ([]byte, error) {
+	var typeIdx wasm.Index
+	if c.peekList("type") {
+		idx, paramIDs, err := p.m.parseTypeUse(c)
+		if err != nil {
+			return nil, err
+		} else if err = checkNoParamIDs(paramIDs); err != nil {
+			return nil, err
+		}
+		typeIdx = idx
+	} else {
+		ft, paramIDs, err := p.m.parseFuncType(c)
+		if err != nil {
+			return nil, err
+		} else if err = checkNoParamIDs(paramIDs); err != nil {
+			return nil, err
+		}
+		if len(ft.Params) == 0 && len(ft.Results) == 0 {
+			return []byte{0x40}, nil
+		} else if len(ft.Params) == 0 && len(ft.Results) == 1 {
+			return []byte{ft.Results[0]}, nil
+		}
+		typeIdx = p.m.typeIndexOf(ft)
+	}
+	return leb128.EncodeInt64(int64(typeIdx)), nil
+}
+
+// checkNoParamIDs returns an error if any parameter of a block type has an identifier, which isn't allowed.
+func checkNoParamIDs(paramIDs []*sexpr) error {
+	for _, id := range paramIDs {
+		if id != nil {
+			return errorf(id.tok, "unexpected %s", id)
+		}
+	}
+	return nil
+}
+
+// parseElse parses "else" after its keyword, which must be in an "if".
+func (p *funcParser) parseElse(c *cursor, s *sexpr) error {
+	if len(p.labels) == 0 || p.labels[len(p.labels)-1].kind != "if" {
+		return errorf(s.tok, "unexpected else")
+	}
+	top := &p.labels[len(p.labels)-1]
+	if err := checkLabelID(c, top); err != nil {
+		return err
+	}
+	top.kind = "else"
+	p.body = append(p.body, wasm.OpcodeElse)
+	return nil
+}
+
+// parseEnd parses "end" after its keyword, which ends the innermost structured instruction.
+func (p *funcParser) parseEnd(c *cursor, s *sexpr) error {
+	if len(p.labels) == 0 {
+		return errorf(s.tok, "unexpected end")
+	}
+	if err := checkLabelID(c, &p.labels[len(p.labels)-1]); err != nil {
+		return err
+	}
+	p.labels = p.labels[:len(p.labels)-1]
+	p.body = append(p.body, wasm.OpcodeEnd)
+	return nil
+}
+
+// checkLabelID parses the optional identifier after "else" or "end", which must match the label.
+func checkLabelID(c *cursor, l *label) error {
+	if s := c.optionalID(); s != nil && s.tok.text != l.id {
+		return errorf(s.tok, "mismatching label %s", s.tok.text)
+	}
+	return nil
+}
+
+// parseFoldedInstruction parses an instruction written as an S-expression, e.g. (i32.add (local.get 0) (i32.const 1)),
+// which is encoded after its operands.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#folded-instructions%E2%91%A0
+func (p *funcParser) parseFoldedInstruction(s *sexpr) error {
+	c := newCursor(s)
+	switch head := s.head(); head {
+	case "":
+		return errorf(s.tok, "expected instruction, but was %s", s)
+	case "block", "loop":
+		if err := p.parseBlock(c, head); err != nil {
+			return err
+		} else if err = p.parseInstructions(c); err != nil {
+			return err
+		}
+	case "if":
+		// The condition is before the block type in the folded form, so parse the label and block type first.
+		start := len(p.body)
+		if err := p.parseBlock(c, head); err != nil {
+			return err
+		}
+		ifInstr := append([]byte{}, p.body[start:]...)
+		p.body = p.body[:start]
+
+		for !c.peekList("then") {
+			cond := c.next()
+			if cond == nil || !cond.isList {
+				return errorf(s.tok, "expected (then ...)")
+			}
+			if err := p.parseFoldedInstruction(cond); err != nil {
+				return err
+			}
+		}
+		p.body = append(p.body, ifInstr...)
+		if err := p.parseInstructions(newCursor(c.next())); err != nil {
+			return err
+		}
+		// An empty else is omitted as it is the same as none.
+		if c.peekList("else") {
+			start = len(p.body)
+			p.body = append(p.body, wasm.OpcodeElse)
+			if err := p.parseInstructions(newCursor(c.next())); err != nil {
+				return err
+			} else if len(p.body) == start+1 {
+				p.body = p.body[:start]
+			}
+		}
+		if err := c.expectDone(); err != nil {
+			return err
+		}
+	default:
+		// The cursor includes the head, so after the immediates, the remaining are the operands.
+		c = &cursor{items: s.list, end: s.end}
+		start := len(p.body)
+		if err := p.parsePlainInstruction(c); err != nil {
+			return err
+		}
+		instr := append([]byte{}, p.body[start:]...)
+		p.body = p.body[:start]
+
+		for !c.done() {
+			operand := c.next()
+			if !operand.isList {
+				return errorf(operand.tok, "unexpected %s", operand)
+			} else if err := p.parseFoldedInstruction(operand); err != nil {
+				return err
+			}
+		}
+		p.body = append(p.body, instr...)
+		return nil
+	}
+
+	p.labels = p.labels[:len(p.labels)-1]
+	p.body = append(p.body, wasm.OpcodeEnd)
+	return nil
+}
+
+// parsePlainInstruction parses the next keyword of the cursor as a plain instruction, and its immediates.
+func (p *funcParser) parsePlainInstruction(c *cursor) error {
+	s := c.next()
+	in, ok := instructions[s.tok.text]
+	if !ok {
+		return errorf(s.tok, "unknown instruction %s", s.tok.text)
+	}
+	p.body = append(p.body, in.opcode...)
+	p.count++
+
+	switch in.immediates {
+	case immediatesNone:
+	case immediatesLabel:
+		return p.appendLabel(c)
+	case immediatesBrTable:
+		var labels []byte
+		n := uint32(0)
+		for ; peekIndex(c); n++ {
+			idx, err := p.resolveLabel(c)
+			if err != nil {
+				return err
+			}
+			labels = append(labels, leb128.EncodeUint32(idx)...)
+		}
+		if n == 0 {
+			return c.unexpected("label index")
+		}
+		p.body = append(append(p.body, leb128.EncodeUint32(n-1)...), labels...)
+	case immediatesFunc:
+		return p.appendIndex(c, p.m.funcNamespace)
+	case immediatesCallIndirect:
+		var tableIdx wasm.Index
+		if peekIndex(c) {
+			idx, err := p.m.tableNamespace.resolve(c)
+			if err != nil {
+				return err
+			}
+			tableIdx = idx
+		}
+		typeIdx, paramIDs, err := p.m.parseTypeUse(c)
+		if err != nil {
+			return err
+		} else if err = checkNoParamIDs(paramIDs); err != nil {
+			return err
+		}
+		p.body = append(p.body, leb128.EncodeUint32(typeIdx)...)
+		p.body = append(p.body, leb128.EncodeUint32(tableIdx)...)
+	case immediatesLocal:
+		return p.appendIndex(c, p.locals)
+	case immediatesGlobal:
+		return p.appendIndex(c, p.m.globalNamespace)
+	case immediatesTable:
+		if !peekIndex(c) {
+			p.body = append(p.body, 0)
+			return nil
+		}
+		return p.appendIndex(c, p.m.tableNamespace)
+	case immediatesTableCopy:
+		if !peekIndex(c) {
+			p.body = append(p.body, 0, 0)
+			return nil
+		} else if err := p.appendIndex(c, p.m.tableNamespace); err != nil {
+			return err
+		}
+		return p.appendIndex(c, p.m.tableNamespace)
+	case immediatesTableInit:
+		// The table index is optional and before the element index, so look ahead to know which is which.
+		table := &cursor{items: []*sexpr{{tok: token{tokenType: tokenReserved, text: "0"}}}}
+		if len(c.items) > 1 && peekIndex(&cursor{items: c.items[1:]}) {
+			table = &cursor{items: []*sexpr{c.next()}}
+		}
+		if err := p.appendIndex(c, p.m.elemNamespace); err != nil {
+			return err
+		}
+		return p.appendIndex(table, p.m.tableNamespace)
+	case immediatesElem:
+		return p.appendIndex(c, p.m.elemNamespace)
+	case immediatesMemoryInit:
+		p.m.usesDataCount = true
+		if err := p.appendIndex(c, p.m.dataNamespace); err != nil {
+			return err
+		}
+		p.body = append(p.body, 0)
+	case immediatesData:
+		p.m.usesDataCount = true
+		return p.appendIndex(c, p.m.dataNamespace)
+	case immediatesMemory:
+		p.body = append(p.body, 0)
+	case immediatesMemoryCopy:
+		p.body = append(p.body, 0, 0)
+	case immediatesMemarg:
+		return p.appendMemarg(c, in.align)
+	case immediatesMemargLane:
+		if err := p.appendMemarg(c, in.align); err != nil {
+			return err
+		}
+		return p.appendLane(c)
+	case immediatesI32:
+		v, err := p.nextNumber(c, "i32", func(text string) (uint64, error) { return parseInt(text, 32) })
+		if err != nil {
+			return err
+		}
+		p.body = append(p.body, leb128.EncodeInt32(int32(uint32(v)))...)
+	case immediatesI64:
+		v, err := p.nextNumber(c, "i64", func(text string) (uint64, error) { return parseInt(text, 64) })
+		if err != nil {
+			return err
+		}
+		p.body = append(p.body, leb128.EncodeInt64(int64(v))...)
+	case immediatesF32:
+		v, err := p.nextNumber(c, "f32", func(text string) (uint64, error) { return parseFloat(text, 32) })
+		if err != nil {
+			return err
+		}
+		p.body = append(p.body, make([]byte, 4)...)
+		binary.LittleEndian.PutUint32(p.body[len(p.body)-4:], uint32(v))
+	case immediatesF64:
+		v, err := p.nextNumber(c, "f64", func(text string) (uint64, error) { return parseFloat(text, 64) })
+		if err != nil {
+			return err
+		}
+		p.body = append(p.body, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(p.body[len(p.body)-8:], v)
+	case immediatesSelect:
+		var types []wasm.ValueType
+		for c.peekList("result") {
+			rc := newCursor(c.next())
+			for !rc.done() {
+				vt, err := p.m.parseValueType(rc)
+				if err != nil {
+					return err
+				}
+				types = append(types, vt)
+			}
+		}
+		if len(types) > 0 {
+			p.body[len(p.body)-1] = wasm.OpcodeTypedSelect
+			p.body = append(append(p.body, leb128.EncodeUint32(uint32(len(types)))...), types...)
+		}
+	case immediatesRefNull:
+		switch {
+		case c.peekKeyword("func"):
+			p.body = append(p.body, wasm.RefTypeFuncref)
+		case c.peekKeyword("extern"):
+			p.body = append(p.body, wasm.RefTypeExternref)
+		default:
+			return c.unexpected("heap type")
+		}
+		c.next()
+	case immediatesV128Const:
+		return p.appendV128Const(c)
+	case immediatesShuffle:
+		for i := 0; i < 16; i++ {
+			if err := p.appendLane(c); err != nil {
+				return err
+			}
+		}
+	case immediatesLane:
+		return p.appendLane(c)
+	}
+	return nil
+}
+
+// appendIndex appends the index of the next item of the cursor resolved in the namespace.
+func (p *funcParser) appendIndex(c *cursor, n *indexNamespace) error {
+	idx, err := n.resolve(c)
+	if err != nil {
+		return err
+	}
+	p.body = append(p.body, leb128.EncodeUint32(idx)...)
+	return nil
+}
+
+func (p *funcParser) appendLabel(c *cursor) error {
+	idx, err := p.resolveLabel(c)
+	if err != nil {
+		return err
+	}
+	p.body = append(p.body, leb128.EncodeUint32(idx)...)
+	return nil
+}
+
+// resolveLabel returns the relative depth of the label, which is either an identifier or an integer.
+func (p *funcParser) resolveLabel(c *cursor) (wasm.Index, error) {
+	if !peekIndex(c) {
+		return 0, c.unexpected("label index")
+	}
+	s := c.next()
+	if s.tok.tokenType != tokenID {
+		v, err := parseUint(s.tok.text, 32)
+		if err != nil {
+			return 0, errorf(s.tok, "%s: %v", s.tok.text, err)
+		}
+		return wasm.Index(v), nil
+	}
+	for i := len(p.labels) - 1; i >= 0; i-- {
+		if p.labels[i].id == s.tok.text {
+			return wasm.Index(len(p.labels) - 1 - i), nil
+		}
+	}
+	return 0, errorf(s.tok, "unknown label %s", s.tok.text)
+}
+
+// appendMemarg appends the alignment and offset of a memory instruction, e.g. "offset=4 align=2".
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instructions%E2%91%A3
+func (p *funcParser) appendMemarg(c *cursor, align uint32) error {
+	var offset uint32
+	if s := c.peek(); s != nil && s.isKeyword() && strings.HasPrefix(s.tok.text, "offset=") {
+		c.next()
+		v, err := parseUint(s.tok.text[len("offset="):], 32)
+		if err != nil {
+			return errorf(s.tok, "%s: %v", s.tok.text, err)
+		}
+		offset = uint32(v)
+	}
+	if s := c.peek(); s != nil && s.isKeyword() && strings.HasPrefix(s.tok.text, "align=") {
+		c.next()
+		v, err := parseUint(s.tok.text[len("align="):], 32)
+		if err != nil {
+			return errorf(s.tok, "%s: %v", s.tok.text, err)
+		} else if v == 0 || v&(v-1) != 0 {
+			return errorf(s.tok, "alignment must be a power of two: %s", s.tok.text)
+		}
+		align = uint32(bits.TrailingZeros64(v))
+	}
+	p.body = append(p.body, leb128.EncodeUint32(align)...)
+	p.body = append(p.body, leb128.EncodeUint32(offset)...)
+	return nil
+}
+
+func (p *funcParser) appendLane(c *cursor) error {
+	v, err := c.nextUint("lane index", 8)
+	if err != nil {
+		return err
+	}
+	p.body = append(p.body, byte(v))
+	return nil
+}
+
+// nextNumber returns the next item of the cursor parsed as a number literal of the type.
+func (p *funcParser) nextNumber(c *cursor, what string, parse func(string) (uint64, error)) (uint64, error) {
+	// Keywords are allowed as floats can be "inf" or "nan".
+	if !c.peekAtom(tokenReserved) && !c.peekAtom(tokenKeyword) {
+		return 0, c.unexpected(what)
+	}
+	s := c.next()
+	v, err := parse(s.tok.text)
+	if err != nil {
+		return 0, errorf(s.tok, "%s: %v", s.tok.text, err)
+	}
+	return v, nil
+}
+
+// appendV128Const appends the lanes of v128.const, which begin with their shape, e.g. "i32x4 1 2 3 4".
+func (p *funcParser) appendV128Const(c *cursor) error {
+	if !c.peekAtom(tokenKeyword) {
+		return c.unexpected("vector shape")
+	}
+	shape := c.next()
+
+	var laneCount, laneBits int
+	float := false
+	switch shape.tok.text {
+	case "i8x16":
+		laneCount, laneBits = 16, 8
+	case "i16x8":
+		laneCount, laneBits = 8, 16
+	case "i32x4":
+		laneCount, laneBits = 4, 32
+	case "i64x2":
+		laneCount, laneBits = 2, 64
+	case "f32x4":
+		laneCount, laneBits, float = 4, 32, true
+	case "f64x2":
+		laneCount, laneBits, float = 2, 64, true
+	default:
+		return errorf(shape.tok, "unknown vector shape %s", shape.tok.text)
+	}
+
+	var lanes [16]byte
+	for i := 0; i < laneCount; i++ {
+		v, err := p.nextNumber(c, shape.tok.text+" lane", func(text string) (uint64, error) {
+			if float {
+				return parseFloat(text, laneBits)
+			}
+			return parseInt(text, laneBits)
+		})
+		if err != nil {
+			return err
+		}
+		for b := 0; b < laneBits/8; b++ {
+			lanes[i*laneBits/8+b] = byte(v >> (8 * b))
+		}
+	}
+	p.body = append(p.body, lanes[:]...)
+	return nil
+}
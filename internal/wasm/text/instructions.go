@@ -0,0 +1,243 @@
+package text
+
+import (
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// immediates is the kind of the immediates which follow an instruction.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#instructions%E2%91%A3
+type immediates byte
+
+const (
+	immediatesNone immediates = iota
+	// immediatesLabel is a label index, e.g. br.
+	immediatesLabel
+	// immediatesBrTable is the label indexes of br_table, where the last one is the default.
+	immediatesBrTable
+	// immediatesFunc is a function index, e.g. call.
+	immediatesFunc
+	// immediatesCallIndirect is an optional table index followed by a type use.
+	immediatesCallIndirect
+	immediatesLocal
+	immediatesGlobal
+	// immediatesTable is an optional table index, which defaults to zero, e.g. table.get.
+	immediatesTable
+	// immediatesTableCopy is either no or both destination and source table indexes.
+	immediatesTableCopy
+	// immediatesTableInit is an optional table index followed by an element segment index.
+	immediatesTableInit
+	immediatesElem
+	// immediatesMemoryInit is a data segment index which is encoded followed by the memory index zero.
+	immediatesMemoryInit
+	immediatesData
+	// immediatesMemory is encoded as the memory index zero, e.g. memory.size.
+	immediatesMemory
+	// immediatesMemoryCopy is encoded as the destination and source memory indexes zero.
+	immediatesMemoryCopy
+	// immediatesMemarg is the optional "offset=" and "align=" of a memory instruction, e.g. i32.load.
+	immediatesMemarg
+	// immediatesMemargLane is immediatesMemarg followed by a lane index, e.g. v128.load8_lane.
+	immediatesMemargLane
+	immediatesI32
+	immediatesI64
+	immediatesF32
+	immediatesF64
+	// immediatesSelect is the optional result type of select, which is encoded as wasm.OpcodeTypedSelect if present.
+	immediatesSelect
+	// immediatesRefNull is the heap type of ref.null.
+	immediatesRefNull
+	// immediatesV128Const is the shape and lanes of v128.const.
+	immediatesV128Const
+	// immediatesShuffle is the sixteen lane indexes of i8x16.shuffle.
+	immediatesShuffle
+	// immediatesLane is a lane index, e.g. i32x4.extract_lane.
+	immediatesLane
+)
+
+// instruction is the binary encoding of a plain instruction of the text format.
+type instruction struct {
+	// opcode is the encoding of the instruction without immediates, including the prefix if any.
+	opcode []byte
+	// immediates is the kind of the immediates which follow the instruction.
+	immediates immediates
+	// align is the natural alignment of a memory instruction, in log2.
+	align uint32
+}
+
+// instructions are the plain instructions of api.CoreFeaturesV2 and api.CoreFeatureRelaxedSIMD, keyed by name.
+//
+// Note: block, loop, if, else and end are structured, so handled by funcParser, not here.
+var instructions = newInstructions()
+
+// textInstructionNames are the names of the instructions which differ between wasm and the text format.
+var textInstructionNames = map[string]string{
+	wasm.OpcodeF32ConvertI64UName:      "f32.convert_i64_u",
+	wasm.OpcodeVecV128i8x16ShuffleName: "i8x16.shuffle",
+	wasm.OpcodeVecI8x16SubSatSName:     "i8x16.sub_sat_s",
+	wasm.OpcodeVecI8x16SubSatUName:     "i8x16.sub_sat_u",
+	wasm.OpcodeVecI64x2LtSName:         "i64x2.lt_s",
+	wasm.OpcodeVecI64x2GtSName:         "i64x2.gt_s",
+	wasm.OpcodeVecI64x2LeSName:         "i64x2.le_s",
+	wasm.OpcodeVecI64x2GeSName:         "i64x2.ge_s",
+}
+
+func newInstructions() map[string]*instruction {
+	ret := map[string]*instruction{}
+	add := func(name string, in *instruction) {
+		if textName, ok := textInstructionNames[name]; ok {
+			name = textName
+		}
+		ret[name] = in
+	}
+
+	for i := 0; i < 256; i++ {
+		op := wasm.Opcode(i)
+		if imm, align, ok := immediatesOf(op); ok {
+			add(wasm.InstructionName(op), &instruction{opcode: []byte{op}, immediates: imm, align: align})
+		}
+	}
+
+	for i := 0; i < 256; i++ {
+		op := wasm.OpcodeMisc(i)
+		if name := wasm.MiscInstructionName(op); name != "" {
+			opcode := append([]byte{wasm.OpcodeMiscPrefix}, leb128.EncodeUint32(uint32(op))...)
+			add(name, &instruction{opcode: opcode, immediates: miscImmediatesOf(op)})
+		}
+	}
+
+	for i := 0; i < 256; i++ {
+		op := wasm.OpcodeVec(i)
+		if name := wasm.VectorInstructionName(op); name != "" {
+			imm, align := vecImmediatesOf(op)
+			opcode := append([]byte{wasm.OpcodeVecPrefix}, leb128.EncodeUint32(uint32(op))...)
+			add(name, &instruction{opcode: opcode, immediates: imm, align: align})
+		}
+	}
+
+	for i := 0; i < 128; i++ {
+		op := wasm.OpcodeVecRelaxed(i)
+		if name := wasm.RelaxedVectorInstructionName(op); name != "" {
+			// The relaxed opcodes are encoded after the non-relaxed ones, which are less than 0x100.
+			opcode := append([]byte{wasm.OpcodeVecPrefix}, leb128.EncodeUint32(0x100+uint32(op))...)
+			add(name, &instruction{opcode: opcode})
+		}
+	}
+	return ret
+}
+
+// immediatesOf returns the immediates of the plain instruction of the opcode, or false if it isn't supported.
+func immediatesOf(op wasm.Opcode) (imm immediates, align uint32, ok bool) {
+	switch {
+	case op >= wasm.OpcodeI32Eqz && op <= wasm.OpcodeI64Extend32S: // numeric instructions
+		return immediatesNone, 0, true
+	case op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32:
+		return immediatesMemarg, naturalAlignment(op), true
+	}
+
+	switch op {
+	case wasm.OpcodeUnreachable, wasm.OpcodeNop, wasm.OpcodeReturn, wasm.OpcodeDrop, wasm.OpcodeRefIsNull:
+		return immediatesNone, 0, true
+	case wasm.OpcodeBr, wasm.OpcodeBrIf:
+		return immediatesLabel, 0, true
+	case wasm.OpcodeBrTable:
+		return immediatesBrTable, 0, true
+	case wasm.OpcodeCall, wasm.OpcodeReturnCall, wasm.OpcodeRefFunc:
+		return immediatesFunc, 0, true
+	case wasm.OpcodeCallIndirect, wasm.OpcodeReturnCallIndirect:
+		return immediatesCallIndirect, 0, true
+	case wasm.OpcodeSelect:
+		return immediatesSelect, 0, true
+	case wasm.OpcodeLocalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalTee:
+		return immediatesLocal, 0, true
+	case wasm.OpcodeGlobalGet, wasm.OpcodeGlobalSet:
+		return immediatesGlobal, 0, true
+	case wasm.OpcodeTableGet, wasm.OpcodeTableSet:
+		return immediatesTable, 0, true
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		return immediatesMemory, 0, true
+	case wasm.OpcodeI32Const:
+		return immediatesI32, 0, true
+	case wasm.OpcodeI64Const:
+		return immediatesI64, 0, true
+	case wasm.OpcodeF32Const:
+		return immediatesF32, 0, true
+	case wasm.OpcodeF64Const:
+		return immediatesF64, 0, true
+	case wasm.OpcodeRefNull:
+		return immediatesRefNull, 0, true
+	}
+	return 0, 0, false
+}
+
+// naturalAlignment returns the alignment of the memory instruction in log2, which is the size of its access.
+func naturalAlignment(op wasm.Opcode) uint32 {
+	switch op {
+	case wasm.OpcodeI32Load8S, wasm.OpcodeI32Load8U, wasm.OpcodeI64Load8S, wasm.OpcodeI64Load8U,
+		wasm.OpcodeI32Store8, wasm.OpcodeI64Store8:
+		return 0
+	case wasm.OpcodeI32Load16S, wasm.OpcodeI32Load16U, wasm.OpcodeI64Load16S, wasm.OpcodeI64Load16U,
+		wasm.OpcodeI32Store16, wasm.OpcodeI64Store16:
+		return 1
+	case wasm.OpcodeI32Load, wasm.OpcodeF32Load, wasm.OpcodeI64Load32S, wasm.OpcodeI64Load32U,
+		wasm.OpcodeI32Store, wasm.OpcodeF32Store, wasm.OpcodeI64Store32:
+		return 2
+	}
+	return 3 // i64 and f64
+}
+
+func miscImmediatesOf(op wasm.OpcodeMisc) immediates {
+	switch op {
+	case wasm.OpcodeMiscMemoryInit:
+		return immediatesMemoryInit
+	case wasm.OpcodeMiscDataDrop:
+		return immediatesData
+	case wasm.OpcodeMiscMemoryCopy:
+		return immediatesMemoryCopy
+	case wasm.OpcodeMiscMemoryFill:
+		return immediatesMemory
+	case wasm.OpcodeMiscTableInit:
+		return immediatesTableInit
+	case wasm.OpcodeMiscElemDrop:
+		return immediatesElem
+	case wasm.OpcodeMiscTableCopy:
+		return immediatesTableCopy
+	case wasm.OpcodeMiscTableGrow, wasm.OpcodeMiscTableSize, wasm.OpcodeMiscTableFill:
+		return immediatesTable
+	}
+	return immediatesNone // saturating truncations
+}
+
+func vecImmediatesOf(op wasm.OpcodeVec) (immediates, uint32) {
+	switch op {
+	case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Store:
+		return immediatesMemarg, 4
+	case wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u, wasm.OpcodeVecV128Load16x4s,
+		wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s, wasm.OpcodeVecV128Load32x2u,
+		wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load64zero:
+		return immediatesMemarg, 3
+	case wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load32zero:
+		return immediatesMemarg, 2
+	case wasm.OpcodeVecV128Load16Splat:
+		return immediatesMemarg, 1
+	case wasm.OpcodeVecV128Load8Splat:
+		return immediatesMemarg, 0
+	case wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Store8Lane:
+		return immediatesMemargLane, 0
+	case wasm.OpcodeVecV128Load16Lane, wasm.OpcodeVecV128Store16Lane:
+		return immediatesMemargLane, 1
+	case wasm.OpcodeVecV128Load32Lane, wasm.OpcodeVecV128Store32Lane:
+		return immediatesMemargLane, 2
+	case wasm.OpcodeVecV128Load64Lane, wasm.OpcodeVecV128Store64Lane:
+		return immediatesMemargLane, 3
+	case wasm.OpcodeVecV128Const:
+		return immediatesV128Const, 0
+	case wasm.OpcodeVecV128i8x16Shuffle:
+		return immediatesShuffle, 0
+	}
+	if op >= wasm.OpcodeVecI8x16ExtractLaneS && op <= wasm.OpcodeVecF64x2ReplaceLane {
+		return immediatesLane, 0
+	}
+	return immediatesNone, 0
+}
@@ -0,0 +1,279 @@
+package text
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// tokenType is the type of token of the WebAssembly Text Format.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#tokens%E2%91%A0
+type tokenType byte
+
+const (
+	tokenLParen tokenType = iota + 1
+	tokenRParen
+	// tokenKeyword is a token which begins with a lowercase letter, e.g. "module" or "i32.add".
+	tokenKeyword
+	// tokenID is an identifier beginning with '$', e.g. "$main".
+	tokenID
+	// tokenString is a string literal. The text of the token is the decoded bytes without the quotes.
+	tokenString
+	// tokenReserved is any other sequence of identifier characters, e.g. the numbers "1", "-0x1p3" or "nan:0x1".
+	tokenReserved
+	// tokenEOF is the end of the source.
+	tokenEOF
+)
+
+// String returns a description of the token type used in errors.
+func (t tokenType) String() string {
+	switch t {
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenKeyword:
+		return "keyword"
+	case tokenID:
+		return "id"
+	case tokenString:
+		return "string"
+	case tokenReserved:
+		return "reserved"
+	case tokenEOF:
+		return "EOF"
+	}
+	return "unknown"
+}
+
+// token is a lexical token of the WebAssembly Text Format, and its position in the source.
+type token struct {
+	tokenType tokenType
+	// text is the source of the token, except tokenString, which is the decoded bytes of the string.
+	text string
+	// line and col are the one-based position of the first character of the token.
+	line, col uint32
+}
+
+// String returns a description of the token used in errors.
+func (t token) String() string {
+	switch t.tokenType {
+	case tokenLParen, tokenRParen, tokenEOF:
+		return t.tokenType.String()
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return t.text
+}
+
+// lexer tokenizes the source of the WebAssembly Text Format, tracking the line and column.
+type lexer struct {
+	source    []byte
+	pos       int
+	line, col uint32
+}
+
+// lex tokenizes the source, and returns the tokens followed by a tokenEOF.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#lexical-format%E2%91%A0
+func lex(source []byte) ([]token, error) {
+	l := &lexer{source: source, line: 1, col: 1}
+	var tokens []token
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance(1)
+		case c == ';' && l.peekAt(1) == ';': // line comment
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case c == '(' && l.peekAt(1) == ';': // block comment, which can be nested
+			if err := l.skipBlockComment(); err != nil {
+				return nil, err
+			}
+		case c == '(':
+			tokens = append(tokens, l.token(tokenLParen, 1))
+		case c == ')':
+			tokens = append(tokens, l.token(tokenRParen, 1))
+		case c == '"':
+			tok, err := l.lexString()
+			if err != nil {
+				return nil, err
+			}
+			if err = l.expectSeparator(); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+		case isIDChar(c):
+			n := 1
+			for l.pos+n < len(l.source) && isIDChar(l.source[l.pos+n]) {
+				n++
+			}
+			tokenType := tokenReserved
+			if c == '$' {
+				if n == 1 {
+					return nil, errorf(l.position(tokenID), "empty identifier")
+				}
+				tokenType = tokenID
+			} else if c >= 'a' && c <= 'z' {
+				tokenType = tokenKeyword
+			}
+			tokens = append(tokens, l.token(tokenType, n))
+			if err := l.expectSeparator(); err != nil {
+				return nil, err
+			}
+		default:
+			r, _ := utf8.DecodeRune(l.source[l.pos:])
+			return nil, errorf(l.position(0), "unexpected character %q", r)
+		}
+	}
+	return append(tokens, l.position(tokenEOF)), nil
+}
+
+// expectSeparator returns an error unless the token just lexed is followed by whitespace, a parenthesis, a comment or
+// the end of the source. For example, "a""b" is not two strings.
+func (l *lexer) expectSeparator() error {
+	if c := l.peekAt(0); c == '"' || isIDChar(c) {
+		return errorf(l.position(0), "expected whitespace before %q", c)
+	}
+	return nil
+}
+
+// peekAt returns the byte at the offset from the current position, or zero past the end of the source.
+func (l *lexer) peekAt(offset int) byte {
+	if l.pos+offset < len(l.source) {
+		return l.source[l.pos+offset]
+	}
+	return 0
+}
+
+// advance moves the position forward by n bytes, counting columns by UTF-8 characters.
+func (l *lexer) advance(n int) {
+	for end := l.pos + n; l.pos < end; l.pos++ {
+		c := l.source[l.pos]
+		if c == '\n' {
+			l.line++
+			l.col = 1
+		} else if c&0xc0 != 0x80 { // not a continuation byte of a UTF-8 character
+			l.col++
+		}
+	}
+}
+
+// position returns a token without text at the current position.
+func (l *lexer) position(tokenType tokenType) token {
+	return token{tokenType: tokenType, line: l.line, col: l.col}
+}
+
+// token returns the token of the next n bytes, and advances past it.
+func (l *lexer) token(tokenType tokenType, n int) token {
+	tok := l.position(tokenType)
+	tok.text = string(l.source[l.pos : l.pos+n])
+	l.advance(n)
+	return tok
+}
+
+func (l *lexer) skipBlockComment() error {
+	start := l.position(0)
+	depth := 0
+	for l.pos < len(l.source) {
+		switch {
+		case l.source[l.pos] == '(' && l.peekAt(1) == ';':
+			depth++
+			l.advance(2)
+		case l.source[l.pos] == ';' && l.peekAt(1) == ')':
+			depth--
+			l.advance(2)
+			if depth == 0 {
+				return nil
+			}
+		default:
+			l.advance(1)
+		}
+	}
+	return errorf(start, "unterminated block comment")
+}
+
+// lexString returns the tokenString starting at the current quote, decoding its escapes.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#strings%E2%91%A0
+func (l *lexer) lexString() (token, error) {
+	tok := l.position(tokenString)
+	l.advance(1) // opening quote
+
+	var buf []byte
+	for {
+		if l.pos >= len(l.source) || l.source[l.pos] == '\n' {
+			return tok, errorf(tok, "unterminated string")
+		}
+		c := l.source[l.pos]
+		if c == '"' {
+			l.advance(1)
+			tok.text = string(buf)
+			return tok, nil
+		} else if c < 0x20 || c == 0x7f {
+			return tok, errorf(l.position(0), "unexpected control character %#x in string", c)
+		} else if c != '\\' {
+			buf = append(buf, c)
+			l.advance(1)
+			continue
+		}
+
+		escape := l.position(0)
+		switch e := l.peekAt(1); e {
+		case 't':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case '"', '\'', '\\':
+			buf = append(buf, e)
+		case 'u':
+			end := l.pos + 2
+			for end < len(l.source) && l.source[end] != '}' && l.source[end] != '"' {
+				end++
+			}
+			if l.peekAt(2) != '{' || end >= len(l.source) || l.source[end] != '}' {
+				return tok, errorf(escape, "invalid unicode escape")
+			}
+			hex := string(l.source[l.pos+3 : end])
+			r, err := strconv.ParseUint(removeUnderscores(hex), 16, 32)
+			if err != nil || !isValidUnderscores(hex, true) || r >= 0x110000 || (r >= 0xd800 && r < 0xe000) {
+				return tok, errorf(escape, "invalid unicode escape \\u{%s}", hex)
+			}
+			buf = utf8.AppendRune(buf, rune(r))
+			l.advance(end + 1 - l.pos)
+			continue
+		default:
+			if !isHexDigit(e) || !isHexDigit(l.peekAt(2)) {
+				return tok, errorf(escape, "invalid escape \\%c", e)
+			}
+			b, _ := strconv.ParseUint(string(l.source[l.pos+1:l.pos+3]), 16, 8)
+			buf = append(buf, byte(b))
+			l.advance(3)
+			continue
+		}
+		l.advance(2)
+	}
+}
+
+// isIDChar returns true if the byte is allowed in keywords, identifiers and numbers.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-idchar
+func isIDChar(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	}
+	switch c {
+	case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '/', ':', '<', '=', '>', '?', '@', '\\', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
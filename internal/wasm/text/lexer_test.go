@@ -0,0 +1,89 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: []token{{tokenType: tokenEOF, line: 1, col: 1}},
+		},
+		{
+			name:  "module",
+			input: "(module $m)",
+			expected: []token{
+				{tokenType: tokenLParen, text: "(", line: 1, col: 1},
+				{tokenType: tokenKeyword, text: "module", line: 1, col: 2},
+				{tokenType: tokenID, text: "$m", line: 1, col: 9},
+				{tokenType: tokenRParen, text: ")", line: 1, col: 11},
+				{tokenType: tokenEOF, line: 1, col: 12},
+			},
+		},
+		{
+			name:  "comments",
+			input: ";; line\n(; block (; nested ;) ;)i32.const -1",
+			expected: []token{
+				{tokenType: tokenKeyword, text: "i32.const", line: 2, col: 25},
+				{tokenType: tokenReserved, text: "-1", line: 2, col: 35},
+				{tokenType: tokenEOF, line: 2, col: 37},
+			},
+		},
+		{
+			name:  "string escapes",
+			input: `"a\t\n\"\\\00\u{1F600}"`,
+			expected: []token{
+				{tokenType: tokenString, text: "a\t\n\"\\\x00😀", line: 1, col: 1},
+				{tokenType: tokenEOF, line: 1, col: 24},
+			},
+		},
+		{
+			name:  "columns count UTF-8 characters",
+			input: "\"😀\" $a",
+			expected: []token{
+				{tokenType: tokenString, text: "😀", line: 1, col: 1},
+				{tokenType: tokenID, text: "$a", line: 1, col: 5},
+				{tokenType: tokenEOF, line: 1, col: 7},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, err := lex([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, tokens)
+		})
+	}
+}
+
+func TestLex_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{name: "empty id", input: "(func $)", expectedErr: "1:7: empty identifier"},
+		{name: "unterminated string", input: "\n  \"abc", expectedErr: "2:3: unterminated string"},
+		{name: "unterminated block comment", input: " (; (; ;)", expectedErr: "1:2: unterminated block comment"},
+		{name: "invalid escape", input: `"\q"`, expectedErr: `1:2: invalid escape \q`},
+		{name: "invalid unicode escape", input: `"\u{d800}"`, expectedErr: `1:2: invalid unicode escape \u{d800}`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := lex([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
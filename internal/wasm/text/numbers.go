@@ -0,0 +1,135 @@
+package text
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errInvalidNumber = errors.New("invalid number")
+
+// parseUint parses an unsigned integer literal, such as an index, into a value of the given bit size.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#integers%E2%91%A6
+func parseUint(text string, bitSize int) (uint64, error) {
+	digits, base := text, 10
+	if strings.HasPrefix(digits, "0x") {
+		digits, base = digits[2:], 16
+	}
+	if digits == "" || !isValidUnderscores(digits, base == 16) {
+		return 0, errInvalidNumber
+	}
+	v, err := strconv.ParseUint(removeUnderscores(digits), base, bitSize)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, errors.New("constant out of range")
+		}
+		return 0, errInvalidNumber
+	}
+	return v, nil
+}
+
+// parseInt parses an integer literal into a value of the given bit size. The literal can be either signed or unsigned,
+// so the result is in two's complement, e.g. "-1" and "0xffffffff" are the same for the bitSize 32.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#integers%E2%91%A6
+func parseInt(text string, bitSize int) (uint64, error) {
+	neg := false
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		neg, text = text[0] == '-', text[1:]
+	}
+	v, err := parseUint(text, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		if v > 1<<(bitSize-1) {
+			return 0, errors.New("constant out of range")
+		}
+		v = -v & (math.MaxUint64 >> (64 - bitSize))
+	}
+	return v, nil
+}
+
+// parseFloat parses a floating-point literal into the bits of a float32 or float64 depending on the bit size.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#floating-point%E2%91%A6
+func parseFloat(text string, bitSize int) (uint64, error) {
+	signBit, mantissaBits := uint64(1)<<63, 52
+	if bitSize == 32 {
+		signBit, mantissaBits = 1<<31, 23
+	}
+
+	var sign uint64
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		if text[0] == '-' {
+			sign = signBit
+		}
+		text = text[1:]
+	}
+
+	var bits uint64
+	switch {
+	case text == "inf":
+		bits = floatBits(math.Inf(1), bitSize)
+	case text == "nan":
+		// The canonical NaN has only the most significant bit of the mantissa set.
+		bits = floatBits(math.Inf(1), bitSize) | 1<<(mantissaBits-1)
+	case strings.HasPrefix(text, "nan:0x"):
+		payload, err := parseUint(text[4:], 64)
+		if err != nil || payload == 0 || payload >= 1<<mantissaBits {
+			return 0, errors.New("invalid NaN payload")
+		}
+		bits = floatBits(math.Inf(1), bitSize) | payload
+	default:
+		if text == "" || text[0] < '0' || text[0] > '9' || !isValidUnderscores(text, strings.HasPrefix(text, "0x")) {
+			return 0, errInvalidNumber
+		}
+		text = removeUnderscores(text)
+		if strings.HasPrefix(text, "0x") && !strings.ContainsAny(text, "pP") {
+			text += "p0" // Unlike Go, the exponent of hexadecimal floats is optional.
+		}
+		f, err := strconv.ParseFloat(text, bitSize)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) && !math.IsInf(f, 0) {
+				err = nil // Rounding to zero is allowed.
+			} else if errors.Is(err, strconv.ErrRange) {
+				return 0, errors.New("constant out of range")
+			} else {
+				return 0, errInvalidNumber
+			}
+		}
+		bits = floatBits(f, bitSize)
+	}
+	return sign | bits, nil
+}
+
+func floatBits(f float64, bitSize int) uint64 {
+	if bitSize == 32 {
+		return uint64(math.Float32bits(float32(f)))
+	}
+	return math.Float64bits(f)
+}
+
+// isValidUnderscores returns true if each underscore in the digits is between two digits, which are hexadecimal if hex.
+func isValidUnderscores(digits string, hex bool) bool {
+	isDigit := isDecimalDigit
+	if hex {
+		isDigit = isHexDigit
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' && (i == 0 || i == len(digits)-1 || !isDigit(digits[i-1]) || !isDigit(digits[i+1])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDecimalDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func removeUnderscores(digits string) string {
+	return strings.ReplaceAll(digits, "_", "")
+}
@@ -0,0 +1,84 @@
+package text
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		bitSize  int
+		expected uint64
+	}{
+		{input: "0", bitSize: 32, expected: 0},
+		{input: "+1_000", bitSize: 32, expected: 1000},
+		{input: "-1", bitSize: 32, expected: 0xffffffff},
+		{input: "0xffff_ffff", bitSize: 32, expected: 0xffffffff},
+		{input: "-0x8000_0000", bitSize: 32, expected: 0x80000000},
+		{input: "-1", bitSize: 64, expected: math.MaxUint64},
+		{input: "-128", bitSize: 8, expected: 0x80},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseInt(tc.input, tc.bitSize)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+
+	for _, input := range []string{"", "-", "0x", "1__0", "_1", "1_", "0x_1", "abc"} {
+		_, err := parseInt(input, 32)
+		require.Equal(t, errInvalidNumber, err, input)
+	}
+
+	for _, input := range []string{"0x1_0000_0000", "-0x8000_0001", "4294967296"} {
+		_, err := parseInt(input, 32)
+		require.EqualError(t, err, "constant out of range", input)
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		input    string
+		bitSize  int
+		expected uint64
+	}{
+		{input: "0", bitSize: 32, expected: 0},
+		{input: "-0", bitSize: 32, expected: 0x80000000},
+		{input: "1.5", bitSize: 32, expected: uint64(math.Float32bits(1.5))},
+		{input: "1_000.000_1e-1_0", bitSize: 64, expected: math.Float64bits(1000.0001e-10)},
+		{input: "0x1p-1", bitSize: 32, expected: uint64(math.Float32bits(0.5))},
+		{input: "0x1.8", bitSize: 64, expected: math.Float64bits(1.5)},
+		{input: "inf", bitSize: 32, expected: 0x7f800000},
+		{input: "-inf", bitSize: 64, expected: 0xfff0000000000000},
+		{input: "nan", bitSize: 32, expected: 0x7fc00000},
+		{input: "-nan:0x1", bitSize: 32, expected: 0xff800001},
+		{input: "nan:0xf_ffff_ffff_ffff", bitSize: 64, expected: 0x7fffffffffffffff},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseFloat(tc.input, tc.bitSize)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+
+	for _, input := range []string{"", ".1", "1_e1", "1e_1", "nan:1", "infinity"} {
+		_, err := parseFloat(input, 32)
+		require.Error(t, err, input)
+	}
+
+	_, err := parseFloat("1e39", 32)
+	require.EqualError(t, err, "constant out of range")
+	_, err = parseFloat("nan:0x80_0000", 32)
+	require.EqualError(t, err, "invalid NaN payload")
+}
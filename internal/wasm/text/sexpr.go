@@ -0,0 +1,187 @@
+package text
+
+import "unicode/utf8"
+
+// sexpr is a node of the S-expressions which the WebAssembly Text Format is written in. This is either a parenthesized
+// list or an atom, which is any token but the parentheses.
+type sexpr struct {
+	// tok is the atom, or the opening parenthesis of the list.
+	tok token
+	// list are the items of a list, and nil for an atom.
+	list []*sexpr
+	// end is the closing parenthesis of a list.
+	end    token
+	isList bool
+}
+
+// String returns a description of the node used in errors.
+func (s *sexpr) String() string {
+	if s.isList {
+		if head := s.head(); head != "" {
+			return "(" + head + " ...)"
+		}
+		return "list"
+	}
+	return s.tok.String()
+}
+
+// head returns the keyword at the beginning of the list, or empty if there isn't.
+func (s *sexpr) head() string {
+	if s.isList && len(s.list) > 0 && s.list[0].isKeyword() {
+		return s.list[0].tok.text
+	}
+	return ""
+}
+
+// isKeyword returns true if this is an atom of tokenKeyword.
+func (s *sexpr) isKeyword() bool {
+	return !s.isList && s.tok.tokenType == tokenKeyword
+}
+
+// parseSexprs parses the tokens, which end with tokenEOF, into the top-level S-expressions.
+func parseSexprs(tokens []token) ([]*sexpr, error) {
+	// stack has the lists which aren't closed yet, and its bottom collects the top-level nodes.
+	stack := []*sexpr{{isList: true}}
+	for _, tok := range tokens {
+		top := stack[len(stack)-1]
+		switch tok.tokenType {
+		case tokenLParen:
+			list := &sexpr{tok: tok, isList: true}
+			top.list = append(top.list, list)
+			stack = append(stack, list)
+		case tokenRParen:
+			if len(stack) == 1 {
+				return nil, errorf(tok, "unexpected ')'")
+			}
+			top.end = tok
+			stack = stack[:len(stack)-1]
+		case tokenEOF:
+			if len(stack) > 1 {
+				return nil, errorf(tok, "expected ')' to close '(' at %d:%d", top.tok.line, top.tok.col)
+			}
+			stack[0].end = tok
+		default:
+			top.list = append(top.list, &sexpr{tok: tok})
+		}
+	}
+	return stack[0].list, nil
+}
+
+// cursor iterates over the items of a list.
+type cursor struct {
+	items []*sexpr
+	// end is the position to report errors at when the items are exhausted.
+	end token
+}
+
+// newCursor returns a cursor over the items of the list after its head.
+func newCursor(list *sexpr) *cursor {
+	return &cursor{items: list.list[1:], end: list.end}
+}
+
+func (c *cursor) done() bool {
+	return len(c.items) == 0
+}
+
+// peek returns the next item or nil if done.
+func (c *cursor) peek() *sexpr {
+	if c.done() {
+		return nil
+	}
+	return c.items[0]
+}
+
+// next returns the next item and moves past it, or nil if done.
+func (c *cursor) next() *sexpr {
+	if c.done() {
+		return nil
+	}
+	s := c.items[0]
+	c.items = c.items[1:]
+	return s
+}
+
+// peekList returns true if the next item is a list beginning with the keyword.
+func (c *cursor) peekList(head string) bool {
+	s := c.peek()
+	return s != nil && s.head() == head
+}
+
+// peekKeyword returns true if the next item is the keyword.
+func (c *cursor) peekKeyword(keyword string) bool {
+	s := c.peek()
+	return s != nil && s.isKeyword() && s.tok.text == keyword
+}
+
+// peekAtom returns true if the next item is an atom of the token type.
+func (c *cursor) peekAtom(tokenType tokenType) bool {
+	s := c.peek()
+	return s != nil && !s.isList && s.tok.tokenType == tokenType
+}
+
+// optionalID returns the next item if it is an identifier, and moves past it.
+func (c *cursor) optionalID() *sexpr {
+	if c.peekAtom(tokenID) {
+		return c.next()
+	}
+	return nil
+}
+
+// position returns the token of the next item to report errors at.
+func (c *cursor) position() token {
+	if s := c.peek(); s != nil {
+		return s.tok
+	}
+	return c.end
+}
+
+// unexpected returns an error about the next item, or about the end of the list if done.
+func (c *cursor) unexpected(expected string) error {
+	if s := c.peek(); s != nil {
+		return errorf(s.tok, "expected %s, but was %s", expected, s)
+	}
+	return errorf(c.end, "expected %s, but was ')'", expected)
+}
+
+// expectDone returns an error if there are remaining items.
+func (c *cursor) expectDone() error {
+	if s := c.peek(); s != nil {
+		return errorf(s.tok, "unexpected %s", s)
+	}
+	return nil
+}
+
+// nextString returns the next item if it is a string literal.
+func (c *cursor) nextString(what string) (string, error) {
+	if !c.peekAtom(tokenString) {
+		return "", c.unexpected(what)
+	}
+	return c.next().tok.text, nil
+}
+
+// nextName returns the next item if it is a string literal which is valid UTF-8, such as the name of an export.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#names%E2%91%A2
+func (c *cursor) nextName(what string) (string, error) {
+	tok := c.position()
+	name, err := c.nextString(what)
+	if err != nil {
+		return "", err
+	} else if !utf8.ValidString(name) {
+		return "", errorf(tok, "malformed UTF-8 encoding of %s", what)
+	}
+	return name, nil
+}
+
+// nextUint returns the next item parsed as an unsigned integer of the bit size.
+func (c *cursor) nextUint(what string, bitSize int) (uint64, error) {
+	if !c.peekAtom(tokenReserved) {
+		return 0, c.unexpected(what)
+	}
+	s := c.next()
+	v, err := parseUint(s.tok.text, bitSize)
+	if err != nil {
+		return 0, errorf(s.tok, "%s: %v", s.tok.text, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,376 @@
+package wasm
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HeapType is the type of the values a TypedRef refers to, which is either an abstract heap type such as HeapTypeFunc
+// or a non-negative index in Module.TypeSection.
+//
+// This is encoded as a signed 33-bit integer, so the abstract heap types have the same encoding as the corresponding
+// ValueType.
+//
+// See https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md#types
+type HeapType int64
+
+const (
+	// HeapTypeFunc is the abstract heap type of all functions, i.e. 0x70 in the original byte.
+	HeapTypeFunc HeapType = -0x10
+	// HeapTypeExtern is the abstract heap type of all host objects, i.e. 0x6f in the original byte.
+	HeapTypeExtern HeapType = -0x11
+
+	// HeapTypeAny is the abstract heap type of all structs and arrays, i.e. 0x6e in the original byte. This is only
+	// valid when CoreFeatureGC is enabled, as are the heap types below.
+	HeapTypeAny HeapType = -0x12
+	// HeapTypeEq is the abstract heap type of the values comparable with ref.eq, i.e. 0x6d in the original byte.
+	HeapTypeEq HeapType = -0x13
+	// HeapTypeI31 is the abstract heap type of unboxed 31-bit integers, i.e. 0x6c in the original byte.
+	//
+	// Note: This is decoded only to be rejected as i31 references are not supported.
+	HeapTypeI31 HeapType = -0x14
+	// HeapTypeStruct is the abstract heap type of all structs, i.e. 0x6b in the original byte.
+	HeapTypeStruct HeapType = -0x15
+	// HeapTypeArray is the abstract heap type of all arrays, i.e. 0x6a in the original byte.
+	HeapTypeArray HeapType = -0x16
+	// HeapTypeNone is the bottom of the HeapTypeAny hierarchy which has no values but null, i.e. 0x71 in the
+	// original byte.
+	HeapTypeNone HeapType = -0x0f
+	// HeapTypeNoExtern is the bottom of the HeapTypeExtern hierarchy, i.e. 0x72 in the original byte.
+	HeapTypeNoExtern HeapType = -0x0e
+	// HeapTypeNoFunc is the bottom of the HeapTypeFunc hierarchy, i.e. 0x73 in the original byte.
+	HeapTypeNoFunc HeapType = -0x0d
+)
+
+// HeapTypeOfByte returns the abstract heap type encoded as the single byte b, which is also the shorthand of the
+// nullable reference value type of it, e.g. 0x6d for eqref. This returns false if b is not an abstract heap type
+// enabled by enabledFeatures.
+func HeapTypeOfByte(b byte, enabledFeatures api.CoreFeatures) (HeapType, bool) {
+	ht := HeapType(b) - 0x80 // Sign-extend the single byte encoding of the signed 33-bit integer.
+	return ht, isAbstractHeapType(ht, enabledFeatures)
+}
+
+// isAbstractHeapType returns true if ht is an abstract heap type enabled by enabledFeatures.
+func isAbstractHeapType(ht HeapType, enabledFeatures api.CoreFeatures) bool {
+	switch ht {
+	case HeapTypeFunc, HeapTypeExtern:
+		return true
+	case HeapTypeAny, HeapTypeEq, HeapTypeStruct, HeapTypeArray, HeapTypeNone, HeapTypeNoExtern, HeapTypeNoFunc:
+		return enabledFeatures.IsEnabled(api.CoreFeatureGC)
+	}
+	return false
+}
+
+// ValidateHeapType returns an error if ht is neither an abstract heap type enabled by enabledFeatures nor an index of
+// types. types can be nil when they are not known yet, in which case any type index is valid.
+func ValidateHeapType(types []*FunctionType, ht HeapType, enabledFeatures api.CoreFeatures) error {
+	if ht >= 0 {
+		if types != nil && int(ht) >= len(types) {
+			return fmt.Errorf("unknown type index %d", ht)
+		}
+		return nil
+	} else if !isAbstractHeapType(ht, enabledFeatures) {
+		return fmt.Errorf("invalid heap type: %d", ht)
+	}
+	return nil
+}
+
+const (
+	// RefTypePrefixNonNullable is the prefix of the non-nullable reference value type `(ref ht)`.
+	RefTypePrefixNonNullable byte = 0x64
+	// RefTypePrefixNullable is the prefix of the nullable reference value type `(ref null ht)`.
+	RefTypePrefixNullable byte = 0x63
+)
+
+// TypedRef is a reference value type defined by CoreFeatureFunctionReferences, which is stored as ValueTypeFuncref,
+// ValueTypeExternref or ValueTypeAnyref depending on the hierarchy of the HeapType.
+//
+// A nil *TypedRef is used where the value is not a reference, or is the nullable top type of the ValueType, i.e.
+// funcref, externref or anyref.
+type TypedRef struct {
+	// Nullable is true when the reference can be null.
+	Nullable bool
+	// HeapType is the type of the referenced values.
+	HeapType HeapType
+}
+
+// NewTypedRef returns the TypedRef of the nullable and the heap type, or nil if it is funcref, externref or anyref.
+func NewTypedRef(nullable bool, heapType HeapType) *TypedRef {
+	if nullable && (heapType == HeapTypeFunc || heapType == HeapTypeExtern || heapType == HeapTypeAny) {
+		return nil
+	}
+	return &TypedRef{Nullable: nullable, HeapType: heapType}
+}
+
+// ValueType returns the ValueType which stores the reference.
+//
+// Note: This returns ValueTypeFuncref for a type index regardless of the type, as it doesn't know the types of the
+// module. Module.Validate replaces it with ValueTypeAnyref if the index is of a CompositeType.
+func (r *TypedRef) ValueType() ValueType {
+	switch r.HeapType {
+	case HeapTypeExtern, HeapTypeNoExtern:
+		return ValueTypeExternref
+	case HeapTypeAny, HeapTypeEq, HeapTypeStruct, HeapTypeArray, HeapTypeNone:
+		return ValueTypeAnyref
+	}
+	return ValueTypeFuncref
+}
+
+// refValueType returns the ValueType which stores the references to the heap type ht, resolving the type index with
+// types.
+func refValueType(types []*FunctionType, ht HeapType) ValueType {
+	if ht >= 0 && int(ht) < len(types) && types[ht].Composite != nil {
+		return ValueTypeAnyref
+	}
+	return (&TypedRef{HeapType: ht}).ValueType()
+}
+
+// AsNonNullable returns the non-nullable variant of the reference type of the value type vt and r.
+func (r *TypedRef) AsNonNullable(vt ValueType) *TypedRef {
+	if r == nil {
+		heapType := HeapTypeFunc
+		switch vt {
+		case ValueTypeExternref:
+			heapType = HeapTypeExtern
+		case ValueTypeAnyref:
+			heapType = HeapTypeAny
+		}
+		return &TypedRef{HeapType: heapType}
+	} else if !r.Nullable {
+		return r
+	}
+	return &TypedRef{HeapType: r.HeapType}
+}
+
+// String implements fmt.Stringer.
+func (r *TypedRef) String() string {
+	ht := heapTypeName(r.HeapType)
+	if r.Nullable {
+		return "(ref null " + ht + ")"
+	}
+	return "(ref " + ht + ")"
+}
+
+// heapTypeName returns the name of the abstract heap type ht in the text format, or the type index.
+func heapTypeName(ht HeapType) string {
+	switch ht {
+	case HeapTypeFunc:
+		return "func"
+	case HeapTypeExtern:
+		return "extern"
+	case HeapTypeAny:
+		return "any"
+	case HeapTypeEq:
+		return "eq"
+	case HeapTypeI31:
+		return "i31"
+	case HeapTypeStruct:
+		return "struct"
+	case HeapTypeArray:
+		return "array"
+	case HeapTypeNone:
+		return "none"
+	case HeapTypeNoExtern:
+		return "noextern"
+	case HeapTypeNoFunc:
+		return "nofunc"
+	}
+	return fmt.Sprintf("%d", ht)
+}
+
+// typedRefName returns the name of the value type vt and its TypedRef r.
+func typedRefName(vt ValueType, r *TypedRef) string {
+	if r == nil {
+		return ValueTypeName(vt)
+	}
+	return r.String()
+}
+
+// isTypedRefSubtype returns true if a value of the reference type have, stored as the same ValueType, can be used as
+// the reference type want. types are used to resolve the type indexes, which are equivalent if they are the same
+// function type or composite type.
+func isTypedRefSubtype(types []*FunctionType, have, want *TypedRef) bool {
+	if want == nil {
+		return true // Every reference is a subtype of the nullable top type, i.e. funcref, externref or anyref.
+	} else if have == nil {
+		return false
+	} else if have.Nullable && !want.Nullable {
+		return false
+	}
+	return isHeapSubtype(types, have.HeapType, want.HeapType)
+}
+
+// isHeapSubtype returns true if the heap type have is a subtype of want.
+func isHeapSubtype(types []*FunctionType, have, want HeapType) bool {
+	if have == want {
+		return true
+	}
+	var composite *CompositeType
+	if have >= 0 {
+		if int(have) >= len(types) {
+			return false
+		}
+		composite = types[have].Composite
+	}
+	switch want {
+	case HeapTypeAny:
+		return refValueType(types, have) == ValueTypeAnyref
+	case HeapTypeEq:
+		return have == HeapTypeStruct || have == HeapTypeArray || have == HeapTypeNone || composite != nil
+	case HeapTypeStruct:
+		return have == HeapTypeNone || (composite != nil && !composite.IsArray)
+	case HeapTypeArray:
+		return have == HeapTypeNone || (composite != nil && composite.IsArray)
+	case HeapTypeFunc:
+		// A reference to a function type is a subtype of the abstract function type.
+		return have == HeapTypeNoFunc || (have >= 0 && composite == nil)
+	case HeapTypeExtern:
+		return have == HeapTypeNoExtern
+	}
+	if want < 0 || int(want) >= len(types) {
+		return false
+	} else if have < 0 {
+		// Only the bottom types are subtypes of a type index.
+		if types[want].Composite != nil {
+			return have == HeapTypeNone
+		}
+		return have == HeapTypeNoFunc
+	}
+	// Follow the declared supertypes of have to find the type equivalent to want.
+	for depth := 0; depth <= len(types); depth++ {
+		if typesEquivalent(types[have], types[want]) {
+			return true
+		}
+		c := types[have].Composite
+		if c == nil || c.Supertype == nil || int(*c.Supertype) >= len(types) {
+			return false
+		}
+		have = HeapType(*c.Supertype)
+	}
+	return false
+}
+
+// typesEquivalent returns true if the types a and b are structurally the same.
+func typesEquivalent(a, b *FunctionType) bool {
+	if a == b {
+		return true
+	} else if a.Composite != nil || b.Composite != nil {
+		return a.Composite != nil && b.Composite != nil && a.Composite.equals(b.Composite)
+	}
+	return a.EqualsSignature(b.Params, b.Results) &&
+		typedRefsEqual(a.ParamRefs, b.ParamRefs) &&
+		typedRefsEqual(a.ResultRefs, b.ResultRefs)
+}
+
+// typedRefsEqual returns true if the TypedRef of each value is the same, where nil slices are the same as ones of all
+// nil elements.
+func typedRefsEqual(a, b []*TypedRef) bool {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		ra, rb := typedRefAt(a, i), typedRefAt(b, i)
+		if (ra == nil) != (rb == nil) || (ra != nil && *ra != *rb) {
+			return false
+		}
+	}
+	return true
+}
+
+// typedRefAt returns the i-th TypedRef in refs, or nil if refs doesn't have it.
+func typedRefAt(refs []*TypedRef, i int) *TypedRef {
+	if i < len(refs) {
+		return refs[i]
+	}
+	return nil
+}
+
+// resolveTypedRefValueTypes replaces the ValueType of the typed references to a CompositeType with ValueTypeAnyref,
+// as the binary decoder stores any type index as ValueTypeFuncref. See TypedRef.ValueType.
+func (m *Module) resolveTypedRefValueTypes() {
+	resolve := func(vts []ValueType, refs []*TypedRef) (changed bool) {
+		for i, r := range refs {
+			if r != nil && r.HeapType >= 0 {
+				vt := refValueType(m.TypeSection, r.HeapType)
+				changed = changed || vts[i] != vt
+				vts[i] = vt
+			}
+		}
+		return
+	}
+	for _, tp := range m.TypeSection {
+		if changedParams, changedResults := resolve(tp.Params, tp.ParamRefs), resolve(tp.Results, tp.ResultRefs); changedParams || changedResults {
+			tp.string = "" // Clear the cached key, which depends on the value types.
+		}
+		if tp.Composite != nil {
+			for _, f := range tp.Composite.Fields {
+				if f.Ref != nil && f.Ref.HeapType >= 0 {
+					f.Type = refValueType(m.TypeSection, f.Ref.HeapType)
+				}
+			}
+		}
+	}
+	for _, imp := range m.ImportSection {
+		if imp.Type == ExternTypeGlobal && imp.DescGlobal.Ref != nil && imp.DescGlobal.Ref.HeapType >= 0 {
+			imp.DescGlobal.ValType = refValueType(m.TypeSection, imp.DescGlobal.Ref.HeapType)
+		}
+	}
+	for _, g := range m.GlobalSection {
+		if g.Type.Ref != nil && g.Type.Ref.HeapType >= 0 {
+			g.Type.ValType = refValueType(m.TypeSection, g.Type.Ref.HeapType)
+		}
+	}
+	for _, c := range m.CodeSection {
+		resolve(c.LocalTypes, c.LocalRefs)
+	}
+}
+
+// validateTypedRefs returns an error if any typed reference in the module refers to a type index out of range.
+func (m *Module) validateTypedRefs() error {
+	for i, tp := range m.TypeSection {
+		if tp.Composite != nil {
+			if err := m.validateCompositeType(Index(i)); err != nil {
+				return fmt.Errorf("invalid type[%d]: %w", i, err)
+			}
+			continue
+		}
+		if err := validateTypeIndexes(m.TypeSection, tp.ParamRefs); err != nil {
+			return fmt.Errorf("invalid param type of type[%d]: %w", i, err)
+		} else if err = validateTypeIndexes(m.TypeSection, tp.ResultRefs); err != nil {
+			return fmt.Errorf("invalid result type of type[%d]: %w", i, err)
+		}
+	}
+	for i, imp := range m.ImportSection {
+		if imp.Type != ExternTypeGlobal {
+			continue
+		} else if err := validateTypeIndexes(m.TypeSection, []*TypedRef{imp.DescGlobal.Ref}); err != nil {
+			return fmt.Errorf("invalid type of import[%d] %s.%s: %w", i, imp.Module, imp.Name, err)
+		}
+	}
+	for i, g := range m.GlobalSection {
+		if err := validateTypeIndexes(m.TypeSection, []*TypedRef{g.Type.Ref}); err != nil {
+			return fmt.Errorf("invalid type of global[%d]: %w", i, err)
+		} else if g.Type.Ref != nil && !g.Type.Ref.Nullable && g.Init != nil && g.Init.Opcode == OpcodeRefNull {
+			return fmt.Errorf("global[%d] of non-nullable type %s must not be initialized with %s",
+				i, g.Type.Ref, OpcodeRefNullName)
+		}
+	}
+	for i, c := range m.CodeSection {
+		if err := validateTypeIndexes(m.TypeSection, c.LocalRefs); err != nil {
+			return fmt.Errorf("invalid local type of code[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateTypeIndexes returns an error if any of refs refers to a type index out of range of types.
+func validateTypeIndexes(types []*FunctionType, refs []*TypedRef) error {
+	for _, r := range refs {
+		if r != nil && r.HeapType >= 0 && int(r.HeapType) >= len(types) {
+			return fmt.Errorf("unknown type index %d of %s", r.HeapType, r)
+		}
+	}
+	return nil
+}
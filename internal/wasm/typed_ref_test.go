@@ -0,0 +1,102 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestTypedRef_String(t *testing.T) {
+	tests := []struct {
+		input    *TypedRef
+		expected string
+	}{
+		{input: &TypedRef{HeapType: HeapTypeFunc}, expected: "(ref func)"},
+		{input: &TypedRef{Nullable: true, HeapType: HeapTypeExtern}, expected: "(ref null extern)"},
+		{input: &TypedRef{HeapType: 3}, expected: "(ref 3)"},
+		{input: &TypedRef{Nullable: true, HeapType: 0}, expected: "(ref null 0)"},
+		{input: &TypedRef{HeapType: HeapTypeStruct}, expected: "(ref struct)"},
+		{input: &TypedRef{Nullable: true, HeapType: HeapTypeNone}, expected: "(ref null none)"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.input.String())
+		})
+	}
+}
+
+func TestNewTypedRef(t *testing.T) {
+	require.Nil(t, NewTypedRef(true, HeapTypeFunc))
+	require.Nil(t, NewTypedRef(true, HeapTypeExtern))
+	require.Nil(t, NewTypedRef(true, HeapTypeAny))
+	require.Equal(t, &TypedRef{HeapType: HeapTypeFunc}, NewTypedRef(false, HeapTypeFunc))
+	require.Equal(t, &TypedRef{Nullable: true, HeapType: 1}, NewTypedRef(true, 1))
+}
+
+func TestIsTypedRefSubtype(t *testing.T) {
+	types := []*FunctionType{
+		{Params: []ValueType{ValueTypeI32}},
+		{Params: []ValueType{ValueTypeI32}}, // equivalent to the type 0
+		{Results: []ValueType{ValueTypeI32}},
+	}
+	nonNull0, null0 := &TypedRef{HeapType: 0}, &TypedRef{Nullable: true, HeapType: 0}
+
+	tests := []struct {
+		name       string
+		have, want *TypedRef
+		expected   bool
+	}{
+		{name: "funcref", have: nil, want: nil, expected: true},
+		{name: "typed as funcref", have: nonNull0, want: nil, expected: true},
+		{name: "funcref as typed", have: nil, want: null0, expected: false},
+		{name: "non-nullable as nullable", have: nonNull0, want: null0, expected: true},
+		{name: "nullable as non-nullable", have: null0, want: nonNull0, expected: false},
+		{name: "typed as (ref func)", have: nonNull0, want: &TypedRef{HeapType: HeapTypeFunc}, expected: true},
+		{name: "(ref func) as typed", have: &TypedRef{HeapType: HeapTypeFunc}, want: nonNull0, expected: false},
+		{name: "equivalent type", have: nonNull0, want: &TypedRef{HeapType: 1}, expected: true},
+		{name: "different type", have: nonNull0, want: &TypedRef{HeapType: 2}, expected: false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isTypedRefSubtype(types, tc.have, tc.want))
+		})
+	}
+}
+
+func TestIsTypedRefSubtype_GC(t *testing.T) {
+	zero := Index(0)
+	types := []*FunctionType{
+		{Composite: &CompositeType{Fields: []*FieldType{{Type: ValueTypeI32}}}},
+		{Composite: &CompositeType{Supertype: &zero, Final: true, Fields: []*FieldType{{Type: ValueTypeI32}, {Type: ValueTypeI64}}}},
+		{Composite: &CompositeType{IsArray: true, Final: true, Fields: []*FieldType{{Type: ValueTypeI32}}}},
+	}
+	ref := func(ht HeapType) *TypedRef { return &TypedRef{HeapType: ht} }
+
+	tests := []struct {
+		name       string
+		have, want *TypedRef
+		expected   bool
+	}{
+		{name: "struct as anyref", have: ref(0), want: nil, expected: true},
+		{name: "struct as eq", have: ref(0), want: ref(HeapTypeEq), expected: true},
+		{name: "struct as struct", have: ref(0), want: ref(HeapTypeStruct), expected: true},
+		{name: "struct as array", have: ref(0), want: ref(HeapTypeArray), expected: false},
+		{name: "array as array", have: ref(2), want: ref(HeapTypeArray), expected: true},
+		{name: "subtype", have: ref(1), want: ref(0), expected: true},
+		{name: "supertype", have: ref(0), want: ref(1), expected: false},
+		{name: "none as struct", have: ref(HeapTypeNone), want: ref(1), expected: true},
+		{name: "eq as any", have: ref(HeapTypeEq), want: ref(HeapTypeAny), expected: true},
+		{name: "any as eq", have: ref(HeapTypeAny), want: ref(HeapTypeEq), expected: false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isTypedRefSubtype(types, tc.have, tc.want))
+		})
+	}
+}
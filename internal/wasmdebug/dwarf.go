@@ -33,9 +33,33 @@ func NewDWARFLines(d *dwarf.Data) *DWARFLines {
 	return &DWARFLines{d: d, linesPerEntry: map[dwarf.Offset][]line{}}
 }
 
+// SourceLine is the position in the source code of an instruction, as given by DWARFLines.SourceLines.
+type SourceLine struct {
+	// File is the name of the source file.
+	File string
+	// Line and Column are the position in File, or zero when unknown.
+	Line, Column int64
+	// Inlined is true when this position is in a function inlined into the next one.
+	Inlined bool
+}
+
 // Line returns the line information for the given instructionOffset which is an offset in
 // the code section of the original Wasm binary. Returns empty string if the info is not found.
 func (d *DWARFLines) Line(instructionOffset uint64) (ret []string) {
+	prefix := fmt.Sprintf("%#x: ", instructionOffset)
+	for i, l := range d.SourceLines(instructionOffset) {
+		if i == 1 {
+			prefix = strings.Repeat(" ", len(prefix))
+		}
+		ret = append(ret, formatLine(prefix, l.File, l.Line, l.Column, l.Inlined))
+	}
+	return
+}
+
+// SourceLines returns the positions in the source code for the given instructionOffset which is an offset in the code
+// section of the original Wasm binary, beginning with the innermost one when the instruction is inlined. Returns nil
+// if the info is not found.
+func (d *DWARFLines) SourceLines(instructionOffset uint64) (ret []SourceLine) {
 	if d == nil {
 		return
 	}
@@ -46,10 +70,110 @@ func (d *DWARFLines) Line(instructionOffset uint64) (ret []string) {
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
+	cu, inlinedRoutines := d.entries(instructionOffset)
+	// If the relevant compilation unit is not found, nothing we can do with this DWARF info.
+	if cu == nil {
+		return
+	}
+
+	lines, lineReader, ok := d.lines(cu)
+	if !ok {
+		return
+	}
+
+	// Now we have the lines for this entry. We can find the corresponding source line for instructionOffset
+	// via binary search on the list.
+	n := len(lines)
+	index := sort.Search(n, func(i int) bool { return lines[i].addr >= instructionOffset })
+
+	if index == n { // This case the address is not found. See the doc sort.Search.
+		return
+	}
+
+	ln := lines[index]
+	if ln.addr != instructionOffset {
+		// If the address doesn't match exactly, the previous entry is the one that contains the instruction.
+		// That can happen anytime as the DWARF spec allows it, and other tools can handle it in this way conventionally
+		// https://github.com/gimli-rs/addr2line/blob/3a2dbaf84551a06a429f26e9c96071bb409b371f/src/lib.rs#L236-L242
+		// https://github.com/kateinoigakukun/wasminspect/blob/f29f052f1b03104da9f702508ac0c1bbc3530ae4/crates/debugger/src/dwarf/mod.rs#L453-L459
+		if index-1 < 0 {
+			return
+		}
+		ln = lines[index-1]
+	}
+
+	// Advance the line reader for the found position.
+	var le dwarf.LineEntry
+	lineReader.Seek(ln.pos)
+	err := lineReader.Next(&le)
+
+	if err != nil {
+		// If we reach this block, that means there's a bug in the []line creation logic above.
+		panic("BUG: stored dwarf.LineReaderPos is invalid")
+	}
+
+	// In the inlined case, the line info is the innermost inlined function call.
+	inlined := len(inlinedRoutines) != 0
+	ret = append(ret, SourceLine{File: le.File.Name, Line: int64(le.Line), Column: int64(le.Column), Inlined: inlined})
+
+	if inlined {
+		files := lineReader.Files()
+		// inlinedRoutines contain the inlined call information in the reverse order (children is higher than parent),
+		// so we traverse the reverse order and emit the inlined calls.
+		for i := len(inlinedRoutines) - 1; i >= 0; i-- {
+			inlined := inlinedRoutines[i]
+			fileIndex, ok := inlined.Val(dwarf.AttrCallFile).(int64)
+			if !ok {
+				return
+			} else if fileIndex >= int64(len(files)) {
+				// This in theory shouldn't happen according to the spec, but guard against ill-formed DWARF info.
+				return
+			}
+			fileName := files[fileIndex]
+			line, _ := inlined.Val(dwarf.AttrCallLine).(int64)
+			col, _ := inlined.Val(dwarf.AttrCallColumn).(int64)
+			ret = append(ret, SourceLine{File: fileName.Name, Line: line, Column: col,
+				// Last one is the origin of the inlined function calls.
+				Inlined: i != 0})
+		}
+	}
+	return
+}
+
+// LineOffsets returns the offsets of the instructions in the range [start, end), which are offsets in the code section
+// of the original Wasm binary, where the line information changes, beginning with start. The SourceLines of each are
+// the positions in the source code of the range. Returns nil if the info is not found.
+func (d *DWARFLines) LineOffsets(start, end uint64) (ret []uint64) {
+	if d == nil {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	cu, _ := d.entries(start)
+	if cu == nil {
+		return
+	}
+	lines, _, ok := d.lines(cu)
+	if !ok {
+		return
+	}
+
+	ret = append(ret, start)
+	for i := sort.Search(len(lines), func(i int) bool { return lines[i].addr > start }); i < len(lines) && lines[i].addr < end; i++ {
+		if addr := lines[i].addr; addr != ret[len(ret)-1] {
+			ret = append(ret, addr)
+		}
+	}
+	return
+}
+
+// entries returns the compilation unit containing the given instructionOffset, and the inlined subroutines containing
+// it from the outermost to the innermost. cu is nil if not found.
+func (d *DWARFLines) entries(instructionOffset uint64) (cu *dwarf.Entry, inlinedRoutines []*dwarf.Entry) {
 	r := d.d.Reader()
 
-	var inlinedRoutines []*dwarf.Entry
-	var cu *dwarf.Entry
 	var inlinedDone bool
 entry:
 	for {
@@ -91,19 +215,16 @@ entry:
 			}
 		}
 	}
+	return
+}
 
-	// If the relevant compilation unit is not found, nothing we can do with this DWARF info.
-	if cu == nil {
-		return
-	}
-
+// lines returns the lines of the compilation unit cu sorted in the increasing order by the address, and its line
+// reader. ok is false if the line information can't be read.
+func (d *DWARFLines) lines(cu *dwarf.Entry) (lines []line, lineReader *dwarf.LineReader, ok bool) {
 	lineReader, err := d.d.LineReader(cu)
 	if err != nil || lineReader == nil {
 		return
 	}
-	var lines []line
-	var ok bool
-	var le dwarf.LineEntry
 	// Get the lines inside the entry.
 	if lines, ok = d.linesPerEntry[cu.Offset]; !ok {
 		// If not found, we create the list of lines by reading all the LineEntries in the Entry.
@@ -114,6 +235,7 @@ entry:
 		// and in fact Zig language tends to emit interleaved line information.
 		//
 		// Thus, here we read all line entries here, and sort them in the increasing order wrt addresses.
+		var le dwarf.LineEntry
 		for {
 			pos := lineReader.Tell()
 			err = lineReader.Next(&le)
@@ -127,65 +249,7 @@ entry:
 		sort.Slice(lines, func(i, j int) bool { return lines[i].addr < lines[j].addr })
 		d.linesPerEntry[cu.Offset] = lines // Caches for the future inquiries for the same Entry.
 	}
-
-	// Now we have the lines for this entry. We can find the corresponding source line for instructionOffset
-	// via binary search on the list.
-	n := len(lines)
-	index := sort.Search(n, func(i int) bool { return lines[i].addr >= instructionOffset })
-
-	if index == n { // This case the address is not found. See the doc sort.Search.
-		return
-	}
-
-	ln := lines[index]
-	if ln.addr != instructionOffset {
-		// If the address doesn't match exactly, the previous entry is the one that contains the instruction.
-		// That can happen anytime as the DWARF spec allows it, and other tools can handle it in this way conventionally
-		// https://github.com/gimli-rs/addr2line/blob/3a2dbaf84551a06a429f26e9c96071bb409b371f/src/lib.rs#L236-L242
-		// https://github.com/kateinoigakukun/wasminspect/blob/f29f052f1b03104da9f702508ac0c1bbc3530ae4/crates/debugger/src/dwarf/mod.rs#L453-L459
-		if index-1 < 0 {
-			return
-		}
-		ln = lines[index-1]
-	}
-
-	// Advance the line reader for the found position.
-	lineReader.Seek(ln.pos)
-	err = lineReader.Next(&le)
-
-	if err != nil {
-		// If we reach this block, that means there's a bug in the []line creation logic above.
-		panic("BUG: stored dwarf.LineReaderPos is invalid")
-	}
-
-	// In the inlined case, the line info is the innermost inlined function call.
-	inlined := len(inlinedRoutines) != 0
-	prefix := fmt.Sprintf("%#x: ", instructionOffset)
-	ret = append(ret, formatLine(prefix, le.File.Name, int64(le.Line), int64(le.Column), inlined))
-
-	if inlined {
-		prefix = strings.Repeat(" ", len(prefix))
-		files := lineReader.Files()
-		// inlinedRoutines contain the inlined call information in the reverse order (children is higher than parent),
-		// so we traverse the reverse order and emit the inlined calls.
-		for i := len(inlinedRoutines) - 1; i >= 0; i-- {
-			inlined := inlinedRoutines[i]
-			fileIndex, ok := inlined.Val(dwarf.AttrCallFile).(int64)
-			if !ok {
-				return
-			} else if fileIndex >= int64(len(files)) {
-				// This in theory shouldn't happen according to the spec, but guard against ill-formed DWARF info.
-				return
-			}
-			fileName := files[fileIndex]
-			line, _ := inlined.Val(dwarf.AttrCallLine).(int64)
-			col, _ := inlined.Val(dwarf.AttrCallColumn).(int64)
-			ret = append(ret, formatLine(prefix, fileName.Name, line, col,
-				// Last one is the origin of the inlined function calls.
-				i != 0))
-		}
-	}
-	return
+	return lines, lineReader, true
 }
 
 func formatLine(prefix, fileName string, line, col int64, inlined bool) string {
@@ -2,6 +2,7 @@ package wasmdebug_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
@@ -10,6 +11,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
 func TestDWARFLines_Line_Zig(t *testing.T) {
@@ -69,6 +71,40 @@ func TestDWARFLines_Line_Zig(t *testing.T) {
 	}
 }
 
+func TestDWARFLines_SourceLines_Zig(t *testing.T) {
+	mod, err := binary.DecodeModule(dwarftestdata.ZigWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+
+	// See TestDWARFLines_Line_Zig for the origin of this offset.
+	lines := mod.DWARFLines.SourceLines(0xa6 - 0x46)
+	require.Equal(t, 3, len(lines))
+	for i, exp := range []wasmdebug.SourceLine{
+		{File: "main.zig", Line: 10, Column: 5, Inlined: true},
+		{File: "main.zig", Line: 6, Column: 5, Inlined: true},
+		{File: "main.zig", Line: 2, Column: 5},
+	} {
+		require.True(t, strings.HasSuffix(lines[i].File, "/"+exp.File), lines[i].File)
+		lines[i].File = exp.File
+		require.Equal(t, exp, lines[i])
+	}
+
+	require.Nil(t, mod.DWARFLines.SourceLines(0))
+	require.Nil(t, (*wasmdebug.DWARFLines)(nil).SourceLines(0xa6-0x46))
+}
+
+func TestDWARFLines_LineOffsets_Zig(t *testing.T) {
+	mod, err := binary.DecodeModule(dwarftestdata.ZigWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+
+	// The body of builtin.default_panic is in [0x5, 0x3c), where the line changes at 0x35 and 0x3a.
+	require.Equal(t, []uint64{0x5, 0x35, 0x3a}, mod.DWARFLines.LineOffsets(0x5, 0x3c))
+	require.Equal(t, []uint64{0x6}, mod.DWARFLines.LineOffsets(0x6, 0x35))
+	require.Equal(t, []uint64{0x35}, mod.DWARFLines.LineOffsets(0x35, 0x3a))
+
+	require.Nil(t, mod.DWARFLines.LineOffsets(0, 1))
+	require.Nil(t, (*wasmdebug.DWARFLines)(nil).LineOffsets(0x5, 0x3c))
+}
+
 func TestDWARFLines_Line_Rust(t *testing.T) {
 	if len(dwarftestdata.RustWasm) == 0 {
 		t.Skip()
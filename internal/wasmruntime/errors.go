@@ -27,6 +27,25 @@ var (
 	ErrRuntimeInvalidTableAccess = New("invalid table access")
 	// ErrRuntimeIndirectCallTypeMismatch indicates that the type check failed during call_indirect.
 	ErrRuntimeIndirectCallTypeMismatch = New("indirect call type mismatch")
+	// ErrRuntimeUnalignedAtomic indicates that an atomic operation was made with incorrect memory alignment.
+	ErrRuntimeUnalignedAtomic = New("unaligned atomic")
+	// ErrRuntimeExpectedSharedMemory indicates that an operation was made against unshared memory when not allowed.
+	ErrRuntimeExpectedSharedMemory = New("expected shared memory")
+	// ErrRuntimeNullReference indicates that a null reference was used by call_ref, ref.as_non_null or the GC
+	// instructions on structs and arrays.
+	ErrRuntimeNullReference = New("null reference")
+	// ErrRuntimeCastFailure indicates that ref.cast failed, or a struct or an array was accessed through a reference
+	// of a different type.
+	ErrRuntimeCastFailure = New("cast failure")
+	// ErrRuntimeOutOfBoundsArrayAccess indicates that an array element was accessed by an index out of its bounds.
+	ErrRuntimeOutOfBoundsArrayAccess = New("out of bounds array access")
+	// ErrRuntimeArrayTooLarge indicates that array.new or array.new_default tried to allocate too many elements.
+	ErrRuntimeArrayTooLarge = New("array too large")
+	// ErrRuntimeFuelExhausted indicates that a function compiled with fuel metering ran out of the fuel of the call.
+	ErrRuntimeFuelExhausted = New("fuel exhausted")
+	// ErrRuntimeInterrupted indicates that the context.Context of the call was canceled or reached its deadline, and
+	// the call was interrupted without closing the module.
+	ErrRuntimeInterrupted = New("interrupted")
 )
 
 // Error is returned by a wasm.Engine during the execution of Wasm functions, and they indicate that the Wasm runtime
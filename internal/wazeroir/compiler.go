@@ -22,6 +22,7 @@ const (
 	controlFrameKindLoop
 	controlFrameKindIfWithElse
 	controlFrameKindIfWithoutElse
+	controlFrameKindTry
 )
 
 type (
@@ -32,8 +33,26 @@ type (
 		originalStackLenWithoutParam int
 		blockType                    *wasm.FunctionType
 		kind                         controlFrameKind
+
+		// The following fields are only used by controlFrameKindTry.
+
+		// catching is true after the first catch or catch_all of the try, where the exceptions thrown are no longer
+		// handled by this frame.
+		catching bool
+		// dispatch is the label where the pending exception is matched against the next catch clause, and nil after
+		// catch_all.
+		dispatch *Label
+		// catchOp is the OperationCatch of the current catch clause.
+		catchOp *OperationCatch
 	}
 	controlFrames struct{ frames []*controlFrame }
+
+	// exceptionHandler is where the control is transferred when an exception is thrown.
+	exceptionHandler struct {
+		label *Label
+		// stackLen is the number of values on the stack when entering into the handler.
+		stackLen int
+	}
 )
 
 func (c *controlFrame) ensureContinuation() {
@@ -56,7 +75,8 @@ func (c *controlFrame) asBranchTarget() *BranchTarget {
 		// Note nil target is translated as return.
 		return &BranchTarget{Label: nil}
 	case controlFrameKindIfWithElse,
-		controlFrameKindIfWithoutElse:
+		controlFrameKindIfWithoutElse,
+		controlFrameKindTry:
 		return &BranchTarget{Label: &Label{FrameID: c.frameID, Kind: LabelKindContinuation}}
 	}
 	panic(fmt.Sprintf("unreachable: a bug in wazeroir implementation: %v", c.kind))
@@ -188,6 +208,32 @@ type compiler struct {
 	bodyOffsetInCodeSection uint64
 
 	ensureTermination bool
+
+	// fuelMetering is true if each basic block consumes the fuel for its instructions (OperationConsumeFuel).
+	fuelMetering bool
+	// fuel is the OperationConsumeFuel of the current basic block, whose Cost is incremented for each reachable
+	// instruction. This is nil unless fuelMetering is true.
+	fuel *OperationConsumeFuel
+
+	// coverage is true if each basic block counts the times it is executed (OperationCoverBlock).
+	coverage bool
+	// cover is the OperationCoverBlock of the current basic block, whose range in CompilationResult.CoverageBlocks
+	// is extended to each reachable instruction. This is nil unless coverage is true.
+	cover *OperationCoverBlock
+
+	// memoryIndex is the index of the memory selected by OperationSelectMemory for the current instruction. This is
+	// non-zero only while handling an instruction with a non-zero memory index immediate, and reset to zero after it.
+	memoryIndex uint32
+	// memories hold all the memories in the module where the target function exists.
+	memories []*wasm.Memory
+	// hasMemory64 is true if any of memories is 64-bit (api.CoreFeatureMemory64).
+	hasMemory64 bool
+
+	// tags hold the type indexes for all tags in the module where the target function exists.
+	tags []wasm.Index
+	// exceptionExit is the handler of the exceptions uncaught in the function, which returns to the caller with the
+	// exception pending. This is nil unless api.CoreFeatureExceptionHandling is enabled.
+	exceptionExit *exceptionHandler
 }
 
 //lint:ignore U1000 for debugging only.
@@ -246,29 +292,51 @@ type CompilationResult struct {
 	Functions []wasm.Index
 	// Types holds all the types in the module from which this function is compiled.
 	Types []*wasm.FunctionType
+	// Tags holds the type indexes of all the tags in the module from which this function is compiled.
+	Tags []wasm.Index
 	// TableTypes holds all the reference types of all tables declared in the module.
 	TableTypes []wasm.ValueType
 	// HasMemory is true if the module from which this function is compiled has memory declaration.
 	HasMemory bool
 	// UsesMemory is true if this function might use memory.
 	UsesMemory bool
+	// GuardedMemory is true if the memory of index zero is defined by the module and reserved in virtual memory, so
+	// that the accesses out of its bounds fault. See wasm.Module ReservesMemoryZero
+	GuardedMemory bool
+	// SharedMemory is true if any memory of the module is shared, in which case other agents might grow it at any
+	// time, so its length is reloaded before each access instead of only when entering a function.
+	SharedMemory bool
 	// HasTable is true if the module from which this function is compiled has table declaration.
 	HasTable bool
+	// HasCallRef is true if any function in the module from which this function is compiled has call_ref or
+	// return_call_ref instructions, which check the type IDs of the module like call_indirect does.
+	HasCallRef bool
 	// HasDataInstances is true if the module has data instances which might be used by memory.init or data.drop instructions.
 	HasDataInstances bool
 	// HasDataInstances is true if the module has element instances which might be used by table.init or elem.drop instructions.
 	HasElementInstances bool
 	EnsureTermination   bool
+	// FuelMetering is true if this function was compiled with OperationConsumeFuel at the beginning of each basic block.
+	FuelMetering bool
+	// CoverageBlocks are the basic blocks of this function indexed by OperationCoverBlock.Index, which is placed at
+	// the beginning of each of them. This is nil unless compiled with coverage.
+	CoverageBlocks []wasm.CoverageBlock
 }
 
-func CompileFunctions(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination bool) ([]*CompilationResult, error) {
-	functions, globals, mem, tables, err := module.AllDeclarations()
+func CompileFunctions(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination, fuelMetering, coverage bool) ([]*CompilationResult, error) {
+	functions, globals, memories, tables, err := module.AllDeclarations()
 	if err != nil {
 		return nil, err
 	}
 
-	hasMemory, hasTable, hasDataInstances, hasElementInstances := mem != nil, len(tables) > 0,
+	hasMemory, hasTable, hasDataInstances, hasElementInstances := len(memories) > 0, len(tables) > 0,
 		len(module.DataSection) > 0, len(module.ElementSection) > 0
+	guardedMemory := module.ReservesMemoryZero()
+	var sharedMemory bool
+	for _, mem := range memories {
+		sharedMemory = sharedMemory || mem.IsShared
+	}
+	tags := module.AllTags()
 
 	tableTypes := make([]wasm.ValueType, len(tables))
 	for i := range tableTypes {
@@ -276,6 +344,7 @@ func CompileFunctions(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint
 	}
 
 	var ret []*CompilationResult
+	var hasCallRef bool
 	for funcIndex := range module.FunctionSection {
 		typeID := module.FunctionSection[funcIndex]
 		sig := module.TypeSection[typeID]
@@ -299,8 +368,8 @@ func CompileFunctions(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint
 			continue
 		}
 		r, err := compile(enabledFeatures, callFrameStackSizeInUint64, sig, code.Body,
-			code.LocalTypes, module.TypeSection, functions, globals, code.BodyOffsetInCodeSection,
-			module.DWARFLines != nil, ensureTermination)
+			code.LocalTypes, module.TypeSection, functions, globals, memories, tags, code.BodyOffsetInCodeSection,
+			module.DWARFLines != nil, ensureTermination, fuelMetering, coverage)
 		if err != nil {
 			def := module.FunctionDefinitionSection[uint32(funcIndex)+module.ImportFuncCount()]
 			return nil, fmt.Errorf("failed to lower func[%s] to wazeroir: %w", def.DebugName(), err)
@@ -309,14 +378,26 @@ func CompileFunctions(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint
 		r.Globals = globals
 		r.Functions = functions
 		r.Types = module.TypeSection
+		r.Tags = tags
 		r.HasMemory = hasMemory
+		r.GuardedMemory = guardedMemory
+		r.SharedMemory = sharedMemory
 		r.HasTable = hasTable
 		r.HasDataInstances = hasDataInstances
 		r.HasElementInstances = hasElementInstances
 		r.Signature = sig
 		r.TableTypes = tableTypes
 		r.EnsureTermination = ensureTermination
+		r.FuelMetering = fuelMetering
 		ret = append(ret, r)
+		hasCallRef = hasCallRef || r.HasCallRef
+	}
+
+	// The module context is shared by all the functions in the module, so they all need to know call_ref is used.
+	if hasCallRef {
+		for _, r := range ret {
+			r.HasCallRef = true
+		}
 	}
 	return ret, nil
 }
@@ -331,9 +412,13 @@ func compile(enabledFeatures api.CoreFeatures,
 	localTypes []wasm.ValueType,
 	types []*wasm.FunctionType,
 	functions []uint32, globals []*wasm.GlobalType,
+	memories []*wasm.Memory,
+	tags []wasm.Index,
 	bodyOffsetInCodeSection uint64,
 	needSourceOffset bool,
 	ensureTermination bool,
+	fuelMetering bool,
+	coverage bool,
 ) (*CompilationResult, error) {
 	c := compiler{
 		enabledFeatures:            enabledFeatures,
@@ -349,10 +434,28 @@ func compile(enabledFeatures api.CoreFeatures,
 		needSourceOffset:           needSourceOffset,
 		bodyOffsetInCodeSection:    bodyOffsetInCodeSection,
 		ensureTermination:          ensureTermination,
+		fuelMetering:               fuelMetering,
+		coverage:                   coverage,
+		memories:                   memories,
+		tags:                       tags,
+	}
+	for _, m := range memories {
+		if m.Is64 {
+			c.hasMemory64 = true
+		}
 	}
 
 	c.initializeStack()
 
+	if c.fuelMetering {
+		// The function entry starts the first basic block.
+		c.fuel = &OperationConsumeFuel{}
+		c.emit(c.fuel)
+	}
+	if c.coverage {
+		c.emitCoverBlock()
+	}
+
 	// Emit const expressions for locals.
 	// Note that here we don't take function arguments
 	// into account, meaning that callers must push
@@ -361,6 +464,13 @@ func compile(enabledFeatures api.CoreFeatures,
 		c.emitDefaultValue(t)
 	}
 
+	if enabledFeatures.IsEnabled(api.CoreFeatureExceptionHandling) {
+		c.exceptionExit = &exceptionHandler{
+			label:    &Label{FrameID: c.nextID(), Kind: LabelKindHeader},
+			stackLen: len(c.stack),
+		}
+	}
+
 	// Insert the function control frame.
 	c.controlFrames.push(&controlFrame{
 		frameID:   c.nextID(),
@@ -374,18 +484,93 @@ func compile(enabledFeatures api.CoreFeatures,
 
 	// Now, enter the function body.
 	for !c.controlFrames.empty() && c.pc < uint64(len(c.body)) {
+		// The instruction belongs to the current basic block, even if it starts a new one.
+		pc, cover := c.pc, c.cover
+		if c.unreachableState.on {
+			cover = nil
+		}
 		if err := c.handleInstruction(); err != nil {
 			return nil, fmt.Errorf("handling instruction: %w", err)
 		}
+		if cover != nil {
+			b := &c.result.CoverageBlocks[cover.Index]
+			if b.Start == b.End {
+				b.Start = c.bodyOffsetInCodeSection + pc
+			}
+			b.End = c.bodyOffsetInCodeSection + c.pc
+		}
+	}
+
+	if h := c.exceptionExit; h != nil && c.result.LabelCallers[h.label.String()] > 0 {
+		// Return to the caller with the default results, leaving the exception pending.
+		c.resetUnreachable()
+		c.stack = c.stack[:h.stackLen]
+		c.emit(&OperationLabel{Label: h.label})
+		for _, t := range c.sig.Results {
+			c.emitDefaultValue(t)
+		}
+		var drop *InclusiveRange
+		if start, end := c.sig.ResultNumInUint64, c.stackLenInUint64(len(c.stack))-1; start <= end {
+			drop = &InclusiveRange{Start: start, End: end}
+		}
+		c.emit(
+			&OperationDrop{Depth: drop},
+			&OperationBr{Target: &BranchTarget{}},
+		)
+	}
+
+	if c.fuelMetering || c.coverage {
+		c.removeEmptyBasicBlocks()
 	}
 	return &c.result, nil
 }
 
+// removeEmptyBasicBlocks removes OperationConsumeFuel and OperationCoverBlock of the basic blocks without any
+// instruction, e.g. the one starting at the end of a block which is immediately followed by the end of its parent.
+func (c *compiler) removeEmptyBasicBlocks() {
+	ops, offsets := c.result.Operations[:0], c.result.IROperationSourceOffsetsInWasmBinary[:0]
+	blocks := c.result.CoverageBlocks[:0]
+	for i, op := range c.result.Operations {
+		switch o := op.(type) {
+		case *OperationConsumeFuel:
+			if o.Cost == 0 {
+				continue
+			}
+		case *OperationCoverBlock:
+			// The blocks are in the order of their operations, so the index only decreases.
+			b := c.result.CoverageBlocks[o.Index]
+			if b.Start == b.End {
+				continue
+			}
+			o.Index = uint32(len(blocks))
+			blocks = append(blocks, b)
+		}
+		ops = append(ops, op)
+		if c.needSourceOffset {
+			offsets = append(offsets, c.result.IROperationSourceOffsetsInWasmBinary[i])
+		}
+	}
+	c.result.Operations, c.result.IROperationSourceOffsetsInWasmBinary = ops, offsets
+	if c.coverage {
+		c.result.CoverageBlocks = blocks
+	}
+}
+
+// emitCoverBlock starts a new basic block, which counts the times it is executed.
+func (c *compiler) emitCoverBlock() {
+	c.cover = &OperationCoverBlock{Index: uint32(len(c.result.CoverageBlocks))}
+	c.result.CoverageBlocks = append(c.result.CoverageBlocks, wasm.CoverageBlock{})
+	c.appendOperation(c.cover)
+}
+
 // Translate the current Wasm instruction to wazeroir's operations,
 // and emit the results into c.results.
 func (c *compiler) handleInstruction() error {
 	op := c.body[c.pc]
 	c.currentOpPC = c.pc
+	if c.fuel != nil && !c.unreachableState.on {
+		c.fuel.Cost++
+	}
 	if false {
 		var instName string
 		if op == wasm.OpcodeVecPrefix {
@@ -533,6 +718,160 @@ operatorSwitch:
 				Label: thenLabel,
 			},
 		)
+	case wasm.OpcodeTry:
+		bt, num, err := wasm.DecodeBlockType(c.types, bytes.NewReader(c.body[c.pc+1:]), c.enabledFeatures)
+		if err != nil {
+			return fmt.Errorf("reading block type for try instruction: %w", err)
+		}
+		c.pc += num
+
+		if c.unreachableState.on {
+			// If it is currently in unreachable,
+			// just remove the entire block.
+			c.unreachableState.depth++
+			break operatorSwitch
+		}
+
+		// Create a new frame -- entering try. The exceptions thrown in the body are dispatched
+		// to the catch clauses from the dispatch label.
+		frame := &controlFrame{
+			frameID:                      c.nextID(),
+			originalStackLenWithoutParam: len(c.stack) - len(bt.Params),
+			kind:                         controlFrameKindTry,
+			blockType:                    bt,
+		}
+		frame.dispatch = &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+		c.controlFrames.push(frame)
+	case wasm.OpcodeCatch, wasm.OpcodeCatchAll:
+		var tagIndex uint32
+		if op == wasm.OpcodeCatch {
+			v, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+			if err != nil {
+				return fmt.Errorf("read the tag for catch: %w", err)
+			}
+			c.pc += n
+			tagIndex = v
+		}
+
+		if c.unreachableState.on && c.unreachableState.depth > 0 {
+			// If it is currently in unreachable, and the nested try,
+			// just remove the entire catch block.
+			break operatorSwitch
+		}
+
+		frame := c.controlFrames.top()
+		if c.unreachableState.on {
+			// We are no longer unreachable in the catch block.
+			c.resetUnreachable()
+		} else {
+			// Exit the previous block to the continuation of this try.
+			continuationLabel := &Label{FrameID: frame.frameID, Kind: LabelKindContinuation}
+			c.result.LabelCallers[continuationLabel.String()]++
+			c.emit(
+				&OperationDrop{Depth: c.getFrameDropRange(frame, true)},
+				&OperationBr{Target: continuationLabel.asBranchTarget()},
+			)
+		}
+		c.stack = c.stack[:frame.originalStackLenWithoutParam]
+		frame.catching = true
+
+		c.emit(&OperationLabel{Label: frame.dispatch})
+		catchOp := &OperationCatch{TagIndex: tagIndex, All: op == wasm.OpcodeCatchAll}
+		if op == wasm.OpcodeCatch {
+			// Enter this catch block if the tag matches, otherwise try the next one.
+			catchLabel := &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+			frame.dispatch = &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+			c.result.LabelCallers[catchLabel.String()]++
+			c.result.LabelCallers[frame.dispatch.String()]++
+			c.emit(
+				&OperationExceptionMatch{TagIndex: tagIndex},
+				&OperationBrIf{
+					Then: catchLabel.asBranchTargetDrop(),
+					Else: frame.dispatch.asBranchTargetDrop(),
+				},
+				&OperationLabel{Label: catchLabel},
+			)
+		} else {
+			frame.dispatch = nil
+		}
+		c.emit(catchOp)
+		frame.catchOp = catchOp
+
+		// The reference to the exception is kept below the payload for rethrow.
+		c.stackPush(UnsignedTypeI64)
+		if op == wasm.OpcodeCatch {
+			for _, t := range c.types[c.tags[tagIndex]].Params {
+				c.stackPush(wasmValueTypeToUnsignedType(t))
+			}
+		}
+	case wasm.OpcodeThrow:
+		if c.unreachableState.on {
+			break operatorSwitch
+		}
+		c.emit(
+			&OperationThrow{TagIndex: index},
+		)
+		c.emitBranchToExceptionHandler(c.exceptionHandler(0), len(c.stack))
+		// Throw operation is stack-polymorphic, and mark the state as unreachable.
+		c.markUnreachable()
+	case wasm.OpcodeRethrow:
+		l, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read the target for rethrow: %w", err)
+		}
+		c.pc += n
+
+		if c.unreachableState.on {
+			break operatorSwitch
+		}
+
+		frame := c.controlFrames.get(int(l))
+		frame.catchOp.Rethrown = true
+		// Pick the reference to the exception pushed at the bottom of the catch block.
+		depth := c.stackLenInUint64(len(c.stack)) - 1 - c.stackLenInUint64(frame.originalStackLenWithoutParam)
+		c.emit(
+			&OperationPick{Depth: depth},
+			&OperationRethrow{},
+		)
+		c.emitBranchToExceptionHandler(c.exceptionHandler(0), len(c.stack))
+		// Rethrow operation is stack-polymorphic, and mark the state as unreachable.
+		c.markUnreachable()
+	case wasm.OpcodeDelegate:
+		l, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read the target for delegate: %w", err)
+		}
+		c.pc += n
+
+		if c.unreachableState.on && c.unreachableState.depth > 0 {
+			// Delegate ends the try block as end does.
+			c.unreachableState.depth--
+			break operatorSwitch
+		}
+
+		frame := c.controlFrames.pop()
+		continuationLabel := &Label{FrameID: frame.frameID, Kind: LabelKindContinuation}
+		if c.unreachableState.on {
+			c.resetUnreachable()
+		} else {
+			c.result.LabelCallers[continuationLabel.String()]++
+			c.emit(
+				&OperationDrop{Depth: c.getFrameDropRange(frame, true)},
+				&OperationBr{Target: continuationLabel.asBranchTarget()},
+			)
+		}
+		c.stack = c.stack[:frame.originalStackLenWithoutParam]
+
+		// The exceptions thrown in the body are handled as if they are thrown in the target block.
+		c.emit(&OperationLabel{Label: frame.dispatch})
+		c.emitBranchToExceptionHandler(c.exceptionHandler(int(l)), len(c.stack))
+
+		for _, t := range frame.blockType.Results {
+			c.stackPush(wasmValueTypeToUnsignedType(t))
+		}
+		c.emit(
+			&OperationLabel{Label: continuationLabel},
+		)
 	case wasm.OpcodeElse:
 		frame := c.controlFrames.top()
 		if c.unreachableState.on && c.unreachableState.depth > 0 {
@@ -604,6 +943,9 @@ operatorSwitch:
 			}
 
 			c.stack = c.stack[:frame.originalStackLenWithoutParam]
+			if frame.kind == controlFrameKindTry {
+				c.emitUncaughtException(frame)
+			}
 			for _, t := range frame.blockType.Results {
 				c.stackPush(wasmValueTypeToUnsignedType(t))
 			}
@@ -679,6 +1021,17 @@ operatorSwitch:
 			c.emit(
 				dropOp,
 			)
+		case controlFrameKindTry:
+			continuationLabel := &Label{Kind: LabelKindContinuation, FrameID: frame.frameID}
+			c.result.LabelCallers[continuationLabel.String()]++
+			c.emit(
+				dropOp,
+				&OperationBr{Target: continuationLabel.asBranchTarget()},
+			)
+			c.emitUncaughtException(frame)
+			c.emit(
+				&OperationLabel{Label: continuationLabel},
+			)
 		default:
 			// Should never happen. If so, there's a bug in the translation.
 			panic(fmt.Errorf("bug: invalid control frame kind: 0x%x", frame.kind))
@@ -819,6 +1172,7 @@ operatorSwitch:
 		c.emit(
 			&OperationCall{FunctionIndex: index},
 		)
+		c.emitExceptionCheck()
 	case wasm.OpcodeCallIndirect:
 		tableIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
 		if err != nil {
@@ -828,6 +1182,55 @@ operatorSwitch:
 		c.emit(
 			&OperationCallIndirect{TypeIndex: index, TableIndex: tableIndex},
 		)
+		c.emitExceptionCheck()
+	case wasm.OpcodeReturnCall:
+		// If it is on the unreachable state, ignore the instruction.
+		if c.unreachableState.on {
+			break operatorSwitch
+		}
+		// Drop all the values in the function frame except the arguments to the callee.
+		params := c.types[c.funcs[index]].ParamNumInUint64
+		c.emit(
+			&OperationTailCall{FunctionIndex: index, Drop: c.getTailCallDropRange(params)},
+		)
+		// Same as return, this is stack-polymorphic.
+		c.markUnreachable()
+	case wasm.OpcodeReturnCallIndirect:
+		tableIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read target for return_call_indirect: %w", err)
+		}
+		c.pc += n
+		// If it is on the unreachable state, ignore the instruction.
+		if c.unreachableState.on {
+			break operatorSwitch
+		}
+		// Drop all the values in the function frame except the arguments to the callee and the offset in the table.
+		params := c.types[index].ParamNumInUint64 + 1
+		c.emit(
+			&OperationTailCallIndirect{TypeIndex: index, TableIndex: tableIndex, Drop: c.getTailCallDropRange(params)},
+		)
+		// Same as return, this is stack-polymorphic.
+		c.markUnreachable()
+	case wasm.OpcodeCallRef:
+		c.result.HasCallRef = true
+		c.emit(
+			&OperationCallRef{TypeIndex: index},
+		)
+		c.emitExceptionCheck()
+	case wasm.OpcodeReturnCallRef:
+		// If it is on the unreachable state, ignore the instruction.
+		if c.unreachableState.on {
+			break operatorSwitch
+		}
+		c.result.HasCallRef = true
+		// Drop all the values in the function frame except the arguments to the callee and the function reference.
+		params := c.types[index].ParamNumInUint64 + 1
+		c.emit(
+			&OperationTailCallRef{TypeIndex: index, Drop: c.getTailCallDropRange(params)},
+		)
+		// Same as return, this is stack-polymorphic.
+		c.markUnreachable()
 	case wasm.OpcodeDrop:
 		r := &InclusiveRange{Start: 0, End: 0}
 		if peekValueType == UnsignedTypeV128 {
@@ -1096,16 +1499,18 @@ operatorSwitch:
 			&OperationStore32{Arg: imm},
 		)
 	case wasm.OpcodeMemorySize:
-		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		if err := c.readMemoryIndex(wasm.OpcodeMemorySizeName); err != nil {
+			return err
+		}
 		c.emit(
-			&OperationMemorySize{},
+			&OperationMemorySize{Memory64: c.isMemory64(c.memoryIndex)},
 		)
 	case wasm.OpcodeMemoryGrow:
-		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		if err := c.readMemoryIndex(wasm.OpcodeMemoryGrowName); err != nil {
+			return err
+		}
 		c.emit(
-			&OperationMemoryGrow{},
+			&OperationMemoryGrow{Memory64: c.isMemory64(c.memoryIndex)},
 		)
 	case wasm.OpcodeI32Const:
 		val, num, err := leb128.LoadInt32(c.body[c.pc+1:])
@@ -1660,10 +2065,94 @@ operatorSwitch:
 			&OperationRefFunc{FunctionIndex: index},
 		)
 	case wasm.OpcodeRefNull:
-		c.pc++ // Skip the type of reftype as every ref value is opaque pointer.
+		// Skip the heap type, which is a signed 33-bit integer, as every ref value is opaque pointer.
+		_, num, err := leb128.DecodeInt33AsInt64(bytes.NewReader(c.body[c.pc+1:]))
+		if err != nil {
+			return fmt.Errorf("failed to read heap type for ref.null: %v", err)
+		}
+		c.pc += num
 		c.emit(
 			&OperationConstI64{Value: 0},
 		)
+	case wasm.OpcodeRefAsNonNull:
+		c.emit(
+			&OperationRefAsNonNull{},
+		)
+	case wasm.OpcodeRefEq:
+		c.emit(
+			&OperationEq{Type: UnsignedTypeI64},
+		)
+	case wasm.OpcodeGCPrefix:
+		gcOp, typeIndex, index, end, err := c.gcImmediates()
+		if err != nil {
+			return err
+		}
+		opSignature, err := c.gcSignature()
+		if err != nil {
+			return err
+		}
+		c.pc = end
+		o := &OperationGC{Opcode: gcOp, TypeIndex: typeIndex, Index: index, Operands: len(opSignature.in)}
+		if len(opSignature.out) > 0 {
+			o.HasResult, o.Result = true, opSignature.out[0]
+		}
+		c.emit(o)
+	case wasm.OpcodeBrOnNull, wasm.OpcodeBrOnNonNull:
+		targetIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read the target for %s: %w", wasm.InstructionName(op), err)
+		}
+		c.pc += n
+
+		if c.unreachableState.on {
+			// If it is currently in unreachable, this is no-op.
+			break operatorSwitch
+		}
+
+		targetFrame := c.controlFrames.get(int(targetIndex))
+		targetFrame.ensureContinuation()
+		target := targetFrame.asBranchTarget()
+		c.result.LabelCallers[target.Label.String()]++
+
+		// The reference on the top of the stack is checked by the copy of it, and the branch is taken on
+		// "branchLabel", where the reference is dropped for br_on_null, or kept as the last value for br_on_non_null.
+		branchLabel := &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+		continuationLabel := &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+		c.result.LabelCallers[branchLabel.String()]++
+		c.result.LabelCallers[continuationLabel.String()]++
+		isNull := &OperationBrIf{Then: branchLabel.asBranchTargetDrop(), Else: continuationLabel.asBranchTargetDrop()}
+		if op == wasm.OpcodeBrOnNonNull {
+			isNull.Then, isNull.Else = isNull.Else, isNull.Then
+		}
+		c.emit(
+			&OperationPick{Depth: 0},
+			&OperationEqz{Type: UnsignedInt64},
+			isNull,
+			&OperationLabel{Label: branchLabel},
+		)
+
+		var drop *InclusiveRange
+		if op == wasm.OpcodeBrOnNull {
+			// The stack has the reference which is dropped before branching.
+			c.emit(&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}})
+			c.stackPop()
+			drop = c.getFrameDropRange(targetFrame, false)
+			c.stackPush(UnsignedTypeI64)
+		} else {
+			// The stack doesn't have the reference which is one of the values to branch with.
+			c.stackPush(UnsignedTypeI64)
+			drop = c.getFrameDropRange(targetFrame, false)
+			c.stackPop()
+		}
+		c.emit(
+			&OperationDrop{Depth: drop},
+			&OperationBr{Target: target},
+			&OperationLabel{Label: continuationLabel},
+		)
+		if op == wasm.OpcodeBrOnNonNull {
+			// The null reference is dropped when not branching.
+			c.emit(&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}})
+		}
 	case wasm.OpcodeRefIsNull:
 		// Simply compare the opaque pointer (i64) with zero.
 		c.emit(
@@ -1736,9 +2225,12 @@ operatorSwitch:
 			if err != nil {
 				return fmt.Errorf("reading i32.const value: %v", err)
 			}
-			c.pc += num + 1 // +1 to skip the memory index which is fixed to zero.
+			c.pc += num
+			if err := c.readMemoryIndex(wasm.OpcodeMemoryInitName); err != nil {
+				return err
+			}
 			c.emit(
-				&OperationMemoryInit{DataIndex: dataIndex},
+				&OperationMemoryInit{DataIndex: dataIndex, Memory64: c.isMemory64(c.memoryIndex)},
 			)
 		case wasm.OpcodeMiscDataDrop:
 			dataIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1751,15 +2243,33 @@ operatorSwitch:
 			)
 		case wasm.OpcodeMiscMemoryCopy:
 			c.result.UsesMemory = true
-			c.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
-			c.emit(
-				&OperationMemoryCopy{},
-			)
+			dst, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+			if err != nil {
+				return fmt.Errorf("reading destination memory index for %s: %w", wasm.OpcodeMemoryCopyName, err)
+			}
+			c.pc += num
+			src, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+			if err != nil {
+				return fmt.Errorf("reading source memory index for %s: %w", wasm.OpcodeMemoryCopyName, err)
+			}
+			c.pc += num
+			memory64 := c.isMemory64(dst) || c.isMemory64(src)
+			if dst == src {
+				c.selectMemory(dst)
+				c.emit(
+					&OperationMemoryCopy{Memory64: memory64},
+				)
+			} else {
+				c.emit(
+					&OperationMemoryCopy{DestinationMemoryIndex: dst, SourceMemoryIndex: src, Memory64: memory64},
+				)
+			}
 		case wasm.OpcodeMiscMemoryFill:
-			c.result.UsesMemory = true
-			c.pc += 1 // +1 to skip the memory index which is fixed to zero.
+			if err := c.readMemoryIndex(wasm.OpcodeMemoryFillName); err != nil {
+				return err
+			}
 			c.emit(
-				&OperationMemoryFill{},
+				&OperationMemoryFill{Memory64: c.isMemory64(c.memoryIndex)},
 			)
 		case wasm.OpcodeMiscTableInit:
 			elemIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1835,6 +2345,15 @@ operatorSwitch:
 			return fmt.Errorf("unsupported misc instruction in wazeroir: 0x%x", op)
 		}
 	case wasm.OpcodeVecPrefix:
+		if relaxedOp, ok := wasm.RelaxedVecOpcode(c.body[c.pc+1:]); ok {
+			c.pc += 2 // The opcode is encoded with two bytes.
+			op, err := relaxedVecOperation(relaxedOp)
+			if err != nil {
+				return err
+			}
+			c.emit(op)
+			break
+		}
 		c.pc++
 		switch vecOp := c.body[c.pc]; vecOp {
 		case wasm.OpcodeVecV128Const:
@@ -2923,15 +3442,130 @@ operatorSwitch:
 		default:
 			return fmt.Errorf("unsupported vector instruction in wazeroir: %s", wasm.VectorInstructionName(vecOp))
 		}
+	case wasm.OpcodeAtomicPrefix:
+		c.pc++
+		// An atomic opcode is encoded as an unsigned variable 32-bit integer, but all of them fit in a byte.
+		atomicOp := c.body[c.pc]
+		name := wasm.AtomicInstructionName(atomicOp)
+		switch atomicOp {
+		case wasm.OpcodeAtomicFence:
+			c.pc++ // Skip the reserved zero byte.
+			c.emit(
+				&OperationAtomicFence{},
+			)
+			break operatorSwitch
+		case wasm.OpcodeAtomicMemoryNotify:
+			imm, err := c.readMemoryArg(name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				&OperationAtomicMemoryNotify{Arg: imm},
+			)
+			break operatorSwitch
+		case wasm.OpcodeAtomicMemoryWait32, wasm.OpcodeAtomicMemoryWait64:
+			imm, err := c.readMemoryArg(name)
+			if err != nil {
+				return err
+			}
+			t := UnsignedInt32
+			if atomicOp == wasm.OpcodeAtomicMemoryWait64 {
+				t = UnsignedInt64
+			}
+			c.emit(
+				&OperationAtomicMemoryWait{Type: t, Arg: imm},
+			)
+			break operatorSwitch
+		}
+
+		attr, ok := atomicInstructions[atomicOp]
+		if !ok {
+			return fmt.Errorf("unsupported atomic instruction in wazeroir: 0x%x", atomicOp)
+		}
+		imm, err := c.readMemoryArg(name)
+		if err != nil {
+			return err
+		}
+		switch attr.kind {
+		case OperationKindAtomicLoad:
+			c.emit(
+				&OperationAtomicLoad{Type: attr.typ, Size: attr.size, Arg: imm},
+			)
+		case OperationKindAtomicStore:
+			c.emit(
+				&OperationAtomicStore{Type: attr.typ, Size: attr.size, Arg: imm},
+			)
+		case OperationKindAtomicRMW:
+			c.emit(
+				&OperationAtomicRMW{Type: attr.typ, Size: attr.size, Arg: imm, Op: attr.op},
+			)
+		case OperationKindAtomicRMWCmpxchg:
+			c.emit(
+				&OperationAtomicRMWCmpxchg{Type: attr.typ, Size: attr.size, Arg: imm},
+			)
+		}
 	default:
 		return fmt.Errorf("unsupported instruction in wazeroir: 0x%x", op)
 	}
 
+	// Switch back to the memory of index zero if the instruction has selected another one.
+	if c.memoryIndex != 0 {
+		c.memoryIndex = 0
+		c.emit(
+			&OperationSelectMemory{},
+		)
+	}
+
 	// Move the program counter to point to the next instruction.
 	c.pc++
 	return nil
 }
 
+// relaxedVecOperation returns the Operation of the relaxed vector instruction. The relaxed instructions are the same
+// as their non-relaxed counterparts except for madd, nmadd and the dot products, so engines don't have to implement
+// them unless they lower some of them to native instructions. See api.CoreFeatureRelaxedSIMD.
+func relaxedVecOperation(op wasm.OpcodeVecRelaxed) (Operation, error) {
+	switch op {
+	case wasm.OpcodeVecI8x16RelaxedSwizzle:
+		return &OperationV128Swizzle{Relaxed: true}, nil
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S:
+		return &OperationV128ITruncSatFromF{OriginShape: ShapeF32x4, Signed: true}, nil
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4U:
+		return &OperationV128ITruncSatFromF{OriginShape: ShapeF32x4, Signed: false}, nil
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero:
+		return &OperationV128ITruncSatFromF{OriginShape: ShapeF64x2, Signed: true}, nil
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		return &OperationV128ITruncSatFromF{OriginShape: ShapeF64x2, Signed: false}, nil
+	case wasm.OpcodeVecF32x4RelaxedMadd:
+		return &OperationV128RelaxedMadd{Shape: ShapeF32x4}, nil
+	case wasm.OpcodeVecF32x4RelaxedNmadd:
+		return &OperationV128RelaxedMadd{Shape: ShapeF32x4, Negate: true}, nil
+	case wasm.OpcodeVecF64x2RelaxedMadd:
+		return &OperationV128RelaxedMadd{Shape: ShapeF64x2}, nil
+	case wasm.OpcodeVecF64x2RelaxedNmadd:
+		return &OperationV128RelaxedMadd{Shape: ShapeF64x2, Negate: true}, nil
+	case wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect:
+		return &OperationV128Bitselect{}, nil
+	case wasm.OpcodeVecF32x4RelaxedMin:
+		return &OperationV128Min{Shape: ShapeF32x4}, nil
+	case wasm.OpcodeVecF32x4RelaxedMax:
+		return &OperationV128Max{Shape: ShapeF32x4}, nil
+	case wasm.OpcodeVecF64x2RelaxedMin:
+		return &OperationV128Min{Shape: ShapeF64x2}, nil
+	case wasm.OpcodeVecF64x2RelaxedMax:
+		return &OperationV128Max{Shape: ShapeF64x2}, nil
+	case wasm.OpcodeVecI16x8RelaxedQ15mulrS:
+		return &OperationV128Q15mulrSatS{}, nil
+	case wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		return &OperationV128RelaxedDot{}, nil
+	case wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		return &OperationV128RelaxedDot{Add: true}, nil
+	default:
+		return nil, fmt.Errorf("unsupported relaxed vector instruction in wazeroir: %s", wasm.RelaxedVectorInstructionName(op))
+	}
+}
+
 func (c *compiler) nextID() (id uint32) {
 	id = c.currentID + 1
 	c.currentID++
@@ -2945,6 +3579,11 @@ func (c *compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 		// and it DOES affect the signature of opcode.
 		wasm.OpcodeCall,
 		wasm.OpcodeCallIndirect,
+		wasm.OpcodeReturnCall,
+		wasm.OpcodeReturnCallIndirect,
+		wasm.OpcodeCallRef,
+		wasm.OpcodeReturnCallRef,
+		wasm.OpcodeThrow,
 		wasm.OpcodeLocalGet,
 		wasm.OpcodeLocalSet,
 		wasm.OpcodeLocalTee,
@@ -2973,6 +3612,9 @@ func (c *compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 	if err != nil {
 		return 0, err
 	}
+	if c.hasMemory64 {
+		s = c.memory64Signature(opcode, s)
+	}
 
 	// Manipulate the stack according to the signature.
 	// Note that the following algorithm assumes that
@@ -3040,26 +3682,41 @@ func (c *compiler) emit(ops ...Operation) {
 					continue
 				}
 			}
-			c.result.Operations = append(c.result.Operations, op)
-			if c.needSourceOffset {
-				c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
-					c.currentOpPC+c.bodyOffsetInCodeSection)
-			}
-			if false {
-				fmt.Printf("emitting ")
-				formatOperation(os.Stdout, op)
+			c.appendOperation(op)
+			if _, ok := op.(*OperationLabel); ok {
+				// Each label starts a new basic block, which consumes the fuel for its instructions, and counts the
+				// times it is executed.
+				if c.fuelMetering {
+					c.fuel = &OperationConsumeFuel{}
+					c.appendOperation(c.fuel)
+				}
+				if c.coverage {
+					c.emitCoverBlock()
+				}
 			}
 		}
 	}
 }
 
+func (c *compiler) appendOperation(op Operation) {
+	c.result.Operations = append(c.result.Operations, op)
+	if c.needSourceOffset {
+		c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
+			c.currentOpPC+c.bodyOffsetInCodeSection)
+	}
+	if false {
+		fmt.Printf("emitting ")
+		formatOperation(os.Stdout, op)
+	}
+}
+
 // Emit const expression with default values of the given type.
 func (c *compiler) emitDefaultValue(t wasm.ValueType) {
 	switch t {
 	case wasm.ValueTypeI32:
 		c.stackPush(UnsignedTypeI32)
 		c.emit(&OperationConstI32{Value: 0})
-	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		c.stackPush(UnsignedTypeI64)
 		c.emit(&OperationConstI64{Value: 0})
 	case wasm.ValueTypeF32:
@@ -3122,6 +3779,75 @@ func (c *compiler) getFrameDropRange(frame *controlFrame, isEnd bool) *Inclusive
 	return nil
 }
 
+// getTailCallDropRange returns the range of values in the function frame to drop before a tail call, so that only
+// the top `keep` values (in uint64 representation) remain.
+func (c *compiler) getTailCallDropRange(keep int) *InclusiveRange {
+	if end := c.stackLenInUint64(len(c.stack)) - 1; keep <= end {
+		return &InclusiveRange{Start: keep, End: end}
+	}
+	return nil
+}
+
+// exceptionHandler returns the handler of the exceptions thrown in the control frame at the depth n.
+func (c *compiler) exceptionHandler(n int) *exceptionHandler {
+	for ; n < len(c.controlFrames.frames); n++ {
+		if frame := c.controlFrames.get(n); frame.kind == controlFrameKindTry && !frame.catching {
+			return &exceptionHandler{label: frame.dispatch, stackLen: frame.originalStackLenWithoutParam}
+		}
+	}
+	return c.exceptionExit
+}
+
+// exceptionHandlerDropRange returns the range (starting from top of the stack) to drop when branching into the
+// exception handler h, where only the first stackLen values on c.stack are live.
+func (c *compiler) exceptionHandlerDropRange(h *exceptionHandler, stackLen int) *InclusiveRange {
+	if end := c.stackLenInUint64(stackLen) - 1 - c.stackLenInUint64(h.stackLen); end >= 0 {
+		return &InclusiveRange{Start: 0, End: end}
+	}
+	return nil
+}
+
+// emitBranchToExceptionHandler emits the branch into the exception handler h, where only the first stackLen values
+// on c.stack are live.
+func (c *compiler) emitBranchToExceptionHandler(h *exceptionHandler, stackLen int) {
+	c.result.LabelCallers[h.label.String()]++
+	c.emit(
+		&OperationDrop{Depth: c.exceptionHandlerDropRange(h, stackLen)},
+		&OperationBr{Target: h.label.asBranchTarget()},
+	)
+}
+
+// emitExceptionCheck emits the branch into the exception handler taken when the preceding function call returns
+// with a pending exception. This is no-op unless api.CoreFeatureExceptionHandling is enabled.
+func (c *compiler) emitExceptionCheck() {
+	if c.exceptionExit == nil || c.unreachableState.on {
+		return
+	}
+	h := c.exceptionHandler(0)
+	c.result.LabelCallers[h.label.String()]++
+	continuationLabel := &Label{FrameID: c.nextID(), Kind: LabelKindHeader}
+	c.result.LabelCallers[continuationLabel.String()]++
+	c.emit(
+		&OperationExceptionPending{},
+		&OperationBrIf{
+			Then: &BranchTargetDrop{ToDrop: c.exceptionHandlerDropRange(h, len(c.stack)), Target: h.label.asBranchTarget()},
+			Else: continuationLabel.asBranchTargetDrop(),
+		},
+		&OperationLabel{Label: continuationLabel},
+	)
+}
+
+// emitUncaughtException emits the end of the catch clauses of the try frame which is already popped, where the
+// exception matching none of them is passed to the outer handler.
+func (c *compiler) emitUncaughtException(frame *controlFrame) {
+	if frame.dispatch == nil {
+		// Ended with catch_all.
+		return
+	}
+	c.emit(&OperationLabel{Label: frame.dispatch})
+	c.emitBranchToExceptionHandler(c.exceptionHandler(0), frame.originalStackLenWithoutParam)
+}
+
 func (c *compiler) stackLenInUint64(ceil int) (ret int) {
 	for i := 0; i < ceil; i++ {
 		if c.stack[i] == UnsignedTypeV128 {
@@ -3133,6 +3859,49 @@ func (c *compiler) stackLenInUint64(ceil int) (ret int) {
 	return
 }
 
+// atomicInstruction describes how a load, store or read-modify-write atomic instruction is lowered.
+type atomicInstruction struct {
+	kind OperationKind
+	typ  UnsignedInt
+	size uint32
+	op   wasm.AtomicArithmeticOp
+}
+
+// atomicInstructions are the atomic instructions except wait, notify and fence, keyed by their wasm.OpcodeAtomic.
+var atomicInstructions = func() map[wasm.OpcodeAtomic]atomicInstruction {
+	// Each group of instructions lays out the value types and sizes in the same order.
+	shapes := []struct {
+		typ  UnsignedInt
+		size uint32
+	}{
+		{UnsignedInt32, 4}, {UnsignedInt64, 8},
+		{UnsignedInt32, 1}, {UnsignedInt32, 2},
+		{UnsignedInt64, 1}, {UnsignedInt64, 2}, {UnsignedInt64, 4},
+	}
+	groups := []struct {
+		first wasm.OpcodeAtomic
+		kind  OperationKind
+		op    wasm.AtomicArithmeticOp
+	}{
+		{wasm.OpcodeAtomicI32Load, OperationKindAtomicLoad, 0},
+		{wasm.OpcodeAtomicI32Store, OperationKindAtomicStore, 0},
+		{wasm.OpcodeAtomicI32RMWAdd, OperationKindAtomicRMW, wasm.AtomicArithmeticOpAdd},
+		{wasm.OpcodeAtomicI32RMWSub, OperationKindAtomicRMW, wasm.AtomicArithmeticOpSub},
+		{wasm.OpcodeAtomicI32RMWAnd, OperationKindAtomicRMW, wasm.AtomicArithmeticOpAnd},
+		{wasm.OpcodeAtomicI32RMWOr, OperationKindAtomicRMW, wasm.AtomicArithmeticOpOr},
+		{wasm.OpcodeAtomicI32RMWXor, OperationKindAtomicRMW, wasm.AtomicArithmeticOpXor},
+		{wasm.OpcodeAtomicI32RMWXchg, OperationKindAtomicRMW, wasm.AtomicArithmeticOpXchg},
+		{wasm.OpcodeAtomicI32RMWCmpxchg, OperationKindAtomicRMWCmpxchg, 0},
+	}
+	ret := make(map[wasm.OpcodeAtomic]atomicInstruction, len(shapes)*len(groups))
+	for _, g := range groups {
+		for i, shape := range shapes {
+			ret[g.first+wasm.OpcodeAtomic(i)] = atomicInstruction{kind: g.kind, typ: shape.typ, size: shape.size, op: g.op}
+		}
+	}
+	return ret
+}()
+
 func (c *compiler) readMemoryArg(tag string) (*MemoryArg, error) {
 	c.result.UsesMemory = true
 	alignment, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -3140,10 +3909,118 @@ func (c *compiler) readMemoryArg(tag string) (*MemoryArg, error) {
 		return nil, fmt.Errorf("reading alignment for %s: %w", tag, err)
 	}
 	c.pc += num
-	offset, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if c.enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) && alignment&memArgMemoryIndexFlag != 0 {
+		alignment &^= memArgMemoryIndexFlag
+		memoryIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return nil, fmt.Errorf("reading memory index for %s: %w", tag, err)
+		}
+		c.pc += num
+		c.selectMemory(memoryIndex)
+	}
+	// The offset is u64 for a 64-bit memory.
+	memory64 := c.isMemory64(c.memoryIndex)
+	var offset uint64
+	if memory64 {
+		offset, num, err = leb128.LoadUint64(c.body[c.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("reading offset for %s: %w", tag, err)
 	}
 	c.pc += num
-	return &MemoryArg{Offset: offset, Alignment: alignment}, nil
+	return &MemoryArg{Offset: offset, Alignment: alignment, Memory64: memory64}, nil
+}
+
+// isMemory64 returns true if the memory of the given index is 64-bit (api.CoreFeatureMemory64).
+func (c *compiler) isMemory64(memoryIndex uint32) bool {
+	return c.hasMemory64 && int(memoryIndex) < len(c.memories) && c.memories[memoryIndex].Is64
+}
+
+// memArgMemoryIndex returns the memory index of the memarg at c.body[at:] without advancing c.pc.
+func (c *compiler) memArgMemoryIndex(at uint64) uint32 {
+	alignment, num, _ := leb128.LoadUint32(c.body[at:])
+	if c.enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) && alignment&memArgMemoryIndexFlag != 0 {
+		memoryIndex, _, _ := leb128.LoadUint32(c.body[at+num:])
+		return memoryIndex
+	}
+	return 0
+}
+
+// memArgMemoryIndexFlag is set in the alignment of memarg when it is followed by a memory index.
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+const memArgMemoryIndexFlag = 1 << 6
+
+// readMemoryIndex reads the memory index immediate of instructions such as memory.size, and selects the memory.
+func (c *compiler) readMemoryIndex(tag string) error {
+	c.result.UsesMemory = true
+	memoryIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if err != nil {
+		return fmt.Errorf("reading memory index for %s: %w", tag, err)
+	}
+	c.pc += num
+	c.selectMemory(memoryIndex)
+	return nil
+}
+
+// gcImmediates returns the opcode following wasm.OpcodeGCPrefix at c.pc, its immediates, and the position of the last
+// byte of the instruction. For ref.test and ref.cast, typeIndex is the heap type as a 32-bit signed integer. index is
+// the field index of struct.get and struct.set, or the number of operands of array.new_fixed.
+//
+// Note: This doesn't advance c.pc, so that this can be used before the stack is modified.
+func (c *compiler) gcImmediates() (op wasm.OpcodeGC, typeIndex, index uint32, end uint64, err error) {
+	pc := c.pc + 1
+	op32, num, err := leb128.LoadUint32(c.body[pc:])
+	if err != nil {
+		err = fmt.Errorf("reading gc opcode: %w", err)
+		return
+	}
+	pc += num
+	op = wasm.OpcodeGC(op32)
+
+	switch op {
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull, wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		var heapType int64
+		heapType, num, err = leb128.DecodeInt33AsInt64(bytes.NewReader(c.body[pc:]))
+		if err != nil {
+			err = fmt.Errorf("reading heap type for %s: %w", wasm.GCInstructionName(op), err)
+			return
+		}
+		pc += num
+		typeIndex = uint32(int32(heapType))
+	case wasm.OpcodeGCArrayLen:
+	default:
+		typeIndex, num, err = leb128.LoadUint32(c.body[pc:])
+		if err != nil {
+			err = fmt.Errorf("reading type index for %s: %w", wasm.GCInstructionName(op), err)
+			return
+		}
+		pc += num
+		switch op {
+		case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU, wasm.OpcodeGCStructSet,
+			wasm.OpcodeGCArrayNewFixed:
+			index, num, err = leb128.LoadUint32(c.body[pc:])
+			if err != nil {
+				err = fmt.Errorf("reading immediate for %s: %w", wasm.GCInstructionName(op), err)
+				return
+			}
+			pc += num
+		}
+	}
+	end = pc - 1
+	return
+}
+
+// selectMemory emits OperationSelectMemory if the memory index is not zero. The memory of index zero is selected
+// again at the end of handleInstruction.
+func (c *compiler) selectMemory(memoryIndex uint32) {
+	if memoryIndex != 0 {
+		c.memoryIndex = memoryIndex
+		c.emit(
+			&OperationSelectMemory{MemoryIndex: memoryIndex},
+		)
+	}
 }
@@ -225,7 +225,7 @@ func TestCompile(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			res, err := CompileFunctions(enabledFeatures, 0, tc.module, false)
+			res, err := CompileFunctions(enabledFeatures, 0, tc.module, false, false, false)
 			require.NoError(t, err)
 
 			fn := res[0]
@@ -322,7 +322,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 	module := &wasm.Module{
 		TypeSection:     []*wasm.FunctionType{v_v},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1},
+		MemorySection:   []*wasm.Memory{{Min: 1}},
 		DataSection: []*wasm.DataSegment{
 			{
 				OffsetExpression: &wasm.ConstantExpression{
@@ -352,7 +352,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 			&OperationConstI32{16},                // [16]
 			&OperationConstI32{0},                 // [16, 0]
 			&OperationConstI32{7},                 // [16, 0, 7]
-			&OperationMemoryInit{1},               // []
+			&OperationMemoryInit{DataIndex: 1},    // []
 			&OperationDataDrop{1},                 // []
 			&OperationBr{Target: &BranchTarget{}}, // return!
 		},
@@ -366,7 +366,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 		TableTypes:       []wasm.RefType{},
 	}
 
-	res, err := CompileFunctions(api.CoreFeatureBulkMemoryOperations, 0, module, false)
+	res, err := CompileFunctions(api.CoreFeatureBulkMemoryOperations, 0, module, false, false, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -662,7 +662,7 @@ func TestCompile_MultiValue(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			res, err := CompileFunctions(enabledFeatures, 0, tc.module, false)
+			res, err := CompileFunctions(enabledFeatures, 0, tc.module, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0])
 		})
@@ -700,7 +700,7 @@ func TestCompile_NonTrappingFloatToIntConversion(t *testing.T) {
 	for _, tp := range module.TypeSection {
 		tp.CacheNumInUint64()
 	}
-	res, err := CompileFunctions(api.CoreFeatureNonTrappingFloatToIntConversion, 0, module, false)
+	res, err := CompileFunctions(api.CoreFeatureNonTrappingFloatToIntConversion, 0, module, false, false, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -731,7 +731,7 @@ func TestCompile_SignExtensionOps(t *testing.T) {
 	for _, tp := range module.TypeSection {
 		tp.CacheNumInUint64()
 	}
-	res, err := CompileFunctions(api.CoreFeatureSignExtensionOps, 0, module, false)
+	res, err := CompileFunctions(api.CoreFeatureSignExtensionOps, 0, module, false, false, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -740,7 +740,7 @@ func requireCompilationResult(t *testing.T, enabledFeatures api.CoreFeatures, ex
 	if enabledFeatures == 0 {
 		enabledFeatures = api.CoreFeaturesV2
 	}
-	res, err := CompileFunctions(enabledFeatures, 0, module, false)
+	res, err := CompileFunctions(enabledFeatures, 0, module, false, false, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -782,7 +782,7 @@ func TestCompile_CallIndirectNonZeroTableIndex(t *testing.T) {
 		Types: []*wasm.FunctionType{v_v, v_v, v_v},
 	}
 
-	res, err := CompileFunctions(api.CoreFeatureBulkMemoryOperations, 0, module, false)
+	res, err := CompileFunctions(api.CoreFeatureBulkMemoryOperations, 0, module, false, false, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -872,7 +872,7 @@ func TestCompile_Refs(t *testing.T) {
 				FunctionSection: []wasm.Index{0},
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 			}
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -941,7 +941,7 @@ func TestCompile_TableGetOrSet(t *testing.T) {
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 				TableSection:    []*wasm.Table{{}},
 			}
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -1010,7 +1010,7 @@ func TestCompile_TableGrowFillSize(t *testing.T) {
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 				TableSection:    []*wasm.Table{{}},
 			}
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 			require.True(t, res[0].HasTable)
@@ -1018,6 +1018,752 @@ func TestCompile_TableGrowFillSize(t *testing.T) {
 	}
 }
 
+func TestCompile_Atomics(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "i64.atomic.load32_u",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI64Load32U, 2, 4,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationAtomicLoad{Type: UnsignedInt64, Size: 4, Arg: &MemoryArg{Alignment: 2, Offset: 4}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "i32.atomic.store16",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32Store16, 1, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationConstI32{Value: 1},
+				&OperationAtomicStore{Type: UnsignedInt32, Size: 2, Arg: &MemoryArg{Alignment: 1}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "i64.atomic.rmw8.xor_u",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI64RMW8XorU, 0, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationConstI64{Value: 1},
+				&OperationAtomicRMW{Type: UnsignedInt64, Size: 1, Arg: &MemoryArg{}, Op: wasm.AtomicArithmeticOpXor},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "i32.atomic.rmw.cmpxchg",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RMWCmpxchg, 2, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationConstI32{Value: 1},
+				&OperationConstI32{Value: 2},
+				&OperationAtomicRMWCmpxchg{Type: UnsignedInt32, Size: 4, Arg: &MemoryArg{Alignment: 2}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.atomic.wait64",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryWait64, 3, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationConstI64{Value: 1},
+				&OperationConstI64{Value: 2},
+				&OperationAtomicMemoryWait{Type: UnsignedInt64, Arg: &MemoryArg{Alignment: 3}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.atomic.notify",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryNotify, 2, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationConstI32{Value: 1},
+				&OperationAtomicMemoryNotify{Arg: &MemoryArg{Alignment: 2}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "atomic.fence",
+			body: []byte{
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicFence, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationAtomicFence{},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{v_v},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+				MemorySection:   []*wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureThreads, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_TailCall(t *testing.T) {
+	tests := []struct {
+		name                       string
+		body                       []byte
+		callFrameStackSizeInUint64 int
+		expected                   []Operation
+	}{
+		{
+			name: "return_call",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI32{},     // [$x, $l]
+				&OperationPick{Depth: 1}, // [$x, $l, $x]
+				&OperationTailCall{FunctionIndex: 0, Drop: &InclusiveRange{Start: 1, End: 2}},
+			},
+		},
+		{
+			name: "return_call with call frame",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			},
+			callFrameStackSizeInUint64: 3,
+			expected: []Operation{ // begin with params: [$x, callframe(3)]
+				&OperationConstI32{},     // [$x, callframe(3), $l]
+				&OperationPick{Depth: 4}, // [$x, callframe(3), $l, $x]
+				&OperationTailCall{FunctionIndex: 0, Drop: &InclusiveRange{Start: 1, End: 5}},
+			},
+		},
+		{
+			name: "return_call_indirect",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeReturnCallIndirect, 0, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI32{},         // [$x, $l]
+				&OperationPick{Depth: 1},     // [$x, $l, $x]
+				&OperationConstI32{Value: 1}, // [$x, $l, $x, 1]
+				&OperationTailCallIndirect{TypeIndex: 0, TableIndex: 0, Drop: &InclusiveRange{Start: 2, End: 3}},
+			},
+		},
+		{
+			name: "unreachable after return_call",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI32{},     // [$x, $l]
+				&OperationPick{Depth: 1}, // [$x, $l, $x]
+				&OperationTailCall{FunctionIndex: 0, Drop: &InclusiveRange{Start: 1, End: 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{i32_i32},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body, LocalTypes: []wasm.ValueType{i32}}},
+				TableSection:    []*wasm.Table{{Type: wasm.RefTypeFuncref}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureTailCall, tc.callFrameStackSizeInUint64, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_FunctionReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "call_ref",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeRefFunc, 0,
+				wasm.OpcodeCallRef, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationPick{Depth: 0},            // [$x, $x]
+				&OperationRefFunc{FunctionIndex: 0}, // [$x, $x, $f]
+				&OperationCallRef{TypeIndex: 0},     // [$x, $result]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+		{
+			name: "return_call_ref",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeRefFunc, 0,
+				wasm.OpcodeReturnCallRef, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationPick{Depth: 0},            // [$x, $x]
+				&OperationRefFunc{FunctionIndex: 0}, // [$x, $x, $f]
+				&OperationTailCallRef{TypeIndex: 0, Drop: &InclusiveRange{Start: 2, End: 2}},
+			},
+		},
+		{
+			name: "ref.as_non_null",
+			body: []byte{
+				wasm.OpcodeRefNull, 0,
+				wasm.OpcodeRefAsNonNull,
+				wasm.OpcodeDrop,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI64{},     // [$x, null]
+				&OperationRefAsNonNull{}, // [$x, null]
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$x]
+				&OperationPick{Depth: 0},                                 // [$x, $x]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+		{
+			name: "br_on_null",
+			body: []byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeRefNull, 0,
+				wasm.OpcodeBrOnNull, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI64{},               // [$x, null]
+				&OperationPick{Depth: 0},           // [$x, null, null]
+				&OperationEqz{Type: UnsignedInt64}, // [$x, null, 1]
+				&OperationBrIf{ // [$x, null]
+					Then: &BranchTargetDrop{Target: &BranchTarget{Label: &Label{FrameID: 3, Kind: LabelKindHeader}}},
+					Else: &BranchTargetDrop{Target: &BranchTarget{Label: &Label{FrameID: 4, Kind: LabelKindHeader}}},
+				},
+				&OperationLabel{Label: &Label{FrameID: 3, Kind: LabelKindHeader}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$x]
+				&OperationBr{Target: &BranchTarget{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}}},
+				&OperationLabel{Label: &Label{FrameID: 4, Kind: LabelKindHeader}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$x]
+				&OperationBr{Target: &BranchTarget{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}}},
+				&OperationLabel{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}},
+				&OperationPick{Depth: 0}, // [$x, $x]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+		{
+			name: "br_on_non_null",
+			body: []byte{
+				wasm.OpcodeBlock, wasm.RefTypePrefixNullable, 0,
+				wasm.OpcodeRefNull, 0,
+				wasm.OpcodeBrOnNonNull, 0,
+				wasm.OpcodeRefNull, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeDrop,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI64{},               // [$x, null]
+				&OperationPick{Depth: 0},           // [$x, null, null]
+				&OperationEqz{Type: UnsignedInt64}, // [$x, null, 1]
+				&OperationBrIf{ // [$x, null]
+					Then: &BranchTargetDrop{Target: &BranchTarget{Label: &Label{FrameID: 4, Kind: LabelKindHeader}}},
+					Else: &BranchTargetDrop{Target: &BranchTarget{Label: &Label{FrameID: 3, Kind: LabelKindHeader}}},
+				},
+				&OperationLabel{Label: &Label{FrameID: 3, Kind: LabelKindHeader}},
+				&OperationBr{Target: &BranchTarget{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}}},
+				&OperationLabel{Label: &Label{FrameID: 4, Kind: LabelKindHeader}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$x]
+				&OperationConstI64{}, // [$x, null]
+				&OperationBr{Target: &BranchTarget{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}}},
+				&OperationLabel{Label: &Label{FrameID: 2, Kind: LabelKindContinuation}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$x]
+				&OperationPick{Depth: 0}, // [$x, $x]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{i32_i32},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureFunctionReferences|api.CoreFeatureTailCall, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_GC(t *testing.T) {
+	// $point: (struct (field (mut i32)) (field i8)), $f64s: (array (mut f64))
+	point := &wasm.FunctionType{Composite: &wasm.CompositeType{Final: true, Fields: []*wasm.FieldType{
+		{Type: wasm.ValueTypeI32, Mutable: true},
+		{Type: wasm.PackedTypeI8},
+	}}}
+	f64s := &wasm.FunctionType{Composite: &wasm.CompositeType{Final: true, IsArray: true, Fields: []*wasm.FieldType{
+		{Type: wasm.ValueTypeF64, Mutable: true},
+	}}}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "struct",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCStructNew, 1,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCStructGetU, 1, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationPick{Depth: 0}, // [$x, $x]
+				&OperationPick{Depth: 1}, // [$x, $x, $x]
+				&OperationGC{Opcode: wasm.OpcodeGCStructNew, TypeIndex: 1, Operands: 2, HasResult: true, Result: UnsignedTypeI64},            // [$x, $p]
+				&OperationGC{Opcode: wasm.OpcodeGCStructGetU, TypeIndex: 1, Index: 1, Operands: 1, HasResult: true, Result: UnsignedTypeI32}, // [$x, $y]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+		{
+			name: "array",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCArrayNewDefault, 2,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeF64Const, 0, 0, 0, 0, 0, 0, 0, 0,
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCArraySet, 2,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationPick{Depth: 0}, // [$x, $x]
+				&OperationGC{Opcode: wasm.OpcodeGCArrayNewDefault, TypeIndex: 2, Operands: 1, HasResult: true, Result: UnsignedTypeI64}, // [$x, $a]
+				&OperationPick{Depth: 1},     // [$x, $a, $x]
+				&OperationConstF64{Value: 0}, // [$x, $a, $x, 0]
+				&OperationGC{Opcode: wasm.OpcodeGCArraySet, TypeIndex: 2, Operands: 3}, // [$x]
+				&OperationPick{Depth: 0}, // [$x, $x]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+		{
+			name: "ref.test and ref.eq",
+			body: []byte{
+				wasm.OpcodeRefNull, 0x6d, // eq
+				wasm.OpcodeGCPrefix, wasm.OpcodeGCRefCastNull, 0x6b, // struct
+				wasm.OpcodeRefNull, 0x6d, // eq
+				wasm.OpcodeRefEq,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{ // begin with params: [$x]
+				&OperationConstI64{}, // [$x, null]
+				&OperationGC{Opcode: wasm.OpcodeGCRefCastNull, TypeIndex: 0xffffffeb, Operands: 1, HasResult: true, Result: UnsignedTypeI64}, // [$x, null]
+				&OperationConstI64{},                // [$x, null, null]
+				&OperationEq{Type: UnsignedTypeI64}, // [$x, 1]
+				&OperationDrop{Depth: &InclusiveRange{Start: 1, End: 1}},
+				&OperationBr{Target: &BranchTarget{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{i32_i32, point, f64s},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureFunctionReferences|api.CoreFeatureGC, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_MultiMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "i32.load on memory 0",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI32Load, 0x2 | 0x40, 0, 4,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationLoad{Type: UnsignedTypeI32, Arg: &MemoryArg{Alignment: 2, Offset: 4}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "i32.load on memory 1",
+			body: []byte{
+				wasm.OpcodeI32Const, 8,
+				wasm.OpcodeI32Load, 0x2 | 0x40, 1, 4,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 8},
+				&OperationSelectMemory{MemoryIndex: 1},
+				&OperationLoad{Type: UnsignedTypeI32, Arg: &MemoryArg{Alignment: 2, Offset: 4}},
+				&OperationSelectMemory{},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.grow on memory 1",
+			body: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeMemoryGrow, 1,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 1},
+				&OperationSelectMemory{MemoryIndex: 1},
+				&OperationMemoryGrow{},
+				&OperationSelectMemory{},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.copy within memory 1",
+			body: []byte{
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 1, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{},
+				&OperationConstI32{},
+				&OperationConstI32{},
+				&OperationSelectMemory{MemoryIndex: 1},
+				&OperationMemoryCopy{},
+				&OperationSelectMemory{},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.copy from memory 1 to 0",
+			body: []byte{
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{},
+				&OperationConstI32{},
+				&OperationConstI32{},
+				&OperationMemoryCopy{DestinationMemoryIndex: 0, SourceMemoryIndex: 1},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{v_v},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+				MemorySection:   []*wasm.Memory{{Min: 1}, {Min: 1}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureMultiMemory, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_Memory64(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "i32.load with 64-bit offset",
+			body: []byte{
+				wasm.OpcodeI64Const, 8,
+				wasm.OpcodeI32Load, 0x2, 0x80, 0x80, 0x80, 0x80, 0x10,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI64{Value: 8},
+				&OperationLoad{Type: UnsignedTypeI32, Arg: &MemoryArg{Alignment: 2, Offset: 1 << 32, Memory64: true}},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.size and memory.grow",
+			body: []byte{
+				wasm.OpcodeMemorySize, 0,
+				wasm.OpcodeMemoryGrow, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationMemorySize{Memory64: true},
+				&OperationMemoryGrow{Memory64: true},
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "memory.copy from 32-bit memory",
+			body: []byte{
+				wasm.OpcodeI64Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI64{},
+				&OperationConstI32{},
+				&OperationConstI32{},
+				&OperationMemoryCopy{DestinationMemoryIndex: 0, SourceMemoryIndex: 1, Memory64: true},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{v_v},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+				MemorySection:   []*wasm.Memory{{Min: 1, Is64: true}, {Min: 1}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureMultiMemory|api.CoreFeatureMemory64, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
+func TestCompile_ExceptionHandling(t *testing.T) {
+	// The label of the function-level exception handler is allocated first, followed by the function frame.
+	exit := &Label{FrameID: 1, Kind: LabelKindHeader}
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []Operation
+	}{
+		{
+			name: "throw uncaught",
+			body: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeThrow, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 1},
+				&OperationThrow{TagIndex: 0},
+				&OperationBr{Target: exit.asBranchTarget()},
+				&OperationLabel{Label: exit},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "call",
+			body: []byte{
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationCall{FunctionIndex: 0},
+				&OperationExceptionPending{},
+				&OperationBrIf{
+					Then: exit.asBranchTargetDrop(),
+					Else: (&Label{FrameID: 3, Kind: LabelKindHeader}).asBranchTargetDrop(),
+				},
+				&OperationLabel{Label: &Label{FrameID: 3, Kind: LabelKindHeader}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+				&OperationLabel{Label: exit},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "try catch",
+			body: []byte{
+				wasm.OpcodeTry, 0x40,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeThrow, 0,
+				wasm.OpcodeCatch, 0,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 1},
+				&OperationThrow{TagIndex: 0},
+				&OperationBr{Target: (&Label{FrameID: 4, Kind: LabelKindHeader}).asBranchTarget()},
+				// Dispatch the exception thrown in the try block.
+				&OperationLabel{Label: &Label{FrameID: 4, Kind: LabelKindHeader}},
+				&OperationExceptionMatch{TagIndex: 0},
+				&OperationBrIf{
+					Then: (&Label{FrameID: 5, Kind: LabelKindHeader}).asBranchTargetDrop(),
+					Else: (&Label{FrameID: 6, Kind: LabelKindHeader}).asBranchTargetDrop(),
+				},
+				&OperationLabel{Label: &Label{FrameID: 5, Kind: LabelKindHeader}},
+				&OperationCatch{TagIndex: 0},                             // [$exception, $payload]
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // [$exception]
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}}, // []
+				&OperationBr{Target: (&Label{FrameID: 3, Kind: LabelKindContinuation}).asBranchTarget()},
+				// Pass the exception unmatched to the caller.
+				&OperationLabel{Label: &Label{FrameID: 6, Kind: LabelKindHeader}},
+				&OperationBr{Target: exit.asBranchTarget()},
+				&OperationLabel{Label: &Label{FrameID: 3, Kind: LabelKindContinuation}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+				&OperationLabel{Label: exit},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+		{
+			name: "catch_all and rethrow",
+			body: []byte{
+				wasm.OpcodeTry, 0x40,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeThrow, 0,
+				wasm.OpcodeCatchAll,
+				wasm.OpcodeRethrow, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			},
+			expected: []Operation{
+				&OperationConstI32{Value: 1},
+				&OperationThrow{TagIndex: 0},
+				&OperationBr{Target: (&Label{FrameID: 4, Kind: LabelKindHeader}).asBranchTarget()},
+				&OperationLabel{Label: &Label{FrameID: 4, Kind: LabelKindHeader}},
+				&OperationCatch{All: true, Rethrown: true}, // [$exception]
+				&OperationPick{Depth: 0},                   // [$exception, $exception]
+				&OperationRethrow{},                        // [$exception]
+				&OperationDrop{Depth: &InclusiveRange{Start: 0, End: 0}},
+				&OperationBr{Target: exit.asBranchTarget()},
+				&OperationLabel{Label: &Label{FrameID: 3, Kind: LabelKindContinuation}},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+				&OperationLabel{Label: exit},
+				&OperationBr{Target: &BranchTarget{}}, // return!
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{v_v, {Params: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				TagSection:      []wasm.Index{1},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureExceptionHandling, 0, module, false, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res[0].Operations)
+		})
+	}
+}
+
 func TestCompile_Locals(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1218,7 +1964,7 @@ func TestCompile_Locals(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false, false, false)
 			require.NoError(t, err)
 			msg := fmt.Sprintf("\nhave:\n\t%s\nwant:\n\t%s", Format(res[0].Operations), Format(tc.expected))
 			require.Equal(t, tc.expected, res[0].Operations, msg)
@@ -2791,10 +3537,10 @@ func TestCompile_Vec(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []*wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []*wasm.Memory{{}},
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 			}
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, module, false, false, false)
 			require.NoError(t, err)
 
 			var actual Operation
@@ -2813,6 +3559,85 @@ func TestCompile_Vec(t *testing.T) {
 	}
 }
 
+func TestCompile_RelaxedSIMD(t *testing.T) {
+	relaxed := func(operands int, op wasm.OpcodeVecRelaxed) (ret []byte) {
+		for i := 0; i < operands; i++ {
+			ret = append(ret, wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+				1, 1, 1, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 1, 1, 1)
+		}
+		return append(ret, wasm.OpcodeVecPrefix, 0x80|op, 0x02, wasm.OpcodeDrop, wasm.OpcodeEnd)
+	}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		expected Operation
+	}{
+		{
+			name:     wasm.OpcodeVecI8x16RelaxedSwizzleName,
+			body:     relaxed(2, wasm.OpcodeVecI8x16RelaxedSwizzle),
+			expected: &OperationV128Swizzle{Relaxed: true},
+		},
+		{
+			name:     wasm.OpcodeVecI32x4RelaxedTruncF64x2UZeroName,
+			body:     relaxed(1, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero),
+			expected: &OperationV128ITruncSatFromF{OriginShape: ShapeF64x2, Signed: false},
+		},
+		{
+			name:     wasm.OpcodeVecF32x4RelaxedMaddName,
+			body:     relaxed(3, wasm.OpcodeVecF32x4RelaxedMadd),
+			expected: &OperationV128RelaxedMadd{Shape: ShapeF32x4},
+		},
+		{
+			name:     wasm.OpcodeVecF64x2RelaxedNmaddName,
+			body:     relaxed(3, wasm.OpcodeVecF64x2RelaxedNmadd),
+			expected: &OperationV128RelaxedMadd{Shape: ShapeF64x2, Negate: true},
+		},
+		{
+			name:     wasm.OpcodeVecI64x2RelaxedLaneselectName,
+			body:     relaxed(3, wasm.OpcodeVecI64x2RelaxedLaneselect),
+			expected: &OperationV128Bitselect{},
+		},
+		{
+			name:     wasm.OpcodeVecF32x4RelaxedMaxName,
+			body:     relaxed(2, wasm.OpcodeVecF32x4RelaxedMax),
+			expected: &OperationV128Max{Shape: ShapeF32x4},
+		},
+		{
+			name:     wasm.OpcodeVecI16x8RelaxedQ15mulrSName,
+			body:     relaxed(2, wasm.OpcodeVecI16x8RelaxedQ15mulrS),
+			expected: &OperationV128Q15mulrSatS{},
+		},
+		{
+			name:     wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName,
+			body:     relaxed(2, wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S),
+			expected: &OperationV128RelaxedDot{},
+		},
+		{
+			name:     wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName,
+			body:     relaxed(3, wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS),
+			expected: &OperationV128RelaxedDot{Add: true},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			module := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{v_v},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: tc.body}},
+			}
+			res, err := CompileFunctions(api.CoreFeaturesV2|api.CoreFeatureRelaxedSIMD, 0, module, false, false, false)
+			require.NoError(t, err)
+
+			// The operations look like: [... target, drop, br(to return)].
+			require.Equal(t, tc.expected, res[0].Operations[len(res[0].Operations)-3])
+		})
+	}
+}
+
 // TestCompile_unreachable_Br_BrIf_BrTable ensures that unreachable br/br_if/br_table instructions are correctly ignored.
 func TestCompile_unreachable_Br_BrIf_BrTable(t *testing.T) {
 	tests := []struct {
@@ -2871,7 +3696,7 @@ func TestCompile_unreachable_Br_BrIf_BrTable(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -2911,7 +3736,7 @@ func TestCompile_drop_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -2981,7 +3806,7 @@ func TestCompile_select_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, tc.mod, false, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -3218,9 +4043,101 @@ func Test_ensureTermination(t *testing.T) {
 					},
 				}},
 			}
-			res, err := CompileFunctions(api.CoreFeaturesV2, 0, mod, tc.ensureTermination)
+			res, err := CompileFunctions(api.CoreFeaturesV2, 0, mod, tc.ensureTermination, false, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.exp, Format(res[0].Operations))
 		})
 	}
 }
+
+func Test_fuelMetering(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeLoop, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeIf, 0x40, wasm.OpcodeEnd,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+		}},
+	}
+	res, err := CompileFunctions(api.CoreFeaturesV2, 0, mod, false, true, false)
+	require.NoError(t, err)
+	require.True(t, res[0].FuelMetering)
+	// Each basic block consumes the fuel for its instructions, except the implicit else block which has none.
+	require.Equal(t, `.entrypoint
+	consume_fuel 2
+	i32.const 0
+	br .L2
+.L2:
+	consume_fuel 2
+	i32.const 1
+	br_if .L2, .L3
+.L3:
+	consume_fuel 3
+	i32.const 1
+	br_if .L4, .L4_else
+.L4:
+	consume_fuel 1
+	br .L4_cont
+.L4_else:
+	br .L4_cont
+.L4_cont:
+	consume_fuel 2
+	drop 0..0
+	br .return
+`, Format(res[0].Operations))
+}
+
+func Test_coverage(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeLoop, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeIf, 0x40, wasm.OpcodeEnd,
+				wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			BodyOffsetInCodeSection: 10,
+		}},
+	}
+	res, err := CompileFunctions(api.CoreFeaturesV2, 0, mod, false, false, true)
+	require.NoError(t, err)
+	// Each basic block counts the times it is executed, except the implicit else block which has no instruction.
+	require.Equal(t, `.entrypoint
+	cover_block 0
+	i32.const 0
+	br .L2
+.L2:
+	cover_block 1
+	i32.const 1
+	br_if .L2, .L3
+.L3:
+	cover_block 2
+	i32.const 1
+	br_if .L4, .L4_else
+.L4:
+	cover_block 3
+	br .L4_cont
+.L4_else:
+	br .L4_cont
+.L4_cont:
+	cover_block 4
+	drop 0..0
+	br .return
+`, Format(res[0].Operations))
+	// The instruction starting a block, such as loop or if, belongs to the previous one.
+	require.Equal(t, []wasm.CoverageBlock{
+		{Start: 10, End: 14},
+		{Start: 14, End: 18},
+		{Start: 18, End: 23},
+		{Start: 23, End: 24},
+		{Start: 24, End: 26},
+	}, res[0].CoverageBlocks)
+}
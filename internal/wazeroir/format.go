@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
 const EntrypointLabel = ".entrypoint"
@@ -43,6 +45,32 @@ func formatOperation(w io.StringWriter, b Operation) {
 		str = fmt.Sprintf("call %d", o.FunctionIndex)
 	case *OperationCallIndirect:
 		str = fmt.Sprintf("call_indirect: type=%d, table=%d", o.TypeIndex, o.TableIndex)
+	case *OperationTailCall:
+		str = fmt.Sprintf("tail_call %d", o.FunctionIndex)
+	case *OperationTailCallIndirect:
+		str = fmt.Sprintf("tail_call_indirect: type=%d, table=%d", o.TypeIndex, o.TableIndex)
+	case *OperationCallRef:
+		str = fmt.Sprintf("call_ref: type=%d", o.TypeIndex)
+	case *OperationTailCallRef:
+		str = fmt.Sprintf("tail_call_ref: type=%d", o.TypeIndex)
+	case *OperationRefAsNonNull:
+		str = "ref.as_non_null"
+	case *OperationGC:
+		str = fmt.Sprintf("%s: type=%d, index=%d", wasm.GCInstructionName(o.Opcode), o.TypeIndex, o.Index)
+	case *OperationThrow:
+		str = fmt.Sprintf("throw %d", o.TagIndex)
+	case *OperationRethrow:
+		str = "rethrow"
+	case *OperationExceptionPending:
+		str = "exception.pending"
+	case *OperationExceptionMatch:
+		str = fmt.Sprintf("exception.match %d", o.TagIndex)
+	case *OperationCatch:
+		if o.All {
+			str = "catch_all"
+		} else {
+			str = fmt.Sprintf("catch %d", o.TagIndex)
+		}
 	case *OperationDrop:
 		str = fmt.Sprintf("drop %d..%d", o.Depth.Start, o.Depth.End)
 	case *OperationSelect:
@@ -81,6 +109,8 @@ func formatOperation(w io.StringWriter, b Operation) {
 		str = "memory.size"
 	case *OperationMemoryGrow:
 		str = "memory.grow"
+	case *OperationSelectMemory:
+		str = fmt.Sprintf("select_memory %d", o.MemoryIndex)
 	case *OperationConstI32:
 		str = fmt.Sprintf("i32.const %d", o.Value)
 	case *OperationConstI64:
@@ -191,8 +221,24 @@ func formatOperation(w io.StringWriter, b Operation) {
 		} else {
 			str = fmt.Sprintf("v128.ITruncSatFrom%sU", shapeName(o.OriginShape))
 		}
+	case *OperationV128RelaxedMadd:
+		if o.Negate {
+			str = fmt.Sprintf("v128.relaxed_nmadd (shape=%s)", shapeName(o.Shape))
+		} else {
+			str = fmt.Sprintf("v128.relaxed_madd (shape=%s)", shapeName(o.Shape))
+		}
+	case *OperationV128RelaxedDot:
+		if o.Add {
+			str = "v128.relaxed_dot_add"
+		} else {
+			str = "v128.relaxed_dot"
+		}
 	case OperationBuiltinFunctionCheckExitCode:
 		str = "builtin_function.check_closed"
+	case *OperationConsumeFuel:
+		str = fmt.Sprintf("consume_fuel %d", o.Cost)
+	case *OperationCoverBlock:
+		str = fmt.Sprintf("cover_block %d", o.Index)
 	default:
 		panic("unreachable: a bug in wazeroir implementation")
 	}
@@ -1,6 +1,10 @@
 package wazeroir
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
 
 // UnsignedInt represents unsigned 32-bit or 64-bit integers.
 type UnsignedInt byte
@@ -418,6 +422,52 @@ func (o OperationKind) String() (ret string) {
 		ret = "V128ITruncSatFromF"
 	case OperationKindBuiltinFunctionCheckExitCode:
 		ret = "BuiltinFunctionCheckExitCode"
+	case OperationKindConsumeFuel:
+		ret = "ConsumeFuel"
+	case OperationKindCoverBlock:
+		ret = "CoverBlock"
+	case OperationKindAtomicMemoryWait:
+		ret = "AtomicMemoryWait"
+	case OperationKindAtomicMemoryNotify:
+		ret = "AtomicMemoryNotify"
+	case OperationKindAtomicFence:
+		ret = "AtomicFence"
+	case OperationKindAtomicLoad:
+		ret = "AtomicLoad"
+	case OperationKindAtomicStore:
+		ret = "AtomicStore"
+	case OperationKindAtomicRMW:
+		ret = "AtomicRMW"
+	case OperationKindAtomicRMWCmpxchg:
+		ret = "AtomicRMWCmpxchg"
+	case OperationKindTailCall:
+		ret = "TailCall"
+	case OperationKindTailCallIndirect:
+		ret = "TailCallIndirect"
+	case OperationKindSelectMemory:
+		ret = "SelectMemory"
+	case OperationKindThrow:
+		ret = "Throw"
+	case OperationKindRethrow:
+		ret = "Rethrow"
+	case OperationKindExceptionPending:
+		ret = "ExceptionPending"
+	case OperationKindExceptionMatch:
+		ret = "ExceptionMatch"
+	case OperationKindCatch:
+		ret = "Catch"
+	case OperationKindCallRef:
+		ret = "CallRef"
+	case OperationKindTailCallRef:
+		ret = "TailCallRef"
+	case OperationKindRefAsNonNull:
+		ret = "RefAsNonNull"
+	case OperationKindGC:
+		ret = "GC"
+	case OperationKindV128RelaxedMadd:
+		ret = "V128RelaxedMadd"
+	case OperationKindV128RelaxedDot:
+		ret = "V128RelaxedDot"
 	default:
 		panic(fmt.Errorf("unknown operation %d", o))
 	}
@@ -708,6 +758,79 @@ const (
 	// OperationKindBuiltinFunctionCheckExitCode is the kind for OperationBuiltinFunctionCheckExitCode.
 	OperationKindBuiltinFunctionCheckExitCode
 
+	// Below are toggled with CoreFeatureThreads.
+
+	// OperationKindAtomicMemoryWait is the kind for OperationAtomicMemoryWait.
+	OperationKindAtomicMemoryWait
+	// OperationKindAtomicMemoryNotify is the kind for OperationAtomicMemoryNotify.
+	OperationKindAtomicMemoryNotify
+	// OperationKindAtomicFence is the kind for OperationAtomicFence.
+	OperationKindAtomicFence
+	// OperationKindAtomicLoad is the kind for OperationAtomicLoad.
+	OperationKindAtomicLoad
+	// OperationKindAtomicStore is the kind for OperationAtomicStore.
+	OperationKindAtomicStore
+	// OperationKindAtomicRMW is the kind for OperationAtomicRMW.
+	OperationKindAtomicRMW
+	// OperationKindAtomicRMWCmpxchg is the kind for OperationAtomicRMWCmpxchg.
+	OperationKindAtomicRMWCmpxchg
+
+	// Below are toggled with CoreFeatureTailCall.
+
+	// OperationKindTailCall is the kind for OperationTailCall.
+	OperationKindTailCall
+	// OperationKindTailCallIndirect is the kind for OperationTailCallIndirect.
+	OperationKindTailCallIndirect
+
+	// Below are toggled with CoreFeatureMultiMemory.
+
+	// OperationKindSelectMemory is the kind for OperationSelectMemory.
+	OperationKindSelectMemory
+
+	// Below are toggled with CoreFeatureExceptionHandling.
+
+	// OperationKindThrow is the kind for OperationThrow.
+	OperationKindThrow
+	// OperationKindRethrow is the kind for OperationRethrow.
+	OperationKindRethrow
+	// OperationKindExceptionPending is the kind for OperationExceptionPending.
+	OperationKindExceptionPending
+	// OperationKindExceptionMatch is the kind for OperationExceptionMatch.
+	OperationKindExceptionMatch
+	// OperationKindCatch is the kind for OperationCatch.
+	OperationKindCatch
+
+	// Below are toggled with CoreFeatureFunctionReferences.
+
+	// OperationKindCallRef is the kind for OperationCallRef.
+	OperationKindCallRef
+	// OperationKindTailCallRef is the kind for OperationTailCallRef.
+	OperationKindTailCallRef
+	// OperationKindRefAsNonNull is the kind for OperationRefAsNonNull.
+	OperationKindRefAsNonNull
+
+	// Below are toggled with CoreFeatureGC.
+
+	// OperationKindGC is the kind for OperationGC.
+	OperationKindGC
+
+	// Below are toggled with CoreFeatureRelaxedSIMD.
+
+	// OperationKindV128RelaxedMadd is the kind for OperationV128RelaxedMadd.
+	OperationKindV128RelaxedMadd
+	// OperationKindV128RelaxedDot is the kind for OperationV128RelaxedDot.
+	OperationKindV128RelaxedDot
+
+	// Below are toggled with fuel metering.
+
+	// OperationKindConsumeFuel is the kind for OperationConsumeFuel.
+	OperationKindConsumeFuel
+
+	// Below are toggled with coverage.
+
+	// OperationKindCoverBlock is the kind for OperationCoverBlock.
+	OperationKindCoverBlock
+
 	// operationKindEnd is always placed at the bottom of this iota definition to be used in the test.
 	operationKindEnd
 )
@@ -723,6 +846,33 @@ func (OperationBuiltinFunctionCheckExitCode) Kind() OperationKind {
 	return OperationKindBuiltinFunctionCheckExitCode
 }
 
+// OperationConsumeFuel implements Operation.
+//
+// OperationConsumeFuel is placed at the beginning of each basic block when fuel metering is enabled, and Cost is the
+// number of Wasm instructions in the block. Engines are expected to subtract Cost from the remaining fuel of the call,
+// and to fail with wasmruntime.ErrRuntimeFuelExhausted if the result is negative.
+type OperationConsumeFuel struct {
+	Cost uint64
+}
+
+// Kind implements Operation.Kind
+func (*OperationConsumeFuel) Kind() OperationKind {
+	return OperationKindConsumeFuel
+}
+
+// OperationCoverBlock implements Operation.
+//
+// OperationCoverBlock is placed at the beginning of each basic block when coverage is enabled, and Index is the index
+// of the block in CompilationResult.CoverageBlocks. Engines are expected to increment the counter of the block.
+type OperationCoverBlock struct {
+	Index uint32
+}
+
+// Kind implements Operation.Kind
+func (*OperationCoverBlock) Kind() OperationKind {
+	return OperationKindCoverBlock
+}
+
 // Label is the label of each block in wazeroir where "block" consists of multiple operations,
 // and must end with branching operations (e.g. OperationBr or OperationBrIf).
 type Label struct {
@@ -921,6 +1071,46 @@ func (*OperationCallIndirect) Kind() OperationKind {
 	return OperationKindCallIndirect
 }
 
+// OperationTailCall implements Operation.
+//
+// This corresponds to wasm.OpcodeReturnCallName, and engines are expected to
+// drop the values in the range OperationTailCall.Drop, leaving only the
+// parameters of the target function on the current frame, and then replace
+// the current frame with the one of the function whose index equals
+// OperationTailCall.FunctionIndex instead of pushing a new frame.
+//
+// Note: The drop is part of this operation rather than a preceding OperationDrop, because the engines may need
+// to preserve the state of the current frame (e.g. the return address) before it is overwritten by the parameters.
+type OperationTailCall struct {
+	FunctionIndex uint32
+	// Drop is the range of values to drop before the call, which is nil when nothing needs to be dropped.
+	Drop *InclusiveRange
+}
+
+// Kind implements Operation.Kind
+func (*OperationTailCall) Kind() OperationKind {
+	return OperationKindTailCall
+}
+
+// OperationTailCallIndirect implements Operation.
+//
+// This corresponds to wasm.OpcodeReturnCallIndirectName, and is the tail call
+// variant of OperationCallIndirect. The engines are expected to drop the
+// values in the range OperationTailCallIndirect.Drop which excludes the
+// "offset" on the top of the stack, perform the same checks as
+// OperationCallIndirect, and then replace the current frame with the one of
+// the target function as in OperationTailCall.
+type OperationTailCallIndirect struct {
+	TypeIndex, TableIndex uint32
+	// Drop is the range of values to drop before the call, which is nil when nothing needs to be dropped.
+	Drop *InclusiveRange
+}
+
+// Kind implements Operation.Kind
+func (*OperationTailCallIndirect) Kind() OperationKind {
+	return OperationKindTailCallIndirect
+}
+
 // InclusiveRange is the range which spans across the value stack starting from the top to the bottom, and
 // both boundary are included in the range.
 type InclusiveRange struct {
@@ -1027,7 +1217,13 @@ type MemoryArg struct {
 
 	// Offset is the address offset added to the instruction's dynamic address operand, yielding a 33-bit effective
 	// address that is the zero-based index at which the memory is accessed. Default to zero.
-	Offset uint32
+	//
+	// Note: This only exceeds 32-bits when Memory64 is true, in which case the effective address is 65-bit.
+	Offset uint64
+
+	// Memory64 is true when the accessed memory is 64-bit (api.CoreFeatureMemory64), which means the dynamic address
+	// operand is i64 instead of i32.
+	Memory64 bool
 }
 
 // OperationLoad implements Operation.
@@ -1160,7 +1356,10 @@ func (OperationStore32) Kind() OperationKind {
 // This corresponds to wasm.OpcodeMemorySize.
 //
 // The engines are expected to push the current page size of the memory onto the stack.
-type OperationMemorySize struct{}
+type OperationMemorySize struct {
+	// Memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), which means the result is i64.
+	Memory64 bool
+}
 
 // Kind implements Operation.Kind.
 func (OperationMemorySize) Kind() OperationKind {
@@ -1168,7 +1367,12 @@ func (OperationMemorySize) Kind() OperationKind {
 }
 
 // OperationMemoryGrow implements Operation.
-type OperationMemoryGrow struct{ Alignment uint64 }
+type OperationMemoryGrow struct {
+	Alignment uint64
+	// Memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), which means both the delta and the result
+	// are i64.
+	Memory64 bool
+}
 
 // Kind implements Operation.Kind.
 //
@@ -1805,6 +2009,8 @@ type OperationMemoryInit struct {
 	// DataIndex is the index of the data instance in ModuleInstance.DataInstances
 	// by which this operation instantiates a part of the memory.
 	DataIndex uint32
+	// Memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), which means the destination offset is i64.
+	Memory64 bool
 }
 
 // Kind implements Operation.Kind.
@@ -1829,7 +2035,14 @@ func (OperationDataDrop) Kind() OperationKind {
 // OperationMemoryCopy implements Operation.
 //
 // This corresponds to wasm.OpcodeMemoryCopyName.
-type OperationMemoryCopy struct{}
+type OperationMemoryCopy struct {
+	// DestinationMemoryIndex and SourceMemoryIndex are only set when copying between different memories. Otherwise,
+	// both are zero and the copy happens within the memory chosen by OperationSelectMemory, if any.
+	DestinationMemoryIndex, SourceMemoryIndex uint32
+	// Memory64 is true when either memory is 64-bit (api.CoreFeatureMemory64), which means the offset into it is i64,
+	// as well as the size if both are.
+	Memory64 bool
+}
 
 // Kind implements Operation.Kind.
 func (OperationMemoryCopy) Kind() OperationKind {
@@ -1839,13 +2052,32 @@ func (OperationMemoryCopy) Kind() OperationKind {
 // OperationMemoryFill implements Operation.
 //
 // This corresponds to wasm.OpcodeMemoryFillName.
-type OperationMemoryFill struct{}
+type OperationMemoryFill struct {
+	// Memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), which means the offset and the size are i64.
+	Memory64 bool
+}
 
 // Kind implements Operation.Kind.
 func (OperationMemoryFill) Kind() OperationKind {
 	return OperationKindMemoryFill
 }
 
+// OperationSelectMemory implements Operation.
+//
+// This is emitted right before an instruction whose memory index immediate is not zero, and the engines are expected
+// to run the following memory operations against the memory of MemoryIndex. Right after that instruction, another
+// OperationSelectMemory with zero MemoryIndex is emitted, so that the memory of index zero is always in use otherwise.
+//
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+type OperationSelectMemory struct {
+	MemoryIndex uint32
+}
+
+// Kind implements Operation.Kind.
+func (*OperationSelectMemory) Kind() OperationKind {
+	return OperationKindSelectMemory
+}
+
 // OperationTableInit implements Operation.
 //
 // This corresponds to wasm.OpcodeTableInitName.
@@ -2202,7 +2434,12 @@ func (OperationV128Shuffle) Kind() OperationKind {
 }
 
 // OperationV128Swizzle implements Operation.
-type OperationV128Swizzle struct{}
+type OperationV128Swizzle struct {
+	// Relaxed is true for wasm.OpcodeVecI8x16RelaxedSwizzleName, whose result for the out-of-range indexes is
+	// implementation-defined. Engines may use the native instruction in that case, or otherwise must behave the same as
+	// wasm.OpcodeVecI8x16SwizzleName.
+	Relaxed bool
+}
 
 // Kind implements Operation.Kind.
 //
@@ -2848,3 +3085,302 @@ type OperationV128ITruncSatFromF struct {
 func (OperationV128ITruncSatFromF) Kind() OperationKind {
 	return OperationKindV128ITruncSatFromF
 }
+
+// OperationAtomicMemoryWait implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicMemoryWait32Name wasm.OpcodeAtomicMemoryWait64Name.
+//
+// The engines are expected to check the boundary and the alignment of the address, and trap if the memory isn't
+// shared. Otherwise, this blocks until notified or timed out, following the semantics of the corresponding
+// WebAssembly instruction.
+type OperationAtomicMemoryWait struct {
+	// Type is the type of the expected value, which is either UnsignedInt32 or UnsignedInt64.
+	Type UnsignedInt
+	Arg  *MemoryArg
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicMemoryWait) Kind() OperationKind {
+	return OperationKindAtomicMemoryWait
+}
+
+// OperationAtomicMemoryNotify implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicMemoryNotifyName.
+//
+// The engines are expected to check the boundary and the alignment of the address, then wake up the waiters following
+// the semantics of the corresponding WebAssembly instruction.
+type OperationAtomicMemoryNotify struct {
+	Arg *MemoryArg
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicMemoryNotify) Kind() OperationKind {
+	return OperationKindAtomicMemoryNotify
+}
+
+// OperationAtomicFence implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicFenceName.
+type OperationAtomicFence struct{}
+
+// Kind implements Operation.Kind
+func (OperationAtomicFence) Kind() OperationKind {
+	return OperationKindAtomicFence
+}
+
+// OperationAtomicLoad implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicI32LoadName wasm.OpcodeAtomicI64LoadName and their narrow variants such as
+// wasm.OpcodeAtomicI64Load32UName.
+//
+// The engines are expected to check the boundary and the alignment of the address, and exit the execution if either
+// is violated, otherwise atomically load the value zero-extended to Type.
+type OperationAtomicLoad struct {
+	Type UnsignedInt
+	// Size is the number of bytes accessed: 1, 2, 4 or 8.
+	Size uint32
+	Arg  *MemoryArg
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicLoad) Kind() OperationKind {
+	return OperationKindAtomicLoad
+}
+
+// OperationAtomicStore implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicI32StoreName wasm.OpcodeAtomicI64StoreName and their narrow variants such as
+// wasm.OpcodeAtomicI64Store32Name.
+//
+// The engines are expected to check the boundary and the alignment of the address, and exit the execution if either
+// is violated, otherwise atomically store the lower Size bytes of the value.
+type OperationAtomicStore struct {
+	Type UnsignedInt
+	// Size is the number of bytes accessed: 1, 2, 4 or 8.
+	Size uint32
+	Arg  *MemoryArg
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicStore) Kind() OperationKind {
+	return OperationKindAtomicStore
+}
+
+// OperationAtomicRMW implements Operation.
+//
+// This corresponds to the read-modify-write instructions except cmpxchg, e.g. wasm.OpcodeAtomicI32RMWAddName or
+// wasm.OpcodeAtomicI64RMW8XchgUName.
+//
+// The engines are expected to check the boundary and the alignment of the address, and exit the execution if either
+// is violated, otherwise atomically apply Op to the value in memory and push the previous value.
+type OperationAtomicRMW struct {
+	Type UnsignedInt
+	// Size is the number of bytes accessed: 1, 2, 4 or 8.
+	Size uint32
+	Arg  *MemoryArg
+	Op   wasm.AtomicArithmeticOp
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicRMW) Kind() OperationKind {
+	return OperationKindAtomicRMW
+}
+
+// OperationAtomicRMWCmpxchg implements Operation.
+//
+// This corresponds to wasm.OpcodeAtomicI32RMWCmpxchgName wasm.OpcodeAtomicI64RMWCmpxchgName and their narrow variants
+// such as wasm.OpcodeAtomicI64RMW32CmpxchgUName.
+//
+// The engines are expected to check the boundary and the alignment of the address, and exit the execution if either
+// is violated, otherwise atomically replace the value in memory with the replacement if it equals the expected value,
+// and push the previous value.
+type OperationAtomicRMWCmpxchg struct {
+	Type UnsignedInt
+	// Size is the number of bytes accessed: 1, 2, 4 or 8.
+	Size uint32
+	Arg  *MemoryArg
+}
+
+// Kind implements Operation.Kind
+func (*OperationAtomicRMWCmpxchg) Kind() OperationKind {
+	return OperationKindAtomicRMWCmpxchg
+}
+
+// OperationThrow implements Operation.
+//
+// This corresponds to wasm.OpcodeThrowName, and the engines are expected to pop the payload of the tag of TagIndex, and
+// make a new exception of the tag and the payload pending. The operation itself doesn't transfer the control: it is
+// always followed by the branch into the handler of the exception.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+type OperationThrow struct {
+	TagIndex uint32
+}
+
+// Kind implements Operation.Kind
+func (*OperationThrow) Kind() OperationKind {
+	return OperationKindThrow
+}
+
+// OperationRethrow implements Operation.
+//
+// This corresponds to wasm.OpcodeRethrowName, and the engines are expected to pop the i64 reference to the exception
+// pushed by OperationCatch, and make the exception pending again. As with OperationThrow, this is always followed by
+// the branch into the handler of the exception.
+type OperationRethrow struct{}
+
+// Kind implements Operation.Kind
+func (*OperationRethrow) Kind() OperationKind {
+	return OperationKindRethrow
+}
+
+// OperationExceptionPending implements Operation.
+//
+// This is emitted right after each function call, and the engines are expected to push 1 as i32 if the callee
+// returned with a pending exception, or 0 otherwise. The result is consumed by OperationBrIf which branches into the
+// handler of the exception.
+type OperationExceptionPending struct{}
+
+// Kind implements Operation.Kind
+func (*OperationExceptionPending) Kind() OperationKind {
+	return OperationKindExceptionPending
+}
+
+// OperationExceptionMatch implements Operation.
+//
+// This is emitted at the beginning of each wasm.OpcodeCatchName clause, and the engines are expected to push 1 as i32
+// if the tag of the pending exception is the tag of TagIndex in the module instance, or 0 otherwise.
+type OperationExceptionMatch struct {
+	TagIndex uint32
+}
+
+// Kind implements Operation.Kind
+func (*OperationExceptionMatch) Kind() OperationKind {
+	return OperationKindExceptionMatch
+}
+
+// OperationCatch implements Operation.
+//
+// This corresponds to wasm.OpcodeCatchName and wasm.OpcodeCatchAllName, and the engines are expected to clear the
+// pending exception and push the i64 reference to it, followed by its payload unless All is true. The reference is
+// only used by OperationRethrow.
+type OperationCatch struct {
+	// TagIndex is the index of the tag caught. Ignored if All is true.
+	TagIndex uint32
+	// All is true for wasm.OpcodeCatchAllName, where the payload is not pushed.
+	All bool
+	// Rethrown is true if the catch block contains wasm.OpcodeRethrowName targeting it, which means that the engines
+	// have to keep the exception accessible by the reference. Otherwise, the reference can be left invalid.
+	Rethrown bool
+}
+
+// Kind implements Operation.Kind
+func (*OperationCatch) Kind() OperationKind {
+	return OperationKindCatch
+}
+
+// OperationCallRef implements Operation.
+//
+// This corresponds to wasm.OpcodeCallRefName, and engines are expected to consume the function reference on the top of
+// the stack, and make a function call against the referenced function. As with OperationCallIndirect, two checks are
+// performed at runtime before entering the target function:
+// 1) whether the reference is null.
+// 2) whether the type of the referenced function matches the function type specified by OperationCallRef.TypeIndex.
+type OperationCallRef struct {
+	TypeIndex uint32
+}
+
+// Kind implements Operation.Kind
+func (*OperationCallRef) Kind() OperationKind {
+	return OperationKindCallRef
+}
+
+// OperationTailCallRef implements Operation.
+//
+// This corresponds to wasm.OpcodeReturnCallRefName, and is the tail call variant of OperationCallRef. The engines are
+// expected to drop the values in the range OperationTailCallRef.Drop which excludes the function reference on the top
+// of the stack, perform the same checks as OperationCallRef, and then replace the current frame with the one of the
+// target function as in OperationTailCall.
+type OperationTailCallRef struct {
+	TypeIndex uint32
+	// Drop is the range of values to drop before the call, which is nil when nothing needs to be dropped.
+	Drop *InclusiveRange
+}
+
+// Kind implements Operation.Kind
+func (*OperationTailCallRef) Kind() OperationKind {
+	return OperationKindTailCallRef
+}
+
+// OperationRefAsNonNull implements Operation.
+//
+// This corresponds to wasm.OpcodeRefAsNonNullName, and the engines are expected to exit the execution if the
+// reference on the top of the stack is null, and otherwise leave it as is.
+type OperationRefAsNonNull struct{}
+
+// Kind implements Operation.Kind
+func (*OperationRefAsNonNull) Kind() OperationKind {
+	return OperationKindRefAsNonNull
+}
+
+// OperationGC implements Operation.
+//
+// This corresponds to the struct, array, ref.test and ref.cast instructions prefixed by wasm.OpcodeGCPrefix. The
+// engines are expected to pop the Operands values on the stack, pass them to wasm.ModuleInstance ExecuteGC in the order
+// they were pushed, and push the result if HasResult is true. Objects are held by an i64 handle to the GC heap of the
+// store, where zero means null.
+type OperationGC struct {
+	// Opcode is the wasm.OpcodeGC following wasm.OpcodeGCPrefix.
+	Opcode byte
+	// TypeIndex is the type index of the struct or array type, or the heap type of ref.test and ref.cast.
+	TypeIndex uint32
+	// Index is the field index of struct.get and struct.set, or the number of values of array.new_fixed.
+	Index uint32
+	// Operands is the number of the values consumed from the stack.
+	Operands int
+	// HasResult is true if the instruction pushes a value of the type Result.
+	HasResult bool
+	Result    UnsignedType
+}
+
+// Kind implements Operation.Kind
+func (*OperationGC) Kind() OperationKind {
+	return OperationKindGC
+}
+
+// OperationV128RelaxedMadd implements Operation.
+//
+// This corresponds to wasm.OpcodeVecF32x4RelaxedMaddName wasm.OpcodeVecF32x4RelaxedNmaddName
+//
+//	wasm.OpcodeVecF64x2RelaxedMaddName wasm.OpcodeVecF64x2RelaxedNmaddName.
+//
+// Engines are expected to pop the values c, b and a, and push a*b+c, or -(a*b)+c if Negate is true. The product is
+// rounded before the addition unless the engine uses the native fused multiply-add instructions.
+type OperationV128RelaxedMadd struct {
+	// Shape is either ShapeF32x4 or ShapeF64x2.
+	Shape  Shape
+	Negate bool
+}
+
+// Kind implements Operation.Kind.
+func (OperationV128RelaxedMadd) Kind() OperationKind {
+	return OperationKindV128RelaxedMadd
+}
+
+// OperationV128RelaxedDot implements Operation.
+//
+// This corresponds to wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName.
+//
+// Engines are expected to pop the values b and a, and push the i16x8 vector whose lanes are the sums of the products
+// of the adjacent pairs of signed i8 lanes. The sums wrap on overflow. If Add is true, the value c is popped before b
+// and a, and the adjacent pairs of the sums are added as signed i32 lanes to c instead. Unless the engine uses the
+// native instructions, b is treated as signed even though the proposal only defines the results of 7-bit lanes.
+type OperationV128RelaxedDot struct {
+	Add bool
+}
+
+// Kind implements Operation.Kind.
+func (OperationV128RelaxedDot) Kind() OperationKind {
+	return OperationKindV128RelaxedDot
+}
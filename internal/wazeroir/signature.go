@@ -3,6 +3,7 @@ package wazeroir
 import (
 	"fmt"
 
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -162,6 +163,26 @@ var (
 	signature_I32I64I32_None = &signature{
 		in: []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI32},
 	}
+	signature_I32I64_I64 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI64},
+	}
+	signature_I32I32I32_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI32, UnsignedTypeI32},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
+	signature_I32I64I64_I64 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI64},
+	}
+	signature_I32I32I64_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI32, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
+	signature_I32I64I64_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
 	signature_UnknownUnknownI32_Unknown = &signature{
 		in:  []UnsignedType{UnsignedTypeUnknown, UnsignedTypeUnknown, UnsignedTypeI32},
 		out: []UnsignedType{UnsignedTypeUnknown},
@@ -252,12 +273,26 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 		return signature_I32_None, nil
 	case wasm.OpcodeReturn:
 		return signature_None_None, nil
+	case wasm.OpcodeReturnCall, wasm.OpcodeReturnCallIndirect, wasm.OpcodeReturnCallRef:
+		// The parameters (and the table offset) are consumed by the tail call itself when lowering,
+		// and nothing is pushed as the instruction is stack-polymorphic.
+		return signature_None_None, nil
 	case wasm.OpcodeCall:
 		return funcTypeToSignature(c.types[c.funcs[index]]), nil
+	case wasm.OpcodeTry, wasm.OpcodeCatch, wasm.OpcodeCatchAll, wasm.OpcodeRethrow, wasm.OpcodeDelegate:
+		// The values pushed by catch and catch_all are managed when lowering as they depend on the enclosing try.
+		return signature_None_None, nil
+	case wasm.OpcodeThrow:
+		return funcTypeToSignature(c.types[c.tags[index]]), nil
 	case wasm.OpcodeCallIndirect:
 		ret := funcTypeToSignature(c.types[index])
 		ret.in = append(ret.in, UnsignedTypeI32)
 		return ret, nil
+	case wasm.OpcodeCallRef:
+		// The function reference is an opaque pointer (uint64) on the top of the stack.
+		ret := funcTypeToSignature(c.types[index])
+		ret.in = append(ret.in, UnsignedTypeI64)
+		return ret, nil
 	case wasm.OpcodeDrop:
 		return signature_Unknown_None, nil
 	case wasm.OpcodeSelect, wasm.OpcodeTypedSelect:
@@ -454,6 +489,12 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 	case wasm.OpcodeRefNull:
 		// ref.null is translated as i64.const 0.
 		return signature_None_I64, nil
+	case wasm.OpcodeRefAsNonNull, wasm.OpcodeBrOnNull:
+		// The reference is left on the stack unless it is null, in which case the execution traps or branches.
+		return signature_I64_I64, nil
+	case wasm.OpcodeBrOnNonNull:
+		// The reference is consumed unless it is non-null, in which case the execution branches with it.
+		return signature_I64_None, nil
 	case wasm.OpcodeMiscPrefix:
 		switch miscOp := c.body[c.pc+1]; miscOp {
 		case wasm.OpcodeMiscI32TruncSatF32S, wasm.OpcodeMiscI32TruncSatF32U:
@@ -479,6 +520,9 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 			return nil, fmt.Errorf("unsupported misc instruction in wazeroir: 0x%x", op)
 		}
 	case wasm.OpcodeVecPrefix:
+		if relaxedOp, ok := wasm.RelaxedVecOpcode(c.body[c.pc+1:]); ok {
+			return relaxedVecSignature(relaxedOp)
+		}
 		switch vecOp := c.body[c.pc+1]; vecOp {
 		case wasm.OpcodeVecV128Const:
 			return signature_None_V128, nil
@@ -588,11 +632,204 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 		default:
 			return nil, fmt.Errorf("unsupported vector instruction in wazeroir: %s", wasm.VectorInstructionName(vecOp))
 		}
+	case wasm.OpcodeGCPrefix:
+		return c.gcSignature()
+	case wasm.OpcodeRefEq:
+		// ref.eq is translated as comparing the uint64 handles of the references.
+		return signature_I64I64_I32, nil
+	case wasm.OpcodeAtomicPrefix:
+		switch atomicOp := c.body[c.pc+1]; atomicOp {
+		case wasm.OpcodeAtomicMemoryNotify:
+			return signature_I32I32_I32, nil
+		case wasm.OpcodeAtomicMemoryWait32:
+			return signature_I32I32I64_I32, nil
+		case wasm.OpcodeAtomicMemoryWait64:
+			return signature_I32I64I64_I32, nil
+		case wasm.OpcodeAtomicFence:
+			return signature_None_None, nil
+		}
+		attr, ok := atomicInstructions[c.body[c.pc+1]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported atomic instruction in wazeroir: 0x%x", c.body[c.pc+1])
+		}
+		i64 := attr.typ == UnsignedInt64
+		switch attr.kind {
+		case OperationKindAtomicLoad:
+			if i64 {
+				return signature_I32_I64, nil
+			}
+			return signature_I32_I32, nil
+		case OperationKindAtomicStore:
+			if i64 {
+				return signature_I32I64_None, nil
+			}
+			return signature_I32I32_None, nil
+		case OperationKindAtomicRMW:
+			if i64 {
+				return signature_I32I64_I64, nil
+			}
+			return signature_I32I32_I32, nil
+		default: // OperationKindAtomicRMWCmpxchg
+			if i64 {
+				return signature_I32I64I64_I64, nil
+			}
+			return signature_I32I32I32_I32, nil
+		}
 	default:
 		return nil, fmt.Errorf("unsupported instruction in wazeroir: 0x%x", op)
 	}
 }
 
+// relaxedVecSignature returns the signature of the relaxed vector instruction.
+func relaxedVecSignature(op wasm.OpcodeVecRelaxed) (*signature, error) {
+	switch op {
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S, wasm.OpcodeVecI32x4RelaxedTruncF32x4U,
+		wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		return signature_V128_V128, nil
+	case wasm.OpcodeVecI8x16RelaxedSwizzle, wasm.OpcodeVecF32x4RelaxedMin, wasm.OpcodeVecF32x4RelaxedMax,
+		wasm.OpcodeVecF64x2RelaxedMin, wasm.OpcodeVecF64x2RelaxedMax, wasm.OpcodeVecI16x8RelaxedQ15mulrS,
+		wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		return signature_V128V128_V128, nil
+	case wasm.OpcodeVecF32x4RelaxedMadd, wasm.OpcodeVecF32x4RelaxedNmadd, wasm.OpcodeVecF64x2RelaxedMadd,
+		wasm.OpcodeVecF64x2RelaxedNmadd, wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		return signature_V128V128V128_V32, nil
+	default:
+		return nil, fmt.Errorf("unsupported relaxed vector instruction in wazeroir: %s", wasm.RelaxedVectorInstructionName(op))
+	}
+}
+
+// gcSignature returns the signature of the GC instruction at c.pc. References are i64 handles, and packed fields
+// are read and written as i32.
+func (c *compiler) gcSignature() (*signature, error) {
+	op, typeIndex, index, _, err := c.gcImmediates()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull, wasm.OpcodeGCArrayLen:
+		return signature_I64_I32, nil
+	case wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		return signature_I64_I64, nil
+	}
+
+	fields := c.types[typeIndex].Composite.Fields
+	switch op {
+	case wasm.OpcodeGCStructNew:
+		ret := &signature{out: []UnsignedType{UnsignedTypeI64}}
+		for _, f := range fields {
+			ret.in = append(ret.in, wasmValueTypeToUnsignedType(f.UnpackedType()))
+		}
+		return ret, nil
+	case wasm.OpcodeGCStructNewDefault:
+		return signature_None_I64, nil
+	case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU:
+		return &signature{
+			in:  []UnsignedType{UnsignedTypeI64},
+			out: []UnsignedType{wasmValueTypeToUnsignedType(fields[index].UnpackedType())},
+		}, nil
+	case wasm.OpcodeGCStructSet:
+		return &signature{in: []UnsignedType{UnsignedTypeI64, wasmValueTypeToUnsignedType(fields[index].UnpackedType())}}, nil
+	}
+
+	elem := wasmValueTypeToUnsignedType(fields[0].UnpackedType())
+	switch op {
+	case wasm.OpcodeGCArrayNew:
+		return &signature{in: []UnsignedType{elem, UnsignedTypeI32}, out: []UnsignedType{UnsignedTypeI64}}, nil
+	case wasm.OpcodeGCArrayNewDefault:
+		return signature_I32_I64, nil
+	case wasm.OpcodeGCArrayNewFixed:
+		ret := &signature{in: make([]UnsignedType, index), out: []UnsignedType{UnsignedTypeI64}}
+		for i := range ret.in {
+			ret.in[i] = elem
+		}
+		return ret, nil
+	case wasm.OpcodeGCArrayGet, wasm.OpcodeGCArrayGetS, wasm.OpcodeGCArrayGetU:
+		return &signature{in: []UnsignedType{UnsignedTypeI64, UnsignedTypeI32}, out: []UnsignedType{elem}}, nil
+	case wasm.OpcodeGCArraySet:
+		return &signature{in: []UnsignedType{UnsignedTypeI64, UnsignedTypeI32, elem}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported gc instruction in wazeroir: 0x%x", op)
+	}
+}
+
+// memory64Signature returns the signature s of the memory instruction at c.pc with i64 addresses and sizes, when they
+// refer to a 64-bit memory (api.CoreFeatureMemory64). Otherwise, this returns s as is.
+//
+// Note: This peeks the memory index immediates without advancing c.pc, as they are read after the stack is modified.
+func (c *compiler) memory64Signature(op wasm.Opcode, s *signature) *signature {
+	var in, out []int // indexes of s.in and s.out to replace with i64.
+	switch op {
+	case wasm.OpcodeMemorySize:
+		if c.isMemory64(uint32(c.body[c.pc+1])) {
+			out = []int{0}
+		}
+	case wasm.OpcodeMemoryGrow:
+		if c.isMemory64(uint32(c.body[c.pc+1])) {
+			in, out = []int{0}, []int{0}
+		}
+	case wasm.OpcodeMiscPrefix:
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeMiscMemoryInit:
+			_, num, _ := leb128.LoadUint32(c.body[c.pc+2:]) // data index
+			if memoryIndex, _, _ := leb128.LoadUint32(c.body[c.pc+2+num:]); c.isMemory64(memoryIndex) {
+				in = []int{0}
+			}
+		case wasm.OpcodeMiscMemoryCopy:
+			dst, num, _ := leb128.LoadUint32(c.body[c.pc+2:])
+			src, _, _ := leb128.LoadUint32(c.body[c.pc+2+num:])
+			if c.isMemory64(dst) {
+				in = append(in, 0)
+			}
+			if c.isMemory64(src) {
+				in = append(in, 1)
+			}
+			if len(in) == 2 { // The size is i64 only when both are.
+				in = append(in, 2)
+			}
+		case wasm.OpcodeMiscMemoryFill:
+			if memoryIndex, _, _ := leb128.LoadUint32(c.body[c.pc+2:]); c.isMemory64(memoryIndex) {
+				in = []int{0, 2}
+			}
+		}
+	case wasm.OpcodeVecPrefix:
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u,
+			wasm.OpcodeVecV128Load16x4s, wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s,
+			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
+			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load32zero,
+			wasm.OpcodeVecV128Load64zero, wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane,
+			wasm.OpcodeVecV128Load32Lane, wasm.OpcodeVecV128Load64Lane, wasm.OpcodeVecV128Store,
+			wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane, wasm.OpcodeVecV128Store32Lane,
+			wasm.OpcodeVecV128Store64Lane:
+			if c.isMemory64(c.memArgMemoryIndex(c.pc + 2)) {
+				in = []int{0}
+			}
+		}
+	case wasm.OpcodeAtomicPrefix:
+		if c.body[c.pc+1] != wasm.OpcodeAtomicFence && c.isMemory64(c.memArgMemoryIndex(c.pc+2)) {
+			in = []int{0}
+		}
+	default:
+		if wasm.OpcodeI32Load <= op && op <= wasm.OpcodeI64Store32 && c.isMemory64(c.memArgMemoryIndex(c.pc+1)) {
+			in = []int{0}
+		}
+	}
+
+	if len(in) == 0 && len(out) == 0 {
+		return s
+	}
+	ret := &signature{in: append([]UnsignedType{}, s.in...), out: append([]UnsignedType{}, s.out...)}
+	for _, i := range in {
+		ret.in[i] = UnsignedTypeI64
+	}
+	for _, i := range out {
+		ret.out[i] = UnsignedTypeI64
+	}
+	return ret
+}
+
 func funcTypeToSignature(tps *wasm.FunctionType) *signature {
 	ret := &signature{}
 	for _, vt := range tps.Params {
@@ -610,7 +847,7 @@ func wasmValueTypeToUnsignedType(vt wasm.ValueType) UnsignedType {
 		return UnsignedTypeI32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		return UnsignedTypeI64
 	case wasm.ValueTypeF32:
 		return UnsignedTypeF32
@@ -628,7 +865,7 @@ func wasmValueTypeToUnsignedOutSignature(vt wasm.ValueType) *signature {
 		return signature_None_I32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		return signature_None_I64
 	case wasm.ValueTypeF32:
 		return signature_None_F32
@@ -646,7 +883,7 @@ func wasmValueTypeToUnsignedInSignature(vt wasm.ValueType) *signature {
 		return signature_I32_None
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		return signature_I64_None
 	case wasm.ValueTypeF32:
 		return signature_F32_None
@@ -664,7 +901,7 @@ func wasmValueTypeToUnsignedInOutSignature(vt wasm.ValueType) *signature {
 		return signature_I32_I32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeAnyref:
 		return signature_I64_I64
 	case wasm.ValueTypeF32:
 		return signature_F32_F32
@@ -0,0 +1,166 @@
+package wazero
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModulePool hands out instances of a CompiledModule, and resets the ones put back to their state right after
+// instantiation, which is much cheaper than instantiating them again. See Runtime.NewModulePool
+//
+// Here's an example of handling each request with an instance in its initial state:
+//
+//	pool, _ := r.NewModulePool(ctx, compiled, wazero.NewModuleConfig().WithName("handler"))
+//	defer pool.Close(ctx)
+//
+//	// In the request handler:
+//	mod, _ := pool.Get(ctx)
+//	defer pool.Put(ctx, mod)
+//	_, err := mod.ExportedFunction("handle").Call(ctx)
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//   - A ModulePool is safe for concurrent use, but an instance must only be used by one goroutine until put back.
+//   - Instances are named after the ModuleConfig name suffixed with their number, e.g. "handler#2", as the names of
+//     modules are unique in a Runtime.
+//   - Resetting an instance restores its defined memories, globals and tables, as well as its system context, such as
+//     open files. The imported memories, globals and tables aren't reset, as they belong to other modules.
+type ModulePool interface {
+	// Get returns an instance in its initial state, instantiating a new one when none is available.
+	Get(ctx context.Context) (api.Module, error)
+
+	// Put resets an instance returned by Get, and makes it available again. An instance which was closed, for example
+	// as the guest exited, is discarded instead.
+	//
+	// This errs without resetting the module if it wasn't returned by Get of this ModulePool, or was already put back.
+	Put(ctx context.Context, mod api.Module) error
+
+	// Closer closes the available instances. The ones in use are closed when put back.
+	api.Closer
+}
+
+// modulePool implements ModulePool
+type modulePool struct {
+	r        *runtime
+	compiled CompiledModule
+	config   *moduleConfig
+	name     string
+
+	// snapshot is the state of the first instance right after instantiation, including the start functions, which
+	// further instances are instantiated from, and the instances put back are reset to.
+	snapshot *wasm.Snapshot
+
+	mux       sync.Mutex
+	available []*wasm.CallContext            // guarded by mux
+	inUse     map[*wasm.CallContext]struct{} // guarded by mux
+	count     int                            // guarded by mux
+	closed    bool                           // guarded by mux
+}
+
+// NewModulePool implements Runtime.NewModulePool
+func (r *runtime) NewModulePool(ctx context.Context, compiled CompiledModule, mConfig ModuleConfig) (ModulePool, error) {
+	config := mConfig.(*moduleConfig)
+	name := config.name
+	if name == "" {
+		name = compiled.Name()
+	}
+	p := &modulePool{r: r, compiled: compiled, config: config, name: name, inUse: map[*wasm.CallContext]struct{}{}, count: 1}
+
+	mod, err := r.InstantiateModule(ctx, compiled, config.WithName(p.instanceName(1)))
+	if err != nil {
+		return nil, err
+	}
+	callCtx := mod.(*wasm.CallContext)
+	if p.snapshot, err = callCtx.Snapshot(); err != nil {
+		_ = mod.Close(ctx)
+		return nil, err
+	}
+	p.available = append(p.available, callCtx)
+	return p, nil
+}
+
+func (p *modulePool) instanceName(n int) string {
+	return fmt.Sprintf("%s#%d", p.name, n)
+}
+
+// Get implements ModulePool.Get
+func (p *modulePool) Get(ctx context.Context) (api.Module, error) {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return nil, errors.New("module pool closed")
+	}
+	if n := len(p.available); n > 0 {
+		mod := p.available[n-1]
+		p.available = p.available[:n-1]
+		p.inUse[mod] = struct{}{}
+		p.mux.Unlock()
+		return mod, nil
+	}
+	p.count++
+	name := p.instanceName(p.count)
+	p.mux.Unlock()
+
+	// The start functions aren't called again, as their effects are in the snapshot.
+	mod, err := p.r.instantiateModule(ctx, p.compiled, p.config.WithName(name).WithStartFunctions(), p.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	p.mux.Lock()
+	p.inUse[mod.(*wasm.CallContext)] = struct{}{}
+	p.mux.Unlock()
+	return mod, nil
+}
+
+// Put implements ModulePool.Put
+func (p *modulePool) Put(ctx context.Context, mod api.Module) error {
+	callCtx, ok := mod.(*wasm.CallContext)
+	p.mux.Lock()
+	if _, inUse := p.inUse[callCtx]; !ok || !inUse {
+		p.mux.Unlock()
+		return errors.New("module wasn't returned by Get of this pool, or was already put back")
+	}
+	delete(p.inUse, callCtx)
+	p.mux.Unlock()
+
+	if callCtx.FailIfClosed() != nil {
+		return nil // Discard the closed instance.
+	}
+
+	sysCtx, err := p.config.toSysContext()
+	if err == nil {
+		err = callCtx.Reset(ctx, p.snapshot, sysCtx)
+	}
+	if err != nil {
+		_ = mod.Close(ctx) // Don't leak an instance which can't be reused.
+		return err
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return mod.Close(ctx)
+	}
+	p.available = append(p.available, callCtx)
+	return nil
+}
+
+// Close implements api.Closer embedded in ModulePool.
+func (p *modulePool) Close(ctx context.Context) (err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.closed = true
+	for _, mod := range p.available {
+		if e := mod.Close(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	p.available = nil
+	return
+}
@@ -0,0 +1,74 @@
+package wazero
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestModulePool(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	var starts int
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(context.Context, api.Module) { starts++ }).
+		Export("start").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	compiled := mustCompile(t, r, `(module (import "env" "start" (func $start)) (start $start))`)
+	pool, err := r.NewModulePool(testCtx, compiled, NewModuleConfig().WithName("pool"))
+	require.NoError(t, err)
+
+	first, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	second, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, "pool#1", first.Name())
+	require.Equal(t, "pool#2", second.Name())
+	require.Equal(t, 1, starts) // The start function is only called for the first instance.
+
+	// The instance put back gets a new system context.
+	sysCtx := first.(*wasm.CallContext).Sys
+	require.NoError(t, pool.Put(testCtx, first))
+	require.NotSame(t, sysCtx, first.(*wasm.CallContext).Sys)
+
+	// A closed instance is discarded, so a new one is instantiated.
+	require.NoError(t, second.CloseWithExitCode(testCtx, 1))
+	require.NoError(t, pool.Put(testCtx, second))
+	reused, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, first, reused)
+	third, err := pool.Get(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, "pool#3", third.Name())
+	require.NoError(t, pool.Put(testCtx, third))
+
+	// Modules not in use from this pool are refused, without being reset.
+	other, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig().WithName("other"))
+	require.NoError(t, err)
+	otherPool, err := r.NewModulePool(testCtx, compiled, NewModuleConfig().WithName("other pool"))
+	require.NoError(t, err)
+	fromOtherPool, err := otherPool.Get(testCtx)
+	require.NoError(t, err)
+	for _, mod := range []api.Module{other, fromOtherPool, third, nil} {
+		require.EqualError(t, pool.Put(testCtx, mod), "module wasn't returned by Get of this pool, or was already put back")
+	}
+	require.NoError(t, otherPool.Put(testCtx, fromOtherPool))
+	require.NoError(t, otherPool.Close(testCtx))
+
+	// Closing the pool closes the available instances, and the ones in use when put back.
+	require.NoError(t, pool.Close(testCtx))
+	require.Nil(t, r.Module("pool#3"))
+	require.NotNil(t, r.Module("pool#1"))
+	require.NoError(t, pool.Put(testCtx, first))
+	require.Nil(t, r.Module("pool#1"))
+
+	_, err = pool.Get(testCtx)
+	require.EqualError(t, err, "module pool closed")
+}
@@ -13,9 +13,15 @@ import (
 	"github.com/tetratelabs/wazero/internal/version"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+	textformat "github.com/tetratelabs/wazero/internal/wasm/text"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
 
+// ErrInterrupted is the error, possibly wrapped, of a call interrupted because its context.Context was canceled or
+// reached its deadline. See RuntimeConfig.WithInterruptOnContextDone.
+var ErrInterrupted error = wasmruntime.ErrRuntimeInterrupted
+
 // Runtime allows embedding of WebAssembly modules.
 //
 // The below is an example of basic initialization:
@@ -39,7 +45,7 @@ type Runtime interface {
 	//		Instantiate(ctx, r)
 	NewHostModuleBuilder(moduleName string) HostModuleBuilder
 
-	// CompileModule decodes the WebAssembly binary (%.wasm) or errs if invalid.
+	// CompileModule decodes the WebAssembly binary (%.wasm) or text (%.wat) or errs if invalid.
 	// Any pre-compilation done after decoding wasm is dependent on RuntimeConfig.
 	//
 	// There are two main reasons to use CompileModule instead of InstantiateModuleFromBinary:
@@ -49,6 +55,9 @@ type Runtime interface {
 	// # Notes
 	//
 	//   - The resulting module name defaults to what was binary from the custom name section.
+	//   - Source not beginning with the binary magic number is decoded as the text format. Identifiers, such as
+	//     "$main", are kept in the name section, and syntax errors include the line and column, e.g. "3:5: ...".
+	//     Source without a module, such as an empty or truncated file, is an error.
 	//   - Any pre-compilation done after decoding the source is dependent on RuntimeConfig.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#name-section%E2%91%A0
@@ -97,6 +106,32 @@ type Runtime interface {
 	//   - The module has a start function, and it failed to execute.
 	InstantiateModule(ctx context.Context, compiled CompiledModule, config ModuleConfig) (api.Module, error)
 
+	// InstantiateModuleFromSnapshot is like InstantiateModule, except the memories, globals and tables defined by the
+	// module start with the state of the Snapshot, which was taken from an instance of the same module.
+	//
+	// Here's an example:
+	//	snapshot, _ := wazero.TakeSnapshot(initialized)
+	//	module, _ := n.InstantiateModuleFromSnapshot(ctx, compiled, snapshot, wazero.NewModuleConfig().WithName("prod"))
+	//
+	// # Notes
+	//
+	//   - The module start function isn't executed, as its effects are in the Snapshot. However, the start functions
+	//     of the ModuleConfig are, so use ModuleConfig.WithStartFunctions to skip the ones which were already called.
+	//   - The data and element segments of imported memories and tables are applied again, as their state isn't in the
+	//     Snapshot.
+	//   - This fails when the Snapshot was taken from an instance of another module, even if it has the same
+	//     definitions, or when it wasn't returned by TakeSnapshot.
+	//
+	// See TakeSnapshot
+	InstantiateModuleFromSnapshot(ctx context.Context, compiled CompiledModule, snapshot Snapshot, config ModuleConfig) (api.Module, error)
+
+	// NewModulePool returns a ModulePool of instances of the module, which are reset to their state right after
+	// instantiation when put back, instead of instantiating new ones.
+	//
+	// This instantiates the first instance with the ModuleConfig, so it fails like InstantiateModule. The further
+	// instances start with the state of the first one, so the start functions are only called once.
+	NewModulePool(ctx context.Context, compiled CompiledModule, config ModuleConfig) (ModulePool, error)
+
 	// Closer closes all compiled code by delegating to CloseWithExitCode with an exit code of zero.
 	api.Closer
 }
@@ -123,6 +158,7 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		engine = config.newEngine(ctx, config.enabledFeatures, nil)
 	}
 	store := wasm.NewStore(config.enabledFeatures, engine)
+	store.InterruptOnContextDone = config.interruptOnDone
 	zero := uint64(0)
 	return &runtime{
 		cache:                 cacheImpl,
@@ -133,7 +169,10 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		dwarfDisabled:         config.dwarfDisabled,
 		storeCustomSections:   config.storeCustomSections,
 		closed:                &zero,
-		ensureTermination:     config.ensureTermination,
+		ensureTermination:     config.ensureTermination || config.interruptOnDone, // Interruption uses the same checks.
+		fuelMetering:          config.fuelMetering,
+		coverage:              config.coverage,
+		memoryReservation:     config.memoryReservation,
 	}
 }
 
@@ -156,6 +195,9 @@ type runtime struct {
 	closed *uint64
 
 	ensureTermination bool
+	fuelMetering      bool
+	coverage          bool
+	memoryReservation bool
 }
 
 // Module implements Runtime.Module.
@@ -173,12 +215,15 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, errors.New("binary == nil")
 	}
 
-	if len(binary) < 4 || !bytes.Equal(binary[0:4], binaryformat.Magic) {
-		return nil, errors.New("invalid binary")
+	var internal *wasm.Module
+	var err error
+	if len(binary) >= 4 && bytes.Equal(binary[0:4], binaryformat.Magic) {
+		internal, err = binaryformat.DecodeModule(binary, r.enabledFeatures,
+			r.memoryLimitPages, r.memoryCapacityFromMax, !r.dwarfDisabled, r.storeCustomSections)
+	} else {
+		// Anything but the binary format is decoded as the text format, which errs with the line and column.
+		internal, err = textformat.DecodeModule(binary, r.enabledFeatures, r.memoryLimitPages, r.memoryCapacityFromMax)
 	}
-
-	internal, err := binaryformat.DecodeModule(binary, r.enabledFeatures,
-		r.memoryLimitPages, r.memoryCapacityFromMax, !r.dwarfDisabled, r.storeCustomSections)
 	if err != nil {
 		return nil, err
 	} else if err = internal.Validate(r.enabledFeatures); err != nil {
@@ -187,11 +232,13 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, err
 	}
 
-	internal.AssignModuleID(binary)
+	internal.ReserveMemory = r.memoryReservation
+	internal.AssignModuleID(binary, r.fuelMetering, r.coverage)
 
 	// Now that the module is validated, cache the function and memory definitions.
 	internal.BuildFunctionDefinitions()
 	internal.BuildMemoryDefinitions()
+	internal.BuildTableDefinitions()
 
 	c := &compiledModule{module: internal, compiledEngine: r.store.Engine}
 
@@ -200,7 +247,7 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, err
 	}
 
-	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination); err != nil {
+	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination, r.fuelMetering, r.coverage); err != nil {
 		return nil, err
 	}
 	return c, nil
@@ -244,6 +291,29 @@ func (r *runtime) InstantiateModule(
 	ctx context.Context,
 	compiled CompiledModule,
 	mConfig ModuleConfig,
+) (mod api.Module, err error) {
+	return r.instantiateModule(ctx, compiled, mConfig, nil)
+}
+
+// InstantiateModuleFromSnapshot implements Runtime.InstantiateModuleFromSnapshot
+func (r *runtime) InstantiateModuleFromSnapshot(
+	ctx context.Context,
+	compiled CompiledModule,
+	s Snapshot,
+	mConfig ModuleConfig,
+) (mod api.Module, err error) {
+	snapshot, ok := s.(*snapshot)
+	if !ok || snapshot == nil {
+		return nil, errors.New("snapshot wasn't taken by wazero")
+	}
+	return r.instantiateModule(ctx, compiled, mConfig, snapshot.s)
+}
+
+func (r *runtime) instantiateModule(
+	ctx context.Context,
+	compiled CompiledModule,
+	mConfig ModuleConfig,
+	snapshot *wasm.Snapshot,
 ) (mod api.Module, err error) {
 	if err := r.failIfClosed(); err != nil {
 		return nil, err
@@ -263,7 +333,11 @@ func (r *runtime) InstantiateModule(
 	}
 
 	// Instantiate the module.
-	mod, err = r.store.Instantiate(ctx, code.module, name, sysCtx)
+	if snapshot == nil {
+		mod, err = r.store.Instantiate(ctx, code.module, name, sysCtx)
+	} else {
+		mod, err = r.store.InstantiateSnapshot(ctx, code.module, name, sysCtx, snapshot)
+	}
 	if err != nil {
 		// If there was an error, don't leak the compiled module.
 		if code.closeWithModule {
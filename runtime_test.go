@@ -7,6 +7,7 @@ import (
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
@@ -110,7 +111,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection, but not exported",
 			wasm: binaryformat.EncodeModule(&wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []*wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 			}),
 			expected: func(compiled CompiledModule) {
 				require.Nil(t, compiled.ImportedMemories())
@@ -120,7 +121,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection exported",
 			wasm: binaryformat.EncodeModule(&wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []*wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 				ExportSection: []*wasm.Export{{
 					Type:  wasm.ExternTypeMemory,
 					Name:  "memory",
@@ -136,6 +137,21 @@ func TestRuntime_CompileModule(t *testing.T) {
 				require.True(t, ok)
 			},
 		},
+		{
+			name: "text format",
+			wasm: []byte(`(module $math
+	(func $add (export "add") (param $x i32) (param $y i32) (result i32)
+		(i32.add (local.get $x) (local.get $y))
+	)
+)`),
+			expected: func(compiled CompiledModule) {
+				require.Equal(t, "math", compiled.Name())
+				f := compiled.ExportedFunctions()["add"]
+				require.Equal(t, "math.add", f.DebugName())
+				require.Equal(t, []string{"x", "y"}, f.ParamNames())
+				require.Equal(t, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, f.ParamTypes())
+			},
+		},
 	}
 
 	r := NewRuntime(testCtx)
@@ -171,9 +187,34 @@ func TestRuntime_CompileModule_Errors(t *testing.T) {
 			wasm:        append(binaryformat.Magic, []byte("yolo")...),
 			expectedErr: "invalid version header",
 		},
+		{
+			name:        "empty",
+			wasm:        []byte{},
+			expectedErr: "1:1: expected module, but was EOF",
+		},
+		{
+			name:        "whitespace",
+			wasm:        []byte(" \n\t"),
+			expectedErr: "2:2: expected module, but was EOF",
+		},
+		{
+			name:        "comment",
+			wasm:        []byte(";;"),
+			expectedErr: "1:3: expected module, but was EOF",
+		},
+		{
+			name:        "truncated binary",
+			wasm:        binaryformat.Magic[:3],
+			expectedErr: "1:1: unexpected character '\\x00'",
+		},
+		{
+			name:        "invalid text",
+			wasm:        []byte("(module\n  (func i32.ad))"),
+			expectedErr: "2:9: unknown instruction i32.ad in module.func[0]",
+		},
 		{
 			name:        "memory has too many pages",
-			wasm:        binaryformat.EncodeModule(&wasm.Module{MemorySection: &wasm.Memory{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}),
+			wasm:        binaryformat.EncodeModule(&wasm.Module{MemorySection: []*wasm.Memory{{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}}),
 			expectedErr: "section memory: max 70000 pages (4 Gi) over limit of 65536 pages (4 Gi)",
 		},
 	}
@@ -191,6 +232,28 @@ func TestRuntime_CompileModule_Errors(t *testing.T) {
 	}
 }
 
+func TestRuntime_InstantiateModuleFromBinary_Text(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(x uint32) uint32 { return x * 2 }).Export("double").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	module, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+	(import "env" "double" (func $double (param i32) (result i32)))
+	(func (export "quadruple") (param $x i32) (result i32)
+		(call $double (call $double (local.get $x)))
+	)
+)`))
+	require.NoError(t, err)
+
+	results, err := module.ExportedFunction("quadruple").Call(testCtx, 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{12}, results)
+}
+
 // TestModule_Memory only covers a couple cases to avoid duplication of internal/wasm/runtime_test.go
 func TestModule_Memory(t *testing.T) {
 	tests := []struct {
@@ -206,7 +269,7 @@ func TestModule_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			wasm: binaryformat.EncodeModule(&wasm.Module{
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []*wasm.Memory{{Min: 1}},
 				ExportSection: []*wasm.Export{{Name: "memory", Type: api.ExternTypeMemory}},
 			}),
 			expected:    true,
@@ -240,6 +303,80 @@ func TestModule_Memory(t *testing.T) {
 	}
 }
 
+func TestModule_Table(t *testing.T) {
+	wat := []byte(`(module
+  (type $i32 (func (result i32)))
+  (table (export "funcs") 2 4 funcref)
+  (table (export "externs") 1 externref)
+  (func $one (result i32) i32.const 1)
+  (elem (i32.const 0) $one)
+  (func (export "call") (param i32) (result i32)
+    (call_indirect (type $i32) (local.get 0))))`)
+
+	for _, config := range []RuntimeConfig{NewRuntimeConfig(), NewRuntimeConfigInterpreter()} {
+		r := NewRuntimeWithConfig(testCtx, config)
+		defer r.Close(testCtx)
+
+		compiled, err := r.CompileModule(testCtx, wat)
+		require.NoError(t, err)
+		require.Nil(t, compiled.ImportedTables())
+		require.Equal(t, 2, len(compiled.ExportedTables()))
+
+		module, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+		require.NoError(t, err)
+
+		require.Nil(t, module.ExportedTable("call"))
+		defs := module.ExportedTableDefinitions()
+		require.Equal(t, 2, len(defs))
+
+		funcs := module.ExportedTable("funcs")
+		def := funcs.Definition()
+		require.Equal(t, defs["funcs"], def)
+		require.Equal(t, api.ValueTypeFuncref, def.Type())
+		require.Equal(t, uint32(2), def.Min())
+		max, ok := def.Max()
+		require.True(t, ok)
+		require.Equal(t, uint32(4), max)
+
+		require.Equal(t, uint32(2), funcs.Size())
+		one, ok := funcs.Get(0)
+		require.True(t, ok)
+		require.NotEqual(t, uint64(0), one)
+		null, ok := funcs.Get(1)
+		require.True(t, ok)
+		require.Zero(t, null)
+		_, ok = funcs.Get(2)
+		require.False(t, ok)
+
+		// Set the function read from the table, and call it via call_indirect.
+		require.True(t, funcs.Set(1, one))
+		require.False(t, funcs.Set(2, one))
+		results, err := module.ExportedFunction("call").Call(testCtx, 1)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1}, results)
+
+		previousSize, ok := funcs.Grow(2, one)
+		require.True(t, ok)
+		require.Equal(t, uint32(2), previousSize)
+		require.Equal(t, uint32(4), funcs.Size())
+		results, err = module.ExportedFunction("call").Call(testCtx, 3)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1}, results)
+		_, ok = funcs.Grow(1, 0)
+		require.False(t, ok)
+
+		externs := module.ExportedTable("externs")
+		require.Equal(t, api.ValueTypeExternref, externs.Definition().Type())
+		_, ok = externs.Definition().Max()
+		require.False(t, ok)
+		ref := api.EncodeExternref(uintptr(unsafe.Pointer(t)))
+		require.True(t, externs.Set(0, ref))
+		v, ok := externs.Get(0)
+		require.True(t, ok)
+		require.Equal(t, ref, v)
+	}
+}
+
 // TestModule_Global only covers a couple cases to avoid duplication of internal/wasm/global_test.go
 func TestModule_Global(t *testing.T) {
 	globalVal := int64(100) // intentionally a value that differs in signed vs unsigned encoding
@@ -306,7 +443,7 @@ func TestModule_Global(t *testing.T) {
 
 			code := &compiledModule{module: tc.module}
 
-			err := r.store.Engine.CompileModule(testCtx, code.module, nil, false)
+			err := r.store.Engine.CompileModule(testCtx, code.module, nil, false, false, false)
 			require.NoError(t, err)
 
 			// Instantiate the module and get the export of the above global
@@ -378,7 +515,7 @@ func TestRuntime_InstantiateModuleFromBinary_DoesntEnforce_Start(t *testing.T) {
 	defer r.Close(testCtx)
 
 	binary := binaryformat.EncodeModule(&wasm.Module{
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []*wasm.Memory{{Min: 1}},
 		ExportSection: []*wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
 	})
 
@@ -723,11 +860,16 @@ type mockEngine struct {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *mockEngine) CompileModule(_ context.Context, module *wasm.Module, _ []experimental.FunctionListener, _ bool) error {
+func (e *mockEngine) CompileModule(_ context.Context, module *wasm.Module, _ []experimental.FunctionListener, _, _, _ bool) error {
 	e.cachedModules[module] = struct{}{}
 	return nil
 }
 
+// Coverage implements the same method as documented on wasm.Engine.
+func (e *mockEngine) Coverage(*wasm.Module) [][]wasm.CoverageBlock {
+	return nil
+}
+
 // CompiledModuleCount implements the same method as documented on wasm.Engine.
 func (e *mockEngine) CompiledModuleCount() uint32 {
 	return uint32(len(e.cachedModules))
@@ -0,0 +1,74 @@
+package wazero
+
+import (
+	"errors"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// Snapshot is the state of the memories, globals and tables defined by a module, as well as which of its data and
+// element segments were dropped, as captured by TakeSnapshot.
+//
+// A Snapshot allows running the initialization of a module once, and then creating many instances already
+// initialized, via Runtime.InstantiateModuleFromSnapshot or a pre-initialized binary.
+//
+// Note: This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+type Snapshot interface {
+	// PreInitializedBinary returns a new WebAssembly binary (%.wasm) of the module the snapshot was taken from, where
+	// the initial state is the one of the snapshot, and without the start function.
+	//
+	// The result requires api.CoreFeatureBulkMemoryOperations when the module has active data or element segments,
+	// as they are replaced by passive or declarative ones to keep their indexes.
+	PreInitializedBinary() ([]byte, error)
+}
+
+// TakeSnapshot captures the state of the memories, globals and tables defined by the module, which must not be used
+// by any call meanwhile.
+//
+// Here's an example of initializing a module once, and then instantiating copies of it:
+//
+//	mod, _ := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithStartFunctions("init"))
+//	snapshot, _ := wazero.TakeSnapshot(mod)
+//	_ = mod.Close(ctx)
+//
+//	for i := 0; i < n; i++ {
+//		copy, _ := r.InstantiateModuleFromSnapshot(ctx, compiled, snapshot,
+//			wazero.NewModuleConfig().WithName(fmt.Sprint(i)).WithStartFunctions())
+//		...
+//	}
+//
+// # Notes
+//
+//   - The state of imported memories, globals and tables isn't captured, as they belong to other modules.
+//   - This fails when a table or global holds a reference which isn't null or a function of the module, such as an
+//     externref.
+func TakeSnapshot(mod api.Module) (Snapshot, error) {
+	callCtx, ok := mod.(*wasm.CallContext)
+	if !ok {
+		return nil, errors.New("module wasn't instantiated by wazero")
+	}
+	s, err := callCtx.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{s: s}, nil
+}
+
+// snapshot implements Snapshot
+type snapshot struct {
+	s *wasm.Snapshot
+}
+
+// PreInitializedBinary implements Snapshot.PreInitializedBinary
+func (s *snapshot) PreInitializedBinary() ([]byte, error) {
+	if s.s.Source.IsHostModule {
+		return nil, errors.New("cannot encode a host module")
+	}
+	m, err := s.s.PreInitialize()
+	if err != nil {
+		return nil, err
+	}
+	return binaryformat.EncodeModule(m), nil
+}
@@ -0,0 +1,86 @@
+package wazero
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestTakeSnapshot_Errors(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	t.Run("not a wazero module", func(t *testing.T) {
+		var mod api.Module
+		_, err := TakeSnapshot(mod)
+		require.EqualError(t, err, "module wasn't instantiated by wazero")
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		mod, err := r.InstantiateModule(testCtx, mustCompile(t, r, `(module)`), NewModuleConfig().WithName("closed"))
+		require.NoError(t, err)
+		require.NoError(t, mod.CloseWithExitCode(testCtx, 2))
+
+		_, err = TakeSnapshot(mod)
+		require.EqualError(t, err, `module "closed" closed with exit_code(2)`)
+	})
+
+	t.Run("host module", func(t *testing.T) {
+		host, err := r.NewHostModuleBuilder("host").Instantiate(testCtx)
+		require.NoError(t, err)
+
+		snapshot, err := TakeSnapshot(host)
+		require.NoError(t, err)
+		_, err = snapshot.PreInitializedBinary()
+		require.EqualError(t, err, "cannot encode a host module")
+	})
+}
+
+func TestRuntime_InstantiateModuleFromSnapshot_Errors(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	const wat = `(module (global (mut i32) (i32.const 1)))`
+	mod, err := r.InstantiateModule(testCtx, mustCompile(t, r, wat), NewModuleConfig().WithName("source"))
+	require.NoError(t, err)
+	snapshot, err := TakeSnapshot(mod)
+	require.NoError(t, err)
+
+	t.Run("another module", func(t *testing.T) {
+		_, err = r.InstantiateModuleFromSnapshot(testCtx, mustCompile(t, r, `(module (memory 1))`), snapshot,
+			NewModuleConfig().WithName("other"))
+		require.EqualError(t, err, "snapshot was taken from another module")
+
+		// The name isn't taken by the failed instantiation.
+		require.Nil(t, r.Module("other"))
+	})
+
+	t.Run("another module with the same definitions", func(t *testing.T) {
+		_, err = r.InstantiateModuleFromSnapshot(testCtx, mustCompile(t, r, `(module (global (mut i32) (i32.const 2)))`),
+			snapshot, NewModuleConfig().WithName("other"))
+		require.EqualError(t, err, "snapshot was taken from another module")
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		_, err = r.InstantiateModuleFromSnapshot(testCtx, mustCompile(t, r, wat), nil, NewModuleConfig())
+		require.EqualError(t, err, "snapshot wasn't taken by wazero")
+	})
+
+	t.Run("not taken by wazero", func(t *testing.T) {
+		_, err = r.InstantiateModuleFromSnapshot(testCtx, mustCompile(t, r, wat), otherSnapshot{}, NewModuleConfig())
+		require.EqualError(t, err, "snapshot wasn't taken by wazero")
+	})
+}
+
+// otherSnapshot is a Snapshot not implemented by wazero.
+type otherSnapshot struct{}
+
+// PreInitializedBinary implements Snapshot.PreInitializedBinary
+func (otherSnapshot) PreInitializedBinary() ([]byte, error) { return nil, nil }
+
+func mustCompile(t *testing.T, r Runtime, wat string) CompiledModule {
+	compiled, err := r.CompileModule(testCtx, []byte(wat))
+	require.NoError(t, err)
+	return compiled
+}
@@ -0,0 +1,376 @@
+package wazero
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// NumericValue are the Go types that map to a WebAssembly numeric
+// api.ValueType without reflection:
+//
+//   - int32 and uint32 map to api.ValueTypeI32
+//   - int64 and uint64 map to api.ValueTypeI64
+//   - float32 maps to api.ValueTypeF32
+//   - float64 maps to api.ValueTypeF64
+type NumericValue interface {
+	int32 | uint32 | int64 | uint64 | float32 | float64
+}
+
+// TypedHostFunction is an api.GoModuleFunction with a WebAssembly signature
+// derived from its Go type parameters. Create one with generic functions such
+// as HostFunc2 or HostVoidFunc1, and define it with
+// HostFunctionBuilder.WithTypedFunction.
+//
+// Unlike HostFunctionBuilder.WithFunc, the resulting function doesn't use
+// reflection, so doesn't allocate when called.
+type TypedHostFunction struct {
+	fn              api.GoModuleFunction
+	params, results []api.ValueType
+}
+
+// HostFunc0 returns a TypedHostFunction with no parameters and one result.
+func HostFunc0[R NumericValue](fn func(context.Context, api.Module) R) TypedHostFunction {
+	return hostFunc(func(ctx context.Context, mod api.Module, stack []uint64) R {
+		return fn(ctx, mod)
+	})
+}
+
+// HostFunc1 returns a TypedHostFunction with 1 parameter and one result.
+func HostFunc1[P1, R NumericValue](fn func(context.Context, api.Module, P1) R) TypedHostFunction {
+	return hostFunc(func(ctx context.Context, mod api.Module, stack []uint64) R {
+		return fn(ctx, mod, decode[P1](stack[0]))
+	}, valueType[P1]())
+}
+
+// HostFunc2 returns a TypedHostFunction with 2 parameters and one result.
+//
+// For example, here's an addition function:
+//
+//	builder.NewFunctionBuilder().
+//		WithTypedFunction(wazero.HostFunc2(func(ctx context.Context, mod api.Module, x, y uint32) uint32 {
+//			return x + y
+//		})).
+//		Export("add")
+func HostFunc2[P1, P2, R NumericValue](fn func(context.Context, api.Module, P1, P2) R) TypedHostFunction {
+	return hostFunc(func(ctx context.Context, mod api.Module, stack []uint64) R {
+		return fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]))
+	}, valueType[P1](), valueType[P2]())
+}
+
+// HostFunc3 returns a TypedHostFunction with 3 parameters and one result.
+func HostFunc3[P1, P2, P3, R NumericValue](fn func(context.Context, api.Module, P1, P2, P3) R) TypedHostFunction {
+	return hostFunc(func(ctx context.Context, mod api.Module, stack []uint64) R {
+		return fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]), decode[P3](stack[2]))
+	}, valueType[P1](), valueType[P2](), valueType[P3]())
+}
+
+// HostFunc4 returns a TypedHostFunction with 4 parameters and one result.
+func HostFunc4[P1, P2, P3, P4, R NumericValue](fn func(context.Context, api.Module, P1, P2, P3, P4) R) TypedHostFunction {
+	return hostFunc(func(ctx context.Context, mod api.Module, stack []uint64) R {
+		return fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]), decode[P3](stack[2]), decode[P4](stack[3]))
+	}, valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]())
+}
+
+// HostVoidFunc0 returns a TypedHostFunction with no parameters or results.
+func HostVoidFunc0(fn func(context.Context, api.Module)) TypedHostFunction {
+	return hostVoidFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		fn(ctx, mod)
+	})
+}
+
+// HostVoidFunc1 returns a TypedHostFunction with 1 parameter and no result.
+func HostVoidFunc1[P1 NumericValue](fn func(context.Context, api.Module, P1)) TypedHostFunction {
+	return hostVoidFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		fn(ctx, mod, decode[P1](stack[0]))
+	}, valueType[P1]())
+}
+
+// HostVoidFunc2 returns a TypedHostFunction with 2 parameters and no result.
+func HostVoidFunc2[P1, P2 NumericValue](fn func(context.Context, api.Module, P1, P2)) TypedHostFunction {
+	return hostVoidFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]))
+	}, valueType[P1](), valueType[P2]())
+}
+
+// HostVoidFunc3 returns a TypedHostFunction with 3 parameters and no result.
+func HostVoidFunc3[P1, P2, P3 NumericValue](fn func(context.Context, api.Module, P1, P2, P3)) TypedHostFunction {
+	return hostVoidFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]), decode[P3](stack[2]))
+	}, valueType[P1](), valueType[P2](), valueType[P3]())
+}
+
+// HostVoidFunc4 returns a TypedHostFunction with 4 parameters and no result.
+func HostVoidFunc4[P1, P2, P3, P4 NumericValue](fn func(context.Context, api.Module, P1, P2, P3, P4)) TypedHostFunction {
+	return hostVoidFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		fn(ctx, mod, decode[P1](stack[0]), decode[P2](stack[1]), decode[P3](stack[2]), decode[P4](stack[3]))
+	}, valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]())
+}
+
+// GuestFunc0 returns a Go function that calls the api.Function, which must
+// have no parameters and one result.
+func GuestFunc0[R NumericValue](fn api.Function) (func(context.Context) (R, error), error) {
+	c, err := newGuestCaller(fn, []api.ValueType{valueType[R]()})
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (R, error) {
+		return callWithResult[R](ctx, c)
+	}, nil
+}
+
+// GuestFunc1 returns a Go function that calls the api.Function, which must
+// have 1 parameter and one result.
+func GuestFunc1[P1, R NumericValue](fn api.Function) (func(context.Context, P1) (R, error), error) {
+	c, err := newGuestCaller(fn, []api.ValueType{valueType[R]()}, valueType[P1]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1) (R, error) {
+		c.stack[0] = encode(p1)
+		return callWithResult[R](ctx, c)
+	}, nil
+}
+
+// GuestFunc2 returns a Go function that calls the api.Function, which must
+// have 2 parameters and one result.
+//
+// For example, this calls an exported addition function:
+//
+//	add, err := wazero.GuestFunc2[uint32, uint32, uint32](mod.ExportedFunction("add"))
+//	if err != nil {
+//		return err // the function doesn't have the signature (i32, i32) -> i32
+//	}
+//	sum, err := add(ctx, 1, 2)
+//
+// An error is returned if the function signature doesn't match the type
+// parameters. The returned function reuses its stack between calls via
+// api.Function CallWithStack, so like api.Function, it is not goroutine-safe.
+func GuestFunc2[P1, P2, R NumericValue](fn api.Function) (func(context.Context, P1, P2) (R, error), error) {
+	c, err := newGuestCaller(fn, []api.ValueType{valueType[R]()}, valueType[P1](), valueType[P2]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2) (R, error) {
+		c.stack[0], c.stack[1] = encode(p1), encode(p2)
+		return callWithResult[R](ctx, c)
+	}, nil
+}
+
+// GuestFunc3 returns a Go function that calls the api.Function, which must
+// have 3 parameters and one result.
+func GuestFunc3[P1, P2, P3, R NumericValue](fn api.Function) (func(context.Context, P1, P2, P3) (R, error), error) {
+	c, err := newGuestCaller(fn, []api.ValueType{valueType[R]()}, valueType[P1](), valueType[P2](), valueType[P3]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2, p3 P3) (R, error) {
+		c.stack[0], c.stack[1], c.stack[2] = encode(p1), encode(p2), encode(p3)
+		return callWithResult[R](ctx, c)
+	}, nil
+}
+
+// GuestFunc4 returns a Go function that calls the api.Function, which must
+// have 4 parameters and one result.
+func GuestFunc4[P1, P2, P3, P4, R NumericValue](fn api.Function) (func(context.Context, P1, P2, P3, P4) (R, error), error) {
+	c, err := newGuestCaller(fn, []api.ValueType{valueType[R]()}, valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2, p3 P3, p4 P4) (R, error) {
+		c.stack[0], c.stack[1], c.stack[2], c.stack[3] = encode(p1), encode(p2), encode(p3), encode(p4)
+		return callWithResult[R](ctx, c)
+	}, nil
+}
+
+// GuestVoidFunc0 returns a Go function that calls the api.Function, which must
+// have no parameters or results.
+func GuestVoidFunc0(fn api.Function) (func(context.Context) error, error) {
+	c, err := newGuestCaller(fn, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		return c.call(ctx)
+	}, nil
+}
+
+// GuestVoidFunc1 returns a Go function that calls the api.Function, which must
+// have 1 parameter and no result.
+func GuestVoidFunc1[P1 NumericValue](fn api.Function) (func(context.Context, P1) error, error) {
+	c, err := newGuestCaller(fn, nil, valueType[P1]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1) error {
+		c.stack[0] = encode(p1)
+		return c.call(ctx)
+	}, nil
+}
+
+// GuestVoidFunc2 returns a Go function that calls the api.Function, which must
+// have 2 parameters and no result.
+func GuestVoidFunc2[P1, P2 NumericValue](fn api.Function) (func(context.Context, P1, P2) error, error) {
+	c, err := newGuestCaller(fn, nil, valueType[P1](), valueType[P2]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2) error {
+		c.stack[0], c.stack[1] = encode(p1), encode(p2)
+		return c.call(ctx)
+	}, nil
+}
+
+// GuestVoidFunc3 returns a Go function that calls the api.Function, which must
+// have 3 parameters and no result.
+func GuestVoidFunc3[P1, P2, P3 NumericValue](fn api.Function) (func(context.Context, P1, P2, P3) error, error) {
+	c, err := newGuestCaller(fn, nil, valueType[P1](), valueType[P2](), valueType[P3]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2, p3 P3) error {
+		c.stack[0], c.stack[1], c.stack[2] = encode(p1), encode(p2), encode(p3)
+		return c.call(ctx)
+	}, nil
+}
+
+// GuestVoidFunc4 returns a Go function that calls the api.Function, which must
+// have 4 parameters and no result.
+func GuestVoidFunc4[P1, P2, P3, P4 NumericValue](fn api.Function) (func(context.Context, P1, P2, P3, P4) error, error) {
+	c, err := newGuestCaller(fn, nil, valueType[P1](), valueType[P2](), valueType[P3](), valueType[P4]())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, p1 P1, p2 P2, p3 P3, p4 P4) error {
+		c.stack[0], c.stack[1], c.stack[2], c.stack[3] = encode(p1), encode(p2), encode(p3), encode(p4)
+		return c.call(ctx)
+	}, nil
+}
+
+// hostFunc returns a TypedHostFunction with the given parameter types and
+// the result type R, where fn decodes the parameters from the stack.
+func hostFunc[R NumericValue](fn func(context.Context, api.Module, []uint64) R, params ...api.ValueType) TypedHostFunction {
+	return TypedHostFunction{
+		fn: api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = encode(fn(ctx, mod, stack))
+		}),
+		params:  params,
+		results: []api.ValueType{valueType[R]()},
+	}
+}
+
+// hostVoidFunc is like hostFunc, except the function has no result.
+func hostVoidFunc(fn func(context.Context, api.Module, []uint64), params ...api.ValueType) TypedHostFunction {
+	return TypedHostFunction{fn: api.GoModuleFunc(fn), params: params}
+}
+
+// guestCaller calls an api.Function with a stack reused between calls, which
+// the GuestFunc functions encode the parameters to.
+type guestCaller struct {
+	fn    api.Function
+	stack []uint64
+}
+
+// newGuestCaller returns a guestCaller for the function, or an error if it
+// doesn't have the given parameter and result types.
+func newGuestCaller(fn api.Function, results []api.ValueType, params ...api.ValueType) (*guestCaller, error) {
+	if err := checkSignature(fn, params, results); err != nil {
+		return nil, err
+	}
+	size := len(params)
+	if len(results) > size {
+		size = len(results)
+	}
+	return &guestCaller{fn: fn, stack: make([]uint64, size)}, nil
+}
+
+func (c *guestCaller) call(ctx context.Context) error {
+	return c.fn.CallWithStack(ctx, c.stack)
+}
+
+// callWithResult calls the function, and decodes its result of type R.
+func callWithResult[R NumericValue](ctx context.Context, c *guestCaller) (R, error) {
+	if err := c.call(ctx); err != nil {
+		var zero R
+		return zero, err
+	}
+	return decode[R](c.stack[0]), nil
+}
+
+// checkSignature returns an error if the function doesn't have the given
+// parameter and result types.
+func checkSignature(fn api.Function, params, results []api.ValueType) error {
+	def := fn.Definition()
+	if !valueTypesEqual(def.ParamTypes(), params) || !valueTypesEqual(def.ResultTypes(), results) {
+		expected := &wasm.FunctionType{Params: params, Results: results}
+		actual := &wasm.FunctionType{Params: def.ParamTypes(), Results: def.ResultTypes()}
+		return fmt.Errorf("function[%s] has signature %s, but expected %s", def.DebugName(), actual, expected)
+	}
+	return nil
+}
+
+func valueTypesEqual(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// valueType returns the api.ValueType of the NumericValue type T.
+func valueType[T NumericValue]() api.ValueType {
+	var v T
+	switch any(v).(type) {
+	case int32, uint32:
+		return api.ValueTypeI32
+	case int64, uint64:
+		return api.ValueTypeI64
+	case float32:
+		return api.ValueTypeF32
+	default: // float64
+		return api.ValueTypeF64
+	}
+}
+
+// encode encodes the value according to its api.ValueType.
+func encode[T NumericValue](v T) uint64 {
+	switch v := any(v).(type) {
+	case int32:
+		return api.EncodeI32(v)
+	case uint32:
+		return api.EncodeU32(v)
+	case int64:
+		return api.EncodeI64(v)
+	case uint64:
+		return v
+	case float32:
+		return api.EncodeF32(v)
+	default: // float64
+		return api.EncodeF64(v.(float64))
+	}
+}
+
+// decode decodes the value according to the api.ValueType of T.
+func decode[T NumericValue](v uint64) (ret T) {
+	switch p := any(&ret).(type) {
+	case *int32:
+		*p = api.DecodeI32(v)
+	case *uint32:
+		*p = api.DecodeU32(v)
+	case *int64:
+		*p = int64(v)
+	case *uint64:
+		*p = v
+	case *float32:
+		*p = api.DecodeF32(v)
+	case *float64:
+		*p = api.DecodeF64(v)
+	}
+	return
+}
@@ -0,0 +1,225 @@
+package wazero
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestTypedHostFunction(t *testing.T) {
+	i32, i64, f32, f64 := api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32, api.ValueTypeF64
+
+	var called []uint64
+	tests := []struct {
+		name                             string
+		fn                               TypedHostFunction
+		expectedParams, expectedResults  []api.ValueType
+		stack, expectedStack, expectedIn []uint64
+	}{
+		{
+			name: "HostFunc0",
+			fn: HostFunc0(func(context.Context, api.Module) float64 {
+				return 1.5
+			}),
+			expectedResults: []api.ValueType{f64},
+			stack:           []uint64{0},
+			expectedStack:   []uint64{api.EncodeF64(1.5)},
+		},
+		{
+			name: "HostFunc1",
+			fn: HostFunc1(func(_ context.Context, _ api.Module, x int32) int32 {
+				return -x
+			}),
+			expectedParams:  []api.ValueType{i32},
+			expectedResults: []api.ValueType{i32},
+			stack:           []uint64{api.EncodeI32(5)},
+			expectedStack:   []uint64{api.EncodeI32(-5)},
+		},
+		{
+			name: "HostFunc2",
+			fn: HostFunc2(func(_ context.Context, _ api.Module, x, y uint32) uint32 {
+				return x + y
+			}),
+			expectedParams:  []api.ValueType{i32, i32},
+			expectedResults: []api.ValueType{i32},
+			stack:           []uint64{1, 2},
+			expectedStack:   []uint64{3, 2},
+		},
+		{
+			name: "HostFunc3",
+			fn: HostFunc3(func(_ context.Context, _ api.Module, x int64, y uint64, z float32) float32 {
+				return float32(x) + float32(y) + z
+			}),
+			expectedParams:  []api.ValueType{i64, i64, f32},
+			expectedResults: []api.ValueType{f32},
+			stack:           []uint64{api.EncodeI64(-1), 2, api.EncodeF32(0.5)},
+			expectedStack:   []uint64{api.EncodeF32(1.5), 2, api.EncodeF32(0.5)},
+		},
+		{
+			name: "HostFunc4",
+			fn: HostFunc4(func(_ context.Context, _ api.Module, a, b, c, d uint64) uint64 {
+				return a * b * c * d
+			}),
+			expectedParams:  []api.ValueType{i64, i64, i64, i64},
+			expectedResults: []api.ValueType{i64},
+			stack:           []uint64{1, 2, 3, 4},
+			expectedStack:   []uint64{24, 2, 3, 4},
+		},
+		{
+			name: "HostVoidFunc0",
+			fn: HostVoidFunc0(func(context.Context, api.Module) {
+				called = append(called, 0)
+			}),
+			expectedIn: []uint64{0},
+		},
+		{
+			name: "HostVoidFunc1",
+			fn: HostVoidFunc1(func(_ context.Context, _ api.Module, x float32) {
+				called = append(called, uint64(x))
+			}),
+			expectedParams: []api.ValueType{f32},
+			stack:          []uint64{api.EncodeF32(2)},
+			expectedStack:  []uint64{api.EncodeF32(2)},
+			expectedIn:     []uint64{2},
+		},
+		{
+			name: "HostVoidFunc2",
+			fn: HostVoidFunc2(func(_ context.Context, _ api.Module, x int32, y float64) {
+				called = append(called, uint64(x), uint64(y))
+			}),
+			expectedParams: []api.ValueType{i32, f64},
+			stack:          []uint64{api.EncodeI32(1), api.EncodeF64(2)},
+			expectedStack:  []uint64{api.EncodeI32(1), api.EncodeF64(2)},
+			expectedIn:     []uint64{1, 2},
+		},
+		{
+			name: "HostVoidFunc3",
+			fn: HostVoidFunc3(func(_ context.Context, _ api.Module, x, y, z uint32) {
+				called = append(called, uint64(x), uint64(y), uint64(z))
+			}),
+			expectedParams: []api.ValueType{i32, i32, i32},
+			stack:          []uint64{1, 2, 3},
+			expectedStack:  []uint64{1, 2, 3},
+			expectedIn:     []uint64{1, 2, 3},
+		},
+		{
+			name: "HostVoidFunc4",
+			fn: HostVoidFunc4(func(_ context.Context, _ api.Module, a, b, c, d int64) {
+				called = append(called, uint64(a), uint64(b), uint64(c), uint64(d))
+			}),
+			expectedParams: []api.ValueType{i64, i64, i64, i64},
+			stack:          []uint64{1, 2, 3, 4},
+			expectedStack:  []uint64{1, 2, 3, 4},
+			expectedIn:     []uint64{1, 2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			called = nil
+			require.Equal(t, tc.expectedParams, tc.fn.params)
+			require.Equal(t, tc.expectedResults, tc.fn.results)
+
+			tc.fn.fn.Call(testCtx, nil, tc.stack)
+			require.Equal(t, tc.expectedStack, tc.stack)
+			require.Equal(t, tc.expectedIn, called)
+		})
+	}
+}
+
+func TestTypedHostFunction_NoAllocations(t *testing.T) {
+	fn := HostFunc2(func(_ context.Context, _ api.Module, x, y float64) float64 {
+		return x + y
+	}).fn
+
+	stack := []uint64{api.EncodeF64(1), api.EncodeF64(2)}
+	allocs := testing.AllocsPerRun(100, func() {
+		fn.Call(testCtx, nil, stack)
+		stack[1] = api.EncodeF64(2)
+	})
+	require.Equal(t, float64(0), allocs)
+}
+
+func TestGuestFunc(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	var stored []int64
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithTypedFunction(HostFunc2(func(_ context.Context, _ api.Module, x, y uint32) uint32 {
+			return x + y
+		})).
+		Export("add").
+		NewFunctionBuilder().
+		WithTypedFunction(HostVoidFunc1(func(_ context.Context, _ api.Module, v int64) {
+			stored = append(stored, v)
+		})).
+		Export("store").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module
+  (import "env" "add" (func $add (param i32 i32) (result i32)))
+  (import "env" "store" (func $store (param i64)))
+  (func (export "add") (param i32 i32) (result i32)
+    (call $add (local.get 0) (local.get 1)))
+  (func (export "store") (param i64)
+    (call $store (local.get 0)))
+  (func (export "div") (param f64 f64) (result f64)
+    (f64.div (local.get 0) (local.get 1)))
+  (func (export "trap") unreachable))`))
+	require.NoError(t, err)
+
+	add, err := GuestFunc2[uint32, uint32, uint32](mod.ExportedFunction("add"))
+	require.NoError(t, err)
+	sum, err := add(testCtx, 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), sum)
+
+	// The stack is reused, so calls don't allocate.
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = add(testCtx, 1, 2)
+	})
+	require.Equal(t, float64(0), allocs)
+
+	store, err := GuestVoidFunc1[int64](mod.ExportedFunction("store"))
+	require.NoError(t, err)
+	require.NoError(t, store(testCtx, -1))
+	require.Equal(t, []int64{-1}, stored)
+
+	div, err := GuestFunc2[float64, float64, float64](mod.ExportedFunction("div"))
+	require.NoError(t, err)
+	quotient, err := div(testCtx, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, math.Inf(1), quotient)
+
+	trap, err := GuestVoidFunc0(mod.ExportedFunction("trap"))
+	require.NoError(t, err)
+	require.Error(t, trap(testCtx))
+}
+
+func TestGuestFunc_Errors(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, []byte(`(module $guest
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1))))`))
+	require.NoError(t, err)
+	add := mod.ExportedFunction("add")
+
+	_, err = GuestFunc2[int64, int64, int64](add)
+	require.EqualError(t, err, "function[guest.$0] has signature i32i32_i32, but expected i64i64_i64")
+
+	_, err = GuestVoidFunc2[uint32, uint32](add)
+	require.EqualError(t, err, "function[guest.$0] has signature i32i32_i32, but expected i32i32_v")
+
+	_, err = GuestFunc1[int32, int32](add)
+	require.EqualError(t, err, "function[guest.$0] has signature i32i32_i32, but expected i32_i32")
+}